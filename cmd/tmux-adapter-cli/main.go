@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: tmux-adapter-cli [flags] <command> [args]\n\n")
+		fmt.Fprintf(os.Stderr, "Inspect agents and conversations through a running tmux-converter.\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		fmt.Fprintf(os.Stderr, "  agents                      list agents and their active conversations\n")
+		fmt.Fprintf(os.Stderr, "  tail <agent>                follow an agent's conversation (Ctrl-C to stop)\n")
+		fmt.Fprintf(os.Stderr, "  prompt <agent> \"text\"       send a prompt to an agent\n")
+		fmt.Fprintf(os.Stderr, "  export <conversation-id>    write a conversation's full native transcript\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExamples:\n")
+		fmt.Fprintf(os.Stderr, "  tmux-adapter-cli agents\n")
+		fmt.Fprintf(os.Stderr, "  tmux-adapter-cli tail hq-mayor\n")
+		fmt.Fprintf(os.Stderr, "  tmux-adapter-cli --url ws://host:8081/ws/v1 prompt hq-mayor \"run the tests\"\n")
+		fmt.Fprintf(os.Stderr, "  tmux-adapter-cli export claude:hq-mayor:abc123 > mayor.jsonl\n")
+	}
+
+	serverURL := flag.String("url", "ws://localhost:8081/ws/v1", "tmux-converter WebSocket URL (export uses the same host over HTTP)")
+	token := flag.String("token", "", "auth token sent as a Bearer header")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// export reads the transcript over HTTP rather than the WebSocket, whose
+	// snapshots are capped.
+	if args[0] == "export" {
+		if len(args) != 2 {
+			fatalf("usage: export <conversation-id>")
+		}
+		if err := runExport(ctx, *serverURL, *token, args[1]); err != nil && ctx.Err() == nil {
+			fatalf("%v", err)
+		}
+		return
+	}
+
+	c, err := dial(ctx, *serverURL, *token)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer c.close()
+
+	switch cmd := args[0]; cmd {
+	case "agents":
+		err = runAgents(ctx, c)
+	case "tail":
+		if len(args) != 2 {
+			fatalf("usage: tail <agent>")
+		}
+		err = runTail(ctx, c, args[1])
+	case "prompt":
+		if len(args) != 3 {
+			fatalf("usage: prompt <agent> \"text\"")
+		}
+		err = runPrompt(ctx, c, args[1], args[2])
+	default:
+		fatalf("unknown command %q (run with -h for usage)", cmd)
+	}
+	if err != nil && ctx.Err() == nil {
+		fatalf("%v", err)
+	}
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "tmux-adapter-cli: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// message is the subset of the converter protocol the CLI reads and writes.
+type message struct {
+	ID             string                   `json:"id,omitempty"`
+	Type           string                   `json:"type"`
+	OK             *bool                    `json:"ok,omitempty"`
+	Error          string                   `json:"error,omitempty"`
+	Protocol       string                   `json:"protocol,omitempty"`
+	Agent          json.RawMessage          `json:"agent,omitempty"`
+	Agents         []agentInfo              `json:"agents,omitempty"`
+	Prompt         string                   `json:"prompt,omitempty"`
+	ConversationID string                   `json:"conversationId,omitempty"`
+	Events         []conv.ConversationEvent `json:"events,omitempty"`
+	Event          *conv.ConversationEvent  `json:"event,omitempty"`
+	From           string                   `json:"from,omitempty"`
+	To             string                   `json:"to,omitempty"`
+}
+
+type agentInfo struct {
+	Name           string `json:"name"`
+	Runtime        string `json:"runtime"`
+	ConversationID string `json:"conversationId,omitempty"`
+}
+
+type client struct {
+	conn   *websocket.Conn
+	nextID int
+}
+
+func dial(ctx context.Context, url, token string) (*client, error) {
+	opts := &websocket.DialOptions{Subprotocols: []string{"tmux-converter.v1"}}
+	if token != "" {
+		opts.HTTPHeader = http.Header{"Authorization": []string{"Bearer " + token}}
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(dialCtx, url, opts)
+	if err != nil {
+		return nil, fmt.Errorf("connect %s: %w", url, err)
+	}
+	conn.SetReadLimit(64 << 20) // snapshots can be large
+
+	c := &client{conn: conn}
+	resp, err := c.request(ctx, message{Type: "hello", Protocol: "tmux-converter.v1"})
+	if err != nil {
+		_ = conn.Close(websocket.StatusNormalClosure, "")
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	if resp.OK == nil || !*resp.OK {
+		_ = conn.Close(websocket.StatusNormalClosure, "")
+		return nil, fmt.Errorf("handshake rejected: %s", resp.Error)
+	}
+	return c, nil
+}
+
+func (c *client) close() {
+	_ = c.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+func (c *client) send(ctx context.Context, msg message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.conn.Write(ctx, websocket.MessageText, data)
+}
+
+func (c *client) read(ctx context.Context) (message, error) {
+	for {
+		typ, data, err := c.conn.Read(ctx)
+		if err != nil {
+			return message{}, err
+		}
+		if typ != websocket.MessageText {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return message{}, fmt.Errorf("invalid server message: %w", err)
+		}
+		return msg, nil
+	}
+}
+
+// request sends msg with a fresh ID and returns the reply carrying that ID.
+// Unrelated broadcasts received in between are skipped.
+func (c *client) request(ctx context.Context, msg message) (message, error) {
+	c.nextID++
+	msg.ID = fmt.Sprintf("%d", c.nextID)
+	if err := c.send(ctx, msg); err != nil {
+		return message{}, err
+	}
+	for {
+		resp, err := c.read(ctx)
+		if err != nil {
+			return message{}, err
+		}
+		if resp.ID != msg.ID {
+			continue
+		}
+		if resp.Type == "error" {
+			return resp, fmt.Errorf("%s: %s", msg.Type, resp.Error)
+		}
+		return resp, nil
+	}
+}
+
+func runAgents(ctx context.Context, c *client) error {
+	resp, err := c.request(ctx, message{Type: "list-agents"})
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tRUNTIME\tCONVERSATION")
+	for _, a := range resp.Agents {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", a.Name, a.Runtime, a.ConversationID)
+	}
+	return w.Flush()
+}
+
+func runTail(ctx context.Context, c *client, agent string) error {
+	resp, err := c.request(ctx, message{Type: "follow-agent", Agent: json.RawMessage(quote(agent))})
+	if err != nil {
+		return err
+	}
+	if resp.ConversationID == "" {
+		fmt.Fprintf(os.Stderr, "waiting for %s to start a conversation...\n", agent)
+	}
+	printed := make(map[int64]int) // seq → content blocks printed, for updates
+	for _, e := range resp.Events {
+		printEvent(e)
+		printed[e.Seq] = len(e.Content)
+	}
+	for {
+		msg, err := c.read(ctx)
+		if err != nil {
+			return err
+		}
+		switch msg.Type {
+		case "conversation-snapshot":
+			for _, e := range msg.Events {
+				printEvent(e)
+				printed[e.Seq] = len(e.Content)
+			}
+		case "conversation-event":
+			if msg.Event != nil {
+				printEvent(*msg.Event)
+				printed[msg.Event.Seq] = len(msg.Event.Content)
+			}
+		case "conversation-event-updated":
+			// Print the blocks the update added, as a continuation line.
+			if e := msg.Event; e != nil && len(e.Content) > printed[e.Seq] {
+				added := *e
+				added.Content = e.Content[printed[e.Seq]:]
+				printEvent(added)
+				printed[e.Seq] = len(e.Content)
+			}
+		case "conversation-switched":
+			fmt.Printf("--- conversation switched: %s → %s ---\n", msg.From, msg.To)
+		case "error":
+			fmt.Fprintf(os.Stderr, "server error: %s\n", msg.Error)
+		}
+	}
+}
+
+func runPrompt(ctx context.Context, c *client, agent, text string) error {
+	resp, err := c.request(ctx, message{Type: "send-prompt", Agent: json.RawMessage(quote(agent)), Prompt: text})
+	if err != nil {
+		return err
+	}
+	if resp.OK == nil || !*resp.OK {
+		return fmt.Errorf("send-prompt: %s", resp.Error)
+	}
+	fmt.Fprintf(os.Stderr, "prompt sent to %s\n", agent)
+	return nil
+}
+
+// runExport copies the conversation's native transcript from the
+// converter's export endpoint to stdout. Unlike a subscribe-conversation
+// snapshot it is never truncated.
+func runExport(ctx context.Context, wsURL, token, conversationID string) error {
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return fmt.Errorf("parse --url: %w", err)
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	u.Path = "/api/conversations/" + url.PathEscape(conversationID) + "/export"
+	u.RawPath, u.RawQuery = "", ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("export %s: %s: %s", conversationID, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	n, err := io.Copy(os.Stdout, resp.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d bytes from %s\n", n, conversationID)
+	return nil
+}
+
+// printEvent writes a one-line human summary of an event.
+func printEvent(e conv.ConversationEvent) {
+	ts := e.Timestamp.Local().Format("15:04:05")
+	fmt.Printf("[%s] %-11s %s\n", ts, e.Type, summarize(e))
+}
+
+func summarize(e conv.ConversationEvent) string {
+	var parts []string
+	for _, b := range e.Content {
+		switch b.Type {
+		case "text", "thinking":
+			parts = append(parts, b.Text)
+		case "tool_use":
+			parts = append(parts, b.ToolName+" "+string(b.Input))
+		case "tool_result":
+			parts = append(parts, b.Output)
+		}
+	}
+	if len(parts) == 0 {
+		if op, ok := e.Metadata["originalType"].(string); ok {
+			return op
+		}
+		return ""
+	}
+	s := strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+	if len(s) > 160 {
+		s = conv.TruncateText(s, 157) + "..."
+	}
+	return s
+}
+
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -1,20 +1,65 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/gastownhall/tmux-adapter/internal/agentio"
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/conv"
 	"github.com/gastownhall/tmux-adapter/internal/converter"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+	"github.com/gastownhall/tmux-adapter/internal/ghexport"
+	"github.com/gastownhall/tmux-adapter/internal/notify"
+	"github.com/gastownhall/tmux-adapter/internal/publish"
+	"github.com/gastownhall/tmux-adapter/internal/retention"
+	"github.com/gastownhall/tmux-adapter/internal/service"
+	"github.com/gastownhall/tmux-adapter/internal/store"
+	"github.com/gastownhall/tmux-adapter/internal/tee"
+	"github.com/gastownhall/tmux-adapter/internal/version"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+	"github.com/gastownhall/tmux-adapter/internal/wsconv"
 )
 
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// parseRemoteFS reads --remote-fs values, runtime or runtime=interval, into
+// a poll interval per runtime.
+func parseRemoteFS(values []string) (map[string]time.Duration, error) {
+	polls := make(map[string]time.Duration)
+	for _, v := range values {
+		runtime, interval, hasInterval := strings.Cut(v, "=")
+		poll := conv.DefaultRemotePoll
+		if hasInterval {
+			d, err := time.ParseDuration(interval)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("--remote-fs %q: want runtime or runtime=interval with a positive interval", v)
+			}
+			poll = d
+		}
+		if runtime == "" {
+			return nil, fmt.Errorf("--remote-fs %q: runtime required", v)
+		}
+		polls[runtime] = poll
+	}
+	return polls, nil
+}
+
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: tmux-converter [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: tmux-converter [version|check-update|self-update] [flags]\n\n")
 		fmt.Fprintf(os.Stderr, "Streams structured conversation events from CLI AI agents over WebSocket.\n")
 		fmt.Fprintf(os.Stderr, "Watches conversation files written by Claude Code, Codex, and Gemini,\n")
 		fmt.Fprintf(os.Stderr, "parses them into normalized JSON events, and streams to connected clients.\n\n")
@@ -24,21 +69,308 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  tmux-converter --gt-dir ~/gt\n")
 		fmt.Fprintf(os.Stderr, "  tmux-converter --gt-dir ~/gt --listen :9090\n")
 		fmt.Fprintf(os.Stderr, "  tmux-converter --gt-dir ~/gt --debug-serve-dir ./samples\n")
+		fmt.Fprintf(os.Stderr, "  tmux-converter --gt-dir ~/gt --debug-protocol\n")
+		fmt.Fprintf(os.Stderr, "  tmux-converter --gt-dir ~/gt --redact --redact-pattern 'ACME-[0-9]{6}'\n")
+		fmt.Fprintf(os.Stderr, "  tmux-converter --gt-dir ~/gt --once --agent hq-mayor --format md\n")
+	}
+
+	if len(os.Args) > 1 {
+		if handled, err := version.RunCommand(os.Args[1], "tmux-converter"); handled {
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 	}
 
 	gtDir := flag.String("gt-dir", filepath.Join(os.Getenv("HOME"), "gt"), "gastown town directory")
 	listen := flag.String("listen", ":8081", "HTTP/WebSocket listen address")
+	allowIPs := flag.String("allow-ips", "", "comma-separated IPs or CIDRs allowed to connect; others get 403 (default: all)")
+	maxConnsPerIP := flag.Int("max-conns-per-ip", 0, "maximum concurrent WebSocket connections from one IP; 0 for no limit")
+	maxMessageBytes := flag.Int("max-message-bytes", wsbase.DefaultLimits.MaxMessageBytes, "largest inbound JSON message accepted per WebSocket frame; 0 for no limit")
+	maxSubscriptions := flag.Int("max-subscriptions", wsbase.DefaultLimits.MaxSubscriptions, "maximum open subscriptions per connection; 0 for no limit")
+	maxPendingFollows := flag.Int("max-pending-follows", wsbase.DefaultLimits.MaxPendingFollows, "maximum follows per connection waiting for an agent's first conversation; 0 for no limit")
+	maxFilterTypes := flag.Int("max-filter-types", wsbase.DefaultLimits.MaxFilterTypes, "maximum event types in one subscription filter; 0 for no limit")
+	maxFilterPatterns := flag.Int("max-filter-patterns", wsbase.DefaultLimits.MaxFilterPatterns, "maximum agentFilter patterns in one request; 0 for no limit")
+	controlIdleTimeout := flag.Duration("control-idle-timeout", 15*time.Minute, "release input control after this long without input from its holder; 0 keeps it until released")
 	debugServeDir := flag.String("debug-serve-dir", "", "serve static files from this directory at / (development only)")
+	debugProtocol := flag.Bool("debug-protocol", false, "log every WebSocket message in/out with timestamps and sizes; echo serverTiming on responses")
+	jwtSecret := flag.String("jwt-secret", "", "comma-separated HS256 secrets; when any JWT option is set, /ws requires a JWT")
+	jwtPublicKey := flag.String("jwt-public-key", "", "PEM file of RS256 public keys for JWT verification")
+	jwksURL := flag.String("jwks-url", "", "JWKS endpoint for RS256 JWT keys, refetched every 10m and on unknown key IDs")
+	jwtAudience := flag.String("jwt-audience", "", "require this value in each JWT's aud claim")
+	jwtExpiry := flag.String("jwt-expiry", "close", "when a connection's JWT expires: close, or read-only")
+	tlsCert := flag.String("tls-cert", "", "serve HTTPS/WSS with this certificate (PEM)")
+	tlsKey := flag.String("tls-key", "", "private key for --tls-cert (PEM)")
+	tlsClientCA := flag.String("tls-client-ca", "", "verify client certificates against this CA bundle (mutual TLS)")
+	var certScopes stringList
+	flag.Var(&certScopes, "client-cert-scope", "map a client certificate common name to scopes, e.g. ops=read,prompt,control; * matches any (repeatable)")
+	promptMaxLength := flag.Int("prompt-max-length", 0, "reject prompts longer than this many bytes; 0 for no limit")
+	promptBlockSecrets := flag.Bool("prompt-block-secrets", false, "reject prompts containing API keys, tokens, or private keys")
+	var promptDeny stringList
+	flag.Var(&promptDeny, "prompt-deny-pattern", "reject prompts matching this regex (repeatable)")
+	promptPrefix := flag.String("prompt-prefix", "", "prepend this text to prompts that don't already start with it")
+	promptHook := flag.String("prompt-hook", "", "shell command that screens each prompt on stdin ($TMUX_ADAPTER_AGENT set); non-zero exit rejects, stdout rewrites")
+	uploadExtensions := flag.String("upload-extensions", "", "comma-separated file extensions allowed for uploads, e.g. .png,.txt (default: any)")
+	uploadMIMETypes := flag.String("upload-mime-types", "", "comma-separated MIME types allowed for uploads; image/* matches a family (default: any)")
+	uploadMaxBytes := flag.Int("upload-max-bytes", 0, "maximum size of one uploaded file; 0 for the protocol limit (8MiB per frame, 1GiB chunked)")
+	uploadQuota := flag.Int64("upload-agent-quota", 0, "maximum total bytes of uploads kept per agent; 0 for no limit")
+	uploadScanner := flag.String("upload-scanner", "", "shell command run on each upload with the staged file as $1; non-zero exit rejects it")
+	var holdPrompts stringList
+	flag.Var(&holdPrompts, "hold-prompts", "type prompts to agents matching this glob or regex but wait for confirm-prompt before submitting them; !pattern excludes (repeatable)")
+	holdTimeout := flag.Duration("hold-timeout", 0, "with --hold-prompts or set-prompt-hold: submit a held prompt nobody confirmed after this long, e.g. 5m; 0 waits for confirm-prompt")
+	promptHistory := flag.Int("prompt-history", agentio.DefaultPromptHistory, "how many prompts get-prompt-history keeps per agent; 0 records none")
+	promptHistoryFile := flag.String("prompt-history-file", "", "also append every prompt to this JSONL file and reload it on start, so history survives restarts")
+	actionsConfig := flag.String("actions-config", "", "JSON file of quick actions (label, prompt template, agent selectors) that list-actions offers every client")
+	submitConfig := flag.String("submit-config", "", "JSON file of per-runtime submit strategies: the keys that submit a typed prompt, the settle delay before them, whether Escape is sent first, and paste-only mode")
+	adminToken := flag.String("admin-token", "", "enable /ws/admin introspection, authorized by this token (Bearer or ?token=...)")
+	reusePort := flag.Bool("reuse-port", false, "bind with SO_REUSEPORT so a new converter can take over the address while this one drains")
+	drainTimeout := flag.Duration("drain-timeout", 10*time.Minute, "on SIGUSR1, how long to keep serving connected clients before exiting")
+	stateDir := flag.String("state-dir", service.DefaultStateDir("tmux-converter"), "directory for conversation snapshots kept across restarts (empty disables)")
+	crashDumps := flag.Bool("crash-dumps", false, "write a dump with every goroutine's stack to crashes/ under --state-dir when a panic is recovered")
+	storeDSN := flag.String("store", "", "state database: a SQLite file path or sqlite:///path (default: state.db under --state-dir)")
+	retentionMaxAge := flag.Duration("retention-max-age", 0, "delete conversation snapshots and records older than this, e.g. 720h; 0 keeps them")
+	retentionMaxBytes := flag.Int64("retention-max-bytes", 0, "delete the oldest conversation snapshots once they exceed this many bytes; 0 for no limit")
+	pprof := flag.Bool("pprof", false, "serve net/http/pprof at /debug/pprof/, authorized by --admin-token")
+	mcp := flag.Bool("mcp", false, "serve a Model Context Protocol endpoint at /mcp with list_agents, read_conversation, and send_prompt tools")
+	openAI := flag.Bool("openai-api", false, "serve an experimental OpenAI-compatible /v1/chat/completions where model names an agent")
+	allowedOrigins := flag.String("allowed-origins", "localhost:*", "comma-separated origin patterns that may open /ws while authentication is configured (host, scheme://host, file://*, null, or *); without authentication every origin may")
+	apiOrigins := flag.String("api-origins", "localhost:*", "comma-separated origin patterns of browser pages that may call /mcp and /v1/ (host, scheme://host, file://*, null, or *)")
+	githubRepo := flag.String("github-repo", "", "comment finished agent turns on this repo's open PR for the agent's git branch (owner/name)")
+	githubToken := flag.String("github-token", "", "GitHub token for --github-repo (default: $GITHUB_TOKEN)")
+	githubComments := flag.String("github-comments", ghexport.ModeTurns, "with --github-repo: turns posts a comment per turn; transcript keeps one comment per conversation up to date")
+	notifyConfig := flag.String("notify-config", "", "JSON file of Slack/Discord webhooks to notify on turn-end, approval-request, error, and agent-exited")
+	publishConfig := flag.String("publish-config", "", "JSON file of NATS servers and Kafka REST Proxies to publish conversation and lifecycle events to (NATS is fire-and-forget core PUB: no JetStream acks, no NKey/creds auth; Kafka only through a Confluent REST Proxy, not brokers directly)")
+	teeEventsDir := flag.String("tee-events-dir", "", "mirror every normalized conversation event to <conversation>.jsonl files in this directory")
+	teeMaxFileBytes := flag.Int64("tee-max-file-bytes", tee.DefaultMaxFileBytes, "with --tee-events-dir: rotate a conversation's file once it would pass this many bytes; 0 never rotates")
+	teeMaxFiles := flag.Int("tee-max-files", tee.DefaultMaxFiles, "with --tee-events-dir: rotated files kept per conversation")
+	rescanInterval := flag.Duration("rescan-interval", agents.DefaultRescanInterval, "how often sessions without an agent are checked for one started in them; 0 relies on tmux notifications alone")
+	parserConfig := flag.String("parser-config", "", "JSON file of per-runtime parser options: event types to skip as they are parsed, and whether to keep each event's transcript line")
+	maxContent := flag.Int("max-content-bytes", conv.DefaultMaxContentSize, "bytes of a content block's text or tool output kept in events; longer blocks are cut and marked truncated (0 keeps everything)")
+	idleTTL := flag.Duration("idle-ttl", 0, "stop tailing a conversation and free its buffer once its file is unchanged and it has no subscribers for this long, e.g. 30m; 0 keeps every conversation tailed")
+	var eagerTail stringList
+	flag.Var(&eagerTail, "eager-tail", "keep conversations of agents matching this glob or regex tailed and buffered however long --idle-ttl finds them idle; !pattern excludes (repeatable)")
+	var remoteFS stringList
+	flag.Var(&remoteFS, "remote-fs", "runtime[=interval]: poll this runtime's conversation files, which live on a network filesystem such as NFS, instead of relying on fsnotify (default interval 500ms; repeatable)")
+	switchConfirm := flag.Duration("switch-confirm", conv.DefaultSwitchConfirm, "how long a new conversation file must keep receiving events before an agent switches to it; 0 switches immediately")
+	redact := flag.Bool("redact", false, "scrub API keys, tokens, private keys, and emails from conversation events")
+	var redactPatterns stringList
+	flag.Var(&redactPatterns, "redact-pattern", "additional regex to scrub from conversation events (repeatable)")
+	once := flag.Bool("once", false, "print --agent's current conversation to stdout and exit, without serving")
+	onceAgent := flag.String("agent", "", "with --once: the agent whose conversation to print")
+	onceFormat := flag.String("format", "json", "with --once: json for every normalized event, or md for a readable transcript")
 	flag.Parse()
 
-	c := converter.New(*gtDir, *listen, *debugServeDir)
+	var middleware []conv.Middleware
+	if *redact || len(redactPatterns) > 0 {
+		custom, err := conv.ParseRedactionPatterns(redactPatterns)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var rules []conv.RedactionRule
+		if *redact {
+			rules = append(rules, conv.DefaultRedactionRules...)
+		}
+		middleware = append(middleware, conv.NewRedactor(append(rules, custom...)).Middleware())
+	}
+
+	if *pprof && *adminToken == "" {
+		log.Fatal("--pprof requires --admin-token")
+	}
+	originPolicy, err := wsbase.ParseOriginPolicy(strings.Split(*allowedOrigins, ","), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	apiOriginPolicy, err := wsbase.ParseOriginPolicy(strings.Split(*apiOrigins, ","), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	eagerTailFilter, err := wsbase.ParseNameFilter(eagerTail, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	remotePoll, err := parseRemoteFS(remoteFS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	parserOptions, err := conv.LoadParserOptions(*parserConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *once {
+		if err := runOnce(*gtDir, *onceAgent, *onceFormat, *maxContent, parserOptions, middleware); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	jwt, err := wsbase.LoadJWTVerifier(*jwtSecret, *jwtPublicKey, *jwksURL, *jwtAudience)
+	if err != nil {
+		log.Fatal(err)
+	}
+	onExpiry, err := wsbase.ParseExpiryAction(*jwtExpiry)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tlsConfig, err := wsbase.TLSConfig(*tlsCert, *tlsKey, *tlsClientCA)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var auth *wsbase.Authenticator
+	if jwt != nil || len(certScopes) > 0 {
+		auth = wsbase.NewAuthenticator("", jwt, onExpiry)
+	}
+	if len(certScopes) > 0 {
+		if *tlsClientCA == "" {
+			log.Fatal("--client-cert-scope requires --tls-client-ca")
+		}
+		certs, err := wsbase.ParseCertScopes(certScopes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		auth.SetCertScopes(certs)
+	}
+
+	ipGuard, err := wsbase.ParseIPGuard(strings.Split(*allowIPs, ","), *maxConnsPerIP)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	promptPolicy, err := agentio.LoadPromptPolicy(*promptMaxLength, promptDeny, *promptBlockSecrets, *promptPrefix, *promptHook)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	uploadPolicy, err := agentio.LoadUploadPolicy(*uploadExtensions, *uploadMIMETypes, *uploadMaxBytes, *uploadQuota, *uploadScanner)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	submit, err := agentio.LoadSubmitStrategies(*submitConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	holdFilter, err := wsbase.ParseNameFilter(holdPrompts, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	holds := agentio.NewPromptHolds(holdFilter, *holdTimeout)
+	history, err := agentio.LoadPromptHistory(*promptHistory, *promptHistoryFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	actions, err := agentio.LoadQuickActions(*actionsConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *githubToken == "" {
+		*githubToken = os.Getenv("GITHUB_TOKEN")
+	}
+	ghExport, err := ghexport.Load(*githubRepo, *githubToken, *githubComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	notifier, err := notify.Load(*notifyConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	publisher, err := publish.Load(*publishConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	eventTee, err := tee.New(*teeEventsDir, *teeMaxFileBytes, *teeMaxFiles)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *crashDumps {
+		if *stateDir == "" {
+			log.Fatal("--crash-dumps needs --state-dir")
+		}
+		crash.SetDumpDir(filepath.Join(*stateDir, "crashes"))
+	}
+
+	if *storeDSN == "" && *stateDir != "" {
+		*storeDSN = filepath.Join(*stateDir, "state.db")
+	}
+	var st store.Store
+	if *storeDSN != "" {
+		if st, err = store.Open(*storeDSN); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	limits := wsbase.Limits{
+		MaxMessageBytes:   *maxMessageBytes,
+		MaxSubscriptions:  *maxSubscriptions,
+		MaxPendingFollows: *maxPendingFollows,
+		MaxFilterTypes:    *maxFilterTypes,
+		MaxFilterPatterns: *maxFilterPatterns,
+		MaxPatternBytes:   wsbase.DefaultLimits.MaxPatternBytes,
+	}
+	c := converter.New(converter.Config{
+		GTDir:         *gtDir,
+		Listen:        *listen,
+		TLSConfig:     tlsConfig,
+		DebugServeDir: *debugServeDir,
+		IPGuard:       ipGuard,
+		Server: wsconv.Options{
+			Auth:               auth,
+			AllowedOrigins:     originPolicy,
+			APIOrigins:         apiOriginPolicy,
+			PromptPolicy:       promptPolicy,
+			UploadPolicy:       uploadPolicy,
+			Submit:             submit,
+			Holds:              holds,
+			History:            history,
+			Actions:            actions,
+			Limits:             limits,
+			DebugProtocol:      *debugProtocol,
+			ControlIdleTimeout: *controlIdleTimeout,
+		},
+		AdminToken:     *adminToken,
+		ReusePort:      *reusePort,
+		StateDir:       *stateDir,
+		Store:          st,
+		Retention:      retention.Policy{MaxAge: *retentionMaxAge, MaxBytes: *retentionMaxBytes},
+		Pprof:          *pprof,
+		MCP:            *mcp,
+		OpenAI:         *openAI,
+		GHExport:       ghExport,
+		Notifier:       notifier,
+		EventTee:       eventTee,
+		Publisher:      publisher,
+		SwitchConfirm:  *switchConfirm,
+		RescanInterval: *rescanInterval,
+		IdleTTL:        *idleTTL,
+		EagerTail:      eagerTailFilter,
+		RemoteFS:       remotePoll,
+		MaxContent:     *maxContent,
+		ParserOptions:  parserOptions,
+		Middleware:     middleware,
+	})
 	if err := c.Start(); err != nil {
 		log.Fatal(err)
 	}
 
+	// SIGUSR1 drains instead of dropping clients
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
+	if sig := <-sigCh; sig != syscall.SIGUSR1 {
+		c.Stop()
+		return
+	}
 
-	c.Stop()
+	// A second signal during the drain cuts it short.
+	ctx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+	defer cancel()
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+	c.Drain(ctx)
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/converter"
+)
+
+// onceTimeout bounds reading a conversation for --once.
+const onceTimeout = time.Minute
+
+// onceDocument is what --once --format json prints.
+type onceDocument struct {
+	Agent          string                   `json:"agent"`
+	Runtime        string                   `json:"runtime"`
+	ConversationID string                   `json:"conversationId"`
+	Path           string                   `json:"path"`
+	Events         []conv.ConversationEvent `json:"events"`
+}
+
+// runOnce prints agentName's current conversation to stdout in format,
+// json or md.
+func runOnce(gtDir, agentName, format string, maxContent int, parserOptions map[string]conv.ParserOptions, middleware []conv.Middleware) error {
+	if agentName == "" {
+		return fmt.Errorf("--once requires --agent")
+	}
+	if format != "json" && format != "md" {
+		return fmt.Errorf("--format %q: want json or md", format)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), onceTimeout)
+	defer cancel()
+	agent, file, events, err := converter.ReadCurrent(ctx, gtDir, agentName, maxContent, parserOptions, middleware...)
+	if err != nil {
+		return err
+	}
+	if format == "md" {
+		return writeMarkdown(os.Stdout, agent, file, events)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(onceDocument{
+		Agent:          agent.Name,
+		Runtime:        file.Runtime,
+		ConversationID: file.ConversationID,
+		Path:           file.Path,
+		Events:         events,
+	})
+}
+
+// writeMarkdown renders a conversation as a readable transcript: prompts,
+// replies, tool calls and their results. Thinking, progress, and other
+// bookkeeping events are left out.
+func writeMarkdown(w io.Writer, agent agents.Agent, file conv.ConversationFile, events []conv.ConversationEvent) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s: conversation `%s`\n\n`%s`\n", agent.Name, file.ConversationID, file.Path)
+	for _, e := range events {
+		var heading string
+		switch e.Type {
+		case conv.EventUser:
+			heading = "User"
+		case conv.EventAssistant, conv.EventToolUse:
+			heading = "Assistant"
+		case conv.EventToolResult:
+			heading = "Tool result"
+		case conv.EventCompaction:
+			heading = "Compaction"
+		case conv.EventError:
+			heading = "Error"
+		default:
+			continue
+		}
+		body := markdownBlocks(e.Content)
+		if body == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s", heading)
+		if !e.Timestamp.IsZero() {
+			fmt.Fprintf(&b, " · %s", e.Timestamp.Local().Format("2006-01-02 15:04:05"))
+		}
+		b.WriteString("\n\n" + body + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownBlocks renders an event's content blocks, joined by blank lines.
+func markdownBlocks(blocks []conv.ContentBlock) string {
+	var parts []string
+	for _, blk := range blocks {
+		var part string
+		switch blk.Type {
+		case "text":
+			if blk.Continues && len(parts) > 0 {
+				parts[len(parts)-1] += blk.Text
+				continue
+			}
+			part = strings.TrimSpace(blk.Text)
+		case "tool_use":
+			part = fmt.Sprintf("**%s**", blk.ToolName)
+			if len(blk.Input) > 0 && string(blk.Input) != "{}" {
+				part += "\n\n" + fence("json", string(blk.Input))
+			}
+		case "tool_result":
+			if blk.IsError {
+				part = "**error**\n\n"
+			}
+			part += fence("", blk.Output)
+		case "image":
+			part = fmt.Sprintf("*[image %s]*", blk.MimeType)
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// fence wraps s in a code fence longer than any run of backticks in it.
+func fence(lang, s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	marks := strings.Repeat("`", max(3, longest+1))
+	return marks + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + marks
+}
@@ -4,7 +4,20 @@ go 1.25.6
 
 require (
 	github.com/fsnotify/fsnotify v1.9.0
+	golang.org/x/image v0.25.0
+	golang.org/x/sys v0.37.0
+	modernc.org/sqlite v1.46.1
 	nhooyr.io/websocket v1.8.17
 )
 
-require golang.org/x/sys v0.13.0 // indirect
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v1.0.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/exp v0.0.0-20251023183803-a4bb9ffd2546 // indirect
+	modernc.org/libc v1.67.6 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
+)
@@ -2,71 +2,131 @@ package adapter
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
 	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/version"
 	"github.com/gastownhall/tmux-adapter/internal/wsadapter"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
 	"github.com/gastownhall/tmux-adapter/web"
 )
 
 // Adapter wires together tmux control mode, agent registry, pipe-pane streaming,
 // and the WebSocket server.
 type Adapter struct {
-	ctrl           *tmux.ControlMode
-	registry       *agents.Registry
-	pipeMgr        *tmux.PipePaneManager
-	wsSrv          *wsadapter.Server
-	httpSrv        *http.Server
-	gtDir          string
-	port           int
-	authToken      string
-	originPatterns []string
-	debugServeDir  string
+	cfg      Config
+	ctrl     *tmux.ControlMode
+	registry *agents.Registry
+	pipeMgr  *tmux.PipePaneManager
+	wsSrv    *wsadapter.Server
+	httpSrv  *http.Server
+	stopScan chan struct{}
+}
+
+// Config configures an Adapter.
+type Config struct {
+	GTDir string // gastown town directory; agents outside it are ignored
+	Port  int
+
+	// TLSConfig, when non-nil, serves HTTPS/WSS (see wsbase.TLSConfig).
+	TLSConfig *tls.Config
+	// IPGuard filters every request by source address and caps sockets per IP.
+	IPGuard *wsbase.IPGuard
+	// Server is what the WebSocket server enforces: authentication, origins,
+	// prompt and upload policies, and per-connection limits.
+	Server wsadapter.Options
+
+	// ScanServers, when non-empty, is a glob of other users' tmux sockets to
+	// watch as well (see tmux.DiscoverServers); their agents are named
+	// "user:session".
+	ScanServers string
+	// RescanInterval is how often sessions without an agent are checked for
+	// one started since (see agents.Registry.SetRescanInterval).
+	RescanInterval time.Duration
+
+	// RecordTmux, when non-empty, is a file to record the tmux control mode
+	// traffic to; ReplayTmux is such a recording to play back, at
+	// ReplaySpeed, instead of connecting to tmux (see tmux.ReplayControlMode).
+	RecordTmux  string
+	ReplayTmux  string
+	ReplaySpeed float64
+
+	DebugServeDir string // serve static files from this directory at / (development only)
+	// ReusePort opens the listener with SO_REUSEPORT and uses a per-process
+	// tmux monitor session, so a replacement adapter can start alongside
+	// this one while it drains.
+	ReusePort bool
+	// Pprof mounts /debug/pprof/ behind Server.Auth.
+	Pprof bool
 }
 
 // New creates a new Adapter.
-func New(gtDir string, port int, authToken string, originPatterns []string, debugServeDir string) *Adapter {
-	return &Adapter{
-		gtDir:          gtDir,
-		port:           port,
-		authToken:      authToken,
-		originPatterns: originPatterns,
-		debugServeDir:  debugServeDir,
-	}
+func New(cfg Config) *Adapter {
+	return &Adapter{cfg: cfg, stopScan: make(chan struct{})}
 }
 
 // Start initializes all components and starts the HTTP/WebSocket server.
 func (a *Adapter) Start() error {
 	// 1. Connect to tmux in control mode
-	ctrl, err := tmux.NewControlMode("adapter-monitor")
+	monitor := "adapter-monitor"
+	if a.cfg.ReusePort {
+		// Closing control mode kills its session; don't share it with the process taking over.
+		monitor = fmt.Sprintf("adapter-monitor-%d", os.Getpid())
+	}
+	var ctrl *tmux.ControlMode
+	var err error
+	switch {
+	case a.cfg.ReplayTmux != "":
+		ctrl, err = tmux.ReplayControlMode(a.cfg.ReplayTmux, a.cfg.ReplaySpeed)
+		if err != nil {
+			return err
+		}
+		// Skip the monitor session the recording was made from.
+		monitor = ctrl.Session()
+	case a.cfg.RecordTmux != "":
+		ctrl, err = tmux.RecordControlMode(monitor, a.cfg.RecordTmux)
+		log.Printf("recording tmux control mode to %s", a.cfg.RecordTmux)
+	default:
+		ctrl, err = tmux.NewControlMode(monitor)
+	}
 	if err != nil {
-		return fmt.Errorf("tmux control mode (session=adapter-monitor): %w", err)
+		return fmt.Errorf("tmux control mode (session=%s): %w", monitor, err)
 	}
 	a.ctrl = ctrl
 	log.Println("connected to tmux control mode")
 
 	// 2. Create agent registry
-	a.registry = agents.NewRegistry(ctrl, a.gtDir, []string{"adapter-monitor"})
+	a.registry = agents.NewRegistry(ctrl, a.cfg.GTDir, []string{monitor})
+	a.registry.SetRescanInterval(a.cfg.RescanInterval)
 
 	// 3. Create pipe-pane manager
 	a.pipeMgr = tmux.NewPipePaneManager(ctrl)
 
 	// 4. Create WebSocket server
-	a.wsSrv = wsadapter.NewServer(a.registry, a.pipeMgr, ctrl, a.authToken, a.originPatterns)
+	a.wsSrv = wsadapter.NewServer(a.registry, a.pipeMgr, ctrl, a.cfg.Server)
 
 	// 5. Start registry watching
 	if err := a.registry.Start(); err != nil {
 		ctrl.Close()
-		return fmt.Errorf("start registry (gtDir=%s): %w", a.gtDir, err)
+		return fmt.Errorf("start registry (gtDir=%s): %w", a.cfg.GTDir, err)
 	}
 	log.Printf("agent registry started (%d agents found)", len(a.registry.GetAgents()))
 
+	// Attach other users' servers after the registry is listening, so the
+	// sessions-changed each attach raises finds their agents.
+	if a.cfg.ScanServers != "" && a.cfg.ReplayTmux == "" {
+		go a.scanServersLoop(monitor)
+	}
+
 	// 6. Forward registry events to WebSocket clients
 	go a.forwardEvents()
 
@@ -74,7 +134,10 @@ func (a *Adapter) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", a.handleHealth)
 	mux.HandleFunc("/readyz", a.handleReady)
-	mux.Handle("/ws", a.wsSrv)
+	mux.HandleFunc("/version", a.handleVersion)
+	mux.Handle("/ws", a.cfg.IPGuard.LimitConns(a.wsSrv))
+	mux.Handle("POST /api/agents/{name}/prompt", a.wsSrv.PromptAPI())
+	mux.Handle("GET /api/agents/{name}/screenshot.png", a.wsSrv.ScreenshotAPI())
 
 	// Serve embedded web component files at /tmux-adapter-web/
 	adapterFS, _ := fs.Sub(web.Files, "tmux-adapter-web")
@@ -88,8 +151,13 @@ func (a *Adapter) Start() error {
 		http.StripPrefix("/shared/", http.FileServer(http.FS(sharedFS))),
 	))
 
+	if a.cfg.Pprof {
+		wsbase.MountPprof(mux, a.cfg.Server.Auth)
+		log.Println("profiling enabled at /debug/pprof/")
+	}
+
 	// Debug: remote console log endpoint
-	if a.debugServeDir != "" {
+	if a.cfg.DebugServeDir != "" {
 		mux.HandleFunc("/debug/log", func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			if r.Method == "OPTIONS" {
@@ -108,20 +176,30 @@ func (a *Adapter) Start() error {
 	}
 
 	// Debug: serve static files from a local directory (development only)
-	if a.debugServeDir != "" {
-		log.Printf("serving static files from %s at /", a.debugServeDir)
-		mux.Handle("/", http.FileServer(http.Dir(a.debugServeDir)))
+	if a.cfg.DebugServeDir != "" {
+		log.Printf("serving static files from %s at /", a.cfg.DebugServeDir)
+		mux.Handle("/", http.FileServer(http.Dir(a.cfg.DebugServeDir)))
 	}
 
 	a.httpSrv = &http.Server{
-		Addr:    fmt.Sprintf(":%d", a.port),
-		Handler: mux,
+		Addr:    fmt.Sprintf(":%d", a.cfg.Port),
+		Handler: a.cfg.IPGuard.Filter(mux),
+	}
+
+	ln, err := wsbase.Listen(a.httpSrv.Addr, a.cfg.ReusePort)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", a.httpSrv.Addr, err)
+	}
+	scheme := "ws"
+	if a.cfg.TLSConfig != nil {
+		ln = tls.NewListener(ln, a.cfg.TLSConfig)
+		scheme = "wss"
 	}
 
 	go func() {
-		log.Printf("WebSocket server listening on ws://localhost:%d/ws", a.port)
-		log.Printf("watching gastown at %s", a.gtDir)
-		if err := a.httpSrv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Printf("WebSocket server listening on %s://localhost:%d/ws", scheme, a.cfg.Port)
+		log.Printf("watching gastown at %s", a.cfg.GTDir)
+		if err := a.httpSrv.Serve(ln); err != http.ErrServerClosed {
 			log.Fatalf("http server: %v", err)
 		}
 	}()
@@ -129,6 +207,51 @@ func (a *Adapter) Start() error {
 	return nil
 }
 
+// serverScanInterval is how often --scan-tmux-servers looks for servers
+// started since the last scan.
+const serverScanInterval = 30 * time.Second
+
+func (a *Adapter) scanServersLoop(monitor string) {
+	ticker := time.NewTicker(serverScanInterval)
+	defer ticker.Stop()
+	for {
+		if err := a.ctrl.SyncServers(a.cfg.ScanServers, monitor); err != nil {
+			log.Printf("scan tmux servers: %v", err)
+		}
+		select {
+		case <-a.stopScan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain stops accepting connections, lets connected clients keep streaming
+// until they disconnect or ctx is done, then shuts down.
+func (a *Adapter) Drain(ctx context.Context) {
+	log.Printf("draining: listener closed, waiting for %d clients to disconnect", a.wsSrv.ClientCount())
+
+	// Shutdown closes the listener; hijacked WebSocket connections are unaffected.
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	if err := a.httpSrv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for a.wsSrv.ClientCount() > 0 {
+		select {
+		case <-ctx.Done():
+			log.Printf("drain: giving up on %d remaining clients", a.wsSrv.ClientCount())
+			a.Stop()
+			return
+		case <-ticker.C:
+		}
+	}
+	a.Stop()
+}
+
 // Stop gracefully shuts down all components.
 func (a *Adapter) Stop() {
 	log.Println("shutting down...")
@@ -143,8 +266,9 @@ func (a *Adapter) Stop() {
 	// 2. Close all WebSocket connections
 	a.wsSrv.CloseAll()
 
-	// 3. Stop registry
+	// 3. Stop registry and server scans
 	a.registry.Stop()
+	close(a.stopScan)
 
 	// 4. Stop all pipe-panes
 	a.pipeMgr.StopAll()
@@ -159,13 +283,27 @@ func (a *Adapter) Stop() {
 // subscribed WebSocket clients.
 func (a *Adapter) forwardEvents() {
 	for event := range a.registry.Events() {
-		msg := wsadapter.MakeAgentEvent(event.Type, event.Agent)
-		a.wsSrv.BroadcastToAgentSubscribers(msg)
+		a.forwardEvent(event)
 	}
 }
 
+func (a *Adapter) forwardEvent(event agents.RegistryEvent) {
+	defer crash.Recover("adapter: forwarding %s event for %s", event.Type, event.Agent.Name)
+	if event.Type == "renamed" {
+		a.wsSrv.RenameAgent(event.OldName, event.Agent)
+		return
+	}
+	msg := wsadapter.MakeAgentEvent(event)
+	a.wsSrv.BroadcastToAgentSubscribers(event.Agent.Name, msg)
+}
+
 func (a *Adapter) handleHealth(w http.ResponseWriter, _ *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "panics": crash.Panics()})
+}
+
+func (a *Adapter) handleVersion(w http.ResponseWriter, _ *http.Request) {
+	v := version.Get()
+	writeJSON(w, http.StatusOK, map[string]any{"version": v.Version, "commit": v.Commit, "date": v.Date})
 }
 
 func (a *Adapter) handleReady(w http.ResponseWriter, _ *http.Request) {
@@ -0,0 +1,225 @@
+package agentio
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+// QuickAction is a server-defined button clients offer for sending a canned
+// prompt, so every UI shows the same curated set.
+type QuickAction struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	// Prompt is a text/template rendered with ActionData for the agent it
+	// is sent to, e.g. "Run the tests in {{.Project}} and report failures".
+	Prompt string `json:"prompt"`
+	// Agents selects agents by "role:<role>", "rig:<rig>",
+	// "runtime:<runtime>", or a name pattern as in agentFilter (a glob such
+	// as "crew-*", or a regex). A leading "!" excludes what a selector
+	// matches. Empty selects every agent.
+	Agents []string `json:"agents,omitempty"`
+	// FilterSyntax is "glob", "regex", or "" for the name patterns in
+	// Agents, as agentFilter's filterSyntax.
+	FilterSyntax string `json:"filterSyntax,omitempty"`
+	// PasteOnly asks clients to send the prompt with pasteOnly set, leaving
+	// it in the input area for a human to submit.
+	PasteOnly bool `json:"pasteOnly,omitempty"`
+}
+
+// ActionData is what a QuickAction's prompt template renders.
+type ActionData struct {
+	Agent   string
+	Role    string
+	Rig     string
+	Runtime string
+	WorkDir string
+	Project string
+}
+
+// QuickActions is the --actions-config file's actions. A nil QuickActions
+// has none.
+type QuickActions struct {
+	actions   []QuickAction
+	templates []*template.Template
+	selectors []*agentSelector
+}
+
+// actionsConfig is the --actions-config file.
+type actionsConfig struct {
+	Actions []QuickAction `json:"actions"`
+}
+
+// LoadQuickActions reads a JSON file of quick actions, such as
+//
+//	{"actions": [{"id": "tests", "label": "Run tests", "prompt": "Run the tests and report failures", "agents": ["runtime:claude"]}]}
+//
+// An empty path returns nil.
+func LoadQuickActions(configPath string) (*QuickActions, error) {
+	if configPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("actions config: %w", err)
+	}
+	var cfg actionsConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("actions config %s: %w", configPath, err)
+	}
+	q, err := NewQuickActions(cfg.Actions)
+	if err != nil {
+		return nil, fmt.Errorf("actions config %s: %w", configPath, err)
+	}
+	return q, nil
+}
+
+// NewQuickActions validates actions and compiles their prompt templates.
+func NewQuickActions(actions []QuickAction) (*QuickActions, error) {
+	q := &QuickActions{}
+	ids := make(map[string]bool)
+	for i, a := range actions {
+		switch {
+		case a.ID == "":
+			return nil, fmt.Errorf("action %d: id required", i)
+		case ids[a.ID]:
+			return nil, fmt.Errorf("action %d: duplicate id %q", i, a.ID)
+		case a.Label == "":
+			return nil, fmt.Errorf("action %q: label required", a.ID)
+		case strings.TrimSpace(a.Prompt) == "":
+			return nil, fmt.Errorf("action %q: prompt required", a.ID)
+		}
+		ids[a.ID] = true
+		sel, err := parseAgentSelector(a.Agents, a.FilterSyntax)
+		if err != nil {
+			return nil, fmt.Errorf("action %q: %w", a.ID, err)
+		}
+		tmpl, err := template.New(a.ID).Option("missingkey=error").Parse(a.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("action %q: prompt template: %w", a.ID, err)
+		}
+		q.actions = append(q.actions, a)
+		q.templates = append(q.templates, tmpl)
+		q.selectors = append(q.selectors, sel)
+	}
+	return q, nil
+}
+
+// List returns every action as configured, prompts unrendered.
+func (q *QuickActions) List() []QuickAction {
+	if q == nil {
+		return nil
+	}
+	return append([]QuickAction(nil), q.actions...)
+}
+
+// For returns the actions that select agent, with their prompts rendered for
+// it. Actions whose template fails to render are left out and logged.
+func (q *QuickActions) For(agent agents.Agent) []QuickAction {
+	if q == nil {
+		return nil
+	}
+	data := ActionData{Agent: agent.Name, Role: agent.Role, Runtime: agent.Runtime, WorkDir: agent.WorkDir, Project: agent.Project}
+	if agent.Rig != nil {
+		data.Rig = *agent.Rig
+	}
+	var out []QuickAction
+	for i, a := range q.actions {
+		if !q.selectors[i].selects(agent) {
+			continue
+		}
+		var b strings.Builder
+		if err := q.templates[i].Execute(&b, data); err != nil {
+			log.Printf("list-actions(%s): action %q: %v", agent.Name, a.ID, err)
+			continue
+		}
+		a.Prompt = b.String()
+		out = append(out, a)
+	}
+	return out
+}
+
+// agentSelector is a QuickAction's compiled Agents. Name patterns are
+// wsbase.NameFilters, so they behave as agentFilter does; tags match the
+// agent's role, rig, or runtime exactly.
+type agentSelector struct {
+	names, notNames *wsbase.NameFilter        // nil when there are no such patterns
+	tags, notTags   []func(agents.Agent) bool // role:, rig:, and runtime: selectors
+}
+
+// parseAgentSelector compiles selectors; syntax applies to name patterns.
+func parseAgentSelector(selectors []string, syntax string) (*agentSelector, error) {
+	s := &agentSelector{}
+	var names, notNames []string
+	for _, raw := range selectors {
+		sel, negate := strings.CutPrefix(raw, "!")
+		tag := agentTag(sel)
+		switch {
+		case tag != nil && negate:
+			s.notTags = append(s.notTags, tag)
+		case tag != nil:
+			s.tags = append(s.tags, tag)
+		case negate:
+			notNames = append(notNames, sel)
+		default:
+			names = append(names, sel)
+		}
+	}
+	var err error
+	if s.names, err = wsbase.ParseNameFilter(names, syntax); err != nil {
+		return nil, fmt.Errorf("agent selector: %w", err)
+	}
+	if s.notNames, err = wsbase.ParseNameFilter(notNames, syntax); err != nil {
+		return nil, fmt.Errorf("agent selector: %w", err)
+	}
+	return s, nil
+}
+
+// agentTag returns the matcher for a role:, rig:, or runtime: selector, or
+// nil for a name pattern.
+func agentTag(sel string) func(agents.Agent) bool {
+	key, value, ok := strings.Cut(sel, ":")
+	if !ok {
+		return nil
+	}
+	switch key {
+	case "role":
+		return func(a agents.Agent) bool { return a.Role == value }
+	case "rig":
+		return func(a agents.Agent) bool { return a.Rig != nil && *a.Rig == value }
+	case "runtime":
+		return func(a agents.Agent) bool { return a.Runtime == value }
+	}
+	return nil
+}
+
+// selects reports whether agent is picked: no exclusion matches it, and an
+// including selector does, or there are none.
+func (s *agentSelector) selects(agent agents.Agent) bool {
+	if s.notNames != nil && s.notNames.Matches(agent.Name) {
+		return false
+	}
+	for _, match := range s.notTags {
+		if match(agent) {
+			return false
+		}
+	}
+	if s.names == nil && len(s.tags) == 0 {
+		return true
+	}
+	if s.names != nil && s.names.Matches(agent.Name) {
+		return true
+	}
+	for _, match := range s.tags {
+		if match(agent) {
+			return true
+		}
+	}
+	return false
+}
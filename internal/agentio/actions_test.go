@@ -0,0 +1,81 @@
+package agentio
+
+import (
+	"testing"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+func TestQuickActionsForAgent(t *testing.T) {
+	q, err := NewQuickActions([]QuickAction{
+		{ID: "tests", Label: "Run tests", Prompt: "Run the tests in {{.Project}}"},
+		{ID: "review", Label: "Review", Prompt: "Review {{.Agent}}'s changes", Agents: []string{"runtime:codex", "crew-*"}},
+		{ID: "stop", Label: "Stop and report", Prompt: "Stop and report", Agents: []string{"rig:gastown"}, PasteOnly: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := q.For(agents.Agent{Name: "crew-joe", Runtime: "claude", Project: "/src/app"})
+	if len(got) != 2 || got[0].Prompt != "Run the tests in /src/app" || got[1].Prompt != "Review crew-joe's changes" {
+		t.Errorf("For(crew-joe) = %+v", got)
+	}
+	rig := "gastown"
+	got = q.For(agents.Agent{Name: "hq-mayor", Runtime: "claude", Rig: &rig})
+	if len(got) != 2 || got[1].ID != "stop" || !got[1].PasteOnly {
+		t.Errorf("For(hq-mayor) = %+v, want tests and stop", got)
+	}
+	if all := q.List(); len(all) != 3 || all[0].Prompt != "Run the tests in {{.Project}}" {
+		t.Errorf("List = %+v, want all three unrendered", all)
+	}
+
+	var none *QuickActions
+	if none.List() != nil || none.For(agents.Agent{Name: "hq-mayor"}) != nil {
+		t.Error("a nil QuickActions offered actions")
+	}
+}
+
+func TestQuickActionsSelectors(t *testing.T) {
+	rig := "gastown"
+	crew := agents.Agent{Name: "crew-joe", Role: "crew", Runtime: "claude", Rig: &rig}
+	scratch := agents.Agent{Name: "crew-scratch", Role: "crew", Runtime: "codex"}
+	mayor := agents.Agent{Name: "hq-mayor", Role: "mayor", Runtime: "claude"}
+	tests := []struct {
+		agents []string
+		syntax string
+		want   []bool // crew, scratch, mayor
+	}{
+		{agents: []string{"crew-*", "!*-scratch"}, want: []bool{true, false, false}},
+		{agents: []string{"!runtime:codex"}, want: []bool{true, false, true}},
+		{agents: []string{"role:mayor", "crew-*", "!rig:gastown"}, want: []bool{false, true, true}},
+		{agents: []string{"^hq-"}, want: []bool{false, false, true}},
+		{agents: []string{"crew-.*"}, syntax: "regex", want: []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		q, err := NewQuickActions([]QuickAction{{ID: "a", Label: "A", Prompt: "a", Agents: tt.agents, FilterSyntax: tt.syntax}})
+		if err != nil {
+			t.Fatalf("%q: %v", tt.agents, err)
+		}
+		for i, agent := range []agents.Agent{crew, scratch, mayor} {
+			if got := len(q.For(agent)) == 1; got != tt.want[i] {
+				t.Errorf("%q selects %s = %v, want %v", tt.agents, agent.Name, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestQuickActionsValidation(t *testing.T) {
+	for name, actions := range map[string][]QuickAction{
+		"missing id":   {{Label: "Run tests", Prompt: "go test"}},
+		"duplicate id": {{ID: "a", Label: "A", Prompt: "a"}, {ID: "a", Label: "B", Prompt: "b"}},
+		"no label":     {{ID: "a", Prompt: "a"}},
+		"no prompt":    {{ID: "a", Label: "A", Prompt: "  "}},
+		"bad template": {{ID: "a", Label: "A", Prompt: "{{.Agent"}},
+		"bad selector": {{ID: "a", Label: "A", Prompt: "a", Agents: []string{"crew-["}}},
+		"bad syntax":   {{ID: "a", Label: "A", Prompt: "a", Agents: []string{"crew-*"}, FilterSyntax: "shell"}},
+	} {
+		if _, err := NewQuickActions(actions); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
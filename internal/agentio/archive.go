@@ -0,0 +1,270 @@
+package agentio
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+const (
+	// MaxArchiveExtractBytes caps how much an uploaded archive may expand to.
+	MaxArchiveExtractBytes = 1 << 30
+	// maxArchiveEntries caps how many files and directories an archive may create.
+	maxArchiveEntries = 10000
+)
+
+// HandleArchiveUpload extracts an uploaded zip or tar.gz into a new directory
+// under the agent's upload directory and, with paste set, pastes the
+// directory's path. Payload format and the returned file ID match
+// HandleFileUpload. The caller must hold the per-agent lock.
+func (p *Prompter) HandleArchiveUpload(agentName string, payload []byte, paste bool) (string, error) {
+	fileName, _, data, err := ParseFileUploadPayload(payload)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxFileUploadBytes {
+		return "", fmt.Errorf("archive %q too large: %d bytes (max %d)", fileName, len(data), MaxFileUploadBytes)
+	}
+	agent, err := p.lookup(agentName)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp("", "tmux-adapter-archive-*-"+SanitizePathComponent(fileName))
+	if err != nil {
+		return "", fmt.Errorf("stage archive: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("stage archive: %w", err)
+	}
+	return p.extractUpload(agent, fileName, f.Name(), paste)
+}
+
+// extractUpload screens an archive staged outside the agent's workdir,
+// extracts it into a fresh upload directory, and optionally pastes that
+// directory's path. It returns the directory's file ID.
+func (p *Prompter) extractUpload(agent agents.Agent, fileName, stagedPath string, paste bool) (string, error) {
+	info, err := os.Stat(stagedPath)
+	if err != nil {
+		return "", fmt.Errorf("archive %q: %w", fileName, err)
+	}
+	if err := p.Uploads.checkArchive(agent.Name, stagedPath, info.Size()); err != nil {
+		return "", fmt.Errorf("archive %q: %w", fileName, err)
+	}
+	budget, budgetRule := p.Uploads.extractBudget(agent.Name, agent.WorkDir)
+	if budget <= 0 {
+		return "", fmt.Errorf("archive %q: %w", fileName, &Rejection{Rule: "quota", Reason: "agent upload quota is used up"})
+	}
+
+	dir, err := saveUpload(agent.WorkDir, agent.Name, archiveDirName(fileName), func(path string) error {
+		return os.Mkdir(path, 0o755)
+	})
+	if err != nil {
+		return "", fmt.Errorf("create extract directory: %w", err)
+	}
+	files, written, err := ExtractArchive(stagedPath, dir, budget, p.Uploads.allowEntry)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		var overflow *archiveOverflow
+		if errors.As(err, &overflow) {
+			err = &Rejection{Rule: budgetRule, Reason: fmt.Sprintf("archive expands past %d bytes", budget)}
+		}
+		return "", fmt.Errorf("archive %q: %w", fileName, err)
+	}
+
+	log.Printf("archive upload %s: name=%q files=%d bytes=%d dir=%s", agent.Name, fileName, files, written, dir)
+	if !paste {
+		return filepath.Base(dir), nil
+	}
+	return filepath.Base(dir), p.pasteUpload(agent, fileName, "inode/directory", dir+string(filepath.Separator), written, nil)
+}
+
+// archiveDirName names the extract directory after the archive, minus its extension.
+func archiveDirName(fileName string) string {
+	base := filepath.Base(fileName)
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+		if len(base) > len(ext) && strings.EqualFold(base[len(base)-len(ext):], ext) {
+			return base[:len(base)-len(ext)]
+		}
+	}
+	return base
+}
+
+// archiveOverflow reports an archive that expands past its byte budget.
+type archiveOverflow struct{ limit int64 }
+
+func (e *archiveOverflow) Error() string {
+	return fmt.Sprintf("archive expands past %d bytes", e.limit)
+}
+
+// ExtractArchive unpacks the zip or gzip-compressed tar at archivePath into
+// dir, detecting the format from its content. Entries that would land outside
+// dir reject the whole archive; symlinks, hard links, and device files are
+// skipped. allow, if non-nil, may reject a regular file by name. Extraction
+// stops once more than maxBytes would be written. It returns the number of
+// files and bytes written.
+func ExtractArchive(archivePath, dir string, maxBytes int64, allow func(name string) error) (int, int64, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return 0, 0, errors.New("not a zip or tar.gz archive")
+	}
+	x := &extractor{dir: dir, remaining: maxBytes, limit: maxBytes, allow: allow}
+	switch {
+	case bytes.Equal(magic, []byte("PK\x03\x04")):
+		err = x.zip(archivePath)
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		if _, err = f.Seek(0, io.SeekStart); err == nil {
+			err = x.tarGz(f)
+		}
+	default:
+		err = errors.New("not a zip or tar.gz archive")
+	}
+	return x.files, maxBytes - x.remaining, err
+}
+
+type extractor struct {
+	dir       string
+	remaining int64
+	limit     int64
+	entries   int
+	files     int
+	allow     func(name string) error
+}
+
+func (x *extractor) zip(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	for _, zf := range r.File {
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			if err := x.mkdir(zf.Name); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := zf.Open()
+			if err != nil {
+				return fmt.Errorf("%s: %w", zf.Name, err)
+			}
+			err = x.writeFile(zf.Name, rc)
+			_ = rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			log.Printf("archive extract: skipping %s (%s)", zf.Name, mode.Type())
+		}
+	}
+	return nil
+}
+
+func (x *extractor) tarGz(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = gz.Close() }()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := x.mkdir(hdr.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := x.writeFile(hdr.Name, tr); err != nil {
+				return err
+			}
+		default:
+			log.Printf("archive extract: skipping %s (tar type %q)", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+// target resolves an entry name to a path inside x.dir.
+func (x *extractor) target(name string) (string, error) {
+	x.entries++
+	if x.entries > maxArchiveEntries {
+		return "", fmt.Errorf("archive has more than %d entries", maxArchiveEntries)
+	}
+	rel := filepath.FromSlash(strings.ReplaceAll(name, `\`, "/"))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("unsafe path in archive: %q", name)
+	}
+	return filepath.Join(x.dir, rel), nil
+}
+
+func (x *extractor) mkdir(name string) error {
+	path, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0o755)
+}
+
+func (x *extractor) writeFile(name string, r io.Reader) error {
+	path, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if x.allow != nil {
+		if err := x.allow(name); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	// Declared sizes can lie; count what is actually decompressed.
+	n, err := io.Copy(out, io.LimitReader(r, x.remaining+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	x.remaining -= n
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if x.remaining < 0 {
+		x.remaining = 0
+		return &archiveOverflow{limit: x.limit}
+	}
+	x.files++
+	return nil
+}
@@ -0,0 +1,92 @@
+package agentio
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "fixture.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractArchiveZipAndTarGz(t *testing.T) {
+	zipPath := writeZip(t, map[string]string{"fixtures/a.txt": "alpha", "fixtures/nested/b.txt": "beta"})
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	_ = tw.WriteHeader(&tar.Header{Name: "fixtures/", Typeflag: tar.TypeDir, Mode: 0o755})
+	_ = tw.WriteHeader(&tar.Header{Name: "fixtures/a.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5})
+	_, _ = tw.Write([]byte("alpha"))
+	_ = tw.WriteHeader(&tar.Header{Name: "fixtures/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	_ = tw.Close()
+	_ = gz.Close()
+	tgzPath := filepath.Join(t.TempDir(), "fixture.tar.gz")
+	if err := os.WriteFile(tgzPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, archive := range []string{zipPath, tgzPath} {
+		dir := t.TempDir()
+		if _, _, err := ExtractArchive(archive, dir, 1024, nil); err != nil {
+			t.Fatalf("ExtractArchive(%s) error = %v", filepath.Base(archive), err)
+		}
+		got, err := os.ReadFile(filepath.Join(dir, "fixtures", "a.txt"))
+		if err != nil || string(got) != "alpha" {
+			t.Fatalf("%s: a.txt = %q, %v", filepath.Base(archive), got, err)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "fixtures", "link")); !os.IsNotExist(err) {
+			t.Fatalf("%s: symlink entry should be skipped, Lstat err = %v", filepath.Base(archive), err)
+		}
+	}
+}
+
+func TestExtractArchiveRejectsTraversalAndBombs(t *testing.T) {
+	dir := t.TempDir()
+	_, _, err := ExtractArchive(writeZip(t, map[string]string{"../escape.txt": "x"}), dir, 1024, nil)
+	if err == nil || !strings.Contains(err.Error(), "unsafe path") {
+		t.Fatalf("traversal error = %v, want unsafe path", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); !os.IsNotExist(err) {
+		t.Fatal("traversal entry was written outside the target directory")
+	}
+
+	_, _, err = ExtractArchive(writeZip(t, map[string]string{"big.txt": strings.Repeat("z", 2048)}), t.TempDir(), 1024, nil)
+	var overflow *archiveOverflow
+	if !errors.As(err, &overflow) {
+		t.Fatalf("oversized error = %v, want archiveOverflow", err)
+	}
+}
+
+func TestExtractArchiveAppliesEntryPolicy(t *testing.T) {
+	p := &UploadPolicy{Extensions: []string{".txt"}}
+	_, _, err := ExtractArchive(writeZip(t, map[string]string{"run.sh": "echo"}), t.TempDir(), 1024, p.allowEntry)
+	var rejection *Rejection
+	if !errors.As(err, &rejection) || rejection.Rule != "extension" {
+		t.Fatalf("error = %v, want extension rejection", err)
+	}
+}
@@ -0,0 +1,80 @@
+package agentio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// atMentionRuntimes read "@path" in a prompt as a file attachment. Other
+// runtimes get the file's absolute path.
+var atMentionRuntimes = map[string]bool{
+	"claude": true,
+	"gemini": true,
+}
+
+// FileReference formats a path so the runtime treats it as an attached file.
+// For @-mention runtimes, relPath (relative to the pane's working directory,
+// or "" when outside it) keeps the mention short.
+func FileReference(runtime, absPath, relPath string) string {
+	if !atMentionRuntimes[runtime] {
+		return absPath
+	}
+	ref := absPath
+	if relPath != "" {
+		ref = relPath
+	}
+	// Both CLIs end a mention at whitespace unless it is escaped.
+	return "@" + strings.ReplaceAll(ref, " ", `\ `)
+}
+
+// ExpandAttachments appends a runtime-appropriate reference for each uploaded
+// file ID (as returned by HandleFileUpload) to prompt. IDs resolve only
+// within the agent's own upload directories.
+func (p *Prompter) ExpandAttachments(agentName, prompt string, fileIDs []string) (string, error) {
+	if len(fileIDs) == 0 {
+		return prompt, nil
+	}
+	agent, err := p.lookup(agentName)
+	if err != nil {
+		return "", err
+	}
+	baseDir := agent.WorkDir
+	if paneInfo, err := p.Ctrl.GetPaneInfo(agentName); err == nil && strings.TrimSpace(paneInfo.WorkDir) != "" {
+		baseDir = paneInfo.WorkDir
+	}
+
+	refs := make([]string, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		path, err := resolveUpload(agent.WorkDir, agentName, id)
+		if err != nil {
+			return "", err
+		}
+		rel := BuildServerPastePath(baseDir, path)
+		if rel == path {
+			rel = ""
+		}
+		refs = append(refs, FileReference(agent.Runtime, path, rel))
+	}
+	// Space-separated: a newline would submit the prompt early in most runtimes.
+	if strings.TrimSpace(prompt) == "" {
+		return strings.Join(refs, " "), nil
+	}
+	return prompt + " " + strings.Join(refs, " "), nil
+}
+
+// resolveUpload finds an uploaded file or extracted archive by ID in the
+// agent's upload directories.
+func resolveUpload(workDir, agentName, fileID string) (string, error) {
+	if fileID == "" || fileID != filepath.Base(fileID) || fileID == "." || fileID == ".." {
+		return "", fmt.Errorf("invalid file ID %q", fileID)
+	}
+	for _, dir := range uploadDirs(workDir, agentName) {
+		path := filepath.Join(dir, fileID)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("uploaded file %q not found", fileID)
+}
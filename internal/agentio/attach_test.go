@@ -0,0 +1,43 @@
+package agentio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileReference(t *testing.T) {
+	tests := []struct {
+		runtime, abs, rel, want string
+	}{
+		{"claude", "/w/.tmux-adapter/uploads/a.txt", "./.tmux-adapter/uploads/a.txt", "@./.tmux-adapter/uploads/a.txt"},
+		{"gemini", "/tmp/my notes.txt", "", `@/tmp/my\ notes.txt`},
+		{"codex", "/w/.tmux-adapter/uploads/a.txt", "./.tmux-adapter/uploads/a.txt", "/w/.tmux-adapter/uploads/a.txt"},
+	}
+	for _, tt := range tests {
+		if got := FileReference(tt.runtime, tt.abs, tt.rel); got != tt.want {
+			t.Errorf("FileReference(%q, %q, %q) = %q, want %q", tt.runtime, tt.abs, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestResolveUpload(t *testing.T) {
+	workDir := t.TempDir()
+	dir := filepath.Join(workDir, ".tmux-adapter", "uploads")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "20260101-000000-a.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := resolveUpload(workDir, "hq-mayor", "20260101-000000-a.txt")
+	if err != nil || got != filepath.Join(dir, "20260101-000000-a.txt") {
+		t.Fatalf("resolveUpload = %q, %v", got, err)
+	}
+	for _, id := range []string{"", ".", "..", "../secret", "missing.txt"} {
+		if _, err := resolveUpload(workDir, "hq-mayor", id); err == nil {
+			t.Errorf("resolveUpload(%q) should fail", id)
+		}
+	}
+}
@@ -12,6 +12,13 @@ const (
 	BinaryResize           byte = 0x03 // client → server: resize
 	BinaryFileUpload       byte = 0x04 // client → server: file upload for paste
 	BinaryTerminalSnapshot byte = 0x05 // server → client: terminal snapshot/refresh
+	BinaryUploadBegin      byte = 0x06 // client → server: start or resume a chunked upload
+	BinaryUploadChunk      byte = 0x07 // client → server: chunked upload data
+	BinaryUploadCommit     byte = 0x08 // client → server: finish a chunked upload
+	BinaryArchiveUpload    byte = 0x09 // client → server: zip/tar.gz upload to extract
+	BinaryFileAttach       byte = 0x0A // client → server: file upload saved for send-prompt attachments, not pasted
+	BinaryPaneOutput       byte = 0x0B // server → client: output of one pane in a subscribed window
+	BinaryBlob             byte = 0x0C // server → client: blob data answering get-blob, named by blob ID
 )
 
 // ParseBinaryEnvelope parses a binary WebSocket frame into its components.
@@ -45,3 +52,13 @@ func MakeBinaryFrame(msgType byte, agentName string, payload []byte) []byte {
 	frame = append(frame, payload...)
 	return frame
 }
+
+// MakePaneFrame builds a 0x0B frame carrying output from one pane of
+// agentName's window: the payload is paneID + \0 + bytes.
+func MakePaneFrame(agentName, paneID string, data []byte) []byte {
+	payload := make([]byte, 0, len(paneID)+1+len(data))
+	payload = append(payload, paneID...)
+	payload = append(payload, 0)
+	payload = append(payload, data...)
+	return MakeBinaryFrame(BinaryPaneOutput, agentName, payload)
+}
@@ -59,3 +59,17 @@ func TestMakeBinaryFrame(t *testing.T) {
 		t.Fatalf("payload = %q, want %q", string(payload), "hello")
 	}
 }
+
+func TestMakePaneFrame(t *testing.T) {
+	frame := MakePaneFrame("agent1", "%4", []byte("ok\n"))
+	msgType, agentName, payload, err := ParseBinaryEnvelope(frame)
+	if err != nil {
+		t.Fatalf("roundtrip error: %v", err)
+	}
+	if msgType != BinaryPaneOutput || agentName != "agent1" {
+		t.Fatalf("frame = 0x%02x %q, want 0x%02x %q", msgType, agentName, BinaryPaneOutput, "agent1")
+	}
+	if string(payload) != "%4\x00ok\n" {
+		t.Fatalf("payload = %q, want pane ID, NUL, data", payload)
+	}
+}
@@ -0,0 +1,349 @@
+package agentio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxChunkedUploadBytes is the largest file a chunked upload may carry.
+	MaxChunkedUploadBytes = 1 << 30
+	// maxChunkedUploads caps uploads in flight across all clients.
+	maxChunkedUploads = 32
+	// maxChunkedUploadsPerClient caps uploads in flight for one client, so a
+	// single connection cannot take every slot.
+	maxChunkedUploadsPerClient = 4
+	// chunkedUploadIdleTimeout discards uploads that have received nothing for this long.
+	chunkedUploadIdleTimeout = 10 * time.Minute
+)
+
+// UploadProgress reports how much of a chunked upload the server holds.
+// FileID is set once a committed upload is saved.
+type UploadProgress struct {
+	UploadID string `json:"uploadId"`
+	Received int64  `json:"received"`
+	Total    int64  `json:"total"`
+	FileID   string `json:"fileId,omitempty"`
+}
+
+// chunkedUpload is a file being reassembled in a staging file outside the
+// agent's workdir. Chunks must arrive in order; the hash runs as they land.
+// It belongs to the client that began it and is discarded when that client
+// disconnects or sends nothing for chunkedUploadIdleTimeout.
+type chunkedUpload struct {
+	mu       sync.Mutex
+	key      string // agent + \0 + upload ID
+	owner    any
+	id       string
+	fileName string
+	mimeType string
+	checksum string // expected hex SHA-256, or "" to skip verification
+	total    int64
+	received int64
+	path     string
+	file     *os.File
+	hash     hash.Hash
+	touched  time.Time
+	idle     *time.Timer // fires expireChunkedUpload
+}
+
+// BeginChunkedUpload starts, or resumes, a chunked upload. Payload format:
+// uploadID + \0 + fileName + \0 + mimeType + \0 + totalBytes + \0 + sha256hex.
+// Beginning an upload ID the same client already has in flight for the agent
+// with the same file name and size reports its progress so the client can
+// resume from there. client identifies the connection, as for ControlLocks.
+func (p *Prompter) BeginChunkedUpload(agentName string, client any, payload []byte) (UploadProgress, error) {
+	fields := strings.SplitN(string(payload), "\x00", 5)
+	if len(fields) != 5 {
+		return UploadProgress{}, errors.New("invalid upload begin: expected uploadId, fileName, mimeType, size, sha256")
+	}
+	id, fileName, mimeType, checksum := fields[0], strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2]), strings.ToLower(strings.TrimSpace(fields[4]))
+	total, err := strconv.ParseInt(fields[3], 10, 64)
+	if id == "" || err != nil || total < 0 {
+		return UploadProgress{}, errors.New("invalid upload begin: missing uploadId or bad size")
+	}
+	if total > MaxChunkedUploadBytes {
+		return UploadProgress{}, fmt.Errorf("file %q too large: %d bytes (max %d)", fileName, total, MaxChunkedUploadBytes)
+	}
+	if fileName == "" {
+		fileName = "attachment.bin"
+	}
+
+	agent, err := p.lookup(agentName)
+	if err != nil {
+		return UploadProgress{}, err
+	}
+	// Reject before any bytes move; the scanner and MIME sniffing wait for commit.
+	if err := p.Uploads.checkMeta(agentName, agent.WorkDir, fileName, mimeType, total); err != nil {
+		return UploadProgress{}, fmt.Errorf("file %q: %w", fileName, err)
+	}
+
+	key := agentName + "\x00" + id
+	p.chunksMu.Lock()
+	if u, ok := p.chunks[key]; ok {
+		// Never wait on an upload's lock while holding chunksMu; commit takes them the other way round.
+		p.chunksMu.Unlock()
+		if u.owner != client {
+			return UploadProgress{}, fmt.Errorf("upload %s already in progress for another client", id)
+		}
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if u.fileName != fileName || u.total != total {
+			return UploadProgress{}, fmt.Errorf("upload %s already in progress for a different file", id)
+		}
+		u.touched = time.Now()
+		return u.progress(), nil
+	}
+	defer p.chunksMu.Unlock()
+	if len(p.chunks) >= maxChunkedUploads {
+		return UploadProgress{}, errors.New("too many uploads in progress")
+	}
+	owned := 0
+	for _, u := range p.chunks {
+		if u.owner == client {
+			owned++
+		}
+	}
+	if owned >= maxChunkedUploadsPerClient {
+		return UploadProgress{}, fmt.Errorf("too many uploads in progress for this connection (max %d)", maxChunkedUploadsPerClient)
+	}
+
+	u, err := newChunkedUpload(key, client, id, fileName, mimeType, checksum, total)
+	if err != nil {
+		return UploadProgress{}, err
+	}
+	u.idle = time.AfterFunc(chunkedUploadIdleTimeout, func() { p.expireChunkedUpload(u) })
+	p.chunks[key] = u
+	return u.progress(), nil
+}
+
+// WriteUploadChunk appends a chunk. Payload format: uploadID + \0 + offset + \0 + bytes.
+// Chunks whose offset is not the number of bytes received so far are dropped
+// (retransmits) and the returned progress tells the client where to resume.
+func (p *Prompter) WriteUploadChunk(agentName string, client any, payload []byte) (UploadProgress, error) {
+	id, rest, ok := bytes.Cut(payload, []byte{0})
+	if !ok {
+		return UploadProgress{}, errors.New("invalid upload chunk: missing uploadId separator")
+	}
+	offsetField, data, ok := bytes.Cut(rest, []byte{0})
+	if !ok {
+		return UploadProgress{}, errors.New("invalid upload chunk: missing offset separator")
+	}
+	offset, err := strconv.ParseInt(string(offsetField), 10, 64)
+	if err != nil {
+		return UploadProgress{}, errors.New("invalid upload chunk: bad offset")
+	}
+
+	u, err := p.chunkedUpload(agentName, client, string(id))
+	if err != nil {
+		return UploadProgress{}, err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	err = u.write(offset, data)
+	return u.progress(), err
+}
+
+// CommitChunkedUpload verifies a complete upload's size and checksum, screens
+// it with Uploads, moves it into the agent's upload directory, and pastes it.
+// Payload is the uploadID, optionally followed by \0 and comma-separated
+// modes: "extract" unpacks the file as an archive (see HandleArchiveUpload),
+// and "attach" saves it without pasting, for send-prompt attachments.
+// The caller must hold the per-agent lock.
+func (p *Prompter) CommitChunkedUpload(agentName string, client any, payload []byte) (UploadProgress, error) {
+	id, modes, _ := strings.Cut(string(payload), "\x00")
+	var extract, attach bool
+	for _, mode := range strings.Split(modes, ",") {
+		switch mode {
+		case "":
+		case "extract":
+			extract = true
+		case "attach":
+			attach = true
+		default:
+			return UploadProgress{}, fmt.Errorf("unknown upload commit mode %q", mode)
+		}
+	}
+	u, err := p.chunkedUpload(agentName, client, id)
+	if err != nil {
+		return UploadProgress{}, err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	progress := u.progress()
+	if u.received != u.total {
+		return progress, fmt.Errorf("upload %s incomplete: %d of %d bytes", id, u.received, u.total)
+	}
+
+	// From here the upload is finished one way or another.
+	p.chunksMu.Lock()
+	delete(p.chunks, u.key)
+	p.chunksMu.Unlock()
+	defer u.discard()
+
+	if err := u.finish(); err != nil {
+		return progress, err
+	}
+
+	agent, err := p.lookup(agentName)
+	if err != nil {
+		return progress, err
+	}
+	if extract {
+		progress.FileID, err = p.extractUpload(agent, u.fileName, u.path, !attach)
+		return progress, err
+	}
+	if err := p.Uploads.CheckFile(agentName, agent.WorkDir, u.fileName, u.mimeType, u.path); err != nil {
+		return progress, fmt.Errorf("file %q: %w", u.fileName, err)
+	}
+
+	var content []byte
+	if u.total <= maxInlinePasteBytes {
+		if content, err = os.ReadFile(u.path); err != nil {
+			return progress, fmt.Errorf("upload %s: %w", id, err)
+		}
+	}
+	savedPath, err := MoveUploadedFile(agent.WorkDir, agentName, u.fileName, u.path)
+	if err != nil {
+		return progress, fmt.Errorf("save uploaded file: %w", err)
+	}
+	progress.FileID = filepath.Base(savedPath)
+	if attach {
+		return progress, nil
+	}
+	return progress, p.pasteUpload(agent, u.fileName, u.mimeType, savedPath, u.total, content)
+}
+
+// chunkedUpload returns the client's upload id for the agent. Another
+// client's upload is reported as not found.
+func (p *Prompter) chunkedUpload(agentName string, client any, id string) (*chunkedUpload, error) {
+	p.chunksMu.Lock()
+	defer p.chunksMu.Unlock()
+	u, ok := p.chunks[agentName+"\x00"+id]
+	if !ok || u.owner != client {
+		return nil, fmt.Errorf("upload %s not found", id)
+	}
+	return u, nil
+}
+
+// ReleaseChunkedUploads discards every upload client has in flight, for a
+// connection that closed.
+func (p *Prompter) ReleaseChunkedUploads(client any) {
+	p.chunksMu.Lock()
+	var released []*chunkedUpload
+	for key, u := range p.chunks {
+		if u.owner == client {
+			delete(p.chunks, key)
+			released = append(released, u)
+		}
+	}
+	p.chunksMu.Unlock()
+	for _, u := range released {
+		// Wait out a chunk being written; commit has already removed its upload.
+		u.mu.Lock()
+		u.discard()
+		u.mu.Unlock()
+	}
+}
+
+// expireChunkedUpload discards u if it has been idle for
+// chunkedUploadIdleTimeout, and otherwise waits out the rest of the timeout.
+func (p *Prompter) expireChunkedUpload(u *chunkedUpload) {
+	p.chunksMu.Lock()
+	if p.chunks[u.key] != u {
+		p.chunksMu.Unlock()
+		return // committed or released
+	}
+	if !u.mu.TryLock() {
+		p.chunksMu.Unlock()
+		u.idle.Reset(chunkedUploadIdleTimeout) // busy, so not idle
+		return
+	}
+	defer u.mu.Unlock()
+	if left := chunkedUploadIdleTimeout - time.Since(u.touched); left > 0 {
+		p.chunksMu.Unlock()
+		u.idle.Reset(left)
+		return
+	}
+	delete(p.chunks, u.key)
+	p.chunksMu.Unlock()
+	log.Printf("chunked upload %s (%q) expired at %d of %d bytes", u.id, u.fileName, u.received, u.total)
+	u.discard()
+}
+
+func newChunkedUpload(key string, owner any, id, fileName, mimeType, checksum string, total int64) (*chunkedUpload, error) {
+	f, err := os.CreateTemp("", "tmux-adapter-upload-*-"+SanitizePathComponent(fileName))
+	if err != nil {
+		return nil, fmt.Errorf("stage upload: %w", err)
+	}
+	return &chunkedUpload{
+		key:      key,
+		owner:    owner,
+		id:       id,
+		fileName: fileName,
+		mimeType: mimeType,
+		checksum: checksum,
+		total:    total,
+		path:     f.Name(),
+		file:     f,
+		hash:     sha256.New(),
+		touched:  time.Now(),
+	}, nil
+}
+
+// write appends data if it starts at the next expected offset and silently
+// drops it otherwise. The caller holds u.mu.
+func (u *chunkedUpload) write(offset int64, data []byte) error {
+	u.touched = time.Now()
+	if offset != u.received {
+		return nil
+	}
+	if u.received+int64(len(data)) > u.total {
+		return fmt.Errorf("upload %s: chunk overruns declared size %d", u.id, u.total)
+	}
+	if _, err := u.file.Write(data); err != nil {
+		return fmt.Errorf("upload %s: stage chunk: %w", u.id, err)
+	}
+	u.hash.Write(data)
+	u.received += int64(len(data))
+	return nil
+}
+
+// finish verifies the checksum of a complete upload and closes its staging
+// file. The caller holds u.mu.
+func (u *chunkedUpload) finish() error {
+	if u.checksum != "" {
+		if sum := hex.EncodeToString(u.hash.Sum(nil)); sum != u.checksum {
+			return fmt.Errorf("upload %s checksum mismatch: got sha256 %s", u.id, sum)
+		}
+	}
+	if err := u.file.Close(); err != nil {
+		return fmt.Errorf("upload %s: %w", u.id, err)
+	}
+	return nil
+}
+
+func (u *chunkedUpload) progress() UploadProgress {
+	return UploadProgress{UploadID: u.id, Received: u.received, Total: u.total}
+}
+
+// discard stops the idle timer and closes and removes the staging file;
+// after a successful move it is already gone.
+func (u *chunkedUpload) discard() {
+	if u.idle != nil {
+		u.idle.Stop()
+	}
+	_ = u.file.Close()
+	_ = os.Remove(u.path)
+}
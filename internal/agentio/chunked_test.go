@@ -0,0 +1,120 @@
+package agentio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkedUploadReassembly(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	sum := sha256.Sum256(data)
+
+	u, err := newChunkedUpload("hq-mayor\x00u1", "client-1", "u1", "fox.txt", "text/plain", hex.EncodeToString(sum[:]), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.discard()
+
+	steps := []struct {
+		offset int64
+		chunk  []byte
+		want   int64
+	}{
+		{offset: 0, chunk: data[:16], want: 16},
+		{offset: 0, chunk: data[:16], want: 16},    // retransmit is dropped
+		{offset: 30, chunk: data[30:], want: 16},   // gap is dropped
+		{offset: 16, chunk: data[16:30], want: 30}, // client resumes at 16
+		{offset: 30, chunk: data[30:], want: int64(len(data))},
+	}
+	for i, s := range steps {
+		if err := u.write(s.offset, s.chunk); err != nil {
+			t.Fatalf("step %d: write error = %v", i, err)
+		}
+		if got := u.progress().Received; got != s.want {
+			t.Fatalf("step %d: received = %d, want %d", i, got, s.want)
+		}
+	}
+
+	if err := u.finish(); err != nil {
+		t.Fatalf("finish error = %v", err)
+	}
+	got, err := os.ReadFile(u.path)
+	if err != nil || string(got) != string(data) {
+		t.Fatalf("staged file = %q, %v; want %q", got, err, data)
+	}
+}
+
+func TestChunkedUploadRejectsBadChecksumAndOverrun(t *testing.T) {
+	u, err := newChunkedUpload("hq-mayor\x00u2", "client-1", "u2", "a.bin", "", strings.Repeat("0", 64), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.discard()
+
+	if err := u.write(0, []byte("12345")); err == nil {
+		t.Fatal("chunk past declared size should fail")
+	}
+	if err := u.write(0, []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.finish(); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("finish error = %v, want checksum mismatch", err)
+	}
+}
+
+func TestChunkedUploadsBelongToTheirClient(t *testing.T) {
+	p := &Prompter{chunks: make(map[string]*chunkedUpload)}
+	for _, id := range []string{"u1", "u2"} {
+		u, err := newChunkedUpload("hq-mayor\x00"+id, "client-1", id, "a.txt", "", "", 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.chunks[u.key] = u
+	}
+	if _, err := p.chunkedUpload("hq-mayor", "client-1", "u1"); err != nil {
+		t.Errorf("owner lookup error = %v", err)
+	}
+	if _, err := p.chunkedUpload("hq-mayor", "client-2", "u1"); err == nil {
+		t.Error("another client found the upload")
+	}
+
+	staged := p.chunks["hq-mayor\x00u1"].path
+	p.ReleaseChunkedUploads("client-2")
+	if len(p.chunks) != 2 {
+		t.Fatalf("releasing another client left %d uploads, want 2", len(p.chunks))
+	}
+	p.ReleaseChunkedUploads("client-1")
+	if len(p.chunks) != 0 {
+		t.Fatalf("released client still has %d uploads", len(p.chunks))
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Errorf("staging file survived release: %v", err)
+	}
+}
+
+func TestChunkedUploadExpiresWhenIdle(t *testing.T) {
+	p := &Prompter{chunks: make(map[string]*chunkedUpload)}
+	u, err := newChunkedUpload("hq-mayor\x00u1", "client-1", "u1", "a.txt", "", "", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.idle = time.AfterFunc(time.Hour, func() {})
+	p.chunks[u.key] = u
+
+	p.expireChunkedUpload(u) // just touched: kept, timer rearmed
+	if p.chunks[u.key] != u {
+		t.Fatal("active upload expired")
+	}
+	u.touched = time.Now().Add(-chunkedUploadIdleTimeout - time.Second)
+	p.expireChunkedUpload(u)
+	if _, ok := p.chunks[u.key]; ok {
+		t.Fatal("idle upload kept")
+	}
+	if _, err := os.Stat(u.path); !os.IsNotExist(err) {
+		t.Errorf("staging file survived expiry: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package agentio
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// slashCommand is one runtime's spelling of a normalized command.
+type slashCommand struct {
+	text string
+	arg  bool // the runtime accepts an argument after the command
+}
+
+// runtimeCommands maps each runtime to its spelling of the normalized
+// run-command names. Runtimes missing from the table, or commands missing
+// from a runtime's entry, are reported as unsupported.
+var runtimeCommands = map[string]map[string]slashCommand{
+	"claude": {
+		"clear":   {text: "/clear"},
+		"compact": {text: "/compact", arg: true},
+		"model":   {text: "/model", arg: true},
+		"resume":  {text: "/resume", arg: true},
+	},
+	"gemini": {
+		"clear":   {text: "/clear"},
+		"compact": {text: "/compress"},
+		"model":   {text: "/model"},
+		"resume":  {text: "/chat resume", arg: true},
+	},
+	"codex": {
+		"clear":   {text: "/new"},
+		"compact": {text: "/compact"},
+		"model":   {text: "/model"},
+	},
+	"opencode": {
+		"clear":   {text: "/new"},
+		"compact": {text: "/compact"},
+		"model":   {text: "/models"},
+		"resume":  {text: "/sessions"},
+	},
+}
+
+// SlashCommand translates a normalized command ("clear", "compact",
+// "model sonnet", "resume") into the text runtime expects at its prompt.
+func SlashCommand(runtime, command string) (string, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(command), " ")
+	arg = strings.TrimSpace(arg)
+	if name == "" {
+		return "", errors.New("command required")
+	}
+	cmd, ok := runtimeCommands[runtime][name]
+	if !ok {
+		return "", fmt.Errorf("command %q not supported for runtime %q", name, runtime)
+	}
+	if arg == "" {
+		return cmd.text, nil
+	}
+	if !cmd.arg {
+		return "", fmt.Errorf("command %q takes no argument for runtime %q", name, runtime)
+	}
+	if strings.ContainsAny(arg, "\r\n") {
+		return "", errors.New("command argument must be a single line")
+	}
+	return cmd.text + " " + arg, nil
+}
+
+// RunCommand sends a normalized command to an agent, translated for its
+// runtime. An argument is screened by Policy.CheckArgument, which skips the
+// required prefix, and must stay a single line. Commands are always
+// submitted, even for runtimes whose strategy is paste-only.
+// The caller must hold the per-agent lock.
+func (p *Prompter) RunCommand(agentName, command string) error {
+	agent, err := p.lookup(agentName)
+	if err != nil {
+		return err
+	}
+	name, arg, _ := strings.Cut(strings.TrimSpace(command), " ")
+	if arg = strings.TrimSpace(arg); arg != "" {
+		if arg, err = p.Policy.CheckArgument(agentName, arg); err != nil {
+			return err
+		}
+		command = name + " " + arg
+	}
+	text, err := SlashCommand(agent.Runtime, command)
+	if err != nil {
+		return err
+	}
+	log.Printf("run-command(%s): %s", agentName, text)
+	return p.submit(agent, text, p.commandStrategy(agent.Runtime), nil)
+}
+
+// commandStrategy is runtime's submit strategy in SubmitAuto mode, for text
+// that must be submitted whatever the strategy's mode.
+func (p *Prompter) commandStrategy(runtime string) SubmitStrategy {
+	strategy := p.Submit.For(runtime)
+	strategy.Mode = SubmitAuto
+	if len(strategy.Keys) == 0 {
+		strategy.Keys = DefaultSubmitStrategy.Keys
+	}
+	return strategy
+}
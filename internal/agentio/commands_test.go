@@ -0,0 +1,32 @@
+package agentio
+
+import "testing"
+
+func TestSlashCommand(t *testing.T) {
+	tests := []struct {
+		runtime, command, want string
+		wantErr                bool
+	}{
+		{runtime: "claude", command: "clear", want: "/clear"},
+		{runtime: "claude", command: "model  opus", want: "/model opus"},
+		{runtime: "gemini", command: "compact", want: "/compress"},
+		{runtime: "codex", command: "clear", want: "/new"},
+		{runtime: "codex", command: "model o3", wantErr: true},
+		{runtime: "codex", command: "resume", wantErr: true},
+		{runtime: "auggie", command: "clear", wantErr: true},
+		{runtime: "claude", command: "", wantErr: true},
+		{runtime: "claude", command: "model opus\n/clear", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := SlashCommand(tt.runtime, tt.command)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("SlashCommand(%q, %q) = %q, want error", tt.runtime, tt.command, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("SlashCommand(%q, %q) = %q, %v; want %q", tt.runtime, tt.command, got, err, tt.want)
+		}
+	}
+}
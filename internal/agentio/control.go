@@ -0,0 +1,172 @@
+package agentio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ControlLocks tracks which client, if any, holds input control of each
+// agent. While an agent is controlled, only the holder may type into it,
+// send prompts, or paste files; everyone else observes.
+//
+// Locks are per process: the adapter and the converter each keep their own,
+// so control acquired through one does not stop input sent through the other.
+type ControlLocks struct {
+	mu          sync.Mutex
+	holders     map[string]*controlHolder // agent name → holder
+	idleTimeout time.Duration
+	onExpire    func(agent string)
+}
+
+type controlHolder struct {
+	agent    string
+	client   any
+	label    string // shown to other clients, e.g. "client-3"
+	lastUsed time.Time
+	idle     *time.Timer
+}
+
+// NewControlLocks creates an empty set of control locks.
+func NewControlLocks() *ControlLocks {
+	return &ControlLocks{holders: make(map[string]*controlHolder)}
+}
+
+// SetIdleTimeout releases a lock whose holder has sent no input to the agent
+// for d, then calls onExpire with the agent's name. Zero disables expiry.
+// Must be called before the first Acquire.
+func (l *ControlLocks) SetIdleTimeout(d time.Duration, onExpire func(agent string)) {
+	l.idleTimeout = d
+	l.onExpire = onExpire
+}
+
+// Acquire gives client control of agent. It succeeds if the agent is free or
+// already held by client; otherwise it returns the current holder's label.
+func (l *ControlLocks) Acquire(agent string, client any, label string) (holder string, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if h, held := l.holders[agent]; held {
+		if h.client != client {
+			return h.label, false
+		}
+		h.lastUsed = time.Now()
+		return h.label, true
+	}
+	h := &controlHolder{agent: agent, client: client, label: label, lastUsed: time.Now()}
+	if l.idleTimeout > 0 {
+		h.idle = time.AfterFunc(l.idleTimeout, func() { l.expire(h) })
+	}
+	l.holders[agent] = h
+	return label, true
+}
+
+// Release gives up client's control of agent. It reports whether client held it.
+func (l *ControlLocks) Release(agent string, client any) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if h, held := l.holders[agent]; held && h.client == client {
+		l.removeLocked(h)
+		return true
+	}
+	return false
+}
+
+// ForceRelease frees agent whoever holds it, for an operator clearing a
+// lock its holder abandoned. It returns the former holder's label.
+func (l *ControlLocks) ForceRelease(agent string) (holder string, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, held := l.holders[agent]
+	if !held {
+		return "", false
+	}
+	l.removeLocked(h)
+	return h.label, true
+}
+
+// ReleaseAll gives up every lock client holds and returns the affected agents.
+func (l *ControlLocks) ReleaseAll(client any) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var released []string
+	for agent, h := range l.holders {
+		if h.client == client {
+			l.removeLocked(h)
+			released = append(released, agent)
+		}
+	}
+	return released
+}
+
+// Holder returns the label of the client controlling agent, or "" if none.
+func (l *ControlLocks) Holder(agent string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h, held := l.holders[agent]; held {
+		return h.label
+	}
+	return ""
+}
+
+// CheckInput returns an error if another client controls agent. Input from
+// the holder itself counts as activity for the idle timeout.
+func (l *ControlLocks) CheckInput(agent string, client any) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, held := l.holders[agent]
+	if !held {
+		return nil
+	}
+	if h.client != client {
+		return fmt.Errorf("%s is controlled by %s; input is read-only until they release control", agent, h.label)
+	}
+	h.lastUsed = time.Now()
+	return nil
+}
+
+// Rename moves the lock on oldName to newName, for an agent whose session
+// was renamed.
+func (l *ControlLocks) Rename(oldName, newName string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h, held := l.holders[oldName]; held {
+		delete(l.holders, oldName)
+		h.agent = newName
+		l.holders[newName] = h
+	}
+}
+
+// removeLocked drops h and stops its idle timer. The caller holds l.mu.
+func (l *ControlLocks) removeLocked(h *controlHolder) {
+	delete(l.holders, h.agent)
+	if h.idle != nil {
+		h.idle.Stop()
+	}
+}
+
+// expire releases h if it has been idle for the whole timeout, and otherwise
+// re-arms its timer for the time remaining.
+func (l *ControlLocks) expire(h *controlHolder) {
+	l.mu.Lock()
+	if l.holders[h.agent] != h {
+		l.mu.Unlock()
+		return
+	}
+	if remaining := l.idleTimeout - time.Since(h.lastUsed); remaining > 0 {
+		h.idle.Reset(remaining)
+		l.mu.Unlock()
+		return
+	}
+	l.removeLocked(h)
+	agent := h.agent
+	l.mu.Unlock()
+
+	if l.onExpire != nil {
+		l.onExpire(agent)
+	}
+}
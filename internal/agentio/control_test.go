@@ -0,0 +1,117 @@
+package agentio
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlLocksAcquireAndRelease(t *testing.T) {
+	l := NewControlLocks()
+	a, b := new(int), new(int)
+
+	if holder, ok := l.Acquire("hq-mayor", a, "client-1"); !ok || holder != "client-1" {
+		t.Fatalf("Acquire by a = (%q, %v), want (client-1, true)", holder, ok)
+	}
+	if _, ok := l.Acquire("hq-mayor", a, "client-1"); !ok {
+		t.Fatal("re-acquire by holder should succeed")
+	}
+	if holder, ok := l.Acquire("hq-mayor", b, "client-2"); ok || holder != "client-1" {
+		t.Fatalf("Acquire by b = (%q, %v), want (client-1, false)", holder, ok)
+	}
+
+	if err := l.CheckInput("hq-mayor", a); err != nil {
+		t.Fatalf("holder input rejected: %v", err)
+	}
+	err := l.CheckInput("hq-mayor", b)
+	if err == nil || !strings.Contains(err.Error(), "client-1") {
+		t.Fatalf("observer input error = %v, want error naming client-1", err)
+	}
+
+	if l.Release("hq-mayor", b) {
+		t.Fatal("non-holder release should fail")
+	}
+	if !l.Release("hq-mayor", a) {
+		t.Fatal("holder release should succeed")
+	}
+	if l.Holder("hq-mayor") != "" || l.CheckInput("hq-mayor", b) != nil {
+		t.Fatal("agent should be free after release")
+	}
+}
+
+func TestControlLocksReleaseAll(t *testing.T) {
+	l := NewControlLocks()
+	a, b := new(int), new(int)
+	l.Acquire("hq-mayor", a, "client-1")
+	l.Acquire("hq-deacon", a, "client-1")
+	l.Acquire("gt-witness", b, "client-2")
+
+	released := l.ReleaseAll(a)
+	if len(released) != 2 {
+		t.Fatalf("ReleaseAll released %v, want 2 agents", released)
+	}
+	if l.Holder("hq-mayor") != "" || l.Holder("gt-witness") != "client-2" {
+		t.Fatal("ReleaseAll should only drop the client's own locks")
+	}
+}
+
+func TestControlLocksRename(t *testing.T) {
+	l := NewControlLocks()
+	a, b := new(int), new(int)
+	l.Acquire("gt-gastown-crew-max", a, "client-1")
+
+	l.Rename("gt-gastown-crew-max", "gt-gastown-crew-maxine")
+	if l.Holder("gt-gastown-crew-max") != "" || l.Holder("gt-gastown-crew-maxine") != "client-1" {
+		t.Fatal("lock should follow the agent to its new name")
+	}
+	if l.CheckInput("gt-gastown-crew-maxine", b) == nil {
+		t.Fatal("observer input under the new name should be refused")
+	}
+}
+
+func TestControlLocksIdleExpiry(t *testing.T) {
+	l := NewControlLocks()
+	expired := make(chan string, 1)
+	l.SetIdleTimeout(50*time.Millisecond, func(agent string) { expired <- agent })
+	a := new(int)
+	l.Acquire("hq-mayor", a, "client-1")
+
+	// Input from the holder keeps the lock.
+	for range 3 {
+		time.Sleep(25 * time.Millisecond)
+		if err := l.CheckInput("hq-mayor", a); err != nil {
+			t.Fatalf("holder input rejected: %v", err)
+		}
+	}
+	if l.Holder("hq-mayor") != "client-1" {
+		t.Fatal("active holder lost control")
+	}
+
+	select {
+	case agent := <-expired:
+		if agent != "hq-mayor" {
+			t.Fatalf("expired %q, want hq-mayor", agent)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle lock never expired")
+	}
+	if l.Holder("hq-mayor") != "" {
+		t.Fatal("expired lock still held")
+	}
+}
+
+func TestControlLocksForceRelease(t *testing.T) {
+	l := NewControlLocks()
+	a := new(int)
+	l.Acquire("hq-mayor", a, "client-1")
+
+	if holder, ok := l.ForceRelease("hq-mayor"); !ok || holder != "client-1" {
+		t.Fatalf("ForceRelease = (%q, %v), want (client-1, true)", holder, ok)
+	}
+	if l.Holder("hq-mayor") != "" {
+		t.Fatal("agent should be free after ForceRelease")
+	}
+	if _, ok := l.ForceRelease("hq-mayor"); ok {
+		t.Fatal("ForceRelease of a free agent should report false")
+	}
+}
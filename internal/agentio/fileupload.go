@@ -3,6 +3,7 @@ package agentio
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -11,6 +12,8 @@ import (
 	"time"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
 )
 
 // MaxFileUploadBytes is the maximum allowed file upload size.
@@ -18,43 +21,61 @@ const MaxFileUploadBytes = 8 * 1024 * 1024
 
 const maxInlinePasteBytes = 256 * 1024
 
-// HandleFileUpload stores an uploaded file server-side, copies a pasteable
-// payload to the local clipboard when possible, and pastes into the tmux target.
+// HandleFileUpload stores an uploaded file server-side and, with paste set,
+// copies a pasteable payload to the local clipboard when possible and pastes
+// into the tmux target. It returns the file ID that send-prompt attachments
+// use to reference the file. Files refused by Uploads return a *Rejection.
 // The caller must hold the per-agent lock.
-func (p *Prompter) HandleFileUpload(agentName string, payload []byte) error {
+func (p *Prompter) HandleFileUpload(agentName string, payload []byte, paste bool) (string, error) {
 	fileName, mimeType, fileBytes, err := ParseFileUploadPayload(payload)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if len(fileBytes) > MaxFileUploadBytes {
-		return fmt.Errorf("file %q too large: %d bytes (max %d)", fileName, len(fileBytes), MaxFileUploadBytes)
+		return "", fmt.Errorf("file %q too large: %d bytes (max %d)", fileName, len(fileBytes), MaxFileUploadBytes)
+	}
+
+	agent, err := p.lookup(agentName)
+	if err != nil {
+		return "", err
 	}
 
-	agent, ok := p.Registry.GetAgent(agentName)
-	if !ok {
-		return fmt.Errorf("agent not found: %s", agentName)
+	if err := p.Uploads.Check(agentName, agent.WorkDir, fileName, mimeType, fileBytes); err != nil {
+		return "", fmt.Errorf("file %q: %w", fileName, err)
 	}
 
 	savedPath, err := SaveUploadedFile(agent.WorkDir, agentName, fileName, fileBytes)
 	if err != nil {
-		return fmt.Errorf("save uploaded file: %w", err)
+		return "", fmt.Errorf("save uploaded file: %w", err)
 	}
+	if !paste {
+		log.Printf("file upload %s: name=%q mime=%q bytes=%d saved=%s (attachment)", agentName, fileName, mimeType, len(fileBytes), savedPath)
+		return filepath.Base(savedPath), nil
+	}
+	return filepath.Base(savedPath), p.pasteUpload(agent, fileName, mimeType, savedPath, int64(len(fileBytes)), fileBytes)
+}
 
+// pasteUpload pastes a saved upload into the agent's pane. content holds the
+// file's bytes, or nil when the file is too large to paste inline.
+func (p *Prompter) pasteUpload(agent agents.Agent, fileName, mimeType, savedPath string, size int64, content []byte) error {
 	pasteBaseDir := agent.WorkDir
-	if paneInfo, err := p.Ctrl.GetPaneInfo(agentName); err == nil && strings.TrimSpace(paneInfo.WorkDir) != "" {
+	if paneInfo, err := p.Ctrl.GetPaneInfo(agent.Name); err == nil && strings.TrimSpace(paneInfo.WorkDir) != "" {
 		pasteBaseDir = paneInfo.WorkDir
 	}
 	pastePath := BuildServerPastePath(pasteBaseDir, savedPath)
-	pastePayload := BuildPastePayload(savedPath, pastePath, mimeType, fileBytes)
+	pastePayload := pathPastePayload(savedPath, pastePath, mimeType)
+	if content != nil {
+		pastePayload = BuildPastePayload(savedPath, pastePath, mimeType, content)
+	}
 
 	if err := CopyToLocalClipboard(pastePayload); err != nil {
-		log.Printf("clipboard copy %s: %v", agentName, err)
+		log.Printf("clipboard copy %s: %v", agent.Name, err)
 	}
-	if err := p.Ctrl.PasteBytes(agentName, pastePayload); err != nil {
+	if err := p.Ctrl.PasteBytes(agent.Name, pastePayload); err != nil {
 		return fmt.Errorf("paste into tmux: %w", err)
 	}
 
-	log.Printf("file upload %s: name=%q mime=%q bytes=%d saved=%s pastePath=%s pastedBytes=%d", agentName, fileName, mimeType, len(fileBytes), savedPath, pastePath, len(pastePayload))
+	log.Printf("file upload %s: name=%q mime=%q bytes=%d saved=%s pastePath=%s pastedBytes=%d", agent.Name, fileName, mimeType, size, savedPath, pastePath, len(pastePayload))
 	return nil
 }
 
@@ -87,6 +108,11 @@ func BuildPastePayload(savedPath, pastePath, mimeType string, fileBytes []byte)
 	if len(fileBytes) <= maxInlinePasteBytes && IsTextLike(mimeType, fileBytes) {
 		return fileBytes
 	}
+	return pathPastePayload(savedPath, pastePath, mimeType)
+}
+
+// pathPastePayload pastes a reference to the saved file rather than its contents.
+func pathPastePayload(savedPath, pastePath, mimeType string) []byte {
 	// Images need the absolute path so Claude Code can read and render them inline.
 	if strings.HasPrefix(mimeType, "image/") {
 		return []byte(savedPath + " ")
@@ -158,24 +184,56 @@ func IsUTF8Text(data []byte) bool {
 
 // SaveUploadedFile saves a file to disk in the agent's upload directory.
 func SaveUploadedFile(workDir, agentName, fileName string, data []byte) (string, error) {
-	safeName := SanitizePathComponent(fileName)
-	stampedName := fmt.Sprintf("%d-%s", time.Now().UnixNano(), safeName)
+	return saveUpload(workDir, agentName, fileName, func(path string) error {
+		return os.WriteFile(path, data, 0o644)
+	})
+}
 
-	candidates := make([]string, 0, 2)
-	if strings.TrimSpace(workDir) != "" {
-		candidates = append(candidates, filepath.Join(workDir, ".tmux-adapter", "uploads"))
+// MoveUploadedFile moves a staged file into the agent's upload directory,
+// copying when the staging area is on another filesystem.
+func MoveUploadedFile(workDir, agentName, fileName, stagedPath string) (string, error) {
+	return saveUpload(workDir, agentName, fileName, func(path string) error {
+		if err := os.Rename(stagedPath, path); err != nil {
+			if err := copyFile(stagedPath, path); err != nil {
+				return err
+			}
+		}
+		return os.Chmod(path, 0o644)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return err
 	}
-	candidates = append(candidates, filepath.Join(os.TempDir(), "tmux-adapter", "uploads", SanitizePathComponent(agentName)))
+	return out.Close()
+}
+
+// saveUpload writes a stamped upload into the first usable upload directory.
+func saveUpload(workDir, agentName, fileName string, write func(path string) error) (string, error) {
+	safeName := SanitizePathComponent(fileName)
+	stampedName := fmt.Sprintf("%d-%s", time.Now().UnixNano(), safeName)
 
 	var lastErr error
-	for _, dir := range candidates {
+	for _, dir := range uploadDirs(workDir, agentName) {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			lastErr = err
 			continue
 		}
 
 		path := filepath.Join(dir, stampedName)
-		if err := os.WriteFile(path, data, 0o644); err != nil {
+		if err := write(path); err != nil {
 			lastErr = err
 			continue
 		}
@@ -188,6 +246,15 @@ func SaveUploadedFile(workDir, agentName, fileName string, data []byte) (string,
 	return "", lastErr
 }
 
+// uploadDirs lists where an agent's uploads are saved, in order of preference.
+func uploadDirs(workDir, agentName string) []string {
+	dirs := make([]string, 0, 2)
+	if strings.TrimSpace(workDir) != "" {
+		dirs = append(dirs, filepath.Join(workDir, ".tmux-adapter", "uploads"))
+	}
+	return append(dirs, filepath.Join(os.TempDir(), "tmux-adapter", "uploads", SanitizePathComponent(agentName)))
+}
+
 // SanitizePathComponent makes a filename safe for use in paths.
 func SanitizePathComponent(s string) string {
 	base := filepath.Base(strings.TrimSpace(s))
@@ -0,0 +1,203 @@
+package agentio
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPromptHistory is how many prompts PromptHistory keeps per agent
+// unless told otherwise.
+const DefaultPromptHistory = 100
+
+// PromptRecord is one prompt typed into an agent, as PromptHistory keeps it.
+type PromptRecord struct {
+	Seq       int64     `json:"seq"` // increases with every prompt to any agent, across restarts when persisted
+	Agent     string    `json:"agent"`
+	Text      string    `json:"text"` // as typed: after the prompt policy and attachment expansion
+	At        time.Time `json:"at"`
+	PasteOnly bool      `json:"pasteOnly,omitempty"`
+	Held      bool      `json:"held,omitempty"`
+	Error     string    `json:"error,omitempty"` // typing or submitting failed
+	Back      int       `json:"back,omitempty"`  // in answers: 1 for the agent's latest prompt, 2 the one before, as up-arrow recalls them
+}
+
+// PromptHistory keeps the last prompts typed into each agent, so clients can
+// offer to resend one and operators can see what automation asked. With a
+// file set, every prompt is also appended to it as a JSON line, and the file
+// is read back (and trimmed) on load. A nil PromptHistory records nothing.
+type PromptHistory struct {
+	size int
+	path string
+
+	mu      sync.Mutex
+	prompts map[string][]PromptRecord // agent → oldest first, at most size
+	seq     int64
+}
+
+// LoadPromptHistory keeps size prompts per agent, persisted to path when it is
+// not empty. size 0 returns nil, recording nothing.
+func LoadPromptHistory(size int, path string) (*PromptHistory, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("prompt history size must not be negative: %d", size)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	h := &PromptHistory{size: size, path: path, prompts: make(map[string][]PromptRecord)}
+	if path == "" {
+		return h, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read prompt history: %w", err)
+	}
+	for line := range bytes.Lines(data) {
+		var rec PromptRecord
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Agent == "" {
+			continue // a line cut short by a crash
+		}
+		h.add(rec)
+		h.seq = max(h.seq, rec.Seq)
+	}
+	if err := h.rewrite(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Record adds a prompt typed into agent, returning it with its sequence
+// number.
+func (h *PromptHistory) Record(rec PromptRecord) PromptRecord {
+	if h == nil {
+		return rec
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	rec.Seq = h.seq
+	if rec.At.IsZero() {
+		rec.At = time.Now()
+	}
+	h.add(rec)
+	if h.path != "" {
+		if err := h.append(rec); err != nil {
+			log.Printf("prompt history: %v", err)
+		}
+	}
+	return rec
+}
+
+// add appends rec to its agent's prompts, dropping the oldest past size.
+// The caller must hold h.mu, or own h.
+func (h *PromptHistory) add(rec PromptRecord) {
+	list := append(h.prompts[rec.Agent], rec)
+	if len(list) > h.size {
+		list = append(list[:0:0], list[len(list)-h.size:]...)
+	}
+	h.prompts[rec.Agent] = list
+}
+
+// Recent returns up to limit of agent's prompts, newest first, starting
+// below sequence number before when it is positive. Each carries Back, its
+// distance from the agent's latest prompt. more reports whether older
+// prompts remain. limit 0 returns them all.
+func (h *PromptHistory) Recent(agent string, limit int, before int64) (recs []PromptRecord, more bool) {
+	if h == nil {
+		return nil, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := h.prompts[agent]
+	for i := len(list) - 1; i >= 0; i-- {
+		if before > 0 && list[i].Seq >= before {
+			continue
+		}
+		if limit > 0 && len(recs) == limit {
+			return recs, true
+		}
+		rec := list[i]
+		rec.Back = len(list) - i
+		recs = append(recs, rec)
+	}
+	return recs, false
+}
+
+// Page is Recent for get-prompt-history: cursor is empty for the latest
+// prompts or the nextCursor of the page before, and next is empty on the
+// oldest page.
+func (h *PromptHistory) Page(agent string, limit int, cursor string) (recs []PromptRecord, next string, err error) {
+	var before int64
+	if cursor != "" {
+		if before, err = strconv.ParseInt(cursor, 10, 64); err != nil || before <= 0 {
+			return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+		}
+	}
+	recs, more := h.Recent(agent, limit, before)
+	if more {
+		next = strconv.FormatInt(recs[len(recs)-1].Seq, 10)
+	}
+	return recs, next, nil
+}
+
+// Last returns agent's latest prompt, for "resend last instruction".
+func (h *PromptHistory) Last(agent string) (PromptRecord, bool) {
+	recs, _ := h.Recent(agent, 1, 0)
+	if len(recs) == 0 {
+		return PromptRecord{}, false
+	}
+	return recs[0], true
+}
+
+// append writes rec to the history file. The caller must hold h.mu.
+func (h *PromptHistory) append(rec PromptRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// rewrite replaces the history file with the prompts kept in memory, so the
+// file doesn't grow past size prompts per agent from one run to the next.
+func (h *PromptHistory) rewrite() error {
+	var all []PromptRecord
+	for _, list := range h.prompts {
+		all = append(all, list...)
+	}
+	slices.SortFunc(all, func(a, b PromptRecord) int { return cmp.Compare(a.Seq, b.Seq) })
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range all {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("write prompt history: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return fmt.Errorf("write prompt history: %w", err)
+	}
+	return nil
+}
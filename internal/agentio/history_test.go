@@ -0,0 +1,86 @@
+package agentio
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromptHistoryPagesNewestFirst(t *testing.T) {
+	h, err := LoadPromptHistory(3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"one", "two", "three", "four"} {
+		h.Record(PromptRecord{Agent: "hq-mayor", Text: text})
+	}
+	h.Record(PromptRecord{Agent: "crew-joe", Text: "other"})
+
+	recs, next, err := h.Page("hq-mayor", 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Text != "four" || recs[0].Back != 1 || recs[1].Text != "three" || recs[1].Back != 2 {
+		t.Fatalf("first page = %+v", recs)
+	}
+	if next == "" {
+		t.Fatal("first page has no nextCursor")
+	}
+	recs, next, err = h.Page("hq-mayor", 2, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].Text != "two" || recs[0].Back != 3 || next != "" {
+		t.Fatalf("second page = %+v, next %q; want only the oldest kept prompt", recs, next)
+	}
+
+	if last, ok := h.Last("crew-joe"); !ok || last.Text != "other" {
+		t.Errorf("Last(crew-joe) = %+v, %v", last, ok)
+	}
+	if _, _, err := h.Page("hq-mayor", 0, "nope"); err == nil {
+		t.Error("Page accepted an invalid cursor")
+	}
+
+	var none *PromptHistory
+	none.Record(PromptRecord{Agent: "hq-mayor", Text: "lost"})
+	if recs, next, err := none.Page("hq-mayor", 0, ""); len(recs) != 0 || next != "" || err != nil {
+		t.Errorf("nil PromptHistory Page = %v, %q, %v", recs, next, err)
+	}
+}
+
+func TestPromptHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompts.jsonl")
+	h, err := LoadPromptHistory(2, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"one", "two", "three"} {
+		h.Record(PromptRecord{Agent: "hq-mayor", Text: text})
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"seq":4,"agent":"hq-ma`) // cut short by a crash
+	_ = f.Close()
+
+	h, err = LoadPromptHistory(2, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, _ := h.Recent("hq-mayor", 0, 0)
+	if len(recs) != 2 || recs[0].Text != "three" || recs[1].Text != "two" {
+		t.Fatalf("reloaded = %+v, want three and two", recs)
+	}
+	if rec := h.Record(PromptRecord{Agent: "hq-mayor", Text: "four"}); rec.Seq != 4 {
+		t.Errorf("Seq after reload = %d, want 4", rec.Seq)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Errorf("file has %d lines after reload and one prompt, want 3:\n%s", lines, data)
+	}
+}
@@ -0,0 +1,149 @@
+package agentio
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+var (
+	// ErrHeldPromptPending refuses a prompt to an agent whose last held
+	// prompt has not been confirmed yet; typing would append to it.
+	ErrHeldPromptPending = errors.New("a held prompt is waiting for confirm-prompt")
+	// ErrNoHeldPrompt answers a confirm-prompt for an agent with nothing held.
+	ErrNoHeldPrompt = errors.New("no held prompt to confirm")
+)
+
+// PromptHolds tracks which agents have their prompts held for approval: a
+// held prompt is typed into the agent's input area but only submitted by
+// ConfirmPrompt, or once the hold timeout passes, so a supervisor can read
+// what automation is about to ask. A nil PromptHolds holds nothing.
+type PromptHolds struct {
+	filter  *wsbase.NameFilter // agents held unless set otherwise; nil holds none
+	timeout time.Duration      // held prompts submit themselves after this; 0 waits for ConfirmPrompt
+
+	mu      sync.Mutex
+	set     map[string]bool // per-agent settings from SetHeld
+	pending map[string]*heldPrompt
+}
+
+// heldPrompt is a prompt typed into an agent's input area awaiting
+// ConfirmPrompt.
+type heldPrompt struct {
+	text  string
+	timer *time.Timer // nil without a timeout
+}
+
+// NewPromptHolds holds prompts to the agents filter matches; nil holds none
+// until SetHeld. A positive timeout submits held prompts that nobody
+// confirmed after that long.
+func NewPromptHolds(filter *wsbase.NameFilter, timeout time.Duration) *PromptHolds {
+	return &PromptHolds{
+		filter:  filter,
+		timeout: timeout,
+		set:     make(map[string]bool),
+		pending: make(map[string]*heldPrompt),
+	}
+}
+
+// Held reports whether prompts to agent are held.
+func (h *PromptHolds) Held(agent string) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if held, ok := h.set[agent]; ok {
+		return held
+	}
+	return h.filter != nil && h.filter.Matches(agent)
+}
+
+// SetHeld turns holding on or off for agent, overriding the filter. A prompt
+// already held stays held until confirmed.
+func (h *PromptHolds) SetHeld(agent string, held bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.set[agent] = held
+}
+
+// Pending reports whether agent has a held prompt awaiting confirmation.
+func (h *PromptHolds) Pending(agent string) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.pending[agent] != nil
+}
+
+// take removes agent's held prompt and stops its timer. If want is not nil
+// only that prompt is taken.
+func (h *PromptHolds) take(agent string, want *heldPrompt) *heldPrompt {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hp := h.pending[agent]
+	if hp == nil || want != nil && hp != want {
+		return nil
+	}
+	delete(h.pending, agent)
+	if hp.timer != nil {
+		hp.timer.Stop()
+	}
+	return hp
+}
+
+// hold records text as agentName's held prompt, starting the hold timeout.
+func (p *Prompter) hold(agentName, text string) {
+	h := p.Holds
+	hp := &heldPrompt{text: text}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.timeout > 0 {
+		hp.timer = time.AfterFunc(h.timeout, func() {
+			lock := p.GetLock(agentName)
+			lock.Lock()
+			defer lock.Unlock()
+			if h.take(agentName, hp) == nil {
+				return // confirmed meanwhile
+			}
+			if _, err := p.confirm(agentName, hp); err != nil {
+				log.Printf("confirm-prompt(%s): after hold timeout: %v", agentName, err)
+				return
+			}
+			log.Printf("confirm-prompt(%s): submitted after %s hold timeout", agentName, h.timeout)
+		})
+	}
+	h.pending[agentName] = hp
+}
+
+// ConfirmPrompt submits the prompt held in agentName's input area, first
+// checking it is still there: if a human already submitted or edited it,
+// nothing is pressed and ErrPromptNotVisible is returned.
+// The caller must hold the per-agent lock.
+func (p *Prompter) ConfirmPrompt(agentName string) (PromptDelivery, error) {
+	return p.confirm(agentName, p.Holds.take(agentName, nil))
+}
+
+func (p *Prompter) confirm(agentName string, hp *heldPrompt) (PromptDelivery, error) {
+	if hp == nil {
+		return PromptDelivery{}, ErrNoHeldPrompt
+	}
+	agent, err := p.lookup(agentName)
+	if err != nil {
+		return PromptDelivery{}, err
+	}
+	d := PromptDelivery{Text: hp.text, Started: time.Now()}
+	if d.Verification, err = p.verifyInput(agent.Name, hp.text); err != nil {
+		return d, fmt.Errorf("held prompt: %w", err)
+	}
+	err = p.press(agent, p.commandStrategy(agent.Runtime), &d)
+	return d, err
+}
@@ -0,0 +1,58 @@
+package agentio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+func TestPromptHoldsFilterAndOverrides(t *testing.T) {
+	filter, err := wsbase.ParseNameFilter([]string{"crew-*", "!crew-max"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewPromptHolds(filter, 0)
+
+	for name, want := range map[string]bool{"crew-joe": true, "crew-max": false, "hq-mayor": false} {
+		if got := h.Held(name); got != want {
+			t.Errorf("Held(%q) = %v, want %v", name, got, want)
+		}
+	}
+	h.SetHeld("hq-mayor", true)
+	h.SetHeld("crew-joe", false)
+	if !h.Held("hq-mayor") || h.Held("crew-joe") {
+		t.Errorf("SetHeld did not override the filter: hq-mayor %v, crew-joe %v", h.Held("hq-mayor"), h.Held("crew-joe"))
+	}
+
+	if NewPromptHolds(nil, 0).Held("hq-mayor") {
+		t.Error("a nil filter held a prompt")
+	}
+	var none *PromptHolds
+	if none.Held("hq-mayor") || none.Pending("hq-mayor") {
+		t.Error("a nil PromptHolds held a prompt")
+	}
+}
+
+func TestPromptHoldsTake(t *testing.T) {
+	h := NewPromptHolds(nil, 0)
+	hp := &heldPrompt{text: "please review"}
+	h.pending["hq-mayor"] = hp
+	if !h.Pending("hq-mayor") {
+		t.Fatal("Pending = false with a held prompt")
+	}
+	if got := h.take("hq-mayor", &heldPrompt{}); got != nil {
+		t.Errorf("take with another prompt = %v, want nil", got)
+	}
+	if got := h.take("hq-mayor", nil); got != hp {
+		t.Errorf("take = %v, want the held prompt", got)
+	}
+	if h.Pending("hq-mayor") {
+		t.Error("Pending = true after take")
+	}
+
+	p := &Prompter{Holds: h}
+	if _, err := p.ConfirmPrompt("hq-mayor"); !errors.Is(err, ErrNoHeldPrompt) {
+		t.Errorf("ConfirmPrompt with nothing held: err = %v, want ErrNoHeldPrompt", err)
+	}
+}
@@ -0,0 +1,150 @@
+package agentio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+// promptHookTimeout bounds how long an external prompt hook may run.
+const promptHookTimeout = 5 * time.Second
+
+// Rejection is returned when a prompt or upload policy refuses input. Rule
+// names the check that failed, e.g. "max-length", "hook", or a deny pattern's
+// name. Reason never repeats the matched text, so secrets are not echoed back.
+type Rejection struct {
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+func (e *Rejection) Error() string {
+	return "rejected by " + e.Rule + ": " + e.Reason
+}
+
+// PromptPolicy screens prompts before they are typed into an agent's pane.
+// Checks run in order: MaxLength, Deny, RequiredPrefix, then Hook; a prompt
+// the hook rewrites goes through MaxLength and Deny again.
+type PromptPolicy struct {
+	// MaxLength rejects prompts longer than this many bytes; 0 disables.
+	MaxLength int
+	// Deny rejects prompts matching any rule.
+	Deny []conv.RedactionRule
+	// RequiredPrefix is prepended to prompts that don't already start with it.
+	RequiredPrefix string
+	// Hook is a shell command run with the prompt on stdin and the agent name
+	// in $TMUX_ADAPTER_AGENT. A non-zero exit rejects the prompt, with stderr
+	// as the reason; non-empty stdout replaces the prompt.
+	Hook string
+}
+
+// LoadPromptPolicy builds a policy from command-line settings. blockSecrets
+// adds the credential rules from conv.DefaultRedactionRules. It returns nil
+// when nothing is set.
+func LoadPromptPolicy(maxLength int, denyPatterns []string, blockSecrets bool, requiredPrefix, hook string) (*PromptPolicy, error) {
+	if maxLength <= 0 && len(denyPatterns) == 0 && !blockSecrets && requiredPrefix == "" && hook == "" {
+		return nil, nil
+	}
+	p := &PromptPolicy{MaxLength: max(maxLength, 0), RequiredPrefix: requiredPrefix, Hook: hook}
+	if blockSecrets {
+		for _, r := range conv.DefaultRedactionRules {
+			// Email addresses are routine in prompts; only credentials are blocked.
+			if r.Name != "email" {
+				p.Deny = append(p.Deny, r)
+			}
+		}
+	}
+	custom, err := conv.ParseRedactionPatterns(denyPatterns)
+	if err != nil {
+		return nil, err
+	}
+	p.Deny = append(p.Deny, custom...)
+	return p, nil
+}
+
+// Check returns the prompt to send, possibly rewritten, or a *Rejection.
+// A nil policy allows every prompt unchanged.
+func (p *PromptPolicy) Check(agentName, prompt string) (string, error) {
+	return p.check(agentName, prompt, true)
+}
+
+// CheckArgument screens a slash command's argument, such as the summary
+// instructions of /compact, with every check but RequiredPrefix, which would
+// break the command it follows.
+func (p *PromptPolicy) CheckArgument(agentName, arg string) (string, error) {
+	return p.check(agentName, arg, false)
+}
+
+func (p *PromptPolicy) check(agentName, prompt string, prefix bool) (string, error) {
+	if p == nil {
+		return prompt, nil
+	}
+	if err := p.screen(prompt); err != nil {
+		return "", err
+	}
+	if prefix && !strings.HasPrefix(prompt, p.RequiredPrefix) {
+		prompt = p.RequiredPrefix + prompt
+	}
+	if p.Hook == "" {
+		return prompt, nil
+	}
+	rewritten, err := p.runHook(agentName, prompt)
+	if err != nil || rewritten == prompt {
+		return rewritten, err
+	}
+	// A hook's rewrite is held to the same limits as the prompt it replaced.
+	if err := p.screen(rewritten); err != nil {
+		err.Reason = "after prompt hook: " + err.Reason
+		return "", err
+	}
+	return rewritten, nil
+}
+
+// screen applies MaxLength and Deny to prompt.
+func (p *PromptPolicy) screen(prompt string) *Rejection {
+	if p.MaxLength > 0 && len(prompt) > p.MaxLength {
+		return &Rejection{Rule: "max-length", Reason: fmt.Sprintf("prompt is %d bytes, limit is %d", len(prompt), p.MaxLength)}
+	}
+	for _, r := range p.Deny {
+		if r.Pattern.MatchString(prompt) {
+			return &Rejection{Rule: r.Name, Reason: "prompt matches a denied pattern"}
+		}
+	}
+	return nil
+}
+
+func (p *PromptPolicy) runHook(agentName, prompt string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), promptHookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.Hook)
+	cmd.Stdin = strings.NewReader(prompt)
+	cmd.Env = append(os.Environ(), "TMUX_ADAPTER_AGENT="+agentName)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		reason := strings.TrimSpace(stderr.String())
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || ctx.Err() != nil {
+			// Fail closed: a broken or hung hook must not let prompts through.
+			log.Printf("prompt hook for %s: %v", agentName, err)
+			reason = "prompt hook failed"
+		} else if reason == "" {
+			reason = "rejected by prompt hook"
+		}
+		return "", &Rejection{Rule: "hook", Reason: reason}
+	}
+	if out := strings.TrimSuffix(stdout.String(), "\n"); out != "" {
+		return out, nil
+	}
+	return prompt, nil
+}
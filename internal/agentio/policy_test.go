@@ -0,0 +1,99 @@
+package agentio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPromptPolicyRules(t *testing.T) {
+	p, err := LoadPromptPolicy(64, []string{`ACME-[0-9]{6}`}, true, "[remote] ", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		prompt string
+		rule   string // "" means allowed
+		want   string
+	}{
+		{prompt: "fix the tests", want: "[remote] fix the tests"},
+		{prompt: "[remote] already tagged", want: "[remote] already tagged"},
+		{prompt: "email me at dev@example.com", want: "[remote] email me at dev@example.com"},
+		{prompt: "use key sk-ant-REDACTED", rule: "anthropic-key"},
+		{prompt: "ticket ACME-123456", rule: "custom-1"},
+		{prompt: string(make([]byte, 65)), rule: "max-length"},
+	}
+	for _, tt := range tests {
+		got, err := p.Check("hq-mayor", tt.prompt)
+		var rejection *Rejection
+		switch {
+		case tt.rule == "" && err != nil:
+			t.Errorf("Check(%q) error = %v, want allowed", tt.prompt, err)
+		case tt.rule == "" && got != tt.want:
+			t.Errorf("Check(%q) = %q, want %q", tt.prompt, got, tt.want)
+		case tt.rule != "" && !errors.As(err, &rejection):
+			t.Errorf("Check(%q) error = %v, want *Rejection", tt.prompt, err)
+		case tt.rule != "" && rejection.Rule != tt.rule:
+			t.Errorf("Check(%q) rule = %q, want %q", tt.prompt, rejection.Rule, tt.rule)
+		}
+	}
+}
+
+func TestPromptPolicyCheckArgument(t *testing.T) {
+	p, err := LoadPromptPolicy(16, []string{`ACME-[0-9]{6}`}, false, "[remote] ", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.CheckArgument("hq-mayor", "keep the plan"); err != nil || got != "keep the plan" {
+		t.Errorf("CheckArgument = (%q, %v), want it unchanged, without the prefix", got, err)
+	}
+	for _, arg := range []string{"ACME-123456", "a summary that runs long"} {
+		var rejection *Rejection
+		if _, err := p.CheckArgument("hq-mayor", arg); !errors.As(err, &rejection) {
+			t.Errorf("CheckArgument(%q) error = %v, want *Rejection", arg, err)
+		}
+	}
+}
+
+func TestPromptPolicyHook(t *testing.T) {
+	p := &PromptPolicy{Hook: `case "$(cat)" in *forbidden*) echo "no forbidden words for $TMUX_ADAPTER_AGENT" >&2; exit 1;; *) echo rewritten;; esac`}
+
+	got, err := p.Check("hq-mayor", "hello")
+	if err != nil || got != "rewritten" {
+		t.Fatalf("Check(hello) = (%q, %v), want (rewritten, nil)", got, err)
+	}
+
+	_, err = p.Check("hq-mayor", "something forbidden")
+	var rejection *Rejection
+	if !errors.As(err, &rejection) || rejection.Rule != "hook" || rejection.Reason != "no forbidden words for hq-mayor" {
+		t.Fatalf("Check(forbidden) error = %v, want hook rejection with stderr reason", err)
+	}
+}
+
+func TestPromptPolicyHookOutputRechecked(t *testing.T) {
+	p, err := LoadPromptPolicy(16, []string{`ACME-[0-9]{6}`}, false, "", `case "$(cat)" in long) echo "this rewrite is far too long";; ticket) echo "ACME-123456";; *) echo ok;; esac`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := p.Check("hq-mayor", "short"); err != nil || got != "ok" {
+		t.Fatalf("Check(short) = (%q, %v), want (ok, nil)", got, err)
+	}
+	for prompt, rule := range map[string]string{"long": "max-length", "ticket": "custom-1"} {
+		_, err := p.Check("hq-mayor", prompt)
+		var rejection *Rejection
+		if !errors.As(err, &rejection) || rejection.Rule != rule {
+			t.Errorf("Check(%q) error = %v, want %s rejection of the hook's rewrite", prompt, err, rule)
+		}
+	}
+}
+
+func TestLoadPromptPolicyUnset(t *testing.T) {
+	p, err := LoadPromptPolicy(0, nil, false, "", "")
+	if err != nil || p != nil {
+		t.Fatalf("LoadPromptPolicy() = (%v, %v), want (nil, nil)", p, err)
+	}
+	if got, err := p.Check("hq-mayor", "anything"); err != nil || got != "anything" {
+		t.Fatalf("nil policy Check = (%q, %v), want unchanged", got, err)
+	}
+}
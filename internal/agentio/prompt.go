@@ -15,16 +15,32 @@ import (
 type Prompter struct {
 	Ctrl     *tmux.ControlMode
 	Registry *agents.Registry
+	Policy   *PromptPolicy // nil allows every prompt
+	Uploads  *UploadPolicy // nil allows every upload
+	Submit   SubmitStrategies
+	Holds    *PromptHolds   // nil holds no prompts
+	History  *PromptHistory // nil records no prompts
 	locks    map[string]*sync.Mutex
 	locksMu  sync.Mutex
+	chunks   map[string]*chunkedUpload // agent + \0 + upload ID
+	chunksMu sync.Mutex
 }
 
-// NewPrompter creates a new Prompter.
-func NewPrompter(ctrl *tmux.ControlMode, registry *agents.Registry) *Prompter {
+// NewPrompter creates a new Prompter that screens prompts with policy and
+// file uploads with uploads, and submits them as submit says for each runtime,
+// holding prompts to the agents holds says for approval and recording them in
+// history.
+func NewPrompter(ctrl *tmux.ControlMode, registry *agents.Registry, policy *PromptPolicy, uploads *UploadPolicy, submit SubmitStrategies, holds *PromptHolds, history *PromptHistory) *Prompter {
 	return &Prompter{
 		Ctrl:     ctrl,
 		Registry: registry,
+		Policy:   policy,
+		Uploads:  uploads,
+		Submit:   submit,
+		Holds:    holds,
+		History:  history,
 		locks:    make(map[string]*sync.Mutex),
+		chunks:   make(map[string]*chunkedUpload),
 	}
 }
 
@@ -38,57 +54,172 @@ func (p *Prompter) GetLock(agent string) *sync.Mutex {
 	return p.locks[agent]
 }
 
+// lookup returns the named agent, or why input can't be sent to it.
+func (p *Prompter) lookup(agentName string) (agents.Agent, error) {
+	agent, ok := p.Registry.GetAgent(agentName)
+	if ok {
+		return agent, nil
+	}
+	if p.Registry.Exited(agentName) {
+		return agents.Agent{}, fmt.Errorf("%s: %w", agentName, agents.ErrAgentExited)
+	}
+	return agents.Agent{}, fmt.Errorf("agent not found: %s", agentName)
+}
+
+// PromptDelivery describes how DeliverPrompt got a prompt to an agent.
+type PromptDelivery struct {
+	Text          string        // what was typed: the prompt as Policy left it
+	Started       time.Time     // when typing began
+	Submitted     time.Time     // when the submit keys were accepted; zero if PasteOnly
+	PasteOnly     bool          // Text was typed but left for a human to submit
+	Held          bool          // Text was typed and awaits ConfirmPrompt
+	Typing        time.Duration // the send-keys that typed Text
+	Verification  string        // whether Text was seen in the input area: VerifyFound, VerifyPasted, VerifySkipped, or VerifyFailed
+	Total         time.Duration // typing through the wake resize, settle delays included
+	EnterAttempts int
+}
+
 // SendPrompt sends a prompt to an agent using the nudge sequence:
-// SendKeysLiteral → 500ms → Escape → 100ms → Enter (3x retry, 200ms) → SIGWINCH wake.
+// SendKeysLiteral → 500ms → verify → Escape → 100ms → Enter (3x retry, 200ms) → SIGWINCH wake.
+// The keys, the settle delay, and whether Escape is sent come from the
+// runtime's SubmitStrategy; in paste-only mode the sequence stops after
+// verification.
+// Verification captures the pane and checks the text is in the agent's input
+// area; if it isn't, Enter is not pressed and ErrPromptNotVisible is returned.
+// The prompt is first screened by Policy; refusals return a *Rejection.
 // The caller must hold the per-agent lock.
 func (p *Prompter) SendPrompt(agentName, prompt string) error {
-	agent, ok := p.Registry.GetAgent(agentName)
-	if !ok {
-		return fmt.Errorf("agent not found: %s", agentName)
+	_, err := p.DeliverPrompt(agentName, prompt, false, nil)
+	return err
+}
+
+// DeliverPrompt is SendPrompt reporting how the prompt was delivered.
+// pasteOnly leaves the typed prompt unsubmitted whatever the runtime's
+// strategy. Prompts to agents Holds holds are typed and left for
+// ConfirmPrompt; while one waits, further prompts fail with
+// ErrHeldPromptPending. typing, if not nil, is called with the text just
+// before it is typed, for callers that watch for the prompt to show up in the
+// agent's transcript. Prompts that get as far as typing are recorded in
+// History, failed ones with their error.
+func (p *Prompter) DeliverPrompt(agentName, prompt string, pasteOnly bool, typing func(text string)) (PromptDelivery, error) {
+	agent, err := p.lookup(agentName)
+	if err != nil {
+		return PromptDelivery{}, err
 	}
+	if p.Holds.Pending(agentName) {
+		return PromptDelivery{}, ErrHeldPromptPending
+	}
+	held := p.Holds.Held(agentName)
+
+	prompt, err = p.Policy.Check(agentName, prompt)
+	if err != nil {
+		log.Printf("send-prompt(%s): %v", agentName, err)
+		return PromptDelivery{}, err
+	}
+	if typing != nil {
+		typing(prompt)
+	}
+	strategy := p.Submit.For(agent.Runtime)
+	if pasteOnly || held {
+		strategy.Mode = SubmitPasteOnly
+	}
+	d := PromptDelivery{Text: prompt}
+	err = p.submit(agent, prompt, strategy, &d)
+	if err == nil && held {
+		d.Held = true
+		p.hold(agentName, prompt)
+	}
+	rec := PromptRecord{Agent: agentName, Text: prompt, At: d.Started, PasteOnly: d.PasteOnly, Held: d.Held}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	p.History.Record(rec)
+	return d, err
+}
 
+// submit types text into the agent's pane and submits it using the nudge
+// sequence described on SendPrompt, recording its timing in d if not nil.
+func (p *Prompter) submit(agent agents.Agent, text string, strategy SubmitStrategy, d *PromptDelivery) error {
 	session := agent.Name
+	if d == nil {
+		d = &PromptDelivery{}
+	}
+	d.Started = time.Now()
 
 	// 1. Send text in literal mode
-	if err := p.Ctrl.SendKeysLiteral(session, prompt); err != nil {
+	if err := p.Ctrl.SendKeysLiteral(session, text); err != nil {
 		return fmt.Errorf("send literal: %w", err)
 	}
+	d.Typing = time.Since(d.Started)
 
-	// 2. Wait 500ms for paste to complete
-	time.Sleep(500 * time.Millisecond)
+	// 2. Wait for paste to complete
+	time.Sleep(strategy.Settle)
+
+	// Check the text went to the input box, not a dialog
+	var err error
+	if d.Verification, err = p.verifyInput(session, text); err != nil {
+		return err
+	}
+	if strategy.Mode == SubmitPasteOnly {
+		d.PasteOnly = true
+		d.Total = time.Since(d.Started)
+		return nil
+	}
+	return p.press(agent, strategy, d)
+}
+
+// press submits the text in the agent's input area: steps 3 to 5 of the
+// nudge sequence.
+func (p *Prompter) press(agent agents.Agent, strategy SubmitStrategy, d *PromptDelivery) error {
+	session := agent.Name
 
 	// 3. Send Escape (for vim mode)
-	if err := p.Ctrl.SendKeysRaw(session, "Escape"); err != nil {
-		return fmt.Errorf("send Escape: %w", err)
+	if strategy.Escape {
+		if err := p.Ctrl.SendKeysRaw(session, "Escape"); err != nil {
+			return fmt.Errorf("send Escape: %w", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// 4. Send the submit keys, each with 3x retry, 200ms backoff
+	for _, key := range strategy.Keys {
+		if err := p.pressKey(session, key, d); err != nil {
+			return err
+		}
 	}
-	time.Sleep(100 * time.Millisecond)
+	d.Submitted = time.Now()
 
-	// 4. Send Enter with 3x retry, 200ms backoff
+	// 5. Wake detached sessions via SIGWINCH resize dance
+	if !agent.Attached {
+		if err := p.Ctrl.ResizePane(session, "-1"); err != nil {
+			log.Printf("send-prompt(%s): wake shrink resize failed: %v", session, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if err := p.Ctrl.ResizePane(session, "+1"); err != nil {
+			log.Printf("send-prompt(%s): wake restore resize failed: %v", session, err)
+		}
+	}
+
+	d.Total = time.Since(d.Started)
+	return nil
+}
+
+// pressKey sends key to session, retrying up to 3 times 200ms apart.
+func (p *Prompter) pressKey(session, key string, d *PromptDelivery) error {
 	var lastErr error
 	for attempt := range 3 {
 		if attempt > 0 {
 			time.Sleep(200 * time.Millisecond)
 		}
-		if err := p.Ctrl.SendKeysRaw(session, "Enter"); err != nil {
+		d.EnterAttempts++
+		if err := p.Ctrl.SendKeysRaw(session, key); err != nil {
 			lastErr = err
 			continue
 		}
-
-		// 5. Wake detached sessions via SIGWINCH resize dance
-		if !agent.Attached {
-			if err := p.Ctrl.ResizePane(session, "-1"); err != nil {
-				log.Printf("send-prompt(%s): wake shrink resize failed: %v", session, err)
-			}
-			time.Sleep(50 * time.Millisecond)
-			if err := p.Ctrl.ResizePane(session, "+1"); err != nil {
-				log.Printf("send-prompt(%s): wake restore resize failed: %v", session, err)
-			}
-		}
-
 		return nil
 	}
 
-	errMsg := "failed to send Enter after 3 attempts"
+	errMsg := "failed to send " + key + " after 3 attempts"
 	if lastErr != nil {
 		errMsg += ": " + lastErr.Error()
 	}
@@ -0,0 +1,148 @@
+package agentio
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+// maxPromptAPIBodyBytes caps a POST /api/agents/{name}/prompt body.
+const maxPromptAPIBodyBytes = 1 << 20
+
+// PromptAPI serves POST /api/agents/{name}/prompt, letting webhooks and CI
+// jobs nudge an agent without holding a WebSocket open. It only answers when
+// the server has credentials configured, and the caller needs the prompt scope.
+type PromptAPI struct {
+	prompter *Prompter
+	control  *ControlLocks
+	auth     *wsbase.Authenticator
+}
+
+// NewPromptAPI creates the HTTP prompt endpoint. Prompts go through the
+// prompter's policy and respect control locks held by WebSocket clients.
+func NewPromptAPI(prompter *Prompter, control *ControlLocks, auth *wsbase.Authenticator) *PromptAPI {
+	return &PromptAPI{prompter: prompter, control: control, auth: auth}
+}
+
+type promptAPIRequest struct {
+	Prompt        string   `json:"prompt"`
+	Attachments   []string `json:"attachments,omitempty"`
+	CorrelationID string   `json:"correlationId,omitempty"`
+	PasteOnly     bool     `json:"pasteOnly,omitempty"`
+}
+
+type promptAPIResponse struct {
+	OK            bool       `json:"ok"`
+	Agent         string     `json:"agent,omitempty"`
+	CorrelationID string     `json:"correlationId,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	Code          string     `json:"code,omitempty"`
+	Rejection     *Rejection `json:"rejection,omitempty"`
+	Verification  string     `json:"verification,omitempty"`
+	PasteOnly     bool       `json:"pasteOnly,omitempty"`
+	Held          bool       `json:"held,omitempty"`
+}
+
+func (a *PromptAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	agentName := r.PathValue("name")
+	if !a.auth.Enabled() {
+		writePromptAPI(w, http.StatusForbidden, promptAPIResponse{Error: "prompt API requires server authentication to be configured"})
+		return
+	}
+	grant, err := a.auth.Authenticate(r)
+	if err != nil {
+		writePromptAPI(w, http.StatusUnauthorized, promptAPIResponse{Error: "unauthorized"})
+		return
+	}
+	if !grant.Prompt {
+		writePromptAPI(w, http.StatusForbidden, promptAPIResponse{Error: wsbase.ErrPromptNotAllowed.Error()})
+		return
+	}
+
+	var req promptAPIRequest
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPromptAPIBodyBytes))
+	if err == nil {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
+		writePromptAPI(w, http.StatusBadRequest, promptAPIResponse{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	if req.Prompt == "" && len(req.Attachments) == 0 {
+		writePromptAPI(w, http.StatusBadRequest, promptAPIResponse{Error: "prompt field required"})
+		return
+	}
+	if req.CorrelationID == "" {
+		req.CorrelationID = newCorrelationID()
+	}
+	resp := promptAPIResponse{Agent: agentName, CorrelationID: req.CorrelationID}
+
+	if _, err := a.prompter.lookup(agentName); err != nil {
+		status := http.StatusNotFound
+		resp.Error = "agent not found"
+		if errors.Is(err, agents.ErrAgentExited) {
+			status = http.StatusGone
+			resp.Error = agents.ErrAgentExited.Error()
+		}
+		writePromptAPI(w, status, resp)
+		return
+	}
+	// HTTP callers never hold control, so any holder blocks them.
+	if err := a.control.CheckInput(agentName, a); err != nil {
+		resp.Error = err.Error()
+		writePromptAPI(w, http.StatusConflict, resp)
+		return
+	}
+
+	prompt, err := a.prompter.ExpandAttachments(agentName, req.Prompt, req.Attachments)
+	if err != nil {
+		resp.Error = err.Error()
+		writePromptAPI(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	lock := a.prompter.GetLock(agentName)
+	lock.Lock()
+	delivery, err := a.prompter.DeliverPrompt(agentName, prompt, req.PasteOnly, nil)
+	lock.Unlock()
+	resp.Verification = delivery.Verification
+	resp.PasteOnly = delivery.PasteOnly
+	resp.Held = delivery.Held
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Code = tmux.ErrorCode(err)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.As(err, &resp.Rejection):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, ErrPromptNotVisible), errors.Is(err, ErrHeldPromptPending):
+			status = http.StatusConflict
+		}
+		writePromptAPI(w, status, resp)
+		return
+	}
+
+	log.Printf("prompt API: %s prompted (correlation %s, subject %q)", agentName, req.CorrelationID, grant.Subject)
+	resp.OK = true
+	writePromptAPI(w, http.StatusOK, resp)
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "prompt-" + hex.EncodeToString(b)
+}
+
+func writePromptAPI(w http.ResponseWriter, status int, resp promptAPIResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,38 @@
+package agentio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+func TestPromptAPIRejectsBeforeTouchingAgents(t *testing.T) {
+	tests := []struct {
+		name string
+		auth *wsbase.Authenticator
+		hdr  string
+		body string
+		want int
+	}{
+		{name: "auth not configured", auth: nil, body: `{"prompt":"hi"}`, want: http.StatusForbidden},
+		{name: "missing token", auth: wsbase.NewAuthenticator("secret", nil, wsbase.ExpireClose), body: `{"prompt":"hi"}`, want: http.StatusUnauthorized},
+		{name: "bad JSON", auth: wsbase.NewAuthenticator("secret", nil, wsbase.ExpireClose), hdr: "Bearer secret", body: `{`, want: http.StatusBadRequest},
+		{name: "empty prompt", auth: wsbase.NewAuthenticator("secret", nil, wsbase.ExpireClose), hdr: "Bearer secret", body: `{}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		api := NewPromptAPI(nil, NewControlLocks(), tt.auth)
+		r := httptest.NewRequest(http.MethodPost, "/api/agents/hq-mayor/prompt", strings.NewReader(tt.body))
+		r.SetPathValue("name", "hq-mayor")
+		if tt.hdr != "" {
+			r.Header.Set("Authorization", tt.hdr)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
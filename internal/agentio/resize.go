@@ -0,0 +1,157 @@
+package agentio
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ResizePolicy decides whose resize frames set an agent's terminal size when
+// several clients view it at once.
+type ResizePolicy string
+
+const (
+	ResizeLastWriter  ResizePolicy = "last-writer"  // every resize applies, as if each client were alone
+	ResizeLargest     ResizePolicy = "largest"      // the largest size any current viewer asked for
+	ResizeFirstWriter ResizePolicy = "first-writer" // the first client to resize owns the size until it leaves
+	ResizeController  ResizePolicy = "controller"   // only the input-control holder; anyone while nobody holds it
+)
+
+// ParseResizePolicy validates a --resize-policy value.
+func ParseResizePolicy(s string) (ResizePolicy, error) {
+	switch p := ResizePolicy(s); p {
+	case ResizeLastWriter, ResizeLargest, ResizeFirstWriter, ResizeController:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid resize policy %q: want last-writer, largest, first-writer, or controller", s)
+}
+
+// TermSize is a terminal size in character cells.
+type TermSize struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// ResizeArbiter applies a ResizePolicy to resize requests, tracking each
+// agent's authoritative size.
+type ResizeArbiter struct {
+	policy  ResizePolicy
+	control *ControlLocks
+
+	mu     sync.Mutex
+	agents map[string]*resizeState // agent name → state
+}
+
+type resizeState struct {
+	current  TermSize
+	owner    any              // first-writer: the client that owns the size
+	requests map[any]TermSize // largest: each client's last request
+}
+
+// NewResizeArbiter creates an arbiter. control is consulted by the
+// controller policy.
+func NewResizeArbiter(policy ResizePolicy, control *ControlLocks) *ResizeArbiter {
+	if policy == "" {
+		policy = ResizeLastWriter
+	}
+	return &ResizeArbiter{policy: policy, control: control, agents: make(map[string]*resizeState)}
+}
+
+// Request handles client asking for size on agent. It returns the
+// authoritative size afterwards and whether to apply it to the pane.
+func (a *ResizeArbiter) Request(agent string, client any, size TermSize) (TermSize, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st := a.state(agent)
+	var next TermSize
+	switch a.policy {
+	case ResizeLargest:
+		st.requests[client] = size
+		next = largest(st.requests)
+	case ResizeFirstWriter:
+		if st.owner != nil && st.owner != client {
+			return st.current, false
+		}
+		st.owner = client
+		next = size
+	case ResizeController:
+		if a.control.CheckInput(agent, client) != nil {
+			return st.current, false
+		}
+		next = size
+	default:
+		next = size
+	}
+	// Re-apply a granted size even if unchanged: tmux may have been resized
+	// behind our back, e.g. by an attached terminal.
+	apply := next != st.current || next == size
+	st.current = next
+	return next, apply
+}
+
+// Forget drops client's claims, such as when it disconnects, and returns the
+// agents whose authoritative size changed as a result with their new size.
+// A first-writer owner leaving frees the size without changing it.
+func (a *ResizeArbiter) Forget(client any) map[string]TermSize {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changed := make(map[string]TermSize)
+	for agent, st := range a.agents {
+		if st.owner == client {
+			st.owner = nil
+		}
+		if _, ok := st.requests[client]; !ok {
+			continue
+		}
+		delete(st.requests, client)
+		if len(st.requests) == 0 {
+			continue // keep the last size rather than shrinking to nothing
+		}
+		if next := largest(st.requests); next != st.current {
+			st.current = next
+			changed[agent] = next
+		}
+	}
+	return changed
+}
+
+// Current returns agent's authoritative size, zero if no resize has applied.
+func (a *ResizeArbiter) Current(agent string) TermSize {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if st, ok := a.agents[agent]; ok {
+		return st.current
+	}
+	return TermSize{}
+}
+
+// Rename moves oldName's size and claims to newName, for an agent whose
+// session was renamed.
+func (a *ResizeArbiter) Rename(oldName, newName string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if st, ok := a.agents[oldName]; ok {
+		delete(a.agents, oldName)
+		a.agents[newName] = st
+	}
+}
+
+func (a *ResizeArbiter) state(agent string) *resizeState {
+	st, ok := a.agents[agent]
+	if !ok {
+		st = &resizeState{requests: make(map[any]TermSize)}
+		a.agents[agent] = st
+	}
+	return st
+}
+
+// largest returns the per-dimension maximum of sizes.
+func largest(sizes map[any]TermSize) TermSize {
+	var out TermSize
+	for _, s := range sizes {
+		out.Cols = max(out.Cols, s.Cols)
+		out.Rows = max(out.Rows, s.Rows)
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package agentio
+
+import "testing"
+
+func TestResizeLargest(t *testing.T) {
+	r := NewResizeArbiter(ResizeLargest, NewControlLocks())
+	a, b := new(int), new(int)
+
+	if size, apply := r.Request("hq-mayor", a, TermSize{Cols: 120, Rows: 40}); !apply || size != (TermSize{120, 40}) {
+		t.Fatalf("first request = %v, %v; want 120x40 applied", size, apply)
+	}
+	if size, apply := r.Request("hq-mayor", b, TermSize{Cols: 80, Rows: 60}); !apply || size != (TermSize{120, 60}) {
+		t.Fatalf("second request = %v, %v; want 120x60 applied", size, apply)
+	}
+	if _, apply := r.Request("hq-mayor", b, TermSize{Cols: 80, Rows: 50}); !apply {
+		t.Fatal("shrinking the largest row request should apply the new maximum")
+	}
+	if got := r.Current("hq-mayor"); got != (TermSize{120, 50}) {
+		t.Fatalf("Current = %v, want 120x50", got)
+	}
+
+	changed := r.Forget(a)
+	if got := changed["hq-mayor"]; got != (TermSize{80, 50}) {
+		t.Fatalf("Forget changed = %v, want hq-mayor 80x50", changed)
+	}
+}
+
+func TestResizeFirstWriter(t *testing.T) {
+	r := NewResizeArbiter(ResizeFirstWriter, NewControlLocks())
+	a, b := new(int), new(int)
+
+	r.Request("hq-mayor", a, TermSize{Cols: 100, Rows: 30})
+	if size, apply := r.Request("hq-mayor", b, TermSize{Cols: 200, Rows: 60}); apply || size != (TermSize{100, 30}) {
+		t.Fatalf("second writer = %v, %v; want 100x30 not applied", size, apply)
+	}
+	r.Forget(a)
+	if size, apply := r.Request("hq-mayor", b, TermSize{Cols: 200, Rows: 60}); !apply || size != (TermSize{200, 60}) {
+		t.Fatalf("after owner left = %v, %v; want 200x60 applied", size, apply)
+	}
+}
+
+func TestResizeController(t *testing.T) {
+	locks := NewControlLocks()
+	r := NewResizeArbiter(ResizeController, locks)
+	a, b := new(int), new(int)
+
+	if _, apply := r.Request("hq-mayor", b, TermSize{Cols: 90, Rows: 30}); !apply {
+		t.Fatal("anyone may resize while nobody holds control")
+	}
+	locks.Acquire("hq-mayor", a, "client-1")
+	if _, apply := r.Request("hq-mayor", b, TermSize{Cols: 200, Rows: 60}); apply {
+		t.Fatal("observer resize applied while another client holds control")
+	}
+	if size, apply := r.Request("hq-mayor", a, TermSize{Cols: 100, Rows: 40}); !apply || size != (TermSize{100, 40}) {
+		t.Fatalf("holder resize = %v, %v; want 100x40 applied", size, apply)
+	}
+}
+
+func TestParseResizePolicy(t *testing.T) {
+	if _, err := ParseResizePolicy("largest"); err != nil {
+		t.Fatalf("ParseResizePolicy(largest) error = %v", err)
+	}
+	if _, err := ParseResizePolicy("smallest"); err == nil {
+		t.Fatal("ParseResizePolicy(smallest) error = nil, want error")
+	}
+}
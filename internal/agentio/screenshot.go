@@ -0,0 +1,71 @@
+package agentio
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/termimage"
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+// ScreenshotAPI serves GET /api/agents/{name}/screenshot.png: the agent's
+// visible pane rendered to a PNG on the server, for status pages and link
+// previews that can't run a browser terminal.
+type ScreenshotAPI struct {
+	ctrl     *tmux.ControlMode
+	registry *agents.Registry
+	auth     *wsbase.Authenticator
+}
+
+// NewScreenshotAPI creates the HTTP screenshot endpoint. Callers need the
+// read scope when auth is configured.
+func NewScreenshotAPI(ctrl *tmux.ControlMode, registry *agents.Registry, auth *wsbase.Authenticator) *ScreenshotAPI {
+	return &ScreenshotAPI{ctrl: ctrl, registry: registry, auth: auth}
+}
+
+func (a *ScreenshotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	grant, err := a.auth.Authenticate(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !grant.Read {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	agentName := r.PathValue("name")
+	if _, ok := a.registry.GetAgent(agentName); !ok {
+		if a.registry.Exited(agentName) {
+			http.Error(w, agents.ErrAgentExited.Error(), http.StatusGone)
+			return
+		}
+		http.Error(w, "agent not found", http.StatusNotFound)
+		return
+	}
+	text, err := a.ctrl.CapturePaneVisible(agentName)
+	if err != nil {
+		http.Error(w, "capture pane: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	// Sized to the pane, so blank rows and trailing columns show; if the
+	// size can't be read the image is sized to the text.
+	var cols, rows int
+	if size, err := a.ctrl.DisplayMessage(agentName, "#{pane_width} #{pane_height}"); err == nil {
+		_, _ = fmt.Sscanf(size, "%d %d", &cols, &rows)
+	}
+
+	var buf bytes.Buffer
+	if err := termimage.EncodePNG(&buf, text, cols, rows); err != nil {
+		log.Printf("screenshot %s: %v", agentName, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(buf.Bytes())
+}
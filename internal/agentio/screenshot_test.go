@@ -0,0 +1,20 @@
+package agentio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+func TestScreenshotAPIRequiresAuth(t *testing.T) {
+	api := NewScreenshotAPI(nil, nil, wsbase.NewAuthenticator("secret", nil, wsbase.ExpireClose))
+	r := httptest.NewRequest(http.MethodGet, "/api/agents/hq-mayor/screenshot.png", nil)
+	r.SetPathValue("name", "hq-mayor")
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,139 @@
+package agentio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Submit modes.
+const (
+	SubmitAuto      = "auto"       // type the prompt, then press the submit keys
+	SubmitPasteOnly = "paste-only" // type the prompt and leave it for a human to submit
+)
+
+// SubmitStrategy is how typed text is submitted to one runtime.
+type SubmitStrategy struct {
+	Mode   string        // SubmitAuto or SubmitPasteOnly
+	Keys   []string      // tmux key names pressed in turn to submit, e.g. Enter or M-Enter
+	Settle time.Duration // wait after typing, before verifying and submitting
+	Escape bool          // press Escape before the submit keys, leaving vim insert mode
+}
+
+// DefaultSubmitStrategy is the nudge sequence every runtime gets unless a
+// submit config overrides it.
+var DefaultSubmitStrategy = SubmitStrategy{
+	Mode:   SubmitAuto,
+	Keys:   []string{"Enter"},
+	Settle: 500 * time.Millisecond,
+	Escape: true,
+}
+
+// SubmitStrategies maps runtimes to their submit strategy. A nil map gives
+// every runtime DefaultSubmitStrategy.
+type SubmitStrategies map[string]SubmitStrategy
+
+// For returns runtime's strategy.
+func (s SubmitStrategies) For(runtime string) SubmitStrategy {
+	if st, ok := s[runtime]; ok {
+		return st
+	}
+	if st, ok := s["*"]; ok {
+		return st
+	}
+	return DefaultSubmitStrategy
+}
+
+// submitOverride is one runtime's entry in a submit config file. Fields left
+// out keep their defaults.
+type submitOverride struct {
+	Mode   string   `json:"mode,omitempty"`
+	Keys   []string `json:"keys,omitempty"`
+	Settle string   `json:"settle,omitempty"`
+	Escape *bool    `json:"escape,omitempty"`
+}
+
+// LoadSubmitStrategies reads a JSON file mapping runtime names to overrides
+// of DefaultSubmitStrategy, such as
+//
+//	{"codex": {"keys": ["M-Enter"], "settle": "1s"}, "claude": {"mode": "paste-only"}}
+//
+// The runtime "*" sets the defaults other entries and unlisted runtimes
+// build on. An empty path returns nil.
+func LoadSubmitStrategies(path string) (SubmitStrategies, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("submit config: %w", err)
+	}
+	var overrides map[string]submitOverride
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("submit config %s: %w", path, err)
+	}
+	s, err := parseSubmitStrategies(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("submit config %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// parseSubmitStrategies applies overrides, keyed by runtime, to
+// DefaultSubmitStrategy.
+func parseSubmitStrategies(overrides map[string]submitOverride) (SubmitStrategies, error) {
+	base := DefaultSubmitStrategy
+	if o, ok := overrides["*"]; ok {
+		var err error
+		if base, err = o.apply(base); err != nil {
+			return nil, fmt.Errorf("runtime *: %w", err)
+		}
+	}
+	s := SubmitStrategies{"*": base}
+	for runtime, o := range overrides {
+		if runtime == "*" {
+			continue
+		}
+		st, err := o.apply(base)
+		if err != nil {
+			return nil, fmt.Errorf("runtime %s: %w", runtime, err)
+		}
+		s[runtime] = st
+	}
+	return s, nil
+}
+
+func (o submitOverride) apply(st SubmitStrategy) (SubmitStrategy, error) {
+	switch o.Mode {
+	case "":
+	case SubmitAuto, SubmitPasteOnly:
+		st.Mode = o.Mode
+	default:
+		return st, fmt.Errorf("unknown mode %q (want %s or %s)", o.Mode, SubmitAuto, SubmitPasteOnly)
+	}
+	if o.Keys != nil {
+		for _, key := range o.Keys {
+			// Keys are passed to send-keys unquoted.
+			if key == "" || strings.ContainsAny(key, " \t\r\n'\"\\;") {
+				return st, fmt.Errorf("invalid key %q", key)
+			}
+		}
+		st.Keys = o.Keys
+	}
+	if o.Settle != "" {
+		d, err := time.ParseDuration(o.Settle)
+		if err != nil || d < 0 {
+			return st, fmt.Errorf("invalid settle %q", o.Settle)
+		}
+		st.Settle = d
+	}
+	if o.Escape != nil {
+		st.Escape = *o.Escape
+	}
+	if st.Mode == SubmitAuto && len(st.Keys) == 0 {
+		return st, fmt.Errorf("mode %s needs at least one key", SubmitAuto)
+	}
+	return st, nil
+}
@@ -0,0 +1,71 @@
+package agentio
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeSubmitConfig(t *testing.T, config string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "submit.json")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSubmitStrategies(t *testing.T) {
+	path := writeSubmitConfig(t, `{
+		"*": {"settle": "800ms"},
+		"codex": {"keys": ["M-Enter"], "escape": false},
+		"claude": {"mode": "paste-only"}
+	}`)
+	s, err := LoadSubmitStrategies(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		runtime string
+		want    SubmitStrategy
+	}{
+		{"codex", SubmitStrategy{Mode: SubmitAuto, Keys: []string{"M-Enter"}, Settle: 800 * time.Millisecond, Escape: false}},
+		{"claude", SubmitStrategy{Mode: SubmitPasteOnly, Keys: []string{"Enter"}, Settle: 800 * time.Millisecond, Escape: true}},
+		{"gemini", SubmitStrategy{Mode: SubmitAuto, Keys: []string{"Enter"}, Settle: 800 * time.Millisecond, Escape: true}},
+	}
+	for _, tt := range tests {
+		if got := s.For(tt.runtime); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("For(%q) = %+v, want %+v", tt.runtime, got, tt.want)
+		}
+	}
+}
+
+func TestSubmitStrategiesDefault(t *testing.T) {
+	s, err := LoadSubmitStrategies("")
+	if err != nil || s != nil {
+		t.Fatalf("LoadSubmitStrategies(\"\") = %v, %v; want nil, nil", s, err)
+	}
+	if got := s.For("claude"); !reflect.DeepEqual(got, DefaultSubmitStrategy) {
+		t.Errorf("nil For(claude) = %+v, want the default", got)
+	}
+}
+
+func TestLoadSubmitStrategiesRejectsBadConfig(t *testing.T) {
+	for _, config := range []string{
+		`{"codex": {"mode": "type-only"}}`,
+		`{"codex": {"settle": "soon"}}`,
+		`{"codex": {"keys": ["Enter; kill-server"]}}`,
+		`{"codex": {"keys": []}}`,
+		`["codex"]`,
+	} {
+		if _, err := LoadSubmitStrategies(writeSubmitConfig(t, config)); err == nil {
+			t.Errorf("LoadSubmitStrategies(%s) succeeded, want an error", config)
+		}
+	}
+	if _, err := LoadSubmitStrategies(writeSubmitConfig(t, `{"codex": {"mode": "paste-only", "keys": []}}`)); err != nil {
+		t.Errorf("paste-only without keys: %v", err)
+	}
+}
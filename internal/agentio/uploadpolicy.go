@@ -0,0 +1,266 @@
+package agentio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// uploadScanTimeout bounds how long an external upload scanner may run.
+const uploadScanTimeout = 60 * time.Second
+
+// UploadPolicy screens file uploads before they are written into an agent's
+// upload directory. Refusals are returned as *Rejection with Rule set to
+// "extension", "mime-type", "size", "quota", or "scanner".
+type UploadPolicy struct {
+	// Extensions lists allowed file extensions such as ".png"; empty allows any.
+	Extensions []string
+	// MIMETypes lists allowed types; "image/*" admits a whole family. Both
+	// the declared type, if any, and the type sniffed from the content must
+	// be allowed, so a mislabelled file is refused.
+	MIMETypes []string
+	// MaxBytes caps a single file below the protocol limit; 0 disables.
+	MaxBytes int
+	// AgentQuota caps the total bytes kept in an agent's upload directories; 0 disables.
+	AgentQuota int64
+	// Scanner is a shell command run with a staged copy of the file as $1 and
+	// the agent name in $TMUX_ADAPTER_AGENT. A non-zero exit rejects the file,
+	// with its output as the reason.
+	Scanner string
+}
+
+// LoadUploadPolicy builds a policy from command-line settings: comma-separated
+// extensions and MIME types, a per-file size cap, a per-agent quota, and a
+// scanner command. It returns nil when nothing is set.
+func LoadUploadPolicy(extensions, mimeTypes string, maxBytes int, agentQuota int64, scanner string) (*UploadPolicy, error) {
+	p := &UploadPolicy{MaxBytes: max(maxBytes, 0), AgentQuota: max(agentQuota, 0), Scanner: scanner}
+	for _, ext := range strings.Split(extensions, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		p.Extensions = append(p.Extensions, ext)
+	}
+	for _, mt := range strings.Split(mimeTypes, ",") {
+		mt = strings.ToLower(strings.TrimSpace(mt))
+		if mt == "" {
+			continue
+		}
+		if _, err := path.Match(mt, ""); err != nil {
+			return nil, fmt.Errorf("upload MIME type %q: %w", mt, err)
+		}
+		p.MIMETypes = append(p.MIMETypes, mt)
+	}
+	if len(p.Extensions) == 0 && len(p.MIMETypes) == 0 && p.MaxBytes == 0 && p.AgentQuota == 0 && p.Scanner == "" {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// Check returns nil if the file may be saved for the agent, or a *Rejection.
+// A nil policy allows every upload.
+func (p *UploadPolicy) Check(agentName, workDir, fileName, mimeType string, data []byte) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.checkMeta(agentName, workDir, fileName, mimeType, int64(len(data))); err != nil {
+		return err
+	}
+	if err := p.checkMIME(http.DetectContentType(data), "content sniffed as"); err != nil {
+		return err
+	}
+	if p.Scanner == "" {
+		return nil
+	}
+
+	f, err := os.CreateTemp("", "tmux-adapter-scan-*-"+SanitizePathComponent(fileName))
+	if err != nil {
+		return fmt.Errorf("stage upload for scan: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("stage upload for scan: %w", err)
+	}
+	return p.scan(agentName, f.Name())
+}
+
+// CheckFile is Check for an upload already staged at stagedPath, outside the
+// agent's workdir, as chunked uploads are.
+func (p *UploadPolicy) CheckFile(agentName, workDir, fileName, mimeType, stagedPath string) error {
+	if p == nil {
+		return nil
+	}
+	f, err := os.Open(stagedPath)
+	if err != nil {
+		return err
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	info, err := f.Stat()
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	if err := p.checkMeta(agentName, workDir, fileName, mimeType, info.Size()); err != nil {
+		return err
+	}
+	if err := p.checkMIME(http.DetectContentType(head[:n]), "content sniffed as"); err != nil {
+		return err
+	}
+	if p.Scanner == "" {
+		return nil
+	}
+	return p.scan(agentName, stagedPath)
+}
+
+// checkMeta applies every rule except the scanner and content sniffing to
+// the declared type. An empty mimeType skips the MIME check, so it can run
+// before any content has arrived.
+func (p *UploadPolicy) checkMeta(agentName, workDir, fileName, mimeType string, size int64) error {
+	if p == nil {
+		return nil
+	}
+	if len(p.Extensions) > 0 {
+		ext := strings.ToLower(filepath.Ext(fileName))
+		if !slices.Contains(p.Extensions, ext) {
+			return &Rejection{Rule: "extension", Reason: fmt.Sprintf("file extension %q not allowed", ext)}
+		}
+	}
+	if mimeType != "" {
+		if err := p.checkMIME(mimeType, "MIME type"); err != nil {
+			return err
+		}
+	}
+	if p.MaxBytes > 0 && size > int64(p.MaxBytes) {
+		return &Rejection{Rule: "size", Reason: fmt.Sprintf("file is %d bytes, limit is %d", size, p.MaxBytes)}
+	}
+	if p.AgentQuota > 0 {
+		used := uploadUsage(workDir, agentName)
+		if used+size > p.AgentQuota {
+			return &Rejection{Rule: "quota", Reason: fmt.Sprintf("agent has %d bytes of uploads, quota is %d", used, p.AgentQuota)}
+		}
+	}
+	return nil
+}
+
+// checkArchive applies the size cap and scanner to an archive staged at
+// stagedPath. Extension rules apply to its entries instead (see allowEntry),
+// and MIME rules do not apply.
+func (p *UploadPolicy) checkArchive(agentName, stagedPath string, size int64) error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxBytes > 0 && size > int64(p.MaxBytes) {
+		return &Rejection{Rule: "size", Reason: fmt.Sprintf("archive is %d bytes, limit is %d", size, p.MaxBytes)}
+	}
+	if p.Scanner == "" {
+		return nil
+	}
+	return p.scan(agentName, stagedPath)
+}
+
+// allowEntry applies the extension allowlist to a file extracted from an archive.
+func (p *UploadPolicy) allowEntry(name string) error {
+	if p == nil || len(p.Extensions) == 0 {
+		return nil
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	if !slices.Contains(p.Extensions, ext) {
+		return &Rejection{Rule: "extension", Reason: fmt.Sprintf("archive entry %q has disallowed extension %q", name, ext)}
+	}
+	return nil
+}
+
+// extractBudget returns how many bytes an archive may expand to for the
+// agent, and the rule ("size" or "quota") that sets the limit.
+func (p *UploadPolicy) extractBudget(agentName, workDir string) (int64, string) {
+	if p == nil || p.AgentQuota == 0 {
+		return MaxArchiveExtractBytes, "size"
+	}
+	if left := p.AgentQuota - uploadUsage(workDir, agentName); left < MaxArchiveExtractBytes {
+		return left, "quota"
+	}
+	return MaxArchiveExtractBytes, "size"
+}
+
+// checkMIME applies the MIME allowlist to mimeType; what names it in the
+// rejection, e.g. "MIME type" for a declared one.
+func (p *UploadPolicy) checkMIME(mimeType, what string) error {
+	if len(p.MIMETypes) == 0 {
+		return nil
+	}
+	// Drop parameters such as "; charset=utf-8".
+	mt, _, _ := strings.Cut(strings.ToLower(mimeType), ";")
+	mt = strings.TrimSpace(mt)
+	if !p.mimeAllowed(mt) {
+		return &Rejection{Rule: "mime-type", Reason: fmt.Sprintf("%s %q not allowed", what, mt)}
+	}
+	return nil
+}
+
+func (p *UploadPolicy) mimeAllowed(mt string) bool {
+	for _, pattern := range p.MIMETypes {
+		if ok, _ := path.Match(pattern, mt); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scan runs the scanner on a file staged outside the agent's workdir.
+func (p *UploadPolicy) scan(agentName, stagedPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), uploadScanTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.Scanner, "sh", stagedPath)
+	cmd.Env = append(os.Environ(), "TMUX_ADAPTER_AGENT="+agentName)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	reason := strings.TrimSpace(string(out))
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || ctx.Err() != nil {
+		// Fail closed: a broken or hung scanner must not let files through.
+		log.Printf("upload scanner for %s: %v", agentName, err)
+		reason = "upload scanner failed"
+	} else if reason == "" {
+		reason = "rejected by upload scanner"
+	}
+	return &Rejection{Rule: "scanner", Reason: reason}
+}
+
+// uploadUsage sums the sizes of files in the agent's upload directories.
+func uploadUsage(workDir, agentName string) int64 {
+	var total int64
+	for _, dir := range uploadDirs(workDir, agentName) {
+		_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+			return nil
+		})
+	}
+	return total
+}
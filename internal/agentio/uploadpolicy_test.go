@@ -0,0 +1,80 @@
+package agentio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadPolicyTypeAndSize(t *testing.T) {
+	p, err := LoadUploadPolicy("png, .TXT", "image/*,text/plain", 16, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, mime string
+		data       string
+		rule       string // "" means allowed
+	}{
+		{name: "shot.png", mime: "image/png", data: "\x89PNG\r\n\x1a\n"},
+		{name: "notes.txt", mime: "text/plain; charset=utf-8", data: "hello"},
+		{name: "notes.txt", mime: "", data: "sniffed as text"},
+		{name: "run.sh", mime: "text/plain", data: "echo", rule: "extension"},
+		{name: "fake.png", mime: "application/x-sh", data: "echo", rule: "mime-type"},
+		{name: "page.png", mime: "image/png", data: "<html><script>", rule: "mime-type"},
+		{name: "page.png", mime: "", data: "<html><script>", rule: "mime-type"},
+		{name: "big.txt", mime: "text/plain", data: "seventeen bytes!!", rule: "size"},
+	}
+	for _, tt := range tests {
+		err := p.Check("hq-mayor", "", tt.name, tt.mime, []byte(tt.data))
+		var rejection *Rejection
+		switch {
+		case tt.rule == "" && err != nil:
+			t.Errorf("Check(%q, %q) error = %v, want allowed", tt.name, tt.mime, err)
+		case tt.rule != "" && !errors.As(err, &rejection):
+			t.Errorf("Check(%q, %q) error = %v, want *Rejection", tt.name, tt.mime, err)
+		case tt.rule != "" && rejection.Rule != tt.rule:
+			t.Errorf("Check(%q, %q) rule = %q, want %q", tt.name, tt.mime, rejection.Rule, tt.rule)
+		}
+	}
+}
+
+func TestUploadPolicyAgentQuota(t *testing.T) {
+	workDir := t.TempDir()
+	agent := "quota-test-" + filepath.Base(workDir)
+	if _, err := SaveUploadedFile(workDir, agent, "a.txt", make([]byte, 60)); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &UploadPolicy{AgentQuota: 100}
+	if err := p.Check(agent, workDir, "b.txt", "text/plain", make([]byte, 40)); err != nil {
+		t.Fatalf("upload within quota rejected: %v", err)
+	}
+	var rejection *Rejection
+	err := p.Check(agent, workDir, "c.txt", "text/plain", make([]byte, 41))
+	if !errors.As(err, &rejection) || rejection.Rule != "quota" {
+		t.Fatalf("upload over quota error = %v, want quota rejection", err)
+	}
+}
+
+func TestUploadPolicyScanner(t *testing.T) {
+	p := &UploadPolicy{Scanner: `if grep -q EICAR "$1"; then echo "$1: Eicar-Signature FOUND"; exit 1; fi`}
+
+	if err := p.Check("hq-mayor", "", "clean.txt", "text/plain", []byte("hello")); err != nil {
+		t.Fatalf("clean file rejected: %v", err)
+	}
+
+	var rejection *Rejection
+	err := p.Check("hq-mayor", "", "bad.txt", "text/plain", []byte("X5O EICAR test"))
+	if !errors.As(err, &rejection) || rejection.Rule != "scanner" {
+		t.Fatalf("infected file error = %v, want scanner rejection", err)
+	}
+
+	// The staged copy never lands in the agent's upload directory and is cleaned up.
+	matches, _ := filepath.Glob(filepath.Join(os.TempDir(), "tmux-adapter-scan-*-bad.txt"))
+	if len(matches) != 0 {
+		t.Fatalf("staged scan files left behind: %v", matches)
+	}
+}
@@ -0,0 +1,101 @@
+package agentio
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrPromptNotVisible is returned when typed text can't be found in the
+// agent's input area, typically because a permission dialog or menu had
+// focus. Enter is not pressed, so the text is left where it landed.
+var ErrPromptNotVisible = errors.New("prompt text did not appear in the agent's input area; not submitted")
+
+// Input verification results, reported in PromptDelivery.Verification.
+const (
+	VerifyFound   = "found"   // the end of the text is on screen
+	VerifyPasted  = "pasted"  // the runtime showed a placeholder for pasted text
+	VerifySkipped = "skipped" // the pane could not be captured
+	VerifyFailed  = "failed"  // the text was not on screen; see ErrPromptNotVisible
+)
+
+const (
+	// verifyLines is how many lines from the bottom of the screen are
+	// searched: the input area, below any dialog.
+	verifyLines = 15
+	// verifyProbe is how many of the text's last non-space characters must
+	// appear there.
+	verifyProbe = 40
+	// verifyAttempts captures are made verifyInterval apart before giving
+	// up, for TUIs slow to redraw.
+	verifyAttempts = 3
+	verifyInterval = 150 * time.Millisecond
+)
+
+// minPlaceholderPaste is the length past which single-line text may be shown
+// as a paste placeholder; runtimes only collapse long or multi-line pastes.
+const minPlaceholderPaste = 200
+
+// pastePlaceholders are what runtimes show in the input area instead of a
+// long paste, lowercased: Claude's "[Pasted text #1 +20 lines]" and Codex's
+// "[Pasted Content 1204 chars]".
+var pastePlaceholders = []string{"[pasted text", "[pasted content"}
+
+// verifyInput checks that text, just typed into session, is showing in the
+// agent's input area.
+func (p *Prompter) verifyInput(session, text string) (string, error) {
+	for attempt := range verifyAttempts {
+		if attempt > 0 {
+			time.Sleep(verifyInterval)
+		}
+		screen, err := p.Ctrl.CapturePaneVisibleText(session)
+		if err != nil {
+			log.Printf("send-prompt(%s): input not verified: %v", session, err)
+			return VerifySkipped, nil
+		}
+		if result, ok := inputShows(screen, text); ok {
+			return result, nil
+		}
+	}
+	log.Printf("send-prompt(%s): typed text not found in the input area", session)
+	return VerifyFailed, ErrPromptNotVisible
+}
+
+// inputShows reports whether the bottom of screen shows text, or a paste
+// placeholder standing in for it. Whitespace and box-drawing characters are
+// ignored on both sides, since input boxes wrap and frame long text.
+func inputShows(screen, text string) (string, bool) {
+	lines := strings.Split(strings.TrimRight(screen, "\n "), "\n")
+	bottom := strings.Join(lines[max(len(lines)-verifyLines, 0):], "\n")
+	long := strings.Contains(strings.TrimSpace(text), "\n") || len(text) > minPlaceholderPaste
+	if long && containsAny(strings.ToLower(bottom), pastePlaceholders) {
+		return VerifyPasted, true
+	}
+	probe := []rune(squeeze(text))
+	probe = probe[max(len(probe)-verifyProbe, 0):]
+	if strings.Contains(squeeze(bottom), string(probe)) {
+		return VerifyFound, true
+	}
+	return VerifyFailed, false
+}
+
+// squeeze drops whitespace and box-drawing characters.
+func squeeze(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || (r >= 0x2500 && r <= 0x257F) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
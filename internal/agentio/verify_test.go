@@ -0,0 +1,61 @@
+package agentio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInputShows(t *testing.T) {
+	long := strings.Repeat("refactor the session registry ", 10)
+	tests := []struct {
+		name, screen, text string
+		want               string
+		ok                 bool
+	}{
+		{
+			name:   "plain input line",
+			screen: "● Done.\n\n> fix the build\n  ? for shortcuts\n",
+			text:   "fix the build",
+			want:   VerifyFound,
+			ok:     true,
+		},
+		{
+			name:   "wrapped in a framed box",
+			screen: "╭──────────────╮\n│ > please look │\n│ at the tests  │\n╰──────────────╯\n",
+			text:   "please look at the tests",
+			want:   VerifyFound,
+			ok:     true,
+		},
+		{
+			name:   "permission dialog had focus",
+			screen: "Do you want to run go test ./...?\n❯ 1. Yes\n  2. No\n",
+			text:   "fix the build",
+			want:   VerifyFailed,
+		},
+		{
+			name:   "long paste shown as placeholder",
+			screen: "> [Pasted text #1 +3 lines]\n",
+			text:   long,
+			want:   VerifyPasted,
+			ok:     true,
+		},
+		{
+			name:   "short text doesn't count a placeholder",
+			screen: "> [Pasted text #1 +3 lines]\n",
+			text:   "fix the build",
+			want:   VerifyFailed,
+		},
+		{
+			name:   "only the bottom of the screen is searched",
+			screen: "> fix the build\n" + strings.Repeat("output\n", verifyLines+1),
+			text:   "fix the build",
+			want:   VerifyFailed,
+		},
+	}
+	for _, tt := range tests {
+		got, ok := inputShows(tt.screen, tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: inputShows() = %q, %t; want %q, %t", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
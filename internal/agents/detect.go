@@ -16,17 +16,26 @@ type Agent struct {
 	Rig      *string `json:"rig"`
 	WorkDir  string  `json:"workDir"`
 	Attached bool    `json:"attached"`
+
+	// Project is the git working tree WorkDir is in (see ProjectRoot).
+	Project string `json:"project,omitempty"`
+
+	// SessionID is tmux's ID for the agent's session ("$N"), which stays
+	// the same when the session is renamed.
+	SessionID string `json:"sessionId,omitempty"`
 }
 
 // runtimeProcessNames maps agent preset names to the process names they run as.
 var runtimeProcessNames = map[string][]string{
-	"claude":  {"node", "claude"},
-	"gemini":  {"gemini"},
-	"codex":   {"codex"},
-	"cursor":  {"cursor-agent"},
-	"auggie":  {"auggie"},
-	"amp":     {"amp"},
+	"claude":   {"node", "claude"},
+	"gemini":   {"gemini"},
+	"codex":    {"codex"},
+	"cursor":   {"cursor-agent"},
+	"auggie":   {"auggie"},
+	"amp":      {"amp"},
 	"opencode": {"opencode", "node", "bun"},
+	// simulated is "tmux-adapter demo-agent", a fake agent for demos.
+	"simulated": {"tmux-adapter"},
 }
 
 // knownShells is the set of process names that indicate a shell (not an agent).
@@ -73,20 +82,26 @@ func CheckProcessBinary(pid string, processNames []string) bool {
 // CheckDescendants walks the process tree looking for a matching process name.
 // Max depth of 10 to prevent infinite loops.
 func CheckDescendants(pid string, processNames []string) bool {
-	return checkDescendantsDepth(pid, processNames, 0)
+	return FindDescendant(pid, processNames) != ""
+}
+
+// FindDescendant is CheckDescendants returning the matching process's PID,
+// or "" if there is none.
+func FindDescendant(pid string, processNames []string) string {
+	return findDescendantDepth(pid, processNames, 0)
 }
 
-func checkDescendantsDepth(pid string, processNames []string, depth int) bool {
+func findDescendantDepth(pid string, processNames []string, depth int) string {
 	if depth >= 10 {
-		return false
+		return ""
 	}
 
 	out, err := exec.Command("pgrep", "-P", pid, "-l").Output()
 	if err != nil {
 		if _, ok := err.(*exec.ExitError); !ok {
-			log.Printf("checkDescendantsDepth(%s): unexpected error: %v", pid, err)
+			log.Printf("findDescendantDepth(%s): unexpected error: %v", pid, err)
 		}
-		return false
+		return ""
 	}
 
 	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
@@ -101,13 +116,13 @@ func checkDescendantsDepth(pid string, processNames []string, depth int) bool {
 		childName := parts[1]
 
 		if IsAgentProcess(childName, processNames) {
-			return true
+			return childPID
 		}
-		if checkDescendantsDepth(childPID, processNames, depth+1) {
-			return true
+		if found := findDescendantDepth(childPID, processNames, depth+1); found != "" {
+			return found
 		}
 	}
-	return false
+	return ""
 }
 
 // ParseSessionName extracts role and rig from a gastown session name.
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package agents
+
+// processAlive can't probe processes here, so agents are only demoted when
+// a scan notices their pane command change.
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,28 @@
+package agents
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ProjectRoot returns the project an agent working in dir belongs to: the
+// root of the git working tree containing dir, the nearest directory
+// holding .git (a file in worktrees and submodules), or dir itself when it
+// is in no working tree. Agents on different worktrees of one repository
+// are different projects.
+func ProjectRoot(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	dir = filepath.Clean(dir)
+	for d := dir; ; {
+		if _, err := os.Lstat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
@@ -0,0 +1,38 @@
+package agents
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectRoot(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	worktree := filepath.Join(root, "feature")
+	plain := filepath.Join(root, "scratch", "notes")
+	for _, dir := range []string{filepath.Join(repo, ".git"), filepath.Join(repo, "cmd", "tool"), worktree, plain} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+repo+"/.git/worktrees/feature\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir, want string
+	}{
+		{repo, repo},
+		{filepath.Join(repo, "cmd", "tool"), repo},
+		{filepath.Join(repo, "cmd", "tool") + "/", repo},
+		{worktree, worktree},
+		{plain, plain},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ProjectRoot(tt.dir); got != tt.want {
+			t.Errorf("ProjectRoot(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
@@ -1,18 +1,45 @@
 package agents
 
 import (
+	"errors"
 	"log"
+	"maps"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
 )
 
 // RegistryEvent represents a change in agent state.
 type RegistryEvent struct {
-	Type  string // "added", "removed", "updated"
-	Agent Agent
+	Type    string // "added", "removed", "updated", "renamed"
+	Agent   Agent
+	Reason  string // for "removed": ReasonProcessExited, or "" when the session ended
+	OldName string // for "renamed": the agent's name before its session was renamed
 }
 
+// ReasonProcessExited marks an agent removed because its process exited
+// while its tmux session lives on.
+const ReasonProcessExited = "process-exited"
+
+// ErrAgentExited refuses input to a session whose agent process has exited;
+// the pane is left running a shell.
+var ErrAgentExited = errors.New("agent process has exited; its session is running a shell")
+
+// processCheckInterval is how often the registry checks that each agent's
+// process is still running. tmux only notifies the registry when sessions
+// change, so without it an agent that exits inside its session lingers until
+// the next unrelated notification.
+const processCheckInterval = time.Second
+
+// DefaultRescanInterval is how often the registry looks for agents started
+// in sessions that had none.
+const DefaultRescanInterval = 5 * time.Second
+
 // Registry tracks live agents and emits lifecycle events.
 type Registry struct {
 	ctrl         ControlModeInterface
@@ -22,21 +49,39 @@ type Registry struct {
 	gtDir        string
 	skipSessions []string
 	stopCh       chan struct{}
+
+	pids   map[string]int  // agent name -> PID of its process, when known
+	exited map[string]bool // sessions whose agent process exited, until it returns or the session ends
+
+	idle           map[string]string // gastown sessions running no agent -> their pane command at the last scan
+	rescanInterval time.Duration
 }
 
 // NewRegistry creates a new agent registry.
 // skipSessions lists tmux session names to ignore during scanning (e.g., monitor sessions).
 func NewRegistry(ctrl ControlModeInterface, gtDir string, skipSessions []string) *Registry {
 	return &Registry{
-		ctrl:         ctrl,
-		agents:       make(map[string]Agent),
-		events:       make(chan RegistryEvent, 100),
-		gtDir:        gtDir,
-		skipSessions: skipSessions,
-		stopCh:       make(chan struct{}),
+		ctrl:           ctrl,
+		agents:         make(map[string]Agent),
+		events:         make(chan RegistryEvent, 100),
+		gtDir:          gtDir,
+		skipSessions:   skipSessions,
+		stopCh:         make(chan struct{}),
+		pids:           make(map[string]int),
+		exited:         make(map[string]bool),
+		idle:           make(map[string]string),
+		rescanInterval: DefaultRescanInterval,
 	}
 }
 
+// SetRescanInterval sets how often sessions without an agent are checked
+// for one started in them since the last scan; 0 disables the check. tmux
+// sends no notification when a command starts in an existing pane unless it
+// renames the window. Must be called before Start.
+func (r *Registry) SetRescanInterval(d time.Duration) {
+	r.rescanInterval = d
+}
+
 // Start begins watching for agent changes.
 func (r *Registry) Start() error {
 	// Initial scan
@@ -46,6 +91,8 @@ func (r *Registry) Start() error {
 
 	// Watch for tmux notifications
 	go r.watchLoop()
+	go r.watchProcesses()
+	go r.rescanLoop()
 	return nil
 }
 
@@ -79,10 +126,37 @@ func (r *Registry) GetAgent(name string) (Agent, bool) {
 	return a, ok
 }
 
+// Exited reports whether name is a session whose agent process has exited
+// while the session itself is still running.
+func (r *Registry) Exited(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.exited[name]
+}
+
 func (r *Registry) shouldSkip(sessionName string) bool {
 	return slices.Contains(r.skipSessions, sessionName)
 }
 
+// withinDir reports whether path is dir or lies beneath it. A bare prefix
+// check would let ~/gt admit ~/gt-other.
+func withinDir(path, dir string) bool {
+	path, dir = filepath.Clean(path), filepath.Clean(dir)
+	if path == dir || dir == string(filepath.Separator) {
+		return true
+	}
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// sessionBase strips the "user:" namespace other users' sessions are listed
+// under (see tmux.PeerSeparator), so their names parse like local ones.
+func sessionBase(name string) string {
+	if _, rest, ok := strings.Cut(name, tmux.PeerSeparator); ok {
+		return rest
+	}
+	return name
+}
+
 func (r *Registry) watchLoop() {
 	for {
 		select {
@@ -93,8 +167,9 @@ func (r *Registry) watchLoop() {
 				return // notifications channel closed
 			}
 			switch notif.Type {
-			case "sessions-changed", "window-renamed":
+			case "sessions-changed", "session-renamed", "window-renamed":
 				// sessions-changed: session created/destroyed
+				// session-renamed: same agent under a new name
 				// window-renamed: agent set terminal title (e.g., Claude Code → "2.1.42")
 				if err := r.scan(); err != nil {
 					log.Printf("agent scan error: %v", err)
@@ -104,6 +179,93 @@ func (r *Registry) watchLoop() {
 	}
 }
 
+// watchProcesses demotes agents whose process exits between scans.
+func (r *Registry) watchProcesses() {
+	ticker := time.NewTicker(processCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case <-ticker.C:
+			r.checkProcesses()
+		}
+	}
+}
+
+// rescanLoop rescans when a session that had no agent starts running a
+// different command, the sign of an agent launched from its shell.
+func (r *Registry) rescanLoop() {
+	if r.rescanInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.rescanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case <-ticker.C:
+			if !r.idleChanged() {
+				continue
+			}
+			if err := r.scan(); err != nil {
+				log.Printf("agent rescan error: %v", err)
+			}
+		}
+	}
+}
+
+// idleChanged reports whether any session without an agent at the last scan
+// now runs a different foreground command. It costs one tmux command per
+// such session, instead of a full scan's process-tree walks.
+func (r *Registry) idleChanged() bool {
+	r.mu.RLock()
+	idle := maps.Clone(r.idle)
+	r.mu.RUnlock()
+	for name, command := range idle {
+		pane, err := r.ctrl.GetPaneInfo(name)
+		if err != nil {
+			continue // closed sessions are reported by sessions-changed
+		}
+		if pane.Command != command {
+			return true
+		}
+	}
+	return false
+}
+
+// checkProcesses removes agents whose recorded process is gone.
+func (r *Registry) checkProcesses() {
+	r.mu.RLock()
+	pids := make(map[string]int, len(r.pids))
+	for name, pid := range r.pids {
+		pids[name] = pid
+	}
+	r.mu.RUnlock()
+
+	var pendingEvents []RegistryEvent
+	for name, pid := range pids {
+		if processAlive(pid) {
+			continue
+		}
+		r.mu.Lock()
+		agent, ok := r.agents[name]
+		if ok && r.pids[name] == pid {
+			delete(r.agents, name)
+			delete(r.pids, name)
+			r.exited[name] = true
+			r.idle[name] = "" // whatever runs now differs, so the next rescan checks it
+			pendingEvents = append(pendingEvents, RegistryEvent{Type: "removed", Agent: agent, Reason: ReasonProcessExited})
+			log.Printf("agent %s: process %d exited", name, pid)
+		}
+		r.mu.Unlock()
+	}
+	for _, event := range pendingEvents {
+		r.events <- event
+	}
+}
+
 func (r *Registry) scan() error {
 	sessions, err := r.ctrl.ListSessions()
 	if err != nil {
@@ -112,9 +274,12 @@ func (r *Registry) scan() error {
 
 	// Build new agent map from current tmux state
 	discovered := make(map[string]Agent)
+	pids := make(map[string]int)
+	agentless := make(map[string]string) // live gastown sessions whose pane runs no agent -> pane command
 
 	for _, sess := range sessions {
-		if !IsGastownSession(sess.Name) {
+		base := sessionBase(sess.Name)
+		if !IsGastownSession(base) {
 			continue
 		}
 
@@ -144,26 +309,39 @@ func (r *Registry) scan() error {
 		// 2. Shell wrapping agent → check descendants
 		// 3. Unrecognized command (version-as-argv[0]) → check binary, then descendants
 		alive := false
+		agentPID := ""
 		if IsAgentProcess(pane.Command, processNames) {
 			alive = true
+			agentPID = pane.PID
 		} else if IsShell(pane.Command) && pane.PID != "" {
-			alive = CheckDescendants(pane.PID, processNames)
+			agentPID = FindDescendant(pane.PID, processNames)
+			alive = agentPID != ""
 		} else if pane.PID != "" {
-			alive = CheckProcessBinary(pane.PID, processNames) || CheckDescendants(pane.PID, processNames)
+			if CheckProcessBinary(pane.PID, processNames) {
+				agentPID = pane.PID
+			} else {
+				agentPID = FindDescendant(pane.PID, processNames)
+			}
+			alive = agentPID != ""
 		}
 
 		if !alive {
+			agentless[sess.Name] = pane.Command
 			continue
 		}
+		// Only watch a PID seen running, so a stale one can't demote the agent.
+		if pid, err := strconv.Atoi(agentPID); err == nil && pid > 0 && processAlive(pid) {
+			pids[sess.Name] = pid
+		}
 
 		// Validate workDir against gtDir if set
-		if r.gtDir != "" && !strings.HasPrefix(pane.WorkDir, r.gtDir) {
+		if r.gtDir != "" && !withinDir(pane.WorkDir, r.gtDir) {
 			// This session's working directory doesn't belong to our gastown instance
 			continue
 		}
 
 		// Determine role and rig from session name (env vars override if available)
-		role, rig := ParseSessionName(sess.Name)
+		role, rig := ParseSessionName(base)
 		if agentRole != "" {
 			role = agentRole
 		}
@@ -183,34 +361,60 @@ func (r *Registry) scan() error {
 		}
 
 		discovered[sess.Name] = Agent{
-			Name:     sess.Name,
-			Role:     role,
-			Runtime:  runtime,
-			Rig:      rigPtr,
-			WorkDir:  pane.WorkDir,
-			Attached: sess.Attached,
+			Name:      sess.Name,
+			Role:      role,
+			Runtime:   runtime,
+			Rig:       rigPtr,
+			WorkDir:   pane.WorkDir,
+			Attached:  sess.Attached,
+			SessionID: sess.ID,
+			Project:   ProjectRoot(pane.WorkDir),
 		}
 	}
 
 	// Diff against known agents
 	r.mu.Lock()
 	var pendingEvents []RegistryEvent
+	renamed := r.renamesLocked(discovered)
 
 	// Find removed agents
 	for name, oldAgent := range r.agents {
 		if _, exists := discovered[name]; !exists {
+			if _, moved := renamed[name]; moved {
+				continue
+			}
 			delete(r.agents, name)
-			pendingEvents = append(pendingEvents, RegistryEvent{Type: "removed", Agent: oldAgent})
+			event := RegistryEvent{Type: "removed", Agent: oldAgent}
+			if _, idle := agentless[name]; idle {
+				event.Reason = ReasonProcessExited
+				r.exited[name] = true
+			}
+			pendingEvents = append(pendingEvents, event)
+		}
+	}
+	r.pids = pids
+	r.idle = agentless
+	for name := range r.exited {
+		if _, idle := agentless[name]; !idle {
+			delete(r.exited, name)
 		}
 	}
 
+	// Move renamed agents to their new names
+	for oldName, newName := range renamed {
+		delete(r.agents, oldName)
+		r.agents[newName] = discovered[newName]
+		pendingEvents = append(pendingEvents, RegistryEvent{Type: "renamed", Agent: discovered[newName], OldName: oldName})
+		log.Printf("agent %s renamed to %s", oldName, newName)
+	}
+
 	// Find added and updated agents
 	for name, newAgent := range discovered {
 		oldAgent, existed := r.agents[name]
 		if !existed {
 			r.agents[name] = newAgent
 			pendingEvents = append(pendingEvents, RegistryEvent{Type: "added", Agent: newAgent})
-		} else if oldAgent.Attached != newAgent.Attached {
+		} else if oldAgent.Attached != newAgent.Attached || oldAgent.Project != newAgent.Project {
 			r.agents[name] = newAgent
 			pendingEvents = append(pendingEvents, RegistryEvent{Type: "updated", Agent: newAgent})
 		}
@@ -224,3 +428,30 @@ func (r *Registry) scan() error {
 
 	return nil
 }
+
+// renamesLocked matches agents whose session was renamed since the last
+// scan by tmux session ID, returning old name → new name. A session whose
+// old name is still live isn't a rename. The caller must hold r.mu.
+func (r *Registry) renamesLocked(discovered map[string]Agent) map[string]string {
+	byID := make(map[string]string, len(r.agents))
+	for name, a := range r.agents {
+		if a.SessionID != "" {
+			byID[a.SessionID] = name
+		}
+	}
+	renamed := make(map[string]string)
+	for name, a := range discovered {
+		oldName, ok := byID[a.SessionID]
+		if !ok || a.SessionID == "" || oldName == name {
+			continue
+		}
+		if _, still := discovered[oldName]; still {
+			continue
+		}
+		if _, taken := r.agents[name]; taken {
+			continue
+		}
+		renamed[oldName] = name
+	}
+	return renamed
+}
@@ -1,6 +1,8 @@
 package agents
 
 import (
+	"os/exec"
+	"strconv"
 	"testing"
 
 	"github.com/gastownhall/tmux-adapter/internal/tmux"
@@ -164,6 +166,40 @@ func TestScanDetectsAgents(t *testing.T) {
 	}
 }
 
+func TestScanParsesOtherUsersSessions(t *testing.T) {
+	mock := newMockControl()
+	mock.sessions = []tmux.SessionInfo{
+		{Name: "alice:gt-myrig-refinery"},
+		{Name: "alice:hello_gastown/crew/bob"},
+		{Name: "hello_gastown/crew/carol"},
+		{Name: "alice/crew/bob"}, // a local project named alice, not the peer
+	}
+	for _, s := range mock.sessions {
+		mock.panes[s.Name] = tmux.PaneInfo{Command: "claude", PID: "100", WorkDir: "/tmp/gt/work"}
+	}
+
+	r := NewRegistry(mock, "/tmp/gt", nil)
+	if err := r.scan(); err != nil {
+		t.Fatalf("scan() error: %v", err)
+	}
+
+	want := map[string][2]string{ // name → role, rig
+		"alice:gt-myrig-refinery":      {"refinery", "myrig"},
+		"alice:hello_gastown/crew/bob": {"crew", "hello_gastown"},
+		"hello_gastown/crew/carol":     {"crew", "hello_gastown"},
+		"alice/crew/bob":               {"crew", "alice"},
+	}
+	for name, rr := range want {
+		a, ok := r.GetAgent(name)
+		if !ok {
+			t.Fatalf("agent %q not found", name)
+		}
+		if a.Role != rr[0] || a.Rig == nil || *a.Rig != rr[1] {
+			t.Fatalf("agent %q: role %q rig %v, want %q %q", name, a.Role, a.Rig, rr[0], rr[1])
+		}
+	}
+}
+
 func TestScanAgentRemoved(t *testing.T) {
 	mock := newMockControl()
 	mock.sessions = []tmux.SessionInfo{
@@ -325,6 +361,34 @@ func TestScanGtDirFilters(t *testing.T) {
 	}
 }
 
+func TestScanGtDirRequiresPathBoundary(t *testing.T) {
+	mock := newMockControl()
+	mock.sessions = []tmux.SessionInfo{
+		{Name: "hq-witness", Attached: false},
+		{Name: "hq-mayor", Attached: false},
+	}
+	mock.panes["hq-witness"] = tmux.PaneInfo{
+		Command: "claude",
+		PID:     "100",
+		WorkDir: "/tmp/gt-other/work", // shares the gtDir prefix but is outside it
+	}
+	mock.panes["hq-mayor"] = tmux.PaneInfo{
+		Command: "claude",
+		PID:     "101",
+		WorkDir: "/tmp/gt",
+	}
+
+	r := NewRegistry(mock, "/tmp/gt/", nil)
+	if err := r.scan(); err != nil {
+		t.Fatalf("scan() error: %v", err)
+	}
+
+	agents := r.GetAgents()
+	if len(agents) != 1 || agents[0].Name != "hq-mayor" {
+		t.Fatalf("agents = %+v, want only hq-mayor", agents)
+	}
+}
+
 func TestGetAgent(t *testing.T) {
 	mock := newMockControl()
 	mock.sessions = []tmux.SessionInfo{
@@ -465,3 +529,142 @@ func TestWatchLoopStopsOnClosedChannel(t *testing.T) {
 	// for goroutine exit. The key correctness property is tested by the fact that
 	// this test completes without spinning.
 }
+
+func TestProcessExitDemotesAgent(t *testing.T) {
+	proc := exec.Command("sleep", "30")
+	if err := proc.Start(); err != nil {
+		t.Skipf("sleep unavailable: %v", err)
+	}
+	mock := newMockControl()
+	mock.sessions = []tmux.SessionInfo{{Name: "hq-mayor"}}
+	mock.panes["hq-mayor"] = tmux.PaneInfo{Command: "claude", PID: strconv.Itoa(proc.Process.Pid), WorkDir: "/tmp/gt/work"}
+	r := NewRegistry(mock, "/tmp/gt", nil)
+	if err := r.scan(); err != nil {
+		t.Fatal(err)
+	}
+	drainEvents(r)
+
+	r.checkProcesses()
+	if _, ok := r.GetAgent("hq-mayor"); !ok {
+		t.Fatal("agent demoted while its process runs")
+	}
+
+	_ = proc.Process.Kill()
+	_ = proc.Wait()
+	r.checkProcesses()
+	events := drainEvents(r)
+	if len(events) != 1 || events[0].Type != "removed" || events[0].Reason != ReasonProcessExited {
+		t.Fatalf("events = %+v, want one removed with reason %s", events, ReasonProcessExited)
+	}
+	if _, ok := r.GetAgent("hq-mayor"); ok || !r.Exited("hq-mayor") {
+		t.Fatal("agent not demoted to exited after its process ended")
+	}
+
+	// The pane falls back to a shell: still exited, and no second event.
+	mock.panes["hq-mayor"] = tmux.PaneInfo{Command: "zsh", WorkDir: "/tmp/gt/work"}
+	if err := r.scan(); err != nil {
+		t.Fatal(err)
+	}
+	if events := drainEvents(r); len(events) != 0 || !r.Exited("hq-mayor") {
+		t.Fatalf("after rescan: events = %+v, exited = %v", events, r.Exited("hq-mayor"))
+	}
+
+	mock.sessions = nil
+	if err := r.scan(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Exited("hq-mayor") {
+		t.Fatal("exited mark outlived the session")
+	}
+}
+
+func TestScanReportsAgentExitInLiveSession(t *testing.T) {
+	mock := newMockControl()
+	mock.sessions = []tmux.SessionInfo{{Name: "hq-mayor"}}
+	mock.panes["hq-mayor"] = tmux.PaneInfo{Command: "claude", WorkDir: "/tmp/gt/work"}
+	r := NewRegistry(mock, "/tmp/gt", nil)
+	if err := r.scan(); err != nil {
+		t.Fatal(err)
+	}
+	drainEvents(r)
+
+	mock.panes["hq-mayor"] = tmux.PaneInfo{Command: "zsh", WorkDir: "/tmp/gt/work"}
+	if err := r.scan(); err != nil {
+		t.Fatal(err)
+	}
+	events := drainEvents(r)
+	if len(events) != 1 || events[0].Reason != ReasonProcessExited || !r.Exited("hq-mayor") {
+		t.Fatalf("events = %+v, want removed with reason %s", events, ReasonProcessExited)
+	}
+}
+
+func TestRescanFindsAgentStartedInIdleSession(t *testing.T) {
+	mock := newMockControl()
+	mock.sessions = []tmux.SessionInfo{{Name: "hq-mayor"}}
+	mock.panes["hq-mayor"] = tmux.PaneInfo{Command: "zsh", WorkDir: "/tmp/gt/work"}
+	r := NewRegistry(mock, "/tmp/gt", nil)
+	if err := r.scan(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.GetAgents()) != 0 {
+		t.Fatal("shell session listed as an agent")
+	}
+	if r.idleChanged() {
+		t.Fatal("idleChanged() = true with nothing started")
+	}
+
+	mock.panes["hq-mayor"] = tmux.PaneInfo{Command: "claude", WorkDir: "/tmp/gt/work"}
+	if !r.idleChanged() {
+		t.Fatal("idleChanged() = false after claude started in the session")
+	}
+	if err := r.scan(); err != nil {
+		t.Fatal(err)
+	}
+	if events := drainEvents(r); len(events) != 1 || events[0].Type != "added" {
+		t.Fatalf("events = %+v, want one added", events)
+	}
+	if r.idleChanged() {
+		t.Fatal("idleChanged() = true once the agent is known")
+	}
+}
+
+func TestScanRenamedSessionKeepsAgent(t *testing.T) {
+	mock := newMockControl()
+	mock.sessions = []tmux.SessionInfo{{Name: "gt-gastown-crew-max", ID: "$3"}}
+	pane := tmux.PaneInfo{Command: "claude", PID: "12345", WorkDir: "/tmp/gt/work"}
+	mock.panes["gt-gastown-crew-max"] = pane
+	r := NewRegistry(mock, "", nil)
+	if err := r.scan(); err != nil {
+		t.Fatalf("scan() error: %v", err)
+	}
+	drainEvents(r)
+
+	mock.sessions = []tmux.SessionInfo{{Name: "gt-gastown-crew-maxine", ID: "$3"}}
+	mock.panes["gt-gastown-crew-maxine"] = pane
+	if err := r.scan(); err != nil {
+		t.Fatalf("scan() error: %v", err)
+	}
+	events := drainEvents(r)
+	if len(events) != 1 || events[0].Type != "renamed" {
+		t.Fatalf("events = %+v, want one renamed", events)
+	}
+	if events[0].OldName != "gt-gastown-crew-max" || events[0].Agent.Name != "gt-gastown-crew-maxine" {
+		t.Fatalf("renamed event = %+v", events[0])
+	}
+	if _, ok := r.GetAgent("gt-gastown-crew-max"); ok {
+		t.Fatal("old name still registered")
+	}
+	if a, ok := r.GetAgent("gt-gastown-crew-maxine"); !ok || a.SessionID != "$3" {
+		t.Fatalf("GetAgent(new) = %+v, %t", a, ok)
+	}
+
+	// A different session taking the old name is a new agent, not a rename.
+	mock.sessions = []tmux.SessionInfo{{Name: "gt-gastown-crew-maxine", ID: "$3"}, {Name: "gt-gastown-crew-max", ID: "$4"}}
+	mock.panes["gt-gastown-crew-max"] = pane
+	if err := r.scan(); err != nil {
+		t.Fatalf("scan() error: %v", err)
+	}
+	if events := drainEvents(r); len(events) != 1 || events[0].Type != "added" {
+		t.Fatalf("events = %+v, want one added", events)
+	}
+}
@@ -0,0 +1,115 @@
+package conv
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/store"
+)
+
+// Errors returned by Annotate.
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrAnnotationDropped    = errors.New("annotation dropped by the event pipeline")
+)
+
+// Annotate adds a note from author to a conversation's timeline. The
+// annotation event goes through the middleware pipeline and reaches
+// subscribers like a parsed event. With a store it is also persisted, and
+// replayed in timestamp order when the conversation is next read from disk.
+func (w *ConversationWatcher) Annotate(conversationID, author, text string) (ConversationEvent, error) {
+	conversationID = CanonicalConversationID(conversationID)
+	w.mu.RLock()
+	stream, ok := w.streams[conversationID]
+	w.mu.RUnlock()
+	if !ok {
+		return ConversationEvent{}, ErrConversationNotFound
+	}
+
+	a := store.Annotation{
+		ID:             newAnnotationID(),
+		ConversationID: conversationID,
+		Author:         author,
+		Text:           text,
+		CreatedAt:      time.Now(),
+	}
+	event, keep := w.pipeline.Process(annotationEvent(a, stream.agent.Name, stream.agent.Runtime))
+	if !keep {
+		return ConversationEvent{}, ErrAnnotationDropped
+	}
+	if w.store != nil {
+		// Store what middleware left of the text, so redactions persist.
+		a.Text = annotationText(event)
+		if err := w.store.AddAnnotation(a); err != nil {
+			return ConversationEvent{}, fmt.Errorf("store annotation: %w", err)
+		}
+	}
+	return w.appendEvent(stream, event), nil
+}
+
+// appendEvent buffers an event that didn't come from a parsed line and
+// delivers it to subscribers, returning it with its seq.
+func (w *ConversationWatcher) appendEvent(stream *conversationStream, event ConversationEvent) ConversationEvent {
+	event.Seq = stream.buffer.Append(event)
+	w.emitEvent(WatcherEvent{Type: "conversation-event", Event: &event})
+	return event
+}
+
+// loadAnnotations returns a conversation's stored annotations as events,
+// for replay while its history is read.
+func (w *ConversationWatcher) loadAnnotations(conversationID, agentName, runtime string) []ConversationEvent {
+	if w.store == nil {
+		return nil
+	}
+	list, err := w.store.Annotations(conversationID)
+	if err != nil {
+		log.Printf("watcher: load annotations for %s: %v", conversationID, err)
+		return nil
+	}
+	events := make([]ConversationEvent, 0, len(list))
+	for _, a := range list {
+		events = append(events, annotationEvent(a, agentName, runtime))
+	}
+	return events
+}
+
+// replayAnnotations delivers the pending annotations made before t, or all
+// of them when t is zero. The caller must hold fs.mu.
+func (w *ConversationWatcher) replayAnnotations(stream *conversationStream, fs *fileStream, t time.Time) {
+	for len(fs.annotations) > 0 && (t.IsZero() || fs.annotations[0].Timestamp.Before(t)) {
+		w.appendEvent(stream, fs.annotations[0])
+		fs.annotations = fs.annotations[1:]
+	}
+}
+
+func annotationEvent(a store.Annotation, agentName, runtime string) ConversationEvent {
+	return ConversationEvent{
+		EventID:        a.ID,
+		StableID:       "annotation:" + a.ID,
+		Type:           EventAnnotation,
+		AgentName:      agentName,
+		ConversationID: a.ConversationID,
+		Timestamp:      a.CreatedAt,
+		ReceivedAt:     a.CreatedAt,
+		Content:        []ContentBlock{{Type: "text", Text: a.Text}},
+		Runtime:        runtime,
+		Metadata:       map[string]any{"author": a.Author},
+	}
+}
+
+func annotationText(e ConversationEvent) string {
+	if len(e.Content) == 0 {
+		return ""
+	}
+	return e.Content[0].Text
+}
+
+func newAnnotationID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "annotation-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,98 @@
+package conv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchClaudeLines is a representative mix of Claude JSONL lines.
+var benchClaudeLines = [][]byte{
+	[]byte(`{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"run the test suite and fix whatever fails"}]}}`),
+	[]byte(`{"type":"assistant","uuid":"a1","requestId":"req1","timestamp":"2026-02-14T01:45:00.362Z","message":{"model":"claude-opus-4-6","role":"assistant","content":[{"type":"thinking","thinking":"Let me look at the failing tests first."},{"type":"text","text":"I'll start by running the tests."}],"usage":{"input_tokens":1200,"output_tokens":80,"cache_read_input_tokens":900,"cache_creation_input_tokens":40}}}`),
+	[]byte(`{"type":"assistant","uuid":"a2","requestId":"req1","timestamp":"2026-02-14T01:45:01.100Z","message":{"model":"claude-opus-4-6","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"go test ./...","description":"Run tests"}}]}}`),
+	[]byte(`{"type":"user","uuid":"u2","timestamp":"2026-02-14T01:45:09.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"ok  \tgithub.com/example/pkg\t0.412s\nFAIL\tgithub.com/example/other\t0.101s","is_error":false}]}}`),
+}
+
+func BenchmarkClaudeParser(b *testing.B) {
+	parser := NewClaudeParser("bench-agent", "claude:abc")
+	var bytes int64
+	for _, l := range benchClaudeLines {
+		bytes += int64(len(l))
+	}
+	b.SetBytes(bytes / int64(len(benchClaudeLines)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.Parse(benchClaudeLines[i%len(benchClaudeLines)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "lines/s")
+}
+
+func BenchmarkBufferFanout(b *testing.B) {
+	// Subscribers that fall behind are logged per dropped event.
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, n := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("subscribers=%d", n), func(b *testing.B) {
+			// Small cap so the run reaches steady-state eviction, which copies the whole buffer.
+			buf := NewConversationBuffer("bench-conv", "bench-agent", 1000)
+			var wg sync.WaitGroup
+			var ids []int
+			for i := 0; i < n; i++ {
+				_, id, live := buf.Subscribe(EventFilter{})
+				ids = append(ids, id)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range live {
+					}
+				}()
+			}
+			event := makeEvent(EventAssistant)
+			event.Content = []ContentBlock{{Type: "text", Text: "benchmark payload"}}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				buf.Append(event)
+			}
+			b.StopTimer()
+			for _, id := range ids {
+				buf.Unsubscribe(id)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func BenchmarkEventJSONEncoding(b *testing.B) {
+	parser := NewClaudeParser("bench-agent", "claude:abc")
+	var events []ConversationEvent
+	for _, l := range benchClaudeLines {
+		parsed, err := parser.Parse(l)
+		if err != nil {
+			b.Fatal(err)
+		}
+		events = append(events, parsed...)
+	}
+	for i := range events {
+		events[i].Timestamp = time.Date(2026, 2, 14, 1, 45, 0, 0, time.UTC)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(events[i%len(events)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
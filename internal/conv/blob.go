@@ -0,0 +1,163 @@
+package conv
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MaxInlineImageBytes is the largest image kept inline in an event as
+// base64. Larger ones move to the blob store, leaving a BlobID that clients
+// fetch with get-blob, so screenshots don't bloat every snapshot.
+const MaxInlineImageBytes = 64 << 10
+
+// DefaultBlobMemoryBytes bounds the blobs held in memory.
+const DefaultBlobMemoryBytes = 64 << 20
+
+// ErrBlobNotFound is returned for a blob ID the store doesn't hold.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore holds content-addressed binary data taken out of events: the
+// most recently used blobs in memory, and with a directory every blob on
+// disk as well, so references in snapshots survive restarts.
+type BlobStore struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	bytes int64
+	lru   *list.List               // of *blobEntry, most recent first
+	byID  map[string]*list.Element // blob ID → lru element
+}
+
+type blobEntry struct {
+	id   string
+	data []byte
+}
+
+// NewBlobStore creates a store keeping up to maxBytes in memory, and every
+// blob under dir unless dir is empty.
+func NewBlobStore(dir string, maxBytes int64) *BlobStore {
+	return &BlobStore{dir: dir, maxBytes: maxBytes, lru: list.New(), byID: make(map[string]*list.Element)}
+}
+
+// Put stores data and returns its ID, the hex SHA-256 of data.
+func (s *BlobStore) Put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+	if s.dir != "" {
+		path := filepath.Join(s.dir, id)
+		if _, err := os.Stat(path); err != nil {
+			if err := s.write(path, data); err != nil {
+				return "", err
+			}
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remember(id, data)
+	return id, nil
+}
+
+// write saves a blob under a temporary name first, so a crash can't leave
+// a truncated file behind under the blob's ID.
+func (s *BlobStore) write(path string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".blob-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get returns a blob's data.
+func (s *BlobStore) Get(id string) ([]byte, error) {
+	if _, err := hex.DecodeString(id); err != nil || len(id) != sha256.Size*2 {
+		return nil, ErrBlobNotFound
+	}
+	s.mu.Lock()
+	if el, ok := s.byID[id]; ok {
+		s.lru.MoveToFront(el)
+		data := el.Value.(*blobEntry).data
+		s.mu.Unlock()
+		return data, nil
+	}
+	s.mu.Unlock()
+	if s.dir == "" {
+		return nil, ErrBlobNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.remember(id, data)
+	s.mu.Unlock()
+	return data, nil
+}
+
+// remember caches a blob in memory, evicting the least recently used ones
+// past maxBytes. The caller must hold s.mu.
+func (s *BlobStore) remember(id string, data []byte) {
+	if el, ok := s.byID[id]; ok {
+		s.lru.MoveToFront(el)
+		return
+	}
+	if int64(len(data)) > s.maxBytes {
+		return
+	}
+	s.byID[id] = s.lru.PushFront(&blobEntry{id: id, data: data})
+	s.bytes += int64(len(data))
+	for s.bytes > s.maxBytes {
+		el := s.lru.Back()
+		e := el.Value.(*blobEntry)
+		s.lru.Remove(el)
+		delete(s.byID, e.id)
+		s.bytes -= int64(len(e.data))
+	}
+}
+
+// externalize moves image data larger than MaxInlineImageBytes out of the
+// event into the store, replacing it with a BlobID. A nil store leaves
+// images inline.
+func (s *BlobStore) externalize(e *ConversationEvent) error {
+	if s == nil {
+		return nil
+	}
+	for i := range e.Content {
+		b := &e.Content[i]
+		// Base64 is longer than what it encodes, so shorter data fits.
+		if b.Type != "image" || len(b.Data) <= MaxInlineImageBytes {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(b.Data)
+		if err != nil || len(data) <= MaxInlineImageBytes {
+			continue // left inline; a malformed image is the client's to reject
+		}
+		id, err := s.Put(data)
+		if err != nil {
+			return err
+		}
+		b.Data, b.BlobID = "", id
+		setBlockMeta(b, "bytes", len(data))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package conv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestBlobStoreExternalizesLargeImages(t *testing.T) {
+	dir := t.TempDir()
+	s := NewBlobStore(dir, DefaultBlobMemoryBytes)
+	large := bytes.Repeat([]byte{0x89}, MaxInlineImageBytes+1)
+	small := base64.StdEncoding.EncodeToString([]byte("tiny"))
+	e := ConversationEvent{Content: []ContentBlock{
+		{Type: "image", MimeType: "image/png", Data: base64.StdEncoding.EncodeToString(large)},
+		{Type: "image", MimeType: "image/png", Data: small},
+	}}
+	if err := s.externalize(&e); err != nil {
+		t.Fatal(err)
+	}
+	big := e.Content[0]
+	if big.Data != "" || big.BlobID == "" || big.Metadata["bytes"] != len(large) {
+		t.Fatalf("large image = %+v, want a blob reference", big)
+	}
+	if e.Content[1].Data != small || e.Content[1].BlobID != "" {
+		t.Fatalf("small image = %+v, want it inline", e.Content[1])
+	}
+
+	// A fresh store over the same directory still serves it.
+	got, err := NewBlobStore(dir, DefaultBlobMemoryBytes).Get(big.BlobID)
+	if err != nil || !bytes.Equal(got, large) {
+		t.Fatalf("Get after restart = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestBlobStoreEvictsFromMemory(t *testing.T) {
+	s := NewBlobStore("", 10)
+	a, _ := s.Put([]byte("aaaaaa"))
+	b, _ := s.Put([]byte("bbbbbb"))
+	if _, err := s.Get(a); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("Get(evicted) error = %v, want ErrBlobNotFound", err)
+	}
+	if got, err := s.Get(b); err != nil || string(got) != "bbbbbb" {
+		t.Fatalf("Get(b) = %q, %v", got, err)
+	}
+	if _, err := s.Get("../../etc/passwd"); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("Get(path) error = %v, want ErrBlobNotFound", err)
+	}
+}
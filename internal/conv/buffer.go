@@ -1,8 +1,10 @@
 package conv
 
 import (
+	"encoding/json"
 	"log"
 	"sync"
+	"time"
 )
 
 // bufferSub holds a subscriber's channel and filter.
@@ -18,9 +20,12 @@ type ConversationBuffer struct {
 	events         []ConversationEvent
 	maxSize        int
 	nextSeq        int64
+	bytes          int64      // estimated size of events, see eventBytes
 	mu             sync.Mutex // Must be full Lock (not RLock) for gap-free snapshot+subscribe
 	subs           map[int]bufferSub
 	nextSubID      int
+	lastEventAt    time.Time
+	lastPromptAt   time.Time
 }
 
 // NewConversationBuffer creates a buffer for a specific conversation.
@@ -34,24 +39,78 @@ func NewConversationBuffer(conversationID, agentName string, maxSize int) *Conve
 	}
 }
 
-// Append adds an event to the buffer and broadcasts to subscribers.
-func (b *ConversationBuffer) Append(event ConversationEvent) {
+// AgentName returns the agent whose conversation this buffer holds.
+func (b *ConversationBuffer) AgentName() string {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	return b.agentName
+}
+
+// setAgentName records the agent's new name after its session was renamed.
+func (b *ConversationBuffer) setAgentName(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.agentName = name
+}
 
+// Append adds an event to the buffer, broadcasts it to subscribers, and
+// returns the seq it was assigned.
+func (b *ConversationBuffer) Append(event ConversationEvent) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.appendLocked(event).Seq
+}
+
+func (b *ConversationBuffer) appendLocked(event ConversationEvent) ConversationEvent {
 	event.Seq = b.nextSeq
 	b.nextSeq++
+	b.noteActivity(event)
 
 	// Evict oldest if at capacity
 	if len(b.events) >= b.maxSize {
+		b.bytes -= eventBytes(b.events[0])
 		// Copy to new backing array so old references don't pin memory
 		newEvents := make([]ConversationEvent, len(b.events)-1, b.maxSize)
 		copy(newEvents, b.events[1:])
 		b.events = newEvents
 	}
 	b.events = append(b.events, event)
+	b.bytes += eventBytes(event)
+	b.broadcastLocked(event)
+	return event
+}
 
-	// Broadcast to subscribers (non-blocking)
+// maxUpdateLookback bounds how far back Update looks for the event being
+// updated. Updates follow their event closely; an older one is appended.
+const maxUpdateLookback = 4096
+
+// Update applies event, a Delta or Replace of one already buffered under
+// the same EventID, in place: the buffered event keeps its seq, counts the
+// update in Updates, and is broadcast again in its new version. If the
+// original is no longer buffered, event is appended instead and updated is
+// false. Update returns the event as buffered.
+func (b *ConversationBuffer) Update(event ConversationEvent) (buffered ConversationEvent, updated bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := len(b.events) - 1; i >= max(0, len(b.events)-maxUpdateLookback); i-- {
+		if b.events[i].EventID != event.EventID || event.EventID == "" {
+			continue
+		}
+		merged := mergeUpdate(b.events[i], event)
+		b.bytes += eventBytes(merged) - eventBytes(b.events[i])
+		b.events[i] = merged
+		b.noteActivity(event)
+		b.broadcastLocked(merged)
+		return merged, true
+	}
+	event.Delta, event.Replace = false, false
+	return b.appendLocked(event), false
+}
+
+// broadcastLocked sends event to matching subscribers without blocking. The
+// caller must hold b.mu.
+func (b *ConversationBuffer) broadcastLocked(event ConversationEvent) {
 	for _, sub := range b.subs {
 		if sub.filter.Matches(event) {
 			select {
@@ -63,6 +122,28 @@ func (b *ConversationBuffer) Append(event ConversationEvent) {
 	}
 }
 
+// Event returns the buffered event with the given seq.
+func (b *ConversationBuffer) Event(seq int64) (ConversationEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.events) == 0 {
+		return ConversationEvent{}, false
+	}
+	i := seq - b.events[0].Seq
+	if i < 0 || i >= int64(len(b.events)) {
+		return ConversationEvent{}, false
+	}
+	return b.events[i], true
+}
+
+// LastSeq returns the seq of the most recently appended event, or -1 if
+// nothing has been appended.
+func (b *ConversationBuffer) LastSeq() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nextSeq - 1
+}
+
 // Snapshot returns all buffered events, optionally filtered.
 func (b *ConversationBuffer) Snapshot(filter EventFilter) []ConversationEvent {
 	b.mu.Lock()
@@ -71,6 +152,17 @@ func (b *ConversationBuffer) Snapshot(filter EventFilter) []ConversationEvent {
 	return b.snapshotLocked(filter)
 }
 
+// Range calls fn with each buffered event, oldest first, without copying
+// them out. It holds the buffer's lock, so fn must be quick and must not
+// call back into the buffer.
+func (b *ConversationBuffer) Range(fn func(e *ConversationEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.events {
+		fn(&b.events[i])
+	}
+}
+
 func (b *ConversationBuffer) snapshotLocked(filter EventFilter) []ConversationEvent {
 	result := make([]ConversationEvent, 0, len(b.events))
 	for _, e := range b.events {
@@ -107,6 +199,157 @@ func (b *ConversationBuffer) Unsubscribe(subID int) {
 	}
 }
 
+// Export returns a copy of the buffered events and the next sequence number,
+// for persisting the buffer across restarts.
+func (b *ConversationBuffer) Export() ([]ConversationEvent, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]ConversationEvent(nil), b.events...), b.nextSeq
+}
+
+// Restore replaces the buffer contents with previously exported events.
+// Sequence numbering continues from nextSeq so client cursors stay valid.
+func (b *ConversationBuffer) Restore(events []ConversationEvent, nextSeq int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(events) > b.maxSize {
+		events = events[len(events)-b.maxSize:]
+	}
+	b.events = append(make([]ConversationEvent, 0, max(len(events), 256)), events...)
+	b.nextSeq = nextSeq
+	b.lastEventAt, b.lastPromptAt = time.Time{}, time.Time{}
+	b.bytes = 0
+	for _, event := range b.events {
+		b.noteActivity(event)
+		b.bytes += eventBytes(event)
+	}
+}
+
+// noteActivity records when event happened for Activity. The caller must
+// hold b.mu.
+func (b *ConversationBuffer) noteActivity(event ConversationEvent) {
+	if event.Timestamp.After(b.lastEventAt) {
+		b.lastEventAt = event.Timestamp
+	}
+	if event.Type == EventUser && event.SubagentID == "" && event.Timestamp.After(b.lastPromptAt) {
+		b.lastPromptAt = event.Timestamp
+	}
+}
+
+// Activity summarizes how recently a conversation changed. EventCount counts
+// every event appended, including ones since evicted.
+type Activity struct {
+	LastEventAt      *time.Time `json:"lastEventAt,omitempty"`
+	LastUserPromptAt *time.Time `json:"lastUserPromptAt,omitempty"`
+	EventCount       int64      `json:"eventCount"`
+}
+
+// Activity returns the buffer's activity summary.
+func (b *ConversationBuffer) Activity() Activity {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a := Activity{EventCount: b.nextSeq}
+	if !b.lastEventAt.IsZero() {
+		t := b.lastEventAt
+		a.LastEventAt = &t
+	}
+	if !b.lastPromptAt.IsZero() {
+		t := b.lastPromptAt
+		a.LastUserPromptAt = &t
+	}
+	return a
+}
+
+// BufferStats reports a buffer's occupancy for introspection. Bytes is an
+// estimate of the memory its events hold; MinSeq and MaxSeq are -1 while it
+// is empty.
+type BufferStats struct {
+	Events      int   `json:"events"`
+	Capacity    int   `json:"capacity"`
+	Bytes       int64 `json:"bytes"`
+	MinSeq      int64 `json:"minSeq"`
+	MaxSeq      int64 `json:"maxSeq"`
+	NextSeq     int64 `json:"nextSeq"`
+	Subscribers int   `json:"subscribers"`
+}
+
+// Stats returns the buffer's current occupancy and subscriber count.
+func (b *ConversationBuffer) Stats() BufferStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := BufferStats{
+		Events:      len(b.events),
+		Capacity:    b.maxSize,
+		Bytes:       b.bytes,
+		MinSeq:      -1,
+		MaxSeq:      -1,
+		NextSeq:     b.nextSeq,
+		Subscribers: len(b.subs),
+	}
+	if len(b.events) > 0 {
+		stats.MinSeq, stats.MaxSeq = b.events[0].Seq, b.events[len(b.events)-1].Seq
+	}
+	return stats
+}
+
+// eventOverhead approximates the fixed size of a ConversationEvent and of a
+// ContentBlock, before the strings and metadata they point to.
+const (
+	eventOverhead = 512
+	blockOverhead = 256
+)
+
+// eventBytes estimates the memory held by e. It counts string and raw JSON
+// lengths plus fixed per-struct overheads, which is close enough to tell
+// which conversation is large without the cost of encoding every event.
+func eventBytes(e ConversationEvent) int64 {
+	n := eventOverhead + len(e.EventID) + len(e.StableID) + len(e.GenerationID) + len(e.Type) +
+		len(e.AgentName) + len(e.ConversationID) + len(e.Role) + len(e.Model) + len(e.Runtime) +
+		len(e.RequestID) + len(e.ParentEventID) + len(e.SubagentID) + len(e.ParentConvID) +
+		valueBytes(e.Metadata)
+	for _, c := range e.Content {
+		n += blockOverhead + len(c.Type) + len(c.Text) + len(c.ToolName) + len(c.ToolID) +
+			len(c.Input) + len(c.Output) + len(c.Signature) + len(c.MimeType) + len(c.Data) +
+			len(c.BlobID) + valueBytes(c.Metadata)
+	}
+	return int64(n)
+}
+
+// valueBytes estimates the memory held by a metadata value.
+func valueBytes(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return 16 + len(v)
+	case map[string]any:
+		if len(v) == 0 {
+			return 0
+		}
+		n := 48
+		for k, e := range v {
+			n += 16 + len(k) + valueBytes(e)
+		}
+		return n
+	case []any:
+		n := 24
+		for _, e := range v {
+			n += valueBytes(e)
+		}
+		return n
+	case []string:
+		n := 24
+		for _, e := range v {
+			n += 16 + len(e)
+		}
+		return n
+	case json.RawMessage:
+		return 24 + len(v)
+	default:
+		return 16
+	}
+}
+
 // MinSeq returns the lowest sequence number still in the buffer, or -1 if empty.
 func (b *ConversationBuffer) MinSeq() int64 {
 	b.mu.Lock()
@@ -1,6 +1,7 @@
 package conv
 
 import (
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -49,6 +50,43 @@ func TestBufferEviction(t *testing.T) {
 	}
 }
 
+func TestBufferActivity(t *testing.T) {
+	buf := NewConversationBuffer("test-conv", "test-agent", 2)
+	if a := buf.Activity(); a.LastEventAt != nil || a.LastUserPromptAt != nil || a.EventCount != 0 {
+		t.Fatalf("empty buffer activity = %+v", a)
+	}
+
+	base := time.Date(2026, 2, 14, 1, 0, 0, 0, time.UTC)
+	at := func(typ string, minutes int) ConversationEvent {
+		e := makeEvent(typ)
+		e.Timestamp = base.Add(time.Duration(minutes) * time.Minute)
+		return e
+	}
+	buf.Append(at(EventUser, 0))
+	sub := at(EventUser, 2)
+	sub.SubagentID = "agent-1"
+	buf.Append(sub)
+	buf.Append(at(EventAssistant, 3))
+
+	a := buf.Activity()
+	if a.EventCount != 3 {
+		t.Errorf("EventCount = %d, want 3 including the evicted event", a.EventCount)
+	}
+	if a.LastEventAt == nil || !a.LastEventAt.Equal(base.Add(3*time.Minute)) {
+		t.Errorf("LastEventAt = %v, want the assistant reply", a.LastEventAt)
+	}
+	if a.LastUserPromptAt == nil || !a.LastUserPromptAt.Equal(base) {
+		t.Errorf("LastUserPromptAt = %v, want the main prompt, not the subagent's", a.LastUserPromptAt)
+	}
+
+	events, next := buf.Export()
+	restored := NewConversationBuffer("test-conv", "test-agent", 10)
+	restored.Restore(events, next)
+	if got := restored.Activity(); got.EventCount != 3 || !got.LastEventAt.Equal(*a.LastEventAt) {
+		t.Errorf("restored activity = %+v, want %+v", got, a)
+	}
+}
+
 func TestBufferSubscribeNoGap(t *testing.T) {
 	buf := NewConversationBuffer("test-conv", "test-agent", 100)
 
@@ -189,6 +227,22 @@ func TestBufferEventsSinceGap(t *testing.T) {
 	}
 }
 
+func TestBufferAppendReturnsSeq(t *testing.T) {
+	buf := NewConversationBuffer("test-conv", "test-agent", 3)
+
+	if buf.LastSeq() != -1 {
+		t.Fatalf("empty LastSeq = %d, want -1", buf.LastSeq())
+	}
+	for i := int64(0); i < 5; i++ {
+		if seq := buf.Append(makeEvent(EventUser)); seq != i {
+			t.Fatalf("Append seq = %d, want %d", seq, i)
+		}
+	}
+	if buf.LastSeq() != 4 {
+		t.Fatalf("LastSeq = %d, want 4", buf.LastSeq())
+	}
+}
+
 func TestBufferMinSeq(t *testing.T) {
 	buf := NewConversationBuffer("test-conv", "test-agent", 3)
 
@@ -204,3 +258,84 @@ func TestBufferMinSeq(t *testing.T) {
 		t.Fatalf("MinSeq = %d, want 2", buf.MinSeq())
 	}
 }
+
+func TestBufferStats(t *testing.T) {
+	buf := NewConversationBuffer("test-conv", "test-agent", 3)
+	for i := 0; i < 5; i++ {
+		buf.Append(makeEvent(EventUser))
+	}
+	_, subID, _ := buf.Subscribe(EventFilter{})
+
+	stats := buf.Stats()
+	if stats.Events != 3 || stats.Capacity != 3 || stats.NextSeq != 5 || stats.Subscribers != 1 {
+		t.Fatalf("Stats() = %+v, want 3 events, capacity 3, nextSeq 5, 1 subscriber", stats)
+	}
+
+	if stats.MinSeq != 2 || stats.MaxSeq != 4 {
+		t.Fatalf("seq range = %d..%d, want 2..4", stats.MinSeq, stats.MaxSeq)
+	}
+
+	buf.Unsubscribe(subID)
+	if got := buf.Stats().Subscribers; got != 0 {
+		t.Fatalf("Subscribers after Unsubscribe = %d, want 0", got)
+	}
+}
+
+func TestBufferStatsBytes(t *testing.T) {
+	buf := NewConversationBuffer("test-conv", "test-agent", 2)
+	if stats := buf.Stats(); stats.Bytes != 0 || stats.MinSeq != -1 || stats.MaxSeq != -1 {
+		t.Fatalf("empty Stats() = %+v", stats)
+	}
+
+	big := makeEvent(EventAssistant)
+	big.Content = []ContentBlock{{Type: "text", Text: strings.Repeat("x", 100000)}}
+	buf.Append(big)
+	withBig := buf.Stats().Bytes
+	if withBig < 100000 {
+		t.Fatalf("Bytes = %d, want at least the text's 100000", withBig)
+	}
+
+	// Evicting the large event gives its bytes back.
+	buf.Append(makeEvent(EventUser))
+	buf.Append(makeEvent(EventUser))
+	if got := buf.Stats().Bytes; got >= withBig-90000 {
+		t.Fatalf("Bytes after eviction = %d, was %d", got, withBig)
+	}
+
+	events, next := buf.Export()
+	restored := NewConversationBuffer("test-conv", "test-agent", 2)
+	restored.Restore(events, next)
+	if got, want := restored.Stats().Bytes, buf.Stats().Bytes; got != want {
+		t.Fatalf("restored Bytes = %d, want %d", got, want)
+	}
+}
+
+func TestBufferUpdateInPlace(t *testing.T) {
+	buf := NewConversationBuffer("test-conv", "test-agent", 100)
+	first := makeEvent(EventThinking)
+	first.EventID, first.Role = "a1", "assistant"
+	first.Content = []ContentBlock{{Type: "thinking", Text: "hmm"}}
+	buf.Append(first)
+	buf.Append(makeEvent(EventProgress))
+
+	_, _, live := buf.Subscribe(EventFilter{})
+	delta := makeEvent(EventAssistant)
+	delta.EventID, delta.Role, delta.Delta = "a1", "assistant", true
+	delta.Content = []ContentBlock{{Type: "text", Text: "done"}}
+	got, updated := buf.Update(delta)
+	if !updated || got.Seq != 0 || got.Updates != 1 || got.Delta || got.Type != EventAssistant || len(got.Content) != 2 {
+		t.Fatalf("Update = %+v, %v; want seq 0 merged in place", got, updated)
+	}
+	if e := <-live; e.Seq != 0 || e.Updates != 1 {
+		t.Fatalf("subscriber got %+v, want the updated event", e)
+	}
+	if snap := buf.Snapshot(EventFilter{}); len(snap) != 2 || len(snap[0].Content) != 2 || buf.LastSeq() != 1 {
+		t.Fatalf("snapshot = %+v, want the update applied without a new seq", snap)
+	}
+
+	replace := makeEvent(EventToolResult)
+	replace.EventID, replace.Replace = "gone", true
+	if got, updated := buf.Update(replace); updated || got.Seq != 2 || got.Replace {
+		t.Fatalf("Update of an unknown event = %+v, %v; want it appended", got, updated)
+	}
+}
@@ -3,6 +3,8 @@ package conv
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -10,6 +12,11 @@ import (
 type ClaudeParser struct {
 	agentName      string
 	conversationID string
+	linker         *SubagentLinker
+	parentTask     *TaskLink // sidechain files: the Task invocation that spawned this subagent
+	streamed       streamedMessages
+	maxContent     int // bytes kept of a block's text or output; 0 keeps everything
+	options        ParserOptions
 }
 
 // NewClaudeParser creates a new Claude Code parser.
@@ -17,37 +24,80 @@ func NewClaudeParser(agentName, conversationID string) *ClaudeParser {
 	return &ClaudeParser{
 		agentName:      agentName,
 		conversationID: conversationID,
+		maxContent:     DefaultMaxContentSize,
 	}
 }
 
+// SetAgentName changes the agent name stamped on events, for an agent
+// whose session was renamed.
+func (p *ClaudeParser) SetAgentName(name string) {
+	p.agentName = name
+}
+
+// SetMaxContentSize sets how many bytes of a block's text or output are
+// kept; longer ones are cut and marked Truncated. 0 keeps everything.
+func (p *ClaudeParser) SetMaxContentSize(n int) {
+	p.maxContent = n
+}
+
+// SetOptions sets which event types are skipped and whether transcript lines
+// are kept on events.
+func (p *ClaudeParser) SetOptions(o ParserOptions) {
+	p.options = o
+}
+
 func (p *ClaudeParser) Runtime() string { return "claude" }
-func (p *ClaudeParser) Reset()          {}
+func (p *ClaudeParser) Reset()          { p.parentTask, p.streamed = nil, streamedMessages{} }
+
+// SetSubagentLinker shares a linker across parsers so sidechain conversations
+// can be attached to the Task invocation that spawned them.
+func (p *ClaudeParser) SetSubagentLinker(l *SubagentLinker) {
+	p.linker = l
+}
 
 // claudeRawLine is the top-level structure of a Claude Code JSONL line.
 type claudeRawLine struct {
-	Type        string          `json:"type"`
-	UUID        string          `json:"uuid"`
-	ParentUUID  string          `json:"parentUuid"`
-	SessionID   string          `json:"sessionId"`
-	Timestamp   string          `json:"timestamp"`
-	RequestID   string          `json:"requestId"`
-	CWD         string          `json:"cwd"`
-	Message     json.RawMessage `json:"message"`
-	Data        json.RawMessage `json:"data"`
-	Operation   string          `json:"operation"`
-	Content     string          `json:"content"`
-	ToolUseID   string          `json:"toolUseID"`
-	MessageID   string          `json:"messageId"`
+	Type          string          `json:"type"`
+	UUID          string          `json:"uuid"`
+	ParentUUID    string          `json:"parentUuid"`
+	SessionID     string          `json:"sessionId"`
+	Timestamp     string          `json:"timestamp"`
+	RequestID     string          `json:"requestId"`
+	CWD           string          `json:"cwd"`
+	Message       json.RawMessage `json:"message"`
+	Data          json.RawMessage `json:"data"`
+	Operation     string          `json:"operation"`
+	Content       string          `json:"content"`
+	ToolUseID     string          `json:"toolUseID"`
+	MessageID     string          `json:"messageId"`
+	IsSidechain   bool            `json:"isSidechain"`
+	AgentID       string          `json:"agentId"`
+	ToolUseResult json.RawMessage `json:"toolUseResult"`
+	DurationMs    int64           `json:"durationMs"` // system turn_duration lines
+
+	// Compaction fields
+	Subtype          string                 `json:"subtype"`
+	Summary          string                 `json:"summary"`
+	LeafUUID         string                 `json:"leafUuid"`
+	CompactMetadata  *claudeCompactMetadata `json:"compactMetadata"`
+	IsCompactSummary bool                   `json:"isCompactSummary"`
+}
+
+// claudeCompactMetadata holds compact_boundary details.
+type claudeCompactMetadata struct {
+	Trigger    string `json:"trigger"`
+	PreTokens  int    `json:"preTokens"`
+	PostTokens int    `json:"postTokens"`
 }
 
 // claudeMessage is the message envelope in assistant/user events.
 type claudeMessage struct {
-	Role       string            `json:"role"`
-	Model      string            `json:"model"`
-	ID         string            `json:"id"`
-	Content    json.RawMessage   `json:"content"`
-	StopReason *string           `json:"stop_reason"`
-	Usage      *claudeUsage      `json:"usage"`
+	Role       string          `json:"role"`
+	Model      string          `json:"model"`
+	ID         string          `json:"id"`
+	Content    json.RawMessage `json:"content"`
+	StopReason *string         `json:"stop_reason"`
+	Usage      *claudeUsage    `json:"usage"`
 }
 
 type claudeUsage struct {
@@ -68,7 +118,17 @@ type claudeContentBlock struct {
 	Input     json.RawMessage `json:"input"`
 	ToolUseID string          `json:"tool_use_id"`
 	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
 	Caller    json.RawMessage `json:"caller"`
+	Source    *claudeSource   `json:"source"`
+}
+
+// claudeSource is an image block's payload.
+type claudeSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+	URL       string `json:"url"`
 }
 
 // claudeProgressData holds progress event data.
@@ -79,8 +139,17 @@ type claudeProgressData struct {
 	Command   string `json:"command"`
 }
 
-// Parse converts a single Claude Code JSONL line into ConversationEvents.
+// Parse converts a single Claude Code JSONL line into ConversationEvents,
+// applying the parser's options.
 func (p *ClaudeParser) Parse(raw []byte) ([]ConversationEvent, error) {
+	events, err := p.parseLine(raw)
+	if err != nil {
+		return nil, err
+	}
+	return p.options.apply(events, raw), nil
+}
+
+func (p *ClaudeParser) parseLine(raw []byte) ([]ConversationEvent, error) {
 	var line claudeRawLine
 	if err := json.Unmarshal(raw, &line); err != nil {
 		return []ConversationEvent{p.makeParseError(err, raw)}, nil
@@ -92,6 +161,29 @@ func (p *ClaudeParser) Parse(raw []byte) ([]ConversationEvent, error) {
 		eventID = line.MessageID
 	}
 
+	var events []ConversationEvent
+	var err error
+	switch {
+	case line.Type == "summary",
+		line.Type == "system" && line.Subtype == "compact_boundary",
+		line.Type == "user" && line.IsCompactSummary:
+		events, err = p.parseCompaction(line, ts, eventID)
+	default:
+		events, err = p.parseByType(line, ts, eventID, raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if line.IsSidechain {
+		p.linkSidechainEvents(line, events)
+	} else {
+		p.linkTaskInvocations(line, events)
+	}
+	return events, nil
+}
+
+func (p *ClaudeParser) parseByType(line claudeRawLine, ts time.Time, eventID string, raw []byte) ([]ConversationEvent, error) {
 	switch line.Type {
 	case "user":
 		return p.parseUserMessage(line, ts, eventID)
@@ -103,11 +195,130 @@ func (p *ClaudeParser) Parse(raw []byte) ([]ConversationEvent, error) {
 		return p.parseQueueOp(line, ts, eventID)
 	case "file-history-snapshot":
 		return nil, nil // skip
+	case "system":
+		if line.Subtype == "turn_duration" {
+			return []ConversationEvent{p.makeTurnEnd(line, ts, eventID)}, nil
+		}
+		return []ConversationEvent{p.makeSystemEvent(line.Type, ts, eventID, raw)}, nil
 	default:
 		return []ConversationEvent{p.makeSystemEvent(line.Type, ts, eventID, raw)}, nil
 	}
 }
 
+// linkSidechainEvents tags subagent events with their subagent and parent
+// conversation IDs. The sidechain's root prompt is matched against Task
+// invocations so the root event's ParentEventID points at the spawning tool_use.
+func (p *ClaudeParser) linkSidechainEvents(line claudeRawLine, events []ConversationEvent) {
+	agentID := line.AgentID
+	if agentID == "" {
+		// Older builds omit agentId; the file stem is "agent-<id>".
+		native := p.conversationID[strings.LastIndex(p.conversationID, ":")+1:]
+		agentID = strings.TrimPrefix(native, "agent-")
+	}
+
+	sessionConv := ""
+	if line.SessionID != "" {
+		sessionConv = makeConversationID("claude", line.SessionID)
+	}
+	root := false
+	if line.ParentUUID == "" && line.Type == "user" && p.parentTask == nil {
+		root = true
+		sub := SubagentLink{AgentID: agentID, ConversationID: p.conversationID}
+		if task, ok := p.linker.ResolveSubagent(sessionConv, firstText(events), sub); ok {
+			p.parentTask = &task
+		}
+	}
+
+	parentConv := sessionConv
+	if p.parentTask != nil {
+		parentConv = p.parentTask.ConversationID
+	}
+
+	for i := range events {
+		events[i].SubagentID = agentID
+		events[i].ParentConvID = parentConv
+		if p.parentTask == nil {
+			continue
+		}
+		setEventMeta(&events[i], "parentToolUseId", p.parentTask.ToolUseID)
+		if root {
+			events[i].ParentEventID = p.parentTask.EventID
+		}
+	}
+}
+
+// linkTaskInvocations records Task tool_use blocks in a main conversation and
+// annotates blocks whose subagent is already known.
+func (p *ClaudeParser) linkTaskInvocations(line claudeRawLine, events []ConversationEvent) {
+	resultAgentID := toolUseResultAgentID(line.ToolUseResult)
+
+	for i := range events {
+		for j := range events[i].Content {
+			block := &events[i].Content[j]
+			switch {
+			case block.Type == "tool_use" && subagentToolNames[block.ToolName]:
+				link := TaskLink{ConversationID: p.conversationID, EventID: events[i].EventID, ToolUseID: block.ToolID}
+				if sub, ok := p.linker.RecordTask(taskPrompt(block.Input), link); ok {
+					setBlockMeta(block, "subagentId", sub.AgentID)
+					setBlockMeta(block, "subagentConversationId", sub.ConversationID)
+				}
+			case block.Type == "tool_result" && resultAgentID != "":
+				setBlockMeta(block, "subagentId", resultAgentID)
+				if sub, ok := p.linker.RecordResult(block.ToolID, resultAgentID); ok {
+					setBlockMeta(block, "subagentConversationId", sub.ConversationID)
+				}
+			}
+		}
+	}
+}
+
+// taskPrompt extracts the prompt argument from a Task tool_use input.
+func taskPrompt(input json.RawMessage) string {
+	var args struct {
+		Prompt string `json:"prompt"`
+	}
+	if len(input) == 0 || json.Unmarshal(input, &args) != nil {
+		return ""
+	}
+	return args.Prompt
+}
+
+// toolUseResultAgentID extracts agentId from a Task tool_result's toolUseResult payload.
+func toolUseResultAgentID(raw json.RawMessage) string {
+	var result struct {
+		AgentID string `json:"agentId"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &result) != nil {
+		return ""
+	}
+	return result.AgentID
+}
+
+func firstText(events []ConversationEvent) string {
+	for _, e := range events {
+		for _, b := range e.Content {
+			if b.Type == "text" {
+				return b.Text
+			}
+		}
+	}
+	return ""
+}
+
+func setEventMeta(e *ConversationEvent, key string, value any) {
+	if e.Metadata == nil {
+		e.Metadata = map[string]any{}
+	}
+	e.Metadata[key] = value
+}
+
+func setBlockMeta(b *ContentBlock, key string, value any) {
+	if b.Metadata == nil {
+		b.Metadata = map[string]any{}
+	}
+	b.Metadata[key] = value
+}
+
 func (p *ClaudeParser) parseUserMessage(line claudeRawLine, ts time.Time, eventID string) ([]ConversationEvent, error) {
 	if line.Message == nil {
 		return nil, nil
@@ -124,6 +335,11 @@ func (p *ClaudeParser) parseUserMessage(line claudeRawLine, ts time.Time, eventI
 		// User message containing tool_result — emit as tool_result event
 		var events []ConversationEvent
 		for _, block := range blocks {
+			if block.Type == "image" && block.ToolID != "" && len(events) > 0 {
+				last := &events[len(events)-1]
+				last.Content = append(last.Content, block)
+				continue
+			}
 			if block.Type == "tool_result" {
 				events = append(events, ConversationEvent{
 					EventID:        eventID,
@@ -170,14 +386,17 @@ func (p *ClaudeParser) parseAssistantMessage(line claudeRawLine, ts time.Time, e
 		return nil, nil
 	}
 
-	// Determine event type based on content
-	eventType := EventAssistant
-	if len(blocks) == 1 {
-		switch blocks[0].Type {
-		case "tool_use":
-			eventType = EventToolUse
-		case "thinking":
-			eventType = EventThinking
+	// Claude writes a streamed reply as several lines sharing the message
+	// ID, one per content block (older builds repeated the earlier blocks).
+	// Lines after the first become a delta of the first line's event.
+	delta := false
+	parentID := line.ParentUUID
+	if msg.ID != "" {
+		if first, added, ok := p.streamed.extend(msg.ID, eventID, line.ParentUUID, blocks); ok {
+			if len(added) == 0 {
+				return nil, nil
+			}
+			eventID, parentID, blocks, delta = first.eventID, first.parentID, added, true
 		}
 	}
 
@@ -193,7 +412,7 @@ func (p *ClaudeParser) parseAssistantMessage(line claudeRawLine, ts time.Time, e
 
 	return []ConversationEvent{{
 		EventID:        eventID,
-		Type:           eventType,
+		Type:           assistantEventType(blocks),
 		AgentName:      p.agentName,
 		ConversationID: p.conversationID,
 		Timestamp:      ts,
@@ -203,10 +422,64 @@ func (p *ClaudeParser) parseAssistantMessage(line claudeRawLine, ts time.Time, e
 		Runtime:        "claude",
 		TokenUsage:     usage,
 		RequestID:      line.RequestID,
-		ParentEventID:  line.ParentUUID,
+		ParentEventID:  parentID,
+		Delta:          delta,
 	}}, nil
 }
 
+// assistantEventType classifies an assistant event by its content: a lone
+// tool_use or thinking block gets its own type.
+func assistantEventType(blocks []ContentBlock) string {
+	if len(blocks) == 1 {
+		switch blocks[0].Type {
+		case "tool_use":
+			return EventToolUse
+		case "thinking":
+			return EventThinking
+		}
+	}
+	return EventAssistant
+}
+
+// maxStreamedMessages bounds the assistant messages a parser remembers for
+// coalescing. A message's lines are written together, so only the last few
+// can still grow.
+const maxStreamedMessages = 32
+
+type streamedMessage struct {
+	eventID  string
+	parentID string
+	blocks   []ContentBlock
+}
+
+// streamedMessages tracks the content of recent assistant messages by
+// message ID.
+type streamedMessages struct {
+	byID  map[string]*streamedMessage
+	order []string
+}
+
+// extend records blocks read for messageID. For a message seen before it
+// returns the message's first line and the content the blocks add (see
+// ContentDelta), with ok set; a new message is remembered under eventID.
+func (s *streamedMessages) extend(messageID, eventID, parentID string, blocks []ContentBlock) (first streamedMessage, added []ContentBlock, ok bool) {
+	if m, seen := s.byID[messageID]; seen {
+		added = ContentDelta(m.blocks, blocks)
+		m.blocks = ApplyContentDelta(m.blocks, added)
+		return *m, added, true
+	}
+	if s.byID == nil {
+		s.byID = make(map[string]*streamedMessage)
+	}
+	if len(s.order) >= maxStreamedMessages {
+		delete(s.byID, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.byID[messageID] = &streamedMessage{eventID: eventID, parentID: parentID, blocks: slices.Clone(blocks)}
+	s.order = append(s.order, messageID)
+	return streamedMessage{}, nil, false
+}
+
 func (p *ClaudeParser) parseProgress(line claudeRawLine, ts time.Time, eventID string) ([]ConversationEvent, error) {
 	var data claudeProgressData
 	if line.Data != nil {
@@ -258,6 +531,73 @@ func (p *ClaudeParser) parseQueueOp(line claudeRawLine, ts time.Time, eventID st
 	}}, nil
 }
 
+// parseCompaction maps Claude's context-compaction records to EventCompaction:
+//   - "summary" lines carry a summary of the conversation up to leafUuid
+//   - "system" compact_boundary lines mark where compaction happened, with token counts
+//   - "user" lines flagged isCompactSummary carry the summary injected after compaction
+func (p *ClaudeParser) parseCompaction(line claudeRawLine, ts time.Time, eventID string) ([]ConversationEvent, error) {
+	meta := map[string]any{}
+	summary := line.Summary
+
+	switch {
+	case line.Type == "summary":
+		if eventID == "" {
+			eventID = line.LeafUUID
+		}
+		if line.LeafUUID != "" {
+			meta["leafUuid"] = line.LeafUUID
+		}
+	case line.IsCompactSummary:
+		if line.Message != nil {
+			var msg claudeMessage
+			if err := json.Unmarshal(line.Message, &msg); err != nil {
+				return []ConversationEvent{p.makeParseError(err, line.Message)}, nil
+			}
+			blocks, _ := p.parseContentBlocks(msg.Content)
+			var parts []string
+			for _, b := range blocks {
+				if b.Type == "text" && b.Text != "" {
+					parts = append(parts, b.Text)
+				}
+			}
+			summary = strings.Join(parts, "\n")
+		}
+	default:
+		if line.Content != "" {
+			meta["content"] = line.Content
+		}
+	}
+
+	if cm := line.CompactMetadata; cm != nil {
+		if cm.Trigger != "" {
+			meta["trigger"] = cm.Trigger
+		}
+		if cm.PreTokens > 0 {
+			meta["preTokens"] = cm.PreTokens
+		}
+		if cm.PostTokens > 0 {
+			meta["postTokens"] = cm.PostTokens
+		}
+	}
+
+	e := ConversationEvent{
+		EventID:        eventID,
+		Type:           EventCompaction,
+		AgentName:      p.agentName,
+		ConversationID: p.conversationID,
+		Timestamp:      ts,
+		Runtime:        "claude",
+		ParentEventID:  line.ParentUUID,
+	}
+	if summary != "" {
+		e.Content = []ContentBlock{p.normalizeBlock(ContentBlock{Type: "text", Text: summary})}
+	}
+	if len(meta) > 0 {
+		e.Metadata = meta
+	}
+	return []ConversationEvent{e}, nil
+}
+
 // parseContentBlocks normalizes Claude message content (string or array) into ContentBlocks.
 // Returns the blocks and whether any tool_result blocks were found.
 func (p *ClaudeParser) parseContentBlocks(raw json.RawMessage) ([]ContentBlock, bool) {
@@ -271,7 +611,7 @@ func (p *ClaudeParser) parseContentBlocks(raw json.RawMessage) ([]ContentBlock,
 		if textContent == "" {
 			return nil, false
 		}
-		return []ContentBlock{{Type: "text", Text: truncateContent(textContent)}}, false
+		return []ContentBlock{p.normalizeBlock(ContentBlock{Type: "text", Text: textContent})}, false
 	}
 
 	// Parse as array
@@ -286,14 +626,14 @@ func (p *ClaudeParser) parseContentBlocks(raw json.RawMessage) ([]ContentBlock,
 		switch rb.Type {
 		case "text":
 			if rb.Text != "" {
-				blocks = append(blocks, ContentBlock{Type: "text", Text: truncateContent(rb.Text)})
+				blocks = append(blocks, p.normalizeBlock(ContentBlock{Type: "text", Text: rb.Text}))
 			}
 		case "thinking":
-			blocks = append(blocks, ContentBlock{
+			blocks = append(blocks, p.normalizeBlock(ContentBlock{
 				Type:      "thinking",
-				Text:      truncateContent(rb.Thinking),
+				Text:      rb.Thinking,
 				Signature: rb.Signature,
-			})
+			}))
 		case "tool_use":
 			blocks = append(blocks, ContentBlock{
 				Type:     "tool_use",
@@ -301,19 +641,63 @@ func (p *ClaudeParser) parseContentBlocks(raw json.RawMessage) ([]ContentBlock,
 				ToolID:   rb.ID,
 				Input:    rb.Input,
 			})
+		case "image":
+			if image, ok := imageBlock(rb.Source); ok {
+				blocks = append(blocks, image)
+			}
 		case "tool_result":
 			hasToolResult = true
 			output := p.extractToolResultContent(rb.Content)
-			blocks = append(blocks, ContentBlock{
-				Type:   "tool_result",
-				ToolID: rb.ToolUseID,
-				Output: truncateContent(output),
-			})
+			blocks = append(blocks, p.normalizeBlock(ContentBlock{
+				Type:    "tool_result",
+				ToolID:  rb.ToolUseID,
+				Output:  output,
+				IsError: rb.IsError,
+			}))
+			// Images a tool returned (a screenshot, a Read of a PNG) follow
+			// their result, tagged with its tool ID.
+			var inner []claudeContentBlock
+			if json.Unmarshal(rb.Content, &inner) == nil {
+				for _, ib := range inner {
+					if ib.Type != "image" {
+						continue
+					}
+					if image, ok := imageBlock(ib.Source); ok {
+						image.ToolID = rb.ToolUseID
+						blocks = append(blocks, image)
+					}
+				}
+			}
 		}
 	}
 	return blocks, hasToolResult
 }
 
+// imageBlock converts a Claude image source: base64 data is kept as is (the
+// watcher moves large images to the blob store), a URL source is recorded
+// in metadata.
+func imageBlock(src *claudeSource) (ContentBlock, bool) {
+	if src == nil {
+		return ContentBlock{}, false
+	}
+	b := ContentBlock{Type: "image", MimeType: src.MediaType}
+	switch src.Type {
+	case "base64":
+		if src.Data == "" {
+			return ContentBlock{}, false
+		}
+		b.Data = src.Data
+	case "url":
+		if src.URL == "" {
+			return ContentBlock{}, false
+		}
+		setBlockMeta(&b, "url", src.URL)
+	default:
+		return ContentBlock{}, false
+	}
+	return b, true
+}
+
 func (p *ClaudeParser) extractToolResultContent(raw json.RawMessage) string {
 	if raw == nil {
 		return ""
@@ -341,25 +725,49 @@ func (p *ClaudeParser) extractToolResultContent(raw json.RawMessage) string {
 	return string(raw)
 }
 
+// parseTimestamp returns the zero time for a missing or malformed timestamp;
+// the watcher fills it in from neighboring events.
 func (p *ClaudeParser) parseTimestamp(ts string) time.Time {
 	t, err := time.Parse(time.RFC3339Nano, ts)
 	if err != nil {
-		return time.Now()
+		return time.Time{}
 	}
 	return t
 }
 
-func (p *ClaudeParser) makeParseError(err error, _ []byte) ConversationEvent {
+func (p *ClaudeParser) makeParseError(err error, raw []byte) ConversationEvent {
+	meta := map[string]any{
+		"errorKind": "parse",
+	}
+	if len(raw) > 0 {
+		line, truncated := captureRawLine(raw)
+		meta["rawLine"] = line
+		if truncated {
+			meta["rawTruncated"] = true
+		}
+	}
 	return ConversationEvent{
 		Type:           EventError,
 		AgentName:      p.agentName,
 		ConversationID: p.conversationID,
-		Timestamp:      time.Now(),
 		Runtime:        "claude",
 		Content:        []ContentBlock{{Type: "text", Text: fmt.Sprintf("parse error: %v", err)}},
-		Metadata: map[string]any{
-			"errorKind": "parse",
-		},
+		Metadata:       meta,
+	}
+}
+
+// makeTurnEnd maps the turn_duration line Claude Code writes once a turn's
+// final reply is done.
+func (p *ClaudeParser) makeTurnEnd(line claudeRawLine, ts time.Time, eventID string) ConversationEvent {
+	return ConversationEvent{
+		EventID:        eventID,
+		Type:           EventTurnEnd,
+		AgentName:      p.agentName,
+		ConversationID: p.conversationID,
+		Timestamp:      ts,
+		Runtime:        "claude",
+		ParentEventID:  line.ParentUUID,
+		DurationMs:     line.DurationMs,
 	}
 }
 
@@ -377,9 +785,15 @@ func (p *ClaudeParser) makeSystemEvent(eventType string, ts time.Time, eventID s
 	}
 }
 
-func truncateContent(s string) string {
-	if len(s) <= MaxContentSize {
-		return s
+// normalizeBlock normalizes b's text and output and cuts them to the parser's
+// content limit, marking the block when it does.
+func (p *ClaudeParser) normalizeBlock(b ContentBlock) ContentBlock {
+	for _, s := range []*string{&b.Text, &b.Output} {
+		*s = NormalizeText(*s)
+		if p.maxContent > 0 && len(*s) > p.maxContent {
+			b.Truncated, b.OriginalBytes = true, len(*s)
+			*s = TruncateText(*s, p.maxContent)
+		}
 	}
-	return s[:MaxContentSize]
+	return b
 }
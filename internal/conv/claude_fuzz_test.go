@@ -0,0 +1,93 @@
+package conv
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// addCorpusLines seeds f with each line of the JSONL files in testdata.
+func addCorpusLines(f *testing.F, path string) {
+	f.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 2*1024*1024), 2*1024*1024)
+	for scanner.Scan() {
+		f.Add(append([]byte(nil), scanner.Bytes()...))
+	}
+	if err := scanner.Err(); err != nil {
+		f.Fatal(err)
+	}
+}
+
+// FuzzClaudeParser feeds arbitrary lines to the Claude parser, as a main
+// conversation and as a subagent sidechain sharing a linker. Run it with
+//
+//	go test ./internal/conv -run '^$' -fuzz FuzzClaudeParser
+//
+// Parsing must not panic or fail: a malformed line becomes a parse error
+// event. Kept content must respect the content limit, and a fresh parser
+// must parse a line the same way every time.
+func FuzzClaudeParser(f *testing.F) {
+	addCorpusLines(f, "testdata/claude/sample.jsonl")
+	for _, seed := range []string{
+		``,
+		`{}`,
+		`null`,
+		`[]`,
+		`{"type":"assistant","message":{"id":"m1","content":[{"type":"text","text":"a"}]}}`,
+		`{"type":"user","isSidechain":true,"message":{"content":"do the thing"}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"image","source":{"type":"base64","data":"AA=="}}]}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Task","id":"t1","input":{"prompt":"do the thing"}}]}}`,
+		`{"type":"system","subtype":"compact_boundary","compactMetadata":{"trigger":"auto","preTokens":"x"}}`,
+		`{"type":"progress","data":{"type":"bash_progress","output":7}}`,
+		`{"type":"queue-operation","operation":"enqueue","content":{}}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	const maxContent = 64
+	f.Fuzz(func(t *testing.T, line []byte) {
+		linker := NewSubagentLinker()
+		for _, convID := range []string{"claude:main", "claude:agent-a1"} {
+			p := NewClaudeParser("fuzz", convID)
+			p.SetSubagentLinker(linker)
+			p.SetMaxContentSize(maxContent)
+			p.SetOptions(ParserOptions{IncludeRaw: true})
+			// Twice, as a streamed message's lines repeat its earlier content.
+			for range 2 {
+				events, err := p.Parse(line)
+				if err != nil {
+					t.Fatalf("Parse(%q) error = %v", line, err)
+				}
+				for _, e := range events {
+					if e.Runtime != "claude" || e.ConversationID != convID {
+						t.Fatalf("event %+v has runtime %q, conversation %q", e, e.Runtime, e.ConversationID)
+					}
+					if e.Type == EventError {
+						continue // the parse error's message, not transcript content
+					}
+					for _, b := range e.Content {
+						if b.Type != "text" && b.Type != "thinking" && b.Type != "tool_result" {
+							continue
+						}
+						if !b.Truncated && !e.Delta && (len(b.Text) > maxContent || len(b.Output) > maxContent) {
+							t.Fatalf("block of %d/%d bytes kept past the %d-byte limit without Truncated", len(b.Text), len(b.Output), maxContent)
+						}
+					}
+				}
+			}
+		}
+
+		first, _ := NewClaudeParser("fuzz", "claude:main").Parse(line)
+		again, _ := NewClaudeParser("fuzz", "claude:main").Parse(line)
+		if !reflect.DeepEqual(first, again) {
+			t.Fatalf("Parse(%q) differs between fresh parsers:\n%+v\n%+v", line, first, again)
+		}
+	})
+}
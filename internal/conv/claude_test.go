@@ -7,7 +7,7 @@ import (
 )
 
 func TestClaudeParserUserMessage(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello world"}]}}`)
 	events, err := parser.Parse(raw)
@@ -36,7 +36,7 @@ func TestClaudeParserUserMessage(t *testing.T) {
 }
 
 func TestClaudeParserAssistantMessage(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{"type":"assistant","uuid":"a1","requestId":"req1","timestamp":"2026-02-14T01:45:00.362Z","message":{"model":"claude-opus-4-6","role":"assistant","content":[{"type":"text","text":"Here is my response."}],"usage":{"input_tokens":100,"output_tokens":50,"cache_read_input_tokens":10,"cache_creation_input_tokens":5}}}`)
 	events, err := parser.Parse(raw)
@@ -68,7 +68,7 @@ func TestClaudeParserAssistantMessage(t *testing.T) {
 }
 
 func TestClaudeParserToolUse(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{"type":"assistant","uuid":"a2","timestamp":"2026-02-14T01:45:01.055Z","message":{"model":"claude-opus-4-6","role":"assistant","content":[{"type":"tool_use","id":"toolu_123","name":"Read","input":{"file_path":"/tmp/test.go"}}]}}`)
 	events, err := parser.Parse(raw)
@@ -91,7 +91,7 @@ func TestClaudeParserToolUse(t *testing.T) {
 }
 
 func TestClaudeParserThinking(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{"type":"assistant","uuid":"a3","timestamp":"2026-02-14T01:44:59.309Z","message":{"model":"claude-opus-4-6","role":"assistant","content":[{"type":"thinking","thinking":"Let me think about this...","signature":"sig123"}]}}`)
 	events, err := parser.Parse(raw)
@@ -114,7 +114,7 @@ func TestClaudeParserThinking(t *testing.T) {
 }
 
 func TestClaudeParserToolResult(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{"type":"user","uuid":"u2","timestamp":"2026-02-14T01:45:01.076Z","message":{"role":"user","content":[{"tool_use_id":"toolu_123","type":"tool_result","content":"file contents here"}]}}`)
 	events, err := parser.Parse(raw)
@@ -136,8 +136,93 @@ func TestClaudeParserToolResult(t *testing.T) {
 	}
 }
 
+func TestClaudeParserImages(t *testing.T) {
+	parser := NewClaudeParser("test-agent", "claude:abc123")
+
+	raw := []byte(`{"type":"user","uuid":"u3","timestamp":"2026-02-14T01:45:02.000Z","message":{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}}]}}`)
+	events, err := parser.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(events) != 1 || len(events[0].Content) != 2 {
+		t.Fatalf("events = %+v, want one event with text and image", events)
+	}
+	if img := events[0].Content[1]; img.Type != "image" || img.MimeType != "image/png" || img.Data != "iVBORw0KGgo=" {
+		t.Fatalf("image block = %+v", img)
+	}
+
+	raw = []byte(`{"type":"user","uuid":"u4","timestamp":"2026-02-14T01:45:03.000Z","message":{"role":"user","content":[{"tool_use_id":"toolu_9","type":"tool_result","content":[{"type":"text","text":"screenshot taken"},{"type":"image","source":{"type":"base64","media_type":"image/jpeg","data":"/9j/4AAQ"}}]}]}}`)
+	events, err = parser.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(events) != 1 || len(events[0].Content) != 2 {
+		t.Fatalf("events = %+v, want one tool_result event with its image", events)
+	}
+	if img := events[0].Content[1]; img.Type != "image" || img.ToolID != "toolu_9" || img.MimeType != "image/jpeg" {
+		t.Fatalf("tool image block = %+v", img)
+	}
+}
+
+func TestClaudeParserCoalescesStreamedMessage(t *testing.T) {
+	parser := NewClaudeParser("test-agent", "claude:abc123")
+	line := func(uuid, parent, content string) []byte {
+		return []byte(`{"type":"assistant","uuid":"` + uuid + `","parentUuid":"` + parent + `","timestamp":"2026-02-14T01:45:10.000Z","message":{"id":"msg_1","role":"assistant","model":"claude-opus-4-6","content":[` + content + `]}}`)
+	}
+
+	var got []ConversationEvent
+	for _, raw := range [][]byte{
+		line("a1", "u0", `{"type":"thinking","thinking":"Let me look."}`),
+		line("a2", "a1", `{"type":"text","text":"Reading it"}`),
+		line("a3", "a2", `{"type":"text","text":"Reading it now."}`),
+		line("a4", "a3", `{"type":"tool_use","id":"toolu_1","name":"Read","input":{"file_path":"/a.go"}}`),
+	} {
+		events, err := parser.Parse(raw)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		got = append(got, events...)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d events, want 4", len(got))
+	}
+	if got[0].Delta || got[0].Type != EventThinking {
+		t.Fatalf("first event = %+v, want a thinking event", got[0])
+	}
+	for _, e := range got[1:] {
+		if !e.Delta || e.EventID != "a1" || e.ParentEventID != "u0" {
+			t.Fatalf("later event = %+v, want a delta of a1", e)
+		}
+	}
+	if b := got[2].Content[0]; !b.Continues || b.Text != " now." {
+		t.Fatalf("grown text delta = %+v, want the added text", b)
+	}
+	if got[3].Type != EventToolUse {
+		t.Fatalf("tool delta Type = %q, want %q", got[3].Type, EventToolUse)
+	}
+
+	var content []ContentBlock
+	for _, e := range got {
+		content = ApplyContentDelta(content, e.Content)
+	}
+	if len(content) != 3 || content[1].Text != "Reading it now." || content[1].Continues {
+		t.Fatalf("merged content = %+v", content)
+	}
+}
+
+func TestContentDeltaCumulativeLines(t *testing.T) {
+	prev := []ContentBlock{{Type: "text", Text: "one"}}
+	next := []ContentBlock{{Type: "text", Text: "one"}, {Type: "text", Text: "two"}}
+	if d := ContentDelta(prev, next); len(d) != 1 || d[0].Text != "two" || d[0].Continues {
+		t.Fatalf("ContentDelta = %+v, want the new block", d)
+	}
+	if d := ContentDelta(next, next); d != nil {
+		t.Fatalf("ContentDelta of a repeat = %+v, want nil", d)
+	}
+}
+
 func TestClaudeParserProgress(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{"type":"progress","uuid":"p1","timestamp":"2026-02-14T01:44:54.307Z","data":{"type":"hook_progress","hookEvent":"SessionStart","hookName":"SessionStart:clear","command":"bd prime"}}`)
 	events, err := parser.Parse(raw)
@@ -157,7 +242,7 @@ func TestClaudeParserProgress(t *testing.T) {
 }
 
 func TestClaudeParserQueueOp(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{"type":"queue-operation","operation":"enqueue","timestamp":"2026-02-14T01:44:54.458Z","sessionId":"abc","content":"background task completed"}`)
 	events, err := parser.Parse(raw)
@@ -177,7 +262,7 @@ func TestClaudeParserQueueOp(t *testing.T) {
 }
 
 func TestClaudeParserFileHistorySnapshotSkipped(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{"type":"file-history-snapshot","messageId":"m1","snapshot":{}}`)
 	events, err := parser.Parse(raw)
@@ -190,7 +275,7 @@ func TestClaudeParserFileHistorySnapshotSkipped(t *testing.T) {
 }
 
 func TestClaudeParserMalformedJSON(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{invalid json here`)
 	events, err := parser.Parse(raw)
@@ -206,7 +291,7 @@ func TestClaudeParserMalformedJSON(t *testing.T) {
 }
 
 func TestClaudeParserUnknownType(t *testing.T) {
-	parser := NewClaudeParser("test-agent", "claude:test-agent:abc123")
+	parser := NewClaudeParser("test-agent", "claude:abc123")
 
 	raw := []byte(`{"type":"future-new-type","uuid":"f1","timestamp":"2026-02-14T01:44:54.253Z"}`)
 	events, err := parser.Parse(raw)
@@ -224,6 +309,74 @@ func TestClaudeParserUnknownType(t *testing.T) {
 	}
 }
 
+func TestClaudeParserTurnDuration(t *testing.T) {
+	parser := NewClaudeParser("test-agent", "claude:abc123")
+
+	raw := []byte(`{"type":"system","subtype":"turn_duration","uuid":"t1","parentUuid":"a9","durationMs":4210,"timestamp":"2026-02-14T01:44:54.253Z"}`)
+	events, err := parser.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Type != EventTurnEnd {
+		t.Fatalf("events = %+v, want one turn_end", events)
+	}
+	if events[0].DurationMs != 4210 || events[0].ParentEventID != "a9" {
+		t.Fatalf("DurationMs = %d, ParentEventID = %q; want 4210, a9", events[0].DurationMs, events[0].ParentEventID)
+	}
+}
+
+func TestClaudeParserCompaction(t *testing.T) {
+	parser := NewClaudeParser("test-agent", "claude:abc123")
+
+	tests := []struct {
+		name     string
+		raw      string
+		wantText string
+		wantMeta map[string]any
+	}{
+		{
+			name:     "summary",
+			raw:      `{"type":"summary","summary":"Refactored the tailer","leafUuid":"leaf1"}`,
+			wantText: "Refactored the tailer",
+			wantMeta: map[string]any{"leafUuid": "leaf1"},
+		},
+		{
+			name:     "compact boundary",
+			raw:      `{"type":"system","subtype":"compact_boundary","uuid":"c1","content":"Conversation compacted","timestamp":"2026-02-14T01:44:54.253Z","compactMetadata":{"trigger":"auto","preTokens":155000,"postTokens":12000}}`,
+			wantMeta: map[string]any{"trigger": "auto", "preTokens": 155000, "postTokens": 12000},
+		},
+		{
+			name:     "compact summary message",
+			raw:      `{"type":"user","uuid":"c2","parentUuid":"c1","isCompactSummary":true,"timestamp":"2026-02-14T01:44:54.300Z","message":{"role":"user","content":"This session is being continued..."}}`,
+			wantText: "This session is being continued...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := parser.Parse([]byte(tt.raw))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			e := events[0]
+			if e.Type != EventCompaction {
+				t.Fatalf("Type = %q, want %q", e.Type, EventCompaction)
+			}
+			if tt.wantText != "" && (len(e.Content) != 1 || e.Content[0].Text != tt.wantText) {
+				t.Fatalf("Content = %+v, want summary %q", e.Content, tt.wantText)
+			}
+			for k, v := range tt.wantMeta {
+				if e.Metadata[k] != v {
+					t.Fatalf("Metadata[%q] = %v, want %v", k, e.Metadata[k], v)
+				}
+			}
+		})
+	}
+}
+
 func TestClaudeParserRealSamples(t *testing.T) {
 	f, err := os.Open("testdata/claude/sample.jsonl")
 	if err != nil {
@@ -231,7 +384,7 @@ func TestClaudeParserRealSamples(t *testing.T) {
 	}
 	defer func() { _ = f.Close() }()
 
-	parser := NewClaudeParser("test-agent", "claude:test-agent:sample")
+	parser := NewClaudeParser("test-agent", "claude:sample")
 	scanner := bufio.NewScanner(f)
 	scanner.Buffer(make([]byte, 2*1024*1024), 2*1024*1024)
 
@@ -0,0 +1,146 @@
+package conv
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// PromptRef points at one end of a prompt one agent sent another through
+// the adapter: the tool_use block that sent it, or the user event it became.
+type PromptRef struct {
+	Agent          string `json:"agent"`
+	ConversationID string `json:"conversationId"`
+	EventID        string `json:"eventId,omitempty"`
+	StableID       string `json:"stableId,omitempty"`
+	ToolID         string `json:"toolId,omitempty"`   // the sending tool_use block
+	PromptID       string `json:"promptId,omitempty"` // the user event's promptId
+}
+
+// promptEnd is a PromptRef waiting for the other end of its prompt.
+type promptEnd struct {
+	ref  PromptRef
+	text string // the prompt as requested, as compared by promptKey
+	at   time.Time
+}
+
+// sendPromptCall reports whether b is a tool call sending a prompt to
+// another agent through the adapter, such as the MCP send_prompt tool
+// (mcp__<server>__send_prompt in Claude Code), and to whom.
+func sendPromptCall(b ContentBlock) (agent, prompt string, ok bool) {
+	if b.Type != "tool_use" || (b.ToolName != "send_prompt" && !strings.HasSuffix(b.ToolName, "__send_prompt")) {
+		return "", "", false
+	}
+	var input struct {
+		Agent  string `json:"agent"`
+		Prompt string `json:"prompt"`
+	}
+	if json.Unmarshal(b.Input, &input) != nil || input.Agent == "" || input.Prompt == "" {
+		return "", "", false
+	}
+	return input.Agent, input.Prompt, true
+}
+
+// linkPromptCalls pairs the send_prompt calls in event, read from stream,
+// with the user events their prompts became. A call whose user event was
+// read first gets metadata.promptTarget on its block; the rest wait for
+// linkPromptArrival. Calls read from history older than promptEchoTTL are
+// not waited for.
+func (w *ConversationWatcher) linkPromptCalls(stream *conversationStream, event *ConversationEvent) {
+	now := time.Now()
+	if now.Sub(event.Timestamp) > promptEchoTTL {
+		return
+	}
+	for i := range event.Content {
+		b := &event.Content[i]
+		target, prompt, ok := sendPromptCall(*b)
+		if !ok {
+			continue
+		}
+		origin := promptEnd{
+			ref: PromptRef{
+				Agent:          stream.agent.Name,
+				ConversationID: stream.conversationID,
+				EventID:        event.EventID,
+				StableID:       event.StableID,
+				ToolID:         b.ToolID,
+			},
+			text: promptKey(prompt),
+			at:   now,
+		}
+		w.promptsMu.Lock()
+		arrival, matched := takePromptEnd(w.promptEchoes, target, origin.text, now)
+		if !matched {
+			addPromptEnd(w.promptCalls, target, origin)
+		}
+		w.promptsMu.Unlock()
+		if matched {
+			setBlockMeta(b, "promptTarget", arrival.ref)
+		}
+	}
+}
+
+// linkPromptArrival pairs event, the user event agentName's prompt p became,
+// with the send_prompt call that sent it. If the call was read first, event
+// gets metadata.promptOrigin; otherwise event waits for linkPromptCalls.
+func (w *ConversationWatcher) linkPromptArrival(agentName string, event *ConversationEvent, p *SentPrompt) {
+	now := time.Now()
+	arrival := promptEnd{
+		ref: PromptRef{
+			Agent:          agentName,
+			ConversationID: event.ConversationID,
+			EventID:        event.EventID,
+			StableID:       event.StableID,
+			PromptID:       p.tag.PromptID,
+		},
+		text: p.request,
+		at:   now,
+	}
+	w.promptsMu.Lock()
+	origin, matched := takePromptEnd(w.promptCalls, agentName, arrival.text, now)
+	if !matched {
+		addPromptEnd(w.promptEchoes, agentName, arrival)
+	}
+	w.promptsMu.Unlock()
+	if matched {
+		setEventMeta(event, "promptOrigin", origin.ref)
+	}
+}
+
+// takePromptEnd removes and returns the oldest end waiting under agent for
+// a prompt with text, dropping those past promptEchoTTL.
+func takePromptEnd(ends map[string][]promptEnd, agent, text string, now time.Time) (promptEnd, bool) {
+	waiting := ends[agent]
+	i := 0
+	for i < len(waiting) && now.Sub(waiting[i].at) > promptEchoTTL {
+		i++
+	}
+	waiting = waiting[i:]
+	for j, e := range waiting {
+		if e.text == text {
+			waiting = append(waiting[:j:j], waiting[j+1:]...)
+			storePromptEnds(ends, agent, waiting)
+			return e, true
+		}
+	}
+	storePromptEnds(ends, agent, waiting)
+	return promptEnd{}, false
+}
+
+// addPromptEnd leaves e waiting under agent, forgetting the oldest end past
+// maxPendingPrompts.
+func addPromptEnd(ends map[string][]promptEnd, agent string, e promptEnd) {
+	waiting := ends[agent]
+	if len(waiting) >= maxPendingPrompts {
+		waiting = waiting[1:]
+	}
+	ends[agent] = append(waiting, e)
+}
+
+func storePromptEnds(ends map[string][]promptEnd, agent string, waiting []promptEnd) {
+	if len(waiting) == 0 {
+		delete(ends, agent)
+	} else {
+		ends[agent] = waiting
+	}
+}
@@ -0,0 +1,80 @@
+package conv
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+func TestLinkPromptCalls(t *testing.T) {
+	w := NewConversationWatcher(nil, 100)
+	defer w.Stop()
+	sender := &conversationStream{conversationID: "claude:lead", agent: agents.Agent{Name: "lead"}}
+	call := func(id string) ConversationEvent {
+		return ConversationEvent{Type: EventToolUse, EventID: id, StableID: "s-" + id, Timestamp: time.Now(), Content: []ContentBlock{{
+			Type: "tool_use", ToolName: "mcp__tmux-converter__send_prompt", ToolID: "toolu_" + id,
+			Input: json.RawMessage(`{"agent":"worker","prompt":"run the tests"}`),
+		}}}
+	}
+	// The prompt policy prefixed the prompt the call asked for.
+	echo := func(id string) ConversationEvent {
+		return ConversationEvent{Type: EventUser, ConversationID: "claude:worker", EventID: id, StableID: "s-" + id, Content: []ContentBlock{{Type: "text", Text: "[ci] run the tests"}}}
+	}
+	tag := func(id string) PromptTag { return PromptTag{PromptID: id, Request: "run the tests"} }
+
+	// The call is read first: the user event links back to it.
+	c1 := call("c1")
+	w.linkPromptCalls(sender, &c1)
+	w.ExpectPrompt("worker", "[ci] run the tests", tag("p1"))
+	u1 := echo("u1")
+	w.claimPrompt("worker", &u1)
+	origin, ok := u1.Metadata["promptOrigin"].(PromptRef)
+	if !ok || origin.Agent != "lead" || origin.ConversationID != "claude:lead" || origin.EventID != "c1" || origin.ToolID != "toolu_c1" {
+		t.Fatalf("promptOrigin = %+v, want the lead's call c1", u1.Metadata["promptOrigin"])
+	}
+	if c1.Content[0].Metadata != nil {
+		t.Fatalf("call read first got metadata %v", c1.Content[0].Metadata)
+	}
+
+	// The user event is read first: the call links forward to it.
+	w.ExpectPrompt("worker", "[ci] run the tests", tag("p2"))
+	u2 := echo("u2")
+	w.claimPrompt("worker", &u2)
+	if _, ok := u2.Metadata["promptOrigin"]; ok {
+		t.Fatalf("user event read before its call has promptOrigin")
+	}
+	c2 := call("c2")
+	w.linkPromptCalls(sender, &c2)
+	target, ok := c2.Content[0].Metadata["promptTarget"].(PromptRef)
+	if !ok || target.Agent != "worker" || target.EventID != "u2" || target.PromptID != "p2" {
+		t.Fatalf("promptTarget = %+v, want the worker's user event u2", c2.Content[0].Metadata["promptTarget"])
+	}
+
+	// A call read from old history waits for nothing.
+	c3 := call("c3")
+	c3.Timestamp = time.Now().Add(-time.Hour)
+	w.linkPromptCalls(sender, &c3)
+	if len(w.promptCalls) != 0 || len(w.promptEchoes) != 0 {
+		t.Fatalf("left waiting: calls %v, echoes %v", w.promptCalls, w.promptEchoes)
+	}
+}
+
+func TestSendPromptCall(t *testing.T) {
+	tests := []struct {
+		block ContentBlock
+		ok    bool
+	}{
+		{ContentBlock{Type: "tool_use", ToolName: "send_prompt", Input: json.RawMessage(`{"agent":"a","prompt":"p"}`)}, true},
+		{ContentBlock{Type: "tool_use", ToolName: "mcp__converter__send_prompt", Input: json.RawMessage(`{"agent":"a","prompt":"p"}`)}, true},
+		{ContentBlock{Type: "tool_use", ToolName: "mcp__converter__send_prompt", Input: json.RawMessage(`{"agent":"a"}`)}, false},
+		{ContentBlock{Type: "tool_use", ToolName: "resend_prompt", Input: json.RawMessage(`{"agent":"a","prompt":"p"}`)}, false},
+		{ContentBlock{Type: "tool_result", ToolName: "send_prompt"}, false},
+	}
+	for _, tt := range tests {
+		if _, _, ok := sendPromptCall(tt.block); ok != tt.ok {
+			t.Errorf("sendPromptCall(%s %s) = %v, want %v", tt.block.ToolName, tt.block.Input, ok, tt.ok)
+		}
+	}
+}
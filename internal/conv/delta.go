@@ -0,0 +1,89 @@
+package conv
+
+import (
+	"bytes"
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// mergeUpdate returns the buffered event prev updated by upd. A Replace
+// supersedes it outright; a Delta adds its content, and the event becomes an
+// assistant event once it holds more than one kind of reply. Either way the
+// event keeps its seq, timestamp, and StableID, so cursors stay valid.
+func mergeUpdate(prev, upd ConversationEvent) ConversationEvent {
+	merged := upd
+	if upd.Delta {
+		merged.Content = ApplyContentDelta(prev.Content, upd.Content)
+		if upd.Type != prev.Type && upd.Role == "assistant" {
+			merged.Type = EventAssistant
+		}
+		merged.ParentEventID = prev.ParentEventID
+		merged.Model = cmp.Or(upd.Model, prev.Model)
+		if upd.TokenUsage == nil {
+			merged.TokenUsage = prev.TokenUsage
+		}
+		if len(prev.Metadata) > 0 {
+			merged.Metadata = maps.Clone(prev.Metadata)
+			maps.Copy(merged.Metadata, upd.Metadata)
+		}
+	}
+	merged.Seq, merged.Timestamp, merged.TimestampEstimated, merged.StableID = prev.Seq, prev.Timestamp, prev.TimestampEstimated, prev.StableID
+	merged.Updates = prev.Updates + 1
+	merged.Delta, merged.Replace = false, false
+	return merged
+}
+
+// ContentDelta returns what next adds to prev, the content already read for
+// a streamed message. next either repeats prev and adds to it or holds only
+// blocks after prev, and in both cases may start with a longer version of
+// prev's last block. A grown text or thinking block is returned as its added
+// text with Continues set.
+func ContentDelta(prev, next []ContentBlock) []ContentBlock {
+	k := 0
+	for k < len(prev) && k < len(next) && sameBlock(prev[k], next[k]) {
+		k++
+	}
+	if k >= len(next) {
+		return nil
+	}
+	// The block that may have grown is prev's last, repeated at k or, in a
+	// line of only new blocks, written again first.
+	if last := len(prev) - 1; (k == last || k == 0) && last >= 0 && grows(prev[last], next[k]) {
+		b := next[k]
+		b.Text, b.Continues = strings.TrimPrefix(b.Text, prev[last].Text), true
+		return append([]ContentBlock{b}, next[k+1:]...)
+	}
+	return slices.Clone(next[k:])
+}
+
+// ApplyContentDelta returns blocks with delta, as returned by ContentDelta,
+// added.
+func ApplyContentDelta(blocks, delta []ContentBlock) []ContentBlock {
+	blocks = slices.Clone(blocks)
+	for i, b := range delta {
+		if last := len(blocks) - 1; i == 0 && b.Continues && last >= 0 && blocks[last].Type == b.Type {
+			blocks[last].Text += b.Text
+			if b.Signature != "" {
+				blocks[last].Signature = b.Signature
+			}
+			continue
+		}
+		b.Continues = false
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+func sameBlock(a, b ContentBlock) bool {
+	return a.Type == b.Type && a.Text == b.Text && a.ToolID == b.ToolID && a.ToolName == b.ToolName &&
+		a.Output == b.Output && a.Signature == b.Signature && a.Data == b.Data && bytes.Equal(a.Input, b.Input)
+}
+
+// grows reports whether b is a longer version of the text or thinking
+// block a.
+func grows(a, b ContentBlock) bool {
+	return a.Type == b.Type && (a.Type == "text" || a.Type == "thinking") &&
+		len(b.Text) > len(a.Text) && strings.HasPrefix(b.Text, a.Text)
+}
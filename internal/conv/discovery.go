@@ -63,6 +63,23 @@ func (d *ClaudeDiscoverer) FindConversations(agentName, workDir string) (Discove
 		return result, nil
 	}
 
+	// Newer Claude Code builds nest Task-tool sidechains under
+	// {session}/subagents/. Only the active (most recent) session is relevant.
+	for _, f := range files {
+		if f.IsSubagent {
+			continue
+		}
+		subDir := filepath.Join(projectDir, f.NativeConversationID, "subagents")
+		if subFiles, err := d.scanDirectory(agentName, subDir); err == nil {
+			for i := range subFiles {
+				subFiles[i].IsSubagent = true
+			}
+			files = append(files, subFiles...)
+			result.WatchDirs = append(result.WatchDirs, subDir)
+		}
+		break
+	}
+
 	result.Files = files
 	return result, nil
 }
@@ -107,7 +124,7 @@ func (d *ClaudeDiscoverer) scanDirectory(agentName, dir string) ([]ConversationF
 		files = append(files, ConversationFile{
 			Path:                 c.path,
 			NativeConversationID: stem,
-			ConversationID:       "claude:" + agentName + ":" + stem,
+			ConversationID:       makeConversationID("claude", stem),
 			IsSubagent:           isSubagent,
 			Runtime:              "claude",
 		})
@@ -116,6 +133,29 @@ func (d *ClaudeDiscoverer) scanDirectory(agentName, dir string) ([]ConversationF
 	return files, nil
 }
 
+// makeConversationID builds the protocol-level conversation ID
+// "runtime:nativeId". The agent is left out so a conversation keeps its ID
+// when its session is renamed or it is resumed in another session; which
+// agent it belongs to is carried separately.
+func makeConversationID(runtime, nativeID string) string {
+	return runtime + ":" + nativeID
+}
+
+// CanonicalConversationID returns the current form of a conversation ID.
+// IDs handed out by earlier versions, "runtime:agentName:nativeId", are
+// accepted as aliases of "runtime:nativeId": tmux session names and native
+// IDs never contain ':', so the native ID is whatever follows the last one.
+func CanonicalConversationID(id string) string {
+	runtime, rest, ok := strings.Cut(id, ":")
+	if !ok {
+		return id
+	}
+	if i := strings.LastIndexByte(rest, ':'); i >= 0 {
+		return makeConversationID(runtime, rest[i+1:])
+	}
+	return id
+}
+
 // encodeWorkDir encodes a working directory path for Claude's projects directory.
 // Claude replaces '/' and '_' with '-'.
 func encodeWorkDir(workDir string) string {
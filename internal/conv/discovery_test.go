@@ -73,8 +73,8 @@ func TestClaudeDiscovererFindsFiles(t *testing.T) {
 	if mainFile == nil {
 		t.Fatal("no main conversation file found")
 	}
-	if mainFile.ConversationID != "claude:test-agent:abc123" {
-		t.Fatalf("ConversationID = %q, want %q", mainFile.ConversationID, "claude:test-agent:abc123")
+	if mainFile.ConversationID != "claude:abc123" {
+		t.Fatalf("ConversationID = %q, want %q", mainFile.ConversationID, "claude:abc123")
 	}
 	if mainFile.Runtime != "claude" {
 		t.Fatalf("Runtime = %q, want %q", mainFile.Runtime, "claude")
@@ -106,32 +106,77 @@ func TestClaudeDiscovererMissingDir(t *testing.T) {
 	}
 }
 
-func TestConversationIDUniqueness(t *testing.T) {
-	// Two agents with the same native file should produce different ConversationIDs
+func TestConversationIDIgnoresAgentName(t *testing.T) {
+	// The same native file found for an agent before and after its session
+	// is renamed keeps its ConversationID.
 	root := t.TempDir()
-	workDir1 := "/tmp/project1"
-	workDir2 := "/tmp/project2"
-
-	for _, wd := range []string{workDir1, workDir2} {
-		dir := filepath.Join(root, "projects", encodeWorkDir(wd))
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			t.Fatal(err)
-		}
-		if err := os.WriteFile(filepath.Join(dir, "same-id.jsonl"), []byte(`{"type":"user"}`+"\n"), 0644); err != nil {
-			t.Fatal(err)
-		}
+	workDir := "/tmp/project1"
+	dir := filepath.Join(root, "projects", encodeWorkDir(workDir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "same-id.jsonl"), []byte(`{"type":"user"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
 	}
 
 	disc := NewClaudeDiscoverer(root)
 
-	r1, _ := disc.FindConversations("agent-a", workDir1)
-	r2, _ := disc.FindConversations("agent-b", workDir2)
+	r1, _ := disc.FindConversations("agent-a", workDir)
+	r2, _ := disc.FindConversations("agent-b", workDir)
 
 	if len(r1.Files) == 0 || len(r2.Files) == 0 {
-		t.Fatal("expected files from both agents")
+		t.Fatal("expected files for both names")
+	}
+	if r1.Files[0].ConversationID != "claude:same-id" || r2.Files[0].ConversationID != "claude:same-id" {
+		t.Fatalf("ConversationIDs = %q, %q; want claude:same-id for both", r1.Files[0].ConversationID, r2.Files[0].ConversationID)
+	}
+}
+
+func TestCanonicalConversationID(t *testing.T) {
+	tests := map[string]string{
+		"claude:abc123":                 "claude:abc123",
+		"claude:hq-mayor:abc123":        "claude:abc123",
+		"claude:gt-rig-crew:agent-ab12": "claude:agent-ab12",
+		"abc123":                        "abc123",
+		"":                              "",
+	}
+	for id, want := range tests {
+		if got := CanonicalConversationID(id); got != want {
+			t.Errorf("CanonicalConversationID(%q) = %q, want %q", id, got, want)
+		}
 	}
+}
 
-	if r1.Files[0].ConversationID == r2.Files[0].ConversationID {
-		t.Fatalf("ConversationIDs should differ: %q == %q", r1.Files[0].ConversationID, r2.Files[0].ConversationID)
+func TestClaudeDiscovererNestedSubagents(t *testing.T) {
+	root := t.TempDir()
+	workDir := "/Users/chris/code/nested"
+	projectDir := filepath.Join(root, "projects", encodeWorkDir(workDir))
+	subDir := filepath.Join(projectDir, "sess1", "subagents")
+
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "sess1.jsonl"), []byte(`{"type":"user"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(subDir, "agent-ab12.jsonl"), []byte(`{"type":"user"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	disc := NewClaudeDiscoverer(root)
+	result, err := disc.FindConversations("test-agent", workDir)
+	if err != nil {
+		t.Fatalf("FindConversations() error = %v", err)
+	}
+
+	if len(result.Files) != 2 {
+		t.Fatalf("got %d files, want 2", len(result.Files))
+	}
+	sub := result.Files[1]
+	if !sub.IsSubagent || sub.ConversationID != "claude:agent-ab12" {
+		t.Fatalf("subagent file = %+v, want nested agent-ab12 subagent", sub)
+	}
+	if len(result.WatchDirs) != 2 || result.WatchDirs[1] != subDir {
+		t.Fatalf("WatchDirs = %v, want project dir and %s", result.WatchDirs, subDir)
 	}
 }
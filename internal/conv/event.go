@@ -1,7 +1,10 @@
 package conv
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -16,7 +19,9 @@ const (
 	EventProgress   = "progress"
 	EventTurnEnd    = "turn_end"
 	EventQueueOp    = "queue_op"
+	EventCompaction = "compaction"
 	EventError      = "error"
+	EventAnnotation = "annotation" // added by an external tool, see Annotate
 )
 
 // ConversationEvent is the universal event type streamed to clients.
@@ -24,11 +29,17 @@ const (
 type ConversationEvent struct {
 	Seq            int64     `json:"seq"`
 	EventID        string    `json:"eventId"`
+	StableID       string    `json:"stableId,omitempty"` // see StableEventID
 	GenerationID   string    `json:"generationId,omitempty"`
 	Type           string    `json:"type"`
 	AgentName      string    `json:"agentName"`
 	ConversationID string    `json:"conversationId"`
 	Timestamp      time.Time `json:"timestamp"`
+	// ReceivedAt is when the watcher read the event's line. Timestamp comes
+	// from the transcript; when that is missing or ahead of ReceivedAt it is
+	// estimated and TimestampEstimated is set (see normalizeTimestamp).
+	ReceivedAt         time.Time `json:"receivedAt"`
+	TimestampEstimated bool      `json:"timestampEstimated,omitempty"`
 
 	Role    string         `json:"role,omitempty"`
 	Content []ContentBlock `json:"content,omitempty"`
@@ -42,6 +53,16 @@ type ConversationEvent struct {
 	ParentConvID  string         `json:"parentConvId,omitempty"`
 	DurationMs    int64          `json:"durationMs,omitempty"`
 	Metadata      map[string]any `json:"metadata,omitempty"`
+
+	// Delta marks an event that continues an earlier one with the same
+	// EventID, such as the next block of a streamed reply: Content holds
+	// only what was added (see ApplyContentDelta). Replace marks one that
+	// supersedes it, such as a tool call whose status changed. The buffer
+	// applies both in place (see ConversationBuffer.Update), so clients see
+	// neither; Updates counts how often a buffered event was updated.
+	Delta   bool `json:"-"`
+	Replace bool `json:"-"`
+	Updates int  `json:"updates,omitempty"`
 }
 
 // ContentBlock is a normalized content element.
@@ -56,7 +77,16 @@ type ContentBlock struct {
 	Signature string          `json:"signature,omitempty"`
 	MimeType  string          `json:"mimeType,omitempty"`
 	Data      string          `json:"data,omitempty"`
+	BlobID    string          `json:"blobId,omitempty"`
+	Continues bool            `json:"continues,omitempty"` // delta block extending the previous block's text
+	Redacted  bool            `json:"redacted,omitempty"`
 	Metadata  map[string]any  `json:"metadata,omitempty"`
+
+	// Truncated is set when Text or Output was cut to the parser's content
+	// limit; OriginalBytes is then its full length. See
+	// ConversationWatcher.FullContent.
+	Truncated     bool `json:"truncated,omitempty"`
+	OriginalBytes int  `json:"originalBytes,omitempty"`
 }
 
 // TokenUsage tracks API token consumption.
@@ -94,7 +124,18 @@ type Cursor struct {
 	GenerationID   string `json:"g"`
 	Seq            int64  `json:"s"`
 	EventID        string `json:"e"`
+	StableID       string `json:"i,omitempty"`
+}
+
+// StableEventID derives an event ID from where the event was read: the same
+// line at the same file offset yields the same ID across restarts and parser
+// instances, unlike EventID, which may be synthetic. index distinguishes the
+// events parsed from a single line.
+func StableEventID(runtime, nativeID string, offset int64, index int) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s\x00%d\x00%d", runtime, nativeID, offset, index))
+	return hex.EncodeToString(sum[:16])
 }
 
-// MaxContentSize is the maximum size in bytes for a single content block's text/output.
-const MaxContentSize = 256 * 1024
+// DefaultMaxContentSize is the default maximum size in bytes for a single
+// content block's text/output; see ClaudeParser.SetMaxContentSize.
+const DefaultMaxContentSize = 256 * 1024
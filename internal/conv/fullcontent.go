@@ -0,0 +1,121 @@
+package conv
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Errors returned by FullContent.
+var (
+	ErrEventNotFound   = errors.New("event not in buffer")
+	ErrNotTruncated    = errors.New("content block is not truncated")
+	ErrContentNotFound = errors.New("content no longer in the transcript")
+)
+
+// markTruncatedSources records in each truncated block's metadata the
+// offset of the line it came from, which FullContent reads back.
+func markTruncatedSources(e *ConversationEvent, offset int64) {
+	for i := range e.Content {
+		if e.Content[i].Truncated {
+			setBlockMeta(&e.Content[i], "sourceOffset", offset)
+		}
+	}
+}
+
+// FullContent returns block index of the buffered event seq in full: the
+// transcript line it came from is read and parsed again without a content
+// limit, and the event passed through middleware again, so redaction still
+// applies.
+func (w *ConversationWatcher) FullContent(conversationID string, seq int64, index int) (ContentBlock, error) {
+	w.mu.RLock()
+	stream, ok := w.streams[CanonicalConversationID(conversationID)]
+	var files []*fileStream
+	if ok {
+		for _, fs := range stream.files {
+			files = append(files, fs)
+		}
+	}
+	w.mu.RUnlock()
+	if !ok {
+		return ContentBlock{}, ErrConversationNotFound
+	}
+	event, ok := stream.buffer.Event(seq)
+	if !ok || index < 0 || index >= len(event.Content) {
+		return ContentBlock{}, ErrEventNotFound
+	}
+	want := event.Content[index]
+	if !want.Truncated {
+		return ContentBlock{}, ErrNotTruncated
+	}
+	var offset int64
+	switch v := want.Metadata["sourceOffset"].(type) {
+	case int64:
+		offset = v
+	case float64: // restored from a snapshot
+		offset = int64(v)
+	default:
+		return ContentBlock{}, ErrContentNotFound
+	}
+
+	for _, fs := range files {
+		line, err := readLineAt(fs.path, offset)
+		if err != nil {
+			continue
+		}
+		factory := w.parserFactory[fs.runtime]
+		if factory == nil {
+			continue
+		}
+		parser := factory(stream.agent.Name, conversationID)
+		if p, ok := parser.(*ClaudeParser); ok {
+			// Parsing again must not record Task invocations twice.
+			p.SetSubagentLinker(nil)
+			p.SetMaxContentSize(0)
+		}
+		events, err := parser.Parse(line)
+		if err != nil {
+			continue
+		}
+		for _, e := range events {
+			for j, b := range e.Content {
+				if b.Type != want.Type || b.ToolID != want.ToolID || max(len(b.Text), len(b.Output)) != want.OriginalBytes {
+					continue
+				}
+				e, keep := w.pipeline.Process(e)
+				if !keep || j >= len(e.Content) {
+					return ContentBlock{}, ErrContentNotFound
+				}
+				return e.Content[j], nil
+			}
+		}
+	}
+	return ContentBlock{}, ErrContentNotFound
+}
+
+// readLineAt reads the line starting at offset in path.
+func readLineAt(path string, offset int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var line []byte
+	r := bufio.NewReader(io.LimitReader(f, MaxLineSize))
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == nil {
+			return bytes.TrimRight(line, "\r\n"), nil
+		}
+		if err != bufio.ErrBufferFull {
+			return nil, fmt.Errorf("read line at %d: %w", offset, err)
+		}
+	}
+}
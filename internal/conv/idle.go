@@ -0,0 +1,248 @@
+package conv
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+)
+
+// idleStream is a conversation stopped for being idle, remembered so it can
+// be tailed again.
+type idleStream struct {
+	agent    agents.Agent
+	file     ConversationFile
+	info     os.FileInfo // the file when tailing stopped
+	activity Activity
+	saved    chan struct{} // closed once its snapshot is written
+}
+
+// SetIdleTTL stops tailing conversations whose files haven't changed for d
+// and that have no subscribers, freeing their buffers. With a state
+// directory the buffer is saved as a snapshot first. The conversation is
+// tailed again when its file is written or a client subscribes (see
+// EnsureTailing), resuming from the snapshot. Zero keeps every conversation
+// tailed. Must be called before Start.
+func (w *ConversationWatcher) SetIdleTTL(d time.Duration) {
+	w.idleTTL = d
+}
+
+// SetEagerTail keeps the conversations of agents that eager matches tailed
+// however long they are idle, so their buffers are always warm for the
+// first subscriber. nil makes every agent subject to SetIdleTTL. Must be
+// called before Start.
+func (w *ConversationWatcher) SetEagerTail(eager func(agentName string) bool) {
+	w.eagerTail = eager
+}
+
+// FullHistory asks EnsureTailing for a conversation's whole history.
+const FullHistory = -1
+
+// EnsureTailing returns a conversation's buffer, first tailing it again if
+// it was stopped for being idle. It returns nil for an unknown conversation.
+// The buffer of a re-tailed conversation fills as its file is read; see
+// LoadProgress.
+//
+// recent is how much history the caller needs: FullHistory, or a number of
+// events. A conversation tailed again without a snapshot then reads only
+// its last recent lines, about that many events, instead of the whole file.
+// Such a partial stream is read again from the start for a FullHistory
+// caller once it has no subscribers; until then that caller gets the
+// partial buffer.
+func (w *ConversationWatcher) EnsureTailing(conversationID string, recent int) *ConversationBuffer {
+	conversationID = CanonicalConversationID(conversationID)
+	if buf := w.GetBuffer(conversationID); buf != nil {
+		if recent != FullHistory || !w.collectPartial(conversationID) {
+			return buf
+		}
+	}
+	w.retail(conversationID, recent)
+	return w.GetBuffer(conversationID)
+}
+
+// Tracks reports whether a conversation is being tailed, or was collected
+// and would be tailed again by EnsureTailing. It starts nothing.
+func (w *ConversationWatcher) Tracks(conversationID string) bool {
+	conversationID = CanonicalConversationID(conversationID)
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, tailed := w.streams[conversationID]
+	_, idle := w.idle[conversationID]
+	return tailed || idle
+}
+
+// collectPartial stops a partial stream nobody is subscribed to, so it can
+// be tailed again with its whole history. It reports whether it did.
+func (w *ConversationWatcher) collectPartial(conversationID string) bool {
+	w.mu.RLock()
+	s, ok := w.streams[conversationID]
+	w.mu.RUnlock()
+	if !ok || !s.partial {
+		return false
+	}
+	w.collect(s)
+	w.mu.RLock()
+	_, ok = w.idle[conversationID]
+	w.mu.RUnlock()
+	return ok
+}
+
+// idleLoop collects idle conversations, and tails collected ones again once
+// their files change, until the watcher stops.
+func (w *ConversationWatcher) idleLoop() {
+	defer crash.Recover("watcher: idle loop")
+	ticker := time.NewTicker(min(max(w.idleTTL/4, 10*time.Millisecond), 30*time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.collectIdle(now)
+			w.retailChanged()
+		}
+	}
+}
+
+// collectIdle stops every stream that has been idle for the TTL. Candidate
+// streams are left alone, since the switch logic owns them, and so are
+// eager agents' streams.
+func (w *ConversationWatcher) collectIdle(now time.Time) {
+	w.mu.RLock()
+	var streams []*conversationStream
+	for id, s := range w.streams {
+		if w.candidates[s.agent.Name] != id && (w.eagerTail == nil || !w.eagerTail(s.agent.Name)) {
+			streams = append(streams, s)
+		}
+	}
+	w.mu.RUnlock()
+
+	for _, s := range streams {
+		if w.isIdle(s, now) {
+			w.collect(s)
+		}
+	}
+}
+
+// isIdle reports whether s has no subscribers, has read its history, and
+// has files unchanged for the TTL.
+func (w *ConversationWatcher) isIdle(s *conversationStream, now time.Time) bool {
+	if s.buffer.Stats().Subscribers > 0 {
+		return false
+	}
+	if _, _, loading := s.loadProgress(); loading {
+		return false
+	}
+	for _, fs := range s.files {
+		info, err := os.Stat(fs.path)
+		if err != nil || now.Sub(info.ModTime()) < w.idleTTL {
+			return false
+		}
+	}
+	return true
+}
+
+// collect stops tailing s and frees its buffer, saving a snapshot of it
+// when a state directory is set. The agent's active conversation stays
+// pointed at s, so follows and listings still find it.
+func (w *ConversationWatcher) collect(s *conversationStream) {
+	w.mu.Lock()
+	if w.streams[s.conversationID] != s || s.buffer.Stats().Subscribers > 0 {
+		w.mu.Unlock()
+		return
+	}
+	idle := idleStream{agent: s.agent, activity: s.buffer.Activity(), saved: make(chan struct{})}
+	for path, fs := range s.files {
+		idle.file = ConversationFile{
+			Path:                 path,
+			NativeConversationID: fs.nativeID,
+			ConversationID:       s.conversationID,
+			IsSubagent:           s.subagent,
+			Runtime:              fs.runtime,
+		}
+		idle.info, _ = os.Stat(path)
+	}
+	delete(w.streams, s.conversationID)
+	w.idle[s.conversationID] = idle
+	w.mu.Unlock()
+
+	s.cancel()
+	for _, fs := range s.files {
+		fs.stop()
+	}
+	if w.stateDir != "" {
+		if err := w.saveSnapshot(s); err != nil {
+			log.Printf("watcher: snapshot idle %s: %v", s.conversationID, err)
+		}
+	}
+	close(idle.saved)
+	if s.partial {
+		log.Printf("watcher: stopped partial tail of %s", s.conversationID)
+	} else {
+		log.Printf("watcher: stopped tailing %s, idle for %s", s.conversationID, w.idleTTL)
+	}
+}
+
+// retailChanged tails collected conversations again whose files have been
+// written since. Directory watchers catch most writes sooner; this covers
+// files outside the watched directories.
+func (w *ConversationWatcher) retailChanged() {
+	w.mu.RLock()
+	var changed []string
+	for id, idle := range w.idle {
+		info, err := os.Stat(idle.file.Path)
+		if err == nil && (idle.info == nil || info.Size() != idle.info.Size() || !info.ModTime().Equal(idle.info.ModTime())) {
+			changed = append(changed, id)
+		}
+	}
+	w.mu.RUnlock()
+	for _, id := range changed {
+		w.retail(id, FullHistory)
+	}
+}
+
+// retailPath tails the collected conversation at path again, if there is
+// one.
+func (w *ConversationWatcher) retailPath(path string) {
+	w.mu.RLock()
+	var id string
+	for convID, idle := range w.idle {
+		if idle.file.Path == path {
+			id = convID
+		}
+	}
+	w.mu.RUnlock()
+	if id != "" {
+		go w.retail(id, FullHistory)
+	}
+}
+
+// retail starts tailing a collected conversation again, reading recent
+// lines of history (see EnsureTailing). The entry stays in w.idle until
+// startConversationStream replaces it with the new stream, so concurrent
+// callers all find the conversation.
+func (w *ConversationWatcher) retail(conversationID string, recent int) {
+	defer crash.Recover("watcher: tailing %s again", conversationID)
+	w.mu.RLock()
+	idle, ok := w.idle[conversationID]
+	w.mu.RUnlock()
+	if !ok {
+		return
+	}
+	<-idle.saved
+	log.Printf("watcher: tailing idle %s again", conversationID)
+	w.startConversationStreamFrom(idle.agent, idle.file, recent)
+}
+
+// forgetIdleLocked drops the collected conversations of agentName and
+// their snapshots. The caller must hold w.mu for writing.
+func (w *ConversationWatcher) forgetIdleLocked(agentName string) {
+	for id, idle := range w.idle {
+		if idle.agent.Name == agentName {
+			delete(w.idle, id)
+			w.discardSnapshot(id, idle)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package conv
+
+// Middleware transforms, annotates, or drops an event between parser and buffer.
+// Returning false drops the event; it is neither buffered nor streamed.
+type Middleware func(ConversationEvent) (ConversationEvent, bool)
+
+// Pipeline is an ordered chain of middleware applied to every parsed event.
+type Pipeline []Middleware
+
+// Process runs the event through each middleware in order, stopping at the first drop.
+func (p Pipeline) Process(e ConversationEvent) (ConversationEvent, bool) {
+	for _, mw := range p {
+		var keep bool
+		e, keep = mw(e)
+		if !keep {
+			return e, false
+		}
+	}
+	return e, true
+}
+
+// Middleware adapts the redactor for use in a Pipeline.
+func (r *Redactor) Middleware() Middleware {
+	return func(e ConversationEvent) (ConversationEvent, bool) {
+		return r.RedactEvent(e), true
+	}
+}
@@ -0,0 +1,74 @@
+package conv
+
+import "testing"
+
+func TestPipelineOrderAndTransform(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(e ConversationEvent) (ConversationEvent, bool) {
+			order = append(order, name)
+			if e.Metadata == nil {
+				e.Metadata = map[string]any{}
+			}
+			e.Metadata[name] = true
+			return e, true
+		}
+	}
+
+	p := Pipeline{tag("first"), tag("second")}
+	got, keep := p.Process(makeEvent(EventUser))
+	if !keep {
+		t.Fatal("event dropped, want kept")
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("order = %v, want [first second]", order)
+	}
+	if got.Metadata["first"] != true || got.Metadata["second"] != true {
+		t.Fatalf("Metadata = %v, want both tags", got.Metadata)
+	}
+}
+
+func TestPipelineDropStopsChain(t *testing.T) {
+	called := false
+	dropProgress := func(e ConversationEvent) (ConversationEvent, bool) {
+		return e, e.Type != EventProgress
+	}
+	after := func(e ConversationEvent) (ConversationEvent, bool) {
+		called = true
+		return e, true
+	}
+
+	p := Pipeline{dropProgress, after}
+	if _, keep := p.Process(makeEvent(EventProgress)); keep {
+		t.Fatal("progress event kept, want dropped")
+	}
+	if called {
+		t.Fatal("middleware after a drop should not run")
+	}
+	if _, keep := p.Process(makeEvent(EventUser)); !keep {
+		t.Fatal("user event dropped, want kept")
+	}
+}
+
+func TestEmptyPipelinePassesThrough(t *testing.T) {
+	var p Pipeline
+	e := makeEvent(EventAssistant)
+	got, keep := p.Process(e)
+	if !keep || got.Type != EventAssistant {
+		t.Fatalf("Process() = (%v, %v), want unchanged event kept", got.Type, keep)
+	}
+}
+
+func TestRedactorMiddleware(t *testing.T) {
+	mw := NewRedactor(DefaultRedactionRules).Middleware()
+	e := makeEvent(EventUser)
+	e.Content = []ContentBlock{{Type: "text", Text: "mail bob@example.com"}}
+
+	got, keep := mw(e)
+	if !keep {
+		t.Fatal("redactor middleware dropped event")
+	}
+	if !got.Content[0].Redacted {
+		t.Fatal("block not redacted")
+	}
+}
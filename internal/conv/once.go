@@ -0,0 +1,58 @@
+package conv
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+// ReadCurrentConversation finds agent's current conversation with disc and
+// reads it once from start to end, without tailing, returning its file and
+// every event in it. Lines are handled as a watcher handles them, so
+// middleware applies; images stay inline, as there is no blob store to
+// fetch them from.
+func ReadCurrentConversation(ctx context.Context, agent agents.Agent, disc Discoverer, newParser func(agentName, convID string) Parser, middleware ...Middleware) (ConversationFile, []ConversationEvent, error) {
+	result, err := disc.FindConversations(agent.Name, agent.WorkDir)
+	if err != nil {
+		return ConversationFile{}, nil, fmt.Errorf("discover conversations for %s: %w", agent.Name, err)
+	}
+	// Files come newest first; subagent transcripts are not the agent's own.
+	i := slices.IndexFunc(result.Files, func(f ConversationFile) bool { return !f.IsSubagent })
+	if i < 0 {
+		return ConversationFile{}, nil, ErrConversationNotFound
+	}
+	file := result.Files[i]
+
+	w := NewConversationWatcher(nil, math.MaxInt)
+	defer w.Stop()
+	w.Use(middleware...)
+	w.blobs = nil
+	// Nothing listens for the watcher's events; the buffer is what's wanted.
+	go func() {
+		for {
+			select {
+			case <-w.events:
+			case <-w.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	stream := &conversationStream{
+		conversationID: file.ConversationID,
+		agent:          agent,
+		buffer:         NewConversationBuffer(file.ConversationID, agent.Name, math.MaxInt),
+		cancel:         func() {},
+	}
+	fs := &fileStream{path: file.Path, runtime: file.Runtime, nativeID: file.NativeConversationID, parser: newParser(agent.Name, file.ConversationID)}
+	stream.files = map[string]*fileStream{file.Path: fs}
+	if _, err := ReadLines(ctx, file.Path, 0, func(line Line) {
+		w.handleLine(stream, fs, line)
+	}); err != nil {
+		return file, nil, fmt.Errorf("read %s: %w", file.Path, err)
+	}
+	return file, stream.buffer.Snapshot(EventFilter{}), nil
+}
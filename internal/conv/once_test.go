@@ -0,0 +1,47 @@
+package conv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+func TestReadCurrentConversation(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	var content strings.Builder
+	// More lines than the watcher's event channel holds, which nothing drains.
+	for i := range 300 {
+		fmt.Fprintf(&content, `{"type":"user","uuid":"u%d","timestamp":"2026-02-14T01:44:50.253Z","message":{"role":"user","content":[{"type":"text","text":"hello %d"}]}}`+"\n", i, i)
+	}
+	if err := os.WriteFile(convPath, []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	newParser := func(agentName, convID string) Parser { return NewClaudeParser(agentName, convID) }
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+
+	if _, _, err := ReadCurrentConversation(context.Background(), agent, &mockDiscoverer{}, newParser); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("no conversations: err = %v, want ErrConversationNotFound", err)
+	}
+
+	disc := &mockDiscoverer{files: []ConversationFile{
+		{Path: filepath.Join(dir, "sub.jsonl"), ConversationID: "claude:sub", Runtime: "claude", IsSubagent: true},
+		{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"},
+	}}
+	file, events, err := ReadCurrentConversation(context.Background(), agent, disc, newParser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.ConversationID != "claude:test" {
+		t.Fatalf("read %s, want the agent's own conversation claude:test", file.ConversationID)
+	}
+	if len(events) != 300 || events[299].EventID != "u299" || events[0].StableID == "" {
+		t.Fatalf("got %d events, want all 300 in order with stable IDs", len(events))
+	}
+}
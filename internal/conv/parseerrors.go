@@ -0,0 +1,101 @@
+package conv
+
+import (
+	"sync"
+	"time"
+)
+
+// MaxRawLineCapture caps the raw input stored with a parse error.
+const MaxRawLineCapture = 8 * 1024
+
+// DefaultParseErrorHistory is the number of recent parse failures kept per conversation.
+const DefaultParseErrorHistory = 50
+
+// ParseFailure is a quarantined line that a parser could not handle.
+type ParseFailure struct {
+	ConversationID string    `json:"conversationId"`
+	AgentName      string    `json:"agentName"`
+	Path           string    `json:"path,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+	Error          string    `json:"error"`
+	RawLine        string    `json:"rawLine,omitempty"`
+	Truncated      bool      `json:"truncated,omitempty"`
+}
+
+// ParseErrorLog keeps a bounded ring of recent parse failures per conversation.
+type ParseErrorLog struct {
+	mu       sync.Mutex
+	max      int
+	failures map[string][]ParseFailure // conversation ID → oldest-first ring
+}
+
+// NewParseErrorLog creates a log retaining up to max failures per conversation.
+func NewParseErrorLog(max int) *ParseErrorLog {
+	if max <= 0 {
+		max = DefaultParseErrorHistory
+	}
+	return &ParseErrorLog{
+		max:      max,
+		failures: make(map[string][]ParseFailure),
+	}
+}
+
+// Record adds a failure, evicting the oldest entry for its conversation when full.
+func (l *ParseErrorLog) Record(f ParseFailure) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ring := l.failures[f.ConversationID]
+	if len(ring) >= l.max {
+		ring = append(ring[:0:0], ring[len(ring)-l.max+1:]...)
+	}
+	l.failures[f.ConversationID] = append(ring, f)
+}
+
+// Forget drops a conversation's failures, for when its stream is removed.
+func (l *ParseErrorLog) Forget(conversationID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, conversationID)
+}
+
+// Get returns recent failures for a conversation, or for all conversations
+// when conversationID is empty. Results are oldest-first within a conversation.
+func (l *ParseErrorLog) Get(conversationID string) []ParseFailure {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if conversationID != "" {
+		return append([]ParseFailure(nil), l.failures[conversationID]...)
+	}
+	var all []ParseFailure
+	for _, ring := range l.failures {
+		all = append(all, ring...)
+	}
+	return all
+}
+
+// captureRawLine returns the raw input capped at MaxRawLineCapture.
+func captureRawLine(raw []byte) (string, bool) {
+	if len(raw) > MaxRawLineCapture {
+		return string(raw[:MaxRawLineCapture]), true
+	}
+	return string(raw), false
+}
+
+// parseFailureFromEvent extracts a ParseFailure from a parser-emitted parse error event.
+func parseFailureFromEvent(e ConversationEvent, path string) (ParseFailure, bool) {
+	if e.Type != EventError || e.Metadata["errorKind"] != "parse" {
+		return ParseFailure{}, false
+	}
+	f := ParseFailure{
+		ConversationID: e.ConversationID,
+		AgentName:      e.AgentName,
+		Path:           path,
+		Timestamp:      e.Timestamp,
+	}
+	if len(e.Content) > 0 {
+		f.Error = e.Content[0].Text
+	}
+	f.RawLine, _ = e.Metadata["rawLine"].(string)
+	f.Truncated, _ = e.Metadata["rawTruncated"].(bool)
+	return f, true
+}
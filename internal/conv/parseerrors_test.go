@@ -0,0 +1,60 @@
+package conv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseErrorLogRing(t *testing.T) {
+	log := NewParseErrorLog(3)
+	for i := 0; i < 5; i++ {
+		log.Record(ParseFailure{ConversationID: "c1", Error: string(rune('a' + i))})
+	}
+	log.Record(ParseFailure{ConversationID: "c2", Error: "other"})
+
+	got := log.Get("c1")
+	if len(got) != 3 {
+		t.Fatalf("got %d failures, want 3", len(got))
+	}
+	if got[0].Error != "c" || got[2].Error != "e" {
+		t.Fatalf("ring = %v, want oldest c, newest e", got)
+	}
+	if all := log.Get(""); len(all) != 4 {
+		t.Fatalf("Get(\"\") returned %d failures, want 4", len(all))
+	}
+}
+
+func TestClaudeParserParseErrorCapturesRawLine(t *testing.T) {
+	parser := NewClaudeParser("test-agent", "claude:abc123")
+
+	raw := []byte(`{"type":"user", broken`)
+	events, _ := parser.Parse(raw)
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if events[0].Metadata["rawLine"] != string(raw) {
+		t.Fatalf("rawLine = %v, want %q", events[0].Metadata["rawLine"], raw)
+	}
+
+	f, ok := parseFailureFromEvent(events[0], "/tmp/x.jsonl")
+	if !ok {
+		t.Fatal("parseFailureFromEvent() ok = false, want true")
+	}
+	if f.RawLine != string(raw) || f.Path != "/tmp/x.jsonl" || !strings.HasPrefix(f.Error, "parse error:") {
+		t.Fatalf("failure = %+v", f)
+	}
+}
+
+func TestClaudeParserParseErrorCapsRawLine(t *testing.T) {
+	parser := NewClaudeParser("test-agent", "claude:abc123")
+
+	raw := []byte("{" + strings.Repeat("x", MaxRawLineCapture*2))
+	events, _ := parser.Parse(raw)
+	line, _ := events[0].Metadata["rawLine"].(string)
+	if len(line) != MaxRawLineCapture {
+		t.Fatalf("rawLine length = %d, want %d", len(line), MaxRawLineCapture)
+	}
+	if events[0].Metadata["rawTruncated"] != true {
+		t.Fatal("rawTruncated not set")
+	}
+}
@@ -0,0 +1,84 @@
+package conv
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// ParserOptions adjusts what one runtime's parser emits. Unlike a
+// subscription's EventFilter, they apply as lines are parsed, so skipped
+// events never reach the pipeline, the buffers, or any client.
+type ParserOptions struct {
+	// SkipTypes are event types dropped as they are parsed.
+	SkipTypes []string `json:"skipTypes,omitempty"`
+	// IncludeRaw keeps each event's transcript line in metadata.rawLine, cut
+	// to MaxRawLineCapture bytes (metadata.rawTruncated marks a cut line).
+	IncludeRaw bool `json:"includeRaw,omitempty"`
+}
+
+// skippableTypes are the event types ParserOptions.SkipTypes may name.
+// Parse errors are always kept: the parse-error log is built from them.
+var skippableTypes = []string{
+	EventUser, EventAssistant, EventSystem, EventToolUse, EventToolResult,
+	EventThinking, EventProgress, EventTurnEnd, EventQueueOp, EventCompaction,
+}
+
+// LoadParserOptions reads a JSON file mapping runtime names to their
+// ParserOptions, such as
+//
+//	{"claude": {"skipTypes": ["progress", "queue_op"], "includeRaw": true}}
+//
+// An empty path returns nil.
+func LoadParserOptions(path string) (map[string]ParserOptions, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("parser config: %w", err)
+	}
+	var opts map[string]ParserOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return nil, fmt.Errorf("parser config %s: %w", path, err)
+	}
+	for runtime, o := range opts {
+		if err := o.validate(); err != nil {
+			return nil, fmt.Errorf("parser config %s: runtime %s: %w", path, runtime, err)
+		}
+	}
+	return opts, nil
+}
+
+func (o ParserOptions) validate() error {
+	for _, t := range o.SkipTypes {
+		if !slices.Contains(skippableTypes, t) {
+			return fmt.Errorf("cannot skip event type %q; skippable: %v", t, skippableTypes)
+		}
+	}
+	return nil
+}
+
+// apply drops the events o skips from those parsed from raw, and attaches
+// raw to the rest if o includes it.
+func (o ParserOptions) apply(events []ConversationEvent, raw []byte) []ConversationEvent {
+	if len(o.SkipTypes) > 0 {
+		events = slices.DeleteFunc(events, func(e ConversationEvent) bool {
+			return slices.Contains(o.SkipTypes, e.Type)
+		})
+	}
+	if o.IncludeRaw && len(raw) > 0 {
+		line, truncated := captureRawLine(raw)
+		for i := range events {
+			if _, ok := events[i].Metadata["rawLine"]; ok {
+				continue // a parse error's own capture
+			}
+			setEventMeta(&events[i], "rawLine", line)
+			if truncated {
+				setEventMeta(&events[i], "rawTruncated", true)
+			}
+		}
+	}
+	return events
+}
@@ -0,0 +1,67 @@
+package conv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParserOptionsSkipAndIncludeRaw(t *testing.T) {
+	parser := NewClaudeParser("test-agent", "claude:abc123")
+	parser.SetOptions(ParserOptions{SkipTypes: []string{EventProgress, EventQueueOp}, IncludeRaw: true})
+
+	progress := []byte(`{"type":"progress","uuid":"p1","timestamp":"2026-02-14T01:44:54.307Z","data":{"type":"hook_progress","hookEvent":"SessionStart","hookName":"SessionStart:clear","command":"bd prime"}}`)
+	events, err := parser.Parse(progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("skipped progress line gave %d events: %+v", len(events), events)
+	}
+
+	user := []byte(`{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello world"}]}}`)
+	events, err = parser.Parse(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Metadata["rawLine"] != string(user) {
+		t.Fatalf("user line events = %+v, want one carrying its rawLine", events)
+	}
+
+	bad := []byte(`{"type":`)
+	events, _ = parser.Parse(bad)
+	if len(events) != 1 || events[0].Type != EventError || events[0].Metadata["rawLine"] != string(bad) {
+		t.Fatalf("parse error events = %+v, want one error keeping its rawLine", events)
+	}
+}
+
+func TestLoadParserOptions(t *testing.T) {
+	dir := t.TempDir()
+	write := func(config string) string {
+		path := filepath.Join(dir, "parser.json")
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	opts, err := LoadParserOptions(write(`{"claude": {"skipTypes": ["progress", "system"], "includeRaw": true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := opts["claude"]; len(o.SkipTypes) != 2 || !o.IncludeRaw {
+		t.Errorf("claude options = %+v", o)
+	}
+	for _, config := range []string{
+		`{"claude": {"skipTypes": ["error"]}}`,
+		`{"claude": {"skipTypes": ["chatter"]}}`,
+		`{"claude": []}`,
+	} {
+		if _, err := LoadParserOptions(write(config)); err == nil {
+			t.Errorf("LoadParserOptions(%s) succeeded, want an error", config)
+		}
+	}
+	if opts, err := LoadParserOptions(""); opts != nil || err != nil {
+		t.Errorf(`LoadParserOptions("") = %v, %v; want nil, nil`, opts, err)
+	}
+}
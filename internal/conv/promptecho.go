@@ -0,0 +1,183 @@
+package conv
+
+import (
+	"cmp"
+	"strings"
+	"time"
+)
+
+// promptEchoTTL is how long a sent prompt waits for the user event it turns
+// into before it is forgotten.
+const promptEchoTTL = 2 * time.Minute
+
+// maxPendingPrompts bounds the prompts an agent can have waiting for their
+// user events; the oldest is forgotten first.
+const maxPendingPrompts = 32
+
+// PromptTag identifies a prompt a client sent, so it can tell the user event
+// the prompt turns into from others and drop its optimistic copy.
+type PromptTag struct {
+	PromptID       string // chosen by the client
+	SubscriptionID string // the client's subscription to the agent's conversation
+	// Request is the prompt as the client asked for it, before the prompt
+	// policy or attachments changed it, for matching the send_prompt call
+	// of an agent that sent it (see linkPromptCalls). Empty means the text
+	// passed to ExpectPrompt.
+	Request string
+}
+
+// SentPrompt is a prompt the watcher is looking for in its agent's
+// conversation; see ExpectPrompt.
+type SentPrompt struct {
+	w         *ConversationWatcher
+	agentName string
+	text      string // as compared by promptKey
+	request   string // tag.Request or text, as compared by promptKey
+	tag       PromptTag
+	sent      time.Time
+
+	echoed     chan struct{} // closed once the prompt's user event is read
+	echoedAt   time.Time
+	answered   chan struct{} // closed once the first reply after it is read
+	answeredAt time.Time
+}
+
+// ExpectPrompt records that text is being sent to agentName. The first user
+// event with the same text in the agent's main conversation within
+// promptEchoTTL gets metadata.promptId and metadata.sentVia (the
+// subscription ID) from tag, and the returned SentPrompt reports when that
+// event and the first reply after it were read.
+func (w *ConversationWatcher) ExpectPrompt(agentName, text string, tag PromptTag) *SentPrompt {
+	p := &SentPrompt{
+		w:         w,
+		agentName: agentName,
+		text:      promptKey(text),
+		request:   promptKey(cmp.Or(tag.Request, text)),
+		tag:       tag,
+		sent:      time.Now(),
+		echoed:    make(chan struct{}),
+		answered:  make(chan struct{}),
+	}
+	w.promptsMu.Lock()
+	pending := pruneExpired(w.prompts[agentName], p.sent)
+	if len(pending) >= maxPendingPrompts {
+		pending = pending[1:]
+	}
+	w.prompts[agentName] = append(pending, p)
+	w.promptsMu.Unlock()
+	return p
+}
+
+// Withdraw stops looking for the prompt, for when sending it failed.
+func (p *SentPrompt) Withdraw() {
+	w := p.w
+	w.promptsMu.Lock()
+	defer w.promptsMu.Unlock()
+	pending := w.prompts[p.agentName]
+	for i, q := range pending {
+		if q == p {
+			w.prompts[p.agentName] = append(pending[:i:i], pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// Echoed is closed once the prompt's user event has been read, at EchoedAt.
+func (p *SentPrompt) Echoed() <-chan struct{} { return p.echoed }
+
+// EchoedAt is when the prompt's user event was read. Valid once Echoed is
+// closed.
+func (p *SentPrompt) EchoedAt() time.Time { return p.echoedAt }
+
+// Answered is closed once the first reply after the prompt's user event has
+// been read, at AnsweredAt. A reply is an assistant, thinking, or tool_use
+// event.
+func (p *SentPrompt) Answered() <-chan struct{} { return p.answered }
+
+// AnsweredAt is when the first reply was read. Valid once Answered is
+// closed.
+func (p *SentPrompt) AnsweredAt() time.Time { return p.answeredAt }
+
+// claimPrompt tags event if it is the user event of a prompt passed to
+// ExpectPrompt, and marks the prompt answered if event is the first reply
+// to it.
+func (w *ConversationWatcher) claimPrompt(agentName string, event *ConversationEvent) {
+	switch event.Type {
+	case EventAssistant, EventThinking, EventToolUse:
+		w.promptsMu.Lock()
+		p, ok := w.answering[agentName]
+		delete(w.answering, agentName)
+		w.promptsMu.Unlock()
+		if ok {
+			p.answeredAt = time.Now()
+			close(p.answered)
+		}
+		return
+	case EventUser:
+	default:
+		return
+	}
+
+	w.promptsMu.Lock()
+	pending := pruneExpired(w.prompts[agentName], time.Now())
+	var claimed *SentPrompt
+	if len(pending) > 0 {
+		text := promptKey(eventText(*event))
+		for i, p := range pending {
+			if p.text == text {
+				claimed = p
+				pending = append(pending[:i:i], pending[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(pending) == 0 {
+		delete(w.prompts, agentName)
+	} else {
+		w.prompts[agentName] = pending
+	}
+	if claimed != nil {
+		w.answering[agentName] = claimed
+	}
+	w.promptsMu.Unlock()
+
+	if claimed == nil {
+		return
+	}
+	claimed.echoedAt = time.Now()
+	close(claimed.echoed)
+	if claimed.tag.PromptID != "" {
+		setEventMeta(event, "promptId", claimed.tag.PromptID)
+	}
+	if claimed.tag.SubscriptionID != "" {
+		setEventMeta(event, "sentVia", claimed.tag.SubscriptionID)
+	}
+	w.linkPromptArrival(agentName, event, claimed)
+}
+
+// pruneExpired drops the prompts sent more than promptEchoTTL before now.
+func pruneExpired(pending []*SentPrompt, now time.Time) []*SentPrompt {
+	i := 0
+	for i < len(pending) && now.Sub(pending[i].sent) > promptEchoTTL {
+		i++
+	}
+	return pending[i:]
+}
+
+// promptKey is the form prompt text and user event text are compared in:
+// runtimes record the prompt with normalized line endings and without the
+// surrounding whitespace.
+func promptKey(text string) string {
+	return strings.TrimSpace(NormalizeText(text))
+}
+
+// eventText joins an event's text blocks.
+func eventText(e ConversationEvent) string {
+	var parts []string
+	for _, b := range e.Content {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,128 @@
+package conv
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// RedactionRule is a named pattern whose matches are scrubbed from event content.
+type RedactionRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRedactionRules detect common credentials and personal data.
+// Order matters: more specific patterns run before broader ones.
+var DefaultRedactionRules = []RedactionRule{
+	{Name: "private-key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{Name: "anthropic-key", Pattern: regexp.MustCompile(`sk-ant-[A-Za-z0-9_\-]{20,}`)},
+	{Name: "openai-key", Pattern: regexp.MustCompile(`sk-(?:proj-)?[A-Za-z0-9_\-]{20,}`)},
+	{Name: "aws-access-key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "github-token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{Name: "slack-token", Pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{Name: "bearer-token", Pattern: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=\-]{20,}`)},
+	{Name: "email", Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+}
+
+// ParseRedactionPatterns compiles user-supplied regexes into rules named "custom-N".
+func ParseRedactionPatterns(patterns []string) ([]RedactionRule, error) {
+	rules := make([]RedactionRule, 0, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", p, err)
+		}
+		rules = append(rules, RedactionRule{Name: fmt.Sprintf("custom-%d", i+1), Pattern: re})
+	}
+	return rules, nil
+}
+
+// Redactor scrubs secrets from conversation events before they are buffered or streamed.
+type Redactor struct {
+	rules []RedactionRule
+}
+
+// NewRedactor creates a redactor applying the given rules in order.
+func NewRedactor(rules []RedactionRule) *Redactor {
+	return &Redactor{rules: rules}
+}
+
+// RedactEvent returns a copy of the event with all rule matches replaced by
+// "[REDACTED:<rule>]". Affected content blocks are marked Redacted; redacted
+// metadata sets Metadata["redacted"].
+func (r *Redactor) RedactEvent(e ConversationEvent) ConversationEvent {
+	if r == nil || len(r.rules) == 0 {
+		return e
+	}
+
+	if len(e.Content) > 0 {
+		blocks := make([]ContentBlock, len(e.Content))
+		for i, b := range e.Content {
+			blocks[i] = r.redactBlock(b)
+		}
+		e.Content = blocks
+	}
+
+	if len(e.Metadata) > 0 {
+		meta := make(map[string]any, len(e.Metadata))
+		changed := false
+		for k, v := range e.Metadata {
+			if s, ok := v.(string); ok {
+				if red, hit := r.redactString(s); hit {
+					meta[k] = red
+					changed = true
+					continue
+				}
+			}
+			meta[k] = v
+		}
+		if changed {
+			meta["redacted"] = true
+		}
+		e.Metadata = meta
+	}
+
+	return e
+}
+
+func (r *Redactor) redactBlock(b ContentBlock) ContentBlock {
+	hit := false
+	if s, ok := r.redactString(b.Text); ok {
+		b.Text = s
+		hit = true
+	}
+	if s, ok := r.redactString(b.Output); ok {
+		b.Output = s
+		hit = true
+	}
+	if len(b.Input) > 0 {
+		if s, ok := r.redactString(string(b.Input)); ok {
+			// A custom pattern may swallow JSON syntax; fall back to an opaque string.
+			if json.Valid([]byte(s)) {
+				b.Input = json.RawMessage(s)
+			} else {
+				b.Input = json.RawMessage(`"[REDACTED]"`)
+			}
+			hit = true
+		}
+	}
+	if hit {
+		b.Redacted = true
+	}
+	return b
+}
+
+func (r *Redactor) redactString(s string) (string, bool) {
+	if s == "" {
+		return s, false
+	}
+	hit := false
+	for _, rule := range r.rules {
+		if rule.Pattern.MatchString(s) {
+			s = rule.Pattern.ReplaceAllLiteralString(s, "[REDACTED:"+rule.Name+"]")
+			hit = true
+		}
+	}
+	return s, hit
+}
@@ -0,0 +1,106 @@
+package conv
+
+import (
+	"log"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+// agentRenamer is implemented by parsers that stamp the agent's name on
+// the events they produce.
+type agentRenamer interface {
+	SetAgentName(name string)
+}
+
+// renameAgent carries oldName's conversations over to agent after its tmux
+// session was renamed: its streams keep tailing, with events and buffers
+// naming the agent by its new name, and it stays the owner of its active
+// conversation and model. Conversation IDs don't name the agent, so they
+// are unchanged; discovery restarts under the new name and finds its files
+// already tailed.
+func (w *ConversationWatcher) renameAgent(oldName string, agent agents.Agent) {
+	newName := agent.Name
+
+	// handleLine reads a stream's agent under its files' locks, and
+	// listings under w.mu, so the update holds both, fileStream locks first.
+	w.mu.RLock()
+	var streams []*conversationStream
+	var files []*fileStream
+	for _, s := range w.streams {
+		if s.agent.Name == oldName {
+			streams = append(streams, s)
+			for _, fs := range s.files {
+				files = append(files, fs)
+			}
+		}
+	}
+	w.mu.RUnlock()
+	for _, fs := range files {
+		fs.mu.Lock()
+	}
+
+	w.mu.Lock()
+	for _, s := range streams {
+		s.agent = agent
+		s.buffer.setAgentName(newName)
+	}
+	for id, idle := range w.idle {
+		if idle.agent.Name == oldName {
+			idle.agent = agent
+			w.idle[id] = idle
+		}
+	}
+	moveKey(w.activeByAgent, oldName, newName)
+	moveKey(w.candidates, oldName, newName)
+	moveKey(w.lastActive, oldName, newName)
+	activeID := w.activeByAgent[newName]
+	if scope, ok := w.discovery[oldName]; ok {
+		scope.cancel()
+		delete(w.discovery, oldName)
+	}
+	if dw, ok := w.dirWatchers[oldName]; ok {
+		if err := dw.Close(); err != nil {
+			log.Printf("watcher: failed to close dir watcher for %s: %v", oldName, err)
+		}
+		delete(w.dirWatchers, oldName)
+	}
+	w.mu.Unlock()
+
+	for _, fs := range files {
+		if p, ok := fs.parser.(agentRenamer); ok {
+			p.SetAgentName(newName)
+		}
+		fs.mu.Unlock()
+	}
+
+	w.modelsMu.Lock()
+	moveKey(w.models, oldName, newName)
+	model := w.models[newName]
+	w.modelsMu.Unlock()
+	w.promptsMu.Lock()
+	moveKey(w.prompts, oldName, newName)
+	moveKey(w.answering, oldName, newName)
+	w.promptsMu.Unlock()
+
+	if w.store != nil {
+		w.forgetAgent(oldName)
+		err := w.store.SetActiveConversation(newName, activeID)
+		if err == nil {
+			err = w.store.SetModel(newName, model)
+		}
+		if err != nil {
+			log.Printf("watcher: store rename %s to %s: %v", oldName, newName, err)
+		}
+	}
+	log.Printf("watcher: %s renamed to %s, %d conversations carried over", oldName, newName, len(streams))
+
+	w.startWatching(agent)
+}
+
+// moveKey moves m's entry under from, if any, to to.
+func moveKey[V any](m map[string]V, from, to string) {
+	if v, ok := m[from]; ok {
+		delete(m, from)
+		m[to] = v
+	}
+}
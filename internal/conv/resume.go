@@ -0,0 +1,144 @@
+package conv
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+// ErrResumeUnsupported is returned by ResumeHint for conversations whose
+// runtime has no resume command, and for subagent sidechains, which can only
+// be resumed through their parent session.
+var ErrResumeUnsupported = errors.New("conversation cannot be resumed")
+
+// resumeCommands builds the command line that resumes a native conversation,
+// per runtime.
+var resumeCommands = map[string]func(nativeID string) []string{
+	"claude": func(id string) []string { return []string{"claude", "--resume", id} },
+	"codex":  func(id string) []string { return []string{"codex", "resume", id} },
+}
+
+// ConversationSource locates a conversation's native transcript on disk.
+type ConversationSource struct {
+	ConversationID string `json:"conversationId"`
+	AgentName      string `json:"agentName"`
+	Runtime        string `json:"runtime"`
+	NativeID       string `json:"nativeId"`
+	Path           string `json:"path"`
+	WorkDir        string `json:"workDir,omitempty"`
+	Subagent       bool   `json:"subagent,omitempty"`
+}
+
+// ResumeHint is what it takes to pick a conversation up in a new session,
+// possibly on another machine: copy the exported transcript to
+// TranscriptPath, then run Command in WorkDir with Env set.
+type ResumeHint struct {
+	ConversationSource
+	Command        []string          `json:"command"`
+	Env            map[string]string `json:"env,omitempty"`
+	TranscriptPath string            `json:"transcriptPath,omitempty"` // relative to the home directory
+	TmuxCommand    []string          `json:"tmuxCommand"`              // starts Command in a detached session
+}
+
+// Source locates a conversation's transcript. Live conversations are found
+// among the watcher's streams; with a store, past ones are found by their
+// recorded path, taking the work directory from the agent if it still runs.
+func (w *ConversationWatcher) Source(conversationID string) (ConversationSource, error) {
+	src, _, err := w.source(conversationID)
+	return src, err
+}
+
+// source is Source that also returns the agent the conversation belongs to,
+// zero if it is no longer running.
+func (w *ConversationWatcher) source(conversationID string) (ConversationSource, agents.Agent, error) {
+	conversationID = CanonicalConversationID(conversationID)
+	w.mu.RLock()
+	stream, ok := w.streams[conversationID]
+	w.mu.RUnlock()
+	if ok {
+		for _, fs := range stream.files {
+			return ConversationSource{
+				ConversationID: conversationID,
+				AgentName:      stream.agent.Name,
+				Runtime:        fs.runtime,
+				NativeID:       fs.nativeID,
+				Path:           fs.path,
+				WorkDir:        stream.agent.WorkDir,
+				Subagent:       stream.subagent,
+			}, stream.agent, nil
+		}
+	}
+
+	if w.store == nil {
+		return ConversationSource{}, agents.Agent{}, ErrConversationNotFound
+	}
+	rec, ok, err := w.store.Conversation(conversationID)
+	if err != nil {
+		return ConversationSource{}, agents.Agent{}, fmt.Errorf("load conversation: %w", err)
+	}
+	if !ok || rec.Path == "" {
+		return ConversationSource{}, agents.Agent{}, ErrConversationNotFound
+	}
+	src := ConversationSource{
+		ConversationID: rec.ID,
+		AgentName:      rec.AgentName,
+		Runtime:        rec.Runtime,
+		NativeID:       strings.TrimSuffix(filepath.Base(rec.Path), filepath.Ext(rec.Path)),
+		Path:           rec.Path,
+		Subagent:       filepath.Base(filepath.Dir(rec.Path)) == "subagents",
+	}
+	var agent agents.Agent
+	if w.registry != nil {
+		agent, _ = w.registry.GetAgent(rec.AgentName)
+		src.WorkDir = agent.WorkDir
+	}
+	return src, agent, nil
+}
+
+// ResumeHint returns the command and environment that resume a conversation
+// in a new tmux session.
+func (w *ConversationWatcher) ResumeHint(conversationID string) (ResumeHint, error) {
+	src, agent, err := w.source(conversationID)
+	if err != nil {
+		return ResumeHint{}, err
+	}
+	build, ok := resumeCommands[src.Runtime]
+	if !ok {
+		return ResumeHint{}, fmt.Errorf("%w: no resume command for runtime %q", ErrResumeUnsupported, src.Runtime)
+	}
+	if src.Subagent {
+		return ResumeHint{}, fmt.Errorf("%w: subagent conversations resume with their parent session", ErrResumeUnsupported)
+	}
+
+	hint := ResumeHint{
+		ConversationSource: src,
+		Command:            build(src.NativeID),
+		Env:                map[string]string{"GT_AGENT": src.Runtime},
+	}
+	if agent.Role != "" {
+		hint.Env["GT_ROLE"] = agent.Role
+	}
+	if agent.Rig != nil {
+		hint.Env["GT_RIG"] = *agent.Rig
+	}
+	if src.Runtime == "claude" && src.WorkDir != "" {
+		// Claude looks for the transcript under the project directory of the
+		// directory it is started in.
+		hint.TranscriptPath = filepath.Join(".claude", "projects", encodeWorkDir(src.WorkDir), src.NativeID+".jsonl")
+	}
+
+	hint.TmuxCommand = []string{"tmux", "new-session", "-d", "-s", src.AgentName}
+	if src.WorkDir != "" {
+		hint.TmuxCommand = append(hint.TmuxCommand, "-c", src.WorkDir)
+	}
+	for _, k := range []string{"GT_AGENT", "GT_ROLE", "GT_RIG"} {
+		if v, ok := hint.Env[k]; ok {
+			hint.TmuxCommand = append(hint.TmuxCommand, "-e", k+"="+v)
+		}
+	}
+	hint.TmuxCommand = append(hint.TmuxCommand, strings.Join(hint.Command, " "))
+	return hint, nil
+}
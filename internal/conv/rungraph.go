@@ -0,0 +1,159 @@
+package conv
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Run graph edge kinds.
+const (
+	EdgeSubagent = "subagent" // a Task call spawned a subagent sidechain
+	EdgePrompt   = "prompt"   // a send_prompt call prompted another agent
+)
+
+// RunGraph is the directed graph of a multi-agent run over a time window:
+// the conversations with events in it, and the subagents and agent-to-agent
+// prompts linking them.
+type RunGraph struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	Nodes []RunNode `json:"nodes"`
+	Edges []RunEdge `json:"edges"`
+}
+
+// RunNode is a conversation in a RunGraph. A conversation an edge names
+// that is not buffered (one evicted, or an agent's older conversation) has
+// only its ID and agent.
+type RunNode struct {
+	ConversationID string    `json:"conversationId"`
+	Agent          string    `json:"agent"`
+	Runtime        string    `json:"runtime,omitempty"`
+	SubagentID     string    `json:"subagentId,omitempty"` // set for a subagent sidechain
+	Events         int       `json:"events"`               // events in the window
+	FirstEventAt   time.Time `json:"firstEventAt,omitzero"`
+	LastEventAt    time.Time `json:"lastEventAt,omitzero"`
+}
+
+// RunEdge runs from the conversation that made a call to the conversation
+// it started or prompted.
+type RunEdge struct {
+	Kind        string    `json:"kind"` // EdgeSubagent or EdgePrompt
+	From        string    `json:"from"` // conversation IDs
+	To          string    `json:"to"`
+	At          time.Time `json:"at"`                    // when the first event it led to happened
+	FromEventID string    `json:"fromEventId,omitempty"` // the event holding the call
+	ToolID      string    `json:"toolId,omitempty"`      // the call's tool_use block
+	ToEventID   string    `json:"toEventId,omitempty"`   // the user event a prompt became
+	PromptID    string    `json:"promptId,omitempty"`
+}
+
+// RunGraph builds the run graph of the conversations being tailed between
+// since and until, from their buffered events: subagents from their
+// sidechains' parent links, and prompts from promptOrigin and promptTarget
+// (see linkPromptCalls). A subagent's edge is kept when the subagent has
+// events in the window; a prompt's when its user event, or its call if that
+// holds the link, falls in it.
+func (w *ConversationWatcher) RunGraph(since, until time.Time) RunGraph {
+	w.mu.RLock()
+	streams := make([]*conversationStream, 0, len(w.streams))
+	for _, s := range w.streams {
+		streams = append(streams, s)
+	}
+	w.mu.RUnlock()
+
+	in := func(t time.Time) bool { return !t.Before(since) && t.Before(until) }
+	g := RunGraph{Since: since, Until: until}
+	nodes := make(map[string]*RunNode)
+	agentOf := make(map[string]string) // conversation ID → agent, from prompt links
+	for _, s := range streams {
+		node := &RunNode{ConversationID: s.conversationID, Agent: s.buffer.AgentName(), Runtime: s.agent.Runtime}
+		var spawn *RunEdge
+		s.buffer.Range(func(e *ConversationEvent) {
+			if e.SubagentID != "" && e.ParentConvID != "" && spawn == nil {
+				node.SubagentID = e.SubagentID
+				spawn = &RunEdge{Kind: EdgeSubagent, From: e.ParentConvID, To: s.conversationID, At: e.Timestamp}
+			}
+			if spawn != nil && spawn.FromEventID == "" && e.ParentEventID != "" {
+				spawn.FromEventID = e.ParentEventID
+				spawn.ToolID, _ = e.Metadata["parentToolUseId"].(string)
+			}
+			if !in(e.Timestamp) {
+				return
+			}
+			node.Events++
+			if node.FirstEventAt.IsZero() {
+				node.FirstEventAt = e.Timestamp
+			}
+			node.LastEventAt = e.Timestamp
+			if ref, ok := promptRefMeta(e.Metadata["promptOrigin"]); ok {
+				agentOf[ref.ConversationID] = ref.Agent
+				g.Edges = append(g.Edges, RunEdge{Kind: EdgePrompt, From: ref.ConversationID, To: s.conversationID, At: e.Timestamp,
+					FromEventID: ref.EventID, ToolID: ref.ToolID, ToEventID: e.EventID, PromptID: promptIDMeta(e)})
+			}
+			for _, b := range e.Content {
+				if ref, ok := promptRefMeta(b.Metadata["promptTarget"]); ok {
+					agentOf[ref.ConversationID] = ref.Agent
+					g.Edges = append(g.Edges, RunEdge{Kind: EdgePrompt, From: s.conversationID, To: ref.ConversationID, At: e.Timestamp,
+						FromEventID: e.EventID, ToolID: b.ToolID, ToEventID: ref.EventID, PromptID: ref.PromptID})
+				}
+			}
+		})
+		if node.Events == 0 {
+			continue
+		}
+		nodes[node.ConversationID] = node
+		if spawn != nil {
+			g.Edges = append(g.Edges, *spawn)
+		}
+	}
+
+	// Calls and prompts link conversations by ID; one outside the buffers
+	// still gets a node, named after the agent the link gives.
+	for _, e := range g.Edges {
+		for _, id := range []string{e.From, e.To} {
+			if _, ok := nodes[id]; !ok {
+				nodes[id] = &RunNode{ConversationID: id, Agent: agentOf[id]}
+			}
+		}
+	}
+	for _, s := range streams {
+		if n, ok := nodes[s.conversationID]; ok && n.Events == 0 {
+			n.Agent, n.Runtime = s.buffer.AgentName(), s.agent.Runtime
+		}
+	}
+
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, *n)
+	}
+	slices.SortFunc(g.Nodes, func(a, b RunNode) int {
+		return cmp.Or(a.FirstEventAt.Compare(b.FirstEventAt), cmp.Compare(a.ConversationID, b.ConversationID))
+	})
+	slices.SortFunc(g.Edges, func(a, b RunEdge) int {
+		return cmp.Or(a.At.Compare(b.At), cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	})
+	return g
+}
+
+// promptRefMeta reads a PromptRef from event metadata: the value linking
+// set, or its JSON form in a buffer restored from a snapshot.
+func promptRefMeta(v any) (PromptRef, bool) {
+	if v == nil {
+		return PromptRef{}, false
+	}
+	if ref, ok := v.(PromptRef); ok {
+		return ref, true
+	}
+	data, err := json.Marshal(v)
+	var ref PromptRef
+	if err != nil || json.Unmarshal(data, &ref) != nil || ref.ConversationID == "" {
+		return PromptRef{}, false
+	}
+	return ref, true
+}
+
+func promptIDMeta(e *ConversationEvent) string {
+	id, _ := e.Metadata["promptId"].(string)
+	return id
+}
@@ -0,0 +1,67 @@
+package conv
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+func TestRunGraph(t *testing.T) {
+	w := NewConversationWatcher(nil, 100)
+	defer w.Stop()
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	stream := func(id, agent string) *ConversationBuffer {
+		buf := NewConversationBuffer(id, agent, 100)
+		w.streams[id] = &conversationStream{conversationID: id, agent: agents.Agent{Name: agent, Runtime: "claude"}, buffer: buf, cancel: func() {}}
+		return buf
+	}
+
+	lead := stream("claude:lead", "lead")
+	lead.Append(ConversationEvent{Type: EventUser, EventID: "l1", Timestamp: t0})
+	lead.Append(ConversationEvent{Type: EventToolUse, EventID: "l2", Timestamp: t0.Add(time.Minute), Content: []ContentBlock{
+		{Type: "tool_use", ToolName: "Task", ToolID: "toolu_task"},
+	}})
+
+	sub := stream("claude:sub", "lead")
+	sub.Append(ConversationEvent{Type: EventUser, EventID: "s1", Timestamp: t0.Add(2 * time.Minute), SubagentID: "a1", ParentConvID: "claude:lead",
+		ParentEventID: "l2", Metadata: map[string]any{"parentToolUseId": "toolu_task"}})
+
+	worker := stream("claude:worker", "worker")
+	// A link restored from a snapshot is plain JSON.
+	var restored map[string]any
+	data, _ := json.Marshal(PromptRef{Agent: "lead", ConversationID: "claude:lead", EventID: "l3", ToolID: "toolu_send"})
+	_ = json.Unmarshal(data, &restored)
+	worker.Append(ConversationEvent{Type: EventUser, EventID: "w1", Timestamp: t0.Add(3 * time.Minute),
+		Metadata: map[string]any{"promptOrigin": restored, "promptId": "p1"}})
+
+	// Another agent's work outside the window.
+	stream("claude:idle", "idler").Append(ConversationEvent{Type: EventUser, EventID: "i1", Timestamp: t0.Add(-time.Hour)})
+
+	g := w.RunGraph(t0, t0.Add(time.Hour))
+	if len(g.Nodes) != 3 {
+		t.Fatalf("nodes = %+v, want lead, sub, worker", g.Nodes)
+	}
+	if g.Nodes[0].ConversationID != "claude:lead" || g.Nodes[0].Events != 2 || g.Nodes[1].SubagentID != "a1" {
+		t.Fatalf("nodes = %+v", g.Nodes)
+	}
+	want := []RunEdge{
+		{Kind: EdgeSubagent, From: "claude:lead", To: "claude:sub", At: t0.Add(2 * time.Minute), FromEventID: "l2", ToolID: "toolu_task"},
+		{Kind: EdgePrompt, From: "claude:lead", To: "claude:worker", At: t0.Add(3 * time.Minute), FromEventID: "l3", ToolID: "toolu_send", ToEventID: "w1", PromptID: "p1"},
+	}
+	if len(g.Edges) != len(want) {
+		t.Fatalf("edges = %+v, want %+v", g.Edges, want)
+	}
+	for i := range want {
+		if g.Edges[i] != want[i] {
+			t.Errorf("edge %d = %+v, want %+v", i, g.Edges[i], want[i])
+		}
+	}
+
+	// A window holding only the prompt still names the lead's conversation.
+	g = w.RunGraph(t0.Add(150*time.Second), t0.Add(time.Hour))
+	if len(g.Nodes) != 2 || g.Nodes[0].ConversationID != "claude:lead" || g.Nodes[0].Agent != "lead" || g.Nodes[0].Events != 0 {
+		t.Fatalf("nodes = %+v, want the lead without events and the worker", g.Nodes)
+	}
+}
@@ -0,0 +1,201 @@
+package conv
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is bumped when the on-disk format changes; older files are ignored.
+const snapshotVersion = 1
+
+// snapshotTailBytes is how much of the file before the saved offset is hashed
+// to detect a file that was rewritten while the converter was down.
+const snapshotTailBytes = 256
+
+// snapshotMaxAge is how long an unconsumed snapshot is kept. Older ones are
+// deleted at startup and ignored on load, so snapshots of conversations that
+// are never seen again don't pile up when no retention limit is set.
+const snapshotMaxAge = 7 * 24 * time.Hour
+
+// bufferSnapshot is a conversation buffer persisted across restarts. Only
+// the events and their sequence numbers are kept, not the parser's state: a
+// restored conversation starts with a fresh parser, so a tool_use whose
+// tool_result arrives after the restore is not paired with it, a subagent
+// launched before the restore is not linked to its parent, and an event is
+// not merged in place with one it would have updated.
+type bufferSnapshot struct {
+	Version        int                 `json:"version"`
+	ConversationID string              `json:"conversationId"`
+	AgentName      string              `json:"agentName"`
+	SavedAt        time.Time           `json:"savedAt"`
+	NextSeq        int64               `json:"nextSeq"`
+	Files          []fileSnapshot      `json:"files"`
+	Events         []ConversationEvent `json:"events"`
+}
+
+// fileSnapshot records where tailing stopped in one conversation file.
+type fileSnapshot struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Tail   string `json:"tail"` // sha256 of the bytes just before Offset
+}
+
+func snapshotPath(dir, conversationID string) string {
+	sum := sha256.Sum256([]byte(conversationID))
+	return filepath.Join(dir, hex.EncodeToString(sum[:12])+".json.gz")
+}
+
+// tailHash hashes up to snapshotTailBytes preceding offset.
+func tailHash(path string, offset int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	start := max(offset-snapshotTailBytes, 0)
+	buf := make([]byte, offset-start)
+	if _, err := f.ReadAt(buf, start); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// saveSnapshot persists a stream's buffer and tail offsets. Each file's lock
+// is held so the offsets and buffered events describe the same lines.
+// Partial streams are not saved.
+func (w *ConversationWatcher) saveSnapshot(stream *conversationStream) error {
+	if stream.partial {
+		return nil // restoring it would pass for the whole history
+	}
+	snap := bufferSnapshot{
+		Version:        snapshotVersion,
+		ConversationID: stream.conversationID,
+		AgentName:      stream.agent.Name,
+		SavedAt:        time.Now(),
+	}
+	for _, fs := range stream.files {
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		tail, err := tailHash(fs.path, fs.offset)
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", fs.path, err)
+		}
+		snap.Files = append(snap.Files, fileSnapshot{Path: fs.path, Offset: fs.offset, Tail: tail})
+	}
+	snap.Events, snap.NextSeq = stream.buffer.Export()
+	if len(snap.Events) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(w.stateDir, 0755); err != nil {
+		return err
+	}
+	path := snapshotPath(w.stateDir, stream.conversationID)
+	tmp, err := os.CreateTemp(w.stateDir, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	zw := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(zw).Encode(snap); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadSnapshot returns the saved buffer for a conversation file if it still
+// lines up with the file on disk. The snapshot is consumed either way: later
+// rediscovery of the same conversation in this process must not reuse it.
+func (w *ConversationWatcher) loadSnapshot(file ConversationFile) (bufferSnapshot, fileSnapshot, bool) {
+	if w.stateDir == "" {
+		return bufferSnapshot{}, fileSnapshot{}, false
+	}
+	path := snapshotPath(w.stateDir, file.ConversationID)
+	f, err := os.Open(path)
+	if err != nil {
+		return bufferSnapshot{}, fileSnapshot{}, false
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(path)
+	}()
+
+	var snap bufferSnapshot
+	zr, err := gzip.NewReader(f)
+	if err == nil {
+		err = json.NewDecoder(io.Reader(zr)).Decode(&snap)
+	}
+	if err != nil {
+		log.Printf("watcher: ignoring unreadable snapshot for %s: %v", file.ConversationID, err)
+		return bufferSnapshot{}, fileSnapshot{}, false
+	}
+	if snap.Version != snapshotVersion || snap.ConversationID != file.ConversationID {
+		return bufferSnapshot{}, fileSnapshot{}, false
+	}
+	if time.Since(snap.SavedAt) > snapshotMaxAge {
+		log.Printf("watcher: ignoring snapshot for %s saved %s", file.ConversationID, snap.SavedAt.Format(time.RFC3339))
+		return bufferSnapshot{}, fileSnapshot{}, false
+	}
+
+	for _, fsnap := range snap.Files {
+		if fsnap.Path != file.Path {
+			continue
+		}
+		if tail, err := tailHash(file.Path, fsnap.Offset); err != nil || tail != fsnap.Tail {
+			log.Printf("watcher: %s changed since snapshot, re-reading from start", file.Path)
+			return bufferSnapshot{}, fileSnapshot{}, false
+		}
+		return snap, fsnap, true
+	}
+	return bufferSnapshot{}, fileSnapshot{}, false
+}
+
+// discardSnapshot deletes the snapshot of a collected conversation the
+// watcher has forgotten, once it has been written; nothing would load it.
+func (w *ConversationWatcher) discardSnapshot(conversationID string, idle idleStream) {
+	if w.stateDir == "" {
+		return
+	}
+	go func() {
+		<-idle.saved
+		if err := os.Remove(snapshotPath(w.stateDir, conversationID)); err != nil && !os.IsNotExist(err) {
+			log.Printf("watcher: remove snapshot of %s: %v", conversationID, err)
+		}
+	}()
+}
+
+// pruneStaleSnapshots deletes snapshots older than snapshotMaxAge.
+func (w *ConversationWatcher) pruneStaleSnapshots() {
+	if w.stateDir == "" {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(w.stateDir, "*.json.gz"))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) <= snapshotMaxAge {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			log.Printf("watcher: removed stale snapshot %s", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package conv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+func newSnapshotStream(t *testing.T, path string, offset int64) *conversationStream {
+	t.Helper()
+	buf := NewConversationBuffer("test-conv", "test-agent", 100)
+	buf.Append(makeEvent(EventUser))
+	buf.Append(makeEvent(EventAssistant))
+	return &conversationStream{
+		conversationID: "test-conv",
+		agent:          agents.Agent{Name: "test-agent"},
+		files:          map[string]*fileStream{path: {path: path, offset: offset}},
+		buffer:         buf,
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "conv.jsonl")
+	if err := os.WriteFile(path, []byte(`{"a":1}`+"\n"+`{"b":2}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewConversationWatcher(nil, 100)
+	w.SetStateDir(filepath.Join(dir, "state"))
+	if err := w.saveSnapshot(newSnapshotStream(t, path, 16)); err != nil {
+		t.Fatalf("saveSnapshot: %v", err)
+	}
+
+	file := ConversationFile{Path: path, ConversationID: "test-conv", Runtime: "claude"}
+	snap, fsnap, ok := w.loadSnapshot(file)
+	if !ok {
+		t.Fatal("expected snapshot to load")
+	}
+	if len(snap.Events) != 2 || snap.NextSeq != 2 || fsnap.Offset != 16 {
+		t.Fatalf("loaded %d events, nextSeq %d, offset %d; want 2, 2, 16", len(snap.Events), snap.NextSeq, fsnap.Offset)
+	}
+
+	restored := NewConversationBuffer("test-conv", "test-agent", 100)
+	restored.Restore(snap.Events, snap.NextSeq)
+	restored.Append(makeEvent(EventUser))
+	if events := restored.Snapshot(EventFilter{}); len(events) != 3 || events[2].Seq != 2 {
+		t.Fatalf("restored buffer did not continue sequence numbering: %+v", events)
+	}
+
+	if _, _, ok := w.loadSnapshot(file); ok {
+		t.Fatal("snapshot should be consumed after loading")
+	}
+}
+
+func TestSnapshotRejectsRewrittenFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "conv.jsonl")
+	if err := os.WriteFile(path, []byte(`{"a":1}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewConversationWatcher(nil, 100)
+	w.SetStateDir(filepath.Join(dir, "state"))
+	if err := w.saveSnapshot(newSnapshotStream(t, path, 8)); err != nil {
+		t.Fatalf("saveSnapshot: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"x":9}`+"\n"+`{"y":8}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := ConversationFile{Path: path, ConversationID: "test-conv", Runtime: "claude"}
+	if _, _, ok := w.loadSnapshot(file); ok {
+		t.Fatal("snapshot should be rejected when the file no longer matches")
+	}
+}
+
+func TestStaleSnapshotsPruned(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "conv.jsonl")
+	if err := os.WriteFile(path, []byte(`{"a":1}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewConversationWatcher(nil, 100)
+	w.SetStateDir(filepath.Join(dir, "state"))
+	if err := w.saveSnapshot(newSnapshotStream(t, path, 8)); err != nil {
+		t.Fatalf("saveSnapshot: %v", err)
+	}
+	snapPath := snapshotPath(w.stateDir, "test-conv")
+	old := time.Now().Add(-snapshotMaxAge - time.Hour)
+	if err := os.Chtimes(snapPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	w.pruneStaleSnapshots()
+	if _, err := os.Stat(snapPath); !os.IsNotExist(err) {
+		t.Fatalf("stale snapshot still present: %v", err)
+	}
+}
+
+func TestForgottenIdleSnapshotDiscarded(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "conv.jsonl")
+	if err := os.WriteFile(path, []byte(`{"a":1}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewConversationWatcher(nil, 100)
+	w.SetStateDir(filepath.Join(dir, "state"))
+	if err := w.saveSnapshot(newSnapshotStream(t, path, 8)); err != nil {
+		t.Fatalf("saveSnapshot: %v", err)
+	}
+	saved := make(chan struct{})
+	close(saved)
+	w.idle["test-conv"] = idleStream{agent: agents.Agent{Name: "test-agent"}, saved: saved}
+
+	w.mu.Lock()
+	w.forgetIdleLocked("test-agent")
+	w.mu.Unlock()
+
+	snapPath := snapshotPath(w.stateDir, "test-conv")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(snapPath); os.IsNotExist(err) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("snapshot of forgotten conversation not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
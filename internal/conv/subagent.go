@@ -0,0 +1,176 @@
+package conv
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subagentToolNames are the Claude tool names that spawn sidechain subagents.
+// Older Claude Code builds call it "Task"; newer builds renamed it "Agent".
+var subagentToolNames = map[string]bool{"Task": true, "Agent": true}
+
+// subagentLinkTTL is how long a Task invocation or sidechain waits for its
+// other half before the linker forgets it.
+const subagentLinkTTL = time.Hour
+
+// TaskLink identifies the tool_use event that spawned a subagent.
+type TaskLink struct {
+	ConversationID string // parent conversation
+	EventID        string // parent event carrying the tool_use block
+	ToolUseID      string // tool_use block ID (e.g. "toolu_...")
+}
+
+// SubagentLink identifies a subagent sidechain conversation.
+type SubagentLink struct {
+	AgentID        string
+	ConversationID string
+}
+
+type pendingTask struct {
+	link TaskLink
+	at   time.Time
+}
+
+type pendingSubagent struct {
+	link SubagentLink
+	at   time.Time
+}
+
+// SubagentLinker correlates Task tool invocations in a parent conversation
+// with the sidechain conversations they spawn. Parent and subagent files are
+// parsed concurrently, so either side may arrive first: whichever side comes
+// second receives the link.
+//
+// The two halves are matched within their parent conversation by the
+// Task's prompt, which is the sidechain's root prompt. Identical prompts are
+// matched in order, first Task to first sidechain, and a tool_result naming
+// the subagent that ran a tool_use settles that pair outright. Entries whose
+// other half never arrives are forgotten after subagentLinkTTL.
+type SubagentLinker struct {
+	mu        sync.Mutex
+	tasks     map[string][]pendingTask     // parent + \0 + prompt → unmatched Task calls, oldest first
+	subagents map[string][]pendingSubagent // parent + \0 + prompt → sidechains seen before their Task
+	now       func() time.Time
+}
+
+// NewSubagentLinker creates an empty linker.
+func NewSubagentLinker() *SubagentLinker {
+	return &SubagentLinker{
+		tasks:     make(map[string][]pendingTask),
+		subagents: make(map[string][]pendingSubagent),
+		now:       time.Now,
+	}
+}
+
+func subagentKey(parentConversationID, prompt string) string {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return ""
+	}
+	return parentConversationID + "\x00" + prompt
+}
+
+// RecordTask registers a Task invocation in link.ConversationID by its
+// prompt. If a sidechain with that root prompt was already seen, the oldest
+// such is returned. Recording the same tool_use again is a no-op.
+func (l *SubagentLinker) RecordTask(prompt string, link TaskLink) (SubagentLink, bool) {
+	if l == nil {
+		return SubagentLink{}, false
+	}
+	key := subagentKey(link.ConversationID, prompt)
+	if key == "" {
+		return SubagentLink{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.pruneLocked(now)
+	if subs := l.subagents[key]; len(subs) > 0 {
+		l.setSubagentsLocked(key, subs[1:])
+		return subs[0].link, true
+	}
+	if !slices.ContainsFunc(l.tasks[key], func(t pendingTask) bool { return t.link.ToolUseID == link.ToolUseID }) {
+		l.tasks[key] = append(l.tasks[key], pendingTask{link: link, at: now})
+	}
+	return SubagentLink{}, false
+}
+
+// ResolveSubagent looks up the Task invocation in parentConversationID for
+// a subagent's root prompt, taking the oldest unmatched one. If the Task has
+// not been parsed yet, the subagent is remembered so the parent side can
+// link it later.
+func (l *SubagentLinker) ResolveSubagent(parentConversationID, prompt string, sub SubagentLink) (TaskLink, bool) {
+	if l == nil {
+		return TaskLink{}, false
+	}
+	key := subagentKey(parentConversationID, prompt)
+	if key == "" {
+		return TaskLink{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.pruneLocked(now)
+	if tasks := l.tasks[key]; len(tasks) > 0 {
+		l.setTasksLocked(key, tasks[1:])
+		return tasks[0].link, true
+	}
+	l.subagents[key] = append(l.subagents[key], pendingSubagent{link: sub, at: now})
+	return TaskLink{}, false
+}
+
+// RecordResult settles a pair from a Task's tool_result, which names the
+// subagent that ran toolUseID: neither half is left for matching by prompt.
+// If that subagent's sidechain was waiting for its Task, its link is returned.
+func (l *SubagentLinker) RecordResult(toolUseID, agentID string) (SubagentLink, bool) {
+	if l == nil || toolUseID == "" || agentID == "" {
+		return SubagentLink{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pruneLocked(l.now())
+	for key, tasks := range l.tasks {
+		l.setTasksLocked(key, slices.DeleteFunc(tasks, func(t pendingTask) bool { return t.link.ToolUseID == toolUseID }))
+	}
+	var found SubagentLink
+	var ok bool
+	for key, subs := range l.subagents {
+		l.setSubagentsLocked(key, slices.DeleteFunc(subs, func(s pendingSubagent) bool {
+			if s.link.AgentID != agentID {
+				return false
+			}
+			found, ok = s.link, true
+			return true
+		}))
+	}
+	return found, ok
+}
+
+// pruneLocked forgets entries older than subagentLinkTTL. The caller holds l.mu.
+func (l *SubagentLinker) pruneLocked(now time.Time) {
+	cutoff := now.Add(-subagentLinkTTL)
+	for key, tasks := range l.tasks {
+		l.setTasksLocked(key, slices.DeleteFunc(tasks, func(t pendingTask) bool { return t.at.Before(cutoff) }))
+	}
+	for key, subs := range l.subagents {
+		l.setSubagentsLocked(key, slices.DeleteFunc(subs, func(s pendingSubagent) bool { return s.at.Before(cutoff) }))
+	}
+}
+
+func (l *SubagentLinker) setTasksLocked(key string, tasks []pendingTask) {
+	if len(tasks) == 0 {
+		delete(l.tasks, key)
+		return
+	}
+	l.tasks[key] = tasks
+}
+
+func (l *SubagentLinker) setSubagentsLocked(key string, subs []pendingSubagent) {
+	if len(subs) == 0 {
+		delete(l.subagents, key)
+		return
+	}
+	l.subagents[key] = subs
+}
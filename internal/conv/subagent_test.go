@@ -0,0 +1,163 @@
+package conv
+
+import (
+	"testing"
+	"time"
+)
+
+const (
+	taskLine      = `{"type":"assistant","uuid":"a-task","sessionId":"parent","timestamp":"2026-02-14T01:45:01.055Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"toolu_task","name":"Task","input":{"description":"find files","prompt":"Find all Go files"}}]}}`
+	sidechainRoot = `{"type":"user","uuid":"s1","parentUuid":null,"isSidechain":true,"agentId":"ab12","sessionId":"parent","timestamp":"2026-02-14T01:45:02.000Z","message":{"role":"user","content":"Find all Go files"}}`
+	sidechainNext = `{"type":"assistant","uuid":"s2","parentUuid":"s1","isSidechain":true,"agentId":"ab12","sessionId":"parent","timestamp":"2026-02-14T01:45:03.000Z","message":{"role":"assistant","content":[{"type":"text","text":"Found 3 files"}]}}`
+)
+
+func parseOne(t *testing.T, p *ClaudeParser, raw string) ConversationEvent {
+	t.Helper()
+	events, err := p.Parse([]byte(raw))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	return events[0]
+}
+
+func TestSubagentLinkTaskFirst(t *testing.T) {
+	linker := NewSubagentLinker()
+	parent := NewClaudeParser("agent", "claude:parent")
+	parent.SetSubagentLinker(linker)
+	sub := NewClaudeParser("agent", "claude:agent-ab12")
+	sub.SetSubagentLinker(linker)
+
+	parseOne(t, parent, taskLine)
+
+	root := parseOne(t, sub, sidechainRoot)
+	if root.ParentEventID != "a-task" {
+		t.Fatalf("root ParentEventID = %q, want %q", root.ParentEventID, "a-task")
+	}
+	if root.SubagentID != "ab12" {
+		t.Fatalf("SubagentID = %q, want %q", root.SubagentID, "ab12")
+	}
+	if root.ParentConvID != "claude:parent" {
+		t.Fatalf("ParentConvID = %q, want %q", root.ParentConvID, "claude:parent")
+	}
+	if root.Metadata["parentToolUseId"] != "toolu_task" {
+		t.Fatalf("parentToolUseId = %v, want %q", root.Metadata["parentToolUseId"], "toolu_task")
+	}
+
+	next := parseOne(t, sub, sidechainNext)
+	if next.ParentEventID != "s1" {
+		t.Fatalf("non-root ParentEventID = %q, want %q (sidechain chain preserved)", next.ParentEventID, "s1")
+	}
+	if next.Metadata["parentToolUseId"] != "toolu_task" {
+		t.Fatalf("non-root parentToolUseId = %v, want %q", next.Metadata["parentToolUseId"], "toolu_task")
+	}
+}
+
+func TestSubagentLinkSidechainFirst(t *testing.T) {
+	linker := NewSubagentLinker()
+	parent := NewClaudeParser("agent", "claude:parent")
+	parent.SetSubagentLinker(linker)
+	sub := NewClaudeParser("agent", "claude:agent-ab12")
+	sub.SetSubagentLinker(linker)
+
+	root := parseOne(t, sub, sidechainRoot)
+	if root.SubagentID != "ab12" || root.ParentConvID != "claude:parent" {
+		t.Fatalf("root = {SubagentID:%q ParentConvID:%q}, want ab12 / claude:agent:parent", root.SubagentID, root.ParentConvID)
+	}
+
+	task := parseOne(t, parent, taskLine)
+	block := task.Content[0]
+	if block.Metadata["subagentId"] != "ab12" {
+		t.Fatalf("subagentId = %v, want %q", block.Metadata["subagentId"], "ab12")
+	}
+	if block.Metadata["subagentConversationId"] != "claude:agent-ab12" {
+		t.Fatalf("subagentConversationId = %v, want %q", block.Metadata["subagentConversationId"], "claude:agent-ab12")
+	}
+}
+
+func TestSubagentToolResultAgentID(t *testing.T) {
+	parent := NewClaudeParser("agent", "claude:parent")
+
+	raw := `{"type":"user","uuid":"u9","timestamp":"2026-02-14T01:45:09.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_task","content":"done"}]},"toolUseResult":{"status":"completed","agentId":"ab12"}}`
+	e := parseOne(t, parent, raw)
+	if e.Content[0].Metadata["subagentId"] != "ab12" {
+		t.Fatalf("subagentId = %v, want %q", e.Content[0].Metadata["subagentId"], "ab12")
+	}
+}
+
+func TestSubagentIDFromFileStem(t *testing.T) {
+	sub := NewClaudeParser("agent", "claude:agent-legacy1")
+	raw := `{"type":"assistant","uuid":"s2","parentUuid":"s1","isSidechain":true,"sessionId":"parent","timestamp":"2026-02-14T01:45:03.000Z","message":{"role":"assistant","content":[{"type":"text","text":"hi"}]}}`
+	e := parseOne(t, sub, raw)
+	if e.SubagentID != "legacy1" {
+		t.Fatalf("SubagentID = %q, want %q", e.SubagentID, "legacy1")
+	}
+}
+
+func TestSubagentLinkerMatchesInOrderWithinParent(t *testing.T) {
+	l := NewSubagentLinker()
+	first := TaskLink{ConversationID: "claude:parent", EventID: "e1", ToolUseID: "toolu_1"}
+	second := TaskLink{ConversationID: "claude:parent", EventID: "e2", ToolUseID: "toolu_2"}
+	elsewhere := TaskLink{ConversationID: "claude:other", EventID: "e3", ToolUseID: "toolu_3"}
+	for _, link := range []TaskLink{elsewhere, first, first, second} { // first twice: a re-parse
+		if _, ok := l.RecordTask("Run the tests", link); ok {
+			t.Fatalf("RecordTask(%s) matched with no sidechain seen", link.ToolUseID)
+		}
+	}
+
+	var got []string
+	for _, id := range []string{"a1", "a2"} {
+		task, ok := l.ResolveSubagent("claude:parent", "Run the tests", SubagentLink{AgentID: id})
+		if !ok {
+			t.Fatalf("sidechain %s unmatched", id)
+		}
+		got = append(got, task.ToolUseID)
+	}
+	if got[0] != "toolu_1" || got[1] != "toolu_2" {
+		t.Errorf("matched %v, want toolu_1 then toolu_2", got)
+	}
+	if _, ok := l.ResolveSubagent("claude:parent", "Run the tests", SubagentLink{AgentID: "a3"}); ok {
+		t.Error("a third sidechain matched a Task from another conversation")
+	}
+}
+
+func TestSubagentLinkerResultSettlesPair(t *testing.T) {
+	l := NewSubagentLinker()
+	l.RecordTask("Run the tests", TaskLink{ConversationID: "claude:parent", ToolUseID: "toolu_1"})
+	l.RecordTask("Run the tests", TaskLink{ConversationID: "claude:parent", ToolUseID: "toolu_2"})
+
+	// toolu_1's result names a1 before a1's sidechain is parsed, so the
+	// next sidechain with that prompt belongs to toolu_2.
+	if _, ok := l.RecordResult("toolu_1", "a1"); ok {
+		t.Error("RecordResult returned a sidechain none was waiting")
+	}
+	if task, ok := l.ResolveSubagent("claude:parent", "Run the tests", SubagentLink{AgentID: "a2"}); !ok || task.ToolUseID != "toolu_2" {
+		t.Errorf("a2 matched (%+v, %v), want toolu_2", task, ok)
+	}
+
+	l.ResolveSubagent("claude:parent", "Lint", SubagentLink{AgentID: "a9", ConversationID: "claude:agent-a9"})
+	if sub, ok := l.RecordResult("toolu_9", "a9"); !ok || sub.ConversationID != "claude:agent-a9" {
+		t.Errorf("RecordResult = (%+v, %v), want the waiting sidechain", sub, ok)
+	}
+	if len(l.subagents) != 0 || len(l.tasks) != 0 {
+		t.Errorf("left %d tasks and %d sidechains pending, want none", len(l.tasks), len(l.subagents))
+	}
+}
+
+func TestSubagentLinkerForgetsStaleEntries(t *testing.T) {
+	l := NewSubagentLinker()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	l.RecordTask("never spawned", TaskLink{ConversationID: "claude:parent", ToolUseID: "toolu_1"})
+	l.ResolveSubagent("claude:parent", "orphan", SubagentLink{AgentID: "a1"})
+
+	now = now.Add(subagentLinkTTL + time.Second)
+	if _, ok := l.ResolveSubagent("claude:parent", "never spawned", SubagentLink{AgentID: "a2"}); ok {
+		t.Error("matched a Task older than subagentLinkTTL")
+	}
+	if len(l.tasks) != 0 || len(l.subagents) != 1 {
+		t.Errorf("pending tasks %d, sidechains %d; want only the new sidechain", len(l.tasks), len(l.subagents))
+	}
+}
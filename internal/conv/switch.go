@@ -0,0 +1,157 @@
+package conv
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+)
+
+// DefaultSwitchConfirm is how long a new conversation file must keep
+// receiving events before the converter switches an agent to it.
+const DefaultSwitchConfirm = 2 * time.Second
+
+// SetSwitchConfirm makes a new conversation file a candidate rather than
+// switching to it at once: it is tailed into its own buffer, and the agent
+// switches only after the file has held events for d. The previous
+// conversation keeps streaming meanwhile. A file that disappears first,
+// such as a runtime's temporary file, never causes a switch. Zero switches
+// immediately. Must be called before Start.
+func (w *ConversationWatcher) SetSwitchConfirm(d time.Duration) {
+	w.switchConfirm = d
+}
+
+// holdSwitch reports whether switching agentName to convID must wait for
+// confirmation, and if so records convID as the agent's candidate, dropping
+// any earlier one. The caller must hold w.mu for writing.
+func (w *ConversationWatcher) holdSwitch(agentName, convID string) bool {
+	if w.switchConfirm <= 0 {
+		return false
+	}
+	active := w.activeByAgent[agentName]
+	if active == "" || active == convID || w.streams[active] == nil {
+		return false
+	}
+	if prev, ok := w.candidates[agentName]; ok && prev != convID {
+		w.dropStreamLocked(prev)
+	}
+	w.candidates[agentName] = convID
+	return true
+}
+
+// confirmSwitch switches agent to the candidate stream once it has held
+// events for the confirmation period, or drops it if its file disappears.
+func (w *ConversationWatcher) confirmSwitch(ctx context.Context, agent agents.Agent, file ConversationFile, stream *conversationStream) {
+	defer crash.Recover("watcher: confirming %s's switch to %s", agent.Name, file.ConversationID)
+	ticker := time.NewTicker(max(w.switchConfirm/4, 10*time.Millisecond))
+	defer ticker.Stop()
+	var since time.Time // when the candidate was first seen holding events
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
+		if _, err := os.Stat(file.Path); err != nil {
+			w.mu.Lock()
+			if w.isCandidate(agent.Name, stream) {
+				delete(w.candidates, agent.Name)
+				w.dropStreamLocked(stream.conversationID)
+			}
+			w.mu.Unlock()
+			log.Printf("watcher: %s disappeared before %s switched to it", file.Path, agent.Name)
+			return
+		}
+		if stream.buffer.LastSeq() < 0 {
+			continue
+		}
+		if since.IsZero() {
+			since = now
+		}
+		if now.Sub(since) < w.switchConfirm {
+			continue
+		}
+
+		w.mu.Lock()
+		if !w.isCandidate(agent.Name, stream) {
+			w.mu.Unlock()
+			return
+		}
+		delete(w.candidates, agent.Name)
+		oldConvID := w.activateLocked(agent, file)
+		w.mu.Unlock()
+		w.announceActive(agent, file, oldConvID)
+		return
+	}
+}
+
+// isCandidate reports whether stream is still agentName's pending switch.
+// The caller must hold w.mu.
+func (w *ConversationWatcher) isCandidate(agentName string, stream *conversationStream) bool {
+	id := stream.conversationID
+	return w.candidates[agentName] == id && w.streams[id] == stream
+}
+
+// activateLocked makes file the agent's active conversation and stops the
+// previous one, returning its ID. Doing both under one hold of w.mu keeps
+// readers from seeing the agent with two active conversations, or none.
+// The caller must hold w.mu for writing.
+func (w *ConversationWatcher) activateLocked(agent agents.Agent, file ConversationFile) string {
+	oldConvID := w.activeByAgent[agent.Name]
+	w.activeByAgent[agent.Name] = file.ConversationID
+	if oldConvID == "" {
+		oldConvID = w.lastActive[agent.Name]
+	}
+	delete(w.lastActive, agent.Name)
+
+	// Clean up orphaned stream from the previous active conversation
+	if oldConvID != "" && oldConvID != file.ConversationID {
+		w.dropStreamLocked(oldConvID)
+		if idle, ok := w.idle[oldConvID]; ok {
+			delete(w.idle, oldConvID)
+			w.discardSnapshot(oldConvID, idle)
+		}
+	}
+	return oldConvID
+}
+
+// announceActive persists a conversation activateLocked switched to and
+// tells subscribers.
+func (w *ConversationWatcher) announceActive(agent agents.Agent, file ConversationFile, oldConvID string) {
+	w.persistConversation(agent, file)
+
+	if oldConvID != "" && oldConvID != file.ConversationID {
+		w.emitEvent(WatcherEvent{
+			Type:      "conversation-switched",
+			Agent:     &agent,
+			OldConvID: oldConvID,
+			NewConvID: file.ConversationID,
+		})
+	} else {
+		w.emitEvent(WatcherEvent{
+			Type:      "conversation-started",
+			Agent:     &agent,
+			NewConvID: file.ConversationID,
+		})
+	}
+}
+
+// dropStreamLocked stops tailing a conversation and forgets it. The caller
+// must hold w.mu for writing.
+func (w *ConversationWatcher) dropStreamLocked(conversationID string) {
+	stream, ok := w.streams[conversationID]
+	if !ok {
+		return
+	}
+	stream.cancel()
+	for _, fs := range stream.files {
+		fs.stop()
+	}
+	delete(w.streams, conversationID)
+	w.parseErrors.Forget(conversationID)
+}
@@ -2,34 +2,84 @@ package conv
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
 )
 
 // MaxReReadFileSize is the safety valve for full-file reads (Gemini strategy).
 const MaxReReadFileSize = 8 * 1024 * 1024
 
+// MaxLineSize caps the transcript line kept in memory. A longer line, such
+// as a file that is not JSONL or a writer that never ends its line, is read
+// past and dropped with a log message instead of growing without bound.
+const MaxLineSize = 64 << 20
+
+// historyReadSize is ReadLines' read buffer. Memory stays bounded by it plus
+// the longest line, however large the file.
+const historyReadSize = 1024 * 1024
+
+// Line is a complete JSONL line and its byte range in the file.
+type Line struct {
+	Data   []byte
+	Offset int64 // byte offset of the line's first byte
+	End    int64 // byte offset just past the line's newline; resume tailing here
+}
+
+// remoteConfirmPolls is how many polls in a row a polling tailer must see a
+// file shrunk or rewritten before believing it: a network filesystem's
+// attribute cache can briefly report a stale size.
+const remoteConfirmPolls = 3
+
+// remoteTailBytes is how much of the file before the offset a polling tailer
+// hashes to notice the file being rewritten in place.
+const remoteTailBytes = 4096
+
 // Tailer watches a conversation file and emits complete lines as they are appended.
 type Tailer struct {
-	path    string
-	offset  int64
-	partial []byte
-	watcher *fsnotify.Watcher
-	lines   chan []byte
-	ctx     context.Context
-	cancel  context.CancelFunc
+	path      string
+	offset    int64
+	partial   []byte
+	pending   atomic.Int64      // bytes read past the last complete line, readable from other goroutines
+	oversized bool              // the line being read is over MaxLineSize and is being dropped
+	watcher   *fsnotify.Watcher // nil for a polling tailer
+	poll      time.Duration
+	lines     chan Line
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	// Polling tailers only: the file as of the last poll, and how many
+	// polls in a row have seen it shrunk or rewritten.
+	modTime  time.Time
+	tailSum  [32]byte
+	suspects int
 }
 
 // NewTailer creates a JSONL tailer for the given file.
 // If fromStart is true, reads from the beginning (history replay).
 // If false, seeks to end (live-only).
 func NewTailer(ctx context.Context, path string, fromStart bool) (*Tailer, error) {
+	var offset int64
+	if !fromStart {
+		if info, err := os.Stat(path); err == nil {
+			offset = info.Size()
+		}
+	}
+	return NewTailerAt(ctx, path, offset)
+}
+
+// NewTailerAt creates a JSONL tailer that resumes at a byte offset,
+// typically a Line.End recorded by a previous tailer.
+func NewTailerAt(ctx context.Context, path string, offset int64) (*Tailer, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
@@ -46,16 +96,42 @@ func NewTailer(ctx context.Context, path string, fromStart bool) (*Tailer, error
 
 	t := &Tailer{
 		path:    path,
+		offset:  offset,
 		watcher: watcher,
-		lines:   make(chan []byte, 256),
+		poll:    time.Second,
+		lines:   make(chan Line, 256),
 		ctx:     tCtx,
 		cancel:  cancel,
 	}
 
-	if !fromStart {
-		if info, err := os.Stat(path); err == nil {
-			t.offset = info.Size()
+	go t.tailLoop()
+
+	return t, nil
+}
+
+// NewPollingTailerAt creates a tailer for a file on a network filesystem
+// such as NFS, where fsnotify doesn't see writes made on another host. It
+// polls every interval and reads past the offset whatever size the file
+// reports, since a cached size may be stale. An in-place rewrite, which a
+// size check misses, is caught by hashing the bytes before the offset; the
+// file is then read again from the start, as after a truncation. A shrink
+// or rewrite must persist for remoteConfirmPolls polls to count.
+func NewPollingTailerAt(ctx context.Context, path string, offset int64, interval time.Duration) (*Tailer, error) {
+	tCtx, cancel := context.WithCancel(ctx)
+	t := &Tailer{
+		path:   path,
+		offset: offset,
+		poll:   interval,
+		lines:  make(chan Line, 256),
+		ctx:    tCtx,
+		cancel: cancel,
+	}
+	if f, err := os.Open(path); err == nil {
+		if info, err := f.Stat(); err == nil {
+			t.modTime = info.ModTime()
 		}
+		t.tailSum = regionSum(f, offset)
+		_ = f.Close()
 	}
 
 	go t.tailLoop()
@@ -63,39 +139,151 @@ func NewTailer(ctx context.Context, path string, fromStart bool) (*Tailer, error
 	return t, nil
 }
 
+// Pending returns the number of bytes read past the last complete line,
+// held until the writer finishes that line.
+func (t *Tailer) Pending() int64 {
+	return t.pending.Load()
+}
+
 // Lines returns a channel of complete JSONL lines.
-func (t *Tailer) Lines() <-chan []byte {
+func (t *Tailer) Lines() <-chan Line {
 	return t.lines
 }
 
 // Stop shuts down the tailer.
 func (t *Tailer) Stop() {
 	t.cancel()
-	_ = t.watcher.Close()
+	if t.watcher != nil {
+		_ = t.watcher.Close()
+	}
+}
+
+// ReadLines reads the complete lines in path from offset to end of file and
+// calls fn for each, without a Tailer's per-line channel handoff. It is the
+// fast path for a file's existing history. The returned offset is just past
+// the last complete line; a Tailer started there picks up a trailing partial line.
+func ReadLines(ctx context.Context, path string, offset int64, fn func(Line)) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return offset, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	r := bufio.NewReaderSize(f, historyReadSize)
+	for ctx.Err() == nil {
+		data, n, oversized, err := readLine(r, nil, false)
+		start := offset
+		offset += int64(n)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return start, err
+		}
+		if oversized {
+			log.Printf("history read %s: dropped a %d-byte line at %d, over the %d-byte limit", path, n, start, MaxLineSize)
+			continue
+		}
+		if data = bytes.TrimRight(data, "\r\n"); len(data) > 0 {
+			fn(Line{Data: data, Offset: start, End: offset})
+		}
+	}
+	return offset, ctx.Err()
+}
+
+// RecentLinesOffset returns the offset of the first of the last n complete
+// lines in path, so that reading from it yields just those lines; a trailing
+// partial line is not counted. It returns 0 when the file has n lines or
+// fewer, and the end of the last complete line when n is 0.
+func RecentLinesOffset(path string, n int) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// The newline ending the last complete line is the first one found;
+	// the one before the first wanted line is newline n+1.
+	buf := make([]byte, historyReadSize)
+	found := 0
+	for end := info.Size(); end > 0; {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				continue
+			}
+			if found++; found == n+1 {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// readLine reads through the next newline and appends what it read to dst,
+// returning dst and the number of bytes read. Once dst would pass
+// MaxLineSize, or if oversized is already set, the rest of the line is read
+// and discarded, and dst comes back nil with oversized set. The error is
+// io.EOF if the file ends before the line does.
+func readLine(r *bufio.Reader, dst []byte, oversized bool) ([]byte, int, bool, error) {
+	n := 0
+	for {
+		chunk, err := r.ReadSlice('\n')
+		n += len(chunk)
+		switch {
+		case oversized:
+		case len(dst)+len(chunk) > MaxLineSize:
+			dst, oversized = nil, true
+		default:
+			dst = append(dst, chunk...)
+		}
+		if err != bufio.ErrBufferFull {
+			return dst, n, oversized, err
+		}
+	}
 }
 
 func (t *Tailer) tailLoop() {
 	defer close(t.lines)
+	defer crash.Recover("tailer %s", t.path)
 
 	// Initial read
 	t.readNewData()
 
-	// Poll fallback timer (1s with jitter)
-	pollTicker := time.NewTicker(time.Second)
+	// Poll fallback timer, the only trigger for a polling tailer
+	pollTicker := time.NewTicker(t.poll)
 	defer pollTicker.Stop()
 
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if t.watcher != nil {
+		events, errs = t.watcher.Events, t.watcher.Errors
+	}
 	for {
 		select {
 		case <-t.ctx.Done():
 			return
-		case event, ok := <-t.watcher.Events:
+		case event, ok := <-events:
 			if !ok {
 				return
 			}
 			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
 				t.readNewData()
 			}
-		case _, ok := <-t.watcher.Errors:
+		case _, ok := <-errs:
 			if !ok {
 				return
 			}
@@ -117,51 +305,104 @@ func (t *Tailer) readNewData() {
 	if err != nil {
 		return
 	}
-	if info.Size() < t.offset {
-		// File was truncated — reset
-		t.offset = 0
-		t.partial = nil
-	}
-
-	if info.Size() == t.offset {
-		return // no new data
+	if t.watcher == nil {
+		rewritten, unsure := t.rewritten(f, info)
+		if unsure {
+			return // read on only once the file's state is confirmed
+		}
+		if rewritten {
+			t.reset()
+		}
+	} else {
+		if info.Size() < t.offset {
+			t.reset() // file was truncated
+		}
+		if info.Size() == t.offset {
+			return // no new data
+		}
 	}
 
 	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
 		return
 	}
 
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 2*1024*1024), 2*1024*1024) // 2MB buffer
+	r := bufio.NewReaderSize(f, 64*1024)
+	for {
+		var n int
+		t.partial, n, t.oversized, err = readLine(r, t.partial, t.oversized)
+		t.offset += int64(n)
+		if err != nil {
+			// EOF mid-line: hold the fragment until the writer finishes the line
+			t.pending.Add(int64(n))
+			if err != io.EOF {
+				log.Printf("tailer read %s: %v", t.path, err)
+			}
+			if t.watcher == nil {
+				t.tailSum = regionSum(f, t.offset)
+			}
+			return
+		}
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(t.partial) > 0 {
-			line = append(t.partial, line...)
-			t.partial = nil
+		size := t.pending.Swap(0) + int64(n)
+		start := t.offset - size
+		data := bytes.TrimRight(t.partial, "\r\n")
+		t.partial = nil
+		if t.oversized {
+			t.oversized = false
+			log.Printf("tailer %s: dropped a %d-byte line at %d, over the %d-byte limit", t.path, size, start, MaxLineSize)
+			continue
 		}
-		if len(line) == 0 {
+		if len(data) == 0 {
 			continue
 		}
-		// Make a copy since scanner reuses the buffer
-		lineCopy := make([]byte, len(line))
-		copy(lineCopy, line)
 
 		select {
-		case t.lines <- lineCopy:
+		case t.lines <- Line{Data: data, Offset: start, End: t.offset}:
 		case <-t.ctx.Done():
 			return
 		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		log.Printf("tailer read %s: %v", t.path, err)
-	}
+// reset starts reading the file again from the beginning.
+func (t *Tailer) reset() {
+	t.offset = 0
+	t.partial = nil
+	t.oversized = false
+	t.pending.Store(0)
+	t.suspects = 0
+}
 
-	// Track current position
-	newOffset, err := f.Seek(0, io.SeekCurrent)
-	if err == nil {
-		t.offset = newOffset
+// rewritten reports whether a polled file has been truncated or rewritten
+// in place for remoteConfirmPolls polls in a row, or is unsure while fewer
+// polls have seen it. Only a changed mtime prompts the comparatively costly
+// hash.
+func (t *Tailer) rewritten(f *os.File, info os.FileInfo) (rewritten, unsure bool) {
+	suspect := info.Size() < t.offset
+	if !suspect && !info.ModTime().Equal(t.modTime) {
+		suspect = regionSum(f, t.offset) != t.tailSum
+	}
+	if !suspect {
+		t.modTime = info.ModTime()
+		t.suspects = 0
+		return false, false
+	}
+	t.suspects++
+	if t.suspects < remoteConfirmPolls {
+		return false, true
 	}
+	log.Printf("tailer: %s was truncated or rewritten, reading it again", t.path)
+	t.modTime = info.ModTime()
+	return true, false
 }
 
+// regionSum hashes up to remoteTailBytes of f preceding offset. A region
+// that can't be read, because the file is now shorter, hashes as empty.
+func regionSum(f *os.File, offset int64) [32]byte {
+	start := max(offset-remoteTailBytes, 0)
+	buf := make([]byte, offset-start)
+	if _, err := f.ReadAt(buf, start); err != nil {
+		buf = nil
+	}
+	return sha256.Sum256(buf)
+}
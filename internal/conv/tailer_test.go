@@ -1,6 +1,7 @@
 package conv
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"path/filepath"
@@ -29,8 +30,8 @@ func TestTailerFollowsAppend(t *testing.T) {
 	// Should get initial line
 	select {
 	case line := <-tailer.Lines():
-		if string(line) != `{"line":1}` {
-			t.Fatalf("first line = %q, want initial content", string(line))
+		if string(line.Data) != `{"line":1}` {
+			t.Fatalf("first line = %q, want initial content", string(line.Data))
 		}
 	case <-time.After(3 * time.Second):
 		t.Fatal("timeout waiting for initial line")
@@ -47,8 +48,8 @@ func TestTailerFollowsAppend(t *testing.T) {
 	// Should get the new line
 	select {
 	case line := <-tailer.Lines():
-		if string(line) != `{"line":2}` {
-			t.Fatalf("second line = %q, want appended content", string(line))
+		if string(line.Data) != `{"line":2}` {
+			t.Fatalf("second line = %q, want appended content", string(line.Data))
 		}
 	case <-time.After(3 * time.Second):
 		t.Fatal("timeout waiting for appended line")
@@ -76,7 +77,7 @@ func TestTailerFromEnd(t *testing.T) {
 	// Old content should NOT appear
 	select {
 	case line := <-tailer.Lines():
-		t.Fatalf("should not receive old content, got %q", string(line))
+		t.Fatalf("should not receive old content, got %q", string(line.Data))
 	case <-time.After(500 * time.Millisecond):
 		// good — no old data
 	}
@@ -92,8 +93,8 @@ func TestTailerFromEnd(t *testing.T) {
 	// New content should appear
 	select {
 	case line := <-tailer.Lines():
-		if string(line) != `{"new":true}` {
-			t.Fatalf("line = %q, want new content", string(line))
+		if string(line.Data) != `{"new":true}` {
+			t.Fatalf("line = %q, want new content", string(line.Data))
 		}
 	case <-time.After(3 * time.Second):
 		t.Fatal("timeout waiting for new line")
@@ -142,7 +143,7 @@ func TestTailerDetectsTruncation(t *testing.T) {
 	for {
 		select {
 		case line := <-tailer.Lines():
-			if string(line) == `{"t":1}` {
+			if string(line.Data) == `{"t":1}` {
 				return // success
 			}
 		case <-timeout:
@@ -187,3 +188,199 @@ func TestTailerShutdown(t *testing.T) {
 		t.Fatal("timeout waiting for channel close")
 	}
 }
+
+func TestTailerHoldsPartialLineAndReportsOffsets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.jsonl")
+
+	if err := os.WriteFile(path, []byte(`{"a":1}`+"\n"+`{"b":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tailer, err := NewTailer(ctx, path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tailer.Stop()
+
+	first := <-tailer.Lines()
+	if string(first.Data) != `{"a":1}` || first.Offset != 0 || first.End != 8 {
+		t.Fatalf("first = {%q %d %d}, want {\"a\":1} 0 8", first.Data, first.Offset, first.End)
+	}
+
+	select {
+	case line := <-tailer.Lines():
+		t.Fatalf("partial line emitted early: %q", line.Data)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("2}\n")
+	_ = f.Close()
+
+	select {
+	case line := <-tailer.Lines():
+		if string(line.Data) != `{"b":2}` || line.Offset != 8 || line.End != 16 {
+			t.Fatalf("second = {%q %d %d}, want {\"b\":2} 8 16", line.Data, line.Offset, line.End)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for completed line")
+	}
+}
+
+func TestTailerAtResumesFromOffset(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.jsonl")
+
+	if err := os.WriteFile(path, []byte(`{"a":1}`+"\n"+`{"b":2}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tailer, err := NewTailerAt(ctx, path, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tailer.Stop()
+
+	select {
+	case line := <-tailer.Lines():
+		if string(line.Data) != `{"b":2}` {
+			t.Fatalf("line = %q, want second line only", line.Data)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestReadLinesStopsBeforePartialLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.jsonl")
+
+	if err := os.WriteFile(path, []byte(`{"a":1}`+"\n\n"+`{"b":2}`+"\r\n"+`{"c":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Line
+	end, err := ReadLines(context.Background(), path, 0, func(line Line) {
+		got = append(got, line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || string(got[0].Data) != `{"a":1}` || string(got[1].Data) != `{"b":2}` {
+		t.Fatalf("lines = %+v, want {\"a\":1} and {\"b\":2}", got)
+	}
+	if got[1].Offset != 9 || got[1].End != 18 {
+		t.Fatalf("second line range = %d..%d, want 9..18", got[1].Offset, got[1].End)
+	}
+	if end != 18 {
+		t.Fatalf("end = %d, want 18 (before the partial line)", end)
+	}
+}
+
+func TestReadLinesDropsOversizedLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.jsonl")
+
+	long := bytes.Repeat([]byte("x"), MaxLineSize+1)
+	content := append(append(long, '\n'), `{"a":1}`+"\n"...)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Line
+	end, err := ReadLines(context.Background(), path, 0, func(line Line) {
+		got = append(got, line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || string(got[0].Data) != `{"a":1}` || got[0].Offset != int64(len(long)+1) {
+		t.Fatalf("lines = %d, want only {\"a\":1} after the dropped line", len(got))
+	}
+	if end != int64(len(content)) {
+		t.Fatalf("end = %d, want %d", end, len(content))
+	}
+}
+
+func TestPollingTailerDetectsInPlaceRewrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.jsonl")
+	if err := os.WriteFile(path, []byte(`{"line":1}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tailer, err := NewPollingTailerAt(ctx, path, 0, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tailer.Stop()
+
+	next := func() string {
+		t.Helper()
+		select {
+		case line := <-tailer.Lines():
+			return string(line.Data)
+		case <-time.After(3 * time.Second):
+			t.Fatal("timeout waiting for line")
+			return ""
+		}
+	}
+	if got := next(); got != `{"line":1}` {
+		t.Fatalf("first line = %q", got)
+	}
+
+	// Appends are picked up without fsnotify.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"line":2}` + "\n")
+	_ = f.Close()
+	if got := next(); got != `{"line":2}` {
+		t.Fatalf("appended line = %q", got)
+	}
+
+	// A rewrite to the same size leaves the size check blind; the hash isn't.
+	if err := os.WriteFile(path, []byte(`{"line":3}`+"\n"+`{"line":4}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(path, later, later)
+	if got := next(); got != `{"line":3}` {
+		t.Fatalf("line after rewrite = %q, want the file read again from the start", got)
+	}
+}
+
+func TestRecentLinesOffset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conv.jsonl")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\npart"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		n    int
+		want int64
+	}{
+		{n: 0, want: 14}, // past "three", before the partial line
+		{n: 1, want: 8},
+		{n: 2, want: 4},
+		{n: 3, want: 0},
+		{n: 10, want: 0},
+	}
+	for _, tt := range tests {
+		if got, err := RecentLinesOffset(path, tt.n); err != nil || got != tt.want {
+			t.Errorf("RecentLinesOffset(%d) = (%d, %v), want %d", tt.n, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package conv
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// NormalizeText prepares text from a transcript for clients: invalid UTF-8
+// sequences are dropped, and \r\n and lone \r line endings become \n.
+// Parsers run every text, thinking, and output field through it.
+func NormalizeText(s string) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "")
+	}
+	if strings.IndexByte(s, '\r') >= 0 {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+		s = strings.ReplaceAll(s, "\r", "\n")
+	}
+	return s
+}
+
+// TruncateText cuts s to at most n bytes without splitting a character.
+func TruncateText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
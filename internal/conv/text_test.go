@@ -0,0 +1,44 @@
+package conv
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNormalizeText(t *testing.T) {
+	for in, want := range map[string]string{
+		"a\r\nb\rc\n":       "a\nb\nc\n",
+		"ok\xff\xfe then":   "ok then",
+		"héllo wörld":       "héllo wörld",
+		"trailing \xe2\x82": "trailing ",
+	} {
+		if got := NormalizeText(in); got != want {
+			t.Errorf("NormalizeText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTruncateTextKeepsRunesWhole(t *testing.T) {
+	s := strings.Repeat("€", 10) // 3 bytes each
+	for n := 0; n <= len(s); n++ {
+		got := TruncateText(s, n)
+		if !utf8.ValidString(got) || len(got) > n || len(got) < n-2 {
+			t.Fatalf("TruncateText(_, %d) = %d bytes, valid %v", n, len(got), utf8.ValidString(got))
+		}
+	}
+}
+
+func TestClaudeParserTruncatesOnRuneBoundary(t *testing.T) {
+	parser := NewClaudeParser("test-agent", "claude:abc123")
+	parser.SetMaxContentSize(10)
+	raw := []byte(`{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":"€€€€€\r\n"}}`)
+	events, err := parser.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := events[0].Content[0]
+	if b.Text != "€€€" || !b.Truncated || b.OriginalBytes != 16 {
+		t.Fatalf("block = %q, truncated %v, %d bytes; want 3 whole characters of 16 bytes", b.Text, b.Truncated, b.OriginalBytes)
+	}
+}
@@ -0,0 +1,217 @@
+package conv
+
+import (
+	"cmp"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxToolMatches caps the matches, files, or counts structured from one
+// search result; the full text stays in Output.
+const maxToolMatches = 1000
+
+// maxPendingToolCalls bounds the tool calls remembered per file while their
+// results are outstanding. Calls that never get a result would otherwise
+// accumulate; past this many the oldest are simply forgotten.
+const maxPendingToolCalls = 4096
+
+// toolOutputProcessors structure a tool_result's Output into its block's
+// metadata, by tool name. Each runtime's name for the same tool maps to the
+// same processor, so clients see one shape whichever runtime ran it.
+var toolOutputProcessors = map[string]func(input json.RawMessage, b *ContentBlock){
+	"Bash":              shellOutput,
+	"shell":             shellOutput,
+	"exec_command":      shellOutput,
+	"run_shell_command": shellOutput,
+
+	"Read":      readOutput,
+	"read_file": readOutput,
+
+	"Grep":                grepOutput,
+	"grep":                grepOutput,
+	"search_file_content": grepOutput,
+}
+
+// GrepMatch is one entry of a search result's metadata: a matching line
+// (content mode), or a file's match count (count mode).
+type GrepMatch struct {
+	Path  string `json:"path,omitempty"`
+	Line  int    `json:"line,omitempty"`
+	Text  string `json:"text,omitempty"`
+	Count int    `json:"count,omitempty"`
+}
+
+type toolCall struct {
+	name  string
+	input json.RawMessage
+}
+
+// toolCalls remembers a file's tool_use blocks by tool ID, so the results
+// that answer them, which carry only the ID, can be structured by tool.
+type toolCalls struct {
+	pending map[string]toolCall
+	order   []string
+}
+
+// annotate records the event's tool calls and structures its tool results.
+func (t *toolCalls) annotate(e *ConversationEvent) {
+	for i := range e.Content {
+		b := &e.Content[i]
+		switch b.Type {
+		case "tool_use":
+			if b.ToolID == "" {
+				continue
+			}
+			if t.pending == nil {
+				t.pending = make(map[string]toolCall)
+			}
+			if len(t.order) >= maxPendingToolCalls {
+				for _, id := range t.order[:len(t.order)/2] {
+					delete(t.pending, id)
+				}
+				t.order = append(t.order[:0], t.order[len(t.order)/2:]...)
+			}
+			t.pending[b.ToolID] = toolCall{name: b.ToolName, input: b.Input}
+			t.order = append(t.order, b.ToolID)
+		case "tool_result":
+			call, ok := t.pending[b.ToolID]
+			if !ok {
+				continue
+			}
+			delete(t.pending, b.ToolID)
+			if b.ToolName == "" {
+				b.ToolName = call.name
+			}
+			if process := toolOutputProcessors[call.name]; process != nil {
+				process(call.input, b)
+			}
+		}
+	}
+}
+
+var exitCodeLine = regexp.MustCompile(`^(?:Exit code|exit code|Exit Code):? (-?\d+)`)
+
+// shellOutput sets exitCode: from the "Exit code N" line runtimes prefix to
+// a failed command's output, or 0 for a command that didn't fail.
+func shellOutput(_ json.RawMessage, b *ContentBlock) {
+	if m := exitCodeLine.FindStringSubmatch(strings.TrimSpace(b.Output)); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		setBlockMeta(b, "exitCode", code)
+		return
+	}
+	if !b.IsError {
+		setBlockMeta(b, "exitCode", 0)
+	}
+}
+
+// numberedLine matches a line of cat -n style file output: Claude writes
+// "    12→text", other runtimes a tab after the number.
+var numberedLine = regexp.MustCompile(`^\s*(\d+)(?:→|\t)`)
+
+// readOutput sets path, and the range of lines returned: startLine and
+// endLine when the output is line-numbered, and lineCount.
+func readOutput(input json.RawMessage, b *ContentBlock) {
+	var args struct {
+		FilePath     string `json:"file_path"`
+		Path         string `json:"path"`
+		AbsolutePath string `json:"absolute_path"`
+	}
+	_ = json.Unmarshal(input, &args)
+	if path := cmp.Or(args.FilePath, args.AbsolutePath, args.Path); path != "" {
+		setBlockMeta(b, "path", path)
+	}
+	if b.IsError || b.Output == "" {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(b.Output, "\n"), "\n")
+	start, end, numbered := 0, 0, 0
+	for _, line := range lines {
+		m := numberedLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		if numbered == 0 {
+			start = n
+		}
+		end = n
+		numbered++
+	}
+	if numbered > 0 {
+		setBlockMeta(b, "startLine", start)
+		setBlockMeta(b, "endLine", end)
+		setBlockMeta(b, "lineCount", numbered)
+		return
+	}
+	setBlockMeta(b, "lineCount", len(lines))
+}
+
+var (
+	grepSummary   = regexp.MustCompile(`^(?:Found \d+ (?:files?|total occurrences?.*)|No (?:files|matches) found\.?)$`)
+	grepPathLine  = regexp.MustCompile(`^(.+?):(\d+)[:-](.*)$`)
+	grepLine      = regexp.MustCompile(`^(\d+)[:-](.*)$`)
+	grepPathCount = regexp.MustCompile(`^(.+):(\d+)$`)
+)
+
+// grepOutput sets mode and the result as a list: files for
+// files_with_matches, matches ([]GrepMatch with path, line, and text) for
+// content, and counts ([]GrepMatch with path and count) for count. A list
+// cut at maxToolMatches sets matchesTruncated.
+func grepOutput(input json.RawMessage, b *ContentBlock) {
+	var args struct {
+		Path       string `json:"path"`
+		OutputMode string `json:"output_mode"`
+	}
+	_ = json.Unmarshal(input, &args)
+	mode := cmp.Or(args.OutputMode, "files_with_matches")
+	setBlockMeta(b, "mode", mode)
+	if b.IsError {
+		return
+	}
+
+	var files []string
+	var matches []GrepMatch
+	truncated := false
+	for _, line := range strings.Split(b.Output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line == "--" || grepSummary.MatchString(line) {
+			continue
+		}
+		if len(files)+len(matches) >= maxToolMatches {
+			truncated = true
+			break
+		}
+		switch mode {
+		case "content":
+			if m := grepPathLine.FindStringSubmatch(line); m != nil {
+				n, _ := strconv.Atoi(m[2])
+				matches = append(matches, GrepMatch{Path: m[1], Line: n, Text: m[3]})
+			} else if m := grepLine.FindStringSubmatch(line); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				matches = append(matches, GrepMatch{Path: args.Path, Line: n, Text: m[2]})
+			} else {
+				matches = append(matches, GrepMatch{Path: args.Path, Text: line})
+			}
+		case "count":
+			if m := grepPathCount.FindStringSubmatch(line); m != nil {
+				n, _ := strconv.Atoi(m[2])
+				matches = append(matches, GrepMatch{Path: m[1], Count: n})
+			}
+		default:
+			files = append(files, line)
+		}
+	}
+	switch mode {
+	case "content":
+		setBlockMeta(b, "matches", matches)
+	case "count":
+		setBlockMeta(b, "counts", matches)
+	default:
+		setBlockMeta(b, "files", files)
+	}
+	if truncated {
+		setBlockMeta(b, "matchesTruncated", true)
+	}
+}
@@ -0,0 +1,73 @@
+package conv
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// toolRoundTrip feeds a tool call and its result through a toolCalls and
+// returns the annotated result block.
+func toolRoundTrip(t *testing.T, name, input string, result ContentBlock) ContentBlock {
+	t.Helper()
+	var calls toolCalls
+	use := ConversationEvent{Type: EventToolUse, Content: []ContentBlock{{Type: "tool_use", ToolID: "t1", ToolName: name, Input: json.RawMessage(input)}}}
+	calls.annotate(&use)
+	result.Type, result.ToolID = "tool_result", "t1"
+	res := ConversationEvent{Type: EventToolResult, Content: []ContentBlock{result}}
+	calls.annotate(&res)
+	if res.Content[0].ToolName != name {
+		t.Fatalf("result ToolName = %q, want %q", res.Content[0].ToolName, name)
+	}
+	if len(calls.pending) != 0 {
+		t.Fatalf("call still pending after its result: %v", calls.pending)
+	}
+	return res.Content[0]
+}
+
+func TestToolOutputShellExitCode(t *testing.T) {
+	b := toolRoundTrip(t, "Bash", `{"command":"go test"}`, ContentBlock{Output: "Exit code 2\nFAIL", IsError: true})
+	if b.Metadata["exitCode"] != 2 {
+		t.Fatalf("failed exitCode = %v", b.Metadata["exitCode"])
+	}
+	b = toolRoundTrip(t, "run_shell_command", `{"command":"ls"}`, ContentBlock{Output: "a\nb"})
+	if b.Metadata["exitCode"] != 0 {
+		t.Fatalf("success exitCode = %v", b.Metadata["exitCode"])
+	}
+}
+
+func TestToolOutputRead(t *testing.T) {
+	b := toolRoundTrip(t, "Read", `{"file_path":"/src/main.go","offset":10}`, ContentBlock{Output: "    10→package main\n    11→\n    12→func main() {}\n"})
+	want := map[string]any{"path": "/src/main.go", "startLine": 10, "endLine": 12, "lineCount": 3}
+	for k, v := range want {
+		if b.Metadata[k] != v {
+			t.Errorf("%s = %v, want %v", k, b.Metadata[k], v)
+		}
+	}
+}
+
+func TestToolOutputGrep(t *testing.T) {
+	b := toolRoundTrip(t, "Grep", `{"pattern":"TODO"}`, ContentBlock{Output: "Found 2 files\n/src/a.go\n/src/b.go"})
+	if files, _ := b.Metadata["files"].([]string); !slices.Equal(files, []string{"/src/a.go", "/src/b.go"}) {
+		t.Fatalf("files = %v", b.Metadata["files"])
+	}
+
+	b = toolRoundTrip(t, "Grep", `{"pattern":"TODO","output_mode":"content"}`, ContentBlock{Output: "/src/a.go:3:// TODO: x\n--\n/src/b.go:9-context"})
+	matches, _ := b.Metadata["matches"].([]GrepMatch)
+	want := []GrepMatch{{Path: "/src/a.go", Line: 3, Text: "// TODO: x"}, {Path: "/src/b.go", Line: 9, Text: "context"}}
+	if !slices.Equal(matches, want) {
+		t.Fatalf("matches = %+v, want %+v", matches, want)
+	}
+
+	b = toolRoundTrip(t, "Grep", `{"pattern":"TODO","output_mode":"count"}`, ContentBlock{Output: "/src/a.go:4\n\nFound 4 total occurrences across 1 file."})
+	if counts, _ := b.Metadata["counts"].([]GrepMatch); !slices.Equal(counts, []GrepMatch{{Path: "/src/a.go", Count: 4}}) {
+		t.Fatalf("counts = %+v", b.Metadata["counts"])
+	}
+}
+
+func TestToolOutputUnknownToolUntouched(t *testing.T) {
+	b := toolRoundTrip(t, "WebFetch", `{"url":"https://example.com"}`, ContentBlock{Output: "Exit code 1"})
+	if b.Metadata != nil {
+		t.Fatalf("unknown tool got metadata %v", b.Metadata)
+	}
+}
@@ -0,0 +1,188 @@
+package conv
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// maxVerifyIDs caps the stable IDs a VerifyReport lists as missing or extra.
+const maxVerifyIDs = 20
+
+// VerifyReport compares a conversation's buffer with what its transcript
+// yields when read again from disk, up to the offsets tailing has reached.
+// Annotations are left out: they are not in the transcript.
+type VerifyReport struct {
+	ConversationID string         `json:"conversationId"`
+	Files          []VerifiedFile `json:"files"`
+	DiskEvents     int            `json:"diskEvents"`   // events the transcript yields
+	BufferEvents   int            `json:"bufferEvents"` // transcript events still buffered
+	Evicted        bool           `json:"evicted"`      // older events have left the buffer; only the rest are compared
+	LastDisk       EventRef       `json:"lastDisk"`
+	LastBuffer     EventRef       `json:"lastBuffer"`
+	Missing        []string       `json:"missing,omitempty"` // stable IDs on disk but not buffered, at most maxVerifyIDs
+	Extra          []string       `json:"extra,omitempty"`   // stable IDs buffered but not on disk, at most maxVerifyIDs
+	MissingCount   int            `json:"missingCount"`
+	ExtraCount     int            `json:"extraCount"`
+	Match          bool           `json:"match"`
+}
+
+// VerifiedFile is one transcript file read back by Verify.
+type VerifiedFile struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"` // tailing's position, where reading back stopped
+	Lines  int    `json:"lines"`
+	Errors int    `json:"errors"` // lines that failed to parse
+}
+
+// EventRef names an event by both of its IDs.
+type EventRef struct {
+	EventID  string `json:"eventId,omitempty"`
+	StableID string `json:"stableId,omitempty"`
+}
+
+// Verify re-reads and re-parses a live conversation's transcript and
+// compares the result with its buffer, to confirm tailing kept the two in
+// step. Events pass through middleware again, so ones it drops are not
+// expected in the buffer.
+func (w *ConversationWatcher) Verify(conversationID string) (VerifyReport, error) {
+	conversationID = CanonicalConversationID(conversationID)
+	w.mu.RLock()
+	stream, ok := w.streams[conversationID]
+	var files []*fileStream
+	if ok {
+		for _, fs := range stream.files {
+			files = append(files, fs)
+		}
+	}
+	w.mu.RUnlock()
+	if !ok {
+		return VerifyReport{}, ErrConversationNotFound
+	}
+	slices.SortFunc(files, func(a, b *fileStream) int { return cmp.Compare(a.path, b.path) })
+
+	// The offsets and the buffer are taken under the files' locks, so they
+	// describe the same lines; reading back happens after, leaving tailing
+	// free to go on.
+	offsets := make([]int64, len(files))
+	for i, fs := range files {
+		fs.mu.Lock()
+		offsets[i] = fs.offset
+	}
+	minSeq := stream.buffer.MinSeq()
+	events := stream.buffer.Snapshot(EventFilter{})
+	for _, fs := range files {
+		fs.mu.Unlock()
+	}
+
+	report := VerifyReport{ConversationID: conversationID}
+	var disk []EventRef
+	for i, fs := range files {
+		vf, refs, err := w.reparse(stream, fs, offsets[i])
+		if err != nil {
+			return VerifyReport{}, fmt.Errorf("read %s: %w", fs.path, err)
+		}
+		report.Files = append(report.Files, vf)
+		disk = append(disk, refs...)
+	}
+
+	var buffered []EventRef
+	for _, e := range events {
+		if e.Type != EventAnnotation {
+			buffered = append(buffered, EventRef{EventID: e.EventID, StableID: e.StableID})
+		}
+	}
+	report.compare(disk, buffered, minSeq > 0)
+	return report, nil
+}
+
+// reparse reads fs from the start to offset with a fresh parser and returns
+// the events the watcher would have buffered from it. Updates to an event
+// read earlier are folded into it, as the buffer does.
+func (w *ConversationWatcher) reparse(stream *conversationStream, fs *fileStream, offset int64) (VerifiedFile, []EventRef, error) {
+	vf := VerifiedFile{Path: fs.path, Offset: offset}
+	factory := w.parserFactory[fs.runtime]
+	if factory == nil {
+		return vf, nil, fmt.Errorf("no parser for runtime %q", fs.runtime)
+	}
+	parser := factory(stream.agent.Name, stream.conversationID)
+	if p, ok := parser.(*ClaudeParser); ok {
+		// Parsing again must not record Task invocations twice.
+		p.SetSubagentLinker(nil)
+	}
+
+	var refs []EventRef
+	seen := make(map[string]bool) // EventIDs read so far, for folding updates
+	_, err := ReadLines(w.ctx, fs.path, 0, func(line Line) {
+		if line.End > offset {
+			return
+		}
+		vf.Lines++
+		events, err := parser.Parse(line.Data)
+		if err != nil {
+			vf.Errors++
+			return
+		}
+		for i, event := range events {
+			event.StableID = StableEventID(fs.runtime, fs.nativeID, line.Offset, i)
+			event, keep := w.pipeline.Process(event)
+			if !keep {
+				continue
+			}
+			if (event.Delta || event.Replace) && event.EventID != "" && seen[event.EventID] {
+				continue
+			}
+			if event.EventID != "" {
+				seen[event.EventID] = true
+			}
+			refs = append(refs, EventRef{EventID: event.EventID, StableID: event.StableID})
+		}
+	})
+	return vf, refs, err
+}
+
+// compare fills in r from the events read from disk and those buffered,
+// both oldest first. If the buffer has evicted events, disk events older
+// than its oldest are not expected in it.
+func (r *VerifyReport) compare(disk, buffered []EventRef, evicted bool) {
+	r.DiskEvents, r.BufferEvents, r.Evicted = len(disk), len(buffered), evicted
+	if len(disk) > 0 {
+		r.LastDisk = disk[len(disk)-1]
+	}
+	if len(buffered) > 0 {
+		r.LastBuffer = buffered[len(buffered)-1]
+	}
+
+	inDisk := make(map[string]bool, len(disk))
+	for _, ref := range disk {
+		inDisk[ref.StableID] = true
+	}
+	inBuffer := make(map[string]bool, len(buffered))
+	for _, ref := range buffered {
+		inBuffer[ref.StableID] = true
+		if !inDisk[ref.StableID] {
+			r.ExtraCount++
+			if len(r.Extra) < maxVerifyIDs {
+				r.Extra = append(r.Extra, ref.StableID)
+			}
+		}
+	}
+
+	expected := disk
+	if evicted && len(buffered) > 0 {
+		if i := slices.IndexFunc(disk, func(ref EventRef) bool { return ref.StableID == buffered[0].StableID }); i >= 0 {
+			expected = disk[i:]
+		}
+	}
+	for _, ref := range expected {
+		if !inBuffer[ref.StableID] {
+			r.MissingCount++
+			if len(r.Missing) < maxVerifyIDs {
+				r.Missing = append(r.Missing, ref.StableID)
+			}
+		}
+	}
+	// Events from several files interleave in the buffer in the order they
+	// were read, so only a single file's last event must come last.
+	r.Match = r.MissingCount == 0 && r.ExtraCount == 0 && (len(r.Files) > 1 || r.LastDisk == r.LastBuffer)
+}
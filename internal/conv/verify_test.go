@@ -0,0 +1,91 @@
+package conv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+func TestWatcherVerify(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	var content strings.Builder
+	for i := range 3 {
+		fmt.Fprintf(&content, `{"type":"user","uuid":"u%d","timestamp":"2026-02-14T01:44:5%d.253Z","message":{"role":"user","content":[{"type":"text","text":"hello %d"}]}}`+"\n", i, i, i)
+	}
+	if err := os.WriteFile(convPath, []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	if _, err := watcher.Verify("claude:test"); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("Verify of an unknown conversation: err = %v, want ErrConversationNotFound", err)
+	}
+
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	watcher.startConversationStream(agent, file)
+	buf := waitForBufferLen(t, watcher, file.ConversationID, 3)
+
+	report, err := watcher.Verify(file.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Match || report.DiskEvents != 3 || report.BufferEvents != 3 || report.Files[0].Lines != 3 {
+		t.Fatalf("report = %+v, want a match over 3 events", report)
+	}
+	if report.LastDisk.EventID != "u2" {
+		t.Fatalf("last disk event = %+v, want u2", report.LastDisk)
+	}
+
+	// An event tailing never read from the file.
+	buf.Append(ConversationEvent{Type: EventUser, EventID: "u9", StableID: "bogus"})
+	// Annotations are not in the transcript and are left out.
+	buf.Append(ConversationEvent{Type: EventAnnotation, EventID: "note"})
+	report, err = watcher.Verify(file.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Match || report.ExtraCount != 1 || report.Extra[0] != "bogus" || report.MissingCount != 0 {
+		t.Fatalf("report = %+v, want one extra event", report)
+	}
+}
+
+func TestVerifyReportCompare(t *testing.T) {
+	refs := func(ids ...string) []EventRef {
+		var r []EventRef
+		for _, id := range ids {
+			r = append(r, EventRef{StableID: id})
+		}
+		return r
+	}
+	tests := []struct {
+		name           string
+		disk, buffer   []EventRef
+		evicted        bool
+		missing, extra int
+		match          bool
+	}{
+		{"equal", refs("a", "b", "c"), refs("a", "b", "c"), false, 0, 0, true},
+		{"evicted head", refs("a", "b", "c"), refs("b", "c"), true, 0, 0, true},
+		{"lost head", refs("a", "b", "c"), refs("b", "c"), false, 1, 0, false},
+		{"gap", refs("a", "b", "c", "d"), refs("b", "d"), true, 1, 0, false},
+		{"behind", refs("a", "b", "c"), refs("a", "b"), false, 1, 0, false},
+	}
+	for _, tt := range tests {
+		r := VerifyReport{Files: make([]VerifiedFile, 1)}
+		r.compare(tt.disk, tt.buffer, tt.evicted)
+		if r.MissingCount != tt.missing || r.ExtraCount != tt.extra || r.Match != tt.match {
+			t.Errorf("%s: missing %d, extra %d, match %v; want %d, %d, %v", tt.name, r.MissingCount, r.ExtraCount, r.Match, tt.missing, tt.extra, tt.match)
+		}
+	}
+}
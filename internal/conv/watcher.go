@@ -10,21 +10,77 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+	"github.com/gastownhall/tmux-adapter/internal/store"
 )
 
 // WatcherEvent represents a lifecycle or conversation event from the watcher.
 type WatcherEvent struct {
-	Type      string              // "agent-added", "agent-removed", "agent-updated", "conversation-started", "conversation-switched", "conversation-event"
-	Agent     *agents.Agent       // for lifecycle events
-	Event     *ConversationEvent  // for conversation events
-	OldConvID string              // for conversation-switched events
-	NewConvID string              // for conversation-started and conversation-switched events
+	Type      string             // "agent-added", "agent-removed", "agent-updated", "agent-renamed", "agent-model-changed", "conversation-started", "conversation-switched", "conversation-event", "conversation-event-updated"
+	Agent     *agents.Agent      // for lifecycle events
+	Event     *ConversationEvent // for conversation events
+	Added     []ContentBlock     // for conversation-event-updated by a delta: the content it added
+	OldConvID string             // for conversation-switched events
+	NewConvID string             // for conversation-started and conversation-switched events
+	OldModel  string             // for agent-model-changed events; "" when first seen
+	NewModel  string             // for agent-model-changed events
+	Reason    string             // for agent-removed events: agents.ReasonProcessExited, or ""
+	OldName   string             // for agent-renamed events: the agent's previous name
 }
 
 type fileStream struct {
-	path   string
-	tailer *Tailer
-	parser Parser
+	path     string
+	runtime  string
+	nativeID string
+	info     os.FileInfo // identity of the file when tailing started; its size is the history to load
+	parser   Parser
+
+	// mu covers handling one line end to end, so offset always matches
+	// what has been appended to the buffer.
+	mu     sync.Mutex
+	offset int64     // end of the last line handled; tailing resumes here
+	tailer *Tailer   // nil until the existing history has been read
+	lastTS time.Time // timestamp of the last event handled, for normalizeTimestamp
+	tools  toolCalls // tool calls awaiting results, for structuring their output
+
+	// annotations are stored annotations still to be replayed among the
+	// history being read, oldest first.
+	annotations []ConversationEvent
+}
+
+// maxClockSkew is how far a transcript timestamp may run ahead of the time
+// the line was read before it is treated as skewed.
+const maxClockSkew = 5 * time.Second
+
+// normalizeTimestamp stamps ReceivedAt and repairs Timestamp so events from
+// one file never lack a time: a missing timestamp takes the previous event's
+// (or, for the first event, the receive time), and one further ahead of the
+// receive time than maxClockSkew is pulled back to it. Repaired timestamps
+// are marked TimestampEstimated. The caller holds fs.mu.
+func (fs *fileStream) normalizeTimestamp(event *ConversationEvent, received time.Time) {
+	event.ReceivedAt = received
+	switch {
+	case event.Timestamp.IsZero():
+		event.Timestamp = received
+		if !fs.lastTS.IsZero() {
+			event.Timestamp = fs.lastTS
+		}
+		event.TimestampEstimated = true
+	case event.Timestamp.After(received.Add(maxClockSkew)):
+		event.Timestamp = received
+		event.TimestampEstimated = true
+	}
+	fs.lastTS = event.Timestamp
+}
+
+// stop stops the file's tailer. The stream's context must already be
+// cancelled, which keeps a tailer from starting if history is still loading.
+func (fs *fileStream) stop() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.tailer != nil {
+		fs.tailer.Stop()
+	}
 }
 
 type conversationStream struct {
@@ -33,6 +89,15 @@ type conversationStream struct {
 	files          map[string]*fileStream
 	buffer         *ConversationBuffer
 	cancel         context.CancelFunc
+	subagent       bool
+	partial        bool // tailing started near the end, skipping older history
+}
+
+// tails reports whether the stream is already tailing path and the file there
+// is still the same one (not replaced or rotated into place).
+func (s *conversationStream) tails(path string, info os.FileInfo) bool {
+	fs, ok := s.files[path]
+	return ok && fs.info != nil && info != nil && os.SameFile(fs.info, info)
 }
 
 // ConversationWatcher orchestrates discovery, tailing, and parsing for all active agents.
@@ -42,14 +107,55 @@ type ConversationWatcher struct {
 	parserFactory map[string]func(agentName, convID string) Parser
 	streams       map[string]*conversationStream // keyed by conversation ID
 	activeByAgent map[string]string              // agent name → active conversation ID
+	candidates    map[string]string              // agent name → conversation waiting to become active
+	switchConfirm time.Duration
+	idleTTL       time.Duration
+	eagerTail     func(agentName string) bool // agents exempt from idleTTL
+	idle          map[string]idleStream       // conversation ID → stream stopped for being idle
+	remotePoll    map[string]time.Duration    // runtime → poll interval, for files on network filesystems
 	events        chan WatcherEvent
 	bufferSize    int
+	pipeline      Pipeline
+	parseErrors   *ParseErrorLog
+	stateDir      string // where buffer snapshots are kept across restarts; "" disables
+	store         store.Store
+	blobs         *BlobStore
+	lastActive    map[string]string // agent name → active conversation before the restart, until rediscovered
 	mu            sync.RWMutex
 	ctx           context.Context
 	cancel        context.CancelFunc
 
+	// models holds each agent's model as of its latest main-conversation
+	// reply. Its own lock: handleLine runs under a fileStream lock, which
+	// must not be held while taking mu.
+	models   map[string]string
+	modelsMu sync.Mutex
+
+	// prompts holds, per agent, prompts sent through ExpectPrompt that
+	// have not yet shown up as user events, and answering the one whose
+	// reply is awaited. Their own lock, as for models.
+	prompts   map[string][]*SentPrompt
+	answering map[string]*SentPrompt
+	promptsMu sync.Mutex
+
+	// promptCalls holds, per target agent, send_prompt calls whose user
+	// events have not been read; promptEchoes, per agent, user events of
+	// prompts sent through ExpectPrompt whose calls have not been read.
+	// Both under promptsMu; see linkPromptCalls.
+	promptCalls  map[string][]promptEnd
+	promptEchoes map[string][]promptEnd
+
 	// Directory watchers for conversation rotation
 	dirWatchers map[string]*fsnotify.Watcher // agent name → directory watcher
+
+	// discovery cancels an agent's in-flight discovery and retries when it
+	// is removed, so a retry can't start tailing an agent that is gone.
+	discovery map[string]discoveryScope // agent name → scope
+}
+
+type discoveryScope struct {
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewConversationWatcher creates a new watcher.
@@ -64,11 +170,23 @@ func NewConversationWatcher(registry *agents.Registry, bufferSize int) *Conversa
 		parserFactory: make(map[string]func(agentName, convID string) Parser),
 		streams:       make(map[string]*conversationStream),
 		activeByAgent: make(map[string]string),
+		candidates:    make(map[string]string),
+		idle:          make(map[string]idleStream),
+		remotePoll:    make(map[string]time.Duration),
+		lastActive:    make(map[string]string),
+		models:        make(map[string]string),
+		prompts:       make(map[string][]*SentPrompt),
+		answering:     make(map[string]*SentPrompt),
+		promptCalls:   make(map[string][]promptEnd),
+		promptEchoes:  make(map[string][]promptEnd),
 		events:        make(chan WatcherEvent, 256),
 		bufferSize:    bufferSize,
+		parseErrors:   NewParseErrorLog(DefaultParseErrorHistory),
+		blobs:         NewBlobStore("", DefaultBlobMemoryBytes),
 		ctx:           ctx,
 		cancel:        cancel,
 		dirWatchers:   make(map[string]*fsnotify.Watcher),
+		discovery:     make(map[string]discoveryScope),
 	}
 }
 
@@ -78,13 +196,75 @@ func (w *ConversationWatcher) RegisterRuntime(runtime string, disc Discoverer, f
 	w.parserFactory[runtime] = factory
 }
 
+// DefaultRemotePoll is how often conversation files on a network
+// filesystem are polled; see SetRemoteFilesystem.
+const DefaultRemotePoll = 500 * time.Millisecond
+
+// remoteDiscoveryInterval is how often conversations of a runtime on a
+// network filesystem are discovered again, standing in for the directory
+// notifications that don't arrive there.
+const remoteDiscoveryInterval = 5 * time.Second
+
+// SetRemoteFilesystem treats runtime's conversation files as living on a
+// network filesystem such as NFS, written from another host: they are
+// tailed by polling every interval (see NewPollingTailerAt) rather than
+// through fsnotify, and discovery runs again every
+// remoteDiscoveryInterval to find new conversations. Must be called before
+// Start.
+func (w *ConversationWatcher) SetRemoteFilesystem(runtime string, interval time.Duration) {
+	w.remotePoll[runtime] = interval
+}
+
+// Use appends middleware applied to every parsed event before it is buffered.
+// Must be called before Start.
+func (w *ConversationWatcher) Use(mw ...Middleware) {
+	w.pipeline = append(w.pipeline, mw...)
+}
+
+// HasMiddleware reports whether events are rewritten before buffering, so
+// the raw transcript holds what clients are not shown, such as redacted text.
+func (w *ConversationWatcher) HasMiddleware() bool {
+	return len(w.pipeline) > 0
+}
+
+// SetStateDir enables buffer snapshots: Stop saves each conversation's buffer
+// and tail offset under dir, and a restarted watcher resumes from them instead
+// of re-parsing the files. Must be called before Start.
+func (w *ConversationWatcher) SetStateDir(dir string) {
+	w.stateDir = dir
+}
+
+// SetStore persists conversation ownership, active conversations, and
+// current models to st, and restores them on Start: a conversation that
+// changed while the watcher was down is reported as conversation-switched,
+// and CurrentModel answers before the agent's next reply. Must be called
+// before Start.
+func (w *ConversationWatcher) SetStore(st store.Store) {
+	w.store = st
+}
+
+// SetBlobDir keeps images moved out of events under dir as well as in
+// memory, so the blob IDs in restored snapshots stay fetchable. Must be
+// called before Start.
+func (w *ConversationWatcher) SetBlobDir(dir string) {
+	w.blobs = NewBlobStore(dir, DefaultBlobMemoryBytes)
+}
+
+// Blob returns the data behind a content block's BlobID.
+func (w *ConversationWatcher) Blob(id string) ([]byte, error) {
+	return w.blobs.Get(id)
+}
+
 // Events returns the channel for receiving watcher events.
 func (w *ConversationWatcher) Events() <-chan WatcherEvent {
 	return w.events
 }
 
 // GetBuffer returns the conversation buffer for a given conversation ID.
+// Like every method taking a conversation ID, it also accepts the ID's older
+// form (see CanonicalConversationID).
 func (w *ConversationWatcher) GetBuffer(conversationID string) *ConversationBuffer {
+	conversationID = CanonicalConversationID(conversationID)
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 	if s, ok := w.streams[conversationID]; ok {
@@ -93,6 +273,12 @@ func (w *ConversationWatcher) GetBuffer(conversationID string) *ConversationBuff
 	return nil
 }
 
+// GetParseErrors returns recent parse failures for a conversation,
+// or for all conversations when conversationID is empty.
+func (w *ConversationWatcher) GetParseErrors(conversationID string) []ParseFailure {
+	return w.parseErrors.Get(CanonicalConversationID(conversationID))
+}
+
 // GetActiveConversation returns the active conversation ID for an agent.
 func (w *ConversationWatcher) GetActiveConversation(agentName string) string {
 	w.mu.RLock()
@@ -100,6 +286,27 @@ func (w *ConversationWatcher) GetActiveConversation(agentName string) string {
 	return w.activeByAgent[agentName]
 }
 
+// CurrentModel returns the model of the agent's latest main-conversation
+// reply, or "" if none has been seen.
+func (w *ConversationWatcher) CurrentModel(agentName string) string {
+	w.modelsMu.Lock()
+	defer w.modelsMu.Unlock()
+	return w.models[agentName]
+}
+
+// Activity returns how recently agentName's active conversation changed,
+// zero if it has none.
+func (w *ConversationWatcher) Activity(agentName string) Activity {
+	w.mu.RLock()
+	stream, ok := w.streams[w.activeByAgent[agentName]]
+	idle := w.idle[w.activeByAgent[agentName]]
+	w.mu.RUnlock()
+	if !ok {
+		return idle.activity
+	}
+	return stream.buffer.Activity()
+}
+
 // ListAgents returns all agents from the registry.
 func (w *ConversationWatcher) ListAgents() []agents.Agent {
 	return w.registry.GetAgents()
@@ -117,18 +324,134 @@ func (w *ConversationWatcher) ListConversations() []ConversationInfo {
 			Runtime:        s.agent.Runtime,
 		})
 	}
+	for id, idle := range w.idle {
+		result = append(result, ConversationInfo{
+			ConversationID: id,
+			AgentName:      idle.agent.Name,
+			Runtime:        idle.agent.Runtime,
+			Idle:           true,
+		})
+	}
 	return result
 }
 
-// ConversationInfo is metadata about an active conversation.
+// ConversationInfo is metadata about an active conversation. Idle marks one
+// no longer tailed for lack of activity (see SetIdleTTL); subscribing
+// tails it again.
 type ConversationInfo struct {
 	ConversationID string `json:"conversationId"`
 	AgentName      string `json:"agentName"`
 	Runtime        string `json:"runtime"`
+	Idle           bool   `json:"idle,omitempty"`
+}
+
+// ConversationStats is introspection data about a live conversation stream.
+type ConversationStats struct {
+	ConversationID string      `json:"conversationId"`
+	AgentName      string      `json:"agentName"`
+	Runtime        string      `json:"runtime"`
+	Active         bool        `json:"active"`
+	Files          []string    `json:"files"`
+	Buffer         BufferStats `json:"buffer"`
+	HistoryDone    bool        `json:"historyDone"` // existing history fully read; see LoadProgress
+}
+
+// Stats returns per-conversation tailing and buffer details.
+func (w *ConversationWatcher) Stats() []ConversationStats {
+	w.mu.RLock()
+	streams := make([]*conversationStream, 0, len(w.streams))
+	result := make([]ConversationStats, 0, len(w.streams))
+	for id, s := range w.streams {
+		files := make([]string, 0, len(s.files))
+		for path := range s.files {
+			files = append(files, path)
+		}
+		streams = append(streams, s)
+		result = append(result, ConversationStats{
+			ConversationID: id,
+			AgentName:      s.agent.Name,
+			Runtime:        s.agent.Runtime,
+			Active:         w.activeByAgent[s.agent.Name] == id,
+			Files:          files,
+		})
+	}
+	w.mu.RUnlock()
+	// Buffer and file locks are taken after w.mu is released: handleLine
+	// holds a file's lock while it takes w.mu.
+	for i, s := range streams {
+		_, _, loading := s.loadProgress()
+		result[i].Buffer = s.buffer.Stats()
+		result[i].HistoryDone = !loading
+	}
+	return result
+}
+
+// LoadProgress reports how much of a conversation's existing history has been
+// read: bytes consumed so far against the files' sizes when tailing began.
+// loading is false once that history has been read (or the ID is unknown).
+func (w *ConversationWatcher) LoadProgress(conversationID string) (read, total int64, loading bool) {
+	w.mu.RLock()
+	stream, ok := w.streams[CanonicalConversationID(conversationID)]
+	w.mu.RUnlock()
+	if !ok {
+		return 0, 0, false
+	}
+	return stream.loadProgress()
+}
+
+func (s *conversationStream) loadProgress() (read, total int64, loading bool) {
+	for _, fs := range s.files {
+		if fs.info == nil {
+			continue
+		}
+		fs.mu.Lock()
+		offset := fs.offset
+		if fs.tailer != nil {
+			// A trailing partial line will never become a line until more is written.
+			offset += fs.tailer.Pending()
+		}
+		fs.mu.Unlock()
+		size := fs.info.Size()
+		read += min(offset, size)
+		total += size
+	}
+	return read, total, read < total
+}
+
+// ReleaseConversation force-stops tailing a conversation and drops its buffer.
+// Directory watchers stay in place, so a new write re-discovers it.
+// Returns false if the conversation is not being tailed.
+func (w *ConversationWatcher) ReleaseConversation(conversationID string) bool {
+	conversationID = CanonicalConversationID(conversationID)
+	w.mu.Lock()
+	stream, ok := w.streams[conversationID]
+	if ok {
+		delete(w.streams, conversationID)
+		w.parseErrors.Forget(conversationID)
+		if w.activeByAgent[stream.agent.Name] == conversationID {
+			delete(w.activeByAgent, stream.agent.Name)
+		}
+		if w.candidates[stream.agent.Name] == conversationID {
+			delete(w.candidates, stream.agent.Name)
+		}
+	}
+	w.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	stream.cancel()
+	for _, fs := range stream.files {
+		fs.stop()
+	}
+	return true
 }
 
 // Start begins watching for agent changes and starts tailing conversations.
 func (w *ConversationWatcher) Start() {
+	w.pruneStaleSnapshots()
+	w.restoreState()
+
 	// Process initial agents
 	for _, agent := range w.registry.GetAgents() {
 		w.emitEvent(WatcherEvent{Type: "agent-added", Agent: &agent})
@@ -136,6 +459,9 @@ func (w *ConversationWatcher) Start() {
 	}
 
 	go w.watchLoop()
+	if w.idleTTL > 0 {
+		go w.idleLoop()
+	}
 }
 
 // Stop shuts down the watcher and all tailers.
@@ -148,9 +474,20 @@ func (w *ConversationWatcher) Stop() {
 	for _, s := range w.streams {
 		s.cancel()
 		for _, fs := range s.files {
-			fs.tailer.Stop()
+			fs.stop()
 		}
 	}
+	if w.stateDir != "" {
+		saved := 0
+		for id, s := range w.streams {
+			if err := w.saveSnapshot(s); err != nil {
+				log.Printf("watcher: snapshot %s: %v", id, err)
+				continue
+			}
+			saved++
+		}
+		log.Printf("watcher: saved %d conversation snapshots to %s", saved, w.stateDir)
+	}
 	for name, dw := range w.dirWatchers {
 		if err := dw.Close(); err != nil {
 			log.Printf("watcher: failed to close dir watcher for %s: %v", name, err)
@@ -167,20 +504,28 @@ func (w *ConversationWatcher) watchLoop() {
 			if !ok {
 				return
 			}
-			switch event.Type {
-			case "added":
-				w.emitEvent(WatcherEvent{Type: "agent-added", Agent: &event.Agent})
-				w.startWatching(event.Agent)
-			case "removed":
-				w.stopWatching(event.Agent.Name)
-				w.emitEvent(WatcherEvent{Type: "agent-removed", Agent: &event.Agent})
-			case "updated":
-				w.emitEvent(WatcherEvent{Type: "agent-updated", Agent: &event.Agent})
-			}
+			w.handleRegistryEvent(event)
 		}
 	}
 }
 
+func (w *ConversationWatcher) handleRegistryEvent(event agents.RegistryEvent) {
+	defer crash.Recover("watcher: %s event for %s", event.Type, event.Agent.Name)
+	switch event.Type {
+	case "added":
+		w.emitEvent(WatcherEvent{Type: "agent-added", Agent: &event.Agent})
+		w.startWatching(event.Agent)
+	case "removed":
+		w.stopWatching(event.Agent.Name)
+		w.emitEvent(WatcherEvent{Type: "agent-removed", Agent: &event.Agent, Reason: event.Reason})
+	case "updated":
+		w.emitEvent(WatcherEvent{Type: "agent-updated", Agent: &event.Agent})
+	case "renamed":
+		w.renameAgent(event.OldName, event.Agent)
+		w.emitEvent(WatcherEvent{Type: "agent-renamed", Agent: &event.Agent, OldName: event.OldName})
+	}
+}
+
 func (w *ConversationWatcher) startWatching(agent agents.Agent) {
 	disc, ok := w.discoverers[agent.Runtime]
 	if !ok {
@@ -188,30 +533,88 @@ func (w *ConversationWatcher) startWatching(agent agents.Agent) {
 		return
 	}
 
+	ctx, cancel := context.WithCancel(w.ctx)
+	w.mu.Lock()
+	if prev, ok := w.discovery[agent.Name]; ok {
+		prev.cancel()
+	}
+	w.discovery[agent.Name] = discoveryScope{ctx: ctx, cancel: cancel}
+	w.mu.Unlock()
+
 	// Non-blocking: spawn goroutine for discovery
-	go w.discoverAndTail(agent, disc)
+	go w.discoverAndTail(ctx, agent, disc)
+	if _, remote := w.remotePoll[agent.Runtime]; remote {
+		go w.pollDiscovery(ctx, agent, disc)
+	}
 }
 
-func (w *ConversationWatcher) discoverAndTail(agent agents.Agent, disc Discoverer) {
+// pollDiscovery discovers agent's conversations again every
+// remoteDiscoveryInterval and tails any new ones.
+func (w *ConversationWatcher) pollDiscovery(ctx context.Context, agent agents.Agent, disc Discoverer) {
+	defer crash.Recover("watcher: polling discovery for %s", agent.Name)
+	ticker := time.NewTicker(remoteDiscoveryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		result, err := disc.FindConversations(agent.Name, agent.WorkDir)
+		if err != nil || ctx.Err() != nil {
+			continue
+		}
+		w.tailDiscovered(agent, result.Files)
+	}
+}
+
+// discoveryContext returns the context scoping discovery for agentName,
+// or a cancelled one if the agent isn't being watched.
+func (w *ConversationWatcher) discoveryContext(agentName string) context.Context {
+	w.mu.RLock()
+	scope, ok := w.discovery[agentName]
+	w.mu.RUnlock()
+	if ok {
+		return scope.ctx
+	}
+	ctx, cancel := context.WithCancel(w.ctx)
+	cancel()
+	return ctx
+}
+
+func (w *ConversationWatcher) discoverAndTail(ctx context.Context, agent agents.Agent, disc Discoverer) {
+	defer crash.Recover("watcher: discovery for %s", agent.Name)
+	if ctx.Err() != nil {
+		return
+	}
 	result, err := disc.FindConversations(agent.Name, agent.WorkDir)
 	if err != nil {
 		log.Printf("watcher: discovery error for %s: %v", agent.Name, err)
 		return
 	}
+	if ctx.Err() != nil {
+		return // removed while discovery ran
+	}
 
 	// Watch directories for conversation rotation
 	w.watchDirectories(agent.Name, result.WatchDirs)
 
 	if len(result.Files) == 0 {
 		log.Printf("watcher: no conversation files found for %s, watching directories", agent.Name)
-		go w.retryDiscovery(agent, disc)
+		go w.retryDiscovery(ctx, agent, disc)
 		return
 	}
+	w.tailDiscovered(agent, result.Files)
+}
 
+// tailDiscovered starts streams for the agent's current conversation and
+// its subagents among files. Files already tailed keep their streams, and
+// idle ones stay collected until written to or subscribed (see SetIdleTTL).
+func (w *ConversationWatcher) tailDiscovered(agent agents.Agent, files []ConversationFile) {
 	// Separate non-subagent and subagent files.
 	// Discovery returns files sorted by mtime descending (most recent first).
 	var mainFiles []ConversationFile
-	for _, f := range result.Files {
+	for _, f := range files {
 		if !f.IsSubagent {
 			mainFiles = append(mainFiles, f)
 		}
@@ -221,18 +624,40 @@ func (w *ConversationWatcher) discoverAndTail(agent agents.Agent, disc Discovere
 		// Most recent file is first — it becomes the active conversation.
 		// Only stream the current conversation file; older files are past sessions.
 		currentFile := mainFiles[0]
-		w.startConversationStream(agent, currentFile)
+		if !w.settled(currentFile) {
+			w.startConversationStream(agent, currentFile)
+		}
 	}
 
 	// Also start subagent streams
-	for _, f := range result.Files {
-		if f.IsSubagent {
+	for _, f := range files {
+		if f.IsSubagent && !w.settled(f) {
 			w.startConversationStream(agent, f)
 		}
 	}
 }
 
+// settled reports whether file needs no new stream: it is already tailed,
+// or was collected for being idle.
+func (w *ConversationWatcher) settled(file ConversationFile) bool {
+	info, _ := os.Stat(file.Path)
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if _, idle := w.idle[file.ConversationID]; idle {
+		return true
+	}
+	s, ok := w.streams[file.ConversationID]
+	return ok && s.tails(file.Path, info)
+}
+
 func (w *ConversationWatcher) startConversationStream(agent agents.Agent, file ConversationFile) {
+	w.startConversationStreamFrom(agent, file, FullHistory)
+}
+
+// startConversationStreamFrom is startConversationStream reading only about
+// the last recent lines of history, or all of it for FullHistory. A snapshot
+// is used when there is one, as it holds the whole history already.
+func (w *ConversationWatcher) startConversationStreamFrom(agent agents.Agent, file ConversationFile, recent int) {
 	factory, ok := w.parserFactory[file.Runtime]
 	if !ok {
 		return
@@ -240,20 +665,33 @@ func (w *ConversationWatcher) startConversationStream(agent agents.Agent, file C
 
 	streamCtx, streamCancel := context.WithCancel(w.ctx)
 
-	tailer, err := NewTailer(streamCtx, file.Path, true)
-	if err != nil {
-		log.Printf("watcher: tailer error for %s: %v", file.Path, err)
-		streamCancel()
-		return
+	buffer := NewConversationBuffer(file.ConversationID, agent.Name, w.bufferSize)
+	var offset int64
+	restored := false
+	if snap, fsnap, ok := w.loadSnapshot(file); ok {
+		buffer.Restore(snap.Events, snap.NextSeq)
+		offset = fsnap.Offset
+		restored = true
+		log.Printf("watcher: restored %d events for %s, resuming at byte %d", len(snap.Events), file.ConversationID, offset)
+	} else if recent != FullHistory {
+		if from, err := RecentLinesOffset(file.Path, recent); err == nil {
+			offset = from
+		}
 	}
 
 	parser := factory(agent.Name, file.ConversationID)
-	buffer := NewConversationBuffer(file.ConversationID, agent.Name, w.bufferSize)
+	info, _ := os.Stat(file.Path)
 
 	fs := &fileStream{
-		path:   file.Path,
-		tailer: tailer,
-		parser: parser,
+		path:     file.Path,
+		runtime:  file.Runtime,
+		nativeID: file.NativeConversationID,
+		info:     info,
+		parser:   parser,
+		offset:   offset,
+	}
+	if !restored { // a snapshot already holds the annotations made before it
+		fs.annotations = w.loadAnnotations(file.ConversationID, agent.Name, file.Runtime)
 	}
 
 	stream := &conversationStream{
@@ -262,91 +700,268 @@ func (w *ConversationWatcher) startConversationStream(agent agents.Agent, file C
 		files:          map[string]*fileStream{file.Path: fs},
 		buffer:         buffer,
 		cancel:         streamCancel,
+		subagent:       file.IsSubagent,
+		partial:        !restored && offset > 0,
 	}
 
 	w.mu.Lock()
+	// Rediscovery of a file we're already tailing keeps the existing stream;
+	// re-reading it into a fresh buffer would replay history to subscribers.
+	if existing, ok := w.streams[file.ConversationID]; ok && existing.tails(file.Path, info) {
+		w.mu.Unlock()
+		streamCancel()
+		return
+	}
 	// Clean up any existing stream for this conversation ID (prevents goroutine/FD leaks on re-discovery)
 	if existing, ok := w.streams[file.ConversationID]; ok {
 		existing.cancel()
 		for _, efs := range existing.files {
-			efs.tailer.Stop()
+			efs.stop()
 		}
 	}
 	w.streams[file.ConversationID] = stream
-	if !file.IsSubagent {
-		oldConvID := w.activeByAgent[agent.Name]
-		w.activeByAgent[agent.Name] = file.ConversationID
-
-		// Clean up orphaned stream from the previous active conversation
-		if oldConvID != "" && oldConvID != file.ConversationID {
-			if oldStream, ok := w.streams[oldConvID]; ok {
-				oldStream.cancel()
-				for _, efs := range oldStream.files {
-					efs.tailer.Stop()
-				}
-				delete(w.streams, oldConvID)
-			}
-		}
+	_, wasIdle := w.idle[file.ConversationID]
+	delete(w.idle, file.ConversationID)
+	switch {
+	case wasIdle && !file.IsSubagent && w.activeByAgent[agent.Name] == file.ConversationID:
+		// Tailed again after going idle: still the active conversation.
 		w.mu.Unlock()
-
-		if oldConvID != "" && oldConvID != file.ConversationID {
-			w.emitEvent(WatcherEvent{
-				Type:      "conversation-switched",
-				Agent:     &agent,
-				OldConvID: oldConvID,
-				NewConvID: file.ConversationID,
-			})
-		} else {
-			w.emitEvent(WatcherEvent{
-				Type:      "conversation-started",
-				Agent:     &agent,
-				NewConvID: file.ConversationID,
-			})
-		}
-	} else {
+	case file.IsSubagent:
+		w.mu.Unlock()
+		w.persistConversation(agent, file)
+	case w.holdSwitch(agent.Name, file.ConversationID):
+		w.mu.Unlock()
+		log.Printf("watcher: %s has a new conversation %s, switching once it is confirmed", agent.Name, file.ConversationID)
+		go w.confirmSwitch(streamCtx, agent, file, stream)
+	default:
+		oldConvID := w.activateLocked(agent, file)
 		w.mu.Unlock()
+		w.announceActive(agent, file, oldConvID)
 	}
 
 	// Start parsing goroutine
-	go w.pumpFileStream(stream, fs)
+	go w.pumpFileStream(streamCtx, stream, fs)
+}
+
+// pumpFileStream reads the file's existing history in bulk, then hands the
+// offset after its last complete line to a tailer for live appends.
+func (w *ConversationWatcher) pumpFileStream(ctx context.Context, stream *conversationStream, fs *fileStream) {
+	defer crash.Recover("watcher: tailing %s for %s", stream.conversationID, stream.agent.Name)
+	start := time.Now()
+	from := fs.offset
+	offset, err := ReadLines(ctx, fs.path, from, func(line Line) {
+		w.handleLine(stream, fs, line)
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("watcher: history read error for %s: %v", fs.path, err)
+	}
+	if offset > from {
+		log.Printf("watcher: loaded %d bytes of history for %s in %s", offset-from, stream.conversationID, time.Since(start).Round(time.Millisecond))
+	}
+
+	fs.mu.Lock()
+	if ctx.Err() != nil {
+		fs.mu.Unlock()
+		return
+	}
+	w.replayAnnotations(stream, fs, time.Time{})
+	var tailer *Tailer
+	if poll, remote := w.remotePoll[fs.runtime]; remote {
+		tailer, err = NewPollingTailerAt(ctx, fs.path, offset, poll)
+	} else {
+		tailer, err = NewTailerAt(ctx, fs.path, offset)
+	}
+	if err != nil {
+		fs.mu.Unlock()
+		log.Printf("watcher: tailer error for %s: %v", fs.path, err)
+		return
+	}
+	fs.tailer = tailer
+	fs.mu.Unlock()
+
+	for line := range tailer.Lines() {
+		w.handleLine(stream, fs, line)
+	}
+}
+
+// handleLine parses, processes, and buffers one transcript line, then
+// records the models its replies came from. The model is tracked after the
+// file's lock is released: agent-model-changed blocks until delivered, and
+// its consumer may wait on w.mu, which is held while tailers are stopped.
+func (w *ConversationWatcher) handleLine(stream *conversationStream, fs *fileStream, line Line) {
+	for _, model := range w.bufferLine(stream, fs, line) {
+		w.trackModel(stream.agent, model)
+	}
 }
 
-func (w *ConversationWatcher) pumpFileStream(stream *conversationStream, fs *fileStream) {
-	for line := range fs.tailer.Lines() {
-		events, err := fs.parser.Parse(line)
-		if err != nil {
-			log.Printf("watcher: parse error for %s: %v", fs.path, err)
+// bufferLine does handleLine's work under the file's lock and returns the
+// models of the agent's own replies. A panic handling the line loses only
+// that line: a parser's is recorded as a parse error.
+func (w *ConversationWatcher) bufferLine(stream *conversationStream, fs *fileStream, line Line) (models []string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	defer crash.Recover("watcher: line at %d of %s (%s, agent %s)", line.Offset, fs.path, stream.conversationID, stream.agent.Name)
+	fs.offset = line.End
+	received := time.Now()
+
+	events, err := parseLine(fs.parser, line)
+	if err != nil {
+		log.Printf("watcher: parse error for %s: %v", fs.path, err)
+		w.quarantineLine(stream, fs, line.Data, err)
+		return
+	}
+	for i, event := range events {
+		event.StableID = StableEventID(fs.runtime, fs.nativeID, line.Offset, i)
+		markTruncatedSources(&event, line.Offset)
+		fs.normalizeTimestamp(&event, received)
+		if !stream.subagent && event.SubagentID == "" {
+			// Before middleware, which may redact the text being matched.
+			w.claimPrompt(stream.agent.Name, &event)
+		}
+		w.linkPromptCalls(stream, &event)
+		event, keep := w.pipeline.Process(event)
+		if !keep {
 			continue
 		}
-		for _, event := range events {
-			stream.buffer.Append(event)
-			w.emitEvent(WatcherEvent{
-				Type:  "conversation-event",
-				Event: &event,
-			})
+		// After middleware, so structured output is built from redacted text.
+		fs.tools.annotate(&event)
+		if err := w.blobs.externalize(&event); err != nil {
+			log.Printf("watcher: store image for %s: %v", stream.conversationID, err)
+		}
+		// Recorded after middleware so redaction also covers the quarantined line.
+		if f, ok := parseFailureFromEvent(event, fs.path); ok {
+			w.parseErrors.Record(f)
+		}
+		w.replayAnnotations(stream, fs, event.Timestamp)
+		we := WatcherEvent{Type: "conversation-event"}
+		if event.Delta || event.Replace {
+			if event.Delta {
+				we.Added = event.Content
+			}
+			var updated bool
+			if event, updated = stream.buffer.Update(event); updated {
+				we.Type = "conversation-event-updated"
+			} else {
+				we.Added = nil
+			}
+		} else {
+			event.Seq = stream.buffer.Append(event)
 		}
+		we.Event = &event
+		w.emitEvent(we)
+		if !stream.subagent && event.SubagentID == "" && event.Model != "" {
+			models = append(models, event.Model)
+		}
+	}
+	return models
+}
+
+// quarantineLine records a line the parser rejected. The line goes through
+// the middleware as a parse error event first, so get-parse-errors returns
+// it redacted like any other event; if the middleware drops the event, only
+// the error is kept.
+func (w *ConversationWatcher) quarantineLine(stream *conversationStream, fs *fileStream, data []byte, err error) {
+	raw, truncated := captureRawLine(data)
+	event := ConversationEvent{
+		Type:           EventError,
+		AgentName:      stream.agent.Name,
+		ConversationID: stream.conversationID,
+		Runtime:        fs.runtime,
+		Timestamp:      time.Now(),
+		Content:        []ContentBlock{{Type: "text", Text: err.Error()}},
+		Metadata:       map[string]any{"errorKind": "parse", "rawLine": raw},
+	}
+	if truncated {
+		event.Metadata["rawTruncated"] = true
+	}
+	event, keep := w.pipeline.Process(event)
+	if !keep {
+		w.parseErrors.Record(ParseFailure{
+			ConversationID: stream.conversationID,
+			AgentName:      stream.agent.Name,
+			Path:           fs.path,
+			Timestamp:      event.Timestamp,
+			Error:          err.Error(),
+		})
+		return
+	}
+	f, _ := parseFailureFromEvent(event, fs.path)
+	w.parseErrors.Record(f)
+}
+
+// parseLine parses line with p, turning a panic into an error.
+func parseLine(p Parser, line Line) (events []ConversationEvent, err error) {
+	defer crash.RecoverError(&err, "%s parser: line at %d", p.Runtime(), line.Offset)
+	return p.Parse(line.Data)
+}
+
+// trackModel records the model an agent's reply came from and emits
+// agent-model-changed when it differs from the last one seen. Replies carry
+// the model that produced them, so a /model switch shows up on the next reply.
+func (w *ConversationWatcher) trackModel(agent agents.Agent, model string) {
+	// Claude marks locally generated replies (interrupts, errors) "<synthetic>".
+	if model == "" || strings.HasPrefix(model, "<") {
+		return
 	}
+	w.modelsMu.Lock()
+	old := w.models[agent.Name]
+	if old == model {
+		w.modelsMu.Unlock()
+		return
+	}
+	w.models[agent.Name] = model
+	w.modelsMu.Unlock()
+	if w.store != nil {
+		if err := w.store.SetModel(agent.Name, model); err != nil {
+			log.Printf("watcher: store model for %s: %v", agent.Name, err)
+		}
+	}
+
+	w.emitEvent(WatcherEvent{
+		Type:     "agent-model-changed",
+		Agent:    &agent,
+		OldModel: old,
+		NewModel: model,
+	})
 }
 
 func (w *ConversationWatcher) stopWatching(agentName string) {
 	w.mu.Lock()
+	if scope, ok := w.discovery[agentName]; ok {
+		scope.cancel()
+		delete(w.discovery, agentName)
+	}
+	if candidate, ok := w.candidates[agentName]; ok {
+		delete(w.candidates, agentName)
+		w.dropStreamLocked(candidate)
+	}
+	w.forgetIdleLocked(agentName)
 	convID, ok := w.activeByAgent[agentName]
 	if !ok {
 		w.mu.Unlock()
 		return
 	}
 	delete(w.activeByAgent, agentName)
+	w.modelsMu.Lock()
+	delete(w.models, agentName)
+	w.modelsMu.Unlock()
+	w.promptsMu.Lock()
+	delete(w.prompts, agentName)
+	delete(w.answering, agentName)
+	w.promptsMu.Unlock()
 
 	stream, streamOk := w.streams[convID]
 	if streamOk {
 		delete(w.streams, convID)
 	}
+	w.parseErrors.Forget(convID)
 	w.mu.Unlock()
+	w.forgetAgent(agentName)
 
 	if streamOk {
 		stream.cancel()
 		for _, fs := range stream.files {
-			fs.tailer.Stop()
+			fs.stop()
 		}
 	}
 
@@ -361,6 +976,68 @@ func (w *ConversationWatcher) stopWatching(agentName string) {
 	w.mu.Unlock()
 }
 
+// restoreState loads the state a previous run persisted.
+func (w *ConversationWatcher) restoreState() {
+	if w.store == nil {
+		return
+	}
+	active, err := w.store.ActiveConversations()
+	if err != nil {
+		log.Printf("watcher: restore active conversations: %v", err)
+	}
+	models, err := w.store.Models()
+	if err != nil {
+		log.Printf("watcher: restore models: %v", err)
+	}
+	w.mu.Lock()
+	for name, convID := range active {
+		w.lastActive[name] = convID
+	}
+	w.mu.Unlock()
+	w.modelsMu.Lock()
+	for name, model := range models {
+		w.models[name] = model
+	}
+	w.modelsMu.Unlock()
+	if len(active) > 0 || len(models) > 0 {
+		log.Printf("watcher: restored state for %d agents", max(len(active), len(models)))
+	}
+}
+
+// persistConversation records a newly tailed conversation, and for a main
+// conversation makes it the agent's active one.
+func (w *ConversationWatcher) persistConversation(agent agents.Agent, file ConversationFile) {
+	if w.store == nil {
+		return
+	}
+	err := w.store.PutConversation(store.Conversation{
+		ID:        file.ConversationID,
+		AgentName: agent.Name,
+		Runtime:   file.Runtime,
+		Path:      file.Path,
+	})
+	if err == nil && !file.IsSubagent {
+		err = w.store.SetActiveConversation(agent.Name, file.ConversationID)
+	}
+	if err != nil {
+		log.Printf("watcher: store conversation %s: %v", file.ConversationID, err)
+	}
+}
+
+// forgetAgent clears a departed agent's persisted active conversation and model.
+func (w *ConversationWatcher) forgetAgent(agentName string) {
+	if w.store == nil {
+		return
+	}
+	err := w.store.SetActiveConversation(agentName, "")
+	if err == nil {
+		err = w.store.SetModel(agentName, "")
+	}
+	if err != nil {
+		log.Printf("watcher: store forget %s: %v", agentName, err)
+	}
+}
+
 func (w *ConversationWatcher) watchDirectories(agentName string, dirs []string) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -398,18 +1075,7 @@ func (w *ConversationWatcher) watchDirectoryLoop(agentName string, watcher *fsno
 			if !ok {
 				return
 			}
-			if event.Has(fsnotify.Create) && strings.HasSuffix(event.Name, ".jsonl") {
-				// New conversation file detected — re-discover
-				w.mu.RLock()
-				agent, agentOk := w.findAgentByName(agentName)
-				w.mu.RUnlock()
-				if agentOk {
-					disc, discOk := w.discoverers[agent.Runtime]
-					if discOk {
-						go w.discoverAndTail(agent, disc)
-					}
-				}
-			}
+			w.handleDirectoryEvent(agentName, event)
 		case _, ok := <-watcher.Errors:
 			if !ok {
 				return
@@ -418,6 +1084,25 @@ func (w *ConversationWatcher) watchDirectoryLoop(agentName string, watcher *fsno
 	}
 }
 
+func (w *ConversationWatcher) handleDirectoryEvent(agentName string, event fsnotify.Event) {
+	defer crash.Recover("watcher: %s in %s's conversation directory", event, agentName)
+	if event.Has(fsnotify.Write) && w.idleTTL > 0 {
+		w.retailPath(event.Name)
+	}
+	if event.Has(fsnotify.Create) && strings.HasSuffix(event.Name, ".jsonl") {
+		// New conversation file detected — re-discover
+		w.mu.RLock()
+		agent, agentOk := w.findAgentByName(agentName)
+		w.mu.RUnlock()
+		if agentOk {
+			disc, discOk := w.discoverers[agent.Runtime]
+			if discOk {
+				go w.discoverAndTail(w.discoveryContext(agentName), agent, disc)
+			}
+		}
+	}
+}
+
 func (w *ConversationWatcher) findAgentByName(name string) (agents.Agent, bool) {
 	for _, a := range w.registry.GetAgents() {
 		if a.Name == name {
@@ -427,20 +1112,20 @@ func (w *ConversationWatcher) findAgentByName(name string) (agents.Agent, bool)
 	return agents.Agent{}, false
 }
 
-func (w *ConversationWatcher) retryDiscovery(agent agents.Agent, disc Discoverer) {
+func (w *ConversationWatcher) retryDiscovery(ctx context.Context, agent agents.Agent, disc Discoverer) {
 	timer := time.NewTimer(5 * time.Second)
 	defer timer.Stop()
 	select {
-	case <-w.ctx.Done():
+	case <-ctx.Done():
 		return
 	case <-timer.C:
-		w.discoverAndTail(agent, disc)
+		w.discoverAndTail(ctx, agent, disc)
 	}
 }
 
 func (w *ConversationWatcher) emitEvent(event WatcherEvent) {
 	switch event.Type {
-	case "conversation-event":
+	case "conversation-event", "conversation-event-updated":
 		// High-volume — non-blocking send, OK to drop (buffer retains events)
 		select {
 		case w.events <- event:
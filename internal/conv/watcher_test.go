@@ -3,8 +3,13 @@ package conv
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/store"
 )
 
 // mockDiscoverer returns pre-configured discovery results.
@@ -33,7 +38,7 @@ func TestWatcherCreatesBuffer(t *testing.T) {
 		files: []ConversationFile{{
 			Path:                 convPath,
 			NativeConversationID: "test",
-			ConversationID:       "claude:test-agent:test",
+			ConversationID:       "claude:test",
 			Runtime:              "claude",
 		}},
 		watchDirs: []string{dir},
@@ -56,8 +61,8 @@ func TestWatcherCreatesBuffer(t *testing.T) {
 	// Use the agents package Agent type indirectly through discoverAndTail
 	// For this unit test, we'll test the buffer integration directly
 
-	buf := NewConversationBuffer("claude:test-agent:test", "test-agent", 100)
-	parser := NewClaudeParser("test-agent", "claude:test-agent:test")
+	buf := NewConversationBuffer("claude:test", "test-agent", 100)
+	parser := NewClaudeParser("test-agent", "claude:test")
 
 	// Simulate parsing a line
 	raw := []byte(`{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}`)
@@ -101,3 +106,844 @@ func TestWatcherEventChannel(t *testing.T) {
 
 	watcher.Stop()
 }
+
+// waitForBufferLen polls until the conversation's buffer holds want events.
+func waitForBufferLen(t *testing.T, w *ConversationWatcher, convID string, want int) *ConversationBuffer {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if buf := w.GetBuffer(convID); buf != nil && len(buf.Snapshot(EventFilter{})) == want {
+			return buf
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("buffer for %s never reached %d events", convID, want)
+	return nil
+}
+
+func TestWatcherRediscoveryKeepsExistingStream(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	line := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}` + "\n"
+	if err := os.WriteFile(convPath, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+
+	watcher.startConversationStream(agent, file)
+	first := waitForBufferLen(t, watcher, file.ConversationID, 1)
+
+	// Rediscovering the same file must not replay it into a new buffer.
+	watcher.startConversationStream(agent, file)
+	time.Sleep(200 * time.Millisecond)
+	if buf := watcher.GetBuffer(file.ConversationID); buf != first {
+		t.Fatal("rediscovery replaced the buffer for an unchanged file")
+	}
+	if n := len(first.Snapshot(EventFilter{})); n != 1 {
+		t.Fatalf("buffer has %d events after rediscovery, want 1", n)
+	}
+
+	// A file replaced at the same path is a new identity and gets a fresh stream.
+	replacement := filepath.Join(dir, "replacement.jsonl")
+	if err := os.WriteFile(replacement, []byte(line+line), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replacement, convPath); err != nil {
+		t.Fatal(err)
+	}
+	watcher.startConversationStream(agent, file)
+	if buf := waitForBufferLen(t, watcher, file.ConversationID, 2); buf == first {
+		t.Fatal("replaced file should get a new buffer")
+	}
+}
+
+// gatedDiscoverer blocks discovery until release is closed.
+type gatedDiscoverer struct {
+	mockDiscoverer
+	release chan struct{}
+}
+
+func (d *gatedDiscoverer) FindConversations(agentName, workDir string) (DiscoveryResult, error) {
+	<-d.release
+	return d.mockDiscoverer.FindConversations(agentName, workDir)
+}
+
+func TestWatcherRemovedAgentCancelsDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	line := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}` + "\n"
+	if err := os.WriteFile(convPath, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	disc := &gatedDiscoverer{mockDiscoverer: mockDiscoverer{files: []ConversationFile{file}}, release: make(chan struct{})}
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", disc, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+
+	// The agent goes away while its discovery is still running.
+	watcher.startWatching(agents.Agent{Name: "test-agent", Runtime: "claude"})
+	watcher.stopWatching("test-agent")
+	close(disc.release)
+
+	time.Sleep(200 * time.Millisecond)
+	if buf := watcher.GetBuffer(file.ConversationID); buf != nil {
+		t.Fatal("discovery finishing after removal started tailing the agent")
+	}
+}
+
+func TestWatcherAssignsStableIDs(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	content := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}` + "\n" +
+		`{"type":"user","uuid":"u2","timestamp":"2026-02-14T01:44:55.253Z","message":{"role":"user","content":[{"type":"text","text":"again"}]}}` + "\n"
+	if err := os.WriteFile(convPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+
+	// Two independent watchers stand in for a converter restart.
+	var ids [2][]string
+	for run := range ids {
+		watcher := NewConversationWatcher(nil, 100)
+		watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+			return NewClaudeParser(agentName, convID)
+		})
+		watcher.startConversationStream(agent, file)
+		for _, e := range waitForBufferLen(t, watcher, file.ConversationID, 2).Snapshot(EventFilter{}) {
+			ids[run] = append(ids[run], e.StableID)
+		}
+		watcher.Stop()
+	}
+
+	if ids[0][0] == "" || ids[0][0] == ids[0][1] {
+		t.Fatalf("stable IDs = %v, want distinct non-empty IDs", ids[0])
+	}
+	if ids[0][0] != ids[1][0] || ids[0][1] != ids[1][1] {
+		t.Fatalf("stable IDs differ across runs: %v vs %v", ids[0], ids[1])
+	}
+	if want := StableEventID("claude", "test", 0, 0); ids[0][0] != want {
+		t.Fatalf("first stable ID = %q, want %q", ids[0][0], want)
+	}
+}
+
+func TestWatcherLoadProgress(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	line := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}` + "\n"
+	// The trailing fragment is still being written and must not hold progress back.
+	if err := os.WriteFile(convPath, []byte(line+line+`{"type":"us`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	if _, _, loading := watcher.LoadProgress("claude:test"); loading {
+		t.Fatal("unknown conversation should not report loading")
+	}
+
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	watcher.startConversationStream(agent, file)
+	waitForBufferLen(t, watcher, file.ConversationID, 2)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		read, total, loading := watcher.LoadProgress(file.ConversationID)
+		if !loading {
+			if read != total || total != int64(2*len(line)+len(`{"type":"us`)) {
+				t.Fatalf("progress = %d/%d, want complete at file size", read, total)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("still loading at %d/%d", read, total)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWatcherTracksModelChanges(t *testing.T) {
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	agent := agents.Agent{Name: "hq-mayor", Runtime: "claude"}
+
+	for _, model := range []string{"claude-sonnet-4-5", "claude-sonnet-4-5", "<synthetic>", "", "claude-opus-4-1"} {
+		watcher.trackModel(agent, model)
+	}
+
+	var changes []WatcherEvent
+	for len(watcher.Events()) > 0 {
+		changes = append(changes, <-watcher.Events())
+	}
+	if len(changes) != 2 {
+		t.Fatalf("got %d model-changed events, want 2: %+v", len(changes), changes)
+	}
+	if changes[1].Type != "agent-model-changed" || changes[1].OldModel != "claude-sonnet-4-5" || changes[1].NewModel != "claude-opus-4-1" {
+		t.Fatalf("second change = %+v", changes[1])
+	}
+	if got := watcher.CurrentModel("hq-mayor"); got != "claude-opus-4-1" {
+		t.Fatalf("CurrentModel = %q, want claude-opus-4-1", got)
+	}
+}
+
+func TestWatcherRestoresStateFromStore(t *testing.T) {
+	dir := t.TempDir()
+	st, err := store.Open(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = st.Close() }()
+	if err := st.SetActiveConversation("hq-mayor", "claude:old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetModel("hq-mayor", "claude-opus-4-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	convPath := filepath.Join(dir, "new.jsonl")
+	if err := os.WriteFile(convPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	watcher.SetStore(st)
+	watcher.restoreState()
+
+	if got := watcher.CurrentModel("hq-mayor"); got != "claude-opus-4-1" {
+		t.Fatalf("CurrentModel after restore = %q", got)
+	}
+
+	// The agent moved to a new conversation while the watcher was down.
+	agent := agents.Agent{Name: "hq-mayor", Runtime: "claude"}
+	watcher.startConversationStream(agent, ConversationFile{Path: convPath, NativeConversationID: "new", ConversationID: "claude:new", Runtime: "claude"})
+	select {
+	case e := <-watcher.Events():
+		if e.Type != "conversation-switched" || e.OldConvID != "claude:old" || e.NewConvID != "claude:new" {
+			t.Fatalf("event = %+v, want switch from the persisted conversation", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for conversation-switched")
+	}
+
+	active, err := st.ActiveConversations()
+	if err != nil || active["hq-mayor"] != "claude:new" {
+		t.Fatalf("persisted active = %v, %v", active, err)
+	}
+	if c, ok, err := st.Conversation("claude:new"); err != nil || !ok || c.AgentName != "hq-mayor" || c.Path != convPath {
+		t.Fatalf("persisted conversation = %+v, %v, %v", c, ok, err)
+	}
+}
+
+func TestNormalizeTimestamp(t *testing.T) {
+	fs := &fileStream{}
+	received := time.Date(2026, 2, 14, 12, 0, 0, 0, time.UTC)
+	parsed := received.Add(-time.Hour)
+
+	first := ConversationEvent{}
+	fs.normalizeTimestamp(&first, received)
+	if !first.Timestamp.Equal(received) || !first.TimestampEstimated || !first.ReceivedAt.Equal(received) {
+		t.Fatalf("first event without timestamp = %+v, want receive time, estimated", first)
+	}
+
+	known := ConversationEvent{Timestamp: parsed}
+	fs.normalizeTimestamp(&known, received)
+	if !known.Timestamp.Equal(parsed) || known.TimestampEstimated {
+		t.Fatalf("event with timestamp = %+v, want it untouched", known)
+	}
+
+	missing := ConversationEvent{}
+	fs.normalizeTimestamp(&missing, received)
+	if !missing.Timestamp.Equal(parsed) || !missing.TimestampEstimated {
+		t.Fatalf("event without timestamp = %+v, want previous event's time", missing)
+	}
+
+	skewed := ConversationEvent{Timestamp: received.Add(time.Minute)}
+	fs.normalizeTimestamp(&skewed, received)
+	if !skewed.Timestamp.Equal(received) || !skewed.TimestampEstimated {
+		t.Fatalf("future event = %+v, want clamped to receive time", skewed)
+	}
+}
+
+func TestWatcherAnnotationsReplayInTimelineOrder(t *testing.T) {
+	dir := t.TempDir()
+	st, err := store.Open(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = st.Close() }()
+
+	convID := "claude:test"
+	if err := st.AddAnnotation(store.Annotation{ID: "a1", ConversationID: convID, Author: "ci", Text: "CI result: failed", CreatedAt: time.Date(2026, 2, 14, 1, 50, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+	convPath := filepath.Join(dir, "test.jsonl")
+	lines := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}` + "\n" +
+		`{"type":"user","uuid":"u2","timestamp":"2026-02-14T01:55:00.000Z","message":{"role":"user","content":[{"type":"text","text":"again"}]}}` + "\n"
+	if err := os.WriteFile(convPath, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	watcher.SetStore(st)
+	watcher.startConversationStream(agents.Agent{Name: "test-agent", Runtime: "claude"},
+		ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: convID, Runtime: "claude"})
+
+	buf := waitForBufferLen(t, watcher, convID, 3)
+	events := buf.Snapshot(EventFilter{})
+	if events[1].Type != EventAnnotation || events[1].Metadata["author"] != "ci" || events[1].Content[0].Text != "CI result: failed" {
+		t.Fatalf("second event = %+v, want the stored annotation between the two prompts", events[1])
+	}
+
+	event, err := watcher.Annotate(convID, "review-bot", "looks good")
+	if err != nil {
+		t.Fatalf("Annotate() error = %v", err)
+	}
+	if event.Seq != 3 || event.Type != EventAnnotation {
+		t.Fatalf("Annotate() = seq %d type %q, want seq 3 annotation", event.Seq, event.Type)
+	}
+	if stored, _ := st.Annotations(convID); len(stored) != 2 {
+		t.Fatalf("store has %d annotations, want 2", len(stored))
+	}
+	if _, err := watcher.Annotate("claude:missing:x", "ci", "x"); err != ErrConversationNotFound {
+		t.Fatalf("Annotate(missing) error = %v, want ErrConversationNotFound", err)
+	}
+}
+
+func TestWatcherResumeHint(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "abc123.jsonl")
+	if err := os.WriteFile(convPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	rig := "gastown"
+	convID := "claude:abc123"
+	watcher.startConversationStream(agents.Agent{Name: "hq-mayor", Runtime: "claude", Role: "mayor", Rig: &rig, WorkDir: "/home/gt/my_town"},
+		ConversationFile{Path: convPath, NativeConversationID: "abc123", ConversationID: convID, Runtime: "claude"})
+
+	hint, err := watcher.ResumeHint(convID)
+	if err != nil {
+		t.Fatalf("ResumeHint() error = %v", err)
+	}
+	if legacy, err := watcher.ResumeHint("claude:hq-mayor:abc123"); err != nil || legacy.ConversationID != convID {
+		t.Fatalf("ResumeHint(old-style ID) = %+v, %v; want the same conversation", legacy.ConversationSource, err)
+	}
+	if got := strings.Join(hint.Command, " "); got != "claude --resume abc123" {
+		t.Errorf("Command = %q, want claude --resume abc123", got)
+	}
+	if hint.Path != convPath || hint.WorkDir != "/home/gt/my_town" {
+		t.Errorf("Path, WorkDir = %q, %q", hint.Path, hint.WorkDir)
+	}
+	if want := filepath.Join(".claude", "projects", "-home-gt-my-town", "abc123.jsonl"); hint.TranscriptPath != want {
+		t.Errorf("TranscriptPath = %q, want %q", hint.TranscriptPath, want)
+	}
+	wantTmux := "tmux new-session -d -s hq-mayor -c /home/gt/my_town -e GT_AGENT=claude -e GT_ROLE=mayor -e GT_RIG=gastown claude --resume abc123"
+	if got := strings.Join(hint.TmuxCommand, " "); got != wantTmux {
+		t.Errorf("TmuxCommand = %q, want %q", got, wantTmux)
+	}
+
+	if _, err := watcher.ResumeHint("claude:missing:x"); err != ErrConversationNotFound {
+		t.Fatalf("ResumeHint(missing) error = %v, want ErrConversationNotFound", err)
+	}
+}
+
+func TestWatcherConfirmsSwitchBeforeSwitching(t *testing.T) {
+	dir := t.TempDir()
+	line := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}` + "\n"
+	paths := map[string]string{}
+	for _, name := range []string{"old", "temp", "new"} {
+		paths[name] = filepath.Join(dir, name+".jsonl")
+		if err := os.WriteFile(paths[name], []byte(line), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	watcher.SetSwitchConfirm(200 * time.Millisecond)
+	agent := agents.Agent{Name: "hq-mayor", Runtime: "claude"}
+	file := func(name string) ConversationFile {
+		return ConversationFile{Path: paths[name], NativeConversationID: name, ConversationID: "claude:" + name, Runtime: "claude"}
+	}
+	nextLifecycle := func() WatcherEvent {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case e := <-watcher.Events():
+				if e.Type != "conversation-event" {
+					return e
+				}
+			case <-timeout:
+				t.Fatal("timeout waiting for a lifecycle event")
+			}
+		}
+	}
+
+	watcher.startConversationStream(agent, file("old"))
+	if e := nextLifecycle(); e.Type != "conversation-started" {
+		t.Fatalf("event = %+v, want conversation-started", e)
+	}
+
+	// A temporary file that vanishes is never switched to.
+	watcher.startConversationStream(agent, file("temp"))
+	if err := os.Remove(paths["temp"]); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if got := watcher.GetActiveConversation("hq-mayor"); got != "claude:old" {
+		t.Fatalf("active = %q after the temp file vanished, want old", got)
+	}
+	if watcher.GetBuffer("claude:temp") != nil {
+		t.Fatal("temp conversation still tailed after its file vanished")
+	}
+
+	watcher.startConversationStream(agent, file("new"))
+	if got := watcher.GetActiveConversation("hq-mayor"); got != "claude:old" {
+		t.Fatalf("active = %q before confirmation, want old", got)
+	}
+	e := nextLifecycle()
+	if e.Type != "conversation-switched" || e.OldConvID != "claude:old" || e.NewConvID != "claude:new" {
+		t.Fatalf("event = %+v, want switch from old to new", e)
+	}
+	if got := watcher.GetActiveConversation("hq-mayor"); got != "claude:new" {
+		t.Fatalf("active = %q after confirmation, want new", got)
+	}
+	if buf := watcher.GetBuffer("claude:new"); buf == nil || buf.LastSeq() != 0 {
+		t.Fatal("new conversation's buffer lost the events read before the switch")
+	}
+	if watcher.GetBuffer("claude:old") != nil {
+		t.Fatal("old conversation still tailed after the switch")
+	}
+}
+
+func TestWatcherFullContent(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	long := strings.Repeat("x", 100) + " secret=hunter2"
+	content := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"short"}]}}` + "\n" +
+		`{"type":"user","uuid":"u2","timestamp":"2026-02-14T01:44:55.253Z","message":{"role":"user","content":[{"tool_use_id":"t1","type":"tool_result","content":"` + long + `"}]}}` + "\n"
+	if err := os.WriteFile(convPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		p := NewClaudeParser(agentName, convID)
+		p.SetMaxContentSize(50)
+		return p
+	})
+	watcher.Use(func(e ConversationEvent) (ConversationEvent, bool) {
+		for i := range e.Content {
+			e.Content[i].Output = strings.ReplaceAll(e.Content[i].Output, "hunter2", "[REDACTED]")
+		}
+		return e, true
+	})
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	watcher.startConversationStream(agents.Agent{Name: "test-agent", Runtime: "claude"}, file)
+	snap := waitForBufferLen(t, watcher, file.ConversationID, 2).Snapshot(EventFilter{})
+
+	b := snap[1].Content[0]
+	if !b.Truncated || b.OriginalBytes != len(long) || len(b.Output) != 50 {
+		t.Fatalf("block = truncated %v, %d of %d bytes; want cut to 50 of %d", b.Truncated, len(b.Output), b.OriginalBytes, len(long))
+	}
+	full, err := watcher.FullContent(file.ConversationID, snap[1].Seq, 0)
+	if err != nil {
+		t.Fatalf("FullContent() error = %v", err)
+	}
+	if want := strings.Repeat("x", 100) + " secret=[REDACTED]"; full.Output != want || full.Truncated {
+		t.Fatalf("full block = %+v, want the whole redacted output", full)
+	}
+	if _, err := watcher.FullContent(file.ConversationID, snap[0].Seq, 0); err != ErrNotTruncated {
+		t.Fatalf("FullContent(untruncated) error = %v, want ErrNotTruncated", err)
+	}
+}
+
+func TestWatcherCollectsIdleStreams(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	line := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}` + "\n"
+	if err := os.WriteFile(convPath, []byte(line+strings.Replace(line, "u1", "u2", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(convPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.SetStateDir(t.TempDir())
+	watcher.SetIdleTTL(time.Minute)
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	watcher.startConversationStream(agent, file)
+	buf := waitForBufferLen(t, watcher, file.ConversationID, 2)
+	for _, _, loading := watcher.LoadProgress(file.ConversationID); loading; _, _, loading = watcher.LoadProgress(file.ConversationID) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A subscriber keeps the stream alive.
+	_, subID, _ := buf.Subscribe(EventFilter{})
+	watcher.collectIdle(time.Now())
+	if watcher.GetBuffer(file.ConversationID) == nil {
+		t.Fatal("stream with a subscriber was collected")
+	}
+	buf.Unsubscribe(subID)
+
+	watcher.collectIdle(time.Now())
+	if watcher.GetBuffer(file.ConversationID) != nil {
+		t.Fatal("idle stream without subscribers was not collected")
+	}
+	if got := watcher.GetActiveConversation("test-agent"); got != file.ConversationID {
+		t.Fatalf("active conversation = %q after collection, want it kept", got)
+	}
+	if convs := watcher.ListConversations(); len(convs) != 1 || !convs[0].Idle {
+		t.Fatalf("ListConversations() = %+v, want the idle conversation", convs)
+	}
+	if watcher.Activity("test-agent").EventCount != 2 {
+		t.Fatalf("Activity() = %+v after collection", watcher.Activity("test-agent"))
+	}
+
+	// Subscribing tails it again, resuming from the snapshot with the same seqs.
+	buf = watcher.EnsureTailing(file.ConversationID, FullHistory)
+	if buf == nil || buf.Stats().Events != 2 || buf.Stats().NextSeq != 2 {
+		t.Fatalf("EnsureTailing() buffer = %+v, want the 2 restored events", buf)
+	}
+
+	// A write to a collected conversation's file tails it again too.
+	watcher.collectIdle(time.Now())
+	f, err := os.OpenFile(convPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(strings.Replace(line, "u1", "u3", 1))
+	_ = f.Close()
+	watcher.retailChanged()
+	waitForBufferLen(t, watcher, file.ConversationID, 3)
+
+	started := 0
+	for len(watcher.Events()) > 0 {
+		if (<-watcher.Events()).Type == "conversation-started" {
+			started++
+		}
+	}
+	if started != 1 {
+		t.Fatalf("got %d conversation-started events, want 1: tailing again is not a new conversation", started)
+	}
+}
+
+func TestWatcherRetailsRecentHistory(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	line := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}` + "\n"
+	content := line + strings.Replace(line, "u1", "u2", 1) + strings.Replace(line, "u1", "u3", 1)
+	if err := os.WriteFile(convPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.SetIdleTTL(time.Minute)
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	watcher.startConversationStream(agent, file)
+	waitForBufferLen(t, watcher, file.ConversationID, 3)
+	for _, _, loading := watcher.LoadProgress(file.ConversationID); loading; _, _, loading = watcher.LoadProgress(file.ConversationID) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	watcher.mu.RLock()
+	stream := watcher.streams[file.ConversationID]
+	watcher.mu.RUnlock()
+	watcher.collect(stream)
+
+	// Without a snapshot, a subscriber wanting one event reads only the last line.
+	buf := watcher.EnsureTailing(file.ConversationID, 1)
+	if buf == nil {
+		t.Fatal("EnsureTailing() = nil")
+	}
+	events := waitForBufferLen(t, watcher, file.ConversationID, 1).Snapshot(EventFilter{})
+	if want := StableEventID("claude", "test", int64(2*len(line)), 0); events[0].StableID != want {
+		t.Fatalf("partial stream starts at %s, want the last line's %s", events[0].StableID, want)
+	}
+
+	// A full-history caller gets the whole file once nobody is subscribed.
+	watcher.EnsureTailing(file.ConversationID, FullHistory)
+	waitForBufferLen(t, watcher, file.ConversationID, 3)
+}
+
+func TestWatcherEagerTailKeepsStreams(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.SetIdleTTL(time.Minute)
+	watcher.SetEagerTail(func(name string) bool { return name == "hq-mayor" })
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	for _, name := range []string{"hq-mayor", "crew-max"} {
+		path := filepath.Join(dir, name+".jsonl")
+		if err := os.WriteFile(path, []byte(`{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":"hello"}}`+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+		file := ConversationFile{Path: path, NativeConversationID: name, ConversationID: "claude:" + name, Runtime: "claude"}
+		watcher.startConversationStream(agents.Agent{Name: name, Runtime: "claude"}, file)
+		waitForBufferLen(t, watcher, file.ConversationID, 1)
+	}
+	for _, s := range watcher.Stats() {
+		for !s.HistoryDone {
+			time.Sleep(10 * time.Millisecond)
+			_, _, loading := watcher.LoadProgress(s.ConversationID)
+			s.HistoryDone = !loading
+		}
+	}
+
+	watcher.collectIdle(time.Now())
+	if watcher.GetBuffer("claude:hq-mayor") == nil {
+		t.Fatal("eager agent's conversation was collected")
+	}
+	if watcher.GetBuffer("claude:crew-max") != nil {
+		t.Fatal("other agent's idle conversation was not collected")
+	}
+}
+
+func TestWatcherTagsEchoedPrompt(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	userLine := func(uuid, text string) string {
+		return `{"type":"user","uuid":"` + uuid + `","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":"` + text + `"}}` + "\n"
+	}
+	if err := os.WriteFile(convPath, []byte(userLine("u1", "hello")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	watcher.startConversationStream(agents.Agent{Name: "hq-mayor", Runtime: "claude"}, file)
+	waitForBufferLen(t, watcher, file.ConversationID, 1)
+
+	sent := watcher.ExpectPrompt("hq-mayor", "fix the build\n", PromptTag{PromptID: "p-1", SubscriptionID: "sub-3"})
+	watcher.ExpectPrompt("hq-mayor", "never sent", PromptTag{PromptID: "p-2"}).Withdraw()
+
+	f, err := os.OpenFile(convPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(userLine("u2", "never sent") + userLine("u3", "fix the build") + userLine("u4", "fix the build"))
+	_ = f.Close()
+
+	events := waitForBufferLen(t, watcher, file.ConversationID, 4).Snapshot(EventFilter{})
+	for i, want := range []map[string]any{nil, nil, {"promptId": "p-1", "sentVia": "sub-3"}, nil} {
+		got := map[string]any{}
+		for _, k := range []string{"promptId", "sentVia"} {
+			if v, ok := events[i].Metadata[k]; ok {
+				got[k] = v
+			}
+		}
+		if len(want) == 0 && len(got) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("event %d tags = %v, want %v", i, got, want)
+		}
+	}
+
+	select {
+	case <-sent.Echoed():
+	default:
+		t.Fatal("Echoed not closed after the prompt's user event")
+	}
+	select {
+	case <-sent.Answered():
+		t.Fatal("Answered closed before any reply")
+	default:
+	}
+	f, err = os.OpenFile(convPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"type":"assistant","uuid":"a1","timestamp":"2026-02-14T01:45:00.362Z","message":{"role":"assistant","content":[{"type":"text","text":"On it."}]}}` + "\n")
+	_ = f.Close()
+	select {
+	case <-sent.Answered():
+	case <-time.After(3 * time.Second):
+		t.Fatal("Answered not closed after the reply")
+	}
+	if sent.AnsweredAt().Before(sent.EchoedAt()) {
+		t.Fatalf("AnsweredAt %v before EchoedAt %v", sent.AnsweredAt(), sent.EchoedAt())
+	}
+}
+
+// panickyParser panics on lines containing "boom".
+type panickyParser struct{ *ClaudeParser }
+
+func (p panickyParser) Parse(raw []byte) ([]ConversationEvent, error) {
+	if strings.Contains(string(raw), "boom") {
+		panic("parser bug")
+	}
+	return p.ClaudeParser.Parse(raw)
+}
+
+func TestWatcherSurvivesParserPanic(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	content := `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":"boom"}}` + "\n" +
+		`{"type":"user","uuid":"u2","timestamp":"2026-02-14T01:44:55.253Z","message":{"role":"user","content":"fine"}}` + "\n"
+	if err := os.WriteFile(convPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return panickyParser{NewClaudeParser(agentName, convID)}
+	})
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	watcher.startConversationStream(agent, file)
+
+	events := waitForBufferLen(t, watcher, file.ConversationID, 1).Snapshot(EventFilter{})
+	if events[0].EventID != "u2" {
+		t.Fatalf("buffered %+v, want only the line after the panic", events[0])
+	}
+	failures := watcher.GetParseErrors(file.ConversationID)
+	if len(failures) != 1 || !strings.Contains(failures[0].Error, "parser bug") {
+		t.Fatalf("parse errors = %+v, want the panicking line quarantined", failures)
+	}
+}
+
+func TestWatcherRedactsQuarantinedLines(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	key := "sk-ant-" + strings.Repeat("a", 30)
+	if err := os.WriteFile(convPath, []byte(`{"type":"user","message":{"content":"boom `+key+`"}}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.Use(NewRedactor(DefaultRedactionRules).Middleware())
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return panickyParser{NewClaudeParser(agentName, convID)}
+	})
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	watcher.startConversationStream(agent, file)
+
+	var failures []ParseFailure
+	deadline := time.Now().Add(3 * time.Second)
+	for len(failures) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		failures = watcher.GetParseErrors(file.ConversationID)
+	}
+	if len(failures) != 1 {
+		t.Fatalf("parse errors = %+v, want the panicking line", failures)
+	}
+	if strings.Contains(failures[0].RawLine, key) || !strings.Contains(failures[0].RawLine, "[REDACTED:anthropic-key]") {
+		t.Errorf("quarantined line = %q, want the key redacted", failures[0].RawLine)
+	}
+
+	// Releasing the conversation forgets its failures.
+	watcher.ReleaseConversation(file.ConversationID)
+	if failures := watcher.GetParseErrors(file.ConversationID); len(failures) != 0 {
+		t.Errorf("parse errors after release = %+v", failures)
+	}
+}
+
+func TestWatcherDropsStreamWhileModelChangeBlocked(t *testing.T) {
+	dir := t.TempDir()
+	convPath := filepath.Join(dir, "test.jsonl")
+	if err := os.WriteFile(convPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewConversationWatcher(nil, 100)
+	defer watcher.Stop()
+	watcher.RegisterRuntime("claude", &mockDiscoverer{}, func(agentName, convID string) Parser {
+		return NewClaudeParser(agentName, convID)
+	})
+	agent := agents.Agent{Name: "test-agent", Runtime: "claude"}
+	file := ConversationFile{Path: convPath, NativeConversationID: "test", ConversationID: "claude:test", Runtime: "claude"}
+	watcher.startConversationStream(agent, file)
+	waitForBufferLen(t, watcher, file.ConversationID, 0)
+
+	// With nobody reading events, agent-model-changed blocks its emitter.
+	for len(watcher.events) < cap(watcher.events) {
+		watcher.events <- WatcherEvent{Type: "agent-updated"}
+	}
+	reply := `{"type":"assistant","uuid":"a1","requestId":"req1","timestamp":"2026-02-14T01:45:00.362Z","message":{"model":"claude-opus-4-6","role":"assistant","content":[{"type":"text","text":"hi"}]}}` + "\n"
+	f, err := os.OpenFile(convPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(reply); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	deadline := time.Now().Add(3 * time.Second)
+	for watcher.CurrentModel(agent.Name) == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("model change never seen")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// A switch stops tailers under w.mu; it must not wait on the blocked emit.
+	done := make(chan struct{})
+	go func() {
+		watcher.mu.Lock()
+		watcher.dropStreamLocked(file.ConversationID)
+		watcher.mu.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dropping the stream deadlocked behind agent-model-changed")
+	}
+}
@@ -2,6 +2,7 @@ package converter
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/fs"
@@ -13,42 +14,124 @@ import (
 
 	"github.com/gastownhall/tmux-adapter/internal/agents"
 	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+	"github.com/gastownhall/tmux-adapter/internal/demo"
+	"github.com/gastownhall/tmux-adapter/internal/ghexport"
+	"github.com/gastownhall/tmux-adapter/internal/notify"
+	"github.com/gastownhall/tmux-adapter/internal/publish"
+	"github.com/gastownhall/tmux-adapter/internal/retention"
+	"github.com/gastownhall/tmux-adapter/internal/store"
+	"github.com/gastownhall/tmux-adapter/internal/tee"
 	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/version"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
 	"github.com/gastownhall/tmux-adapter/internal/wsconv"
 	"github.com/gastownhall/tmux-adapter/web"
 )
 
+// pruneInterval is how often the retention policy is applied in the background.
+const pruneInterval = time.Hour
+
 // Converter is the structured conversation streaming service.
 type Converter struct {
-	ctrl          *tmux.ControlMode
-	registry      *agents.Registry
-	watcher       *conv.ConversationWatcher
-	wsSrv         *wsconv.Server
-	httpSrv       *http.Server
-	gtDir         string
-	listen        string
-	debugServeDir string
+	cfg      Config
+	ctrl     *tmux.ControlMode
+	registry *agents.Registry
+	watcher  *conv.ConversationWatcher
+	wsSrv    *wsconv.Server
+	httpSrv  *http.Server
+	pruner   *retention.Pruner
+}
+
+// Config configures a Converter. Nil integrations are off.
+type Config struct {
+	GTDir  string // gastown town directory; agents outside it are ignored
+	Listen string // HTTP listen address, e.g. ":8081"
+
+	// TLSConfig, when non-nil, serves HTTPS/WSS (see wsbase.TLSConfig).
+	TLSConfig *tls.Config
+	// IPGuard filters every request by source address and caps sockets per IP.
+	IPGuard *wsbase.IPGuard
+	// Server is what the WebSocket server enforces: authentication, prompt
+	// and upload policies, and per-connection limits. Its AllowedOrigins
+	// applies to /ws while authentication is configured, since browsers
+	// present client certificates on their own and any page could otherwise
+	// open an authenticated socket. Without authentication /ws admits every
+	// origin.
+	Server wsconv.Options
+
+	// AdminToken, when non-empty, enables the /ws/admin introspection endpoint.
+	AdminToken string
+	// ReusePort allows a replacement converter to bind the address while
+	// this one drains.
+	ReusePort bool
+	// StateDir, when non-empty, keeps conversation buffers on disk across
+	// restarts.
+	StateDir string
+	// Store persists active conversations and models; Stop closes it.
+	Store store.Store
+	// Retention bounds the snapshots and conversation records kept under
+	// StateDir and in Store; the zero Policy keeps everything.
+	Retention retention.Policy
+
+	Pprof  bool // mount /debug/pprof/ behind AdminToken
+	MCP    bool // serve the Model Context Protocol endpoint at /mcp
+	OpenAI bool // serve the experimental OpenAI-compatible API under /v1/
+
+	GHExport  *ghexport.Exporter // comments finished turns on the agent's GitHub PR
+	Notifier  *notify.Notifier   // posts agent events to Slack and Discord webhooks
+	EventTee  *tee.Writer        // mirrors conversation events to local JSONL files
+	Publisher *publish.Publisher // pushes conversation and lifecycle events to NATS or Kafka
+
+	// SwitchConfirm is how long a new conversation file must keep receiving
+	// events before an agent switches to it (see conv.SetSwitchConfirm).
+	SwitchConfirm time.Duration
+	// RescanInterval is how often sessions without an agent are checked for
+	// one started since (see agents.Registry.SetRescanInterval).
+	RescanInterval time.Duration
+	// IdleTTL stops tailing conversations unchanged and unwatched for that
+	// long (see conv.SetIdleTTL); 0 keeps them all tailed. EagerTail, when
+	// non-nil, names the agents whose conversations stay tailed regardless.
+	IdleTTL   time.Duration
+	EagerTail *wsbase.NameFilter
+	// RemoteFS maps runtimes whose conversation files are on a network
+	// filesystem to how often to poll them (see conv.SetRemoteFilesystem).
+	RemoteFS map[string]time.Duration
+
+	// MaxContent is how many bytes of a content block's text are kept before
+	// it is marked truncated; 0 keeps everything.
+	MaxContent int
+	// ParserOptions sets what each runtime's parser skips and keeps (see
+	// conv.LoadParserOptions).
+	ParserOptions map[string]conv.ParserOptions
+	// Middleware runs in order on every parsed event before it is buffered,
+	// letting callers redact, annotate, or drop events.
+	Middleware []conv.Middleware
+
+	DebugServeDir string // serve static files from this directory at / (development only)
 }
 
 // New creates a new Converter.
-func New(gtDir, listen, debugServeDir string) *Converter {
-	return &Converter{
-		gtDir:         gtDir,
-		listen:        listen,
-		debugServeDir: debugServeDir,
-	}
+func New(cfg Config) *Converter {
+	return &Converter{cfg: cfg}
 }
 
 // Start initializes all components and starts the HTTP server.
 func (c *Converter) Start() error {
-	ctrl, err := tmux.NewControlMode("converter-monitor")
+	monitor := "converter-monitor"
+	if c.cfg.ReusePort {
+		// Closing control mode kills its session; don't share it with the process taking over.
+		monitor = fmt.Sprintf("converter-monitor-%d", os.Getpid())
+	}
+	ctrl, err := tmux.NewControlMode(monitor)
 	if err != nil {
 		return fmt.Errorf("tmux control mode: %w", err)
 	}
 	c.ctrl = ctrl
 	log.Println("converter: connected to tmux control mode")
 
-	c.registry = agents.NewRegistry(ctrl, c.gtDir, []string{"converter-monitor"})
+	c.registry = agents.NewRegistry(ctrl, c.cfg.GTDir, []string{monitor})
+	c.registry.SetRescanInterval(c.cfg.RescanInterval)
 
 	if err := c.registry.Start(); err != nil {
 		ctrl.Close()
@@ -58,25 +141,65 @@ func (c *Converter) Start() error {
 
 	// Set up conversation watcher with Claude discoverer/parser
 	c.watcher = conv.NewConversationWatcher(c.registry, 100000)
+	c.watcher.Use(c.cfg.Middleware...)
+	c.watcher.SetSwitchConfirm(c.cfg.SwitchConfirm)
+	c.watcher.SetIdleTTL(c.cfg.IdleTTL)
+	if c.cfg.EagerTail != nil {
+		c.watcher.SetEagerTail(c.cfg.EagerTail.Matches)
+	}
+	for runtime, poll := range c.cfg.RemoteFS {
+		c.watcher.SetRemoteFilesystem(runtime, poll)
+	}
+	if c.cfg.StateDir != "" {
+		c.watcher.SetStateDir(filepath.Join(c.cfg.StateDir, "snapshots"))
+		c.watcher.SetBlobDir(filepath.Join(c.cfg.StateDir, "blobs"))
+	}
+	if c.cfg.Store != nil {
+		c.watcher.SetStore(c.cfg.Store)
+	}
+	if c.cfg.Retention.Enabled() {
+		var dirs []string
+		if c.cfg.StateDir != "" {
+			dirs = append(dirs, filepath.Join(c.cfg.StateDir, "snapshots"), filepath.Join(c.cfg.StateDir, "blobs"))
+		}
+		c.pruner = retention.New(c.cfg.Retention, c.cfg.Store, dirs...)
+		c.pruner.Start(pruneInterval)
+		log.Printf("converter: retention enabled (max age %s, max bytes %d)", c.cfg.Retention.MaxAge, c.cfg.Retention.MaxBytes)
+	}
 
-	claudeRoot := filepath.Join(os.Getenv("HOME"), ".claude")
 	c.watcher.RegisterRuntime("claude",
-		conv.NewClaudeDiscoverer(claudeRoot),
-		func(agentName, convID string) conv.Parser {
-			return conv.NewClaudeParser(agentName, convID)
-		},
+		conv.NewClaudeDiscoverer(claudeRoot()),
+		claudeParsers(conv.NewSubagentLinker(), c.cfg.MaxContent, c.cfg.ParserOptions["claude"]),
+	)
+	// Demo agents write Claude-format transcripts under their own root.
+	c.watcher.RegisterRuntime(demo.Runtime,
+		conv.NewClaudeDiscoverer(demo.DefaultRoot()),
+		claudeParsers(conv.NewSubagentLinker(), c.cfg.MaxContent, c.cfg.ParserOptions["claude"]),
 	)
 
+	// Start integrations first: events from before they started are history.
+	c.cfg.GHExport.Start(func(agentName string) string {
+		agent, _ := c.registry.GetAgent(agentName)
+		return agent.WorkDir
+	})
+	c.cfg.Notifier.Start(c.registry.GetAgent)
+	c.cfg.EventTee.Start()
+	c.cfg.Publisher.Start()
+
 	c.watcher.Start()
 	log.Println("converter: conversation watcher started")
 
 	// Set up WebSocket server
-	c.wsSrv = wsconv.NewServer(c.watcher, "", []string{"*"}, c.ctrl, c.registry)
+	srvOpts := c.cfg.Server
+	if !srvOpts.Auth.Enabled() || srvOpts.AllowedOrigins == nil {
+		srvOpts.AllowedOrigins, _ = wsbase.ParseOriginPolicy([]string{"*"}, nil)
+	}
+	c.wsSrv = wsconv.NewServer(c.watcher, c.ctrl, c.registry, srvOpts)
 
 	// Forward watcher events to WebSocket broadcast
 	go func() {
 		for event := range c.watcher.Events() {
-			c.wsSrv.Broadcast(event)
+			c.forward(event)
 		}
 	}()
 
@@ -84,19 +207,48 @@ func (c *Converter) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = fmt.Fprint(w, `{"ok":true}`)
+		_, _ = fmt.Fprintf(w, `{"ok":true,"panics":%d}`, crash.Panics())
 	})
 	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		_, _ = fmt.Fprint(w, `{"ok":true}`)
 	})
+	mux.HandleFunc("/version", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		data, _ := json.Marshal(version.Get())
+		_, _ = w.Write(data)
+	})
 	mux.HandleFunc("/conversations", func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		convs := c.watcher.ListConversations()
 		data, _ := json.Marshal(convs)
 		_, _ = w.Write(data)
 	})
-	mux.HandleFunc("/ws", c.wsSrv.HandleWebSocket)
+	// Each protocol version gets its own path, so future versions can be
+	// served alongside it and proxies can route by path; /ws is v1's alias.
+	wsHandler := c.cfg.IPGuard.LimitConns(http.HandlerFunc(c.wsSrv.HandleWebSocket))
+	mux.Handle("/ws/v1", wsHandler)
+	mux.Handle("/ws", wsHandler)
+	mux.Handle("POST /api/agents/{name}/prompt", c.wsSrv.PromptAPI())
+	mux.Handle("GET /api/agents/{name}/screenshot.png", c.wsSrv.ScreenshotAPI())
+	mux.Handle("GET /api/conversations/{id}/export", c.wsSrv.ExportAPI())
+	mux.Handle("GET /api/conversations/{id}/resume-hint", c.wsSrv.ResumeHintAPI())
+	if c.cfg.AdminToken != "" {
+		mux.Handle("/ws/admin", c.cfg.IPGuard.LimitConns(wsconv.NewAdminHandler(c.wsSrv, c.cfg.AdminToken, c.pruner)))
+		log.Println("converter: admin endpoint enabled at /ws/admin")
+	}
+	if c.cfg.MCP {
+		mux.Handle("/mcp", wsconv.NewMCPHandler(c.wsSrv))
+		log.Println("converter: MCP endpoint enabled at /mcp")
+	}
+	if c.cfg.OpenAI {
+		mux.Handle("/v1/", wsconv.NewOpenAIHandler(c.wsSrv))
+		log.Println("converter: OpenAI-compatible API enabled at /v1/chat/completions (experimental)")
+	}
+	if c.cfg.Pprof {
+		wsbase.MountPprof(mux, wsbase.NewAuthenticator(c.cfg.AdminToken, nil, wsbase.ExpireClose))
+		log.Println("converter: profiling enabled at /debug/pprof/")
+	}
 
 	// Serve embedded converter web component files at /tmux-converter-web/
 	converterFS, _ := fs.Sub(web.Files, "tmux-converter-web")
@@ -110,19 +262,27 @@ func (c *Converter) Start() error {
 		http.StripPrefix("/shared/", http.FileServer(http.FS(sharedFS))),
 	))
 
-	if c.debugServeDir != "" {
-		log.Printf("converter: serving static files from %s at /", c.debugServeDir)
-		mux.Handle("/", http.FileServer(http.Dir(c.debugServeDir)))
+	if c.cfg.DebugServeDir != "" {
+		log.Printf("converter: serving static files from %s at /", c.cfg.DebugServeDir)
+		mux.Handle("/", http.FileServer(http.Dir(c.cfg.DebugServeDir)))
 	}
 
 	c.httpSrv = &http.Server{
-		Addr:    c.listen,
-		Handler: mux,
+		Addr:    c.cfg.Listen,
+		Handler: c.cfg.IPGuard.Filter(mux),
+	}
+
+	ln, err := wsbase.Listen(c.cfg.Listen, c.cfg.ReusePort)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", c.cfg.Listen, err)
+	}
+	if c.cfg.TLSConfig != nil {
+		ln = tls.NewListener(ln, c.cfg.TLSConfig)
 	}
 
 	go func() {
-		log.Printf("converter listening on %s", c.listen)
-		if err := c.httpSrv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Printf("converter listening on %s", c.cfg.Listen)
+		if err := c.httpSrv.Serve(ln); err != http.ErrServerClosed {
 			log.Fatalf("converter http server: %v", err)
 		}
 	}()
@@ -130,6 +290,42 @@ func (c *Converter) Start() error {
 	return nil
 }
 
+// forward hands a watcher event to the WebSocket server and every sink.
+func (c *Converter) forward(event conv.WatcherEvent) {
+	defer crash.Recover("converter: forwarding %s event", event.Type)
+	c.wsSrv.Broadcast(event)
+	c.cfg.GHExport.Observe(event)
+	c.cfg.Notifier.Observe(event)
+	c.cfg.EventTee.Observe(event)
+	c.cfg.Publisher.Observe(event)
+}
+
+// Drain stops accepting connections, lets connected clients keep streaming
+// until they disconnect or ctx is done, then shuts down.
+func (c *Converter) Drain(ctx context.Context) {
+	log.Printf("converter: draining, waiting for %d clients to disconnect", c.wsSrv.ClientCount())
+
+	// Shutdown closes the listener; hijacked WebSocket connections are unaffected.
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	if err := c.httpSrv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("converter http shutdown: %v", err)
+	}
+	cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for c.wsSrv.ClientCount() > 0 {
+		select {
+		case <-ctx.Done():
+			log.Printf("drain: giving up on %d remaining clients", c.wsSrv.ClientCount())
+			c.Stop()
+			return
+		case <-ticker.C:
+		}
+	}
+	c.Stop()
+}
+
 // Stop gracefully shuts down the converter.
 func (c *Converter) Stop() {
 	log.Println("converter: shutting down...")
@@ -140,13 +336,40 @@ func (c *Converter) Stop() {
 		log.Printf("converter http shutdown: %v", err)
 	}
 
+	c.pruner.Stop()
 	c.watcher.Stop()
+	c.cfg.GHExport.Stop()
+	c.cfg.Notifier.Stop()
+	c.cfg.EventTee.Stop()
+	c.cfg.Publisher.Stop()
+	if c.cfg.Store != nil {
+		if err := c.cfg.Store.Close(); err != nil {
+			log.Printf("converter store close: %v", err)
+		}
+	}
 	c.registry.Stop()
 	c.ctrl.Close()
 
 	log.Println("converter: shutdown complete")
 }
 
+// claudeRoot is where Claude Code keeps its transcripts.
+func claudeRoot() string {
+	return filepath.Join(os.Getenv("HOME"), ".claude")
+}
+
+// claudeParsers returns the parser factory for Claude transcripts. A nil
+// linker leaves Task invocations unrecorded.
+func claudeParsers(linker *conv.SubagentLinker, maxContent int, opts conv.ParserOptions) func(agentName, convID string) conv.Parser {
+	return func(agentName, convID string) conv.Parser {
+		p := conv.NewClaudeParser(agentName, convID)
+		p.SetSubagentLinker(linker)
+		p.SetMaxContentSize(maxContent)
+		p.SetOptions(opts)
+		return p
+	}
+}
+
 func corsHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/demo"
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
+)
+
+// ReadCurrent finds the agent named agentName and reads its current
+// conversation once, without starting the watcher or the server, for
+// --once. Only Claude and simulated agents have a transcript to read.
+func ReadCurrent(ctx context.Context, gtDir, agentName string, maxContent int, parserOptions map[string]conv.ParserOptions, middleware ...conv.Middleware) (agents.Agent, conv.ConversationFile, []conv.ConversationEvent, error) {
+	// Closing control mode kills its session; never share one with a server.
+	monitor := fmt.Sprintf("converter-once-%d", os.Getpid())
+	ctrl, err := tmux.NewControlMode(monitor)
+	if err != nil {
+		return agents.Agent{}, conv.ConversationFile{}, nil, fmt.Errorf("tmux control mode: %w", err)
+	}
+	defer ctrl.Close()
+
+	registry := agents.NewRegistry(ctrl, gtDir, []string{monitor})
+	if err := registry.Start(); err != nil {
+		return agents.Agent{}, conv.ConversationFile{}, nil, fmt.Errorf("start registry: %w", err)
+	}
+	agent, ok := registry.GetAgent(agentName)
+	registry.Stop()
+	if !ok {
+		return agents.Agent{}, conv.ConversationFile{}, nil, fmt.Errorf("agent %q not found", agentName)
+	}
+	root := claudeRoot()
+	switch agent.Runtime {
+	case "claude":
+	case demo.Runtime:
+		root = demo.DefaultRoot()
+	default:
+		return agent, conv.ConversationFile{}, nil, fmt.Errorf("agent %q runs %s, which has no conversation parser", agentName, agent.Runtime)
+	}
+
+	file, events, err := conv.ReadCurrentConversation(ctx, agent,
+		conv.NewClaudeDiscoverer(root),
+		claudeParsers(nil, maxContent, parserOptions["claude"]),
+		middleware...)
+	return agent, file, events, err
+}
@@ -0,0 +1,105 @@
+// Package crash recovers panics in long-running goroutines, so a bad
+// transcript line or message ends the work that hit it rather than the
+// process and every agent's stream with it.
+package crash
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	panics atomic.Int64
+
+	dumpMu  sync.Mutex
+	dumpDir string // "" writes no crash dumps
+	dumps   int    // written so far, up to maxDumps
+)
+
+// maxDumps bounds the crash dumps one process writes, so a panic on every
+// line of a transcript can't fill the disk.
+const maxDumps = 20
+
+// SetDumpDir makes recovered panics write a crash dump, holding the panic,
+// its stack, and every goroutine's, to a file in dir. "" turns dumps off.
+func SetDumpDir(dir string) {
+	dumpMu.Lock()
+	defer dumpMu.Unlock()
+	dumpDir = dir
+}
+
+// Panics returns how many panics have been recovered since the process
+// started.
+func Panics() int64 {
+	return panics.Load()
+}
+
+// Recover recovers a panic in the calling goroutine, logging it with its
+// stack. It must be deferred directly:
+//
+//	defer crash.Recover("watcher: tailing %s for %s", convID, agentName)
+//
+// format and args describe the work that panicked; they are only formatted
+// if it does.
+func Recover(format string, args ...any) {
+	if r := recover(); r != nil {
+		report(r, format, args)
+	}
+}
+
+// RecoverError is Recover for a function with a named error result, which
+// is set to an error describing the panic.
+//
+//	defer crash.RecoverError(&err, "parse line at %d", offset)
+func RecoverError(err *error, format string, args ...any) {
+	if r := recover(); r != nil {
+		report(r, format, args)
+		*err = fmt.Errorf("panic: %v", r)
+	}
+}
+
+func report(r any, format string, args []any) {
+	n := panics.Add(1)
+	where := fmt.Sprintf(format, args...)
+	stack := debug.Stack()
+	log.Printf("panic recovered in %s: %v\n%s", where, r, stack)
+
+	dumpMu.Lock()
+	defer dumpMu.Unlock()
+	if dumpDir == "" || dumps >= maxDumps {
+		return
+	}
+	dumps++
+	path, err := writeDump(dumpDir, n, where, r, stack)
+	if err != nil {
+		log.Printf("crash dump: %v", err)
+		return
+	}
+	log.Printf("crash dump written to %s", path)
+}
+
+// writeDump writes a crash dump for the nth panic to dir.
+func writeDump(dir string, n int64, where string, r any, stack []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("crash-%s-%d-%d.txt", now.UTC().Format("20060102T150405Z"), os.Getpid(), n))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(f, "time: %s\npid: %d\nin: %s\npanic: %v\n\n%s\nall goroutines:\n\n", now.Format(time.RFC3339Nano), os.Getpid(), where, r, stack)
+	_ = pprof.Lookup("goroutine").WriteTo(f, 2)
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,66 @@
+package crash
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecoverKeepsGoing(t *testing.T) {
+	before := Panics()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer Recover("test worker %d", 7)
+		var m map[string]int
+		m["boom"]++
+	}()
+	<-done
+	if got := Panics(); got != before+1 {
+		t.Fatalf("Panics() = %d, want %d", got, before+1)
+	}
+}
+
+func TestRecoverErrorSetsError(t *testing.T) {
+	parse := func() (n int, err error) {
+		defer RecoverError(&err, "parse")
+		panic("bad line")
+	}
+	if _, err := parse(); err == nil || !strings.Contains(err.Error(), "bad line") {
+		t.Fatalf("err = %v, want the panic as an error", err)
+	}
+
+	ok := func() (err error) {
+		defer RecoverError(&err, "parse")
+		return errors.New("plain")
+	}
+	if err := ok(); err == nil || err.Error() != "plain" {
+		t.Fatalf("err = %v, want the function's own error", err)
+	}
+}
+
+func TestRecoverWritesDump(t *testing.T) {
+	dir := t.TempDir()
+	SetDumpDir(dir)
+	defer SetDumpDir("")
+
+	func() {
+		defer Recover("conversation %s", "claude:abc")
+		panic("boom")
+	}()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("dump dir holds %v (%v), want one dump", entries, err)
+	}
+	data, err := os.ReadFile(dir + "/" + entries[0].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"in: conversation claude:abc", "panic: boom", "all goroutines:"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("dump lacks %q", want)
+		}
+	}
+}
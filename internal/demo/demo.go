@@ -0,0 +1,307 @@
+// Package demo implements the simulated runtime: a fake agent that writes
+// Claude Code-style JSONL transcripts at a steady pace and answers prompts
+// typed into its terminal, so frontends can be developed against live data
+// without an API key. Run it in a gastown tmux session with
+// "tmux-adapter demo-agent"; it tags the session so the registry reports it
+// with runtime "simulated", and the converter reads its transcripts from
+// DefaultRoot.
+package demo
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Runtime is the runtime the registry reports for demo agents.
+const Runtime = "simulated"
+
+// DefaultRoot is where demo agents write their transcripts, laid out like
+// ~/.claude: projects/<encoded workdir>/<session>.jsonl.
+func DefaultRoot() string {
+	return filepath.Join(os.Getenv("HOME"), ".tmux-adapter", "demo")
+}
+
+// Options configures a demo agent. Zero values take the defaults noted.
+type Options struct {
+	Root     string        // transcript root; default DefaultRoot
+	WorkDir  string        // the agent's working directory; default the current one
+	Interval time.Duration // pause between turns it starts itself; 0 only answers typed prompts
+	Pace     time.Duration // delay between the events of one turn
+	ToolRate float64       // chance, 0 to 1, that a turn calls a tool before replying
+	Seed     uint64        // seeds the script; 0 picks one at random
+	Turns    int           // exit after this many turns; 0 runs until stopped
+	Model    string        // model name written on replies; default "claude-demo"
+	In       io.Reader     // typed prompts, one per line; nil reads none
+	Out      io.Writer     // the terminal; nil discards
+}
+
+// MarkSession sets GT_AGENT in the tmux session the process runs in, so the
+// registry takes its tmux-adapter process for a simulated agent. Outside
+// tmux it does nothing.
+func MarkSession() error {
+	if os.Getenv("TMUX") == "" {
+		return nil
+	}
+	if out, err := exec.Command("tmux", "set-environment", "GT_AGENT", Runtime).CombinedOutput(); err != nil {
+		return fmt.Errorf("tmux set-environment: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Run plays the demo agent until ctx is done, opts.Turns turns have been
+// played, or, with no Interval, typed input ends.
+func Run(ctx context.Context, opts Options) error {
+	if opts.Root == "" {
+		opts.Root = DefaultRoot()
+	}
+	if opts.WorkDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		opts.WorkDir = wd
+	}
+	if opts.Model == "" {
+		opts.Model = "claude-demo"
+	}
+	if opts.Out == nil {
+		opts.Out = io.Discard
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = mrand.Uint64()
+	}
+
+	a := &agent{opts: opts, rng: mrand.New(mrand.NewPCG(seed, seed>>1|1)), sessionID: newUUID()}
+	dir := filepath.Join(opts.Root, "projects", encodeWorkDir(opts.WorkDir))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	a.path = filepath.Join(dir, a.sessionID+".jsonl")
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	a.f = f
+
+	fmt.Fprintf(opts.Out, "✻ simulated agent (%s)\n  transcript: %s\n\n", opts.Model, a.path)
+
+	prompts := make(chan string)
+	inputDone := make(chan struct{})
+	if opts.In != nil {
+		go func() {
+			defer close(inputDone)
+			sc := bufio.NewScanner(opts.In)
+			for sc.Scan() {
+				if text := strings.TrimSpace(sc.Text()); text != "" {
+					select {
+					case prompts <- text:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	} else {
+		close(inputDone)
+	}
+
+	var tick <-chan time.Time
+	if opts.Interval > 0 {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	fmt.Fprint(opts.Out, "> ")
+	for turns := 0; opts.Turns == 0 || turns < opts.Turns; {
+		var prompt string
+		select {
+		case <-ctx.Done():
+			return nil
+		case prompt = <-prompts: // the terminal already echoed it
+		case <-tick:
+			prompt = pick(a.rng, scriptedPrompts)
+			fmt.Fprintln(opts.Out, prompt)
+		case <-inputDone:
+			if tick == nil {
+				return nil
+			}
+			inputDone = nil // keep going on the timer alone
+			continue
+		}
+		if err := a.turn(ctx, prompt); err != nil {
+			return err
+		}
+		turns++
+		fmt.Fprint(opts.Out, "> ")
+	}
+	return nil
+}
+
+// agent is one demo agent's transcript state.
+type agent struct {
+	opts      Options
+	rng       *mrand.Rand
+	sessionID string
+	path      string
+	f         *os.File
+	parent    string // UUID of the last line written
+	messages  int
+}
+
+// turn plays one prompt: the user line, thinking, maybe a tool call and its
+// result, the reply, and the turn_duration line.
+func (a *agent) turn(ctx context.Context, prompt string) error {
+	start := time.Now()
+	if err := a.write(map[string]any{"type": "user", "message": map[string]any{"role": "user", "content": prompt}}); err != nil {
+		return err
+	}
+	requestID := "req_demo" + randomHex(12)
+
+	if !a.pause(ctx) {
+		return nil
+	}
+	thought := pick(a.rng, thoughts)
+	if err := a.assistant(requestID, []any{map[string]any{"type": "thinking", "thinking": thought, "signature": ""}}, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.opts.Out, "✻ %s\n", thought)
+
+	if a.rng.Float64() < a.opts.ToolRate {
+		if !a.pause(ctx) {
+			return nil
+		}
+		call := pick(a.rng, toolCalls)
+		toolID := "toolu_demo" + randomHex(12)
+		stop := "tool_use"
+		input := call.input(a.opts.WorkDir)
+		if err := a.assistant(requestID, []any{map[string]any{"type": "tool_use", "id": toolID, "name": call.name, "input": input}}, &stop); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.opts.Out, "⏺ %s(%s)\n", call.name, call.summary(input))
+		if !a.pause(ctx) {
+			return nil
+		}
+		result := map[string]any{"type": "tool_result", "tool_use_id": toolID, "content": call.output}
+		if err := a.write(map[string]any{"type": "user", "message": map[string]any{"role": "user", "content": []any{result}}}); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.opts.Out, "  ⎿  %s\n", firstLine(call.output))
+	}
+
+	if !a.pause(ctx) {
+		return nil
+	}
+	reply := pick(a.rng, replies)
+	stop := "end_turn"
+	if err := a.assistant(requestID, []any{map[string]any{"type": "text", "text": reply}}, &stop); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.opts.Out, "⏺ %s\n\n", reply)
+	return a.write(map[string]any{"type": "system", "subtype": "turn_duration", "durationMs": time.Since(start).Milliseconds()})
+}
+
+// assistant writes an assistant line holding content.
+func (a *agent) assistant(requestID string, content []any, stopReason *string) error {
+	a.messages++
+	return a.write(map[string]any{
+		"type":      "assistant",
+		"requestId": requestID,
+		"message": map[string]any{
+			"model":       a.opts.Model,
+			"id":          fmt.Sprintf("msg_demo%s%03d", a.sessionID[:8], a.messages),
+			"type":        "message",
+			"role":        "assistant",
+			"content":     content,
+			"stop_reason": stopReason,
+			"usage": map[string]int{
+				"input_tokens":            3 + a.rng.IntN(40),
+				"output_tokens":           20 + a.rng.IntN(400),
+				"cache_read_input_tokens": 10000 + a.rng.IntN(30000),
+			},
+		},
+	})
+}
+
+// write appends a transcript line, filling in the envelope fields Claude
+// Code writes on every line.
+func (a *agent) write(line map[string]any) error {
+	id := newUUID()
+	line["uuid"] = id
+	line["parentUuid"] = nil
+	if a.parent != "" {
+		line["parentUuid"] = a.parent
+	}
+	line["sessionId"] = a.sessionID
+	line["cwd"] = a.opts.WorkDir
+	line["timestamp"] = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	line["isSidechain"] = false
+	line["userType"] = "external"
+	line["version"] = "demo"
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	if _, err := a.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write transcript: %w", err)
+	}
+	a.parent = id
+	return nil
+}
+
+// pause waits Pace, jittered by up to half again, reporting false if ctx
+// ended first.
+func (a *agent) pause(ctx context.Context) bool {
+	if a.opts.Pace <= 0 {
+		return ctx.Err() == nil
+	}
+	d := a.opts.Pace + time.Duration(a.rng.Int64N(int64(a.opts.Pace)/2+1))
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// encodeWorkDir matches how Claude Code (and conv.ClaudeDiscoverer) name a
+// working directory's project directory.
+func encodeWorkDir(workDir string) string {
+	return strings.NewReplacer("/", "-", "_", "-").Replace(workDir)
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func randomHex(n int) string {
+	b := make([]byte, (n+1)/2)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)[:n]
+}
+
+func pick[T any](rng *mrand.Rand, items []T) T {
+	return items[rng.IntN(len(items))]
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
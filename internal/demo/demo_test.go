@@ -0,0 +1,68 @@
+package demo
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+func TestRunWritesClaudeTranscript(t *testing.T) {
+	root := t.TempDir()
+	var out strings.Builder
+	err := Run(context.Background(), Options{
+		Root:     root,
+		WorkDir:  "/home/me/gt/my_rig",
+		ToolRate: 1,
+		Seed:     7,
+		Turns:    2,
+		In:       strings.NewReader("Run the tests\n\nFix the build\n"),
+		Out:      &out,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(root, "projects", "-home-me-gt-my-rig", "*.jsonl"))
+	if len(files) != 1 {
+		t.Fatalf("transcripts = %v, want one under the encoded workdir", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	p := conv.NewClaudeParser("gt-demo", "demo")
+	var types, texts []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		events, err := p.Parse(sc.Bytes())
+		if err != nil {
+			t.Fatalf("parse %s: %v", sc.Text(), err)
+		}
+		for _, ev := range events {
+			types = append(types, ev.Type)
+			if ev.Type == conv.EventUser {
+				for _, b := range ev.Content {
+					texts = append(texts, b.Text)
+				}
+			}
+		}
+	}
+	want := []string{conv.EventUser, conv.EventThinking, conv.EventToolUse, conv.EventToolResult, conv.EventAssistant, conv.EventTurnEnd}
+	if !slices.Equal(types, append(want, want...)) {
+		t.Errorf("event types = %v, want %v twice", types, want)
+	}
+	if !slices.Equal(texts, []string{"Run the tests", "Fix the build"}) {
+		t.Errorf("prompts = %q, want the typed ones", texts)
+	}
+	if !strings.Contains(out.String(), "⏺ ") {
+		t.Errorf("terminal output has no replies:\n%s", out.String())
+	}
+}
@@ -0,0 +1,75 @@
+package demo
+
+import (
+	"path/filepath"
+)
+
+// scriptedPrompts are what the agent "is asked" on turns it starts itself.
+var scriptedPrompts = []string{
+	"Check the build and fix anything that's broken",
+	"Summarize what changed since the last release",
+	"Look for TODOs in the parser and pick one to tackle",
+	"Run the tests and report failures",
+	"Tidy up the README's install section",
+	"Why is the websocket reconnect flaky?",
+}
+
+var thoughts = []string{
+	"Let me look at the relevant files before changing anything.",
+	"I should check how this is handled elsewhere in the codebase first.",
+	"The quickest way to confirm this is to run the tests.",
+	"This looks like it touches the config loader; I'll start there.",
+	"I need to see the recent history to answer this properly.",
+}
+
+var replies = []string{
+	"Done. The build is clean and all tests pass.",
+	"I found the issue: the retry loop never resets its backoff. I've fixed it and added a test.",
+	"Here's a summary: three bug fixes, one new flag, and the docs were refreshed for the new install steps.",
+	"Nothing to change here — the behavior matches the spec. I've left a comment explaining why.",
+	"I've made the change in two files. Want me to open a PR?",
+	"The failure was a race in the watcher setup; it's fixed and the test now runs with -race.",
+}
+
+// toolCall is a scripted tool use and the result it "returns".
+type toolCall struct {
+	name    string
+	input   func(workDir string) map[string]any
+	summary func(input map[string]any) string
+	output  string
+}
+
+var toolCalls = []toolCall{
+	{
+		name: "Bash",
+		input: func(string) map[string]any {
+			return map[string]any{"command": "go test ./...", "description": "Run the tests"}
+		},
+		summary: func(in map[string]any) string { return in["command"].(string) },
+		output:  "ok  \texample.com/app/internal/config\t0.012s\nok  \texample.com/app/internal/server\t0.231s",
+	},
+	{
+		name: "Read",
+		input: func(wd string) map[string]any {
+			return map[string]any{"file_path": filepath.Join(wd, "README.md")}
+		},
+		summary: func(in map[string]any) string { return in["file_path"].(string) },
+		output:  "     1\t# app\n     2\t\n     3\tA small service.",
+	},
+	{
+		name: "Grep",
+		input: func(string) map[string]any {
+			return map[string]any{"pattern": "TODO", "output_mode": "files_with_matches"}
+		},
+		summary: func(in map[string]any) string { return "pattern: \"" + in["pattern"].(string) + "\"" },
+		output:  "Found 2 files\ninternal/parser/lexer.go\ninternal/parser/parse.go",
+	},
+	{
+		name: "Bash",
+		input: func(string) map[string]any {
+			return map[string]any{"command": "git log --oneline -5", "description": "Show recent commits"}
+		},
+		summary: func(in map[string]any) string { return in["command"].(string) },
+		output:  "a1b2c3d Fix reconnect backoff\ne4f5a6b Add --timeout flag\n0c9d8e7 Update install docs",
+	},
+}
@@ -0,0 +1,398 @@
+// Package ghexport posts agent conversations to GitHub: when an agent
+// finishes a turn, a markdown summary is commented on the open pull request
+// whose head is the agent's current git branch.
+package ghexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+const (
+	defaultAPIURL = "https://api.github.com"
+	// maxCommentBytes stays under GitHub's 65536-character comment limit.
+	maxCommentBytes = 60000
+	maxPromptQuote  = 500
+	queueSize       = 64
+)
+
+// Comment modes.
+const (
+	// ModeTurns posts a new comment for every finished turn.
+	ModeTurns = "turns"
+	// ModeTranscript keeps one comment per conversation and PR, rewritten
+	// after each turn with the conversation so far.
+	ModeTranscript = "transcript"
+)
+
+// Exporter turns conversation events into PR comments. Events are grouped
+// into turns per conversation (user prompt → assistant replies → turn_end);
+// only turns that end after Start are posted, so history replay on startup
+// never floods a PR.
+type Exporter struct {
+	repo    string // owner/name
+	token   string
+	mode    string
+	apiURL  string
+	client  *http.Client
+	branch  func(workDir string) (string, error)
+	workDir func(agentName string) string
+
+	mu      sync.Mutex
+	turns   map[string]*turn // conversation ID → turn in progress
+	started time.Time
+	queue   chan *turn
+	done    chan struct{}
+
+	// Transcript mode state, owned by the run goroutine.
+	transcripts map[transcriptKey]*transcript
+}
+
+type turn struct {
+	agent    string
+	convID   string
+	prompt   string
+	replies  []string
+	replyAt  map[int64]int // seq → index in replies, for events updated in place
+	tools    map[string]int
+	duration time.Duration
+}
+
+type transcriptKey struct {
+	convID string
+	pr     int
+}
+
+type transcript struct {
+	commentID int64
+	turns     []string
+}
+
+// Load returns an exporter for repo ("owner/name"), or nil when repo is empty.
+// mode is ModeTurns or ModeTranscript; empty means ModeTurns.
+func Load(repo, token, mode string) (*Exporter, error) {
+	repo = strings.TrimSpace(repo)
+	if repo == "" {
+		return nil, nil
+	}
+	if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("github repo %q: want owner/name", repo)
+	}
+	if strings.TrimSpace(token) == "" {
+		return nil, fmt.Errorf("github repo %s: a token is required", repo)
+	}
+	switch mode {
+	case "":
+		mode = ModeTurns
+	case ModeTurns, ModeTranscript:
+	default:
+		return nil, fmt.Errorf("github comment mode %q: want %s or %s", mode, ModeTurns, ModeTranscript)
+	}
+	return &Exporter{
+		repo:        repo,
+		token:       strings.TrimSpace(token),
+		mode:        mode,
+		apiURL:      defaultAPIURL,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		branch:      gitBranch,
+		turns:       make(map[string]*turn),
+		queue:       make(chan *turn, queueSize),
+		done:        make(chan struct{}),
+		transcripts: make(map[transcriptKey]*transcript),
+	}, nil
+}
+
+// Start begins posting. workDir maps an agent name to its working directory,
+// whose checked-out branch selects the pull request.
+func (e *Exporter) Start(workDir func(agentName string) string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.workDir = workDir
+	e.started = time.Now()
+	e.mu.Unlock()
+	log.Printf("ghexport: commenting finished turns on %s pull requests (%s mode)", e.repo, e.mode)
+	go e.run()
+}
+
+// Stop discards queued comments and stops posting.
+func (e *Exporter) Stop() {
+	if e == nil {
+		return
+	}
+	close(e.done)
+}
+
+// Observe feeds a watcher event to the exporter. It never blocks.
+func (e *Exporter) Observe(we conv.WatcherEvent) {
+	if e == nil || (we.Type != "conversation-event" && we.Type != "conversation-event-updated") || we.Event == nil || we.Event.SubagentID != "" {
+		return
+	}
+	ev := we.Event
+	// An update repeats the event's earlier tool calls; only the ones it
+	// added are new.
+	calls := ev.Content
+	if we.Type == "conversation-event-updated" {
+		calls = we.Added
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	t := e.turns[ev.ConversationID]
+	switch ev.Type {
+	case conv.EventUser:
+		e.turns[ev.ConversationID] = &turn{agent: ev.AgentName, convID: ev.ConversationID, prompt: blockText(ev.Content), replyAt: make(map[int64]int), tools: make(map[string]int)}
+	case conv.EventAssistant:
+		if t != nil {
+			if text := blockText(ev.Content); text != "" {
+				if i, ok := t.replyAt[ev.Seq]; ok {
+					t.replies[i] = text
+				} else {
+					t.replyAt[ev.Seq] = len(t.replies)
+					t.replies = append(t.replies, text)
+				}
+			}
+		}
+		fallthrough
+	case conv.EventToolUse:
+		if t != nil {
+			for _, b := range calls {
+				if b.Type == "tool_use" {
+					t.tools[b.ToolName]++
+				}
+			}
+		}
+	case conv.EventTurnEnd:
+		delete(e.turns, ev.ConversationID)
+		if t == nil || e.started.IsZero() || ev.Timestamp.Before(e.started) {
+			return
+		}
+		t.duration = time.Duration(ev.DurationMs) * time.Millisecond
+		select {
+		case e.queue <- t:
+		default:
+			log.Printf("ghexport: queue full, dropping turn summary for %s", t.agent)
+		}
+	}
+}
+
+func (e *Exporter) run() {
+	for {
+		select {
+		case <-e.done:
+			return
+		case t := <-e.queue:
+			if err := e.post(t); err != nil {
+				log.Printf("ghexport: %s: %v", t.agent, err)
+			}
+		}
+	}
+}
+
+// post comments t's summary on the open PR for the agent's current branch,
+// or rewrites the conversation's transcript comment. Agents on a branch
+// without an open PR are skipped.
+func (e *Exporter) post(t *turn) error {
+	e.mu.Lock()
+	workDir := e.workDir
+	e.mu.Unlock()
+	dir := ""
+	if workDir != nil {
+		dir = workDir(t.agent)
+	}
+	if dir == "" {
+		return errors.New("agent working directory unknown")
+	}
+	branch, err := e.branch(dir)
+	if err != nil {
+		return fmt.Errorf("git branch in %s: %w", dir, err)
+	}
+	if branch == "" {
+		return nil // detached HEAD
+	}
+
+	number, err := e.findPR(branch)
+	if err != nil || number == 0 {
+		return err
+	}
+	if e.mode == ModeTranscript {
+		return e.postTranscript(t, number)
+	}
+	if _, err := e.comment(number, 0, t.markdown()); err != nil {
+		return fmt.Errorf("comment on #%d: %w", number, err)
+	}
+	log.Printf("ghexport: posted turn summary for %s on %s#%d (branch %s)", t.agent, e.repo, number, branch)
+	return nil
+}
+
+// postTranscript appends t to its conversation's transcript and creates or
+// edits the PR comment holding it. When the transcript outgrows a comment,
+// the oldest turns are dropped.
+func (e *Exporter) postTranscript(t *turn, number int) error {
+	key := transcriptKey{convID: t.convID, pr: number}
+	tr := e.transcripts[key]
+	if tr == nil {
+		tr = &transcript{}
+		e.transcripts[key] = tr
+	}
+	tr.turns = append(tr.turns, t.markdown())
+	header := fmt.Sprintf("### Conversation `%s` (%s)\n\n", t.convID, t.agent)
+	body := header + strings.Join(tr.turns, "\n\n---\n\n")
+	for len(body) > maxCommentBytes && len(tr.turns) > 1 {
+		tr.turns = tr.turns[1:]
+		body = header + "*(earlier turns omitted)*\n\n" + strings.Join(tr.turns, "\n\n---\n\n")
+	}
+
+	id, err := e.comment(number, tr.commentID, body)
+	if err != nil {
+		return fmt.Errorf("transcript comment on #%d: %w", number, err)
+	}
+	tr.commentID = id
+	return nil
+}
+
+// comment creates a comment on PR number, or edits comment id when it is
+// non-zero, and returns the comment's id.
+func (e *Exporter) comment(number int, id int64, text string) (int64, error) {
+	body, err := json.Marshal(map[string]string{"body": truncate(text, maxCommentBytes)})
+	if err != nil {
+		return 0, err
+	}
+	method, path := http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", e.repo, number)
+	if id != 0 {
+		method, path = http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", e.repo, id)
+	}
+	var out struct {
+		ID int64 `json:"id"`
+	}
+	if err := e.call(method, path, body, &out); err != nil {
+		return 0, err
+	}
+	return out.ID, nil
+}
+
+// findPR returns the number of the open PR whose head is branch in the
+// repo itself, or 0 if there is none.
+func (e *Exporter) findPR(branch string) (int, error) {
+	owner, _, _ := strings.Cut(e.repo, "/")
+	q := url.Values{"state": {"open"}, "head": {owner + ":" + branch}}
+	var pulls []struct {
+		Number int `json:"number"`
+	}
+	if err := e.call(http.MethodGet, "/repos/"+e.repo+"/pulls?"+q.Encode(), nil, &pulls); err != nil {
+		return 0, fmt.Errorf("find PR for %s: %w", branch, err)
+	}
+	if len(pulls) == 0 {
+		return 0, nil
+	}
+	return pulls[0].Number, nil
+}
+
+func (e *Exporter) call(method, path string, body []byte, out any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, e.apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+e.token)
+	req.Header.Set("User-Agent", "tmux-converter")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("github %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// markdown renders the turn as a PR comment.
+func (t *turn) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** finished a turn", t.agent)
+	if t.duration > 0 {
+		fmt.Fprintf(&b, " in %s", t.duration.Round(time.Second))
+	}
+	b.WriteString("\n\n")
+	if prompt := strings.TrimSpace(t.prompt); prompt != "" {
+		if len(prompt) > maxPromptQuote {
+			prompt = truncate(prompt, maxPromptQuote) + "…"
+		}
+		b.WriteString("> " + strings.ReplaceAll(prompt, "\n", "\n> ") + "\n\n")
+	}
+	b.WriteString(strings.Join(t.replies, "\n\n"))
+	if len(t.tools) > 0 {
+		names := make([]string, 0, len(t.tools))
+		for name := range t.tools {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			names[i] = fmt.Sprintf("%s ×%d", name, t.tools[name])
+		}
+		b.WriteString("\n\n<sub>Tools: " + strings.Join(names, ", ") + "</sub>")
+	}
+	return b.String()
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// blockText joins an event's text blocks.
+func blockText(blocks []conv.ContentBlock) string {
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" && strings.TrimSpace(b.Text) != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// gitBranch returns the branch checked out in dir, or "" on a detached HEAD.
+func gitBranch(dir string) (string, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return "", err
+	}
+	branch := strings.TrimSpace(string(out))
+	if branch == "HEAD" {
+		return "", nil
+	}
+	return branch, nil
+}
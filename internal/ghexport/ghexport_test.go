@@ -0,0 +1,162 @@
+package ghexport
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+type githubCall struct {
+	method string
+	path   string
+	body   string
+}
+
+// fakeGitHub answers PR lookups for branch "feature" with #7 and records comment writes.
+func fakeGitHub(t *testing.T) (*httptest.Server, func() []githubCall) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []githubCall
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet && r.URL.Path == "/repos/acme/app/pulls" {
+			if r.URL.Query().Get("head") == "acme:feature" {
+				_, _ = io.WriteString(w, `[{"number":7}]`)
+			} else {
+				_, _ = io.WriteString(w, `[]`)
+			}
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		calls = append(calls, githubCall{r.Method, r.URL.Path, string(body)})
+		mu.Unlock()
+		_, _ = io.WriteString(w, `{"id":99}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []githubCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]githubCall(nil), calls...)
+	}
+}
+
+func newTestExporter(t *testing.T, mode, apiURL string) *Exporter {
+	t.Helper()
+	e, err := Load("acme/app", "tok", mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.apiURL = apiURL
+	e.branch = func(dir string) (string, error) { return strings.TrimPrefix(dir, "/work/"), nil }
+	e.Start(func(agent string) string { return "/work/" + agent })
+	t.Cleanup(e.Stop)
+	return e
+}
+
+func feedTurn(e *Exporter, agent, convID, prompt, reply string, at time.Time) {
+	events := []conv.ConversationEvent{
+		{Type: conv.EventUser, AgentName: agent, ConversationID: convID, Timestamp: at, Content: []conv.ContentBlock{{Type: "text", Text: prompt}}},
+		{Type: conv.EventAssistant, AgentName: agent, ConversationID: convID, Timestamp: at, Content: []conv.ContentBlock{
+			{Type: "text", Text: reply},
+			{Type: "tool_use", ToolName: "Bash"},
+		}},
+		{Type: conv.EventTurnEnd, AgentName: agent, ConversationID: convID, Timestamp: at, DurationMs: 42000},
+	}
+	for i := range events {
+		e.Observe(conv.WatcherEvent{Type: "conversation-event", Event: &events[i]})
+	}
+}
+
+func waitCalls(t *testing.T, get func() []githubCall, n int) []githubCall {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if calls := get(); len(calls) >= n {
+			return calls
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d GitHub calls, got %d", n, len(get()))
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	if e, err := Load("", "", ""); e != nil || err != nil {
+		t.Fatalf("Load(\"\") = %v, %v; want nil, nil", e, err)
+	}
+	for _, tc := range []struct{ repo, token, mode string }{
+		{"acme", "tok", ""},
+		{"acme/app/x", "tok", ""},
+		{"acme/app", "", ""},
+		{"acme/app", "tok", "everything"},
+	} {
+		if _, err := Load(tc.repo, tc.token, tc.mode); err == nil {
+			t.Errorf("Load(%q, %q, %q) = nil error", tc.repo, tc.token, tc.mode)
+		}
+	}
+}
+
+func TestTurnsModePostsSummaryOnBranchPR(t *testing.T) {
+	srv, calls := fakeGitHub(t)
+	e := newTestExporter(t, ModeTurns, srv.URL)
+
+	// History from before Start, and agents on branches without a PR, post nothing.
+	feedTurn(e, "feature", "c1", "old prompt", "old reply", time.Now().Add(-time.Hour))
+	feedTurn(e, "main", "c2", "fix it", "fixed", time.Now().Add(time.Second))
+	feedTurn(e, "feature", "c1", "add tests", "Added **tests**.", time.Now().Add(time.Second))
+
+	got := waitCalls(t, calls, 1)
+	time.Sleep(50 * time.Millisecond)
+	if got = calls(); len(got) != 1 {
+		t.Fatalf("got %d comment calls, want 1: %+v", len(got), got)
+	}
+	if got[0].method != http.MethodPost || got[0].path != "/repos/acme/app/issues/7/comments" {
+		t.Fatalf("call = %s %s, want POST /repos/acme/app/issues/7/comments", got[0].method, got[0].path)
+	}
+	var body struct{ Body string }
+	if err := json.Unmarshal([]byte(got[0].body), &body); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"**feature** finished a turn in 42s", "> add tests", "Added **tests**.", "Tools: Bash ×1"} {
+		if !strings.Contains(body.Body, want) {
+			t.Errorf("comment missing %q:\n%s", want, body.Body)
+		}
+	}
+	if strings.Contains(body.Body, "old reply") {
+		t.Errorf("comment includes a turn from history:\n%s", body.Body)
+	}
+}
+
+func TestTranscriptModeEditsOneComment(t *testing.T) {
+	srv, calls := fakeGitHub(t)
+	e := newTestExporter(t, ModeTranscript, srv.URL)
+
+	feedTurn(e, "feature", "c1", "first", "one", time.Now().Add(time.Second))
+	waitCalls(t, calls, 1)
+	feedTurn(e, "feature", "c1", "second", "two", time.Now().Add(time.Second))
+	got := waitCalls(t, calls, 2)
+
+	if got[0].method != http.MethodPost || got[1].method != http.MethodPatch || got[1].path != "/repos/acme/app/issues/comments/99" {
+		t.Fatalf("calls = %s %s, %s %s; want POST then PATCH of comment 99", got[0].method, got[0].path, got[1].method, got[1].path)
+	}
+	if !strings.Contains(got[1].body, "one") || !strings.Contains(got[1].body, "two") {
+		t.Fatalf("edited transcript should hold both turns: %s", got[1].body)
+	}
+}
+
+func TestTruncateKeepsUTF8(t *testing.T) {
+	if got := truncate("héllo", 2); got != "h" {
+		t.Fatalf("truncate = %q, want %q", got, "h")
+	}
+}
@@ -0,0 +1,452 @@
+// Package notify posts agent events to Slack and Discord incoming webhooks.
+// Each configured notifier picks the agents and events it cares about and
+// renders messages from text/template templates.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+// Notification events.
+const (
+	EventTurnEnd         = "turn-end"
+	EventApprovalRequest = "approval-request"
+	EventError           = "error"
+	EventAgentExited     = "agent-exited"
+)
+
+// Webhook kinds.
+const (
+	KindSlack   = "slack"
+	KindDiscord = "discord"
+)
+
+const (
+	defaultApprovalAfter = 30 * time.Second
+	maxSummaryText       = 500
+	queueSize            = 64
+)
+
+// maxMessageBytes is each service's message length limit.
+var maxMessageBytes = map[string]int{KindSlack: 3000, KindDiscord: 2000}
+
+var allEvents = []string{EventTurnEnd, EventApprovalRequest, EventError, EventAgentExited}
+
+var defaultTemplates = map[string]string{
+	EventTurnEnd:         "✅ `{{.Agent}}` finished a turn{{if .Duration}} in {{.Duration}}{{end}}{{if .Text}}\n{{.Text}}{{end}}",
+	EventApprovalRequest: "⏳ `{{.Agent}}` has been waiting {{.Duration}} on {{.Tool}} and may need approval",
+	EventError:           "⚠️ `{{.Agent}}` hit an error: {{.Text}}",
+	EventAgentExited:     "🛑 `{{.Agent}}` exited",
+}
+
+// Config is the --notify-config file.
+type Config struct {
+	Notifiers []NotifierConfig `json:"notifiers"`
+	// ApprovalAfter is how long a tool call may go without a result before
+	// approval-request fires, e.g. "45s". Default 30s.
+	ApprovalAfter string `json:"approvalAfter,omitempty"`
+}
+
+// NotifierConfig is one webhook destination.
+type NotifierConfig struct {
+	Kind string `json:"kind"` // slack or discord
+	URL  string `json:"url"`
+	// Agents selects agents by name glob ("hq-*"), "role:<role>",
+	// "rig:<rig>", or "runtime:<runtime>". Empty selects every agent.
+	Agents []string `json:"agents,omitempty"`
+	// Events to send; empty sends all of them.
+	Events []string `json:"events,omitempty"`
+	// Templates overrides the message template per event.
+	Templates map[string]string `json:"templates,omitempty"`
+}
+
+// Message is the data a template renders.
+type Message struct {
+	Event          string
+	Agent          string
+	Role           string
+	Rig            string
+	Runtime        string
+	ConversationID string
+	Text           string        // assistant reply (turn-end) or error text
+	Tool           string        // approval-request only
+	Duration       time.Duration // turn length, or time spent waiting for approval
+}
+
+type notifier struct {
+	kind      string
+	url       string
+	selectors []string
+	events    map[string]bool
+	templates map[string]*template.Template
+}
+
+type delivery struct {
+	n    *notifier
+	text string
+}
+
+// Notifier routes watcher events to the configured webhooks.
+type Notifier struct {
+	notifiers     []*notifier
+	approvalAfter time.Duration
+	client        *http.Client
+
+	mu      sync.Mutex
+	agent   func(name string) (agents.Agent, bool)
+	started time.Time
+	replies map[string]string                 // conversation ID → latest assistant text
+	pending map[string]map[string]*time.Timer // conversation ID → tool ID → approval timer
+	stopped bool
+	queue   chan delivery
+	done    chan struct{}
+}
+
+// Load reads a notification config file, or returns nil when configPath is empty.
+func Load(configPath string) (*Notifier, error) {
+	if configPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("notify config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("notify config %s: %w", configPath, err)
+	}
+	n, err := New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("notify config %s: %w", configPath, err)
+	}
+	return n, nil
+}
+
+// New validates cfg and compiles its templates.
+func New(cfg Config) (*Notifier, error) {
+	n := &Notifier{
+		approvalAfter: defaultApprovalAfter,
+		client:        &http.Client{Timeout: 15 * time.Second},
+		replies:       make(map[string]string),
+		pending:       make(map[string]map[string]*time.Timer),
+		queue:         make(chan delivery, queueSize),
+		done:          make(chan struct{}),
+	}
+	if cfg.ApprovalAfter != "" {
+		d, err := time.ParseDuration(cfg.ApprovalAfter)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("approvalAfter %q: want a positive duration", cfg.ApprovalAfter)
+		}
+		n.approvalAfter = d
+	}
+	if len(cfg.Notifiers) == 0 {
+		return nil, fmt.Errorf("no notifiers configured")
+	}
+	for i, nc := range cfg.Notifiers {
+		if nc.Kind != KindSlack && nc.Kind != KindDiscord {
+			return nil, fmt.Errorf("notifier %d: kind %q: want %s or %s", i, nc.Kind, KindSlack, KindDiscord)
+		}
+		if !strings.HasPrefix(nc.URL, "https://") && !strings.HasPrefix(nc.URL, "http://") {
+			return nil, fmt.Errorf("notifier %d: url must be an http(s) webhook URL", i)
+		}
+		for _, sel := range nc.Agents {
+			if _, err := path.Match(sel, ""); err != nil {
+				return nil, fmt.Errorf("notifier %d: agent selector %q: %w", i, sel, err)
+			}
+		}
+		events := nc.Events
+		if len(events) == 0 {
+			events = allEvents
+		}
+		nt := &notifier{kind: nc.Kind, url: nc.URL, selectors: nc.Agents, events: make(map[string]bool), templates: make(map[string]*template.Template)}
+		for _, ev := range events {
+			src, ok := defaultTemplates[ev]
+			if !ok {
+				return nil, fmt.Errorf("notifier %d: unknown event %q", i, ev)
+			}
+			if custom := nc.Templates[ev]; custom != "" {
+				src = custom
+			}
+			tmpl, err := template.New(ev).Parse(src)
+			if err != nil {
+				return nil, fmt.Errorf("notifier %d: %s template: %w", i, ev, err)
+			}
+			nt.events[ev] = true
+			nt.templates[ev] = tmpl
+		}
+		for ev := range nc.Templates {
+			if !nt.events[ev] {
+				return nil, fmt.Errorf("notifier %d: template for %q, which it does not send", i, ev)
+			}
+		}
+		n.notifiers = append(n.notifiers, nt)
+	}
+	return n, nil
+}
+
+// Start begins delivering. agent looks up a live agent's role, rig, and
+// runtime for selector matching.
+func (n *Notifier) Start(agent func(name string) (agents.Agent, bool)) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	n.agent = agent
+	n.started = time.Now()
+	n.mu.Unlock()
+	log.Printf("notify: %d webhook notifier(s) active", len(n.notifiers))
+	go n.run()
+}
+
+// Stop cancels pending approval timers and stops delivering.
+func (n *Notifier) Stop() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	n.stopped = true
+	for _, timers := range n.pending {
+		for _, t := range timers {
+			t.Stop()
+		}
+	}
+	n.pending = make(map[string]map[string]*time.Timer)
+	n.mu.Unlock()
+	close(n.done)
+}
+
+// Observe feeds a watcher event to the notifier. It never blocks.
+func (n *Notifier) Observe(we conv.WatcherEvent) {
+	if n == nil {
+		return
+	}
+	if we.Type == "agent-removed" && we.Agent != nil {
+		n.notify(*we.Agent, Message{Event: EventAgentExited})
+		return
+	}
+	if (we.Type != "conversation-event" && we.Type != "conversation-event-updated") || we.Event == nil || we.Event.SubagentID != "" {
+		return
+	}
+	ev := we.Event
+	// An update repeats the event's earlier tool calls; only the ones it
+	// added are new.
+	calls := ev.Content
+	if we.Type == "conversation-event-updated" {
+		calls = we.Added
+	}
+
+	n.mu.Lock()
+	live := !n.started.IsZero() && !ev.Timestamp.Before(n.started)
+	switch ev.Type {
+	case conv.EventAssistant, conv.EventToolUse:
+		if text := blockText(ev.Content); text != "" {
+			n.replies[ev.ConversationID] = text
+		}
+		for _, b := range calls {
+			if live && b.Type == "tool_use" && b.ToolID != "" {
+				n.watchToolLocked(ev.AgentName, ev.ConversationID, b.ToolID, b.ToolName)
+			}
+		}
+	case conv.EventToolResult:
+		for _, b := range ev.Content {
+			if t := n.pending[ev.ConversationID][b.ToolID]; t != nil {
+				t.Stop()
+				delete(n.pending[ev.ConversationID], b.ToolID)
+			}
+		}
+	}
+	reply := n.replies[ev.ConversationID]
+	if ev.Type == conv.EventTurnEnd {
+		delete(n.replies, ev.ConversationID)
+	}
+	n.mu.Unlock()
+	if !live {
+		return
+	}
+
+	switch ev.Type {
+	case conv.EventTurnEnd:
+		n.notifyName(ev.AgentName, Message{
+			Event:          EventTurnEnd,
+			ConversationID: ev.ConversationID,
+			Text:           truncate(reply, maxSummaryText),
+			Duration:       (time.Duration(ev.DurationMs) * time.Millisecond).Round(time.Second),
+		})
+	case conv.EventError:
+		if ev.Metadata["errorKind"] == "parse" {
+			return // a malformed transcript line, not something the agent hit
+		}
+		n.notifyName(ev.AgentName, Message{Event: EventError, ConversationID: ev.ConversationID, Text: truncate(blockText(ev.Content), maxSummaryText)})
+	}
+}
+
+// watchToolLocked starts the approval timer for a tool call. Claude Code
+// writes a tool_use before asking permission and its tool_result only once
+// the tool has run, so a call that stays unanswered is most likely waiting
+// on a human (or is simply slow). Callers hold n.mu.
+func (n *Notifier) watchToolLocked(agentName, convID, toolID, toolName string) {
+	if n.stopped || n.pending[convID][toolID] != nil {
+		return
+	}
+	if n.pending[convID] == nil {
+		n.pending[convID] = make(map[string]*time.Timer)
+	}
+	n.pending[convID][toolID] = time.AfterFunc(n.approvalAfter, func() {
+		n.mu.Lock()
+		_, waiting := n.pending[convID][toolID]
+		delete(n.pending[convID], toolID)
+		if len(n.pending[convID]) == 0 {
+			delete(n.pending, convID)
+		}
+		n.mu.Unlock()
+		if waiting {
+			n.notifyName(agentName, Message{Event: EventApprovalRequest, ConversationID: convID, Tool: toolName, Duration: n.approvalAfter})
+		}
+	})
+}
+
+func (n *Notifier) notifyName(agentName string, msg Message) {
+	n.mu.Lock()
+	lookup := n.agent
+	n.mu.Unlock()
+	agent := agents.Agent{Name: agentName}
+	if lookup != nil {
+		if a, ok := lookup(agentName); ok {
+			agent = a
+		}
+	}
+	n.notify(agent, msg)
+}
+
+// notify renders msg for every notifier that wants this agent and event.
+func (n *Notifier) notify(agent agents.Agent, msg Message) {
+	msg.Agent, msg.Role, msg.Runtime = agent.Name, agent.Role, agent.Runtime
+	if agent.Rig != nil {
+		msg.Rig = *agent.Rig
+	}
+	for _, nt := range n.notifiers {
+		if !nt.events[msg.Event] || !nt.matches(agent) {
+			continue
+		}
+		var b strings.Builder
+		if err := nt.templates[msg.Event].Execute(&b, msg); err != nil {
+			log.Printf("notify: %s template for %s: %v", msg.Event, agent.Name, err)
+			continue
+		}
+		select {
+		case n.queue <- delivery{n: nt, text: truncate(b.String(), maxMessageBytes[nt.kind])}:
+		default:
+			log.Printf("notify: queue full, dropping %s for %s", msg.Event, agent.Name)
+		}
+	}
+}
+
+// matches reports whether any selector picks agent; no selectors pick all.
+func (nt *notifier) matches(agent agents.Agent) bool {
+	if len(nt.selectors) == 0 {
+		return true
+	}
+	for _, sel := range nt.selectors {
+		key, value, tagged := strings.Cut(sel, ":")
+		switch {
+		case tagged && key == "role":
+			if agent.Role == value {
+				return true
+			}
+		case tagged && key == "rig":
+			if agent.Rig != nil && *agent.Rig == value {
+				return true
+			}
+		case tagged && key == "runtime":
+			if agent.Runtime == value {
+				return true
+			}
+		default:
+			if ok, _ := path.Match(sel, agent.Name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *Notifier) run() {
+	for {
+		select {
+		case <-n.done:
+			return
+		case d := <-n.queue:
+			if err := n.post(d); err != nil {
+				log.Printf("notify: %s webhook: %v", d.n.kind, err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) post(d delivery) error {
+	payload := map[string]string{"text": d.text}
+	if d.n.kind == KindDiscord {
+		payload = map[string]string{"content": d.text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// blockText joins an event's text blocks.
+func blockText(blocks []conv.ContentBlock) string {
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" && strings.TrimSpace(b.Text) != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence,
+// marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	n -= len("…")
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
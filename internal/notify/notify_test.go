@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+// hook records the JSON bodies posted to a fake webhook.
+type hook struct {
+	mu     sync.Mutex
+	bodies []map[string]string
+}
+
+func newHook(t *testing.T) (*hook, string) {
+	t.Helper()
+	h := &hook{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]string
+		_ = json.Unmarshal(data, &body)
+		h.mu.Lock()
+		h.bodies = append(h.bodies, body)
+		h.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return h, srv.URL
+}
+
+func (h *hook) wait(t *testing.T, n int) []map[string]string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.Lock()
+		got := append([]map[string]string(nil), h.bodies...)
+		h.mu.Unlock()
+		if len(got) >= n || time.Now().After(deadline) {
+			if len(got) < n {
+				t.Fatalf("got %d webhook posts, want %d", len(got), n)
+			}
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+var gastown = "gastown"
+
+var testAgents = map[string]agents.Agent{
+	"hq-mayor":     {Name: "hq-mayor", Role: "mayor", Runtime: "claude"},
+	"gt-crew-jack": {Name: "gt-crew-jack", Role: "crew", Runtime: "claude", Rig: &gastown},
+}
+
+func startNotifier(t *testing.T, cfg Config) *Notifier {
+	t.Helper()
+	n, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Start(func(name string) (agents.Agent, bool) {
+		a, ok := testAgents[name]
+		return a, ok
+	})
+	t.Cleanup(n.Stop)
+	return n
+}
+
+func observe(n *Notifier, ev conv.ConversationEvent) {
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now().Add(time.Second)
+	}
+	if ev.ConversationID == "" {
+		ev.ConversationID = "claude:" + ev.AgentName + ":c1"
+	}
+	n.Observe(conv.WatcherEvent{Type: "conversation-event", Event: &ev})
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"empty":        {},
+		"kind":         {Notifiers: []NotifierConfig{{Kind: "teams", URL: "https://x"}}},
+		"url":          {Notifiers: []NotifierConfig{{Kind: KindSlack, URL: "hooks.slack.com"}}},
+		"event":        {Notifiers: []NotifierConfig{{Kind: KindSlack, URL: "https://x", Events: []string{"tool-use"}}}},
+		"template":     {Notifiers: []NotifierConfig{{Kind: KindSlack, URL: "https://x", Templates: map[string]string{EventError: "{{.Agent"}}}},
+		"unsent":       {Notifiers: []NotifierConfig{{Kind: KindSlack, URL: "https://x", Events: []string{EventError}, Templates: map[string]string{EventTurnEnd: "x"}}}},
+		"approval":     {ApprovalAfter: "soon", Notifiers: []NotifierConfig{{Kind: KindSlack, URL: "https://x"}}},
+		"bad selector": {Notifiers: []NotifierConfig{{Kind: KindSlack, URL: "https://x", Agents: []string{"hq-["}}}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: New succeeded, want error", name)
+		}
+	}
+}
+
+func TestTurnEndRoutedBySelector(t *testing.T) {
+	slack, slackURL := newHook(t)
+	discord, discordURL := newHook(t)
+	n := startNotifier(t, Config{Notifiers: []NotifierConfig{
+		{Kind: KindSlack, URL: slackURL, Agents: []string{"role:mayor"}, Events: []string{EventTurnEnd}},
+		{Kind: KindDiscord, URL: discordURL, Agents: []string{"rig:gastown"}, Templates: map[string]string{EventTurnEnd: "{{.Agent}} ({{.Rig}}) done"}},
+	}})
+
+	// History replayed at startup is not announced.
+	observe(n, conv.ConversationEvent{Type: conv.EventTurnEnd, AgentName: "hq-mayor", Timestamp: time.Now().Add(-time.Hour)})
+
+	observe(n, conv.ConversationEvent{Type: conv.EventAssistant, AgentName: "hq-mayor", Content: []conv.ContentBlock{{Type: "text", Text: "All tests pass."}}})
+	observe(n, conv.ConversationEvent{Type: conv.EventTurnEnd, AgentName: "hq-mayor", DurationMs: 3000})
+	observe(n, conv.ConversationEvent{Type: conv.EventTurnEnd, AgentName: "gt-crew-jack"})
+
+	got := slack.wait(t, 1)
+	if len(got) != 1 || got[0]["text"] != "✅ `hq-mayor` finished a turn in 3s\nAll tests pass." {
+		t.Fatalf("slack posts = %v", got)
+	}
+	got = discord.wait(t, 1)
+	if len(got) != 1 || got[0]["content"] != "gt-crew-jack (gastown) done" {
+		t.Fatalf("discord posts = %v", got)
+	}
+}
+
+func TestApprovalRequestAfterUnansweredToolUse(t *testing.T) {
+	slack, slackURL := newHook(t)
+	n := startNotifier(t, Config{ApprovalAfter: "30ms", Notifiers: []NotifierConfig{
+		{Kind: KindSlack, URL: slackURL, Agents: []string{"hq-*"}, Events: []string{EventApprovalRequest}},
+	}})
+
+	// Answered quickly: no notification.
+	observe(n, conv.ConversationEvent{Type: conv.EventToolUse, AgentName: "hq-mayor", Content: []conv.ContentBlock{{Type: "tool_use", ToolID: "t1", ToolName: "Read"}}})
+	observe(n, conv.ConversationEvent{Type: conv.EventToolResult, AgentName: "hq-mayor", Content: []conv.ContentBlock{{Type: "tool_result", ToolID: "t1"}}})
+	// Left waiting.
+	observe(n, conv.ConversationEvent{Type: conv.EventToolUse, AgentName: "hq-mayor", Content: []conv.ContentBlock{{Type: "tool_use", ToolID: "t2", ToolName: "Bash"}}})
+
+	got := slack.wait(t, 1)
+	time.Sleep(60 * time.Millisecond)
+	if got = slack.wait(t, 1); len(got) != 1 || !strings.Contains(got[0]["text"], "on Bash and may need approval") {
+		t.Fatalf("posts = %v", got)
+	}
+}
+
+func TestErrorAndAgentExited(t *testing.T) {
+	slack, slackURL := newHook(t)
+	n := startNotifier(t, Config{Notifiers: []NotifierConfig{{Kind: KindSlack, URL: slackURL}}})
+
+	observe(n, conv.ConversationEvent{Type: conv.EventError, AgentName: "hq-mayor", Metadata: map[string]any{"errorKind": "parse"}})
+	observe(n, conv.ConversationEvent{Type: conv.EventError, AgentName: "hq-mayor", Content: []conv.ContentBlock{{Type: "text", Text: "API overloaded"}}})
+	a := testAgents["gt-crew-jack"]
+	n.Observe(conv.WatcherEvent{Type: "agent-removed", Agent: &a})
+
+	got := slack.wait(t, 2)
+	time.Sleep(30 * time.Millisecond)
+	got = slack.wait(t, 2)
+	if len(got) != 2 || got[0]["text"] != "⚠️ `hq-mayor` hit an error: API overloaded" || got[1]["text"] != "🛑 `gt-crew-jack` exited" {
+		t.Fatalf("posts = %v", got)
+	}
+}
+
+func TestTruncateMarksCut(t *testing.T) {
+	if got := truncate("héllo world", 5); got != "h…" {
+		t.Fatalf("truncate = %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+package publish
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// kafkaREST produces to Kafka through a Confluent-compatible REST Proxy
+// (API v2), which keeps the Kafka wire protocol out of this binary.
+type kafkaREST struct {
+	base   string
+	client *http.Client
+}
+
+// kafkaRecord is one record of a REST Proxy produce request.
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+func newKafka(rawURL string) (*kafkaREST, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("kafka url %q: want the REST Proxy's http(s) URL", rawURL)
+	}
+	return &kafkaREST{base: strings.TrimSuffix(rawURL, "/"), client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// send produces the batch with one request per topic, keeping each topic's
+// records in order.
+func (k *kafkaREST) send(batch []message) error {
+	var topics []string
+	records := make(map[string][]kafkaRecord)
+	for _, m := range batch {
+		topic := kafkaTopic(m.subject)
+		if _, ok := records[topic]; !ok {
+			topics = append(topics, topic)
+		}
+		records[topic] = append(records[topic], kafkaRecord{Key: m.key, Value: m.data})
+	}
+	for _, topic := range topics {
+		if err := k.produce(topic, records[topic]); err != nil {
+			return fmt.Errorf("topic %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+func (k *kafkaREST) produce(topic string, records []kafkaRecord) error {
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.base+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	// A 200 can still carry per-record failures.
+	var result struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&result) == nil {
+		failed, first := 0, ""
+		for _, o := range result.Offsets {
+			if o.Error != "" {
+				failed++
+				first = cmp.Or(first, o.Error)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d records failed: %s", failed, len(records), first)
+		}
+	}
+	return nil
+}
+
+func (k *kafkaREST) close() {}
+
+// kafkaTopic replaces characters Kafka doesn't allow in topic names.
+func kafkaTopic(subject string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, subject)
+}
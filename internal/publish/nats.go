@@ -0,0 +1,198 @@
+package publish
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	natsDialTimeout = 10 * time.Second
+	natsRetryDelay  = 5 * time.Second // between failed connection attempts
+)
+
+// natsConn publishes over the NATS client protocol: a line-based text
+// protocol, of which publishing needs only CONNECT, PUB, and PING/PONG.
+// It connects on first send and again on the send after a failure.
+// Delivery is at most once: there are no JetStream acks, and only
+// user/password and token auth are supported, not NKeys or .creds files.
+type natsConn struct {
+	addr        string
+	tlsRequired bool
+	serverName  string
+	user, pass  string
+	token       string
+
+	mu         sync.Mutex // guards conn and w; held while writing
+	conn       net.Conn
+	w          *bufio.Writer
+	maxPayload int
+	retryAt    time.Time
+	lastErr    error
+}
+
+// natsInfo is the part of the server's INFO message used here.
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+func newNATS(rawURL, token string) (*natsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("nats url %q: want nats://host:port", rawURL)
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("nats url %q: scheme must be nats or tls", rawURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	n := &natsConn{addr: addr, tlsRequired: u.Scheme == "tls", serverName: u.Hostname(), token: token}
+	if u.User != nil {
+		n.user = u.User.Username()
+		n.pass, _ = u.User.Password()
+	}
+	return n, nil
+}
+
+func (n *natsConn) send(batch []message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		if time.Now().Before(n.retryAt) {
+			return fmt.Errorf("not connected: %w", n.lastErr)
+		}
+		if err := n.connectLocked(); err != nil {
+			n.retryAt, n.lastErr = time.Now().Add(natsRetryDelay), err
+			return err
+		}
+	}
+	for _, m := range batch {
+		if n.maxPayload > 0 && len(m.data) > n.maxPayload {
+			log.Printf("publish: nats: %d-byte message for %s exceeds the server's max payload, skipped", len(m.data), m.subject)
+			continue
+		}
+		_, _ = fmt.Fprintf(n.w, "PUB %s %d\r\n", m.subject, len(m.data))
+		_, _ = n.w.Write(m.data)
+		_, _ = n.w.WriteString("\r\n")
+	}
+	if err := n.w.Flush(); err != nil {
+		n.dropLocked()
+		return err
+	}
+	return nil
+}
+
+func (n *natsConn) close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn != nil {
+		_ = n.w.Flush()
+		n.dropLocked()
+	}
+}
+
+// connectLocked dials the server and completes the handshake: INFO from the
+// server, TLS if either side wants it, then CONNECT answered by PONG.
+func (n *natsConn) connectLocked() error {
+	conn, err := net.DialTimeout("tcp", n.addr, natsDialTimeout)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(natsDialTimeout))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("nats: read INFO: %w", err)
+	}
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "INFO ")
+	var info natsInfo
+	if !ok || json.Unmarshal([]byte(rest), &info) != nil {
+		_ = conn.Close()
+		return fmt.Errorf("nats: expected INFO, got %q", strings.TrimSpace(line))
+	}
+	if n.tlsRequired || info.TLSRequired {
+		tc := tls.Client(conn, &tls.Config{ServerName: n.serverName})
+		if err := tc.Handshake(); err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("nats: tls: %w", err)
+		}
+		conn, r = tc, bufio.NewReader(tc)
+	}
+
+	connect, _ := json.Marshal(map[string]any{
+		"verbose": false, "pedantic": false, "lang": "go", "name": "tmux-converter", "protocol": 1,
+		"user": n.user, "pass": n.pass, "auth_token": n.token,
+	})
+	w := bufio.NewWriter(conn)
+	_, _ = fmt.Fprintf(w, "CONNECT %s\r\nPING\r\n", connect)
+	if err := w.Flush(); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("nats: connect: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			_ = conn.Close()
+			return errors.New("nats: " + line)
+		}
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	n.conn, n.w, n.maxPayload = conn, w, info.MaxPayload
+	go n.readLoop(conn, r)
+	return nil
+}
+
+// readLoop answers the server's keepalive PINGs and reports its errors
+// until the connection closes.
+func (n *natsConn) readLoop(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			n.mu.Lock()
+			if n.conn == conn {
+				_, _ = n.w.WriteString("PONG\r\n")
+				_ = n.w.Flush()
+			}
+			n.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			log.Printf("publish: nats: %s", line)
+		}
+	}
+	n.mu.Lock()
+	if n.conn == conn {
+		n.dropLocked()
+	}
+	n.mu.Unlock()
+}
+
+// dropLocked closes the connection so the next send reconnects.
+func (n *natsConn) dropLocked() {
+	_ = n.conn.Close()
+	n.conn, n.w = nil, nil
+}
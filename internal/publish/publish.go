@@ -0,0 +1,330 @@
+// Package publish pushes normalized conversation events and agent lifecycle
+// events to message brokers, for pipelines that ingest from NATS or Kafka
+// rather than WebSockets. Each publisher names its subjects (NATS) or topics
+// (Kafka) with templates such as "gt.{runtime}.{agent}.events".
+package publish
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+// Publisher kinds.
+const (
+	KindNATS  = "nats"
+	KindKafka = "kafka" // through a Kafka REST Proxy
+)
+
+// Default subject templates.
+const (
+	DefaultEventSubject     = "tmux-converter.{runtime}.{agent}.events"
+	DefaultLifecycleSubject = "tmux-converter.{runtime}.{agent}.lifecycle"
+)
+
+const (
+	queueSize = 4096
+	batchSize = 256 // messages sent per round trip at most
+)
+
+// placeholders lists what subject templates may use.
+var placeholders = []string{"{agent}", "{runtime}", "{type}"}
+
+// Config is the --publish-config file.
+type Config struct {
+	Publishers []PublisherConfig `json:"publishers"`
+}
+
+// PublisherConfig is one broker destination.
+type PublisherConfig struct {
+	Kind string `json:"kind"` // nats or kafka
+	// URL is nats://[user:pass@]host:4222 (tls:// for TLS), or the Kafka
+	// REST Proxy's base URL.
+	URL string `json:"url"`
+	// Token authenticates to NATS; ignored for Kafka.
+	Token string `json:"token,omitempty"`
+	// EventSubject and LifecycleSubject name where conversation events and
+	// agent lifecycle events go: a NATS subject or Kafka topic in which
+	// {agent}, {runtime}, and {type} (the event type) are replaced. An
+	// empty template uses the default; "-" disables that stream.
+	EventSubject     string `json:"eventSubject,omitempty"`
+	LifecycleSubject string `json:"lifecycleSubject,omitempty"`
+}
+
+// Lifecycle is the payload of a lifecycle message.
+type Lifecycle struct {
+	Type           string        `json:"type"`
+	Agent          *agents.Agent `json:"agent,omitempty"`
+	Reason         string        `json:"reason,omitempty"`
+	ConversationID string        `json:"conversationId,omitempty"`
+	From           string        `json:"from,omitempty"` // conversation-switched and agent-model-changed
+	To             string        `json:"to,omitempty"`
+}
+
+// message is one payload bound for a subject; key is the agent name,
+// which Kafka uses to keep an agent's events on one partition.
+type message struct {
+	subject string
+	key     string
+	data    []byte
+}
+
+// transport delivers batches of messages to a broker.
+type transport interface {
+	send(batch []message) error
+	close()
+}
+
+type publisher struct {
+	kind      string
+	events    string
+	lifecycle string
+	transport transport
+	queue     chan message
+	dropped   atomic.Int64
+}
+
+// Publisher routes watcher events to the configured brokers.
+type Publisher struct {
+	publishers []*publisher
+
+	mu      sync.Mutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// Load reads a publish config file, or returns nil when configPath is empty.
+func Load(configPath string) (*Publisher, error) {
+	if configPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("publish config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("publish config %s: %w", configPath, err)
+	}
+	p, err := New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("publish config %s: %w", configPath, err)
+	}
+	return p, nil
+}
+
+// New validates cfg. Connections are made on Start.
+func New(cfg Config) (*Publisher, error) {
+	if len(cfg.Publishers) == 0 {
+		return nil, fmt.Errorf("no publishers configured")
+	}
+	p := &Publisher{}
+	for i, pc := range cfg.Publishers {
+		var t transport
+		var err error
+		switch pc.Kind {
+		case KindNATS:
+			t, err = newNATS(pc.URL, pc.Token)
+		case KindKafka:
+			t, err = newKafka(pc.URL)
+		default:
+			err = fmt.Errorf("kind %q: want %s or %s", pc.Kind, KindNATS, KindKafka)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("publisher %d: %w", i, err)
+		}
+		pub := &publisher{
+			kind:      pc.Kind,
+			events:    subjectTemplate(pc.EventSubject, DefaultEventSubject),
+			lifecycle: subjectTemplate(pc.LifecycleSubject, DefaultLifecycleSubject),
+			transport: t,
+			queue:     make(chan message, queueSize),
+		}
+		for _, tmpl := range []string{pub.events, pub.lifecycle} {
+			if err := checkTemplate(tmpl); err != nil {
+				return nil, fmt.Errorf("publisher %d: %w", i, err)
+			}
+		}
+		p.publishers = append(p.publishers, pub)
+	}
+	return p, nil
+}
+
+func subjectTemplate(tmpl, def string) string {
+	switch tmpl {
+	case "":
+		return def
+	case "-":
+		return ""
+	}
+	return tmpl
+}
+
+// checkTemplate rejects braces that aren't a known placeholder.
+func checkTemplate(tmpl string) error {
+	rest := tmpl
+	for _, ph := range placeholders {
+		rest = strings.ReplaceAll(rest, ph, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("subject %q: placeholders are %s", tmpl, strings.Join(placeholders, ", "))
+	}
+	return nil
+}
+
+// Start begins delivering.
+func (p *Publisher) Start() {
+	if p == nil {
+		return
+	}
+	for _, pub := range p.publishers {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			pub.run()
+		}()
+	}
+	log.Printf("publish: %d broker publisher(s) active", len(p.publishers))
+}
+
+// Stop delivers what is already queued, then closes the connections.
+func (p *Publisher) Stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	p.stopped = true
+	for _, pub := range p.publishers {
+		close(pub.queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// Observe publishes a watcher event. It never blocks: when a broker can't
+// keep up, its messages are dropped and counted.
+func (p *Publisher) Observe(we conv.WatcherEvent) {
+	if p == nil {
+		return
+	}
+	var agentName, runtime string
+	var lifecycle bool
+	var payload any
+	switch we.Type {
+	case "conversation-event", "conversation-event-updated":
+		if we.Event == nil {
+			return
+		}
+		agentName, runtime, payload = we.Event.AgentName, we.Event.Runtime, we.Event
+	case "agent-added", "agent-removed", "agent-updated", "agent-renamed", "agent-model-changed", "conversation-started", "conversation-switched":
+		if we.Agent == nil {
+			return
+		}
+		agentName, runtime, lifecycle = we.Agent.Name, we.Agent.Runtime, true
+		payload = lifecycleOf(we)
+	default:
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	typ := we.Type
+	if !lifecycle {
+		typ = we.Event.Type
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	for _, pub := range p.publishers {
+		tmpl := pub.events
+		if lifecycle {
+			tmpl = pub.lifecycle
+		}
+		if tmpl == "" {
+			continue
+		}
+		subject := strings.NewReplacer("{agent}", token(agentName), "{runtime}", token(runtime), "{type}", token(typ)).Replace(tmpl)
+		select {
+		case pub.queue <- message{subject: subject, key: agentName, data: data}:
+		default:
+			pub.dropped.Add(1)
+		}
+	}
+}
+
+// subjectUnsafe replaces what would split or wildcard a NATS subject token.
+var subjectUnsafe = strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_")
+
+// token makes a placeholder value one non-empty subject token.
+func token(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return subjectUnsafe.Replace(s)
+}
+
+func lifecycleOf(we conv.WatcherEvent) Lifecycle {
+	l := Lifecycle{Type: we.Type, Agent: we.Agent, Reason: we.Reason}
+	switch we.Type {
+	case "agent-model-changed":
+		l.From, l.To = we.OldModel, we.NewModel
+	case "agent-renamed":
+		l.From, l.To = we.OldName, we.Agent.Name
+	case "conversation-started":
+		l.ConversationID = we.NewConvID
+	case "conversation-switched":
+		l.ConversationID, l.From, l.To = we.NewConvID, we.OldConvID, we.NewConvID
+	}
+	return l
+}
+
+// run sends queued messages in batches until the queue is closed.
+func (pub *publisher) run() {
+	defer pub.transport.close()
+	batch := make([]message, 0, batchSize)
+	var lastErr string
+	var loggedAt time.Time
+	for msg := range pub.queue {
+		if n := pub.dropped.Swap(0); n > 0 {
+			log.Printf("publish: %s: queue full, dropped %d message(s)", pub.kind, n)
+		}
+		batch = append(batch[:0], msg)
+	fill:
+		for len(batch) < batchSize {
+			select {
+			case m, ok := <-pub.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, m)
+			default:
+				break fill
+			}
+		}
+		if err := pub.transport.send(batch); err != nil {
+			// While a broker is down every batch fails the same way.
+			if err.Error() != lastErr || time.Since(loggedAt) > time.Minute {
+				log.Printf("publish: %s: dropped %d message(s): %v", pub.kind, len(batch), err)
+				lastErr, loggedAt = err.Error(), time.Now()
+			}
+		} else {
+			lastErr = ""
+		}
+	}
+}
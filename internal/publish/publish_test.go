@@ -0,0 +1,187 @@
+package publish
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+// fakeNATS accepts one client, completes the handshake, and records what
+// it publishes.
+type fakeNATS struct {
+	mu      sync.Mutex
+	connect string
+	pubs    map[string][]string // subject → payloads
+}
+
+func newFakeNATS(t *testing.T) (*fakeNATS, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	f := &fakeNATS{pubs: make(map[string][]string)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = io.WriteString(conn, `INFO {"server_id":"test","max_payload":1048576}`+"\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 0:
+			case fields[0] == "CONNECT":
+				f.mu.Lock()
+				f.connect = strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+				f.mu.Unlock()
+			case fields[0] == "PING":
+				_, _ = io.WriteString(conn, "PONG\r\n")
+			case fields[0] == "PUB" && len(fields) == 3:
+				n, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, n+2)
+				if _, err := io.ReadFull(r, payload); err != nil {
+					return
+				}
+				f.mu.Lock()
+				f.pubs[fields[1]] = append(f.pubs[fields[1]], string(payload[:n]))
+				f.mu.Unlock()
+			}
+		}
+	}()
+	return f, "nats://alice:secret@" + ln.Addr().String()
+}
+
+func agentEvent(typ string) conv.WatcherEvent {
+	return conv.WatcherEvent{Type: typ, Agent: &agents.Agent{Name: "hq-mayor", Runtime: "claude"}}
+}
+
+func conversationEvent(agent, typ string) conv.WatcherEvent {
+	return conv.WatcherEvent{Type: "conversation-event", Event: &conv.ConversationEvent{
+		EventID: "e1", Type: typ, AgentName: agent, Runtime: "claude", ConversationID: "claude:" + agent + ":abc",
+	}}
+}
+
+func TestPublishNATS(t *testing.T) {
+	srv, url := newFakeNATS(t)
+	p, err := New(Config{Publishers: []PublisherConfig{{
+		Kind: KindNATS, URL: url,
+		EventSubject: "gt.{runtime}.{agent}.{type}",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Start()
+	p.Observe(agentEvent("agent-added"))
+	p.Observe(conversationEvent("hq-mayor", conv.EventAssistant))
+	p.Observe(conversationEvent("crew.max", conv.EventUser))
+	p.Observe(conv.WatcherEvent{Type: "conversation-event"}) // no event: ignored
+	p.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		srv.mu.Lock()
+		n := len(srv.pubs)
+		srv.mu.Unlock()
+		if n == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	var connect map[string]any
+	if err := json.Unmarshal([]byte(srv.connect), &connect); err != nil || connect["user"] != "alice" || connect["pass"] != "secret" {
+		t.Fatalf("CONNECT = %s (%v)", srv.connect, err)
+	}
+	lifecycle := srv.pubs["tmux-converter.claude.hq-mayor.lifecycle"]
+	if len(lifecycle) != 1 || !strings.Contains(lifecycle[0], `"type":"agent-added"`) {
+		t.Fatalf("lifecycle messages = %v (all: %v)", lifecycle, srv.pubs)
+	}
+	if got := srv.pubs["gt.claude.hq-mayor.assistant"]; len(got) != 1 || !strings.Contains(got[0], `"eventId":"e1"`) {
+		t.Fatalf("assistant event = %v (all: %v)", got, srv.pubs)
+	}
+	// A dot in the agent name would have split the subject token.
+	if got := srv.pubs["gt.claude.crew_max.user"]; len(got) != 1 {
+		t.Fatalf("user event = %v (all: %v)", got, srv.pubs)
+	}
+}
+
+func TestPublishKafkaREST(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string][]kafkaRecord)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+			http.Error(w, "bad content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var body struct {
+			Records []kafkaRecord `json:"records"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		topic := strings.TrimPrefix(r.URL.Path, "/topics/")
+		got[topic] = append(got[topic], body.Records...)
+		mu.Unlock()
+		_, _ = io.WriteString(w, `{"offsets":[{"partition":0,"offset":1}]}`)
+	}))
+	defer srv.Close()
+
+	p, err := New(Config{Publishers: []PublisherConfig{{
+		Kind: KindKafka, URL: srv.URL,
+		EventSubject:     "agents-{runtime}",
+		LifecycleSubject: "-",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Start()
+	p.Observe(agentEvent("agent-removed"))
+	p.Observe(conversationEvent("hq-mayor", conv.EventAssistant))
+	p.Observe(conversationEvent("hq-mayor", conv.EventTurnEnd))
+	p.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || len(got["agents-claude"]) != 2 {
+		t.Fatalf("records by topic = %v", got)
+	}
+	if rec := got["agents-claude"][0]; rec.Key != "hq-mayor" || !strings.Contains(string(rec.Value), `"type":"assistant"`) {
+		t.Fatalf("first record = %+v", rec)
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	for _, pc := range []PublisherConfig{
+		{Kind: "redis", URL: "redis://localhost"},
+		{Kind: KindNATS, URL: "http://localhost:4222"},
+		{Kind: KindKafka, URL: "localhost:8082"},
+		{Kind: KindNATS, URL: "nats://localhost", EventSubject: "gt.{conversation}"},
+	} {
+		if _, err := New(Config{Publishers: []PublisherConfig{pc}}); err == nil {
+			t.Errorf("New(%+v) should fail", pc)
+		}
+	}
+	if _, err := New(Config{}); err == nil {
+		t.Error("New with no publishers should fail")
+	}
+}
@@ -0,0 +1,164 @@
+// Package retention prunes persisted conversation data so long-running hosts
+// don't fill their disks: files under the state directories by age and total
+// size, and conversation records in the store by age.
+package retention
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/store"
+)
+
+// Policy bounds persisted data. Zero fields impose no limit.
+type Policy struct {
+	MaxAge   time.Duration // remove files and records older than this
+	MaxBytes int64         // then remove the oldest files until the total fits
+}
+
+// Enabled reports whether the policy limits anything.
+func (p Policy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxBytes > 0
+}
+
+// Result reports what one prune removed.
+type Result struct {
+	Files         int   `json:"files"`
+	Bytes         int64 `json:"bytes"`
+	Conversations int   `json:"conversations"`
+	Remaining     int64 `json:"remainingBytes"`
+}
+
+// Pruner applies a Policy to a set of directories and a store.
+type Pruner struct {
+	policy Policy
+	dirs   []string
+	store  store.Store
+
+	mu   sync.Mutex // one prune at a time
+	done chan struct{}
+	once sync.Once
+}
+
+// New creates a pruner for dirs and, if non-nil, st.
+func New(policy Policy, st store.Store, dirs ...string) *Pruner {
+	return &Pruner{policy: policy, dirs: dirs, store: st, done: make(chan struct{})}
+}
+
+// Start prunes now and then every interval until Stop.
+func (p *Pruner) Start(interval time.Duration) {
+	if p == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := p.PruneNow(); err != nil {
+				log.Printf("retention: %v", err)
+			}
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends background pruning.
+func (p *Pruner) Stop() {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() { close(p.done) })
+}
+
+type prunable struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// PruneNow applies the policy once.
+func (p *Pruner) PruneNow() (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var res Result
+	now := time.Now()
+	files, err := p.collect()
+	if err != nil {
+		return res, err
+	}
+	// Oldest first, so the size pass drops the least recent data.
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	for _, f := range files {
+		expired := p.policy.MaxAge > 0 && now.Sub(f.modTime) > p.policy.MaxAge
+		oversize := p.policy.MaxBytes > 0 && total > p.policy.MaxBytes
+		if !expired && !oversize {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("retention: remove %s: %v", f.path, err)
+			continue
+		}
+		res.Files++
+		res.Bytes += f.size
+		total -= f.size
+	}
+	res.Remaining = total
+
+	if p.store != nil && p.policy.MaxAge > 0 {
+		n, err := p.store.PruneConversations(now.Add(-p.policy.MaxAge))
+		if err != nil {
+			return res, fmt.Errorf("prune conversation records: %w", err)
+		}
+		res.Conversations = n
+	}
+	if res.Files > 0 || res.Conversations > 0 {
+		log.Printf("retention: removed %d files (%d bytes) and %d conversation records; %d bytes remain", res.Files, res.Bytes, res.Conversations, res.Remaining)
+	}
+	return res, nil
+}
+
+// collect lists the regular files under the pruner's directories, skipping
+// dot-files, which are writes still in progress.
+func (p *Pruner) collect() ([]prunable, error) {
+	var files []prunable
+	for _, dir := range p.dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil // removed since the walk listed it
+			}
+			files = append(files, prunable{path: path, size: info.Size(), modTime: info.ModTime()})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scan %s: %w", dir, err)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,91 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/store"
+)
+
+// writeAged creates a file of size bytes last modified age ago.
+func writeAged(t *testing.T, path string, size int, age time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestPruneByAge(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "snapshots", "old.json.gz")
+	fresh := filepath.Join(dir, "snapshots", "fresh.json.gz")
+	inProgress := filepath.Join(dir, "snapshots", ".snapshot-123")
+	writeAged(t, old, 100, 72*time.Hour)
+	writeAged(t, fresh, 100, time.Hour)
+	writeAged(t, inProgress, 100, 72*time.Hour)
+
+	res, err := New(Policy{MaxAge: 48 * time.Hour}, nil, filepath.Join(dir, "snapshots"), filepath.Join(dir, "missing")).PruneNow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Files != 1 || res.Bytes != 100 || res.Remaining != 100 {
+		t.Fatalf("result = %+v", res)
+	}
+	if exists(old) || !exists(fresh) || !exists(inProgress) {
+		t.Fatalf("old=%v fresh=%v inProgress=%v; want only old removed", exists(old), exists(fresh), exists(inProgress))
+	}
+}
+
+func TestPruneByTotalBytesRemovesOldestFirst(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "nested", "b")
+	c := filepath.Join(dir, "c")
+	writeAged(t, a, 400, 3*time.Hour)
+	writeAged(t, b, 400, 2*time.Hour)
+	writeAged(t, c, 400, time.Hour)
+
+	res, err := New(Policy{MaxBytes: 1000}, nil, dir).PruneNow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Files != 1 || res.Remaining != 800 {
+		t.Fatalf("result = %+v", res)
+	}
+	if exists(a) || !exists(b) || !exists(c) {
+		t.Fatalf("a=%v b=%v c=%v; want only the oldest removed", exists(a), exists(b), exists(c))
+	}
+}
+
+func TestPruneConversationRecords(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = st.Close() }()
+	if err := st.PutConversation(store.Conversation{ID: "old", AgentName: "a", Runtime: "claude", Path: "/p", LastSeen: time.Now().Add(-72 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := New(Policy{MaxAge: 48 * time.Hour}, st).PruneNow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Conversations != 1 {
+		t.Fatalf("Conversations pruned = %d, want 1", res.Conversations)
+	}
+}
@@ -0,0 +1,167 @@
+// Package service installs the adapter as a per-user systemd unit (Linux) or
+// launchd agent (macOS) so it starts at login and restarts on failure.
+package service
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Config describes the service to install.
+type Config struct {
+	Name     string   // service name, e.g. "tmux-adapter"
+	Binary   string   // absolute path to the executable
+	Args     []string // flags passed to the executable
+	StateDir string   // working directory and log location
+}
+
+// DefaultStateDir returns the per-user state directory for a service name.
+func DefaultStateDir(name string) string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "state", name)
+}
+
+// Install writes the unit/plist for the current platform and enables it.
+func Install(cfg Config) (string, error) {
+	if err := os.MkdirAll(cfg.StateDir, 0755); err != nil {
+		return "", fmt.Errorf("create state dir %s: %w", cfg.StateDir, err)
+	}
+
+	path, err := unitPath(cfg.Name)
+	if err != nil {
+		return "", err
+	}
+	var content string
+	switch runtime.GOOS {
+	case "linux":
+		content = SystemdUnit(cfg)
+	case "darwin":
+		content = LaunchdPlist(cfg)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	// Owner-only: the baked-in flags can hold --auth-token and other secrets.
+	// Chmod too, since WriteFile keeps the mode of a unit being reinstalled.
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return "", fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		return "", fmt.Errorf("chmod %s: %w", path, err)
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		if err := run("systemctl", "--user", "daemon-reload"); err != nil {
+			return path, err
+		}
+		return path, run("systemctl", "--user", "enable", "--now", cfg.Name+".service")
+	default:
+		_ = run("launchctl", "unload", path) // reinstall: ignore "not loaded"
+		return path, run("launchctl", "load", "-w", path)
+	}
+}
+
+// Uninstall stops and disables the service and removes its unit/plist.
+func Uninstall(name string) (string, error) {
+	path, err := unitPath(name)
+	if err != nil {
+		return "", err
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		_ = run("systemctl", "--user", "disable", "--now", name+".service")
+	default:
+		_ = run("launchctl", "unload", "-w", path)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return path, fmt.Errorf("remove %s: %w", path, err)
+	}
+	if runtime.GOOS == "linux" {
+		return path, run("systemctl", "--user", "daemon-reload")
+	}
+	return path, nil
+}
+
+func unitPath(name string) (string, error) {
+	home := os.Getenv("HOME")
+	switch runtime.GOOS {
+	case "linux":
+		return filepath.Join(home, ".config", "systemd", "user", name+".service"), nil
+	case "darwin":
+		return filepath.Join(home, "Library", "LaunchAgents", launchdLabel(name)+".plist"), nil
+	default:
+		return "", fmt.Errorf("service management is not supported on %s", runtime.GOOS)
+	}
+}
+
+func launchdLabel(name string) string {
+	return "com.gastownhall." + name
+}
+
+// SystemdUnit renders a systemd user unit for cfg.
+func SystemdUnit(cfg Config) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", cfg.Name)
+	fmt.Fprintf(&b, "After=default.target\n\n")
+	fmt.Fprintf(&b, "[Service]\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", systemdCommand(cfg.Binary, cfg.Args))
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", cfg.StateDir)
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=2\n\n")
+	fmt.Fprintf(&b, "[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=default.target\n")
+	return b.String()
+}
+
+// systemdCommand quotes each word so paths and flag values with spaces survive.
+func systemdCommand(binary string, args []string) string {
+	words := make([]string, 0, len(args)+1)
+	for _, w := range append([]string{binary}, args...) {
+		if w == "" || strings.ContainsAny(w, " \t\"'\\$%") {
+			w = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`).Replace(w) + `"`
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// LaunchdPlist renders a launchd agent plist for cfg.
+func LaunchdPlist(cfg Config) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString(`<plist version="1.0">` + "\n<dict>\n")
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", launchdLabel(cfg.Name))
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, w := range append([]string{cfg.Binary}, cfg.Args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", html.EscapeString(w))
+	}
+	b.WriteString("\t</array>\n")
+	fmt.Fprintf(&b, "\t<key>WorkingDirectory</key>\n\t<string>%s</string>\n", html.EscapeString(cfg.StateDir))
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	fmt.Fprintf(&b, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n", html.EscapeString(filepath.Join(cfg.StateDir, cfg.Name+".log")))
+	fmt.Fprintf(&b, "\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", html.EscapeString(filepath.Join(cfg.StateDir, cfg.Name+".log")))
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
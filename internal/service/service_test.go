@@ -0,0 +1,54 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	unit := SystemdUnit(Config{
+		Name:     "tmux-adapter",
+		Binary:   "/usr/local/bin/tmux-adapter",
+		Args:     []string{"--gt-dir", "/home/me/my gt", "--port", "8080"},
+		StateDir: "/home/me/.local/state/tmux-adapter",
+	})
+
+	want := `ExecStart=/usr/local/bin/tmux-adapter --gt-dir "/home/me/my gt" --port 8080`
+	if !strings.Contains(unit, want) {
+		t.Fatalf("unit missing %q:\n%s", want, unit)
+	}
+	if !strings.Contains(unit, "WorkingDirectory=/home/me/.local/state/tmux-adapter") {
+		t.Fatalf("unit missing WorkingDirectory:\n%s", unit)
+	}
+	if !strings.Contains(unit, "WantedBy=default.target") {
+		t.Fatalf("unit missing install section:\n%s", unit)
+	}
+}
+
+func TestSystemdCommandEscapes(t *testing.T) {
+	got := systemdCommand("/bin/x", []string{`a"b`, "100%", ""})
+	want := `/bin/x "a\"b" "100%%" ""`
+	if got != want {
+		t.Fatalf("systemdCommand() = %s, want %s", got, want)
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	plist := LaunchdPlist(Config{
+		Name:     "tmux-adapter",
+		Binary:   "/usr/local/bin/tmux-adapter",
+		Args:     []string{"--auth-token", "a&b"},
+		StateDir: "/Users/me/state",
+	})
+
+	for _, want := range []string{
+		"<string>com.gastownhall.tmux-adapter</string>",
+		"<string>/usr/local/bin/tmux-adapter</string>",
+		"<string>a&amp;b</string>",
+		"<string>/Users/me/state/tmux-adapter.log</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Fatalf("plist missing %q:\n%s", want, plist)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+func init() {
+	Register("sqlite", OpenSQLite)
+}
+
+// sqliteMigrations are applied in order; the database's user_version is the
+// number already applied. Append only.
+var sqliteMigrations = []string{
+	`CREATE TABLE conversations (
+		id         TEXT PRIMARY KEY,
+		agent_name TEXT NOT NULL,
+		runtime    TEXT NOT NULL,
+		path       TEXT NOT NULL,
+		first_seen INTEGER NOT NULL,
+		last_seen  INTEGER NOT NULL
+	);
+	CREATE INDEX conversations_agent ON conversations (agent_name, last_seen);
+	CREATE TABLE agent_state (
+		agent_name          TEXT PRIMARY KEY,
+		active_conversation TEXT NOT NULL DEFAULT '',
+		model               TEXT NOT NULL DEFAULT ''
+	);`,
+	`CREATE TABLE annotations (
+		id              TEXT PRIMARY KEY,
+		conversation_id TEXT NOT NULL,
+		author          TEXT NOT NULL,
+		text            TEXT NOT NULL,
+		created_at      INTEGER NOT NULL
+	);
+	CREATE INDEX annotations_conversation ON annotations (conversation_id, created_at);`,
+	// Conversation IDs lose their agent name: "runtime:agent:native"
+	// becomes "runtime:native". A native conversation recorded under two
+	// agents keeps one row.
+	`CREATE TEMP TABLE id_map AS
+		SELECT id AS old, substr(id, 1, instr(id, ':')) || substr(rest, instr(rest, ':') + 1) AS new
+		FROM (SELECT id, substr(id, instr(id, ':') + 1) AS rest FROM conversations
+			UNION SELECT conversation_id, substr(conversation_id, instr(conversation_id, ':') + 1) FROM annotations
+			UNION SELECT active_conversation, substr(active_conversation, instr(active_conversation, ':') + 1) FROM agent_state)
+		WHERE instr(rest, ':') > 0;
+	UPDATE OR REPLACE conversations SET id = (SELECT new FROM id_map WHERE old = id) WHERE id IN (SELECT old FROM id_map);
+	UPDATE annotations SET conversation_id = (SELECT new FROM id_map WHERE old = conversation_id) WHERE conversation_id IN (SELECT old FROM id_map);
+	UPDATE agent_state SET active_conversation = (SELECT new FROM id_map WHERE old = active_conversation) WHERE active_conversation IN (SELECT old FROM id_map);
+	DROP TABLE id_map;`,
+}
+
+// SQLite is the built-in Store backed by a single database file.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (creating if needed) the database at dsn, a file path or
+// "sqlite://<path>", and brings its schema up to date.
+func OpenSQLite(dsn string) (Store, error) {
+	path := strings.TrimPrefix(dsn, "sqlite://")
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("store: %w", err)
+		}
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("store: open %s: %w", path, err)
+	}
+	// One connection: SQLite serializes writers anyway, and :memory: databases
+	// are per-connection.
+	db.SetMaxOpenConns(1)
+	if err := migrateSQLite(db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("store: migrate %s: %w", path, err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("schema version %d is newer than this binary supports (%d)", version, len(sqliteMigrations))
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLite) PutConversation(c Conversation) error {
+	now := time.Now()
+	if c.FirstSeen.IsZero() {
+		c.FirstSeen = now
+	}
+	if c.LastSeen.IsZero() {
+		c.LastSeen = now
+	}
+	_, err := s.db.Exec(`INSERT INTO conversations (id, agent_name, runtime, path, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			agent_name = excluded.agent_name,
+			runtime = excluded.runtime,
+			path = excluded.path,
+			last_seen = excluded.last_seen`,
+		c.ID, c.AgentName, c.Runtime, c.Path, c.FirstSeen.UnixMilli(), c.LastSeen.UnixMilli())
+	return err
+}
+
+func (s *SQLite) Conversation(id string) (Conversation, bool, error) {
+	row := s.db.QueryRow(`SELECT id, agent_name, runtime, path, first_seen, last_seen FROM conversations WHERE id = ?`, id)
+	c, err := scanConversation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Conversation{}, false, nil
+	}
+	if err != nil {
+		return Conversation{}, false, err
+	}
+	return c, true, nil
+}
+
+func (s *SQLite) Conversations(agentName string) ([]Conversation, error) {
+	query := `SELECT id, agent_name, runtime, path, first_seen, last_seen FROM conversations`
+	var args []any
+	if agentName != "" {
+		query += ` WHERE agent_name = ?`
+		args = append(args, agentName)
+	}
+	rows, err := s.db.Query(query+` ORDER BY last_seen DESC, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var result []Conversation
+	for rows.Next() {
+		c, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLite) PruneConversations(cutoff time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	res, err := tx.Exec(`DELETE FROM conversations WHERE last_seen < ?
+		AND id NOT IN (SELECT active_conversation FROM agent_state)`, cutoff.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	// Annotations on conversations the store never recorded are kept: they
+	// may belong to one whose record hasn't been written yet.
+	if _, err := tx.Exec(`DELETE FROM annotations WHERE created_at < ?
+		AND conversation_id NOT IN (SELECT id FROM conversations)`, cutoff.UnixMilli()); err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+func (s *SQLite) AddAnnotation(a Annotation) error {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	_, err := s.db.Exec(`INSERT INTO annotations (id, conversation_id, author, text, created_at) VALUES (?, ?, ?, ?, ?)`,
+		a.ID, a.ConversationID, a.Author, a.Text, a.CreatedAt.UnixMilli())
+	return err
+}
+
+func (s *SQLite) Annotations(conversationID string) ([]Annotation, error) {
+	rows, err := s.db.Query(`SELECT id, conversation_id, author, text, created_at FROM annotations
+		WHERE conversation_id = ? ORDER BY created_at, id`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var result []Annotation
+	for rows.Next() {
+		var a Annotation
+		var created int64
+		if err := rows.Scan(&a.ID, &a.ConversationID, &a.Author, &a.Text, &created); err != nil {
+			return nil, err
+		}
+		a.CreatedAt = time.UnixMilli(created)
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLite) SetActiveConversation(agentName, conversationID string) error {
+	_, err := s.db.Exec(`INSERT INTO agent_state (agent_name, active_conversation) VALUES (?, ?)
+		ON CONFLICT (agent_name) DO UPDATE SET active_conversation = excluded.active_conversation`,
+		agentName, conversationID)
+	return err
+}
+
+func (s *SQLite) ActiveConversations() (map[string]string, error) {
+	return s.agentState(`active_conversation`)
+}
+
+func (s *SQLite) SetModel(agentName, model string) error {
+	_, err := s.db.Exec(`INSERT INTO agent_state (agent_name, model) VALUES (?, ?)
+		ON CONFLICT (agent_name) DO UPDATE SET model = excluded.model`,
+		agentName, model)
+	return err
+}
+
+func (s *SQLite) Models() (map[string]string, error) {
+	return s.agentState(`model`)
+}
+
+// agentState returns agent name → column for rows where column is set.
+// column is one of this file's constants, never user input.
+func (s *SQLite) agentState(column string) (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT agent_name, ` + column + ` FROM agent_state WHERE ` + column + ` != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	result := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanConversation(row scanner) (Conversation, error) {
+	var c Conversation
+	var first, last int64
+	if err := row.Scan(&c.ID, &c.AgentName, &c.Runtime, &c.Path, &first, &last); err != nil {
+		return Conversation{}, err
+	}
+	c.FirstSeen = time.UnixMilli(first)
+	c.LastSeen = time.UnixMilli(last)
+	return c, nil
+}
@@ -0,0 +1,119 @@
+// Package store persists converter state that must outlive a restart:
+// which agent each conversation belongs to, each agent's active
+// conversation, its current model, and annotations added to conversations. Backends register under a DSN
+// scheme; SQLite is built in and is the default for bare file paths.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is a persistence backend. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// PutConversation records a conversation, updating its agent, runtime,
+	// path, and LastSeen if it is already known. FirstSeen is kept from the
+	// first call.
+	PutConversation(c Conversation) error
+	// Conversation looks up one conversation by ID.
+	Conversation(id string) (Conversation, bool, error)
+	// Conversations lists an agent's conversations, most recently seen
+	// first, or every conversation when agentName is empty.
+	Conversations(agentName string) ([]Conversation, error)
+	// PruneConversations deletes conversations last seen before cutoff that
+	// are not an agent's active conversation, and their annotations,
+	// returning how many conversations it removed.
+	PruneConversations(cutoff time.Time) (int, error)
+
+	// SetActiveConversation records the conversation an agent is currently
+	// writing to; an empty ID clears it.
+	SetActiveConversation(agentName, conversationID string) error
+	// ActiveConversations returns agent name → active conversation ID.
+	ActiveConversations() (map[string]string, error)
+
+	// SetModel records the model an agent last replied with; "" clears it.
+	SetModel(agentName, model string) error
+	// Models returns agent name → model.
+	Models() (map[string]string, error)
+
+	// AddAnnotation records an annotation on a conversation.
+	AddAnnotation(a Annotation) error
+	// Annotations lists a conversation's annotations, oldest first.
+	Annotations(conversationID string) ([]Annotation, error)
+
+	Close() error
+}
+
+// Conversation is a conversation the converter has tailed.
+type Conversation struct {
+	ID        string    `json:"conversationId"`
+	AgentName string    `json:"agentName"`
+	Runtime   string    `json:"runtime"`
+	Path      string    `json:"path"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Annotation is a note an external tool added to a conversation's timeline.
+// It is deleted along with its conversation.
+type Annotation struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversationId"`
+	Author         string    `json:"author"`
+	Text           string    `json:"text"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// OpenFunc opens a backend. It receives the DSN with its scheme intact.
+type OpenFunc func(dsn string) (Store, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]OpenFunc)
+)
+
+// Register makes a backend available under a DSN scheme ("postgres" for
+// "postgres://..."). It panics if the scheme is taken, like sql.Register.
+func Register(scheme string, open OpenFunc) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if _, dup := drivers[scheme]; dup {
+		panic("store: Register called twice for scheme " + scheme)
+	}
+	drivers[scheme] = open
+}
+
+// Open opens the backend named by dsn's scheme. A DSN without a scheme is
+// a SQLite file path.
+func Open(dsn string) (Store, error) {
+	if dsn == "" {
+		return nil, errors.New("store: empty DSN")
+	}
+	scheme := "sqlite"
+	if s, _, ok := strings.Cut(dsn, "://"); ok {
+		scheme = s
+	}
+	driversMu.RLock()
+	open, ok := drivers[scheme]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("store: no backend for scheme %q (have: %s)", scheme, strings.Join(schemes(), ", "))
+	}
+	return open(dsn)
+}
+
+func schemes() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
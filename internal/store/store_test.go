@@ -0,0 +1,199 @@
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTemp(t *testing.T) (Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state", "state.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func TestOpenUnknownScheme(t *testing.T) {
+	_, err := Open("postgres://db/converter")
+	if err == nil || !strings.Contains(err.Error(), `no backend for scheme "postgres"`) {
+		t.Fatalf("Open(postgres) error = %v", err)
+	}
+}
+
+func TestConversationsUpsertAndList(t *testing.T) {
+	s, _ := openTemp(t)
+	defer func() { _ = s.Close() }()
+
+	t0 := time.UnixMilli(1_700_000_000_000)
+	must(t, s.PutConversation(Conversation{ID: "claude:a:1", AgentName: "a", Runtime: "claude", Path: "/p/1.jsonl", FirstSeen: t0, LastSeen: t0}))
+	must(t, s.PutConversation(Conversation{ID: "claude:a:2", AgentName: "a", Runtime: "claude", Path: "/p/2.jsonl", FirstSeen: t0, LastSeen: t0.Add(time.Minute)}))
+	must(t, s.PutConversation(Conversation{ID: "claude:b:1", AgentName: "b", Runtime: "claude", Path: "/q/1.jsonl", FirstSeen: t0, LastSeen: t0}))
+	// Seen again later: LastSeen moves, FirstSeen stays.
+	must(t, s.PutConversation(Conversation{ID: "claude:a:1", AgentName: "a", Runtime: "claude", Path: "/p/1.jsonl", FirstSeen: t0.Add(time.Hour), LastSeen: t0.Add(time.Hour)}))
+
+	c, ok, err := s.Conversation("claude:a:1")
+	if err != nil || !ok {
+		t.Fatalf("Conversation = %v, %v", ok, err)
+	}
+	if !c.FirstSeen.Equal(t0) || !c.LastSeen.Equal(t0.Add(time.Hour)) {
+		t.Fatalf("FirstSeen, LastSeen = %v, %v", c.FirstSeen, c.LastSeen)
+	}
+	if _, ok, _ := s.Conversation("missing"); ok {
+		t.Fatal("Conversation(missing) found")
+	}
+
+	list, err := s.Conversations("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "claude:a:1" || list[1].ID != "claude:a:2" {
+		t.Fatalf("Conversations(a) = %+v", list)
+	}
+	if all, _ := s.Conversations(""); len(all) != 3 {
+		t.Fatalf("Conversations(\"\") returned %d, want 3", len(all))
+	}
+}
+
+func TestPruneConversationsKeepsActive(t *testing.T) {
+	s, _ := openTemp(t)
+	defer func() { _ = s.Close() }()
+
+	old := time.Now().Add(-48 * time.Hour)
+	must(t, s.PutConversation(Conversation{ID: "old", AgentName: "a", Runtime: "claude", Path: "/p/old", LastSeen: old}))
+	must(t, s.PutConversation(Conversation{ID: "old-active", AgentName: "b", Runtime: "claude", Path: "/p/b", LastSeen: old}))
+	must(t, s.PutConversation(Conversation{ID: "new", AgentName: "a", Runtime: "claude", Path: "/p/new"}))
+	must(t, s.SetActiveConversation("b", "old-active"))
+
+	n, err := s.PruneConversations(time.Now().Add(-24 * time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("PruneConversations = %d, %v; want 1", n, err)
+	}
+	if _, ok, _ := s.Conversation("old"); ok {
+		t.Fatal("old conversation survived pruning")
+	}
+	if _, ok, _ := s.Conversation("old-active"); !ok {
+		t.Fatal("active conversation was pruned")
+	}
+}
+
+func TestAnnotationsListAndPrune(t *testing.T) {
+	s, _ := openTemp(t)
+	defer func() { _ = s.Close() }()
+
+	old := time.Now().Add(-48 * time.Hour)
+	must(t, s.PutConversation(Conversation{ID: "old", AgentName: "a", Runtime: "claude", Path: "/p/old", LastSeen: old}))
+	must(t, s.PutConversation(Conversation{ID: "new", AgentName: "a", Runtime: "claude", Path: "/p/new"}))
+	must(t, s.AddAnnotation(Annotation{ID: "a2", ConversationID: "new", Author: "ci", Text: "CI result: passed", CreatedAt: old.Add(time.Hour)}))
+	must(t, s.AddAnnotation(Annotation{ID: "a1", ConversationID: "new", Author: "ci", Text: "CI result: failed", CreatedAt: old}))
+	must(t, s.AddAnnotation(Annotation{ID: "a3", ConversationID: "old", Author: "ci", Text: "stale", CreatedAt: old}))
+
+	list, err := s.Annotations("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "a1" || list[1].ID != "a2" || list[0].Author != "ci" {
+		t.Fatalf("Annotations(new) = %+v, want a1 then a2", list)
+	}
+
+	if _, err := s.PruneConversations(time.Now().Add(-24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.Annotations("old"); len(list) != 0 {
+		t.Fatalf("pruned conversation kept %d annotations", len(list))
+	}
+	if list, _ := s.Annotations("new"); len(list) != 2 {
+		t.Fatalf("kept conversation has %d annotations after prune, want 2", len(list))
+	}
+}
+
+func TestAgentStateSurvivesReopen(t *testing.T) {
+	s, path := openTemp(t)
+	must(t, s.SetActiveConversation("a", "claude:a:2"))
+	must(t, s.SetModel("a", "claude-opus-4"))
+	must(t, s.SetModel("b", "claude-sonnet-4"))
+	must(t, s.SetActiveConversation("b", "claude:b:1"))
+	must(t, s.SetActiveConversation("b", ""))
+	must(t, s.Close())
+
+	s, err := Open("sqlite://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	active, err := s.ActiveConversations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"a": "claude:a:2"}; !reflect.DeepEqual(active, want) {
+		t.Fatalf("ActiveConversations = %v, want %v", active, want)
+	}
+	models, err := s.Models()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"a": "claude-opus-4", "b": "claude-sonnet-4"}; !reflect.DeepEqual(models, want) {
+		t.Fatalf("Models = %v, want %v", models, want)
+	}
+}
+
+func TestMigrationDropsAgentFromConversationIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range sqliteMigrations[:2] {
+		_, err := db.Exec(m)
+		must(t, err)
+	}
+	for _, stmt := range []string{
+		`PRAGMA user_version = 2`,
+		`INSERT INTO conversations VALUES ('claude:a:1', 'a', 'claude', '/p/1.jsonl', 1, 1), ('claude:b:1', 'b', 'claude', '/p/1.jsonl', 1, 2), ('claude:2', 'a', 'claude', '/p/2.jsonl', 1, 1)`,
+		`INSERT INTO annotations VALUES ('n1', 'claude:a:1', 'ci', 'hi', 1)`,
+		`INSERT INTO agent_state VALUES ('a', 'claude:a:1', ''), ('c', '', '')`,
+	} {
+		_, err := db.Exec(stmt)
+		must(t, err)
+	}
+	must(t, db.Close())
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	var ids []string
+	for _, agent := range []string{"a", "b"} {
+		list, err := s.Conversations(agent)
+		must(t, err)
+		for _, c := range list {
+			ids = append(ids, c.ID)
+		}
+	}
+	slices.Sort(ids)
+	if want := []string{"claude:1", "claude:2"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("conversation IDs after migration = %v, want %v", ids, want)
+	}
+	if list, err := s.Annotations("claude:1"); err != nil || len(list) != 1 {
+		t.Fatalf("Annotations(claude:1) = %v, %v; want the migrated annotation", list, err)
+	}
+	active, err := s.ActiveConversations()
+	must(t, err)
+	if want := map[string]string{"a": "claude:1"}; !reflect.DeepEqual(active, want) {
+		t.Fatalf("ActiveConversations = %v, want %v", active, want)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,293 @@
+// Package tee mirrors the converter's normalized conversation events to
+// local JSONL files, one per conversation, for offline analysis without a
+// WebSocket consumer. Files rotate at a size cap and only a few rotations
+// are kept, so each conversation's disk use is bounded.
+package tee
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+// Defaults for the rotation flags.
+const (
+	DefaultMaxFileBytes = 64 << 20
+	DefaultMaxFiles     = 4
+)
+
+const (
+	queueSize    = 4096
+	maxOpenFiles = 64
+	tailWindow   = 64 << 10 // first read when looking for a file's last line
+)
+
+// fileNames makes conversation IDs such as "claude:hq-mayor:abc123" safe
+// as file names everywhere.
+var fileNames = strings.NewReplacer("/", "_", `\`, "_", ":", "_")
+
+// Writer appends conversation events to <dir>/<conversation>.jsonl. When a
+// file would pass maxFileBytes it is renamed to .jsonl.1, older rotations
+// shift up, and all but the newest maxFiles rotations are removed.
+type Writer struct {
+	dir          string
+	maxFileBytes int64
+	maxFiles     int
+
+	mu      sync.Mutex
+	stopped bool
+	queue   chan *conv.ConversationEvent
+	done    chan struct{}
+	dropped atomic.Int64
+
+	files map[string]*file // conversation ID → open file; writer goroutine only
+	clock int64            // orders files by last use for closing idle ones
+}
+
+type file struct {
+	f    *os.File
+	path string
+	size int64
+	used int64
+
+	// Set when events were already in the file at open: the watcher
+	// replays a conversation from its start after a restart, and events up
+	// to the one last written are skipped instead of written twice.
+	resuming bool
+	resumeID string
+	resumeAt time.Time
+}
+
+// New creates a writer for dir, or returns nil when dir is empty.
+// maxFileBytes <= 0 never rotates; maxFiles is how many rotated files to
+// keep per conversation.
+func New(dir string, maxFileBytes int64, maxFiles int) (*Writer, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if maxFiles < 0 {
+		return nil, fmt.Errorf("tee: max files %d: want 0 or more", maxFiles)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("tee: %w", err)
+	}
+	return &Writer{
+		dir:          dir,
+		maxFileBytes: maxFileBytes,
+		maxFiles:     maxFiles,
+		queue:        make(chan *conv.ConversationEvent, queueSize),
+		done:         make(chan struct{}),
+		files:        make(map[string]*file),
+	}, nil
+}
+
+// Start begins writing queued events.
+func (w *Writer) Start() {
+	if w == nil {
+		return
+	}
+	log.Printf("tee: writing conversation events under %s", w.dir)
+	go w.run()
+}
+
+// Stop writes the events already queued, then closes every file.
+func (w *Writer) Stop() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.stopped = true
+	close(w.queue)
+	w.mu.Unlock()
+	<-w.done
+}
+
+// Observe queues a watcher event's conversation event for writing. It never
+// blocks: when the disk can't keep up, events are dropped and counted.
+func (w *Writer) Observe(we conv.WatcherEvent) {
+	if w == nil || (we.Type != "conversation-event" && we.Type != "conversation-event-updated") || we.Event == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	select {
+	case w.queue <- we.Event:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	for event := range w.queue {
+		if n := w.dropped.Swap(0); n > 0 {
+			log.Printf("tee: queue full, dropped %d event(s)", n)
+		}
+		if err := w.write(event); err != nil {
+			log.Printf("tee: %s: %v", event.ConversationID, err)
+		}
+	}
+	for id, f := range w.files {
+		_ = f.f.Close()
+		delete(w.files, id)
+	}
+}
+
+func (w *Writer) write(event *conv.ConversationEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f, err := w.open(event.ConversationID)
+	if err != nil {
+		return err
+	}
+	if f.resuming && f.written(event) {
+		return nil
+	}
+	if w.maxFileBytes > 0 && f.size > 0 && f.size+int64(len(line)) > w.maxFileBytes {
+		if err := w.rotate(f); err != nil {
+			delete(w.files, event.ConversationID) // reopen on the next event
+			return err
+		}
+	}
+	n, err := f.f.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+// written reports whether a replayed event was already in the file when it
+// was opened, ending the replay at the first event that wasn't. Events match
+// the last one written by ID, or failing that are new once they are later.
+func (f *file) written(event *conv.ConversationEvent) bool {
+	if id := eventID(event); id != "" && id == f.resumeID {
+		f.resuming = false
+		return true
+	}
+	if !event.Timestamp.After(f.resumeAt) {
+		return true
+	}
+	f.resuming = false
+	return false
+}
+
+// eventID identifies an event across restarts.
+func eventID(event *conv.ConversationEvent) string {
+	if event.StableID != "" {
+		return event.StableID
+	}
+	return event.EventID
+}
+
+func (w *Writer) open(conversationID string) (*file, error) {
+	w.clock++
+	if f, ok := w.files[conversationID]; ok {
+		f.used = w.clock
+		return f, nil
+	}
+	if len(w.files) >= maxOpenFiles {
+		w.closeIdlest()
+	}
+
+	path := filepath.Join(w.dir, fileNames.Replace(conversationID)+".jsonl")
+	fh, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		_ = fh.Close()
+		return nil, err
+	}
+	f := &file{f: fh, path: path, size: info.Size(), used: w.clock}
+	if f.size > 0 {
+		if last, err := lastLine(path, f.size); err == nil {
+			var prev conv.ConversationEvent
+			if json.Unmarshal(last, &prev) == nil {
+				f.resuming, f.resumeID, f.resumeAt = true, eventID(&prev), prev.Timestamp
+			}
+		}
+	}
+	w.files[conversationID] = f
+	return f, nil
+}
+
+// closeIdlest closes the file written longest ago.
+func (w *Writer) closeIdlest() {
+	var idlest string
+	for id, f := range w.files {
+		if idlest == "" || f.used < w.files[idlest].used {
+			idlest = id
+		}
+	}
+	if f := w.files[idlest]; f != nil {
+		_ = f.f.Close()
+		delete(w.files, idlest)
+	}
+}
+
+// rotate shifts path.N to path.N+1, drops those past maxFiles, and starts
+// an empty file.
+func (w *Writer) rotate(f *file) error {
+	_ = f.f.Close()
+	_ = os.Remove(f.path + "." + strconv.Itoa(w.maxFiles))
+	for i := w.maxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(f.path+"."+strconv.Itoa(i), f.path+"."+strconv.Itoa(i+1))
+	}
+	if w.maxFiles > 0 {
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	f.f, f.size = fh, 0
+	return nil
+}
+
+// lastLine returns the last complete line of the size-byte file at path.
+func lastLine(path string, size int64) ([]byte, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fh.Close() }()
+	for window := int64(tailWindow); ; window *= 2 {
+		start := max(size-window, 0)
+		buf := make([]byte, size-start)
+		if _, err := fh.ReadAt(buf, start); err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = bytes.TrimRight(buf, "\n")
+		if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+			return buf[i+1:], nil
+		}
+		if start == 0 {
+			return buf, nil
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package tee
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+var base = time.Date(2026, 2, 14, 1, 0, 0, 0, time.UTC)
+
+func event(convID string, i int) conv.WatcherEvent {
+	return conv.WatcherEvent{Type: "conversation-event", Event: &conv.ConversationEvent{
+		EventID:        "e" + string(rune('a'+i)),
+		Type:           conv.EventAssistant,
+		ConversationID: convID,
+		Timestamp:      base.Add(time.Duration(i) * time.Second),
+	}}
+}
+
+// readIDs returns the event IDs in a JSONL file.
+func readIDs(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	var ids []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var ev conv.ConversationEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		ids = append(ids, ev.EventID)
+	}
+	return ids
+}
+
+func TestWriterWritesPerConversation(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(dir, 0, DefaultMaxFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Start()
+	w.Observe(event("claude:hq-mayor:abc", 0))
+	w.Observe(event("claude:gt-crew:def", 1))
+	w.Observe(event("claude:hq-mayor:abc", 2))
+	w.Observe(conv.WatcherEvent{Type: "agent-added"})
+	w.Stop()
+
+	if got := readIDs(t, filepath.Join(dir, "claude_hq-mayor_abc.jsonl")); len(got) != 2 || got[0] != "ea" || got[1] != "ec" {
+		t.Fatalf("hq-mayor events = %v", got)
+	}
+	if got := readIDs(t, filepath.Join(dir, "claude_gt-crew_def.jsonl")); len(got) != 1 {
+		t.Fatalf("gt-crew events = %v", got)
+	}
+	w.Observe(event("claude:hq-mayor:abc", 3)) // after Stop: ignored, no panic
+}
+
+func TestWriterRotates(t *testing.T) {
+	dir := t.TempDir()
+	line, _ := json.Marshal(event("c", 0).Event)
+	w, err := New(dir, int64(len(line)+1)*2, 2) // two events per file
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Start()
+	for i := range 7 {
+		w.Observe(event("c", i))
+	}
+	w.Stop()
+
+	path := filepath.Join(dir, "c.jsonl")
+	for suffix, want := range map[string]int{"": 1, ".1": 2, ".2": 2} {
+		if got := readIDs(t, path+suffix); len(got) != want {
+			t.Errorf("c.jsonl%s has %d events, want %d", suffix, len(got), want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("rotation past max files kept: %v", err)
+	}
+}
+
+func TestWriterSkipsReplayedEvents(t *testing.T) {
+	dir := t.TempDir()
+	w, _ := New(dir, 0, 0)
+	w.Start()
+	for i := range 3 {
+		w.Observe(event("c", i))
+	}
+	w.Stop()
+
+	// After a restart the watcher replays the conversation from its start.
+	w, _ = New(dir, 0, 0)
+	w.Start()
+	for i := range 5 {
+		w.Observe(event("c", i))
+	}
+	w.Stop()
+
+	got := readIDs(t, filepath.Join(dir, "c.jsonl"))
+	want := []string{"ea", "eb", "ec", "ed", "ee"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+}
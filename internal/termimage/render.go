@@ -0,0 +1,119 @@
+package termimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Cell size in pixels, that of the built-in 7x13 font.
+const (
+	CellWidth  = 7
+	CellHeight = 13
+)
+
+// Default colors, those of a dark terminal theme.
+var (
+	defaultFG = color.RGBA{0xd0, 0xd0, 0xd0, 0xff}
+	defaultBG = color.RGBA{0x1e, 0x1e, 0x1e, 0xff}
+)
+
+// ansi16 is the xterm palette for colors 0–15.
+var ansi16 = [16]color.RGBA{
+	{0x00, 0x00, 0x00, 0xff}, {0xcd, 0x00, 0x00, 0xff}, {0x00, 0xcd, 0x00, 0xff}, {0xcd, 0xcd, 0x00, 0xff},
+	{0x00, 0x00, 0xee, 0xff}, {0xcd, 0x00, 0xcd, 0xff}, {0x00, 0xcd, 0xcd, 0xff}, {0xe5, 0xe5, 0xe5, 0xff},
+	{0x7f, 0x7f, 0x7f, 0xff}, {0xff, 0x00, 0x00, 0xff}, {0x00, 0xff, 0x00, 0xff}, {0xff, 0xff, 0x00, 0xff},
+	{0x5c, 0x5c, 0xff, 0xff}, {0xff, 0x00, 0xff, 0xff}, {0x00, 0xff, 0xff, 0xff}, {0xff, 0xff, 0xff, 0xff},
+}
+
+// paletteColor returns color n of the xterm 256-color palette.
+func paletteColor(n int) *color.RGBA {
+	var c color.RGBA
+	switch {
+	case n < 16:
+		c = ansi16[n]
+	case n < 232:
+		n -= 16
+		level := func(v int) uint8 {
+			if v == 0 {
+				return 0
+			}
+			return uint8(55 + 40*v)
+		}
+		c = color.RGBA{level(n / 36), level(n / 6 % 6), level(n % 6), 0xff}
+	default:
+		v := uint8(8 + 10*(n-232))
+		c = color.RGBA{v, v, v, 0xff}
+	}
+	return &c
+}
+
+// Render draws s, CellWidth × CellHeight pixels to a cell.
+func Render(s *Screen) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, s.Cols*CellWidth, s.Rows*CellHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(defaultBG), image.Point{}, draw.Src)
+	face := basicfont.Face7x13
+	for y, row := range s.Cells {
+		for x, cell := range row {
+			if cell.Rune == 0 {
+				continue // the right half of a wide character, drawn with its left
+			}
+			fg, bg := cellColors(cell.Style)
+			rect := image.Rect(x*CellWidth, y*CellHeight, (x+1)*CellWidth, (y+1)*CellHeight)
+			span := rect
+			if wide(cell.Rune) {
+				span.Max.X += CellWidth
+			}
+			if bg != defaultBG {
+				draw.Draw(img, span, image.NewUniform(bg), image.Point{}, draw.Src)
+			}
+			src := image.NewUniform(fg)
+			if cell.Rune != ' ' && !drawShape(img, rect, cell.Rune, fg, bg) {
+				r := cell.Rune
+				if l, ok := lookalikes[r]; ok {
+					r = l
+				}
+				d := font.Drawer{Dst: img, Src: src, Face: face, Dot: fixed.P(rect.Min.X, rect.Min.Y+face.Ascent)}
+				d.DrawString(string(r))
+				if cell.Style.Bold {
+					d.Dot = fixed.P(rect.Min.X+1, rect.Min.Y+face.Ascent)
+					d.DrawString(string(r))
+				}
+			}
+			if cell.Style.Underline {
+				draw.Draw(img, image.Rect(span.Min.X, span.Max.Y-1, span.Max.X, span.Max.Y), src, image.Point{}, draw.Src)
+			}
+		}
+	}
+	return img
+}
+
+// cellColors resolves a style's foreground and background.
+func cellColors(st Style) (fg, bg color.RGBA) {
+	fg, bg = defaultFG, defaultBG
+	if st.FG != nil {
+		fg = *st.FG
+	}
+	if st.BG != nil {
+		bg = *st.BG
+	}
+	if st.Reverse {
+		fg, bg = bg, fg
+	}
+	if st.Dim {
+		fg = color.RGBA{uint8((int(fg.R) + int(bg.R)) / 2), uint8((int(fg.G) + int(bg.G)) / 2), uint8((int(fg.B) + int(bg.B)) / 2), 0xff}
+	}
+	return fg, bg
+}
+
+// EncodePNG renders text captured with capture-pane -e on a cols × rows
+// screen, as Parse lays it out, and writes it to w as a PNG.
+func EncodePNG(w io.Writer, text string, cols, rows int) error {
+	return png.Encode(w, Render(Parse(text, cols, rows)))
+}
@@ -0,0 +1,57 @@
+package termimage
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	s := Parse("\x1b[41m \x1b[0m█─\n", 0, 2)
+	img := Render(s)
+	if b := img.Bounds(); b.Dx() != 3*CellWidth || b.Dy() != 2*CellHeight {
+		t.Fatalf("bounds = %v, want 3x2 cells", b)
+	}
+	if got := img.RGBAAt(1, 1); got != ansi16[1] {
+		t.Errorf("red cell pixel = %v, want %v", got, ansi16[1])
+	}
+	if got := img.RGBAAt(CellWidth+3, 6); got != defaultFG {
+		t.Errorf("full block pixel = %v, want %v", got, defaultFG)
+	}
+	// The line meets both edges of its cell at the center row.
+	for _, x := range []int{2 * CellWidth, 3*CellWidth - 1} {
+		if got := img.RGBAAt(x, CellHeight/2); got != defaultFG {
+			t.Errorf("line pixel at x=%d = %v, want %v", x, got, defaultFG)
+		}
+	}
+	if got := img.RGBAAt(1, CellHeight+1); got != defaultBG {
+		t.Errorf("blank row pixel = %v, want %v", got, defaultBG)
+	}
+}
+
+func TestEncodePNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodePNG(&buf, "hello \x1b[1mworld\x1b[0m ╭─╮ ⏺\n", 80, 24); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 80*CellWidth || b.Dy() != 24*CellHeight {
+		t.Fatalf("bounds = %v, want 80x24 cells", b)
+	}
+	// Some of "h" is drawn in the foreground color.
+	var inked bool
+	for y := 0; y < CellHeight; y++ {
+		for x := 0; x < CellWidth; x++ {
+			if color.RGBAModel.Convert(img.At(x, y)) == defaultFG {
+				inked = true
+			}
+		}
+	}
+	if !inked {
+		t.Error("first cell has no glyph drawn")
+	}
+}
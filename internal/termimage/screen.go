@@ -0,0 +1,218 @@
+// Package termimage renders captured terminal screens to images, so a
+// pane's current look can be shared as a PNG without a browser terminal.
+package termimage
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Style is how a cell is drawn. A nil color is the terminal default.
+type Style struct {
+	FG, BG    *color.RGBA
+	Bold      bool
+	Dim       bool
+	Underline bool
+	Reverse   bool
+}
+
+// Cell is one character cell. The right half of a wide character holds
+// rune 0.
+type Cell struct {
+	Rune  rune
+	Style Style
+}
+
+// Screen is a grid of cells, Rows × Cols.
+type Screen struct {
+	Cols, Rows int
+	Cells      [][]Cell
+}
+
+// Parse lays out text captured with tmux capture-pane -e: lines of
+// characters with SGR escape sequences for color and attributes. Other
+// escape sequences are skipped. A cols or rows of 0 sizes the screen to the
+// text; otherwise text outside the grid is cut.
+func Parse(text string, cols, rows int) *Screen {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+	if rows <= 0 {
+		rows = max(len(lines), 1)
+	}
+	s := &Screen{Cols: cols, Rows: rows, Cells: make([][]Cell, rows)}
+
+	var style Style
+	for y := 0; y < len(lines) && y < rows; y++ {
+		line := lines[y]
+		var row []Cell
+		for i := 0; i < len(line); {
+			if line[i] == 0x1b {
+				n, params, final := escape(line[i:])
+				if final == 'm' {
+					style = applySGR(style, params)
+				}
+				i += n
+				continue
+			}
+			r, size := utf8.DecodeRuneInString(line[i:])
+			i += size
+			switch {
+			case r == '\t':
+				row = append(row, Cell{Rune: ' ', Style: style})
+				for len(row)%8 != 0 {
+					row = append(row, Cell{Rune: ' ', Style: style})
+				}
+			case r < 0x20 || r == 0x7f:
+			default:
+				row = append(row, Cell{Rune: r, Style: style})
+				if wide(r) {
+					row = append(row, Cell{Style: style})
+				}
+			}
+		}
+		s.Cells[y] = row
+	}
+
+	if s.Cols <= 0 {
+		for _, row := range s.Cells {
+			s.Cols = max(s.Cols, len(row))
+		}
+		s.Cols = max(s.Cols, 1)
+	}
+	for y, row := range s.Cells {
+		if len(row) > s.Cols {
+			row = row[:s.Cols]
+		}
+		for len(row) < s.Cols {
+			row = append(row, Cell{Rune: ' '})
+		}
+		s.Cells[y] = row
+	}
+	return s
+}
+
+// escape measures the escape sequence at the start of b, returning its
+// length, and for a CSI sequence its parameters and final byte.
+func escape(b string) (n int, params string, final byte) {
+	if len(b) < 2 {
+		return len(b), "", 0
+	}
+	switch b[1] {
+	case '[':
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				return i + 1, b[2:i], b[i]
+			}
+		}
+		return len(b), "", 0
+	case ']', 'P', '_':
+		// OSC, DCS, and APC run to BEL or ST.
+		for i := 2; i < len(b); i++ {
+			if b[i] == 0x07 {
+				return i + 1, "", 0
+			}
+			if b[i] == 0x1b && i+1 < len(b) && b[i+1] == '\\' {
+				return i + 2, "", 0
+			}
+		}
+		return len(b), "", 0
+	case '(', ')':
+		return min(3, len(b)), "", 0
+	}
+	return 2, "", 0
+}
+
+// applySGR returns style with the SGR parameters applied.
+func applySGR(style Style, params string) Style {
+	if params == "" {
+		return Style{}
+	}
+	codes := strings.FieldsFunc(params, func(r rune) bool { return r == ';' || r == ':' })
+	num := func(i int) int {
+		if i >= len(codes) {
+			return -1
+		}
+		n, err := strconv.Atoi(codes[i])
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	for i := 0; i < len(codes); i++ {
+		switch n := num(i); {
+		case n == 0:
+			style = Style{}
+		case n == 1:
+			style.Bold = true
+		case n == 2:
+			style.Dim = true
+		case n == 4:
+			style.Underline = true
+		case n == 7:
+			style.Reverse = true
+		case n == 22:
+			style.Bold, style.Dim = false, false
+		case n == 24:
+			style.Underline = false
+		case n == 27:
+			style.Reverse = false
+		case n >= 30 && n <= 37:
+			style.FG = paletteColor(n - 30)
+		case n >= 90 && n <= 97:
+			style.FG = paletteColor(n - 90 + 8)
+		case n >= 40 && n <= 47:
+			style.BG = paletteColor(n - 40)
+		case n >= 100 && n <= 107:
+			style.BG = paletteColor(n - 100 + 8)
+		case n == 39:
+			style.FG = nil
+		case n == 49:
+			style.BG = nil
+		case n == 38, n == 48, n == 58:
+			var c *color.RGBA
+			switch num(i + 1) {
+			case 5:
+				if p := num(i + 2); p >= 0 && p < 256 {
+					c = paletteColor(p)
+				}
+				i += 2
+			case 2:
+				r, g, b := num(i+2), num(i+3), num(i+4)
+				if r >= 0 && g >= 0 && b >= 0 {
+					c = &color.RGBA{uint8(r), uint8(g), uint8(b), 0xff}
+				}
+				i += 4
+			}
+			switch n {
+			case 38:
+				style.FG = c
+			case 48:
+				style.BG = c
+			}
+		}
+	}
+	return style
+}
+
+// wide reports whether r takes two cells, for the ranges of East Asian
+// wide characters and emoji that agents' output is likely to hold.
+func wide(r rune) bool {
+	switch {
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return true
+	}
+	return false
+}
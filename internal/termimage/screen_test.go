@@ -0,0 +1,54 @@
+package termimage
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseStyles(t *testing.T) {
+	s := Parse("a\x1b[1;31mb\x1b[0m\x1b[38;5;21mc\x1b[48;2;1;2;3md\x1b[7me\x1b[m\x1b]8;;http://x\x07f\n", 0, 0)
+	if s.Cols != 6 || s.Rows != 1 {
+		t.Fatalf("size = %dx%d, want 6x1", s.Cols, s.Rows)
+	}
+	row := s.Cells[0]
+	for i, want := range "abcdef" {
+		if row[i].Rune != want {
+			t.Errorf("cell %d = %q, want %q", i, row[i].Rune, want)
+		}
+	}
+	if row[0].Style.FG != nil || row[0].Style.Bold {
+		t.Errorf("a has style %+v, want the default", row[0].Style)
+	}
+	if !row[1].Style.Bold || *row[1].Style.FG != ansi16[1] {
+		t.Errorf("b has style %+v, want bold red", row[1].Style)
+	}
+	if row[2].Style.Bold || *row[2].Style.FG != (color.RGBA{0, 0, 0xff, 0xff}) {
+		t.Errorf("c has style %+v, want palette blue", row[2].Style)
+	}
+	if *row[3].Style.BG != (color.RGBA{1, 2, 3, 0xff}) {
+		t.Errorf("d has background %v, want 1,2,3", row[3].Style.BG)
+	}
+	if !row[4].Style.Reverse || row[5].Style != (Style{}) {
+		t.Errorf("e, f have styles %+v, %+v; want reverse, then reset", row[4].Style, row[5].Style)
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	// Style carries across lines, as capture-pane -e leaves it.
+	s := Parse("\x1b[32mab\ncd\n\tx\n漢z", 4, 5)
+	if s.Cols != 4 || s.Rows != 5 || len(s.Cells) != 5 {
+		t.Fatalf("size = %dx%d, want 4x5", s.Cols, s.Rows)
+	}
+	if s.Cells[1][0].Style.FG == nil {
+		t.Error("style did not carry onto the next line")
+	}
+	if len(s.Cells[2]) != 4 || s.Cells[2][3].Rune != ' ' {
+		t.Errorf("tab row = %v, want blanks cut at 4 columns", s.Cells[2])
+	}
+	if s.Cells[3][0].Rune != '漢' || s.Cells[3][1].Rune != 0 || s.Cells[3][2].Rune != 'z' {
+		t.Errorf("wide row = %v, want 漢 over two cells", s.Cells[3])
+	}
+	if len(s.Cells[4]) != 4 || s.Cells[4][0].Rune != ' ' {
+		t.Errorf("blank row = %v, want 4 blanks", s.Cells[4])
+	}
+}
@@ -0,0 +1,200 @@
+package termimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Line weights of a box-drawing character's arms.
+const (
+	none = iota
+	light
+	heavy
+	double
+)
+
+// arms are the weights of a box-drawing character's lines from the cell's
+// center: up, right, down, left.
+type arms [4]uint8
+
+// boxArms returns the arms of box-drawing character r (U+2500–U+257F).
+// Lines mixing weights draw at one; dashes draw solid.
+func boxArms(r rune) (arms, bool) {
+	switch {
+	case r == 0x2500, r == 0x2504, r == 0x2508, r == 0x254c:
+		return arms{none, light, none, light}, true
+	case r == 0x2501, r == 0x2505, r == 0x2509, r == 0x254d:
+		return arms{none, heavy, none, heavy}, true
+	case r == 0x2502, r == 0x2506, r == 0x250a, r == 0x254e:
+		return arms{light, none, light, none}, true
+	case r == 0x2503, r == 0x2507, r == 0x250b, r == 0x254f:
+		return arms{heavy, none, heavy, none}, true
+	case r >= 0x250c && r <= 0x254b:
+		// Corners, tees, and crosses, each shape in runs ending at its
+		// all-heavy form.
+		for _, g := range boxGroups {
+			if r >= g.first && r <= g.last {
+				w := uint8(light)
+				if r == g.last {
+					w = heavy
+				}
+				return g.shape.weigh(w), true
+			}
+		}
+	case r >= 0x2550 && r <= 0x256c:
+		for _, g := range doubleGroups {
+			if r >= g.first && r <= g.last {
+				return g.shape.weigh(double), true
+			}
+		}
+	case r >= 0x256d && r <= 0x2570:
+		return [...]arms{{none, light, light, none}, {none, none, light, light}, {light, none, none, light}, {light, light, none, none}}[r-0x256d], true
+	case r >= 0x2574 && r <= 0x257b:
+		var a arms
+		w := uint8(light)
+		if r >= 0x2578 {
+			w = heavy
+		}
+		a[(int(r-0x2574)+3)%4] = w // left, up, right, down
+		return a, true
+	case r >= 0x257c && r <= 0x257f:
+		return [...]arms{{none, heavy, none, light}, {light, none, heavy, none}, {none, light, none, heavy}, {heavy, none, light, none}}[r-0x257c], true
+	}
+	return arms{}, false
+}
+
+type boxGroup struct {
+	first, last rune
+	shape       arms // 1 for each arm drawn
+}
+
+func (a arms) weigh(w uint8) arms {
+	for i := range a {
+		a[i] *= w
+	}
+	return a
+}
+
+var boxGroups = []boxGroup{
+	{0x250c, 0x250f, arms{0, 1, 1, 0}}, // ┌
+	{0x2510, 0x2513, arms{0, 0, 1, 1}}, // ┐
+	{0x2514, 0x2517, arms{1, 1, 0, 0}}, // └
+	{0x2518, 0x251b, arms{1, 0, 0, 1}}, // ┘
+	{0x251c, 0x2523, arms{1, 1, 1, 0}}, // ├
+	{0x2524, 0x252b, arms{1, 0, 1, 1}}, // ┤
+	{0x252c, 0x2533, arms{0, 1, 1, 1}}, // ┬
+	{0x2534, 0x253b, arms{1, 1, 0, 1}}, // ┴
+	{0x253c, 0x254b, arms{1, 1, 1, 1}}, // ┼
+}
+
+var doubleGroups = []boxGroup{
+	{0x2550, 0x2550, arms{0, 1, 0, 1}}, // ═
+	{0x2551, 0x2551, arms{1, 0, 1, 0}}, // ║
+	{0x2552, 0x2554, arms{0, 1, 1, 0}}, // ╔
+	{0x2555, 0x2557, arms{0, 0, 1, 1}}, // ╗
+	{0x2558, 0x255a, arms{1, 1, 0, 0}}, // ╚
+	{0x255b, 0x255d, arms{1, 0, 0, 1}}, // ╝
+	{0x255e, 0x2560, arms{1, 1, 1, 0}}, // ╠
+	{0x2561, 0x2563, arms{1, 0, 1, 1}}, // ╣
+	{0x2564, 0x2566, arms{0, 1, 1, 1}}, // ╦
+	{0x2567, 0x2569, arms{1, 1, 0, 1}}, // ╩
+	{0x256a, 0x256c, arms{1, 1, 1, 1}}, // ╬
+}
+
+// lookalikes are ASCII stand-ins for common characters the built-in font
+// lacks.
+var lookalikes = map[rune]rune{
+	'‘': '\'', '’': '\'', '“': '"', '”': '"', '–': '-', '—': '-', '‐': '-',
+	'…': '.', '·': '.', '›': '>', '‹': '<', '→': '>', '←': '<', '↑': '^', '↓': 'v',
+	'✓': 'v', '✔': 'v', '✗': 'x', '✘': 'x', '×': 'x', '❯': '>', '▶': '>', '▸': '>',
+	'✻': '*', '✳': '*', '✶': '*', '✢': '*', '✽': '*', ' ': ' ',
+}
+
+// drawShape draws r into rect if it is a box-drawing, block, or bullet
+// character, which are drawn rather than taken from the font, so they meet
+// their neighbors. It reports whether it drew r.
+func drawShape(img *image.RGBA, rect image.Rectangle, r rune, fg, bg color.RGBA) bool {
+	src := image.NewUniform(fg)
+	fill := func(x0, y0, x1, y1 int) {
+		draw.Draw(img, image.Rect(rect.Min.X+x0, rect.Min.Y+y0, rect.Min.X+x1, rect.Min.Y+y1), src, image.Point{}, draw.Src)
+	}
+	w, h := rect.Dx(), rect.Dy()
+	cx, cy := w/2, h/2
+
+	if a, ok := boxArms(r); ok {
+		// Each weight is the offsets of the one-pixel lines it draws.
+		strokes := [...][]int{light: {0}, heavy: {0, 1}, double: {-1, 1}}
+		for arm, weight := range a {
+			if weight == none {
+				continue
+			}
+			for _, d := range strokes[weight] {
+				switch arm {
+				case 0:
+					fill(cx+d, 0, cx+d+1, cy+1)
+				case 1:
+					fill(cx, cy+d, w, cy+d+1)
+				case 2:
+					fill(cx+d, cy, cx+d+1, h)
+				case 3:
+					fill(0, cy+d, cx+1, cy+d+1)
+				}
+			}
+		}
+		return true
+	}
+
+	switch {
+	case r == 0x2580: // ▀
+		fill(0, 0, w, h/2)
+	case r >= 0x2581 && r <= 0x2588: // ▁ to █
+		fill(0, h-h*int(r-0x2580)/8, w, h)
+	case r >= 0x2589 && r <= 0x258f: // ▉ to ▏
+		fill(0, 0, w*int(0x2590-r)/8, h)
+	case r == 0x2590: // ▐
+		fill(w/2, 0, w, h)
+	case r >= 0x2591 && r <= 0x2593: // ░ ▒ ▓
+		n := int(r - 0x2590)
+		src = image.NewUniform(color.RGBA{
+			uint8((int(fg.R)*n + int(bg.R)*(4-n)) / 4),
+			uint8((int(fg.G)*n + int(bg.G)*(4-n)) / 4),
+			uint8((int(fg.B)*n + int(bg.B)*(4-n)) / 4),
+			0xff,
+		})
+		fill(0, 0, w, h)
+	case r == 0x2594: // ▔
+		fill(0, 0, w, h/8)
+	case r == 0x2595: // ▕
+		fill(w-w/8, 0, w, h)
+	case r >= 0x2596 && r <= 0x259f: // quadrants
+		// Upper left, upper right, lower left, lower right.
+		quads := [...][4]bool{
+			{false, false, true, false}, {false, false, false, true}, {true, false, false, false},
+			{true, false, true, true}, {true, false, false, true}, {true, true, true, false},
+			{true, true, false, true}, {false, true, false, false}, {false, true, true, false},
+			{false, true, true, true},
+		}[r-0x2596]
+		for i, on := range quads {
+			if on {
+				x, y := i%2*cx, i/2*cy
+				fill(x, y, x+cx+i%2*(w-2*cx), y+cy+i/2*(h-2*cy))
+			}
+		}
+	case r == '●' || r == '⏺' || r == '•' || r == '∙':
+		radius := 3
+		if r == '•' || r == '∙' {
+			radius = 2
+		}
+		for y := -radius; y <= radius; y++ {
+			for x := -radius; x <= radius; x++ {
+				if x*x+y*y <= radius*radius {
+					fill(cx+x, cy+y, cx+x+1, cy+y+1)
+				}
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package tmux
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -14,6 +15,7 @@ import (
 type SessionInfo struct {
 	Name     string
 	Attached bool
+	ID       string // tmux's session ID ("$N"), which survives renames; "" if unknown
 }
 
 // PaneInfo holds tmux pane details.
@@ -24,9 +26,18 @@ type PaneInfo struct {
 	WorkDir string
 }
 
-// ListSessions returns all tmux sessions with their attached status.
+// ListSessions returns all tmux sessions with their attached status,
+// including peers' sessions named "user:session".
 func (cm *ControlMode) ListSessions() ([]SessionInfo, error) {
-	out, err := cm.Execute("list-sessions -F '#{session_name}\t#{session_attached}'")
+	sessions, err := cm.listSessions()
+	if err != nil {
+		return nil, err
+	}
+	return append(sessions, cm.peerSessions()...), nil
+}
+
+func (cm *ControlMode) listSessions() ([]SessionInfo, error) {
+	out, err := cm.Execute("list-sessions -F '#{session_name}\t#{session_attached}\t#{session_id}'")
 	if err != nil {
 		return nil, err
 	}
@@ -36,14 +47,18 @@ func (cm *ControlMode) ListSessions() ([]SessionInfo, error) {
 		if line == "" {
 			continue
 		}
-		parts := strings.SplitN(line, "\t", 2)
+		parts := strings.SplitN(line, "\t", 3)
 		if len(parts) < 2 {
 			continue
 		}
-		sessions = append(sessions, SessionInfo{
+		info := SessionInfo{
 			Name:     parts[0],
 			Attached: parts[1] != "0",
-		})
+		}
+		if len(parts) == 3 {
+			info.ID = parts[2]
+		}
+		sessions = append(sessions, info)
 	}
 	return sessions, nil
 }
@@ -51,9 +66,10 @@ func (cm *ControlMode) ListSessions() ([]SessionInfo, error) {
 // ShowEnvironment reads a session environment variable.
 // Returns empty string if the variable is not set.
 func (cm *ControlMode) ShowEnvironment(session, key string) (string, error) {
+	cm, session = cm.resolve(session)
 	out, err := cm.Execute(fmt.Sprintf("show-environment -t '%s' %s", session, key))
 	if err != nil {
-		if strings.Contains(err.Error(), "unknown variable") {
+		if errors.Is(err, ErrUnknownVariable) {
 			return "", nil
 		}
 		return "", err
@@ -69,6 +85,7 @@ func (cm *ControlMode) ShowEnvironment(session, key string) (string, error) {
 
 // GetPaneInfo returns pane details for the first pane in a session.
 func (cm *ControlMode) GetPaneInfo(session string) (PaneInfo, error) {
+	cm, session = cm.resolve(session)
 	out, err := cm.Execute(fmt.Sprintf("list-panes -t '%s' -F '#{pane_id}\t#{pane_current_command}\t#{pane_pid}\t#{pane_current_path}'", session))
 	if err != nil {
 		return PaneInfo{}, err
@@ -89,32 +106,101 @@ func (cm *ControlMode) GetPaneInfo(session string) (PaneInfo, error) {
 	}, nil
 }
 
+// PaneLayout describes one pane's place in its window, in character cells.
+type PaneLayout struct {
+	PaneID  string `json:"paneId"`
+	Index   int    `json:"index"`
+	Left    int    `json:"left"`
+	Top     int    `json:"top"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Active  bool   `json:"active"`
+	Command string `json:"command"`
+}
+
+// WindowLayout describes the panes of a session's current window.
+type WindowLayout struct {
+	Width  int          `json:"width"`
+	Height int          `json:"height"`
+	Panes  []PaneLayout `json:"panes"`
+}
+
+// GetWindowLayout returns the geometry of every pane in the session's current window.
+func (cm *ControlMode) GetWindowLayout(session string) (WindowLayout, error) {
+	if peer, name, rest := cm.resolvePeer(session); peer != nil {
+		layout, err := peer.GetWindowLayout(rest)
+		for i := range layout.Panes {
+			layout.Panes[i].PaneID = name + PeerSeparator + layout.Panes[i].PaneID
+		}
+		return layout, err
+	}
+	out, err := cm.Execute(fmt.Sprintf("list-panes -t '%s' -F '#{window_width}\t#{window_height}\t#{pane_id}\t#{pane_index}\t#{pane_left}\t#{pane_top}\t#{pane_width}\t#{pane_height}\t#{pane_active}\t#{pane_current_command}'", session))
+	if err != nil {
+		return WindowLayout{}, err
+	}
+	return parseWindowLayout(out)
+}
+
+func parseWindowLayout(out string) (WindowLayout, error) {
+	var layout WindowLayout
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "\t", 10)
+		if len(parts) < 10 {
+			return WindowLayout{}, fmt.Errorf("unexpected pane layout format: %q", line)
+		}
+		var nums [7]int
+		for i, field := range []int{0, 1, 3, 4, 5, 6, 7} {
+			n, err := strconv.Atoi(parts[field])
+			if err != nil {
+				return WindowLayout{}, fmt.Errorf("unexpected pane layout format: %q", line)
+			}
+			nums[i] = n
+		}
+		layout.Width, layout.Height = nums[0], nums[1]
+		layout.Panes = append(layout.Panes, PaneLayout{
+			PaneID:  parts[2],
+			Index:   nums[2],
+			Left:    nums[3],
+			Top:     nums[4],
+			Width:   nums[5],
+			Height:  nums[6],
+			Active:  parts[8] == "1",
+			Command: parts[9],
+		})
+	}
+	if len(layout.Panes) == 0 {
+		return WindowLayout{}, fmt.Errorf("no panes in window")
+	}
+	return layout, nil
+}
+
 // SendKeysLiteral sends text in literal mode (no key name interpretation).
 func (cm *ControlMode) SendKeysLiteral(target, text string) error {
+	cm, target = cm.resolve(target)
 	_, err := cm.Execute(fmt.Sprintf("send-keys -t '%s' -l %s", target, shellQuote(text)))
 	return err
 }
 
 // SendKeysBytes sends raw bytes exactly as keyboard input.
-// Uses send-keys -H to avoid command parsing issues with control bytes.
+// Uses send-keys -H to avoid command parsing issues with control bytes,
+// or literal mode on tmux releases without -H.
 func (cm *ControlMode) SendKeysBytes(target string, data []byte) error {
+	cm, target = cm.resolve(target)
 	if len(data) == 0 {
 		return nil
 	}
-
-	// Older tmux versions may not support -H; fall back to literal mode.
-	if err := cm.sendKeysHex(target, data); err != nil {
-		if strings.Contains(err.Error(), "unknown flag -H") {
-			return cm.SendKeysLiteral(target, string(data))
-		}
-		return err
+	if !cm.Capabilities().SendKeysHex {
+		return cm.SendKeysLiteral(target, string(data))
 	}
-
-	return nil
+	return cm.sendKeysHex(target, data)
 }
 
 // SendKeysRaw sends key names without literal mode.
 func (cm *ControlMode) SendKeysRaw(target string, keys ...string) error {
+	cm, target = cm.resolve(target)
 	var b strings.Builder
 	fmt.Fprintf(&b, "send-keys -t '%s'", target)
 	for _, key := range keys {
@@ -129,6 +215,7 @@ func (cm *ControlMode) SendKeysRaw(target string, keys ...string) error {
 // Uses a uniquely named buffer to avoid races when multiple control-mode
 // connections share the same tmux server.
 func (cm *ControlMode) PasteBytes(target string, data []byte) error {
+	cm, target = cm.resolve(target)
 	if len(data) == 0 {
 		return nil
 	}
@@ -152,6 +239,9 @@ func (cm *ControlMode) PasteBytes(target string, data []byte) error {
 	if err := f.Close(); err != nil {
 		return fmt.Errorf("close temp buffer file: %w", err)
 	}
+	if err := cm.giveToOwner(f.Name()); err != nil {
+		return err
+	}
 
 	// Use a unique buffer name so concurrent control-mode connections
 	// (e.g. adapter + converter) don't clobber each other's paste buffers.
@@ -163,11 +253,14 @@ func (cm *ControlMode) PasteBytes(target string, data []byte) error {
 	return cm.pasteBufferNamed(target, bufName)
 }
 
+// loadBufferNamed loads path into buffer bufName, also setting the terminal
+// clipboard where tmux supports load-buffer -w.
 func (cm *ControlMode) loadBufferNamed(path, bufName string) error {
-	_, err := cm.Execute(fmt.Sprintf("load-buffer -w -b %s %s", bufName, shellQuote(path)))
-	if err != nil && strings.Contains(err.Error(), "unknown flag") {
-		_, err = cm.Execute(fmt.Sprintf("load-buffer -b %s %s", bufName, shellQuote(path)))
+	flags := "-b"
+	if cm.Capabilities().LoadBufferClipboard {
+		flags = "-w -b"
 	}
+	_, err := cm.Execute(fmt.Sprintf("load-buffer %s %s %s", flags, bufName, shellQuote(path)))
 	return err
 }
 
@@ -201,25 +294,46 @@ func (cm *ControlMode) sendKeysHex(target string, data []byte) error {
 
 // CapturePaneAll captures the entire scrollback history of a session with ANSI escape codes.
 func (cm *ControlMode) CapturePaneAll(session string) (string, error) {
+	cm, session = cm.resolve(session)
 	return cm.Execute(fmt.Sprintf("capture-pane -p -e -t '%s' -S -", session))
 }
 
 // CapturePaneVisible captures only the currently visible terminal screen.
 // The -a flag prefers the alternate screen buffer when present (full-screen TUIs).
 func (cm *ControlMode) CapturePaneVisible(session string) (string, error) {
+	cm, session = cm.resolve(session)
 	out, err := cm.Execute(fmt.Sprintf("capture-pane -p -e -a -t '%s'", session))
-	if err != nil && strings.Contains(err.Error(), "no alternate screen") {
+	if noAlternateScreen(err) {
 		return cm.Execute(fmt.Sprintf("capture-pane -p -e -t '%s'", session))
 	}
 	return out, err
 }
 
+// CapturePaneVisibleText captures the visible screen as plain text, without
+// escape sequences, and with wrapped lines joined.
+func (cm *ControlMode) CapturePaneVisibleText(session string) (string, error) {
+	cm, session = cm.resolve(session)
+	out, err := cm.Execute(fmt.Sprintf("capture-pane -p -J -a -t '%s'", session))
+	if noAlternateScreen(err) {
+		return cm.Execute(fmt.Sprintf("capture-pane -p -J -t '%s'", session))
+	}
+	return out, err
+}
+
+// noAlternateScreen reports whether a capture-pane -a failed because the pane
+// isn't showing its alternate screen, or because this tmux lacks -a; either
+// way the normal screen is the visible one.
+func noAlternateScreen(err error) bool {
+	return errors.Is(err, ErrNoAlternateScreen) || errors.Is(err, ErrUnsupportedFlag)
+}
+
 // CapturePaneHistory captures only the scrollback history (above the visible area).
 // Returns empty string if there is no scrollback.
 func (cm *ControlMode) CapturePaneHistory(session string) (string, error) {
+	cm, session = cm.resolve(session)
 	out, err := cm.Execute(fmt.Sprintf("capture-pane -p -e -t '%s' -S - -E -1", session))
 	if err != nil {
-		if strings.Contains(err.Error(), "nothing to capture") {
+		if errors.Is(err, ErrNothingToCapture) {
 			return "", nil
 		}
 		return "", err
@@ -230,8 +344,14 @@ func (cm *ControlMode) CapturePaneHistory(session string) (string, error) {
 // ForceRedraw triggers a SIGWINCH by briefly changing the window size.
 // Uses resize-window (not resize-pane) because single-pane windows
 // constrain the pane to the window size, making resize-pane a no-op.
+// On tmux releases without resize-window it sends SIGWINCH instead.
 func (cm *ControlMode) ForceRedraw(session string) {
+	cm, session = cm.resolve(session)
 	log.Printf("ForceRedraw(%s): starting", session)
+	if !cm.Capabilities().ResizeWindow {
+		cm.forceRedrawViaSIGWINCH(session)
+		return
+	}
 
 	sizeStr, err := cm.DisplayMessage(session, "#{window_width}:#{window_height}")
 	if err != nil {
@@ -292,12 +412,14 @@ func (cm *ControlMode) forceRedrawViaSIGWINCH(session string) {
 
 // ResizePane adjusts the pane height by delta (e.g., "-1" or "+1").
 func (cm *ControlMode) ResizePane(target, delta string) error {
+	cm, target = cm.resolve(target)
 	_, err := cm.Execute(fmt.Sprintf("resize-pane -t '%s' -y %s", target, delta))
 	return err
 }
 
 // DisplayMessage queries a session variable using display-message.
 func (cm *ControlMode) DisplayMessage(session, format string) (string, error) {
+	cm, session = cm.resolve(session)
 	out, err := cm.Execute(fmt.Sprintf("display-message -t '%s' -p '%s'", session, format))
 	if err != nil {
 		return "", err
@@ -307,12 +429,14 @@ func (cm *ControlMode) DisplayMessage(session, format string) (string, error) {
 
 // PipePaneStart activates pipe-pane for output-only streaming to a command.
 func (cm *ControlMode) PipePaneStart(session, command string) error {
+	cm, session = cm.resolve(session)
 	_, err := cm.Execute(fmt.Sprintf("pipe-pane -o -t '%s' '%s'", session, command))
 	return err
 }
 
 // PipePaneStop deactivates pipe-pane for a session.
 func (cm *ControlMode) PipePaneStop(session string) error {
+	cm, session = cm.resolve(session)
 	_, err := cm.Execute(fmt.Sprintf("pipe-pane -t '%s'", session))
 	return err
 }
@@ -323,32 +447,55 @@ func (cm *ControlMode) ResizePaneTo(target string, cols, rows int) error {
 	return cm.ResizeWindow(target, cols, rows)
 }
 
-// ResizeWindow sets a session's window to an exact size.
+// ResizeWindow sets a session's window to an exact size. It fails on tmux
+// releases without resize-window.
 func (cm *ControlMode) ResizeWindow(target string, cols, rows int) error {
+	cm, target = cm.resolve(target)
+	if caps := cm.Capabilities(); !caps.ResizeWindow {
+		return fmt.Errorf("resize-window needs tmux 2.9 or later, have %s", caps.Version)
+	}
 	_, err := cm.Execute(fmt.Sprintf("resize-window -t '%s' -x %d -y %d", target, cols, rows))
 	return err
 }
 
 // KillSession destroys a tmux session.
 func (cm *ControlMode) KillSession(session string) error {
+	cm, session = cm.resolve(session)
 	_, err := cm.Execute(fmt.Sprintf("kill-session -t '%s'", session))
 	return err
 }
 
-// HasSession checks if a session exists using exact matching.
+// HasSession checks if a session exists using exact matching. A failure tmux
+// words in a way ErrSessionNotFound doesn't recognize is settled by listing
+// sessions instead.
 func (cm *ControlMode) HasSession(session string) (bool, error) {
+	cm, session = cm.resolve(session)
 	_, err := cm.Execute(fmt.Sprintf("has-session -t '=%s'", session))
-	if err != nil {
-		if strings.Contains(err.Error(), "can't find session") {
-			return false, nil
-		}
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, ErrSessionNotFound) {
+		return false, nil
+	}
+	var ce *CommandError
+	if !errors.As(err, &ce) {
+		return false, err
+	}
+	sessions, lerr := cm.listSessions()
+	if lerr != nil {
 		return false, err
 	}
-	return true, nil
+	for _, s := range sessions {
+		if s.Name == session {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // IsSessionAttached checks if a human is attached to the session.
 func (cm *ControlMode) IsSessionAttached(session string) (bool, error) {
+	cm, session = cm.resolve(session)
 	out, err := cm.DisplayMessage(session, "#{session_attached}")
 	if err != nil {
 		return false, err
@@ -1,6 +1,7 @@
 package tmux
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -41,7 +42,7 @@ func TestCapturePaneVisibleFallsBackWhenNoAlternateScreen(t *testing.T) {
 
 			go func(command string) {
 				if strings.Contains(command, "capture-pane -p -e -a ") {
-					cm.responseCh <- commandResponse{err: fmt.Errorf("tmux: no alternate screen")}
+					cm.responseCh <- commandResponse{err: newCommandError("no alternate screen")}
 					return
 				}
 				cm.responseCh <- commandResponse{output: "visible-screen"}
@@ -85,7 +86,7 @@ func TestHasSession_Exists(t *testing.T) {
 
 func TestHasSession_NotFound(t *testing.T) {
 	cm := newStubCM(func(cmd string) commandResponse {
-		return commandResponse{err: fmt.Errorf("tmux: can't find session: my-session")}
+		return commandResponse{err: newCommandError("can't find session: my-session")}
 	})
 
 	exists, err := cm.HasSession("my-session")
@@ -99,7 +100,7 @@ func TestHasSession_NotFound(t *testing.T) {
 
 func TestHasSession_PropagatesOtherErrors(t *testing.T) {
 	cm := newStubCM(func(cmd string) commandResponse {
-		return commandResponse{err: fmt.Errorf("tmux: server exited unexpectedly")}
+		return commandResponse{err: newCommandError("server exited unexpectedly")}
 	})
 
 	exists, err := cm.HasSession("my-session")
@@ -130,7 +131,7 @@ func TestShowEnvironment_Found(t *testing.T) {
 
 func TestShowEnvironment_UnknownVariable(t *testing.T) {
 	cm := newStubCM(func(cmd string) commandResponse {
-		return commandResponse{err: fmt.Errorf("tmux: unknown variable: MY_VAR")}
+		return commandResponse{err: newCommandError("unknown variable: MY_VAR")}
 	})
 
 	val, err := cm.ShowEnvironment("my-session", "MY_VAR")
@@ -144,7 +145,7 @@ func TestShowEnvironment_UnknownVariable(t *testing.T) {
 
 func TestShowEnvironment_PropagatesOtherErrors(t *testing.T) {
 	cm := newStubCM(func(cmd string) commandResponse {
-		return commandResponse{err: fmt.Errorf("tmux: can't find session: my-session")}
+		return commandResponse{err: newCommandError("can't find session: my-session")}
 	})
 
 	val, err := cm.ShowEnvironment("my-session", "MY_VAR")
@@ -175,7 +176,7 @@ func TestCapturePaneHistory_HasHistory(t *testing.T) {
 
 func TestCapturePaneHistory_NothingToCapture(t *testing.T) {
 	cm := newStubCM(func(cmd string) commandResponse {
-		return commandResponse{err: fmt.Errorf("tmux: nothing to capture")}
+		return commandResponse{err: newCommandError("nothing to capture")}
 	})
 
 	out, err := cm.CapturePaneHistory("my-session")
@@ -189,7 +190,7 @@ func TestCapturePaneHistory_NothingToCapture(t *testing.T) {
 
 func TestCapturePaneHistory_PropagatesOtherErrors(t *testing.T) {
 	cm := newStubCM(func(cmd string) commandResponse {
-		return commandResponse{err: fmt.Errorf("tmux: can't find pane: my-session")}
+		return commandResponse{err: newCommandError("can't find pane: my-session")}
 	})
 
 	out, err := cm.CapturePaneHistory("my-session")
@@ -203,3 +204,80 @@ func TestCapturePaneHistory_PropagatesOtherErrors(t *testing.T) {
 		t.Fatalf("CapturePaneHistory() = %q, want empty on error", out)
 	}
 }
+
+func TestGetWindowLayout(t *testing.T) {
+	cm := newStubCM(func(cmd string) commandResponse {
+		if !strings.HasPrefix(cmd, "list-panes -t 'hq-mayor'") {
+			return commandResponse{err: fmt.Errorf("unexpected command %q", cmd)}
+		}
+		return commandResponse{output: "200\t50\t%1\t0\t0\t0\t120\t50\t1\tclaude\n200\t50\t%4\t1\t121\t0\t79\t50\t0\tgo test -run Watch\n"}
+	})
+
+	layout, err := cm.GetWindowLayout("hq-mayor")
+	if err != nil {
+		t.Fatalf("GetWindowLayout() error = %v", err)
+	}
+	if layout.Width != 200 || layout.Height != 50 || len(layout.Panes) != 2 {
+		t.Fatalf("layout = %+v, want 200x50 with 2 panes", layout)
+	}
+	want := PaneLayout{PaneID: "%4", Index: 1, Left: 121, Width: 79, Height: 50, Command: "go test -run Watch"}
+	if layout.Panes[1] != want {
+		t.Fatalf("pane 1 = %+v, want %+v", layout.Panes[1], want)
+	}
+	if !layout.Panes[0].Active || layout.Panes[1].Active {
+		t.Fatalf("active flags = %v/%v, want true/false", layout.Panes[0].Active, layout.Panes[1].Active)
+	}
+}
+
+func TestHasSession_UnrecognizedErrorListsSessions(t *testing.T) {
+	cm := newStubCM(func(cmd string) commandResponse {
+		if strings.HasPrefix(cmd, "list-sessions") {
+			return commandResponse{output: "other\t0\t$1"}
+		}
+		return commandResponse{err: newCommandError("Sitzung nicht gefunden: my-session")}
+	})
+
+	exists, err := cm.HasSession("my-session")
+	if err != nil || exists {
+		t.Fatalf("HasSession() = %v, %v; want false, nil from the session list", exists, err)
+	}
+}
+
+func TestCapturePaneVisibleFallsBackWithoutAlternateFlag(t *testing.T) {
+	cm := newStubCM(func(cmd string) commandResponse {
+		if strings.Contains(cmd, " -a ") {
+			return commandResponse{err: newCommandError("command capture-pane: unknown flag -a")}
+		}
+		return commandResponse{output: "visible-screen"}
+	})
+
+	out, err := cm.CapturePaneVisibleText("hq-mayor")
+	if err != nil || out != "visible-screen" {
+		t.Fatalf("CapturePaneVisibleText() = %q, %v; want the normal screen", out, err)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	cm := newStubCM(func(cmd string) commandResponse {
+		return commandResponse{err: newCommandError("can't find session: hq-mayor")}
+	})
+	_, err := cm.Execute("kill-session -t 'hq-mayor'")
+	wrapped := fmt.Errorf("send literal: %w", err)
+
+	if !errors.Is(wrapped, ErrSessionNotFound) {
+		t.Errorf("errors.Is(%v, ErrSessionNotFound) = false", wrapped)
+	}
+	if code := ErrorCode(wrapped); code != "session-not-found" {
+		t.Errorf("ErrorCode = %q, want session-not-found", code)
+	}
+	var ce *CommandError
+	if !errors.As(err, &ce) || ce.Command != "kill-session -t 'hq-mayor'" {
+		t.Errorf("CommandError = %+v, want the command recorded", ce)
+	}
+	if err.Error() != "tmux: can't find session: hq-mayor" {
+		t.Errorf("Error() = %q", err)
+	}
+	if code := ErrorCode(newCommandError("server exited unexpectedly")); code != "" {
+		t.Errorf("ErrorCode for an unclassified failure = %q, want empty", code)
+	}
+}
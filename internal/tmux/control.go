@@ -36,32 +36,76 @@ type ControlMode struct {
 	responseCh     chan commandResponse // single channel for current pending command
 	execMu         sync.Mutex           // serializes Execute() calls
 	done           chan struct{}
+	exited         chan struct{} // closed when the tmux client exits
 	closing        atomic.Bool
 	session        string
 	executeTimeout time.Duration
+
+	// socket is the -S path of the server to talk to; "" for the default.
+	// owner is the uid running that server, or -1 if it is our own.
+	socket string
+	owner  int
+
+	// events fans structural notifications out to SubscribeEvents.
+	events eventHub
+
+	// caps is what the tmux binary supports, probed at startup; nil means
+	// unprobed (see Capabilities).
+	caps *Capabilities
+
+	// peers are other users' servers reached through this connection,
+	// keyed by user (see AddPeer).
+	peersMu sync.RWMutex
+	peers   map[string]*ControlMode
+
+	// rec records the connection's traffic (see RecordControlMode); nil
+	// records nothing.
+	rec *recorder
 }
 
 // NewControlMode creates and starts a tmux control mode connection.
 // It creates a session with the given name if needed, then attaches in control mode.
 func NewControlMode(sessionName string) (*ControlMode, error) {
+	return newControlMode("", -1, sessionName, nil)
+}
+
+// NewServerControlMode connects to the tmux server listening on socket,
+// run by uid owner, the way NewControlMode connects to the default server.
+func NewServerControlMode(socket string, owner int, sessionName string) (*ControlMode, error) {
+	return newControlMode(socket, owner, sessionName, nil)
+}
 
+func newControlMode(socket string, owner int, sessionName string, rec *recorder) (*ControlMode, error) {
 	// Create monitor session if it doesn't exist
-	create := exec.Command("tmux", "-u", "new-session", "-d", "-s", sessionName)
+	create := tmuxCommand(socket, "new-session", "-d", "-s", sessionName)
 	if err := create.Run(); err != nil {
 		// Session may already exist; this is non-fatal.
 		log.Printf("tmux monitor session create (%s): %v", sessionName, err)
 	}
+	caps, err := probeCapabilities(socket)
+	if err != nil {
+		log.Printf("tmux version probe: %v; assuming a current release", err)
+	} else {
+		log.Printf("tmux %s: send-keys -H=%t load-buffer -w=%t resize-window=%t",
+			caps.Version, caps.SendKeysHex, caps.LoadBufferClipboard, caps.ResizeWindow)
+	}
 
 	cm := &ControlMode{
 		notifications:  make(chan Notification, 100),
 		responseCh:     make(chan commandResponse, 1),
 		done:           make(chan struct{}),
+		exited:         make(chan struct{}),
 		session:        sessionName,
 		executeTimeout: defaultExecuteTimeout,
+		socket:         socket,
+		owner:          owner,
+		caps:           &caps,
+		peers:          make(map[string]*ControlMode),
+		rec:            rec,
 	}
+	rec.record(TraceEntry{Kind: "start", Session: sessionName, Version: caps.Version})
 
-	cm.cmd = exec.Command("tmux", "-u", "-C", "attach", "-t", sessionName)
-	var err error
+	cm.cmd = tmuxCommand(socket, "-C", "attach", "-t", sessionName)
 	cm.stdin, err = cm.cmd.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("stdin pipe: %w", err)
@@ -76,7 +120,11 @@ func NewControlMode(sessionName string) (*ControlMode, error) {
 		return nil, fmt.Errorf("start tmux control mode: %w", err)
 	}
 
-	go cm.readLoop(stdout)
+	go func() {
+		cm.readLoop(stdout)
+		cm.closeEvents()
+		close(cm.exited)
+	}()
 
 	// Wait for the initial attach response (command 0) to be consumed by readLoop
 	// before accepting any Execute() calls. The readLoop handles this by dropping
@@ -102,6 +150,7 @@ func (cm *ControlMode) Execute(command string) (string, error) {
 	}
 
 	// Write command to stdin
+	cm.rec.record(TraceEntry{Kind: "send", Line: command})
 	_, err := fmt.Fprintf(cm.stdin, "%s\n", command)
 	if err != nil {
 		return "", fmt.Errorf("write command: %w", err)
@@ -110,6 +159,9 @@ func (cm *ControlMode) Execute(command string) (string, error) {
 	// Wait for response
 	select {
 	case resp := <-cm.responseCh:
+		if ce, ok := resp.err.(*CommandError); ok {
+			ce.Command = command
+		}
 		return resp.output, resp.err
 	case <-time.After(cm.executeTimeout):
 		return "", fmt.Errorf("tmux command timed out after %s: %s", cm.executeTimeout, command)
@@ -123,23 +175,53 @@ func (cm *ControlMode) Notifications() <-chan Notification {
 	return cm.notifications
 }
 
-// Close shuts down the control mode connection and kills the monitor session.
+// Session returns the monitor session the connection is attached to.
+func (cm *ControlMode) Session() string {
+	return cm.session
+}
+
+// Close shuts down the control mode connection and kills the monitor session,
+// along with those of any peers.
 func (cm *ControlMode) Close() {
+	cm.peersMu.Lock()
+	peers := cm.peers
+	cm.peers = make(map[string]*ControlMode)
+	cm.peersMu.Unlock()
+	for _, peer := range peers {
+		peer.Close()
+	}
+
 	cm.closing.Store(true)
 	if err := cm.stdin.Close(); err != nil {
 		log.Printf("tmux control stdin close: %v", err)
 	}
+	if cm.cmd == nil {
+		// A replay (see ReplayControlMode): no tmux to wait for or clean up.
+		close(cm.done)
+		return
+	}
 	if err := cm.cmd.Wait(); err != nil {
 		log.Printf("tmux control wait: %v", err)
 	}
 	close(cm.done)
+	cm.rec.close()
 
 	// Kill the monitor session
-	if err := exec.Command("tmux", "-u", "kill-session", "-t", cm.session).Run(); err != nil {
+	if err := tmuxCommand(cm.socket, "kill-session", "-t", cm.session).Run(); err != nil {
 		log.Printf("tmux monitor session kill (%s): %v", cm.session, err)
 	}
 }
 
+// tmuxCommand builds a tmux invocation against socket, or the default
+// server if socket is "".
+func tmuxCommand(socket string, args ...string) *exec.Cmd {
+	base := []string{"-u"}
+	if socket != "" {
+		base = append(base, "-S", socket)
+	}
+	return exec.Command("tmux", append(base, args...)...)
+}
+
 // readLoop reads stdout from the tmux control mode process and dispatches
 // responses and notifications.
 //
@@ -164,6 +246,10 @@ func (cm *ControlMode) readLoop(stdout io.Reader) {
 
 	for scanner.Scan() {
 		line := scanner.Text()
+		cm.rec.record(TraceEntry{Kind: "recv", Line: line})
+		if !inResponse {
+			cm.publishEvent(line)
+		}
 
 		switch {
 		case strings.HasPrefix(line, "%begin "):
@@ -204,7 +290,7 @@ func (cm *ControlMode) readLoop(stdout io.Reader) {
 							if errMsg == "" {
 								errMsg = "command failed"
 							}
-							cm.responseCh <- commandResponse{err: fmt.Errorf("tmux: %s", strings.TrimSpace(errMsg))}
+							cm.responseCh <- commandResponse{err: newCommandError(strings.TrimSpace(errMsg))}
 						}
 					}
 				}
@@ -231,11 +317,12 @@ func (cm *ControlMode) readLoop(stdout io.Reader) {
 		case strings.HasPrefix(line, "%window-renamed"):
 			cm.notifications <- Notification{Type: "window-renamed", Args: strings.TrimPrefix(line, "%window-renamed ")}
 
-		case strings.HasPrefix(line, "%window-"):
-			// Ignore other window events (add, close, pane-changed)
+		case strings.HasPrefix(line, "%session-renamed"):
+			cm.notifications <- Notification{Type: "session-renamed", Args: strings.TrimPrefix(line, "%session-renamed ")}
 
-		case strings.HasPrefix(line, "%layout-change"):
-			// Ignore layout changes
+		case strings.HasPrefix(line, "%window-"), strings.HasPrefix(line, "%unlinked-window-"),
+			strings.HasPrefix(line, "%layout-change"), strings.HasPrefix(line, "%session-window-changed"):
+			// Structural events the registry doesn't need; only published
 
 		case strings.HasPrefix(line, "%exit"):
 			// Control mode is exiting
@@ -0,0 +1,80 @@
+package tmux
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kinds of tmux command failure. A *CommandError wraps the one its message
+// maps to, so callers test with errors.Is rather than matching tmux's text.
+var (
+	ErrSessionNotFound   = errors.New("session not found")
+	ErrUnsupportedFlag   = errors.New("flag not supported by this tmux")
+	ErrNoAlternateScreen = errors.New("pane has no alternate screen")
+	ErrUnknownVariable   = errors.New("environment variable not set")
+	ErrNothingToCapture  = errors.New("nothing to capture")
+)
+
+// errorKinds maps fragments of tmux's %error messages to the errors above.
+// It is the only place tmux's wording is matched; a fragment missing here
+// (from an older or patched tmux) leaves the error unclassified.
+var errorKinds = []struct {
+	fragment string
+	kind     error
+}{
+	{"can't find session", ErrSessionNotFound},
+	{"no such session", ErrSessionNotFound},
+	{"session not found", ErrSessionNotFound},
+	{"unknown flag", ErrUnsupportedFlag},
+	{"unknown option", ErrUnsupportedFlag},
+	{"invalid option", ErrUnsupportedFlag},
+	{"no alternate screen", ErrNoAlternateScreen},
+	{"unknown variable", ErrUnknownVariable},
+	{"nothing to capture", ErrNothingToCapture},
+}
+
+// errorCodes are the machine-readable names protocol responses carry for
+// each kind.
+var errorCodes = map[error]string{
+	ErrSessionNotFound:   "session-not-found",
+	ErrUnsupportedFlag:   "unsupported-flag",
+	ErrNoAlternateScreen: "no-alternate-screen",
+	ErrUnknownVariable:   "unknown-variable",
+	ErrNothingToCapture:  "nothing-to-capture",
+}
+
+// CommandError is a command tmux answered with %error.
+type CommandError struct {
+	Command string // as sent, e.g. "has-session -t '=hq-mayor'"; "" if unknown
+	Message string // tmux's error output
+	kind    error  // one of the Err values above, or nil
+}
+
+// newCommandError classifies tmux's error output.
+func newCommandError(message string) *CommandError {
+	e := &CommandError{Message: message}
+	lower := strings.ToLower(message)
+	for _, k := range errorKinds {
+		if strings.Contains(lower, k.fragment) {
+			e.kind = k.kind
+			break
+		}
+	}
+	return e
+}
+
+func (e *CommandError) Error() string { return "tmux: " + e.Message }
+
+// Unwrap returns the kind of failure, so errors.Is(err, ErrSessionNotFound)
+// works through the CommandError.
+func (e *CommandError) Unwrap() error { return e.kind }
+
+// ErrorCode returns the machine-readable code for a classified tmux failure
+// anywhere in err's chain, e.g. "session-not-found", or "" for other errors.
+func ErrorCode(err error) string {
+	var ce *CommandError
+	if !errors.As(err, &ce) || ce.kind == nil {
+		return ""
+	}
+	return errorCodes[ce.kind]
+}
@@ -0,0 +1,150 @@
+package tmux
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// eventBuffer is how many events a subscriber may fall behind by before
+// further ones are dropped for it.
+const eventBuffer = 64
+
+// EventTypes are the control-mode notifications SubscribeEvents forwards:
+// the ones describing session, window, and pane structure.
+var EventTypes = []string{
+	"sessions-changed",
+	"session-renamed",
+	"session-window-changed",
+	"window-add",
+	"window-close",
+	"window-renamed",
+	"window-pane-changed",
+	"layout-change",
+	"unlinked-window-add",
+	"unlinked-window-close",
+	"unlinked-window-renamed",
+}
+
+// Event is a structural control-mode notification with its arguments
+// picked apart. Raw is the notification line as tmux sent it.
+type Event struct {
+	Type      string `json:"type"`
+	SessionID string `json:"sessionId,omitempty"` // $N
+	WindowID  string `json:"windowId,omitempty"`  // @N
+	PaneID    string `json:"paneId,omitempty"`    // %N
+	Name      string `json:"name,omitempty"`      // new session or window name
+	Layout    string `json:"layout,omitempty"`    // layout-change's window layout
+	Raw       string `json:"raw"`
+}
+
+// eventHub fans events out to subscribers.
+type eventHub struct {
+	mu     sync.Mutex
+	subs   map[int]chan Event
+	nextID int
+	closed bool
+}
+
+// ParseEvent parses a control-mode notification line, reporting false for
+// lines that aren't one of EventTypes.
+func ParseEvent(line string) (Event, bool) {
+	name, args, _ := strings.Cut(line, " ")
+	name, ok := strings.CutPrefix(name, "%")
+	if !ok || !slices.Contains(EventTypes, name) {
+		return Event{}, false
+	}
+	e := Event{Type: name, Raw: line}
+	switch name {
+	case "session-renamed":
+		// %session-renamed $ID NAME
+		e.SessionID, e.Name, _ = strings.Cut(args, " ")
+	case "session-window-changed":
+		// %session-window-changed $ID @ID
+		e.SessionID, e.WindowID, _ = strings.Cut(args, " ")
+	case "window-renamed", "unlinked-window-renamed":
+		// %window-renamed @ID NAME
+		e.WindowID, e.Name, _ = strings.Cut(args, " ")
+	case "window-pane-changed":
+		// %window-pane-changed @ID %ID
+		e.WindowID, e.PaneID, _ = strings.Cut(args, " ")
+	case "layout-change":
+		// %layout-change @ID LAYOUT VISIBLE-LAYOUT FLAGS
+		fields := strings.Fields(args)
+		if len(fields) > 0 {
+			e.WindowID = fields[0]
+		}
+		if len(fields) > 1 {
+			e.Layout = fields[1]
+		}
+	case "window-add", "window-close", "unlinked-window-add", "unlinked-window-close":
+		e.WindowID = args
+	}
+	return e, true
+}
+
+// SubscribeEvents starts forwarding this server's structural notifications
+// (see EventTypes). Events a subscriber is too slow for are dropped. The
+// channel closes on UnsubscribeEvents or when control mode exits. Peers'
+// events are not included.
+func (cm *ControlMode) SubscribeEvents() (int, <-chan Event) {
+	h := &cm.events
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan Event, eventBuffer)
+	if h.closed {
+		close(ch)
+		return 0, ch
+	}
+	if h.subs == nil {
+		h.subs = make(map[int]chan Event)
+	}
+	h.nextID++
+	h.subs[h.nextID] = ch
+	return h.nextID, ch
+}
+
+// UnsubscribeEvents stops a SubscribeEvents subscription and closes its
+// channel.
+func (cm *ControlMode) UnsubscribeEvents(id int) {
+	h := &cm.events
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ch, ok := h.subs[id]; ok {
+		delete(h.subs, id)
+		close(ch)
+	}
+}
+
+// publishEvent forwards line to event subscribers if it is a structural
+// notification.
+func (cm *ControlMode) publishEvent(line string) {
+	h := &cm.events
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs) == 0 {
+		return
+	}
+	e, ok := ParseEvent(line)
+	if !ok {
+		return
+	}
+	for _, ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// closeEvents ends every subscription once control mode has exited.
+func (cm *ControlMode) closeEvents() {
+	h := &cm.events
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for id, ch := range h.subs {
+		delete(h.subs, id)
+		close(ch)
+	}
+}
@@ -0,0 +1,59 @@
+package tmux
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		line string
+		want Event
+	}{
+		{"%session-renamed $3 gt-gastown-crew-max", Event{Type: "session-renamed", SessionID: "$3", Name: "gt-gastown-crew-max"}},
+		{"%window-close @7", Event{Type: "window-close", WindowID: "@7"}},
+		{"%layout-change @2 b25d,80x24,0,0,1 b25d,80x24,0,0,1 *", Event{Type: "layout-change", WindowID: "@2", Layout: "b25d,80x24,0,0,1"}},
+		{"%window-pane-changed @2 %5", Event{Type: "window-pane-changed", WindowID: "@2", PaneID: "%5"}},
+		{"%sessions-changed", Event{Type: "sessions-changed"}},
+	}
+	for _, tt := range tests {
+		got, ok := ParseEvent(tt.line)
+		tt.want.Raw = tt.line
+		if !ok || got != tt.want {
+			t.Errorf("ParseEvent(%q) = %+v, %t; want %+v", tt.line, got, ok, tt.want)
+		}
+	}
+	for _, line := range []string{"%output %1 hello", "%begin 1 2 0", "%session-changed $1 main", "plain output"} {
+		if e, ok := ParseEvent(line); ok {
+			t.Errorf("ParseEvent(%q) = %+v, want no event", line, e)
+		}
+	}
+}
+
+func TestReadLoopPublishesEvents(t *testing.T) {
+	cm := &ControlMode{notifications: make(chan Notification, 10), responseCh: make(chan commandResponse, 1)}
+	_, ch := cm.SubscribeEvents()
+
+	cm.readLoop(strings.NewReader(strings.Join([]string{
+		"%begin 1 1 0",
+		"%window-close @9", // command output, not a notification
+		"%end 1 1 0",
+		"%window-close @7",
+		"%output %1 hi",
+		"%window-renamed @2 claude",
+	}, "\n") + "\n"))
+	cm.closeEvents()
+
+	var got []string
+	for e := range ch {
+		got = append(got, e.Raw)
+	}
+	want := []string{"%window-close @7", "%window-renamed @2 claude"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+	<-cm.notifications // %output
+	if n := <-cm.notifications; n.Type != "window-renamed" {
+		t.Fatalf("notification = %+v, want window-renamed for the registry", n)
+	}
+}
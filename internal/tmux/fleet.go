@@ -0,0 +1,188 @@
+package tmux
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultServerPattern matches every user's default tmux server socket.
+const DefaultServerPattern = "/tmp/tmux-*/default"
+
+// PeerSeparator joins a peer server's name to its session names and IDs.
+// tmux session names can't contain it, so "alice:hq-mayor" is never a local
+// session, whereas "alice/hq-mayor" could be a project-scoped one.
+const PeerSeparator = ":"
+
+// Server is another user's tmux server found by DiscoverServers.
+type Server struct {
+	Name   string // namespace for its sessions: the owner's login, plus "@socket" if not "default"
+	User   string
+	UID    int
+	Socket string
+}
+
+// DiscoverServers lists the tmux server sockets matching pattern that belong
+// to other users. Our own server is reached without a peer.
+func DiscoverServers(pattern string) ([]Server, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("scan %s: %w", pattern, err)
+	}
+	self := os.Getuid()
+	var servers []Server
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.Mode()&os.ModeSocket == 0 {
+			continue
+		}
+		uid, ok := fileOwner(info)
+		if !ok || uid == self {
+			continue
+		}
+		login := strconv.Itoa(uid)
+		if u, err := user.LookupId(login); err == nil {
+			login = u.Username
+		}
+		name := login
+		if base := filepath.Base(path); base != "default" {
+			name += "@" + strings.ReplaceAll(base, PeerSeparator, "_")
+		}
+		servers = append(servers, Server{Name: name, User: login, UID: uid, Socket: path})
+	}
+	return servers, nil
+}
+
+// SyncServers attaches a peer for each server matching pattern that isn't
+// attached yet. Each peer's control client uses a monitor session of its
+// own, hidden from ListSessions. Peers whose server exits detach themselves.
+func (cm *ControlMode) SyncServers(pattern, monitor string) error {
+	servers, err := DiscoverServers(pattern)
+	if err != nil {
+		return err
+	}
+	for _, srv := range servers {
+		cm.peersMu.RLock()
+		_, attached := cm.peers[srv.Name]
+		cm.peersMu.RUnlock()
+		if attached {
+			continue
+		}
+		// A stale socket has no server behind it; creating the monitor
+		// session would start one, as us, in the user's socket directory.
+		if err := tmuxCommand(srv.Socket, "list-sessions").Run(); err != nil {
+			continue
+		}
+		peer, err := NewServerControlMode(srv.Socket, srv.UID, monitor)
+		if err != nil {
+			log.Printf("tmux: attach %s's server at %s: %v", srv.User, srv.Socket, err)
+			continue
+		}
+		cm.addPeer(srv.Name, peer)
+		log.Printf("tmux: attached %s's server at %s as %q", srv.User, srv.Socket, srv.Name)
+	}
+	return nil
+}
+
+// addPeer routes "name:..." targets to peer and forwards its notifications,
+// dropping it again when its server exits.
+func (cm *ControlMode) addPeer(name string, peer *ControlMode) {
+	cm.peersMu.Lock()
+	cm.peers[name] = peer
+	cm.peersMu.Unlock()
+	cm.notifications <- Notification{Type: "sessions-changed"}
+
+	go func() {
+		for {
+			select {
+			case n := <-peer.notifications:
+				cm.notifications <- n
+			case <-peer.exited:
+				cm.peersMu.Lock()
+				current := cm.peers[name] == peer
+				if current {
+					delete(cm.peers, name)
+				}
+				cm.peersMu.Unlock()
+				if current { // otherwise Close already took it
+					log.Printf("tmux: %q server exited", name)
+					peer.Close()
+					cm.notifications <- Notification{Type: "sessions-changed"}
+				}
+				return
+			}
+		}
+	}()
+}
+
+// resolve maps target to the connection serving it: "name:target" is
+// target on peer name's server, anything else is on ours.
+func (cm *ControlMode) resolve(target string) (*ControlMode, string) {
+	if peer, _, rest := cm.resolvePeer(target); peer != nil {
+		return peer, rest
+	}
+	return cm, target
+}
+
+func (cm *ControlMode) resolvePeer(target string) (peer *ControlMode, name, rest string) {
+	name, rest, ok := strings.Cut(target, PeerSeparator)
+	if !ok {
+		return nil, "", target
+	}
+	cm.peersMu.RLock()
+	defer cm.peersMu.RUnlock()
+	return cm.peers[name], name, rest
+}
+
+// peerSessions lists the peers' sessions, namespaced by peer.
+func (cm *ControlMode) peerSessions() []SessionInfo {
+	cm.peersMu.RLock()
+	peers := make(map[string]*ControlMode, len(cm.peers))
+	for name, peer := range cm.peers {
+		peers[name] = peer
+	}
+	cm.peersMu.RUnlock()
+
+	var sessions []SessionInfo
+	for name, peer := range peers {
+		list, err := peer.listSessions()
+		if err != nil {
+			log.Printf("tmux: list %q sessions: %v", name, err)
+			continue
+		}
+		for _, s := range list {
+			if s.Name == peer.session {
+				continue
+			}
+			s.Name = name + PeerSeparator + s.Name
+			if s.ID != "" {
+				s.ID = name + PeerSeparator + s.ID // IDs are only unique per server
+			}
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// giveToOwner hands a file we created to the user running a peer's server,
+// which has to read or write it.
+func (cm *ControlMode) giveToOwner(path string) error {
+	if cm.socket == "" || cm.owner < 0 {
+		return nil
+	}
+	if err := os.Chown(path, cm.owner, -1); err != nil {
+		return fmt.Errorf("chown %s to server owner: %w", path, err)
+	}
+	return nil
+}
+
+// OwnFile hands a file at path to the user running target's server, for
+// files that server's commands write, such as pipe-pane output.
+func (cm *ControlMode) OwnFile(target, path string) error {
+	cm, _ = cm.resolve(target)
+	return cm.giveToOwner(path)
+}
@@ -0,0 +1,75 @@
+package tmux
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPeerSessionsAreNamespaced(t *testing.T) {
+	local := newStubCM(func(string) commandResponse {
+		return commandResponse{output: "adapter-monitor\t0\nhq-mayor\t1"}
+	})
+	var peerCmds []string
+	peer := newStubCM(func(cmd string) commandResponse {
+		peerCmds = append(peerCmds, cmd)
+		if strings.HasPrefix(cmd, "list-sessions") {
+			return commandResponse{output: "adapter-monitor\t0\nbuilder\t0"}
+		}
+		return commandResponse{output: "%4\tclaude\t123\t/home/alice/gt"}
+	})
+	peer.session = "adapter-monitor"
+	local.peers = map[string]*ControlMode{"alice": peer}
+
+	sessions, err := local.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	var names []string
+	for _, s := range sessions {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "adapter-monitor,hq-mayor,alice:builder" {
+		t.Fatalf("sessions = %s, want the peer's monitor hidden and its sessions namespaced", got)
+	}
+
+	// Targets in a peer's namespace run on the peer without the prefix.
+	if _, err := local.GetPaneInfo("alice:builder"); err != nil {
+		t.Fatalf("GetPaneInfo() error = %v", err)
+	}
+	if last := peerCmds[len(peerCmds)-1]; !strings.Contains(last, "-t 'builder'") {
+		t.Fatalf("peer ran %q, want target builder", last)
+	}
+}
+
+func TestResolveUnknownNamespaceStaysLocal(t *testing.T) {
+	local := &ControlMode{}
+	if cm, target := local.resolve("bob:builder"); cm != local || target != "bob:builder" {
+		t.Fatalf("resolve() = %p %q, want local and the target unchanged", cm, target)
+	}
+}
+
+func TestResolveProjectSessionStaysLocal(t *testing.T) {
+	local := &ControlMode{peers: map[string]*ControlMode{"alice": {}}}
+	if cm, target := local.resolve("alice/crew/bob"); cm != local || target != "alice/crew/bob" {
+		t.Fatalf("resolve() = %p %q, want the local project session untouched", cm, target)
+	}
+}
+
+func TestDiscoverServersSkipsOwnServer(t *testing.T) {
+	dir := t.TempDir()
+	ln, err := net.Listen("unix", filepath.Join(dir, "default"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+
+	servers, err := DiscoverServers(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("DiscoverServers() error = %v", err)
+	}
+	if len(servers) != 0 {
+		t.Fatalf("servers = %+v, want our own socket skipped", servers)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -53,7 +54,8 @@ func (pm *PipePaneManager) Subscribe(session string) (int, <-chan []byte, error)
 	}
 
 	// First subscriber — activate pipe-pane
-	filePath := fmt.Sprintf("/tmp/adapter-%s.pipe", session)
+	// Project-scoped sessions ("project/role/name") would otherwise nest paths.
+	filePath := fmt.Sprintf("/tmp/adapter-%s.pipe", strings.ReplaceAll(session, "/", "_"))
 
 	// Create the file if it doesn't exist
 	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
@@ -63,6 +65,13 @@ func (pm *PipePaneManager) Subscribe(session string) (int, <-chan []byte, error)
 	if err := f.Close(); err != nil {
 		return 0, nil, fmt.Errorf("close pipe file: %w", err)
 	}
+	// On another user's server, pipe-pane's cat runs as that user.
+	if err := pm.ctrl.OwnFile(session, filePath); err != nil {
+		if rmErr := os.Remove(filePath); rmErr != nil {
+			log.Printf("pipe-pane cleanup %s: %v", filePath, rmErr)
+		}
+		return 0, nil, err
+	}
 
 	// Activate pipe-pane
 	if err := pm.ctrl.PipePaneStart(session, fmt.Sprintf("cat >> %s", filePath)); err != nil {
@@ -111,6 +120,19 @@ func (pm *PipePaneManager) Unsubscribe(session string, id int) {
 	}
 }
 
+// Rename moves the stream of session oldName to newName after tmux renamed
+// the session. The pipe stays attached to the pane, so output carries on
+// without a gap.
+func (pm *PipePaneManager) Rename(oldName, newName string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if stream, ok := pm.streams[oldName]; ok {
+		delete(pm.streams, oldName)
+		stream.session = newName
+		pm.streams[newName] = stream
+	}
+}
+
 // StopAll deactivates all pipe-panes and cleans up.
 func (pm *PipePaneManager) StopAll() {
 	pm.mu.Lock()
@@ -0,0 +1,304 @@
+package tmux
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceEntry is one line of a control mode transcript (JSONL). The first
+// entry is a "start"; after it, "send" entries are commands written to tmux
+// and "recv" entries are lines it wrote back, responses and notifications
+// alike, in the order they happened.
+type TraceEntry struct {
+	At      time.Time `json:"at"`
+	Kind    string    `json:"kind"`              // "start", "send", or "recv"
+	Line    string    `json:"line,omitempty"`    // send, recv
+	Session string    `json:"session,omitempty"` // start: the monitor session
+	Version string    `json:"version,omitempty"` // start: tmux -V, e.g. "3.4"
+}
+
+// recorder appends a connection's traffic to a transcript file. A nil
+// recorder records nothing.
+type recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+	err error // first write error; later entries are dropped
+}
+
+func newRecorder(path string) (*recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("tmux transcript: %w", err)
+	}
+	return &recorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (r *recorder) record(e TraceEntry) {
+	if r == nil {
+		return
+	}
+	e.At = time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	if err := r.enc.Encode(e); err != nil {
+		r.err = err
+		log.Printf("tmux transcript %s: %v; recording stopped", r.f.Name(), err)
+	}
+}
+
+func (r *recorder) close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.f.Close(); err != nil {
+		log.Printf("tmux transcript close: %v", err)
+	}
+}
+
+// RecordControlMode is NewControlMode that also writes everything sent to
+// and received from tmux to a transcript at path, for ReplayControlMode.
+// Peers it attaches are not recorded.
+func RecordControlMode(sessionName, path string) (*ControlMode, error) {
+	rec, err := newRecorder(path)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := newControlMode("", -1, sessionName, rec)
+	if err != nil {
+		rec.close()
+		return nil, err
+	}
+	return cm, nil
+}
+
+// replayCommandWait is how long a replay waits for the command the
+// transcript says comes next before moving on without it.
+const replayCommandWait = 2 * time.Second
+
+// ReplayControlMode returns a ControlMode that plays back a transcript
+// written by RecordControlMode instead of talking to tmux, so a registry or
+// detection bug can be reproduced offline. Notifications are replayed in
+// order, spaced as recorded divided by speed (0 plays them as fast as
+// possible). Each command is answered with the recorded response to the
+// next unanswered instance of the same command; a command the transcript no
+// longer has gets the last response it had, and one it never had fails.
+// Session returns the recorded monitor session.
+func ReplayControlMode(path string, speed float64) (*ControlMode, error) {
+	start, items, err := loadTranscript(path)
+	if err != nil {
+		return nil, err
+	}
+	caps := CapabilitiesFor("tmux " + start.Version)
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	cm := &ControlMode{
+		stdin:          stdinW,
+		notifications:  make(chan Notification, 100),
+		responseCh:     make(chan commandResponse, 1),
+		done:           make(chan struct{}),
+		exited:         make(chan struct{}),
+		session:        start.Session,
+		executeTimeout: defaultExecuteTimeout,
+		owner:          -1,
+		caps:           &caps,
+		peers:          make(map[string]*ControlMode),
+	}
+	p := &replayer{items: items, speed: speed, out: stdoutW, sent: make(chan string), last: make(map[string][]string)}
+	go func() {
+		sc := bufio.NewScanner(stdinR)
+		for sc.Scan() {
+			p.sent <- sc.Text()
+		}
+		close(p.sent)
+	}()
+	go func() {
+		p.run()
+		_ = stdoutW.Close()
+	}()
+	go func() {
+		cm.readLoop(stdoutR)
+		cm.closeEvents()
+		close(cm.exited)
+	}()
+	log.Printf("replaying tmux transcript %s (tmux %s, %d entries)", path, start.Version, len(items))
+	return cm, nil
+}
+
+// replayItem is a notification line, or a command with the response lines
+// tmux gave it.
+type replayItem struct {
+	at       time.Time
+	command  string // "" for a notification
+	lines    []string
+	answered bool
+}
+
+// loadTranscript reads a transcript into its start entry and replay items.
+// The lines between a %begin and its %end or %error that follow a send are
+// that command's response; every other received line is a notification,
+// including the response to the initial attach.
+func loadTranscript(path string) (TraceEntry, []*replayItem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return TraceEntry{}, nil, fmt.Errorf("tmux transcript: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var start TraceEntry
+	var items []*replayItem
+	var pending []*replayItem // sent, response not yet seen
+	var inResponse *replayItem
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		var e TraceEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return TraceEntry{}, nil, fmt.Errorf("tmux transcript %s:%d: %w", path, n, err)
+		}
+		switch {
+		case n == 1 && e.Kind != "start":
+			return TraceEntry{}, nil, fmt.Errorf("tmux transcript %s: first entry is %q, not start", path, e.Kind)
+		case e.Kind == "start":
+			start = e
+		case e.Kind == "send":
+			item := &replayItem{at: e.At, command: e.Line}
+			items = append(items, item)
+			pending = append(pending, item)
+		case e.Kind == "recv" && inResponse != nil:
+			inResponse.lines = append(inResponse.lines, e.Line)
+			if strings.HasPrefix(e.Line, "%end ") || strings.HasPrefix(e.Line, "%error ") {
+				inResponse = nil
+			}
+		case e.Kind == "recv" && strings.HasPrefix(e.Line, "%begin ") && len(pending) > 0:
+			inResponse, pending = pending[0], pending[1:]
+			inResponse.lines = []string{e.Line}
+		case e.Kind == "recv":
+			items = append(items, &replayItem{at: e.At, lines: []string{e.Line}})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return TraceEntry{}, nil, fmt.Errorf("tmux transcript %s: %w", path, err)
+	}
+	if start.Kind == "" {
+		return TraceEntry{}, nil, fmt.Errorf("tmux transcript %s is empty", path)
+	}
+	return start, items, nil
+}
+
+// replayer feeds transcript items to a ControlMode's readLoop.
+type replayer struct {
+	items []*replayItem
+	speed float64
+	out   io.Writer
+	sent  chan string         // commands Execute wrote
+	last  map[string][]string // each command's latest response given
+}
+
+// run plays the items in order, answering commands as they arrive, then
+// keeps answering until the connection's stdin closes.
+func (p *replayer) run() {
+	var prev time.Time
+	for i, item := range p.items {
+		if !prev.IsZero() && p.speed > 0 {
+			if !p.serveFor(i, time.Duration(float64(item.at.Sub(prev))/p.speed)) {
+				return
+			}
+		}
+		prev = item.at
+		if item.command == "" {
+			if !p.write(item.lines) {
+				return
+			}
+			continue
+		}
+		deadline := time.Now().Add(replayCommandWait)
+		for !item.answered {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				log.Printf("replay: %q not sent; skipping it", item.command)
+				break
+			}
+			if !p.serveFor(i, wait) {
+				return
+			}
+		}
+	}
+	log.Printf("replay: transcript done")
+	for command := range p.sent {
+		if !p.answer(len(p.items), command) {
+			return
+		}
+	}
+}
+
+// serveFor answers commands for d, or until the command at item i has been
+// answered. It reports false once stdin is closed.
+func (p *replayer) serveFor(i int, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case command, ok := <-p.sent:
+			if !ok || !p.answer(i, command) {
+				return false
+			}
+			if p.items[i].command != "" && p.items[i].answered {
+				return true
+			}
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// answer writes the response to command from the first unanswered item at
+// or after i that recorded it, falling back on the last response given.
+func (p *replayer) answer(i int, command string) bool {
+	skipped := ""
+	for j := i; j < len(p.items); j++ {
+		item := p.items[j]
+		if item.command == "" || item.answered || item.lines == nil {
+			continue
+		}
+		if item.command != command {
+			if skipped == "" {
+				skipped = item.command
+			}
+			continue
+		}
+		if skipped != "" {
+			log.Printf("replay: %q sent before %q, unlike the transcript", command, skipped)
+		}
+		item.answered = true
+		p.last[command] = item.lines
+		return p.write(item.lines)
+	}
+	if lines, ok := p.last[command]; ok {
+		return p.write(lines)
+	}
+	log.Printf("replay: %q is not in the transcript", command)
+	return p.write([]string{"%begin 0 0 0", "replay: command not in transcript", "%error 0 0 0"})
+}
+
+func (p *replayer) write(lines []string) bool {
+	for _, line := range lines {
+		if _, err := io.WriteString(p.out, line+"\n"); err != nil {
+			return false
+		}
+	}
+	return true
+}
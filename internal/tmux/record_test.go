@@ -0,0 +1,94 @@
+package tmux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTmux answers control mode commands the way tmux would: list-sessions
+// succeeds, anything else fails as a missing session.
+func fakeTmux(stdin io.Reader, stdout io.WriteCloser) {
+	defer func() { _ = stdout.Close() }()
+	_, _ = io.WriteString(stdout, "%begin 1 1 0\n%end 1 1 0\n%sessions-changed\n")
+	sc := bufio.NewScanner(stdin)
+	for n := 2; sc.Scan(); n++ {
+		if sc.Text() == "list-sessions" {
+			fmt.Fprintf(stdout, "%%begin 1 %d 1\nhq-mayor\n%%end 1 %d 1\n", n, n)
+		} else {
+			fmt.Fprintf(stdout, "%%begin 1 %d 1\ncan't find session: x\n%%error 1 %d 1\n", n, n)
+		}
+	}
+}
+
+func waitNotification(t *testing.T, cm *ControlMode, want string) {
+	t.Helper()
+	select {
+	case n := <-cm.Notifications():
+		if n.Type != want {
+			t.Fatalf("notification = %+v, want %s", n, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no %s notification", want)
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tmux.jsonl")
+	rec, err := newRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.record(TraceEntry{Kind: "start", Session: "adapter-monitor", Version: "3.4"})
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	live := &ControlMode{
+		stdin:          stdinW,
+		notifications:  make(chan Notification, 10),
+		responseCh:     make(chan commandResponse, 1),
+		done:           make(chan struct{}),
+		executeTimeout: 2 * time.Second,
+		rec:            rec,
+	}
+	go fakeTmux(stdinR, stdoutW)
+	loopDone := make(chan struct{})
+	go func() { live.readLoop(stdoutR); close(loopDone) }()
+
+	waitNotification(t, live, "sessions-changed")
+	if out, err := live.Execute("list-sessions"); err != nil || out != "hq-mayor" {
+		t.Fatalf("live list-sessions = %q, %v", out, err)
+	}
+	if _, err := live.Execute("has-session -t x"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("live has-session error = %v", err)
+	}
+	_ = stdinW.Close()
+	<-loopDone
+	rec.close()
+
+	cm, err := ReplayControlMode(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	if cm.Session() != "adapter-monitor" || cm.Capabilities().Version != "3.4" {
+		t.Errorf("replay session %q, tmux %q; want the recorded ones", cm.Session(), cm.Capabilities().Version)
+	}
+	waitNotification(t, cm, "sessions-changed")
+	if out, err := cm.Execute("list-sessions"); err != nil || out != "hq-mayor" {
+		t.Fatalf("replayed list-sessions = %q, %v", out, err)
+	}
+	if _, err := cm.Execute("has-session -t x"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("replayed has-session error = %v", err)
+	}
+	if out, err := cm.Execute("list-sessions"); err != nil || out != "hq-mayor" {
+		t.Errorf("list-sessions past the transcript = %q, %v; want its last response", out, err)
+	}
+	if _, err := cm.Execute("kill-server"); err == nil || !strings.Contains(err.Error(), "not in transcript") {
+		t.Errorf("unrecorded command error = %v", err)
+	}
+}
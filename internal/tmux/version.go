@@ -0,0 +1,91 @@
+package tmux
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Capabilities records which optional command forms the installed tmux
+// supports, so commands pick a variant up front instead of trying one and
+// matching the error text tmux returns.
+type Capabilities struct {
+	// Version is the version tmux -V reported, e.g. "3.3a", or "" if it
+	// could not be determined.
+	Version string
+
+	// SendKeysHex is send-keys -H, which sends bytes given in hex (3.0).
+	// Without it SendKeysBytes sends the bytes with send-keys -l.
+	SendKeysHex bool
+	// LoadBufferClipboard is load-buffer -w, which also sets the terminal
+	// clipboard (3.2).
+	LoadBufferClipboard bool
+	// ResizeWindow is the resize-window command (2.9). Without it
+	// ForceRedraw signals the pane's process directly.
+	ResizeWindow bool
+}
+
+// capabilityTable lists each capability with the first release to have it.
+var capabilityTable = []struct {
+	major, minor int
+	set          func(*Capabilities)
+}{
+	{2, 9, func(c *Capabilities) { c.ResizeWindow = true }},
+	{3, 0, func(c *Capabilities) { c.SendKeysHex = true }},
+	{3, 2, func(c *Capabilities) { c.LoadBufferClipboard = true }},
+}
+
+// tmuxVersion matches the release in tmux -V output: "tmux 3.3a",
+// "tmux next-3.5", "tmux 3.4-rc".
+var tmuxVersion = regexp.MustCompile(`(\d+)\.(\d+)([a-z]?)`)
+
+// ParseVersion returns the major and minor release in tmux -V output. ok is
+// false for builds that report no release number, such as "tmux master" or
+// "tmux openbsd-7.5".
+func ParseVersion(out string) (major, minor int, ok bool) {
+	out = strings.TrimSpace(out)
+	if strings.Contains(out, "openbsd-") {
+		return 0, 0, false
+	}
+	m := tmuxVersion.FindStringSubmatch(out)
+	if m == nil {
+		return 0, 0, false
+	}
+	major, _ = strconv.Atoi(m[1])
+	minor, _ = strconv.Atoi(m[2])
+	return major, minor, true
+}
+
+// CapabilitiesFor returns the capabilities of the tmux that printed out for
+// tmux -V. A build that reports no release number is a development or
+// OpenBSD base build, which tracks the latest tmux, so it gets every
+// capability.
+func CapabilitiesFor(out string) Capabilities {
+	c := Capabilities{Version: strings.TrimPrefix(strings.TrimSpace(out), "tmux ")}
+	major, minor, ok := ParseVersion(out)
+	for _, entry := range capabilityTable {
+		if !ok || major > entry.major || (major == entry.major && minor >= entry.minor) {
+			entry.set(&c)
+		}
+	}
+	return c
+}
+
+// probeCapabilities runs tmux -V against socket. A tmux that can't be run
+// or reports nothing gets every capability, as a current release would.
+func probeCapabilities(socket string) (Capabilities, error) {
+	out, err := tmuxCommand(socket, "-V").Output()
+	if err != nil {
+		return CapabilitiesFor(""), fmt.Errorf("tmux -V: %w", err)
+	}
+	return CapabilitiesFor(string(out)), nil
+}
+
+// Capabilities returns what the connected tmux supports.
+func (cm *ControlMode) Capabilities() Capabilities {
+	if cm.caps == nil {
+		return CapabilitiesFor("")
+	}
+	return *cm.caps
+}
@@ -0,0 +1,116 @@
+package tmux
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCapabilitiesFor(t *testing.T) {
+	tests := []struct {
+		out                    string
+		version                string
+		resize, hex, clipboard bool
+	}{
+		{"tmux 2.8\n", "2.8", false, false, false},
+		{"tmux 2.9a\n", "2.9a", true, false, false},
+		{"tmux 3.0a\n", "3.0a", true, true, false},
+		{"tmux 3.1c\n", "3.1c", true, true, false},
+		{"tmux 3.2a\n", "3.2a", true, true, true},
+		{"tmux 3.3a\n", "3.3a", true, true, true},
+		{"tmux 3.4\n", "3.4", true, true, true},
+		{"tmux next-3.6\n", "next-3.6", true, true, true},
+		{"tmux master\n", "master", true, true, true},
+		{"tmux openbsd-7.5\n", "openbsd-7.5", true, true, true},
+		{"", "", true, true, true},
+	}
+	for _, tt := range tests {
+		c := CapabilitiesFor(tt.out)
+		if c.Version != tt.version {
+			t.Errorf("CapabilitiesFor(%q).Version = %q, want %q", tt.out, c.Version, tt.version)
+		}
+		if c.ResizeWindow != tt.resize || c.SendKeysHex != tt.hex || c.LoadBufferClipboard != tt.clipboard {
+			t.Errorf("CapabilitiesFor(%q) = %+v, want resize=%t hex=%t clipboard=%t", tt.out, c, tt.resize, tt.hex, tt.clipboard)
+		}
+	}
+}
+
+// recordingCM returns a stub ControlMode for the tmux that prints version,
+// and the commands it executes.
+func recordingCM(version string) (*ControlMode, func() []string) {
+	var mu sync.Mutex
+	var executed []string
+	cm := newStubCM(func(cmd string) commandResponse {
+		mu.Lock()
+		executed = append(executed, cmd)
+		mu.Unlock()
+		return commandResponse{output: "80:24"}
+	})
+	caps := CapabilitiesFor(version)
+	cm.caps = &caps
+	return cm, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), executed...)
+	}
+}
+
+func TestSendKeysBytesByVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"tmux 2.9a": `send-keys -t 'hq-mayor' -l "a"`,
+		"tmux 3.0a": "send-keys -t 'hq-mayor' -H 61",
+		"tmux 3.4":  "send-keys -t 'hq-mayor' -H 61",
+	} {
+		cm, executed := recordingCM(version)
+		if err := cm.SendKeysBytes("hq-mayor", []byte("a")); err != nil {
+			t.Fatalf("%s: SendKeysBytes() error = %v", version, err)
+		}
+		if got := executed(); len(got) != 1 || got[0] != want {
+			t.Errorf("%s: executed %q, want [%q]", version, got, want)
+		}
+	}
+}
+
+func TestPasteBytesByVersion(t *testing.T) {
+	for version, clipboard := range map[string]bool{
+		"tmux 3.1c": false,
+		"tmux 3.2a": true,
+	} {
+		cm, executed := recordingCM(version)
+		if err := cm.PasteBytes("hq-mayor", []byte("hello")); err != nil {
+			t.Fatalf("%s: PasteBytes() error = %v", version, err)
+		}
+		got := executed()
+		if len(got) != 2 || !strings.HasPrefix(got[0], "load-buffer ") || !strings.HasPrefix(got[1], "paste-buffer ") {
+			t.Fatalf("%s: executed %q, want load-buffer then paste-buffer", version, got)
+		}
+		if strings.Contains(got[0], " -w ") != clipboard {
+			t.Errorf("%s: %q, want -w = %t", version, got[0], clipboard)
+		}
+	}
+}
+
+func TestResizeWindowByVersion(t *testing.T) {
+	cm, executed := recordingCM("tmux 2.8")
+	if err := cm.ResizeWindow("hq-mayor", 80, 24); err == nil {
+		t.Fatal("ResizeWindow() on tmux 2.8 succeeded, want an error")
+	}
+	if got := executed(); len(got) != 0 {
+		t.Fatalf("tmux 2.8 executed %q, want nothing", got)
+	}
+
+	cm, executed = recordingCM("tmux 2.9a")
+	if err := cm.ResizeWindow("hq-mayor", 80, 24); err != nil {
+		t.Fatalf("ResizeWindow() on tmux 2.9a error = %v", err)
+	}
+	if got := executed(); len(got) != 1 || got[0] != "resize-window -t 'hq-mayor' -x 80 -y 24" {
+		t.Fatalf("tmux 2.9a executed %q", got)
+	}
+}
+
+func TestUnprobedControlModeAssumesCurrentTmux(t *testing.T) {
+	cm := newStubCM(func(string) commandResponse { return commandResponse{} })
+	if c := cm.Capabilities(); !c.SendKeysHex || !c.LoadBufferClipboard || !c.ResizeWindow {
+		t.Fatalf("Capabilities() = %+v, want every capability", c)
+	}
+}
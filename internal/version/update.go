@@ -0,0 +1,285 @@
+package version
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReleasesURL is the GitHub API endpoint for the latest release.
+var ReleasesURL = "https://api.github.com/repos/gastownhall/tmux-adapter/releases/latest"
+
+// Release is the subset of the GitHub release payload used for updates.
+type Release struct {
+	TagName string  `json:"tag_name"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a downloadable release file.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+var httpClient = &http.Client{Timeout: 2 * time.Minute}
+
+// Latest fetches the latest published release.
+func Latest(ctx context.Context) (Release, error) {
+	var rel Release
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ReleasesURL, nil)
+	if err != nil {
+		return rel, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return rel, fmt.Errorf("fetch latest release: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return rel, fmt.Errorf("fetch latest release: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return rel, fmt.Errorf("decode release: %w", err)
+	}
+	return rel, nil
+}
+
+// IsNewer reports whether the release is a later semantic version than the
+// running build, so an older release is never offered as an update. Builds
+// whose version isn't semantic, such as "dev", are older than any release;
+// releases whose tag isn't are never newer.
+func (r Release) IsNewer() bool {
+	rel, ok := parseSemver(r.TagName)
+	if !ok {
+		return false
+	}
+	cur, ok := parseSemver(Version)
+	return !ok || rel.compare(cur) > 0
+}
+
+// semver is a parsed [v]MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD] version.
+type semver struct {
+	core [3]int
+	pre  []string
+}
+
+// gitDescribeSuffix is what `git describe` appends to the last tag for a
+// build commits past it ("-3-gabc1234", maybe "-dirty"); such a build counts
+// as the tag itself.
+var gitDescribeSuffix = regexp.MustCompile(`(-\d+-g[0-9a-f]+)?(-dirty)?$`)
+
+func parseSemver(s string) (semver, bool) {
+	s, _, _ = strings.Cut(strings.TrimPrefix(s, "v"), "+")
+	s = gitDescribeSuffix.ReplaceAllString(s, "")
+	core, pre, hasPre := strings.Cut(s, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 || (hasPre && pre == "") {
+		return semver{}, false
+	}
+	var v semver
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		v.core[i] = n
+	}
+	if hasPre {
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, true
+}
+
+// compare orders versions by semver precedence, returning -1, 0, or 1.
+func (v semver) compare(o semver) int {
+	if c := slices.Compare(v.core[:], o.core[:]); c != 0 {
+		return c
+	}
+	// A pre-release comes before its release.
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		if c := comparePrerelease(v.pre[i], o.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(v.pre), len(o.pre))
+}
+
+// comparePrerelease orders two pre-release identifiers: numeric ones
+// numerically and before alphanumeric ones, which compare as strings.
+func comparePrerelease(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmp.Compare(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// AssetName is the release file for a binary on this platform, e.g. "tmux-adapter-linux-amd64".
+func AssetName(binary string) string {
+	return fmt.Sprintf("%s-%s-%s", binary, runtime.GOOS, runtime.GOARCH)
+}
+
+// Find returns the asset with the given name.
+func (r Release) Find(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// SelfUpdate downloads the platform binary from rel, verifies it against
+// the release's checksums.txt, and atomically replaces the running
+// executable. A release without checksums.txt is refused.
+func SelfUpdate(ctx context.Context, rel Release, binary string) error {
+	name := AssetName(binary)
+	asset, ok := rel.Find(name)
+	if !ok {
+		return fmt.Errorf("release %s has no asset %s", rel.TagName, name)
+	}
+	sums, ok := rel.Find("checksums.txt")
+	if !ok {
+		return fmt.Errorf("release %s has no checksums.txt; refusing to install an unverified binary", rel.TagName)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	// Download next to the executable so the final rename stays on one filesystem.
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".update-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	h := sha256.New()
+	if err := download(ctx, asset.URL, io.MultiWriter(tmp, h)); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	want, err := fetchChecksum(ctx, sums.URL, name)
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", name, got, want)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return fmt.Errorf("replace %s: %w", exe, err)
+	}
+	return nil
+}
+
+func download(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// fetchChecksum finds name's SHA-256 in a sha256sum-format checksums file.
+func fetchChecksum(ctx context.Context, url, name string) (string, error) {
+	var b strings.Builder
+	if err := download(ctx, url, &b); err != nil {
+		return "", err
+	}
+	return parseChecksum(b.String(), name)
+}
+
+func parseChecksum(sums, name string) (string, error) {
+	sc := bufio.NewScanner(strings.NewReader(sums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("checksums.txt has no entry for %s", name)
+}
+
+// RunCommand handles the shared `version`, `check-update`, and `self-update`
+// subcommands. It reports false if cmd is not one of them.
+func RunCommand(cmd, binary string) (bool, error) {
+	switch cmd {
+	case "version":
+		fmt.Printf("%s %s\n", binary, String())
+		return true, nil
+	case "check-update", "self-update":
+	default:
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	rel, err := Latest(ctx)
+	if err != nil {
+		return true, err
+	}
+	if !rel.IsNewer() {
+		fmt.Printf("%s %s is up to date\n", binary, Version)
+		return true, nil
+	}
+	if cmd == "check-update" {
+		fmt.Printf("%s %s available (running %s); run `%s self-update`\n", binary, rel.TagName, Version, binary)
+		return true, nil
+	}
+	if err := SelfUpdate(ctx, rel, binary); err != nil {
+		return true, err
+	}
+	fmt.Printf("%s updated %s → %s; restart to use the new version\n", binary, Version, rel.TagName)
+	return true, nil
+}
@@ -0,0 +1,80 @@
+package version
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLatest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"tag_name":"v1.2.0","assets":[{"name":"tmux-adapter-linux-amd64","browser_download_url":"https://example.com/a"}]}`))
+	}))
+	defer srv.Close()
+
+	old := ReleasesURL
+	ReleasesURL = srv.URL
+	defer func() { ReleasesURL = old }()
+
+	rel, err := Latest(context.Background())
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if rel.TagName != "v1.2.0" {
+		t.Fatalf("TagName = %q, want v1.2.0", rel.TagName)
+	}
+	if a, ok := rel.Find("tmux-adapter-linux-amd64"); !ok || a.URL != "https://example.com/a" {
+		t.Fatalf("Find() = %+v, %v", a, ok)
+	}
+}
+
+func TestIsNewer(t *testing.T) {
+	old := Version
+	defer func() { Version = old }()
+
+	tests := []struct {
+		running, tag string
+		want         bool
+	}{
+		{"1.2.0", "v1.2.0", false},
+		{"1.2.0", "v1.3.0", true},
+		{"1.2.0", "v1.10.0", true},
+		{"v1.3.0", "v1.2.9", false}, // a downgrade is not an update
+		{"v2.0.0", "v1.9.0", false},
+		{"v1.3.0-rc.1", "v1.3.0", true},
+		{"v1.3.0", "v1.3.0-rc.2", false},
+		{"v1.3.0-rc.2", "v1.3.0-rc.10", true},
+		{"v1.3.0-rc.1", "v1.3.0-beta", false},
+		{"v1.2.0-3-gabc1234-dirty", "v1.2.0", false}, // git describe: past v1.2.0
+		{"v1.2.0-3-gabc1234", "v1.2.1", true},
+		{"dev", "v0.1.0", true},
+		{"1.2.0", "nightly", false},
+	}
+	for _, tt := range tests {
+		Version = tt.running
+		if got := (Release{TagName: tt.tag}).IsNewer(); got != tt.want {
+			t.Errorf("running %s, release %s: IsNewer() = %v, want %v", tt.running, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestSelfUpdateRequiresChecksums(t *testing.T) {
+	rel := Release{TagName: "v9.9.9", Assets: []Asset{{Name: AssetName("tmux-adapter"), URL: "http://127.0.0.1:0/never-fetched"}}}
+	err := SelfUpdate(context.Background(), rel, "tmux-adapter")
+	if err == nil || !strings.Contains(err.Error(), "no checksums.txt") {
+		t.Fatalf("SelfUpdate() error = %v, want a refusal without checksums.txt", err)
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	sums := "abc123  tmux-adapter-linux-amd64\nDEF456 *tmux-converter-linux-amd64\n"
+	got, err := parseChecksum(sums, "tmux-converter-linux-amd64")
+	if err != nil || got != "def456" {
+		t.Fatalf("parseChecksum() = %q, %v; want def456", got, err)
+	}
+	if _, err := parseChecksum(sums, "missing"); err == nil {
+		t.Fatal("expected error for missing entry")
+	}
+}
@@ -0,0 +1,29 @@
+// Package version holds build metadata injected at link time and implements
+// release checks and in-place self-update from GitHub releases.
+package version
+
+import "fmt"
+
+// Set via -ldflags "-X github.com/gastownhall/tmux-adapter/internal/version.Version=..." (see Makefile).
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+// Info is the build metadata served at GET /version.
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+}
+
+// Get returns the current build metadata.
+func Get() Info {
+	return Info{Version: Version, Commit: Commit, Date: Date}
+}
+
+// String formats build metadata for `<binary> version`.
+func String() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", Version, Commit, Date)
+}
@@ -3,10 +3,16 @@ package wsadapter
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"sync"
 
 	"nhooyr.io/websocket"
+
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
 )
 
 // outMsg wraps a WebSocket message with its type (text or binary).
@@ -15,22 +21,33 @@ type outMsg struct {
 	data []byte
 }
 
+// errReadOnlyMirror refuses input from a client mirroring the agent.
+var errReadOnlyMirror = errors.New("subscribed as a read-only mirror; subscribe without mirror to send input")
+
 // outputSub tracks a pipe-pane subscription by ID and channel.
 type outputSub struct {
-	id int
-	ch <-chan []byte
+	id     int
+	ch     <-chan []byte
+	mirror bool        // read-only mirror: filtered output, no input
+	label  *agentLabel // agent name the frames carry
 }
 
 // Client represents a single WebSocket connection.
 type Client struct {
-	conn       *websocket.Conn
-	server     *Server
-	send       chan outMsg
-	agentSub   bool                     // subscribed to agent lifecycle
-	outputSubs map[string]outputSub     // agent name -> subscription
-	mu         sync.Mutex
-	ctx        context.Context
-	cancel     context.CancelFunc
+	id          string // "client-N", shown to others as the holder of input control and prefixed to its log lines
+	info        wsbase.ConnInfo
+	conn        *websocket.Conn
+	server      *Server
+	send        chan outMsg
+	agentSub    bool                  // subscribed to agent lifecycle
+	agentFilter *wsbase.NameFilter    // agents the lifecycle subscription covers
+	outputSubs  map[string]outputSub  // agent name -> subscription
+	windowSubs  map[string]*windowSub // agent name -> window subscription
+	tmuxEvents  *tmuxEventSub         // raw tmux notifications, if subscribed
+	grant       *wsbase.GrantHolder   // what the connection's credentials allow
+	mu          sync.Mutex
+	ctx         context.Context
+	cancel      context.CancelFunc
 }
 
 // NewClient creates a new WebSocket client.
@@ -40,6 +57,7 @@ func NewClient(conn *websocket.Conn, server *Server, ctx context.Context, cancel
 		server:     server,
 		send:       make(chan outMsg, 256),
 		outputSubs: make(map[string]outputSub),
+		windowSubs: make(map[string]*windowSub),
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -48,6 +66,7 @@ func NewClient(conn *websocket.Conn, server *Server, ctx context.Context, cancel
 // ReadPump reads messages from the WebSocket and routes them to handlers.
 func (c *Client) ReadPump() {
 	defer c.cancel()
+	defer crash.Recover("%s: read pump", c.id)
 
 	for {
 		typ, data, err := c.conn.Read(c.ctx)
@@ -60,6 +79,11 @@ func (c *Client) ReadPump() {
 			continue
 		}
 
+		if lerr := c.server.limits.CheckMessage(len(data)); lerr != nil {
+			c.sendLimit("", lerr)
+			continue
+		}
+
 		var req Request
 		if err := json.Unmarshal(data, &req); err != nil {
 			c.sendError("", "invalid JSON: "+err.Error())
@@ -73,6 +97,7 @@ func (c *Client) ReadPump() {
 // WritePump writes queued messages to the WebSocket and streams output subscriptions.
 func (c *Client) WritePump() {
 	defer c.cancel()
+	defer crash.Recover("%s: write pump", c.id)
 
 	for {
 		select {
@@ -89,12 +114,17 @@ func (c *Client) WritePump() {
 	}
 }
 
+// logf logs a line about this connection, prefixed with its ID.
+func (c *Client) logf(format string, args ...any) {
+	log.Printf("%s: %s", c.id, fmt.Sprintf(format, args...))
+}
+
 // SendText queues a text message for sending to this client.
 func (c *Client) SendText(msg []byte) {
 	select {
 	case c.send <- outMsg{typ: websocket.MessageText, data: msg}:
 	default:
-		log.Printf("dropping message for slow client")
+		c.logf("dropping message for slow client")
 	}
 }
 
@@ -103,7 +133,7 @@ func (c *Client) SendBinary(data []byte) {
 	select {
 	case c.send <- outMsg{typ: websocket.MessageBinary, data: data}:
 	default:
-		log.Printf("dropping binary message for slow client")
+		c.logf("dropping binary message for slow client")
 	}
 }
 
@@ -111,12 +141,19 @@ func (c *Client) SendBinary(data []byte) {
 func (c *Client) sendJSON(v any) {
 	data, err := json.Marshal(v)
 	if err != nil {
-		log.Printf("marshal error: %v", err)
+		c.logf("marshal error: %v", err)
 		return
 	}
 	c.SendText(data)
 }
 
+// sendTmuxError is sendError for a failure that may have come from tmux,
+// adding its machine-readable code.
+func (c *Client) sendTmuxError(id, agentName, errMsg string, err error) {
+	ok := false
+	c.sendJSON(Response{ID: id, Type: "error", OK: &ok, Name: agentName, Error: errMsg, Code: tmux.ErrorCode(err)})
+}
+
 // sendError sends an error response.
 func (c *Client) sendError(id, errMsg string) {
 	ok := false
@@ -131,19 +168,104 @@ func (c *Client) sendError(id, errMsg string) {
 	c.sendJSON(resp)
 }
 
+// sendLimit refuses a request that would exceed a per-connection limit.
+func (c *Client) sendLimit(id string, lerr *wsbase.LimitError) {
+	ok := false
+	c.sendJSON(Response{ID: id, Type: "error", OK: &ok, Error: lerr.Error(), Limit: lerr})
+}
+
+// leaveAgent drops this client as a viewer of agentName and announces it.
+func (c *Client) leaveAgent(agentName string) {
+	if count, left := c.server.presence.Leave(agentName, c); left {
+		c.server.broadcastViewers("viewer-left", agentName, count)
+	}
+}
+
+// isMirror reports whether the client streams agentName as a read-only mirror.
+func (c *Client) isMirror(agentName string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.outputSubs[agentName].mirror
+}
+
+// checkInput returns why the client may not send input to agentName, or nil.
+func (c *Client) checkInput(agentName string) error {
+	if c.isMirror(agentName) {
+		return errReadOnlyMirror
+	}
+	return c.grant.CheckInput(c.server.control, agentName, c)
+}
+
+// goAgentWork runs fn on a new goroutine holding agentName's prompter lock.
+// Work still queued for the lock when the client disconnects is dropped;
+// work already running finishes, so a pane is never left with a half-typed
+// prompt.
+func (c *Client) goAgentWork(agentName, what string, fn func()) {
+	lock := c.server.prompter.GetLock(agentName)
+	go func() {
+		defer crash.Recover("%s: %s for %s", c.id, what, agentName)
+		lock.Lock()
+		defer lock.Unlock()
+		if c.ctx.Err() != nil {
+			c.logf("dropped %s for %s: client disconnected", what, agentName)
+			return
+		}
+		fn()
+	}()
+}
+
+// watchGrantExpiry closes the connection, or drops it to read-only, when
+// its token expires.
+func (c *Client) watchGrantExpiry() {
+	c.grant.WatchExpiry(c.ctx, c.server.auth.OnExpiry(), func(readOnly bool) {
+		if !readOnly {
+			c.logf("token expired, closing")
+			_ = c.conn.Close(websocket.StatusPolicyViolation, "token expired")
+			return
+		}
+		c.logf("token expired, now read-only")
+		for _, agentName := range c.server.control.ReleaseAll(c) {
+			c.server.broadcastControl(agentName, "")
+		}
+		c.sendJSON(Response{Type: "auth-expired", Error: "token expired; connection is read-only"})
+	})
+}
+
 // Close cleans up all subscriptions and closes the connection.
 func (c *Client) Close() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 
 	// Unsubscribe from all output streams
 	for session, sub := range c.outputSubs {
 		c.server.pipeMgr.Unsubscribe(session, sub.id)
 		delete(c.outputSubs, session)
 	}
+	for agentName, ws := range c.windowSubs {
+		ws.stop(c.server.pipeMgr)
+		delete(c.windowSubs, agentName)
+	}
+	if c.tmuxEvents != nil {
+		c.server.ctrl.UnsubscribeEvents(c.tmuxEvents.id)
+		c.tmuxEvents = nil
+	}
 
 	c.agentSub = false
 	if err := c.conn.Close(websocket.StatusNormalClosure, ""); err != nil {
-		log.Printf("client close websocket: %v", err)
+		c.logf("close websocket: %v", err)
+	}
+	c.mu.Unlock()
+
+	// Broadcasting locks every client, so announce departures after releasing ours.
+	for agentName, count := range c.server.presence.LeaveAll(c) {
+		c.server.broadcastViewers("viewer-left", agentName, count)
+	}
+	for _, agentName := range c.server.control.ReleaseAll(c) {
+		c.server.broadcastControl(agentName, "")
+	}
+	c.server.prompter.ReleaseChunkedUploads(c)
+	for agentName, size := range c.server.resize.Forget(c) {
+		if err := c.server.applySize(agentName, size); err != nil {
+			c.logf("resize %s error: %v", agentName, err)
+		}
 	}
 }
@@ -1,37 +1,81 @@
 package wsadapter
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gastownhall/tmux-adapter/internal/agentio"
 	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
 )
 
 // Request is a message from a WebSocket client.
 type Request struct {
-	ID     string `json:"id"`
-	Type   string `json:"type"`
-	Agent  string `json:"agent,omitempty"`
-	Prompt string `json:"prompt,omitempty"`
-	Stream *bool  `json:"stream,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	Agent        string   `json:"agent,omitempty"`
+	Prompt       string   `json:"prompt,omitempty"`
+	Stream       *bool    `json:"stream,omitempty"`
+	PasteOnly    bool     `json:"pasteOnly,omitempty"`
+	Hold         *bool    `json:"hold,omitempty"`
+	Attachments  []string `json:"attachments,omitempty"`
+	Command      string   `json:"command,omitempty"`
+	Mirror       bool     `json:"mirror,omitempty"`
+	SortBy       string   `json:"sortBy,omitempty"`
+	Limit        int      `json:"limit,omitempty"`
+	Offset       int      `json:"offset,omitempty"`
+	Cursor       string   `json:"cursor,omitempty"`
+	AgentFilter  []string `json:"agentFilter,omitempty"`
+	FilterSyntax string   `json:"filterSyntax,omitempty"`
+	Events       []string `json:"events,omitempty"`
 }
 
 // Response is a message sent to a WebSocket client.
 type Response struct {
-	ID      string         `json:"id,omitempty"`
-	Type    string         `json:"type"`
-	OK      *bool          `json:"ok,omitempty"`
-	Error   string         `json:"error,omitempty"`
-	Agents  []agents.Agent `json:"agents,omitempty"`
-	History string         `json:"history,omitempty"`
-	Agent   *agents.Agent  `json:"agent,omitempty"`
-	Name    string         `json:"name,omitempty"`
-	Data    string         `json:"data,omitempty"`
+	ID           string                  `json:"id,omitempty"`
+	Type         string                  `json:"type"`
+	OK           *bool                   `json:"ok,omitempty"`
+	Error        string                  `json:"error,omitempty"`
+	Code         string                  `json:"code,omitempty"` // tmux.ErrorCode of the failure, e.g. session-not-found
+	Agents       []AgentView             `json:"agents,omitempty"`
+	Total        *int                    `json:"total,omitempty"`
+	NextCursor   string                  `json:"nextCursor,omitempty"`
+	History      string                  `json:"history,omitempty"`
+	Agent        *agents.Agent           `json:"agent,omitempty"`
+	Name         string                  `json:"name,omitempty"`
+	Data         string                  `json:"data,omitempty"`
+	ViewerCount  *int                    `json:"viewerCount,omitempty"`
+	ControlledBy string                  `json:"controlledBy,omitempty"`
+	Upload       *agentio.UploadProgress `json:"upload,omitempty"`
+	FileID       string                  `json:"fileId,omitempty"`
+	Rejection    *agentio.Rejection      `json:"rejection,omitempty"`
+	Layout       *tmux.WindowLayout      `json:"layout,omitempty"`
+	Size         *agentio.TermSize       `json:"size,omitempty"`
+	Limit        *wsbase.LimitError      `json:"limit,omitempty"`
+	Reason       string                  `json:"reason,omitempty"`
+	Events       []string                `json:"events,omitempty"`
+	Event        *tmux.Event             `json:"event,omitempty"`
+	OldName      string                  `json:"oldName,omitempty"`
+	Verification string                  `json:"verification,omitempty"`
+	PasteOnly    bool                    `json:"pasteOnly,omitempty"`
+	Held         *bool                   `json:"held,omitempty"`
+	Prompts      []agentio.PromptRecord  `json:"prompts,omitempty"`
+	Actions      []agentio.QuickAction   `json:"actions,omitempty"`
+}
+
+// AgentView is an agent as listed to clients, with the number of clients
+// currently streaming its output.
+type AgentView struct {
+	agents.Agent
+	ViewerCount  int    `json:"viewerCount"`
+	ControlledBy string `json:"controlledBy,omitempty"` // client holding input control
 }
 
 // handleMessage routes a text request to the appropriate handler.
@@ -41,14 +85,36 @@ func handleMessage(c *Client, req Request) {
 		handleListAgents(c, req)
 	case "send-prompt":
 		handleSendPrompt(c, req)
+	case "run-command":
+		handleRunCommand(c, req)
+	case "set-prompt-hold":
+		handleSetPromptHold(c, req)
+	case "confirm-prompt":
+		handleConfirmPrompt(c, req)
+	case "get-prompt-history":
+		handleGetPromptHistory(c, req)
+	case "list-actions":
+		handleListActions(c, req)
 	case "subscribe-output":
 		handleSubscribeOutput(c, req)
 	case "unsubscribe-output":
 		handleUnsubscribeOutput(c, req)
+	case "subscribe-window":
+		handleSubscribeWindow(c, req)
+	case "unsubscribe-window":
+		handleUnsubscribeWindow(c, req)
 	case "subscribe-agents":
 		handleSubscribeAgents(c, req)
 	case "unsubscribe-agents":
 		handleUnsubscribeAgents(c, req)
+	case "subscribe-tmux-events":
+		handleSubscribeTmuxEvents(c, req)
+	case "unsubscribe-tmux-events":
+		handleUnsubscribeTmuxEvents(c, req)
+	case "acquire-control":
+		handleAcquireControl(c, req)
+	case "release-control":
+		handleReleaseControl(c, req)
 	default:
 		c.sendError(req.ID, "unknown message type: "+req.Type)
 	}
@@ -63,13 +129,24 @@ func handleBinaryMessage(c *Client, data []byte) {
 		return
 	}
 
+	switch msgType {
+	case agentio.BinaryKeyboardInput, agentio.BinaryFileUpload, agentio.BinaryFileAttach, agentio.BinaryArchiveUpload, agentio.BinaryUploadBegin, agentio.BinaryUploadChunk, agentio.BinaryUploadCommit:
+		if err := c.checkInput(agentName); err != nil {
+			c.sendError("", err.Error())
+			return
+		}
+	}
+
 	switch msgType {
 	case agentio.BinaryKeyboardInput:
 		if err := sendKeyboardPayload(c, agentName, payload); err != nil {
-			log.Printf("keyboard input %s error: %v", agentName, err)
-			c.sendError("", "keyboard input "+agentName+": "+err.Error())
+			c.logf("keyboard input %s error: %v", agentName, err)
+			c.sendTmuxError("", agentName, "keyboard input "+agentName+": "+err.Error(), err)
 		}
 	case agentio.BinaryResize:
+		if c.isMirror(agentName) {
+			return // mirrors follow the size the drivers set
+		}
 		parts := strings.SplitN(string(payload), ":", 2)
 		if len(parts) != 2 {
 			c.sendError("", "invalid resize payload for "+agentName+": expected cols:rows")
@@ -85,31 +162,75 @@ func handleBinaryMessage(c *Client, data []byte) {
 			c.sendError("", fmt.Sprintf("invalid resize payload for %s: %dx%d out of range", agentName, cols, rows))
 			return
 		}
-		log.Printf("binary resize %s -> %dx%d", agentName, cols, rows)
-		if err := c.server.ctrl.ResizePaneTo(agentName, cols, rows); err != nil {
-			log.Printf("resize %s error: %v", agentName, err)
-			c.sendError("", "resize "+agentName+": "+err.Error())
+		size, apply := c.server.resize.Request(agentName, c, agentio.TermSize{Cols: cols, Rows: rows})
+		if !apply {
+			// Arbitration kept another size; tell this client what it is.
+			if size.Cols > 0 {
+				c.sendJSON(Response{Type: "terminal-size", Name: agentName, Size: &size})
+			}
+			return
+		}
+		if err := c.server.applySize(agentName, size); err != nil {
+			c.logf("resize %s error: %v", agentName, err)
+			c.sendTmuxError("", agentName, "resize "+agentName+": "+err.Error(), err)
 			return
 		}
 		// No snapshot needed — pipe-pane captures the app's SIGWINCH redraw naturally.
-	case agentio.BinaryFileUpload:
+	case agentio.BinaryFileUpload, agentio.BinaryFileAttach, agentio.BinaryArchiveUpload:
+		handle := c.server.prompter.HandleFileUpload
+		if msgType == agentio.BinaryArchiveUpload {
+			handle = c.server.prompter.HandleArchiveUpload
+		}
+		paste := msgType != agentio.BinaryFileAttach
 		payloadCopy := append([]byte(nil), payload...)
-		go func() {
-			lock := c.server.prompter.GetLock(agentName)
-			lock.Lock()
-			defer lock.Unlock()
-
-			if err := c.server.prompter.HandleFileUpload(agentName, payloadCopy); err != nil {
-				log.Printf("file upload %s error: %v", agentName, err)
-				c.sendError("", "file upload "+agentName+": "+err.Error())
+		c.goAgentWork(agentName, "file upload", func() {
+			fileID, err := handle(agentName, payloadCopy, paste)
+			if err != nil {
+				c.logf("file upload %s error: %v", agentName, err)
+				var rejection *agentio.Rejection
+				errors.As(err, &rejection)
+				ok := false
+				c.sendJSON(Response{Type: "error", OK: &ok, Name: agentName, Error: "file upload " + agentName + ": " + err.Error(), Code: tmux.ErrorCode(err), Rejection: rejection})
+				return
 			}
-		}()
+			c.sendJSON(Response{Type: "file-uploaded", Name: agentName, FileID: fileID})
+		})
+	case agentio.BinaryUploadBegin:
+		progress, err := c.server.prompter.BeginChunkedUpload(agentName, c, payload)
+		sendUploadResult(c, "upload-progress", agentName, progress, err)
+	case agentio.BinaryUploadChunk:
+		progress, err := c.server.prompter.WriteUploadChunk(agentName, c, payload)
+		sendUploadResult(c, "upload-progress", agentName, progress, err)
+	case agentio.BinaryUploadCommit:
+		payloadCopy := append([]byte(nil), payload...)
+		c.goAgentWork(agentName, "upload commit", func() {
+			progress, err := c.server.prompter.CommitChunkedUpload(agentName, c, payloadCopy)
+			sendUploadResult(c, "upload-complete", agentName, progress, err)
+		})
 	default:
-		log.Printf("unknown binary message type: 0x%02x", msgType)
+		c.logf("unknown binary message type: 0x%02x", msgType)
 		c.sendError("", fmt.Sprintf("unknown binary message type: 0x%02x", msgType))
 	}
 }
 
+// sendUploadResult acks a chunked upload step, or reports the error that
+// stopped it along with any policy rejection.
+func sendUploadResult(c *Client, msgType, agentName string, progress agentio.UploadProgress, err error) {
+	var upload *agentio.UploadProgress
+	if progress.UploadID != "" {
+		upload = &progress
+	}
+	if err != nil {
+		c.logf("chunked upload %s error: %v", agentName, err)
+		var rejection *agentio.Rejection
+		errors.As(err, &rejection)
+		ok := false
+		c.sendJSON(Response{Type: "error", OK: &ok, Name: agentName, Error: "file upload " + agentName + ": " + err.Error(), Code: tmux.ErrorCode(err), Upload: upload, Rejection: rejection})
+		return
+	}
+	c.sendJSON(Response{Type: msgType, Name: agentName, Upload: upload})
+}
+
 func sendKeyboardPayload(c *Client, agentName string, payload []byte) error {
 	// Prefer tmux key names for known VT special-key sequences (e.g. Shift+Tab).
 	// Fall back to byte-exact injection for everything else.
@@ -176,20 +297,44 @@ func tmuxKeyNameFromVT(payload []byte) (string, bool) {
 }
 
 func handleListAgents(c *Client, req Request) {
-	agentList := c.server.registry.GetAgents()
+	if lerr := c.server.limits.CheckNameFilter(req.AgentFilter); lerr != nil {
+		c.sendLimit(req.ID, lerr)
+		return
+	}
+	filter, err := wsbase.ParseNameFilter(req.AgentFilter, req.FilterSyntax)
+	if err != nil {
+		c.sendError(req.ID, err.Error())
+		return
+	}
+	page, next, total, err := c.server.agentPage(req, filter)
+	if err != nil {
+		c.sendError(req.ID, err.Error())
+		return
+	}
 	c.sendJSON(Response{
-		ID:     req.ID,
-		Type:   "list-agents",
-		Agents: agentList,
+		ID:         req.ID,
+		Type:       "list-agents",
+		Agents:     page,
+		Total:      &total,
+		NextCursor: next,
 	})
 }
 
+// agentSorts orders agent lists by list-agents' sortBy values. The adapter
+// sees no conversation activity, so lastActivity is the converter's alone.
+var agentSorts = map[string]func(a, b AgentView) int{
+	"name": func(a, b AgentView) int { return strings.Compare(a.Name, b.Name) },
+	"runtime": func(a, b AgentView) int {
+		return cmp.Or(strings.Compare(a.Runtime, b.Runtime), strings.Compare(a.Name, b.Name))
+	},
+}
+
 func handleSendPrompt(c *Client, req Request) {
 	if req.Agent == "" {
 		c.sendError(req.ID, "agent field required")
 		return
 	}
-	if req.Prompt == "" {
+	if req.Prompt == "" && len(req.Attachments) == 0 {
 		c.sendError(req.ID, "prompt field required")
 		return
 	}
@@ -200,22 +345,140 @@ func handleSendPrompt(c *Client, req Request) {
 		c.sendJSON(Response{ID: req.ID, Type: "send-prompt", OK: &ok, Error: "agent not found"})
 		return
 	}
+	if err := c.checkInput(req.Agent); err != nil {
+		ok := false
+		c.sendJSON(Response{ID: req.ID, Type: "send-prompt", OK: &ok, Error: err.Error()})
+		return
+	}
+
+	c.goAgentWork(req.Agent, "send-prompt", func() {
+		prompt, err := c.server.prompter.ExpandAttachments(req.Agent, req.Prompt, req.Attachments)
+		var delivery agentio.PromptDelivery
+		if err == nil {
+			delivery, err = c.server.prompter.DeliverPrompt(req.Agent, prompt, req.PasteOnly, nil)
+		}
+		if err != nil {
+			var rejection *agentio.Rejection
+			errors.As(err, &rejection)
+			ok := false
+			c.sendJSON(Response{ID: req.ID, Type: "send-prompt", OK: &ok, Error: err.Error(), Code: tmux.ErrorCode(err), Rejection: rejection, Verification: delivery.Verification})
+			return
+		}
+
+		ok := true
+		resp := Response{ID: req.ID, Type: "send-prompt", OK: &ok, Verification: delivery.Verification, PasteOnly: delivery.PasteOnly}
+		if delivery.Held {
+			resp.Held = &delivery.Held
+		}
+		c.sendJSON(resp)
+	})
+}
+
+func handleSetPromptHold(c *Client, req Request) {
+	if req.Agent == "" {
+		c.sendError(req.ID, "agent field required")
+		return
+	}
+	if req.Hold == nil {
+		c.sendError(req.ID, "hold field required")
+		return
+	}
+	if err := c.checkInput(req.Agent); err != nil {
+		ok := false
+		c.sendJSON(Response{ID: req.ID, Type: "set-prompt-hold", OK: &ok, Error: err.Error()})
+		return
+	}
+	c.server.prompter.Holds.SetHeld(req.Agent, *req.Hold)
+	ok := true
+	c.sendJSON(Response{ID: req.ID, Type: "set-prompt-hold", OK: &ok, Name: req.Agent, Held: req.Hold})
+}
+
+func handleConfirmPrompt(c *Client, req Request) {
+	if req.Agent == "" {
+		c.sendError(req.ID, "agent field required")
+		return
+	}
+	if err := c.checkInput(req.Agent); err != nil {
+		ok := false
+		c.sendJSON(Response{ID: req.ID, Type: "confirm-prompt", OK: &ok, Error: err.Error()})
+		return
+	}
+
+	c.goAgentWork(req.Agent, "confirm-prompt", func() {
+		delivery, err := c.server.prompter.ConfirmPrompt(req.Agent)
+		if err != nil {
+			ok := false
+			c.sendJSON(Response{ID: req.ID, Type: "confirm-prompt", OK: &ok, Error: err.Error(), Code: tmux.ErrorCode(err), Verification: delivery.Verification})
+			return
+		}
+		ok := true
+		c.sendJSON(Response{ID: req.ID, Type: "confirm-prompt", OK: &ok, Verification: delivery.Verification})
+	})
+}
+
+func handleGetPromptHistory(c *Client, req Request) {
+	if req.Agent == "" {
+		c.sendError(req.ID, "agent field required")
+		return
+	}
+	prompts, next, err := c.server.prompter.History.Page(req.Agent, req.Limit, req.Cursor)
+	if err != nil {
+		ok := false
+		c.sendJSON(Response{ID: req.ID, Type: "get-prompt-history", OK: &ok, Name: req.Agent, Error: err.Error()})
+		return
+	}
+	ok := true
+	c.sendJSON(Response{ID: req.ID, Type: "get-prompt-history", OK: &ok, Name: req.Agent, Prompts: prompts, NextCursor: next})
+}
 
-	lock := c.server.prompter.GetLock(req.Agent)
+// handleListActions sends the configured quick actions: all of them as
+// configured, or with an agent, those selecting it with prompts rendered
+// for it.
+func handleListActions(c *Client, req Request) {
+	actions := c.server.actions.List()
+	if req.Agent != "" {
+		agent, ok := c.server.registry.GetAgent(req.Agent)
+		if !ok {
+			ok := false
+			c.sendJSON(Response{ID: req.ID, Type: "list-actions", OK: &ok, Name: req.Agent, Error: "agent not found"})
+			return
+		}
+		actions = c.server.actions.For(agent)
+	}
+	ok := true
+	c.sendJSON(Response{ID: req.ID, Type: "list-actions", OK: &ok, Name: req.Agent, Actions: actions})
+}
 
-	go func() {
-		lock.Lock()
-		defer lock.Unlock()
+func handleRunCommand(c *Client, req Request) {
+	if req.Agent == "" {
+		c.sendError(req.ID, "agent field required")
+		return
+	}
+	if req.Command == "" {
+		c.sendError(req.ID, "command field required")
+		return
+	}
+	if _, ok := c.server.registry.GetAgent(req.Agent); !ok {
+		ok := false
+		c.sendJSON(Response{ID: req.ID, Type: "run-command", OK: &ok, Error: "agent not found"})
+		return
+	}
+	if err := c.checkInput(req.Agent); err != nil {
+		ok := false
+		c.sendJSON(Response{ID: req.ID, Type: "run-command", OK: &ok, Error: err.Error()})
+		return
+	}
 
-		if err := c.server.prompter.SendPrompt(req.Agent, req.Prompt); err != nil {
+	c.goAgentWork(req.Agent, "run-command", func() {
+		if err := c.server.prompter.RunCommand(req.Agent, req.Command); err != nil {
 			ok := false
-			c.sendJSON(Response{ID: req.ID, Type: "send-prompt", OK: &ok, Error: err.Error()})
+			c.sendJSON(Response{ID: req.ID, Type: "run-command", OK: &ok, Error: err.Error(), Code: tmux.ErrorCode(err)})
 			return
 		}
 
 		ok := true
-		c.sendJSON(Response{ID: req.ID, Type: "send-prompt", OK: &ok})
-	}()
+		c.sendJSON(Response{ID: req.ID, Type: "run-command", OK: &ok})
+	})
 }
 
 func handleSubscribeOutput(c *Client, req Request) {
@@ -241,26 +504,39 @@ func handleSubscribeOutput(c *Client, req Request) {
 		oldSub, hadOld := c.outputSubs[req.Agent]
 		if hadOld {
 			delete(c.outputSubs, req.Agent)
+		} else if lerr := c.server.limits.CheckSubscriptions(len(c.outputSubs) + len(c.windowSubs)); lerr != nil {
+			c.mu.Unlock()
+			c.sendLimit(req.ID, lerr)
+			return
 		}
 		c.mu.Unlock()
 		if hadOld {
-			log.Printf("subscribe-output(%s): replacing existing subscription", req.Agent)
+			c.logf("subscribe-output(%s): replacing existing subscription", req.Agent)
 			c.server.pipeMgr.Unsubscribe(req.Agent, oldSub.id)
 		}
 
 		// Subscribe to pipe-pane first so it's ready for ongoing streaming.
-		log.Printf("subscribe-output(%s): starting pipe-pane", req.Agent)
+		c.logf("subscribe-output(%s): starting pipe-pane", req.Agent)
 		subID, ch, err := c.server.pipeMgr.Subscribe(req.Agent)
 		if err != nil {
-			log.Printf("subscribe-output(%s): pipe-pane error: %v", req.Agent, err)
+			c.logf("subscribe-output(%s): pipe-pane error: %v", req.Agent, err)
+			if hadOld {
+				c.leaveAgent(req.Agent)
+			}
 			okVal := false
-			c.sendJSON(Response{ID: req.ID, Type: "subscribe-output", OK: &okVal, Error: err.Error()})
+			c.sendJSON(Response{ID: req.ID, Type: "subscribe-output", OK: &okVal, Error: err.Error(), Code: tmux.ErrorCode(err)})
 			return
 		}
-		log.Printf("subscribe-output(%s): pipe-pane active", req.Agent)
+		c.logf("subscribe-output(%s): pipe-pane active", req.Agent)
+		if !hadOld {
+			if count, joined := c.server.presence.Join(req.Agent, c); joined {
+				c.server.broadcastViewers("viewer-joined", req.Agent, count)
+			}
+		}
 
+		label := newAgentLabel(req.Agent)
 		c.mu.Lock()
-		c.outputSubs[req.Agent] = outputSub{id: subID, ch: ch}
+		c.outputSubs[req.Agent] = outputSub{id: subID, ch: ch, mirror: req.Mirror, label: label}
 		c.mu.Unlock()
 
 		okVal := true
@@ -286,12 +562,12 @@ func handleSubscribeOutput(c *Client, req Request) {
 			}
 		}
 		if drained > 0 {
-			log.Printf("subscribe-output(%s): drained %d pre-redraw chunks", req.Agent, drained)
+			c.logf("subscribe-output(%s): drained %d pre-redraw chunks", req.Agent, drained)
 		}
 
 		// Force a clean redraw. The resize dance triggers SIGWINCH, causing
 		// the app to repaint. pipe-pane captures all output in real-time.
-		log.Printf("subscribe-output(%s): forcing redraw", req.Agent)
+		c.logf("subscribe-output(%s): forcing redraw", req.Agent)
 		c.server.ctrl.ForceRedraw(req.Agent)
 
 		// Let the app finish redrawing; pipe-pane buffers all output in ch.
@@ -299,13 +575,19 @@ func handleSubscribeOutput(c *Client, req Request) {
 
 		// Send a minimal 0x05 (clear screen) to trigger the client's reset+reveal.
 		// The actual content comes from pipe-pane data buffered in ch.
-		log.Printf("subscribe-output(%s): sending 0x05 clear-screen trigger", req.Agent)
+		c.logf("subscribe-output(%s): sending 0x05 clear-screen trigger", req.Agent)
 		c.SendBinary(agentio.MakeBinaryFrame(agentio.BinaryTerminalSnapshot, req.Agent, []byte("\x1b[2J\x1b[H")))
 
+		if req.Mirror {
+			go c.streamMirror(label, ch)
+			return
+		}
+
 		// Stream raw bytes in background — immediately flushes buffered pipe-pane data.
 		go func() {
+			defer crash.Recover("%s: output stream for %s", c.id, req.Agent)
 			for rawBytes := range ch {
-				c.SendBinary(agentio.MakeBinaryFrame(agentio.BinaryTerminalOutput, req.Agent, rawBytes))
+				c.SendBinary(agentio.MakeBinaryFrame(agentio.BinaryTerminalOutput, label.get(), rawBytes))
 			}
 		}()
 	} else {
@@ -336,6 +618,7 @@ func handleUnsubscribeOutput(c *Client, req Request) {
 
 	if exists {
 		c.server.pipeMgr.Unsubscribe(req.Agent, sub.id)
+		c.leaveAgent(req.Agent)
 	}
 
 	okVal := true
@@ -343,17 +626,33 @@ func handleUnsubscribeOutput(c *Client, req Request) {
 }
 
 func handleSubscribeAgents(c *Client, req Request) {
+	if lerr := c.server.limits.CheckNameFilter(req.AgentFilter); lerr != nil {
+		c.sendLimit(req.ID, lerr)
+		return
+	}
+	filter, err := wsbase.ParseNameFilter(req.AgentFilter, req.FilterSyntax)
+	if err != nil {
+		c.sendError(req.ID, err.Error())
+		return
+	}
+	page, next, total, err := c.server.agentPage(req, filter)
+	if err != nil {
+		c.sendError(req.ID, err.Error())
+		return
+	}
 	c.mu.Lock()
 	c.agentSub = true
+	c.agentFilter = filter
 	c.mu.Unlock()
 
-	agentList := c.server.registry.GetAgents()
 	okVal := true
 	c.sendJSON(Response{
-		ID:     req.ID,
-		Type:   "subscribe-agents",
-		OK:     &okVal,
-		Agents: agentList,
+		ID:         req.ID,
+		Type:       "subscribe-agents",
+		OK:         &okVal,
+		Agents:     page,
+		Total:      &total,
+		NextCursor: next,
 	})
 }
 
@@ -366,16 +665,59 @@ func handleUnsubscribeAgents(c *Client, req Request) {
 	c.sendJSON(Response{ID: req.ID, Type: "unsubscribe-agents", OK: &okVal})
 }
 
+func handleAcquireControl(c *Client, req Request) {
+	if req.Agent == "" {
+		c.sendError(req.ID, "agent field required")
+		return
+	}
+	if _, ok := c.server.registry.GetAgent(req.Agent); !ok {
+		okVal := false
+		c.sendJSON(Response{ID: req.ID, Type: "acquire-control", OK: &okVal, Error: "agent not found"})
+		return
+	}
+	if !c.grant.Get().Control {
+		okVal := false
+		c.sendJSON(Response{ID: req.ID, Type: "acquire-control", OK: &okVal, Error: wsbase.ErrControlNotAllowed.Error()})
+		return
+	}
+
+	holder, acquired := c.server.control.Acquire(req.Agent, c, c.id)
+	if !acquired {
+		okVal := false
+		c.sendJSON(Response{ID: req.ID, Type: "acquire-control", OK: &okVal, ControlledBy: holder, Error: req.Agent + " is already controlled by " + holder})
+		return
+	}
+	okVal := true
+	c.sendJSON(Response{ID: req.ID, Type: "acquire-control", OK: &okVal, ControlledBy: holder})
+	c.server.broadcastControl(req.Agent, holder)
+}
+
+func handleReleaseControl(c *Client, req Request) {
+	if req.Agent == "" {
+		c.sendError(req.ID, "agent field required")
+		return
+	}
+	released := c.server.control.Release(req.Agent, c)
+	okVal := true
+	c.sendJSON(Response{ID: req.ID, Type: "release-control", OK: &okVal})
+	if released {
+		c.server.broadcastControl(req.Agent, "")
+	}
+}
+
 // MakeAgentEvent creates a JSON event message for agent lifecycle changes.
-func MakeAgentEvent(eventType string, agent agents.Agent) []byte {
+func MakeAgentEvent(event agents.RegistryEvent) []byte {
+	agent := event.Agent
 	var resp Response
-	switch eventType {
+	switch event.Type {
 	case "added":
 		resp = Response{Type: "agent-added", Agent: &agent}
 	case "removed":
-		resp = Response{Type: "agent-removed", Name: agent.Name}
+		resp = Response{Type: "agent-removed", Name: agent.Name, Reason: event.Reason}
 	case "updated":
 		resp = Response{Type: "agent-updated", Agent: &agent}
+	case "renamed":
+		resp = Response{Type: "agent-renamed", OldName: event.OldName, Agent: &agent}
 	}
 	data, _ := json.Marshal(resp)
 	return data
@@ -0,0 +1,156 @@
+package wsadapter
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agentio"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+)
+
+// mirrorInterval is how long a read-only mirror batches output. Typing
+// echoes a character, moves the cursor, and redraws the prompt in separate
+// writes; batching lets the viewer paint only the settled result.
+const mirrorInterval = 150 * time.Millisecond
+
+// hideCursor starts every mirror stream: with the cursor hidden, another
+// driver's cursor jumps are invisible, so the filter can hold them back.
+const hideCursor = "\x1b[?25l"
+
+// maxMirrorPending bounds output held back as cursor-only.
+const maxMirrorPending = 64 * 1024
+
+// mirrorFilter reduces pipe-pane output to what a passive viewer needs.
+// It strips cursor show/hide toggles and holds output that only moves the
+// cursor until something visible follows, so the moves are applied in the
+// same frame as the content they position.
+type mirrorFilter struct {
+	pending []byte
+}
+
+// Write queues a chunk of raw pane output.
+func (m *mirrorFilter) Write(chunk []byte) {
+	m.pending = append(m.pending, chunk...)
+}
+
+// Flush returns the queued output ready to send, or nil if it would not
+// change what the viewer sees yet. An escape sequence split across chunks
+// stays queued until it is complete.
+func (m *mirrorFilter) Flush() []byte {
+	out, n, visible := scanMirror(m.pending)
+	if !visible && len(m.pending) < maxMirrorPending {
+		return nil
+	}
+	m.pending = append([]byte(nil), m.pending[n:]...)
+	return out
+}
+
+// scanMirror walks the complete escape sequences and bytes at the start of
+// b. It returns them with cursor visibility toggles removed, how many bytes
+// of b they span, and whether any of them changes the screen.
+func scanMirror(b []byte) (out []byte, n int, visible bool) {
+	out = make([]byte, 0, len(b))
+	i := 0
+	for i < len(b) {
+		c := b[i]
+		if c != 0x1b {
+			switch c {
+			case '\r', '\b', 0x07: // cursor motion and bell
+			default:
+				visible = true
+			}
+			out = append(out, c)
+			i++
+			continue
+		}
+
+		end, kind := escapeEnd(b[i:])
+		if kind == escIncomplete {
+			break
+		}
+		seq := b[i : i+end]
+		switch kind {
+		case escCursorToggle:
+			// dropped: the mirror keeps the cursor hidden
+		case escCursorMove, escInvisible:
+			out = append(out, seq...)
+		default:
+			out = append(out, seq...)
+			visible = true
+		}
+		i += end
+	}
+	return out, i, visible
+}
+
+type escKind int
+
+const (
+	escIncomplete escKind = iota
+	escVisible
+	escCursorMove
+	escCursorToggle
+	escInvisible // OSC window titles and the like
+)
+
+// escapeEnd returns the length of the escape sequence at the start of b
+// (which begins with ESC) and what it does.
+func escapeEnd(b []byte) (int, escKind) {
+	if len(b) < 2 {
+		return 0, escIncomplete
+	}
+	switch b[1] {
+	case '[':
+		for j := 2; j < len(b); j++ {
+			if b[j] < 0x40 || b[j] > 0x7e {
+				continue
+			}
+			params := b[2:j]
+			switch {
+			case (b[j] == 'h' || b[j] == 'l') && bytes.Equal(params, []byte("?25")):
+				return j + 1, escCursorToggle
+			case bytes.IndexByte([]byte("ABCDEFGHfdsu"), b[j]) >= 0 && bytes.IndexByte(params, '?') < 0:
+				return j + 1, escCursorMove
+			}
+			return j + 1, escVisible
+		}
+		return 0, escIncomplete
+	case ']':
+		for j := 2; j < len(b); j++ {
+			if b[j] == 0x07 {
+				return j + 1, escInvisible
+			}
+			if b[j] == 0x1b && j+1 < len(b) && b[j+1] == '\\' {
+				return j + 2, escInvisible
+			}
+		}
+		return 0, escIncomplete
+	case '7', '8':
+		return 2, escCursorMove
+	}
+	return 2, escVisible
+}
+
+// streamMirror forwards ch to the client through a mirrorFilter until ch
+// closes.
+func (c *Client) streamMirror(label *agentLabel, ch <-chan []byte) {
+	defer crash.Recover("%s: mirror stream for %s", c.id, label.get())
+	c.SendBinary(agentio.MakeBinaryFrame(agentio.BinaryTerminalOutput, label.get(), []byte(hideCursor)))
+
+	var filter mirrorFilter
+	ticker := time.NewTicker(mirrorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case rawBytes, ok := <-ch:
+			if !ok {
+				return
+			}
+			filter.Write(rawBytes)
+		case <-ticker.C:
+			if out := filter.Flush(); len(out) > 0 {
+				c.SendBinary(agentio.MakeBinaryFrame(agentio.BinaryTerminalOutput, label.get(), out))
+			}
+		}
+	}
+}
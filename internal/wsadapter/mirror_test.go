@@ -0,0 +1,42 @@
+package wsadapter
+
+import "testing"
+
+func TestMirrorFilterHoldsCursorOnlyOutput(t *testing.T) {
+	var m mirrorFilter
+
+	m.Write([]byte("\x1b[?25h\x1b[12;5H"))
+	if out := m.Flush(); out != nil {
+		t.Fatalf("cursor-only Flush = %q, want nil", out)
+	}
+
+	m.Write([]byte("x\x1b[?25l"))
+	if got, want := string(m.Flush()), "\x1b[12;5Hx"; got != want {
+		t.Fatalf("Flush = %q, want %q", got, want)
+	}
+	if len(m.pending) != 0 {
+		t.Fatalf("pending = %q after flush, want empty", m.pending)
+	}
+}
+
+func TestMirrorFilterKeepsSplitEscape(t *testing.T) {
+	var m mirrorFilter
+
+	m.Write([]byte("ok\x1b[3"))
+	if got := string(m.Flush()); got != "ok" {
+		t.Fatalf("Flush = %q, want %q", got, "ok")
+	}
+	m.Write([]byte("1mred"))
+	if got, want := string(m.Flush()), "\x1b[31mred"; got != want {
+		t.Fatalf("Flush = %q, want %q", got, want)
+	}
+}
+
+func TestMirrorFilterTitleIsInvisible(t *testing.T) {
+	var m mirrorFilter
+
+	m.Write([]byte("\x1b]0;claude\x07\r"))
+	if out := m.Flush(); out != nil {
+		t.Fatalf("title-only Flush = %q, want nil", out)
+	}
+}
@@ -0,0 +1,65 @@
+package wsadapter
+
+import (
+	"sync/atomic"
+
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+)
+
+// agentLabel is the agent name a stream's frames carry. Renaming the
+// agent's session updates it, so streams already running switch names.
+type agentLabel struct {
+	name atomic.Pointer[string]
+}
+
+func newAgentLabel(name string) *agentLabel {
+	l := &agentLabel{}
+	l.set(name)
+	return l
+}
+
+func (l *agentLabel) get() string     { return *l.name.Load() }
+func (l *agentLabel) set(name string) { l.name.Store(&name) }
+
+// RenameAgent moves everything held under oldName to agent's new name after
+// its tmux session was renamed: output and window streams, viewers, input
+// control, and resize claims. Streams keep running; their frames carry the
+// new name from then on. Lifecycle subscribers of either name get
+// agent-renamed.
+func (s *Server) RenameAgent(oldName string, agent agents.Agent) {
+	newName := agent.Name
+	s.pipeMgr.Rename(oldName, newName)
+	s.presence.Rename(oldName, newName)
+	s.control.Rename(oldName, newName)
+	s.resize.Rename(oldName, newName)
+
+	data := MakeAgentEvent(agents.RegistryEvent{Type: "renamed", Agent: agent, OldName: oldName})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.clients {
+		client.renameAgent(oldName, newName)
+
+		client.mu.Lock()
+		subscribed := client.agentSub && (client.agentFilter.Matches(oldName) || client.agentFilter.Matches(newName))
+		client.mu.Unlock()
+		if subscribed {
+			client.SendText(data)
+		}
+	}
+}
+
+// renameAgent moves the client's subscriptions to oldName over to newName.
+func (c *Client) renameAgent(oldName, newName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sub, ok := c.outputSubs[oldName]; ok {
+		delete(c.outputSubs, oldName)
+		sub.label.set(newName)
+		c.outputSubs[newName] = sub
+	}
+	if ws, ok := c.windowSubs[oldName]; ok {
+		delete(c.windowSubs, oldName)
+		ws.rename(oldName, newName)
+		c.windowSubs[newName] = ws
+	}
+}
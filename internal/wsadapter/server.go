@@ -1,11 +1,16 @@
 package wsadapter
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/gastownhall/tmux-adapter/internal/agentio"
 	"github.com/gastownhall/tmux-adapter/internal/agents"
@@ -19,33 +24,72 @@ type Server struct {
 	pipeMgr        *tmux.PipePaneManager
 	ctrl           *tmux.ControlMode
 	prompter       *agentio.Prompter
-	authToken      string
-	originPatterns []string
+	actions        *agentio.QuickActions
+	auth           *wsbase.Authenticator
+	allowedOrigins *wsbase.OriginPolicy
+	presence       *wsbase.Presence
+	control        *agentio.ControlLocks
+	resize         *agentio.ResizeArbiter
+	limits         wsbase.Limits
 	clients        map[*Client]struct{}
+	nextClientID   int
 	mu             sync.Mutex
 }
 
+// Options configures a Server. Nil policies allow everything.
+type Options struct {
+	Auth           *wsbase.Authenticator // checks every connection; nil leaves them open
+	AllowedOrigins *wsbase.OriginPolicy
+	PromptPolicy   *agentio.PromptPolicy // screens send-prompt requests
+	UploadPolicy   *agentio.UploadPolicy // screens file uploads
+	// Submit says how typed prompts are submitted to each runtime, and Holds
+	// which agents' prompts wait for confirm-prompt.
+	Submit  agentio.SubmitStrategies
+	Holds   *agentio.PromptHolds
+	History *agentio.PromptHistory // records the prompts sent to each agent
+	Actions *agentio.QuickActions  // offered by list-actions
+	// ResizePolicy arbitrates resize frames from clients viewing the same agent.
+	ResizePolicy agentio.ResizePolicy
+	Limits       wsbase.Limits // caps what each connection may hold
+	// ControlIdleTimeout releases input control held this long without
+	// input from the holder; zero keeps it until released.
+	ControlIdleTimeout time.Duration
+}
+
 // NewServer creates a new WebSocket server.
-func NewServer(registry *agents.Registry, pipeMgr *tmux.PipePaneManager, ctrl *tmux.ControlMode, authToken string, originPatterns []string) *Server {
-	return &Server{
+func NewServer(registry *agents.Registry, pipeMgr *tmux.PipePaneManager, ctrl *tmux.ControlMode, opts Options) *Server {
+	control := agentio.NewControlLocks()
+	s := &Server{
 		registry:       registry,
 		pipeMgr:        pipeMgr,
 		ctrl:           ctrl,
-		prompter:       agentio.NewPrompter(ctrl, registry),
-		authToken:      strings.TrimSpace(authToken),
-		originPatterns: originPatterns,
+		prompter:       agentio.NewPrompter(ctrl, registry, opts.PromptPolicy, opts.UploadPolicy, opts.Submit, opts.Holds, opts.History),
+		actions:        opts.Actions,
+		auth:           opts.Auth,
+		allowedOrigins: opts.AllowedOrigins,
+		presence:       wsbase.NewPresence(),
+		control:        control,
+		resize:         agentio.NewResizeArbiter(opts.ResizePolicy, control),
+		limits:         opts.Limits,
 		clients:        make(map[*Client]struct{}),
 	}
+	control.SetIdleTimeout(opts.ControlIdleTimeout, func(agent string) {
+		log.Printf("control of %s released after %s idle", agent, opts.ControlIdleTimeout)
+		s.broadcastControl(agent, "")
+	})
+	return s
 }
 
 // ServeHTTP handles WebSocket upgrade requests at /ws.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if !wsbase.IsAuthorizedRequest(s.authToken, r) {
+	grant, err := s.auth.Authenticate(r)
+	if err != nil {
+		log.Printf("websocket unauthorized from %s: %v", r.RemoteAddr, err)
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	conn, err := wsbase.AcceptWebSocket(w, r, s.originPatterns)
+	conn, err := wsbase.AcceptWebSocket(w, r, s.allowedOrigins)
 	if err != nil {
 		return
 	}
@@ -53,30 +97,36 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	ctx, cancel := context.WithCancel(r.Context())
 	client := NewClient(conn, s, ctx, cancel)
+	client.grant = wsbase.NewGrantHolder(grant)
+	client.info = wsbase.NewConnInfo(r, conn, grant)
 
 	s.mu.Lock()
+	s.nextClientID++
+	client.id = fmt.Sprintf("client-%d", s.nextClientID)
 	s.clients[client] = struct{}{}
 	count := len(s.clients)
 	s.mu.Unlock()
 
-	log.Printf("client connected (%d total)", count)
+	client.logf("connected %s (%d total)", client.info, count)
 
 	// Run read/write pumps — blocks until client disconnects
 	go client.WritePump()
+	go client.watchGrantExpiry()
 	client.ReadPump()
 
 	// Cleanup on disconnect
 	s.RemoveClient(client)
 }
 
-// BroadcastToAgentSubscribers sends a message to all clients subscribed to agent lifecycle events.
-func (s *Server) BroadcastToAgentSubscribers(msg []byte) {
+// BroadcastToAgentSubscribers sends a message to all clients subscribed to
+// lifecycle events of the named agent.
+func (s *Server) BroadcastToAgentSubscribers(agentName string, msg []byte) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for client := range s.clients {
 		client.mu.Lock()
-		subscribed := client.agentSub
+		subscribed := client.agentSub && client.agentFilter.Matches(agentName)
 		client.mu.Unlock()
 
 		if subscribed {
@@ -85,6 +135,95 @@ func (s *Server) BroadcastToAgentSubscribers(msg []byte) {
 	}
 }
 
+// broadcastViewers tells agent-lifecycle subscribers that an agent gained
+// ("viewer-joined") or lost ("viewer-left") a client streaming its output.
+func (s *Server) broadcastViewers(eventType, agentName string, count int) {
+	data, _ := json.Marshal(Response{Type: eventType, Name: agentName, ViewerCount: &count})
+	s.BroadcastToAgentSubscribers(agentName, data)
+}
+
+// broadcastControl tells agent-lifecycle subscribers who now controls an
+// agent's input; an empty controlledBy means the agent is free.
+func (s *Server) broadcastControl(agentName, controlledBy string) {
+	data, _ := json.Marshal(Response{Type: "control-changed", Name: agentName, ControlledBy: controlledBy})
+	s.BroadcastToAgentSubscribers(agentName, data)
+}
+
+// broadcastSize tells the clients streaming an agent its authoritative
+// terminal size, so viewers whose resize lost arbitration can fit to it.
+func (s *Server) broadcastSize(agentName string, size agentio.TermSize) {
+	data, _ := json.Marshal(Response{Type: "terminal-size", Name: agentName, Size: &size})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for client := range s.clients {
+		client.mu.Lock()
+		_, output := client.outputSubs[agentName]
+		_, window := client.windowSubs[agentName]
+		client.mu.Unlock()
+
+		if output || window {
+			client.SendText(data)
+		}
+	}
+}
+
+// applySize resizes an agent's window to the arbitrated size and announces it.
+func (s *Server) applySize(agentName string, size agentio.TermSize) error {
+	log.Printf("resize %s -> %dx%d", agentName, size.Cols, size.Rows)
+	if err := s.ctrl.ResizePaneTo(agentName, size.Cols, size.Rows); err != nil {
+		return err
+	}
+	s.broadcastSize(agentName, size)
+	return nil
+}
+
+// agentViews attaches current viewer counts and control holders to agents.
+func (s *Server) agentViews(list []agents.Agent) []AgentView {
+	views := make([]AgentView, len(list))
+	for i, a := range list {
+		views[i] = AgentView{Agent: a, ViewerCount: s.presence.Count(a.Name), ControlledBy: s.control.Holder(a.Name)}
+	}
+	return views
+}
+
+// agentPage returns the agents a list-agents or subscribe-agents request
+// asks for, the cursor of the page after it, and how many agents pass filter.
+func (s *Server) agentPage(req Request, filter *wsbase.NameFilter) ([]AgentView, string, int, error) {
+	sortBy := cmp.Or(req.SortBy, "name")
+	compare, ok := agentSorts[sortBy]
+	if !ok {
+		return nil, "", 0, fmt.Errorf("unknown sortBy %q: want name or runtime", req.SortBy)
+	}
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, "", 0, errors.New("limit and offset must not be negative")
+	}
+	list := slices.DeleteFunc(s.registry.GetAgents(), func(a agents.Agent) bool { return !filter.Matches(a.Name) })
+	views := s.agentViews(list)
+	slices.SortFunc(views, compare)
+	page, next, err := wsbase.Paginate(views, func(v AgentView) string { return v.Name }, wsbase.Page{Limit: req.Limit, Offset: req.Offset, Cursor: req.Cursor})
+	return page, next, len(views), err
+}
+
+// PromptAPI returns the HTTP prompt endpoint, sharing this server's
+// prompter, control locks, and authentication.
+func (s *Server) PromptAPI() *agentio.PromptAPI {
+	return agentio.NewPromptAPI(s.prompter, s.control, s.auth)
+}
+
+// ScreenshotAPI returns the HTTP endpoint rendering an agent's pane to a
+// PNG, sharing this server's authentication.
+func (s *Server) ScreenshotAPI() *agentio.ScreenshotAPI {
+	return agentio.NewScreenshotAPI(s.ctrl, s.registry, s.auth)
+}
+
+// ClientCount returns the number of connected clients.
+func (s *Server) ClientCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
 // RemoveClient unsubscribes and removes a client from the server.
 func (s *Server) RemoveClient(client *Client) {
 	s.mu.Lock()
@@ -93,7 +232,7 @@ func (s *Server) RemoveClient(client *Client) {
 	s.mu.Unlock()
 
 	client.Close()
-	log.Printf("client disconnected (%d remaining)", count)
+	client.logf("disconnected (%d remaining)", count)
 }
 
 // CloseAll closes all connected clients.
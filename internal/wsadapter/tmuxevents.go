@@ -0,0 +1,73 @@
+package wsadapter
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
+)
+
+// tmuxEventSub forwards raw tmux control-mode notifications to a client.
+type tmuxEventSub struct {
+	id    int
+	types map[string]bool // event types the client asked for
+}
+
+func handleSubscribeTmuxEvents(c *Client, req Request) {
+	types := req.Events
+	if len(types) == 0 {
+		types = tmux.EventTypes
+	}
+	selected := make(map[string]bool, len(types))
+	for _, t := range types {
+		if !slices.Contains(tmux.EventTypes, t) {
+			c.sendError(req.ID, "unknown tmux event "+t+"; supported: "+strings.Join(tmux.EventTypes, ", "))
+			return
+		}
+		selected[t] = true
+	}
+
+	c.mu.Lock()
+	sub := c.tmuxEvents
+	if sub != nil {
+		sub.types = selected
+		c.mu.Unlock()
+	} else {
+		id, ch := c.server.ctrl.SubscribeEvents()
+		sub = &tmuxEventSub{id: id, types: selected}
+		c.tmuxEvents = sub
+		c.mu.Unlock()
+		go c.streamTmuxEvents(sub, ch)
+	}
+
+	okVal := true
+	c.sendJSON(Response{ID: req.ID, Type: "subscribe-tmux-events", OK: &okVal, Events: types})
+}
+
+func handleUnsubscribeTmuxEvents(c *Client, req Request) {
+	c.mu.Lock()
+	sub := c.tmuxEvents
+	c.tmuxEvents = nil
+	c.mu.Unlock()
+	if sub != nil {
+		c.server.ctrl.UnsubscribeEvents(sub.id)
+	}
+
+	okVal := true
+	c.sendJSON(Response{ID: req.ID, Type: "unsubscribe-tmux-events", OK: &okVal})
+}
+
+// streamTmuxEvents sends the events of the types sub currently selects
+// until the subscription ends.
+func (c *Client) streamTmuxEvents(sub *tmuxEventSub, ch <-chan tmux.Event) {
+	defer crash.Recover("%s: tmux event stream", c.id)
+	for e := range ch {
+		c.mu.Lock()
+		wanted := c.tmuxEvents == sub && sub.types[e.Type]
+		c.mu.Unlock()
+		if wanted {
+			c.sendJSON(Response{Type: "tmux-event", Event: &e})
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package wsadapter
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agentio"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+// windowLayoutInterval is how often a window subscription checks for panes
+// being split, closed, resized, or focused.
+const windowLayoutInterval = time.Second
+
+// windowSub streams every pane of an agent's current window.
+type windowSub struct {
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	stopped bool
+	layout  tmux.WindowLayout
+	panes   map[string]windowPane // pane ID -> pipe-pane subscription
+	label   *agentLabel           // agent name the frames carry
+}
+
+// windowPane is one pane's pipe-pane subscription. The active pane is
+// subscribed under the agent's name so it shares subscribe-output's pipe:
+// tmux allows one pipe per pane. Other panes use their pane ID.
+type windowPane struct {
+	key string
+	id  int
+}
+
+func handleSubscribeWindow(c *Client, req Request) {
+	if req.Agent == "" {
+		c.sendError(req.ID, "agent field required")
+		return
+	}
+	if _, ok := c.server.registry.GetAgent(req.Agent); !ok {
+		okVal := false
+		c.sendJSON(Response{ID: req.ID, Type: "subscribe-window", OK: &okVal, Error: "agent not found"})
+		return
+	}
+	c.mu.Lock()
+	_, replacing := c.windowSubs[req.Agent]
+	var lerr *wsbase.LimitError
+	if !replacing {
+		lerr = c.server.limits.CheckSubscriptions(len(c.outputSubs) + len(c.windowSubs))
+	}
+	c.mu.Unlock()
+	if lerr != nil {
+		c.sendLimit(req.ID, lerr)
+		return
+	}
+	layout, err := c.server.ctrl.GetWindowLayout(req.Agent)
+	if err != nil {
+		okVal := false
+		c.sendJSON(Response{ID: req.ID, Type: "subscribe-window", OK: &okVal, Error: err.Error(), Code: tmux.ErrorCode(err)})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	ws := &windowSub{cancel: cancel, panes: make(map[string]windowPane), label: newAgentLabel(req.Agent)}
+	c.mu.Lock()
+	old, hadOld := c.windowSubs[req.Agent]
+	c.windowSubs[req.Agent] = ws
+	c.mu.Unlock()
+	if hadOld {
+		old.stop(c.server.pipeMgr)
+	} else if count, joined := c.server.presence.Join(req.Agent, c); joined {
+		c.server.broadcastViewers("viewer-joined", req.Agent, count)
+	}
+
+	okVal := true
+	c.sendJSON(Response{ID: req.ID, Type: "subscribe-window", OK: &okVal, Name: req.Agent, Layout: &layout})
+	ws.sync(c, layout)
+	go ws.watch(ctx, c)
+}
+
+func handleUnsubscribeWindow(c *Client, req Request) {
+	if req.Agent == "" {
+		c.sendError(req.ID, "agent field required")
+		return
+	}
+
+	c.mu.Lock()
+	ws, exists := c.windowSubs[req.Agent]
+	delete(c.windowSubs, req.Agent)
+	c.mu.Unlock()
+
+	if exists {
+		ws.stop(c.server.pipeMgr)
+		c.leaveAgent(req.Agent)
+	}
+
+	okVal := true
+	c.sendJSON(Response{ID: req.ID, Type: "unsubscribe-window", OK: &okVal})
+}
+
+// watch polls the window layout, announcing changes with window-layout and
+// streaming panes as they appear.
+func (ws *windowSub) watch(ctx context.Context, c *Client) {
+	defer crash.Recover("%s: window layout watch", c.id)
+	ticker := time.NewTicker(windowLayoutInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		agentName := ws.label.get()
+		layout, err := c.server.ctrl.GetWindowLayout(agentName)
+		if err != nil {
+			continue // the session is gone or tmux is reconnecting; agent-removed covers the former
+		}
+		ws.mu.Lock()
+		changed := !reflect.DeepEqual(layout, ws.layout)
+		ws.mu.Unlock()
+		if changed {
+			c.sendJSON(Response{Type: "window-layout", Name: agentName, Layout: &layout})
+			ws.sync(c, layout)
+		}
+	}
+}
+
+// sync subscribes to the panes in layout that aren't streaming yet, sending
+// each one's current screen first, and drops panes that have closed.
+func (ws *windowSub) sync(c *Client, layout tmux.WindowLayout) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.stopped {
+		return
+	}
+	ws.layout = layout
+	agentName := ws.label.get()
+
+	keys := make(map[string]string, len(layout.Panes))
+	for _, pane := range layout.Panes {
+		if pane.Active {
+			keys[pane.PaneID] = agentName
+		} else {
+			keys[pane.PaneID] = pane.PaneID
+		}
+	}
+	for paneID, p := range ws.panes {
+		if keys[paneID] != p.key {
+			c.server.pipeMgr.Unsubscribe(p.key, p.id)
+			delete(ws.panes, paneID)
+		}
+	}
+
+	for _, pane := range layout.Panes {
+		if _, ok := ws.panes[pane.PaneID]; ok {
+			continue
+		}
+		key := keys[pane.PaneID]
+		subID, ch, err := c.server.pipeMgr.Subscribe(key)
+		if err != nil {
+			c.logf("subscribe-window(%s): pane %s: %v", agentName, pane.PaneID, err)
+			continue
+		}
+		ws.panes[pane.PaneID] = windowPane{key: key, id: subID}
+
+		screen, err := c.server.ctrl.CapturePaneVisible(pane.PaneID)
+		if err != nil {
+			c.logf("subscribe-window(%s): capture pane %s: %v", agentName, pane.PaneID, err)
+		}
+		c.SendBinary(agentio.MakePaneFrame(agentName, pane.PaneID, []byte("\x1b[2J\x1b[H"+screen)))
+
+		go func(paneID string) {
+			defer crash.Recover("%s: pane %s stream for %s", c.id, paneID, agentName)
+			for rawBytes := range ch {
+				c.SendBinary(agentio.MakePaneFrame(ws.label.get(), paneID, rawBytes))
+			}
+		}(pane.PaneID)
+	}
+}
+
+// stop ends polling and releases every pane's pipe-pane subscription.
+func (ws *windowSub) stop(pm *tmux.PipePaneManager) {
+	ws.cancel()
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.stopped = true
+	for paneID, p := range ws.panes {
+		pm.Unsubscribe(p.key, p.id)
+		delete(ws.panes, paneID)
+	}
+}
+
+// rename points the subscription at the agent's new name. The active pane's
+// pipe is shared with subscribe-output under the agent's name, which the
+// pipe-pane manager renames too.
+func (ws *windowSub) rename(oldName, newName string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.label.set(newName)
+	for paneID, p := range ws.panes {
+		if p.key == oldName {
+			p.key = newName
+			ws.panes[paneID] = p
+		}
+	}
+}
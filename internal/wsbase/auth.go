@@ -2,8 +2,11 @@ package wsbase
 
 import (
 	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // IsAuthorizedRequest checks if the request contains a valid auth token.
@@ -13,16 +16,19 @@ func IsAuthorizedRequest(expectedToken string, r *http.Request) bool {
 	if token == "" {
 		return true
 	}
+	return TokensEqual(token, requestToken(r))
+}
 
+// requestToken returns the bearer token from the Authorization header,
+// falling back to the ?token= query parameter.
+func requestToken(r *http.Request) string {
 	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
 	if bearerToken, ok := strings.CutPrefix(authHeader, "Bearer "); ok {
-		if TokensEqual(token, strings.TrimSpace(bearerToken)) {
-			return true
+		if t := strings.TrimSpace(bearerToken); t != "" {
+			return t
 		}
 	}
-
-	queryToken := strings.TrimSpace(r.URL.Query().Get("token"))
-	return TokensEqual(token, queryToken)
+	return strings.TrimSpace(r.URL.Query().Get("token"))
 }
 
 // TokensEqual performs constant-time comparison of two tokens.
@@ -32,3 +38,177 @@ func TokensEqual(expected, actual string) bool {
 	}
 	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
 }
+
+// Grant is what an authenticated connection may do.
+// Read covers listing and subscribing, Prompt covers prompts, keystrokes,
+// and file uploads, Control covers taking exclusive input control, and
+// Annotate covers adding annotations to conversations.
+type Grant struct {
+	Subject  string
+	Read     bool
+	Prompt   bool
+	Control  bool
+	Annotate bool
+	Expires  time.Time // zero for grants that never expire
+}
+
+// FullGrant allows everything and never expires; it is what the static
+// token (or no configured auth) grants.
+func FullGrant() Grant {
+	return Grant{Read: true, Prompt: true, Control: true, Annotate: true}
+}
+
+// ReadOnly returns the grant without its write permissions.
+func (g Grant) ReadOnly() Grant {
+	g.Prompt, g.Control, g.Annotate = false, false, false
+	return g
+}
+
+// Errors for connections whose grant lacks a permission.
+var (
+	ErrPromptNotAllowed   = errors.New("token does not allow input")
+	ErrControlNotAllowed  = errors.New("token does not allow taking control")
+	ErrAnnotateNotAllowed = errors.New("token does not allow annotations")
+)
+
+// ExpiryAction is what happens to a connection when its token expires.
+type ExpiryAction string
+
+const (
+	ExpireClose    ExpiryAction = "close"     // close the socket
+	ExpireReadOnly ExpiryAction = "read-only" // keep streaming, refuse input
+)
+
+// ParseExpiryAction validates an --jwt-expiry value.
+func ParseExpiryAction(s string) (ExpiryAction, error) {
+	switch a := ExpiryAction(s); a {
+	case ExpireClose, ExpireReadOnly:
+		return a, nil
+	}
+	return "", fmt.Errorf("invalid JWT expiry action %q: want close or read-only", s)
+}
+
+// Authenticator checks WebSocket upgrade requests against a static token
+// and, if configured, JWTs and verified client certificates.
+// A nil Authenticator allows everything.
+type Authenticator struct {
+	token    string
+	jwt      *JWTVerifier
+	certs    map[string]Grant // client certificate common name → grant; "*" matches any
+	onExpiry ExpiryAction
+}
+
+// ParseCertScopes parses "name=scope,scope" entries that map verified client
+// certificate common names to permissions, e.g. "dashboard=read" or
+// "ops=read,prompt,control". The name "*" matches any verified certificate.
+func ParseCertScopes(entries []string) (map[string]Grant, error) {
+	certs := make(map[string]Grant, len(entries))
+	for _, entry := range entries {
+		name, scopes, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid certificate scope %q: want name=scope,scope", entry)
+		}
+		g := grantForScopes(strings.Split(scopes, ","))
+		if !g.Read {
+			return nil, fmt.Errorf("certificate scope %q: read is required", entry)
+		}
+		certs[name] = g
+	}
+	return certs, nil
+}
+
+// grantForScopes maps scope names to permissions, ignoring unknown scopes.
+func grantForScopes(scopes []string) Grant {
+	var g Grant
+	for _, scope := range scopes {
+		switch strings.TrimSpace(scope) {
+		case "read":
+			g.Read = true
+		case "prompt":
+			g.Prompt = true
+		case "control":
+			g.Control = true
+		case "annotate":
+			g.Annotate = true
+		}
+	}
+	return g
+}
+
+// NewAuthenticator creates an authenticator. The static token, when set,
+// grants everything; JWTs are granted the permissions named in their scopes.
+func NewAuthenticator(token string, jwt *JWTVerifier, onExpiry ExpiryAction) *Authenticator {
+	return &Authenticator{token: strings.TrimSpace(token), jwt: jwt, onExpiry: onExpiry}
+}
+
+// SetCertScopes enables client certificate authentication with the given
+// common name → grant mapping (see ParseCertScopes).
+func (a *Authenticator) SetCertScopes(certs map[string]Grant) {
+	a.certs = certs
+}
+
+// Enabled reports whether any credentials are configured; without them,
+// Authenticate grants everything.
+func (a *Authenticator) Enabled() bool {
+	return a != nil && (a.token != "" || a.jwt != nil || len(a.certs) > 0)
+}
+
+// OnExpiry reports what to do with a connection whose grant has expired.
+func (a *Authenticator) OnExpiry() ExpiryAction {
+	if a == nil || a.onExpiry == "" {
+		return ExpireClose
+	}
+	return a.onExpiry
+}
+
+// Authenticate returns the request's grant, or an error if it carries no
+// acceptable credentials. A mapped client certificate takes precedence over
+// tokens, and its subject becomes the grant's subject for audit logs.
+func (a *Authenticator) Authenticate(r *http.Request) (Grant, error) {
+	if !a.Enabled() {
+		return FullGrant(), nil
+	}
+	if g, ok := a.certGrant(r); ok {
+		return g, nil
+	}
+	token := requestToken(r)
+	if token == "" {
+		return Grant{}, errors.New("missing token")
+	}
+	if TokensEqual(a.token, token) {
+		return FullGrant(), nil
+	}
+	if a.jwt == nil {
+		return Grant{}, errors.New("invalid token")
+	}
+	claims, err := a.jwt.Verify(token)
+	if err != nil {
+		return Grant{}, err
+	}
+	g := grantForScopes(claims.Scopes())
+	g.Subject = claims.Subject
+	g.Expires = time.Unix(claims.ExpiresAt, 0)
+	if !g.Read {
+		return Grant{}, errors.New("token lacks read scope")
+	}
+	return g, nil
+}
+
+// certGrant returns the grant mapped to the request's verified client
+// certificate, expiring with the certificate.
+func (a *Authenticator) certGrant(r *http.Request) (Grant, bool) {
+	if len(a.certs) == 0 || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return Grant{}, false
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	g, ok := a.certs[leaf.Subject.CommonName]
+	if !ok {
+		if g, ok = a.certs["*"]; !ok {
+			return Grant{}, false
+		}
+	}
+	g.Subject = leaf.Subject.String()
+	g.Expires = leaf.NotAfter
+	return g, true
+}
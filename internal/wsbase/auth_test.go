@@ -1,8 +1,13 @@
 package wsbase
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestIsAuthorizedRequestWithoutToken(t *testing.T) {
@@ -37,3 +42,47 @@ func TestIsAuthorizedRequestRejectsInvalidToken(t *testing.T) {
 		t.Fatal("expected invalid tokens to be rejected")
 	}
 }
+
+func TestAuthenticatorClientCertificate(t *testing.T) {
+	certs, err := ParseCertScopes([]string{"ops=read,prompt,control", "*=read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthenticator("", nil, ExpireClose)
+	auth.SetCertScopes(certs)
+
+	withCert := func(cn string) *http.Request {
+		req := httptest.NewRequest("GET", "https://localhost:8080/ws", nil)
+		leaf := &x509.Certificate{Subject: pkix.Name{CommonName: cn}, NotAfter: time.Now().Add(time.Hour)}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+		return req
+	}
+
+	g, err := auth.Authenticate(withCert("ops"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Control || g.Subject != "CN=ops" || g.Expires.IsZero() {
+		t.Fatalf("ops grant = %+v, want full permissions as CN=ops expiring with the cert", g)
+	}
+
+	g, err = auth.Authenticate(withCert("dashboard"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Read || g.Prompt {
+		t.Fatalf("wildcard grant = %+v, want read only", g)
+	}
+
+	if _, err := auth.Authenticate(httptest.NewRequest("GET", "http://localhost:8080/ws", nil)); err == nil {
+		t.Fatal("expected request without certificate or token to be rejected")
+	}
+}
+
+func TestParseCertScopesRejectsBadEntries(t *testing.T) {
+	for _, entry := range []string{"ops", "=read", "ops=prompt"} {
+		if _, err := ParseCertScopes([]string{entry}); err == nil {
+			t.Errorf("ParseCertScopes(%q): expected error", entry)
+		}
+	}
+}
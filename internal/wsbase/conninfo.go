@@ -0,0 +1,57 @@
+package wsbase
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"nhooyr.io/websocket"
+)
+
+// maxUserAgent caps the User-Agent kept per connection.
+const maxUserAgent = 200
+
+// ConnInfo identifies the client behind a WebSocket connection, for
+// connection logs and admin views.
+type ConnInfo struct {
+	RemoteAddr  string `json:"remoteAddr"`
+	UserAgent   string `json:"userAgent,omitempty"`
+	Subprotocol string `json:"subprotocol,omitempty"` // negotiated in the upgrade
+	Identity    string `json:"identity,omitempty"`    // token or certificate subject
+}
+
+// NewConnInfo describes the connection conn accepted from r with grant's
+// credentials.
+func NewConnInfo(r *http.Request, conn *websocket.Conn, grant Grant) ConnInfo {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgent {
+		ua = strings.ToValidUTF8(ua[:maxUserAgent], "") + "…"
+	}
+	return ConnInfo{
+		RemoteAddr:  r.RemoteAddr,
+		UserAgent:   ua,
+		Subprotocol: conn.Subprotocol(),
+		Identity:    grant.Subject,
+	}
+}
+
+// String formats i for a log line, e.g.
+// `from 10.0.0.5:51234 as "ci-bot" (subprotocol tmux-converter.v1, user agent "curl/8.5.0")`.
+func (i ConnInfo) String() string {
+	var b strings.Builder
+	b.WriteString("from " + i.RemoteAddr)
+	if i.Identity != "" {
+		fmt.Fprintf(&b, " as %q", i.Identity)
+	}
+	var details []string
+	if i.Subprotocol != "" {
+		details = append(details, "subprotocol "+i.Subprotocol)
+	}
+	if i.UserAgent != "" {
+		details = append(details, fmt.Sprintf("user agent %q", i.UserAgent))
+	}
+	if len(details) > 0 {
+		b.WriteString(" (" + strings.Join(details, ", ") + ")")
+	}
+	return b.String()
+}
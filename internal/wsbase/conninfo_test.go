@@ -0,0 +1,22 @@
+package wsbase
+
+import "testing"
+
+func TestConnInfoString(t *testing.T) {
+	tests := []struct {
+		info ConnInfo
+		want string
+	}{
+		{ConnInfo{RemoteAddr: "10.0.0.5:51234"}, "from 10.0.0.5:51234"},
+		{
+			ConnInfo{RemoteAddr: "10.0.0.5:51234", Identity: "ci-bot", Subprotocol: "tmux-converter.v1", UserAgent: "curl/8.5.0"},
+			`from 10.0.0.5:51234 as "ci-bot" (subprotocol tmux-converter.v1, user agent "curl/8.5.0")`,
+		},
+		{ConnInfo{RemoteAddr: "[::1]:9000", UserAgent: "Mozilla/5.0"}, `from [::1]:9000 (user agent "Mozilla/5.0")`},
+	}
+	for _, tt := range tests {
+		if got := tt.info.String(); got != tt.want {
+			t.Errorf("String() = %s, want %s", got, tt.want)
+		}
+	}
+}
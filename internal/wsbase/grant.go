@@ -0,0 +1,69 @@
+package wsbase
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InputLocks is what GrantHolder.CheckInput needs of the input-control
+// locks; agentio.ControlLocks implements it.
+type InputLocks interface {
+	CheckInput(agent string, client any) error
+}
+
+// GrantHolder holds a WebSocket connection's grant for its lifetime, which
+// may change once: WatchExpiry drops it to read-only when the token expires.
+type GrantHolder struct {
+	mu    sync.Mutex
+	grant Grant
+}
+
+// NewGrantHolder returns a holder for the grant a connection authenticated with.
+func NewGrantHolder(g Grant) *GrantHolder {
+	return &GrantHolder{grant: g}
+}
+
+// Get returns what the connection may currently do.
+func (h *GrantHolder) Get() Grant {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.grant
+}
+
+// CheckInput returns why client may not send input to agent, or nil: the
+// grant must allow prompts, and no other client may hold input control.
+func (h *GrantHolder) CheckInput(locks InputLocks, agent string, client any) error {
+	if !h.Get().Prompt {
+		return ErrPromptNotAllowed
+	}
+	return locks.CheckInput(agent, client)
+}
+
+// WatchExpiry waits until the grant expires or ctx ends, whichever is first;
+// a grant without an expiry returns at once. On expiry with ExpireReadOnly
+// the grant is dropped to read-only before onExpire is called with
+// readOnly true; otherwise onExpire is called with false and should close
+// the connection.
+func (h *GrantHolder) WatchExpiry(ctx context.Context, action ExpiryAction, onExpire func(readOnly bool)) {
+	expires := h.Get().Expires
+	if expires.IsZero() {
+		return
+	}
+	timer := time.NewTimer(time.Until(expires))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+
+	if action != ExpireReadOnly {
+		onExpire(false)
+		return
+	}
+	h.mu.Lock()
+	h.grant = h.grant.ReadOnly()
+	h.mu.Unlock()
+	onExpire(true)
+}
@@ -0,0 +1,68 @@
+package wsbase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type lockedBy string
+
+func (l lockedBy) CheckInput(agent string, client any) error {
+	if l != "" && client != string(l) {
+		return errors.New(agent + " is controlled by " + string(l))
+	}
+	return nil
+}
+
+func TestGrantHolderCheckInput(t *testing.T) {
+	full := NewGrantHolder(FullGrant())
+	if err := full.CheckInput(lockedBy(""), "hq-mayor", "client-1"); err != nil {
+		t.Errorf("full grant, no lock: %v", err)
+	}
+	if err := full.CheckInput(lockedBy("client-2"), "hq-mayor", "client-1"); err == nil {
+		t.Error("input allowed while another client holds control")
+	}
+	readOnly := NewGrantHolder(FullGrant().ReadOnly())
+	if err := readOnly.CheckInput(lockedBy(""), "hq-mayor", "client-1"); !errors.Is(err, ErrPromptNotAllowed) {
+		t.Errorf("read-only grant error = %v, want ErrPromptNotAllowed", err)
+	}
+}
+
+func TestGrantHolderWatchExpiry(t *testing.T) {
+	expiring := FullGrant()
+	expiring.Expires = time.Now().Add(10 * time.Millisecond)
+
+	tests := []struct {
+		action    ExpiryAction
+		wantRO    bool
+		wantInput bool
+	}{
+		{action: ExpireReadOnly, wantRO: true},
+		{action: ExpireClose, wantRO: false, wantInput: true},
+	}
+	for _, tt := range tests {
+		h := NewGrantHolder(expiring)
+		called := false
+		h.WatchExpiry(context.Background(), tt.action, func(readOnly bool) {
+			called = true
+			if readOnly != tt.wantRO {
+				t.Errorf("%s: onExpire(readOnly=%v), want %v", tt.action, readOnly, tt.wantRO)
+			}
+		})
+		if !called {
+			t.Errorf("%s: onExpire not called", tt.action)
+		}
+		if got := h.Get().Prompt; got != tt.wantInput {
+			t.Errorf("%s: Prompt after expiry = %v, want %v", tt.action, got, tt.wantInput)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	later := FullGrant()
+	later.Expires = time.Now().Add(time.Hour)
+	NewGrantHolder(later).WatchExpiry(ctx, ExpireClose, func(bool) { t.Error("onExpire called after ctx ended") })
+	NewGrantHolder(FullGrant()).WatchExpiry(context.Background(), ExpireClose, func(bool) { t.Error("onExpire called for a grant that never expires") })
+}
@@ -0,0 +1,126 @@
+package wsbase
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// IPGuard admits HTTP clients by source address: an optional allowlist of
+// networks, and an optional cap on concurrent connections per IP so one
+// misbehaving client can't open hundreds of sockets. A nil IPGuard admits everything.
+type IPGuard struct {
+	allow    []*net.IPNet
+	maxPerIP int
+
+	mu    sync.Mutex
+	conns map[string]int // IP → open connections
+}
+
+// ParseIPGuard builds a guard from CIDRs or bare IPs and a per-IP connection
+// limit (0 for none). It returns nil when neither is set.
+func ParseIPGuard(allow []string, maxPerIP int) (*IPGuard, error) {
+	g := &IPGuard{maxPerIP: maxPerIP, conns: make(map[string]int)}
+	for _, entry := range allow {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("allowed IP %q: not an IP or CIDR", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			g.allow = append(g.allow, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("allowed IP %q: %w", entry, err)
+		}
+		g.allow = append(g.allow, ipNet)
+	}
+	if len(g.allow) == 0 && maxPerIP <= 0 {
+		return nil, nil
+	}
+	return g, nil
+}
+
+// Filter rejects requests from addresses outside the allowlist with 403.
+func (g *IPGuard) Filter(next http.Handler) http.Handler {
+	if g == nil || len(g.allow) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.allowed(remoteIP(r)) {
+			log.Printf("rejected %s %s: address not in --allow-ips", r.RemoteAddr, r.URL.Path)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitConns rejects requests beyond the per-IP limit with 429. The slot is
+// held until next returns, so wrap handlers that serve a whole WebSocket connection.
+func (g *IPGuard) LimitConns(next http.Handler) http.Handler {
+	if g == nil || g.maxPerIP <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := remoteIP(r).String()
+		if !g.acquire(ip) {
+			log.Printf("rejected %s: more than %d connections from %s", r.URL.Path, g.maxPerIP, ip)
+			http.Error(w, "too many connections", http.StatusTooManyRequests)
+			return
+		}
+		defer g.release(ip)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *IPGuard) allowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range g.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *IPGuard) acquire(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.conns[ip] >= g.maxPerIP {
+		return false
+	}
+	g.conns[ip]++
+	return true
+}
+
+func (g *IPGuard) release(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.conns[ip]--; g.conns[ip] <= 0 {
+		delete(g.conns, ip)
+	}
+}
+
+// remoteIP returns the request's peer address, or nil if it can't be parsed.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
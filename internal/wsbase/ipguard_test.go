@@ -0,0 +1,77 @@
+package wsbase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPGuardFilter(t *testing.T) {
+	g, err := ParseIPGuard([]string{"10.0.0.0/8", "192.168.1.5", "::1"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := g.Filter(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+	tests := map[string]int{
+		"10.1.2.3:5000":    http.StatusOK,
+		"192.168.1.5:5000": http.StatusOK,
+		"[::1]:5000":       http.StatusOK,
+		"192.168.1.6:5000": http.StatusForbidden,
+	}
+	for remote, want := range tests {
+		req := httptest.NewRequest("GET", "/ws", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", remote, rec.Code, want)
+		}
+	}
+}
+
+func TestIPGuardLimitConns(t *testing.T) {
+	g, err := ParseIPGuard(nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	entered := make(chan struct{}, 2)
+	h := g.LimitConns(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	serve := func(remote string) int {
+		req := httptest.NewRequest("GET", "/ws", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	done := make(chan int, 2)
+	for range 2 {
+		go func() { done <- serve("10.0.0.1:5000") }()
+		<-entered
+	}
+	if code := serve("10.0.0.1:6000"); code != http.StatusTooManyRequests {
+		t.Fatalf("third connection: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	close(release)
+	<-done
+	<-done
+	if code := serve("10.0.0.1:7000"); code != http.StatusOK {
+		t.Fatalf("after release: status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestParseIPGuard(t *testing.T) {
+	if g, err := ParseIPGuard([]string{""}, 0); err != nil || g != nil {
+		t.Fatalf("empty config: guard = %v, err = %v; want nil, nil", g, err)
+	}
+	if _, err := ParseIPGuard([]string{"not-an-ip"}, 0); err == nil {
+		t.Fatal("expected invalid address to fail")
+	}
+}
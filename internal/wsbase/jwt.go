@@ -0,0 +1,304 @@
+package wsbase
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwtLeeway tolerates clock skew between the issuer and this host.
+	jwtLeeway = 30 * time.Second
+	// jwksRefreshInterval is how long fetched JWKS keys are trusted before refetching.
+	jwksRefreshInterval = 10 * time.Minute
+	// jwksMinRefetch limits refetches triggered by unknown key IDs.
+	jwksMinRefetch = 30 * time.Second
+)
+
+// JWTClaims are the registered and scope claims read from a verified token.
+type JWTClaims struct {
+	Subject   string      `json:"sub"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt int64       `json:"exp"`
+	NotBefore int64       `json:"nbf"`
+	Scope     string      `json:"scope"` // space-separated, OAuth style
+	Scp       []string    `json:"scp"`   // array form used by some issuers
+}
+
+// Scopes returns the token's scopes from either claim form.
+func (c JWTClaims) Scopes() []string {
+	return append(strings.Fields(c.Scope), c.Scp...)
+}
+
+// jwtAudience accepts "aud" as a single string or an array.
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = jwtAudience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// JWTVerifier validates HS256 and RS256 tokens. Several HMAC secrets or RSA
+// keys may be configured at once so keys can be rotated without downtime;
+// keys from a JWKS URL are refetched periodically and on unknown key IDs.
+type JWTVerifier struct {
+	hmacSecrets [][]byte
+	rsaKeys     []*rsa.PublicKey
+	jwks        *jwksCache
+	audience    string
+}
+
+// NewJWTVerifier creates a verifier. rsaKeysPEM may hold several PEM-encoded
+// public keys. A non-empty audience must appear in each token's "aud" claim.
+func NewJWTVerifier(hmacSecrets []string, rsaKeysPEM []byte, jwksURL, audience string) (*JWTVerifier, error) {
+	v := &JWTVerifier{audience: audience}
+	for _, s := range hmacSecrets {
+		if s = strings.TrimSpace(s); s != "" {
+			v.hmacSecrets = append(v.hmacSecrets, []byte(s))
+		}
+	}
+	for rest := rsaKeysPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse RSA public key: %w", err)
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is %T, want RSA", key)
+		}
+		v.rsaKeys = append(v.rsaKeys, rsaKey)
+	}
+	if jwksURL != "" {
+		v.jwks = &jwksCache{url: jwksURL, client: &http.Client{Timeout: 5 * time.Second}}
+	}
+	if len(v.hmacSecrets) == 0 && len(v.rsaKeys) == 0 && v.jwks == nil {
+		return nil, errors.New("JWT verification needs a secret, public key, or JWKS URL")
+	}
+	return v, nil
+}
+
+// LoadJWTVerifier builds a verifier from command-line settings: comma-separated
+// HMAC secrets, a PEM file path, and a JWKS URL. It returns nil when none are set.
+func LoadJWTVerifier(secrets, publicKeyFile, jwksURL, audience string) (*JWTVerifier, error) {
+	if secrets == "" && publicKeyFile == "" && jwksURL == "" {
+		return nil, nil
+	}
+	var pemData []byte
+	if publicKeyFile != "" {
+		data, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read JWT public key: %w", err)
+		}
+		pemData = data
+	}
+	return NewJWTVerifier(strings.Split(secrets, ","), pemData, jwksURL, audience)
+}
+
+// Verify checks a token's signature, expiry, not-before, and audience.
+// Tokens without an "exp" claim are rejected.
+func (v *JWTVerifier) Verify(token string) (JWTClaims, error) {
+	var claims JWTClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return claims, fmt.Errorf("token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, fmt.Errorf("token signature: %w", err)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch header.Alg {
+	case "HS256":
+		if !v.verifyHMAC(signed, sig) {
+			return claims, errors.New("invalid signature")
+		}
+	case "RS256":
+		if !v.verifyRSA(header.Kid, signed, sig) {
+			return claims, errors.New("invalid signature")
+		}
+	default:
+		return claims, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return claims, fmt.Errorf("token claims: %w", err)
+	}
+	now := time.Now()
+	if claims.ExpiresAt == 0 {
+		return claims, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(jwtLeeway)) {
+		return claims, errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now.Add(jwtLeeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return claims, errors.New("token not yet valid")
+	}
+	if v.audience != "" && !slices.Contains(claims.Audience, v.audience) {
+		return claims, fmt.Errorf("token audience does not include %q", v.audience)
+	}
+	return claims, nil
+}
+
+func (v *JWTVerifier) verifyHMAC(signed, sig []byte) bool {
+	for _, secret := range v.hmacSecrets {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		if hmac.Equal(mac.Sum(nil), sig) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *JWTVerifier) verifyRSA(kid string, signed, sig []byte) bool {
+	digest := sha256.Sum256(signed)
+	keys := v.rsaKeys
+	if v.jwks != nil {
+		keys = append(keys[:len(keys):len(keys)], v.jwks.keys(kid)...)
+	}
+	for _, key := range keys {
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// jwksCache holds RSA keys fetched from a JWKS endpoint, keyed by key ID.
+type jwksCache struct {
+	url    string
+	client *http.Client
+
+	mu       sync.Mutex
+	byKid    map[string]*rsa.PublicKey
+	fetched  time.Time
+	inflight chan struct{} // closed when the fetch in progress finishes
+}
+
+// keys returns the key for kid, or every cached key when kid is empty.
+// Stale caches and unknown key IDs trigger a refetch. The fetch runs without
+// the lock held; callers needing one while it is in progress wait for it
+// rather than starting another.
+func (c *jwksCache) keys(kid string) []*rsa.PublicKey {
+	c.mu.Lock()
+	age := time.Since(c.fetched)
+	_, known := c.byKid[kid]
+	if age > jwksRefreshInterval || (kid != "" && !known && age > jwksMinRefetch) {
+		if done := c.inflight; done != nil {
+			c.mu.Unlock()
+			<-done
+			c.mu.Lock()
+		} else {
+			done := make(chan struct{})
+			c.inflight = done
+			c.mu.Unlock()
+			byKid, err := c.fetch()
+			c.mu.Lock()
+			if err != nil {
+				// Keep serving the previous keys; the endpoint may be briefly down.
+				log.Printf("jwks fetch %s: %v", c.url, err)
+			} else {
+				c.byKid = byKid
+			}
+			c.fetched = time.Now()
+			c.inflight = nil
+			close(done)
+		}
+	}
+	defer c.mu.Unlock()
+
+	if kid != "" {
+		if key, ok := c.byKid[kid]; ok {
+			return []*rsa.PublicKey{key}
+		}
+		return nil
+	}
+	keys := make([]*rsa.PublicKey, 0, len(c.byKid))
+	for _, key := range c.byKid {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c *jwksCache) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	byKid := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		byKid[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return byKid, nil
+}
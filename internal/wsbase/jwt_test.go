@@ -0,0 +1,218 @@
+package wsbase
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signJWT(t *testing.T, header, claims map[string]any, sign func([]byte) []byte) string {
+	t.Helper()
+	enc := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := enc(header) + "." + enc(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func hs256(secret string) func([]byte) []byte {
+	return func(data []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(data)
+		return mac.Sum(nil)
+	}
+}
+
+func rs256(t *testing.T, key *rsa.PrivateKey) func([]byte) []byte {
+	return func(data []byte) []byte {
+		digest := sha256.Sum256(data)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+}
+
+func validClaims(scope string) map[string]any {
+	return map[string]any{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix(), "scope": scope}
+}
+
+func TestJWTVerifierHS256Rotation(t *testing.T) {
+	v, err := NewJWTVerifier([]string{"new-secret", "old-secret"}, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"new-secret", "old-secret"} {
+		token := signJWT(t, map[string]any{"alg": "HS256"}, validClaims("read"), hs256(secret))
+		claims, err := v.Verify(token)
+		if err != nil {
+			t.Fatalf("token signed with %s: %v", secret, err)
+		}
+		if claims.Subject != "alice" {
+			t.Fatalf("subject = %q, want alice", claims.Subject)
+		}
+	}
+
+	token := signJWT(t, map[string]any{"alg": "HS256"}, validClaims("read"), hs256("retired"))
+	if _, err := v.Verify(token); err == nil {
+		t.Fatal("expected token signed with an unknown secret to fail")
+	}
+}
+
+func TestJWTVerifierRejectsBadClaims(t *testing.T) {
+	v, err := NewJWTVerifier([]string{"secret"}, nil, "", "tmux-adapter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]map[string]any{
+		"expired":       {"exp": time.Now().Add(-time.Hour).Unix(), "aud": "tmux-adapter"},
+		"no expiry":     {"aud": "tmux-adapter"},
+		"not yet valid": {"exp": time.Now().Add(time.Hour).Unix(), "nbf": time.Now().Add(time.Hour).Unix(), "aud": "tmux-adapter"},
+		"wrong aud":     {"exp": time.Now().Add(time.Hour).Unix(), "aud": []string{"other"}},
+	}
+	for name, claims := range tests {
+		token := signJWT(t, map[string]any{"alg": "HS256"}, claims, hs256("secret"))
+		if _, err := v.Verify(token); err == nil {
+			t.Errorf("%s: expected verification to fail", name)
+		}
+	}
+
+	none := signJWT(t, map[string]any{"alg": "none"}, validClaims("read"), func([]byte) []byte { return nil })
+	if _, err := v.Verify(none); err == nil {
+		t.Error("expected alg none to be rejected")
+	}
+}
+
+func TestJWTVerifierRS256PEM(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewJWTVerifier(nil, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := signJWT(t, map[string]any{"alg": "RS256"}, validClaims("read"), rs256(t, key))
+	if _, err := v.Verify(token); err != nil {
+		t.Fatalf("RS256 token: %v", err)
+	}
+
+	// An HS256 token must not verify against RSA key material.
+	forged := signJWT(t, map[string]any{"alg": "HS256"}, validClaims("read"), hs256(string(der)))
+	if _, err := v.Verify(forged); err == nil {
+		t.Fatal("expected HS256 token to fail without HMAC secrets")
+	}
+}
+
+func TestJWTVerifierJWKSFetchesNewKeys(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	published := map[string]*rsa.PrivateKey{"k1": oldKey}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for kid, k := range published {
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	defer srv.Close()
+
+	v, err := NewJWTVerifier(nil, nil, srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signJWT(t, map[string]any{"alg": "RS256", "kid": "k1"}, validClaims("read"), rs256(t, oldKey))
+	if _, err := v.Verify(token); err != nil {
+		t.Fatalf("k1 token: %v", err)
+	}
+
+	// Rotate: the issuer publishes k2. An unknown kid triggers a refetch once
+	// the minimum refetch interval has passed.
+	published["k2"] = newKey
+	v.jwks.fetched = time.Now().Add(-jwksMinRefetch - time.Second)
+	token = signJWT(t, map[string]any{"alg": "RS256", "kid": "k2"}, validClaims("read"), rs256(t, newKey))
+	if _, err := v.Verify(token); err != nil {
+		t.Fatalf("k2 token after rotation: %v", err)
+	}
+}
+
+func TestAuthenticatorScopes(t *testing.T) {
+	v, err := NewJWTVerifier([]string{"secret"}, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthenticator("static", v, ExpireReadOnly)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/ws?token=static", nil)
+	if g, err := auth.Authenticate(req); err != nil || g != FullGrant() {
+		t.Fatalf("static token: grant = %+v, err = %v; want full grant", g, err)
+	}
+
+	token := signJWT(t, map[string]any{"alg": "HS256"}, validClaims("read prompt"), hs256("secret"))
+	req = httptest.NewRequest("GET", "http://localhost:8080/ws", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	g, err := auth.Authenticate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Read || !g.Prompt || g.Control || g.Subject != "alice" || g.Expires.IsZero() {
+		t.Fatalf("grant = %+v, want read+prompt for alice with expiry", g)
+	}
+	if ro := g.ReadOnly(); ro.Prompt || !ro.Read {
+		t.Fatalf("ReadOnly() = %+v, want read only", ro)
+	}
+
+	token = signJWT(t, map[string]any{"alg": "HS256"}, validClaims("prompt"), hs256("secret"))
+	req = httptest.NewRequest("GET", "http://localhost:8080/ws?token="+token, nil)
+	if _, err := auth.Authenticate(req); err == nil {
+		t.Fatal("expected token without read scope to be rejected")
+	}
+
+	req = httptest.NewRequest("GET", "http://localhost:8080/ws", nil)
+	if _, err := auth.Authenticate(req); err == nil {
+		t.Fatal("expected request without token to be rejected")
+	}
+}
+
+func TestNilAuthenticatorAllowsAll(t *testing.T) {
+	var auth *Authenticator
+	req := httptest.NewRequest("GET", "http://localhost:8080/ws", nil)
+	if g, err := auth.Authenticate(req); err != nil || g != FullGrant() {
+		t.Fatalf("grant = %+v, err = %v; want full grant", g, err)
+	}
+}
@@ -0,0 +1,76 @@
+package wsbase
+
+import "fmt"
+
+// Limits caps the state one WebSocket connection can make the server hold.
+// A zero field imposes no limit.
+type Limits struct {
+	MaxMessageBytes   int // inbound text (JSON) message size
+	MaxSubscriptions  int // open subscriptions and follows per connection
+	MaxPendingFollows int // follows still waiting for the agent's first conversation
+	MaxFilterTypes    int // event types listed in one subscription filter
+	MaxFilterPatterns int // agentFilter patterns in one request
+	MaxPatternBytes   int // bytes in one agentFilter pattern
+}
+
+// DefaultLimits are generous for real clients but bound a fuzzing one.
+var DefaultLimits = Limits{
+	MaxMessageBytes:   1 << 20,
+	MaxSubscriptions:  256,
+	MaxPendingFollows: 64,
+	MaxFilterTypes:    32,
+	MaxFilterPatterns: 32,
+	MaxPatternBytes:   256,
+}
+
+// LimitError reports a request refused by a Limits field. It is sent to
+// clients as the error message's "limit" object.
+type LimitError struct {
+	Limit string `json:"limit"` // "message-bytes", "subscriptions", "pending-follows", "filter-types", "filter-patterns", or "pattern-bytes"
+	Max   int    `json:"max"`
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit exceeded (max %d)", e.Limit, e.Max)
+}
+
+// CheckMessage refuses a text message of size bytes.
+func (l Limits) CheckMessage(size int) *LimitError {
+	return exceeds("message-bytes", size, l.MaxMessageBytes)
+}
+
+// CheckSubscriptions refuses opening a subscription when open are already held.
+func (l Limits) CheckSubscriptions(open int) *LimitError {
+	return exceeds("subscriptions", open+1, l.MaxSubscriptions)
+}
+
+// CheckPendingFollows refuses a pending follow when pending are already held.
+func (l Limits) CheckPendingFollows(pending int) *LimitError {
+	return exceeds("pending-follows", pending+1, l.MaxPendingFollows)
+}
+
+// CheckFilterTypes refuses a filter listing n event types.
+func (l Limits) CheckFilterTypes(n int) *LimitError {
+	return exceeds("filter-types", n, l.MaxFilterTypes)
+}
+
+// CheckNameFilter refuses an agentFilter with too many patterns, or with a
+// pattern too long.
+func (l Limits) CheckNameFilter(patterns []string) *LimitError {
+	if lerr := exceeds("filter-patterns", len(patterns), l.MaxFilterPatterns); lerr != nil {
+		return lerr
+	}
+	for _, p := range patterns {
+		if lerr := exceeds("pattern-bytes", len(p), l.MaxPatternBytes); lerr != nil {
+			return lerr
+		}
+	}
+	return nil
+}
+
+func exceeds(limit string, n, max int) *LimitError {
+	if max > 0 && n > max {
+		return &LimitError{Limit: limit, Max: max}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package wsbase
+
+import "testing"
+
+func TestLimits(t *testing.T) {
+	l := Limits{MaxMessageBytes: 10, MaxSubscriptions: 2, MaxPendingFollows: 1}
+
+	if lerr := l.CheckMessage(10); lerr != nil {
+		t.Fatalf("CheckMessage(10) = %v, want nil", lerr)
+	}
+	if lerr := l.CheckMessage(11); lerr == nil || lerr.Limit != "message-bytes" || lerr.Max != 10 {
+		t.Fatalf("CheckMessage(11) = %v, want message-bytes max 10", lerr)
+	}
+	if lerr := l.CheckSubscriptions(1); lerr != nil {
+		t.Fatalf("CheckSubscriptions(1 open) = %v, want nil", lerr)
+	}
+	if lerr := l.CheckSubscriptions(2); lerr == nil || lerr.Limit != "subscriptions" {
+		t.Fatalf("CheckSubscriptions(2 open) = %v, want subscriptions error", lerr)
+	}
+	if lerr := l.CheckPendingFollows(1); lerr == nil {
+		t.Fatal("CheckPendingFollows(1 pending) = nil, want error")
+	}
+	if lerr := l.CheckFilterTypes(1000); lerr != nil {
+		t.Fatalf("CheckFilterTypes with no limit = %v, want nil", lerr)
+	}
+
+	l = Limits{MaxFilterPatterns: 2, MaxPatternBytes: 8}
+	if lerr := l.CheckNameFilter([]string{"team-*", "!*-tmp"}); lerr != nil {
+		t.Fatalf("CheckNameFilter(2 short) = %v, want nil", lerr)
+	}
+	if lerr := l.CheckNameFilter([]string{"a", "b", "c"}); lerr == nil || lerr.Limit != "filter-patterns" {
+		t.Fatalf("CheckNameFilter(3 patterns) = %v, want filter-patterns error", lerr)
+	}
+	if lerr := l.CheckNameFilter([]string{"much-too-long"}); lerr == nil || lerr.Limit != "pattern-bytes" {
+		t.Fatalf("CheckNameFilter(long pattern) = %v, want pattern-bytes error", lerr)
+	}
+}
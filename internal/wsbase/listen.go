@@ -0,0 +1,16 @@
+package wsbase
+
+import (
+	"context"
+	"net"
+)
+
+// Listen opens a TCP listener on addr. With reusePort, SO_REUSEPORT is set so
+// a replacement process can bind the same address while this one drains.
+func Listen(addr string, reusePort bool) (net.Listener, error) {
+	if !reusePort {
+		return net.Listen("tcp", addr)
+	}
+	lc := net.ListenConfig{Control: reusePortControl}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package wsbase
+
+import (
+	"errors"
+	"syscall"
+)
+
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
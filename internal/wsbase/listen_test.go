@@ -0,0 +1,32 @@
+//go:build unix
+
+package wsbase
+
+import "testing"
+
+func TestListenReusePort(t *testing.T) {
+	first, err := Listen("127.0.0.1:0", true)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer func() { _ = first.Close() }()
+
+	second, err := Listen(first.Addr().String(), true)
+	if err != nil {
+		t.Fatalf("second Listen() on %s error = %v, want shared bind", first.Addr(), err)
+	}
+	_ = second.Close()
+}
+
+func TestListenWithoutReusePortConflicts(t *testing.T) {
+	first, err := Listen("127.0.0.1:0", false)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer func() { _ = first.Close() }()
+
+	if second, err := Listen(first.Addr().String(), false); err == nil {
+		_ = second.Close()
+		t.Fatal("second Listen() succeeded without reusePort, want address in use")
+	}
+}
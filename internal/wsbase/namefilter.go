@@ -0,0 +1,126 @@
+package wsbase
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// NameFilter selects agents by name for list-agents and subscribe-agents.
+//
+// Each pattern is a glob ("team-*", filepath.Match syntax) or a regular
+// expression; a leading "!" excludes the names it matches instead. A name
+// passes if it matches any including pattern, or there are none, and no
+// excluding pattern. A nil NameFilter passes every name.
+type NameFilter struct {
+	include []func(string) bool
+	exclude []func(string) bool
+}
+
+// regexOnly holds characters that mean nothing special in a glob, so a
+// pattern using them was written as a regular expression.
+const regexOnly = `^$()+|\{}`
+
+// filterCacheSize bounds the compiled filters kept for reuse. Clients tend
+// to send the same few filters on every list-agents poll, so a small cache
+// spares recompiling their regexes each request.
+const filterCacheSize = 256
+
+var filterCache = struct {
+	mu      sync.Mutex
+	filters map[string]*NameFilter
+}{filters: make(map[string]*NameFilter)}
+
+// ParseNameFilter compiles patterns. syntax is "glob", "regex", or "" to
+// decide per pattern: a pattern using regular-expression-only characters
+// such as "^" or "|" is a regex, anything else a glob. A pattern containing
+// "." is ambiguous ("team-.*" is a regex, but also a glob that never
+// matches, since tmux session names have no dots), so it needs an explicit
+// syntax. Regexes match anywhere in the name unless anchored; globs match
+// the whole name. A NameFilter is immutable, so the result is shared with
+// earlier calls that passed the same patterns and syntax; callers keep it
+// for the life of a subscription rather than reparsing per event.
+func ParseNameFilter(patterns []string, syntax string) (*NameFilter, error) {
+	if syntax != "" && syntax != "glob" && syntax != "regex" {
+		return nil, fmt.Errorf("unknown filterSyntax %q: want glob or regex", syntax)
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	key := syntax + "\x00" + strings.Join(patterns, "\x00")
+	filterCache.mu.Lock()
+	f, ok := filterCache.filters[key]
+	filterCache.mu.Unlock()
+	if ok {
+		return f, nil
+	}
+	f, err := parseNameFilter(patterns, syntax)
+	if err != nil {
+		return nil, err
+	}
+	filterCache.mu.Lock()
+	if len(filterCache.filters) >= filterCacheSize {
+		clear(filterCache.filters)
+	}
+	filterCache.filters[key] = f
+	filterCache.mu.Unlock()
+	return f, nil
+}
+
+func parseNameFilter(patterns []string, syntax string) (*NameFilter, error) {
+	f := &NameFilter{}
+	for _, raw := range patterns {
+		pat, negate := strings.CutPrefix(raw, "!")
+		if pat == "" {
+			return nil, fmt.Errorf("empty agent filter pattern %q", raw)
+		}
+		if syntax == "" && strings.Contains(pat, ".") {
+			return nil, fmt.Errorf("agent filter %q contains \".\"; set filterSyntax to glob or regex", raw)
+		}
+		var match func(string) bool
+		if syntax == "regex" || syntax == "" && strings.ContainsAny(pat, regexOnly) {
+			re, err := regexp.Compile(pat)
+			if err != nil {
+				return nil, fmt.Errorf("agent filter %q: %w", raw, err)
+			}
+			match = re.MatchString
+		} else {
+			if _, err := filepath.Match(pat, ""); err != nil {
+				return nil, fmt.Errorf("agent filter %q: %w", raw, err)
+			}
+			match = func(name string) bool {
+				ok, _ := filepath.Match(pat, name)
+				return ok
+			}
+		}
+		if negate {
+			f.exclude = append(f.exclude, match)
+		} else {
+			f.include = append(f.include, match)
+		}
+	}
+	return f, nil
+}
+
+// Matches reports whether the filter passes name.
+func (f *NameFilter) Matches(name string) bool {
+	if f == nil {
+		return true
+	}
+	for _, match := range f.exclude {
+		if match(name) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, match := range f.include {
+		if match(name) {
+			return true
+		}
+	}
+	return false
+}
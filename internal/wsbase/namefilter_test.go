@@ -0,0 +1,94 @@
+package wsbase
+
+import "testing"
+
+func TestNameFilterGlobs(t *testing.T) {
+	f, err := ParseNameFilter([]string{"team-*", "!*-scratch"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"team-alpha":   true,
+		"team-scratch": false,
+		"hq-mayor":     false,
+	}
+	for name, want := range cases {
+		if got := f.Matches(name); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	only, err := ParseNameFilter([]string{"!*-scratch"}, "glob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !only.Matches("hq-mayor") || only.Matches("hq-scratch") {
+		t.Fatal("exclusion-only filter should pass everything else")
+	}
+}
+
+func TestNameFilterRegex(t *testing.T) {
+	// Detected from "^" and "|"; unanchored regexes match anywhere.
+	f, err := ParseNameFilter([]string{"^hq-(mayor|deacon)$", "!witness"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Matches("hq-mayor") || f.Matches("hq-crew") {
+		t.Fatal("detected regex did not apply")
+	}
+
+	f, err = ParseNameFilter([]string{"team-.*"}, "regex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Matches("team-alpha") {
+		t.Fatal("explicit regex with \".\" did not apply")
+	}
+
+	f, err = ParseNameFilter([]string{"crew"}, "regex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Matches("gt-rig1-crew-max") {
+		t.Fatal("explicit regex should match a substring")
+	}
+}
+
+func TestNameFilterErrors(t *testing.T) {
+	if f, err := ParseNameFilter(nil, ""); err != nil || !f.Matches("anything") {
+		t.Fatalf("no patterns = %v, %v; want a filter passing everything", f, err)
+	}
+	for _, tc := range []struct {
+		patterns []string
+		syntax   string
+	}{
+		{[]string{"[invalid"}, "glob"},
+		{[]string{"[invalid"}, "regex"},
+		{[]string{"("}, ""},
+		{[]string{"!"}, ""},
+		{[]string{"team-.*"}, ""}, // glob or regex? filterSyntax must say
+		{[]string{"^hq-.*$"}, ""},
+		{[]string{"a*"}, "shell"},
+	} {
+		if _, err := ParseNameFilter(tc.patterns, tc.syntax); err == nil {
+			t.Errorf("ParseNameFilter(%q, %q) should fail", tc.patterns, tc.syntax)
+		}
+	}
+}
+
+func TestNameFilterReusesCompiled(t *testing.T) {
+	a, err := ParseNameFilter([]string{"^crew-", "!crew-max"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ParseNameFilter([]string{"^crew-", "!crew-max"}, "")
+	if a != b {
+		t.Error("the same patterns should reuse the compiled filter")
+	}
+	if c, _ := ParseNameFilter([]string{"^crew-", "!crew-max"}, "regex"); c == a {
+		t.Error("a different syntax should compile its own filter")
+	}
+	if d, _ := ParseNameFilter([]string{"^crew-!crew-max"}, ""); d == a {
+		t.Error("joined patterns should not collide with separate ones")
+	}
+}
@@ -0,0 +1,130 @@
+package wsbase
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// OriginPolicy decides which browser origins may open a WebSocket.
+//
+// Patterns come in three forms:
+//   - "localhost:*" matches the origin's host:port under any scheme.
+//   - "https://*.example.com" or "*://10.0.0.*:*" also match the scheme;
+//     "file://*" admits Electron and other file:// pages.
+//   - "null" admits the opaque "Origin: null" sent by sandboxed pages.
+//
+// Host and scheme parts use filepath.Match syntax. A lone "*" allows every origin.
+// Requests whose remote address is inside an allowed CIDR skip origin checks,
+// for LAN clients reaching the server by IP. Same-host origins are always allowed.
+type OriginPolicy struct {
+	patterns []originPattern
+	any      bool
+	null     bool
+	remote   []*net.IPNet
+}
+
+type originPattern struct {
+	scheme string // "" matches any scheme
+	host   string
+}
+
+// ParseOriginPolicy builds a policy from origin patterns and remote CIDRs
+// such as "192.168.0.0/16".
+func ParseOriginPolicy(patterns, remoteCIDRs []string) (*OriginPolicy, error) {
+	p := &OriginPolicy{}
+	for _, raw := range patterns {
+		pat := strings.ToLower(strings.TrimSpace(raw))
+		switch pat {
+		case "":
+			continue
+		case "*":
+			p.any = true
+			continue
+		case "null":
+			p.null = true
+			continue
+		}
+		op := originPattern{host: pat}
+		if scheme, host, ok := strings.Cut(pat, "://"); ok {
+			op = originPattern{scheme: scheme, host: host}
+		}
+		if _, err := filepath.Match(op.host, ""); err != nil {
+			return nil, fmt.Errorf("origin pattern %q: %w", raw, err)
+		}
+		if _, err := filepath.Match(op.scheme, ""); err != nil {
+			return nil, fmt.Errorf("origin pattern %q: %w", raw, err)
+		}
+		p.patterns = append(p.patterns, op)
+	}
+	for _, cidr := range remoteCIDRs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("remote CIDR %q: %w", cidr, err)
+		}
+		p.remote = append(p.remote, ipNet)
+	}
+	return p, nil
+}
+
+// Check returns nil if the request may upgrade, or an error naming the
+// origin and remote address that were rejected.
+func (p *OriginPolicy) Check(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.any || p.remoteAllowed(remoteIP(r)) {
+		return nil
+	}
+	if origin == "null" {
+		if p.null {
+			return nil
+		}
+		return fmt.Errorf("origin %q from %s not allowed", origin, r.RemoteAddr)
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("origin %q from %s: %w", origin, r.RemoteAddr, err)
+	}
+	if u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	for _, op := range p.patterns {
+		if op.matches(scheme, host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q from %s not allowed", origin, r.RemoteAddr)
+}
+
+func (op originPattern) matches(scheme, host string) bool {
+	if op.scheme == "" {
+		// Host-only patterns never admit host-less origins like file://.
+		if host == "" {
+			return false
+		}
+	} else if ok, _ := filepath.Match(op.scheme, scheme); !ok {
+		return false
+	}
+	ok, _ := filepath.Match(op.host, host)
+	return ok
+}
+
+func (p *OriginPolicy) remoteAllowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range p.remote {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package wsbase
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginPolicy(t *testing.T) {
+	policy, err := ParseOriginPolicy(
+		[]string{"localhost:*", "https://*.example.com", "file://*", "null"},
+		[]string{"192.168.0.0/16"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		origin string
+		remote string
+		allow  bool
+	}{
+		{"no origin", "", "10.0.0.1:5000", true},
+		{"host pattern any scheme", "http://localhost:3000", "10.0.0.1:5000", true},
+		{"scheme and host pattern", "https://app.example.com", "10.0.0.1:5000", true},
+		{"wrong scheme", "http://app.example.com", "10.0.0.1:5000", false},
+		{"file origin", "file://", "10.0.0.1:5000", true},
+		{"null origin", "null", "10.0.0.1:5000", true},
+		{"same host", "http://adapter.test:8080", "10.0.0.1:5000", true},
+		{"unknown origin", "https://evil.test", "10.0.0.1:5000", false},
+		{"unknown origin from allowed CIDR", "https://evil.test", "192.168.1.20:5000", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://adapter.test:8080/ws", nil)
+			req.RemoteAddr = tt.remote
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if err := policy.Check(req); (err == nil) != tt.allow {
+				t.Fatalf("Check(%q from %s) = %v, want allow=%v", tt.origin, tt.remote, err, tt.allow)
+			}
+		})
+	}
+}
+
+func TestOriginPolicyHostPatternRejectsFileAndNull(t *testing.T) {
+	policy, err := ParseOriginPolicy([]string{"localhost:*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, origin := range []string{"file://", "null"} {
+		req := httptest.NewRequest("GET", "http://adapter.test:8080/ws", nil)
+		req.Header.Set("Origin", origin)
+		if policy.Check(req) == nil {
+			t.Fatalf("origin %q allowed by a host-only pattern", origin)
+		}
+	}
+}
+
+func TestParseOriginPolicyRejectsBadInput(t *testing.T) {
+	if _, err := ParseOriginPolicy([]string{"[bad"}, nil); err == nil {
+		t.Fatal("expected malformed pattern to fail")
+	}
+	if _, err := ParseOriginPolicy(nil, []string{"192.168.0.0"}); err == nil {
+		t.Fatal("expected CIDR without a prefix length to fail")
+	}
+}
@@ -0,0 +1,70 @@
+package wsbase
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor rejects a page cursor this server didn't issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page selects a window of a sorted list: at most Limit items (0 for all),
+// starting Offset items in or, with a Cursor from a previous page, just after
+// the item that page ended on.
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+// Paginate returns the items of sorted that p selects, and a cursor for the
+// next page if any remain. key identifies an item; a cursor resumes after
+// its item's key, or at its position if that item has since gone.
+func Paginate[T any](sorted []T, key func(T) string, p Page) (page []T, next string, err error) {
+	start := max(p.Offset, 0)
+	if p.Cursor != "" {
+		pos, after, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = pos
+		for i, item := range sorted {
+			if key(item) == after {
+				start = i + 1
+				break
+			}
+		}
+	}
+	start = min(start, len(sorted))
+	end := len(sorted)
+	if p.Limit > 0 {
+		end = min(start+p.Limit, end)
+	}
+	page = sorted[start:end]
+	if end < len(sorted) && end > start {
+		next = encodeCursor(end, key(sorted[end-1]))
+	}
+	return page, next, nil
+}
+
+func encodeCursor(pos int, after string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(pos) + ":" + after))
+}
+
+func decodeCursor(cursor string) (pos int, after string, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, "", ErrInvalidCursor
+	}
+	n, after, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return 0, "", ErrInvalidCursor
+	}
+	pos, err = strconv.Atoi(n)
+	if err != nil || pos < 0 {
+		return 0, "", ErrInvalidCursor
+	}
+	return pos, after, nil
+}
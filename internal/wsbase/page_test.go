@@ -0,0 +1,61 @@
+package wsbase
+
+import (
+	"slices"
+	"testing"
+)
+
+func identity(s string) string { return s }
+
+func TestPaginateWalksPagesWithCursor(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+	var got []string
+	p := Page{Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("cursor never ran out")
+		}
+		page, next, err := Paginate(items, identity, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, page...)
+		if next == "" {
+			break
+		}
+		p.Cursor = next
+	}
+	if !slices.Equal(got, items) {
+		t.Fatalf("pages = %v, want %v", got, items)
+	}
+}
+
+func TestPaginateCursorSurvivesRemoval(t *testing.T) {
+	_, next, err := Paginate([]string{"a", "b", "c", "d"}, identity, Page{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "a" went away: the next page still starts after "b".
+	page, _, err := Paginate([]string{"b", "c", "d"}, identity, Page{Limit: 2, Cursor: next})
+	if err != nil || !slices.Equal(page, []string{"c", "d"}) {
+		t.Fatalf("page = %v, %v; want [c d]", page, err)
+	}
+	// "b" went away too: resume at the old position.
+	page, _, err = Paginate([]string{"c", "d", "e"}, identity, Page{Limit: 2, Cursor: next})
+	if err != nil || !slices.Equal(page, []string{"e"}) {
+		t.Fatalf("page = %v, %v; want [e]", page, err)
+	}
+}
+
+func TestPaginateOffsetAndBadCursor(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	if page, next, _ := Paginate(items, identity, Page{Offset: 1}); !slices.Equal(page, []string{"b", "c"}) || next != "" {
+		t.Fatalf("offset 1 = %v, next %q", page, next)
+	}
+	if page, _, _ := Paginate(items, identity, Page{Offset: 10}); len(page) != 0 {
+		t.Fatalf("offset past the end = %v", page)
+	}
+	if _, _, err := Paginate(items, identity, Page{Cursor: "!!"}); err != ErrInvalidCursor {
+		t.Fatalf("bad cursor error = %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package wsbase
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// MountPprof registers net/http/pprof handlers under /debug/pprof/ on mux,
+// authorized the same way as WebSocket connections. JWTs need the control
+// scope. Without configured credentials every request is refused, rather
+// than Authenticate's usual grant of everything.
+func MountPprof(mux *http.ServeMux, auth *Authenticator) {
+	guard := func(h http.HandlerFunc) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.Enabled() {
+				http.Error(w, "profiling requires server authentication to be configured", http.StatusForbidden)
+				return
+			}
+			if g, err := auth.Authenticate(r); err != nil || !g.Control {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h(w, r)
+		})
+	}
+	mux.Handle("/debug/pprof/", guard(pprof.Index))
+	mux.Handle("/debug/pprof/cmdline", guard(pprof.Cmdline))
+	mux.Handle("/debug/pprof/profile", guard(pprof.Profile))
+	mux.Handle("/debug/pprof/symbol", guard(pprof.Symbol))
+	mux.Handle("/debug/pprof/trace", guard(pprof.Trace))
+}
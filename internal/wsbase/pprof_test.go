@@ -0,0 +1,36 @@
+package wsbase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMountPprofRequiresToken(t *testing.T) {
+	mux := http.NewServeMux()
+	MountPprof(mux, NewAuthenticator("secret", nil, ExpireClose))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without token: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/?token=secret", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("with token: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestMountPprofRefusesWithoutCredentials(t *testing.T) {
+	for _, auth := range []*Authenticator{nil, NewAuthenticator("", nil, ExpireClose)} {
+		mux := http.NewServeMux()
+		MountPprof(mux, auth)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("auth %v: status = %d, want %d", auth, rec.Code, http.StatusForbidden)
+		}
+	}
+}
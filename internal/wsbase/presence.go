@@ -0,0 +1,90 @@
+package wsbase
+
+import "sync"
+
+// Presence counts the distinct clients viewing each agent. A client may hold
+// several subscriptions to the same agent; it is one viewer until the last
+// of them is released.
+type Presence struct {
+	mu      sync.Mutex
+	viewers map[string]map[any]int // agent name → client → open subscriptions
+}
+
+// NewPresence creates an empty presence tracker.
+func NewPresence() *Presence {
+	return &Presence{viewers: make(map[string]map[any]int)}
+}
+
+// Join records a subscription by client to agent. joined reports whether the
+// client just became a viewer; count is the agent's viewer count afterwards.
+func (p *Presence) Join(agent string, client any) (count int, joined bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clients, ok := p.viewers[agent]
+	if !ok {
+		clients = make(map[any]int)
+		p.viewers[agent] = clients
+	}
+	clients[client]++
+	return len(clients), clients[client] == 1
+}
+
+// Leave releases one subscription by client to agent. left reports whether
+// the client stopped being a viewer; count is the agent's viewer count afterwards.
+func (p *Presence) Leave(agent string, client any) (count int, left bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clients, ok := p.viewers[agent]
+	if !ok || clients[client] == 0 {
+		return len(clients), false
+	}
+	clients[client]--
+	if clients[client] > 0 {
+		return len(clients), false
+	}
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(p.viewers, agent)
+	}
+	return len(clients), true
+}
+
+// LeaveAll drops every subscription held by client and returns the new viewer
+// count of each agent it was viewing.
+func (p *Presence) LeaveAll(client any) map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	left := make(map[string]int)
+	for agent, clients := range p.viewers {
+		if _, ok := clients[client]; !ok {
+			continue
+		}
+		delete(clients, client)
+		left[agent] = len(clients)
+		if len(clients) == 0 {
+			delete(p.viewers, agent)
+		}
+	}
+	return left
+}
+
+// Count returns the number of clients viewing agent.
+func (p *Presence) Count(agent string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.viewers[agent])
+}
+
+// Rename moves the viewers of oldName to newName, for an agent whose
+// session was renamed.
+func (p *Presence) Rename(oldName, newName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if clients, ok := p.viewers[oldName]; ok {
+		delete(p.viewers, oldName)
+		p.viewers[newName] = clients
+	}
+}
@@ -0,0 +1,59 @@
+package wsbase
+
+import "testing"
+
+func TestPresenceCountsDistinctClients(t *testing.T) {
+	p := NewPresence()
+	a, b := new(int), new(int)
+
+	if count, joined := p.Join("hq-mayor", a); count != 1 || !joined {
+		t.Fatalf("first join = (%d, %v), want (1, true)", count, joined)
+	}
+	if count, joined := p.Join("hq-mayor", a); count != 1 || joined {
+		t.Fatalf("second subscription by same client = (%d, %v), want (1, false)", count, joined)
+	}
+	if count, joined := p.Join("hq-mayor", b); count != 2 || !joined {
+		t.Fatalf("second client join = (%d, %v), want (2, true)", count, joined)
+	}
+
+	if count, left := p.Leave("hq-mayor", a); count != 2 || left {
+		t.Fatalf("leave with a subscription remaining = (%d, %v), want (2, false)", count, left)
+	}
+	if count, left := p.Leave("hq-mayor", a); count != 1 || !left {
+		t.Fatalf("last leave = (%d, %v), want (1, true)", count, left)
+	}
+	if count, left := p.Leave("hq-mayor", a); count != 1 || left {
+		t.Fatalf("leave without join = (%d, %v), want (1, false)", count, left)
+	}
+}
+
+func TestPresenceLeaveAll(t *testing.T) {
+	p := NewPresence()
+	a, b := new(int), new(int)
+	p.Join("hq-mayor", a)
+	p.Join("hq-mayor", a)
+	p.Join("hq-mayor", b)
+	p.Join("gt-witness", a)
+
+	left := p.LeaveAll(a)
+	if len(left) != 2 || left["hq-mayor"] != 1 || left["gt-witness"] != 0 {
+		t.Fatalf("LeaveAll = %v, want map[gt-witness:0 hq-mayor:1]", left)
+	}
+	if p.Count("hq-mayor") != 1 || p.Count("gt-witness") != 0 {
+		t.Fatalf("counts after LeaveAll = %d, %d; want 1, 0", p.Count("hq-mayor"), p.Count("gt-witness"))
+	}
+}
+
+func TestPresenceRename(t *testing.T) {
+	p := NewPresence()
+	a := new(int)
+	p.Join("gt-gastown-crew-max", a)
+
+	p.Rename("gt-gastown-crew-max", "gt-gastown-crew-maxine")
+	if p.Count("gt-gastown-crew-max") != 0 || p.Count("gt-gastown-crew-maxine") != 1 {
+		t.Fatalf("counts after Rename = %d, %d; want 0, 1", p.Count("gt-gastown-crew-max"), p.Count("gt-gastown-crew-maxine"))
+	}
+	if count, left := p.Leave("gt-gastown-crew-maxine", a); !left || count != 0 {
+		t.Fatalf("leave under new name = (%d, %v), want (0, true)", count, left)
+	}
+}
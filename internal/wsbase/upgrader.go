@@ -8,10 +8,19 @@ import (
 )
 
 // AcceptWebSocket upgrades an HTTP request to a WebSocket connection
-// with the given origin patterns.
-func AcceptWebSocket(w http.ResponseWriter, r *http.Request, originPatterns []string) (*websocket.Conn, error) {
+// if its origin passes the policy. Rejections are logged and answered with 403.
+// The first of subprotocols the client offers in Sec-WebSocket-Protocol is
+// selected (see Conn.Subprotocol); clients offering none still connect.
+func AcceptWebSocket(w http.ResponseWriter, r *http.Request, origins *OriginPolicy, subprotocols ...string) (*websocket.Conn, error) {
+	if err := origins.Check(r); err != nil {
+		log.Printf("websocket rejected: %v", err)
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, err
+	}
 	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-		OriginPatterns: originPatterns,
+		// The policy above has already vetted the origin.
+		InsecureSkipVerify: true,
+		Subprotocols:       subprotocols,
 	})
 	if err != nil {
 		log.Printf("websocket accept: %v", err)
@@ -0,0 +1,255 @@
+package wsconv
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+	"github.com/gastownhall/tmux-adapter/internal/retention"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+// AdminHandler serves /ws/admin: runtime introspection and operator actions
+// (disconnect a client, force-release a conversation's tailers or an agent's
+// input control, check a buffer against its transcript, prune persisted data).
+type AdminHandler struct {
+	server *Server
+	token  string
+	pruner *retention.Pruner
+}
+
+// NewAdminHandler creates an admin endpoint for the server. The token is
+// required; an empty token rejects every request. pruner serves prune-now
+// and may be nil when no retention policy is configured.
+func NewAdminHandler(server *Server, token string, pruner *retention.Pruner) *AdminHandler {
+	return &AdminHandler{server: server, token: token, pruner: pruner}
+}
+
+type adminRequest struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	ClientID       string `json:"clientId,omitempty"`
+	ConversationID string `json:"conversationId,omitempty"`
+	Agent          string `json:"agent,omitempty"`
+}
+
+type adminResponse struct {
+	ID            string                   `json:"id,omitempty"`
+	Type          string                   `json:"type"`
+	OK            *bool                    `json:"ok,omitempty"`
+	Error         string                   `json:"error,omitempty"`
+	ControlledBy  string                   `json:"controlledBy,omitempty"` // release-control: the former holder
+	Clients       []adminClientInfo        `json:"clients,omitempty"`
+	Conversations []conv.ConversationStats `json:"conversations,omitempty"`
+	Runtime       *adminRuntimeInfo        `json:"runtime,omitempty"`
+	Pruned        *retention.Result        `json:"pruned,omitempty"`
+	Verify        *conv.VerifyReport       `json:"verify,omitempty"`
+}
+
+type adminClientInfo struct {
+	ID string `json:"id"`
+	wsbase.ConnInfo
+	Protocol         string                  `json:"protocol,omitempty"` // from hello
+	ConnectedAt      time.Time               `json:"connectedAt"`
+	HandshakeDone    bool                    `json:"handshakeDone"`
+	SubscribedAgents bool                    `json:"subscribedAgents"`
+	Debug            bool                    `json:"debug,omitempty"`
+	SendQueue        int                     `json:"sendQueue"`
+	Goroutines       int64                   `json:"goroutines"` // streams, pumps, and in-flight prompts and uploads
+	Subscriptions    []adminSubscriptionInfo `json:"subscriptions"`
+}
+
+type adminSubscriptionInfo struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversationId,omitempty"`
+	Agent          string `json:"agent,omitempty"`
+}
+
+type adminRuntimeInfo struct {
+	Goroutines int    `json:"goroutines"`
+	HeapAlloc  uint64 `json:"heapAlloc"`
+	HeapInuse  uint64 `json:"heapInuse"`
+	NumGC      uint32 `json:"numGC"`
+	Panics     int64  `json:"panics"` // recovered since startup
+}
+
+// ServeHTTP upgrades an authorized request and serves admin requests until the connection closes.
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token == "" || !wsbase.IsAuthorizedRequest(h.token, r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := wsbase.AcceptWebSocket(w, r, h.server.allowedOrigins)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close(websocket.StatusNormalClosure, "") }()
+
+	ctx := r.Context()
+	for {
+		typ, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		if typ != websocket.MessageText {
+			continue
+		}
+		resp := h.handle(data)
+		out, err := json.Marshal(resp)
+		if err != nil {
+			continue
+		}
+		wctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = conn.Write(wctx, websocket.MessageText, out)
+		cancel()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (h *AdminHandler) handle(data []byte) adminResponse {
+	var req adminRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return adminResponse{Type: "error", Error: "invalid JSON"}
+	}
+
+	switch req.Type {
+	case "get-stats":
+		return adminResponse{
+			ID:            req.ID,
+			Type:          "get-stats",
+			Clients:       h.server.clientInfo(),
+			Conversations: h.server.watcher.Stats(),
+			Runtime:       runtimeInfo(),
+		}
+	case "disconnect-client":
+		if req.ClientID == "" {
+			return adminResponse{ID: req.ID, Type: "error", Error: "clientId required"}
+		}
+		if !h.server.disconnectClient(req.ClientID) {
+			return adminResponse{ID: req.ID, Type: "disconnect-client", OK: boolPtr(false), Error: "client not found"}
+		}
+		return adminResponse{ID: req.ID, Type: "disconnect-client", OK: boolPtr(true)}
+	case "release-tailing":
+		if req.ConversationID == "" {
+			return adminResponse{ID: req.ID, Type: "error", Error: "conversationId required"}
+		}
+		if !h.server.watcher.ReleaseConversation(req.ConversationID) {
+			return adminResponse{ID: req.ID, Type: "release-tailing", OK: boolPtr(false), Error: "conversation not found"}
+		}
+		return adminResponse{ID: req.ID, Type: "release-tailing", OK: boolPtr(true)}
+	case "release-control":
+		if req.Agent == "" {
+			return adminResponse{ID: req.ID, Type: "error", Error: "agent required"}
+		}
+		holder, ok := h.server.control.ForceRelease(req.Agent)
+		if !ok {
+			return adminResponse{ID: req.ID, Type: "release-control", OK: boolPtr(false), Error: "agent not controlled"}
+		}
+		log.Printf("admin: released control of %s from %s", req.Agent, holder)
+		h.server.broadcastControl(req.Agent, "")
+		return adminResponse{ID: req.ID, Type: "release-control", OK: boolPtr(true), ControlledBy: holder}
+	case "verify-conversation":
+		if req.ConversationID == "" {
+			return adminResponse{ID: req.ID, Type: "error", Error: "conversationId required"}
+		}
+		report, err := h.server.watcher.Verify(req.ConversationID)
+		if err != nil {
+			return adminResponse{ID: req.ID, Type: "verify-conversation", OK: boolPtr(false), Error: err.Error()}
+		}
+		if !report.Match {
+			log.Printf("admin: verify-conversation %s: buffer diverges from transcript: %d missing, %d extra, last %s on disk vs %s buffered",
+				report.ConversationID, report.MissingCount, report.ExtraCount, report.LastDisk.StableID, report.LastBuffer.StableID)
+		}
+		return adminResponse{ID: req.ID, Type: "verify-conversation", OK: boolPtr(report.Match), Verify: &report}
+	case "prune-now":
+		if h.pruner == nil {
+			return adminResponse{ID: req.ID, Type: "prune-now", OK: boolPtr(false), Error: "no retention policy configured"}
+		}
+		res, err := h.pruner.PruneNow()
+		if err != nil {
+			return adminResponse{ID: req.ID, Type: "prune-now", OK: boolPtr(false), Error: err.Error(), Pruned: &res}
+		}
+		return adminResponse{ID: req.ID, Type: "prune-now", OK: boolPtr(true), Pruned: &res}
+	default:
+		return adminResponse{ID: req.ID, Type: "error", Error: "unknown message type: " + req.Type}
+	}
+}
+
+func (s *Server) clientInfo() []adminClientInfo {
+	s.mu.Lock()
+	clients := make([]*Client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	result := make([]adminClientInfo, 0, len(clients))
+	for _, c := range clients {
+		c.mu.Lock()
+		info := adminClientInfo{
+			ID:               c.id,
+			ConnInfo:         c.info,
+			Protocol:         c.protocol,
+			ConnectedAt:      c.connectedAt,
+			HandshakeDone:    c.handshakeDone,
+			SubscribedAgents: c.subscribedAgents.Load(),
+			Debug:            c.debug.Load(),
+			SendQueue:        len(c.send),
+			Goroutines:       c.goroutines.Load(),
+			Subscriptions:    make([]adminSubscriptionInfo, 0, len(c.subs)),
+		}
+		for _, sub := range c.subs {
+			info.Subscriptions = append(info.Subscriptions, adminSubscriptionInfo{
+				ID:             sub.id,
+				ConversationID: sub.conversationID,
+				Agent:          sub.agentName,
+			})
+		}
+		c.mu.Unlock()
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ConnectedAt.Before(result[j].ConnectedAt) })
+	return result
+}
+
+func (s *Server) disconnectClient(id string) bool {
+	// Closing waits on the close handshake, so it runs after the lock is released.
+	var target *Client
+	s.mu.Lock()
+	for c := range s.clients {
+		if c.id == id {
+			target = c
+			break
+		}
+	}
+	s.mu.Unlock()
+	if target == nil {
+		return false
+	}
+	_ = target.conn.Close(websocket.StatusPolicyViolation, "disconnected by admin")
+	target.cancel()
+	return true
+}
+
+func runtimeInfo() *adminRuntimeInfo {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return &adminRuntimeInfo{
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  m.HeapAlloc,
+		HeapInuse:  m.HeapInuse,
+		NumGC:      m.NumGC,
+		Panics:     crash.Panics(),
+	}
+}
@@ -0,0 +1,22 @@
+package wsconv
+
+import (
+	"nhooyr.io/websocket"
+)
+
+// watchGrantExpiry closes the connection, or drops it to read-only, when
+// its token expires.
+func (c *Client) watchGrantExpiry() {
+	c.grant.WatchExpiry(c.ctx, c.server.auth.OnExpiry(), func(readOnly bool) {
+		if !readOnly {
+			c.logf("token expired, closing")
+			_ = c.conn.Close(websocket.StatusPolicyViolation, "token expired")
+			return
+		}
+		c.logf("token expired, now read-only")
+		for _, agentName := range c.server.control.ReleaseAll(c) {
+			c.server.broadcastControl(agentName, "")
+		}
+		c.sendJSON(serverMessage{Type: "auth-expired", Error: "token expired; connection is read-only"})
+	})
+}
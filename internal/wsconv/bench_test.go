@@ -0,0 +1,131 @@
+package wsconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+func benchEvents(n int) []conv.ConversationEvent {
+	events := make([]conv.ConversationEvent, n)
+	for i := range events {
+		events[i] = conv.ConversationEvent{
+			Seq:            int64(i),
+			EventID:        fmt.Sprintf("evt-%d", i),
+			Type:           conv.EventAssistant,
+			AgentName:      "bench-agent",
+			ConversationID: "claude:bench-agent:abc",
+			Timestamp:      time.Date(2026, 2, 14, 1, 45, 0, 0, time.UTC),
+			Runtime:        "claude",
+			Role:           "assistant",
+			Content:        []conv.ContentBlock{{Type: "text", Text: "I'll start by running the tests and then look at any failures."}},
+		}
+	}
+	return events
+}
+
+func BenchmarkEncodeConversationEvent(b *testing.B) {
+	event := benchEvents(1)[0]
+	msg := serverMessage{Type: "conversation-event", SubscriptionID: "sub-1", Event: &event}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeConversationSnapshot(b *testing.B) {
+	for _, n := range []int{100, 1000, 20000} {
+		b.Run(fmt.Sprintf("events=%d", n), func(b *testing.B) {
+			msg := serverMessage{Type: "conversation-snapshot", SubscriptionID: "sub-1", Events: benchEvents(n)}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := json.Marshal(msg)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(data)))
+			}
+		})
+	}
+}
+
+// BenchmarkFilterFanout is the per-event cost of the broadcast filtering
+// path: 100 subscriptions with type filters, each matching the event and
+// encoding it for the ones that want it. The agentFilter case adds a regex
+// agent filter per subscription, compiled once at subscribe time as the
+// server does, so only matching is timed.
+func BenchmarkFilterFanout(b *testing.B) {
+	for _, tc := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"types", nil},
+		{"agentFilter=regex", []string{"^(bench|crew)-", "!-scratch$"}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			event := benchEvents(1)[0]
+			filters := make([]conv.EventFilter, 100)
+			agents := make([]*wsbase.NameFilter, 100)
+			for i := range filters {
+				types := []string{conv.EventUser, conv.EventAssistant}
+				if i%2 == 1 {
+					types = []string{conv.EventToolUse, conv.EventToolResult}
+				}
+				filters[i] = buildFilter(&clientFilter{Types: types})
+				agent, err := wsbase.ParseNameFilter(tc.patterns, "")
+				if err != nil {
+					b.Fatal(err)
+				}
+				agents[i] = agent
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j, f := range filters {
+					if !agents[j].Matches(event.AgentName) || !f.Matches(event) {
+						continue
+					}
+					msg := serverMessage{Type: "conversation-event", SubscriptionID: "sub-1", Event: &event}
+					if _, err := json.Marshal(msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFilterFanoutEncodedOnce is BenchmarkFilterFanout with the event
+// encoded once per broadcast, as Server.Broadcast does.
+func BenchmarkFilterFanoutEncodedOnce(b *testing.B) {
+	event := benchEvents(1)[0]
+	filters := make([]conv.EventFilter, 100)
+	for i := range filters {
+		types := []string{conv.EventUser, conv.EventAssistant}
+		if i%2 == 1 {
+			types = []string{conv.EventToolUse, conv.EventToolResult}
+		}
+		filters[i] = buildFilter(&clientFilter{Types: types})
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		encoded, err := json.Marshal(&event)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, f := range filters {
+			if !f.Matches(event) {
+				continue
+			}
+			msg := serverMessage{Type: "conversation-event", SubscriptionID: "sub-1", Event: json.RawMessage(encoded)}
+			if _, err := json.Marshal(msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package wsconv
+
+import (
+	"log"
+	"time"
+)
+
+// serverTiming is echoed on responses to debug-mode clients.
+type serverTiming struct {
+	ReceivedAt time.Time `json:"receivedAt"`
+	SentAt     time.Time `json:"sentAt"`
+	ElapsedMs  float64   `json:"elapsedMs"`
+}
+
+const debugTimeFormat = "15:04:05.000000"
+
+// maxPendingTimings caps the arrival times kept for requests not yet answered
+// through sendJSON. Replies sent another way never claim theirs, so past the
+// cap the oldest is forgotten and its response goes out without serverTiming.
+const maxPendingTimings = 256
+
+// debugInbound logs a client message and remembers when it arrived so the
+// response can carry serverTiming.
+func (c *Client) debugInbound(msg clientMessage, size int) {
+	if !c.debug.Load() {
+		return
+	}
+	now := time.Now()
+	log.Printf("wsconv debug %s %s %s ← %s id=%q bytes=%d", now.Format(debugTimeFormat), c.id, c.info.RemoteAddr, msg.Type, msg.ID, size)
+	if msg.ID == "" {
+		return
+	}
+	c.debugMu.Lock()
+	if len(c.received) >= maxPendingTimings {
+		var oldestID string
+		var oldest time.Time
+		for id, at := range c.received {
+			if oldestID == "" || at.Before(oldest) {
+				oldestID, oldest = id, at
+			}
+		}
+		delete(c.received, oldestID)
+	}
+	c.received[msg.ID] = now
+	c.debugMu.Unlock()
+}
+
+// debugStamp attaches serverTiming to a response for a request seen by debugInbound.
+func (c *Client) debugStamp(msg *serverMessage) {
+	if !c.debug.Load() || msg.ID == "" {
+		return
+	}
+	c.debugMu.Lock()
+	receivedAt, ok := c.received[msg.ID]
+	delete(c.received, msg.ID)
+	c.debugMu.Unlock()
+	if !ok {
+		return
+	}
+	now := time.Now()
+	msg.ServerTiming = &serverTiming{
+		ReceivedAt: receivedAt,
+		SentAt:     now,
+		ElapsedMs:  float64(now.Sub(receivedAt).Microseconds()) / 1000,
+	}
+}
+
+// debugOutbound logs a server message about to be queued for the client.
+func (c *Client) debugOutbound(msgType string, size int, dropped bool) {
+	if !c.debug.Load() {
+		return
+	}
+	suffix := ""
+	if dropped {
+		suffix = " DROPPED (slow consumer)"
+	}
+	log.Printf("wsconv debug %s %s %s → %s bytes=%d%s", time.Now().Format(debugTimeFormat), c.id, c.info.RemoteAddr, msgType, size, suffix)
+}
@@ -0,0 +1,97 @@
+package wsconv
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+// ExportAPI serves GET /api/conversations/{id}/export: the conversation's
+// native transcript, byte for byte, so another machine can resume it. With
+// middleware configured it answers 403: native lines can't go through the
+// event pipeline, and sending them as they are would undo redaction.
+func (s *Server) ExportAPI() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorizeRead(w, r) {
+			return
+		}
+		if s.watcher.HasMiddleware() {
+			http.Error(w, "export is disabled while redaction is configured", http.StatusForbidden)
+			return
+		}
+		src, err := s.watcher.Source(r.PathValue("id"))
+		if err != nil {
+			writeConversationAPIError(w, err)
+			return
+		}
+		f, err := os.Open(src.Path)
+		if err != nil {
+			writeConversationAPIError(w, conv.ErrConversationNotFound)
+			return
+		}
+		defer func() { _ = f.Close() }()
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Only the bytes present now: a live transcript keeps growing, and a
+		// line cut off mid-write is dropped by the runtime on resume anyway.
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filepath.Base(src.Path)+`"`)
+		if _, err := io.CopyN(w, f, info.Size()); err != nil {
+			log.Printf("export %s: %v", src.ConversationID, err)
+		}
+	})
+}
+
+// ResumeHintAPI serves GET /api/conversations/{id}/resume-hint.
+func (s *Server) ResumeHintAPI() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorizeRead(w, r) {
+			return
+		}
+		hint, err := s.watcher.ResumeHint(r.PathValue("id"))
+		if err != nil {
+			writeConversationAPIError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(hint)
+	})
+}
+
+// authorizeRead answers the request itself unless its credentials may read
+// conversations.
+func (s *Server) authorizeRead(w http.ResponseWriter, r *http.Request) bool {
+	grant, err := s.auth.Authenticate(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if !grant.Read {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func writeConversationAPIError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, conv.ErrConversationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, conv.ErrResumeUnsupported):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
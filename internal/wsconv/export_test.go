@@ -0,0 +1,35 @@
+package wsconv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+func TestExportRefusedWhileRedacting(t *testing.T) {
+	tests := []struct {
+		name string
+		mw   []conv.Middleware
+		want int
+	}{
+		{name: "no middleware", want: http.StatusOK},
+		{name: "redaction", mw: []conv.Middleware{conv.NewRedactor(conv.DefaultRedactionRules).Middleware()}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, func(w *conv.ConversationWatcher) { w.Use(tt.mw...) })
+			mux := http.NewServeMux()
+			mux.Handle("GET /api/conversations/{id}/export", srv.ExportAPI())
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, apiRequest(http.MethodGet, "/api/conversations/claude:test/export", "viewer", ""))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusOK && rec.Body.String() != testTranscript {
+				t.Fatalf("exported %q, want the transcript unchanged", rec.Body)
+			}
+		})
+	}
+}
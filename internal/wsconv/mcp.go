@@ -0,0 +1,328 @@
+package wsconv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/version"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+const (
+	mcpMaxRequestBytes    = 4 << 20
+	mcpDefaultEventLimit  = 50
+	mcpMaxEventLimit      = 500
+	mcpLatestProtocol     = "2025-06-18"
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+)
+
+// mcpProtocols are the MCP revisions this server can speak; initialize echoes
+// the client's choice when it is one of them.
+var mcpProtocols = []string{mcpLatestProtocol, "2025-03-26", "2024-11-05"}
+
+// MCPHandler serves /mcp: the converter's agents as Model Context Protocol
+// tools (list_agents, read_conversation, send_prompt) over the streamable
+// HTTP transport. Each JSON-RPC request is answered with a plain JSON body;
+// the server never opens an SSE stream. Requests authenticate like /ws, but
+// only once the server has credentials configured, and browser pages must
+// come from an allowed API origin (see screenAPIRequest).
+type MCPHandler struct {
+	server *Server
+}
+
+// NewMCPHandler creates an MCP endpoint backed by the server's watcher and prompter.
+func NewMCPHandler(server *Server) *MCPHandler {
+	return &MCPHandler{server: server}
+}
+
+type mcpRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+var mcpTools = []mcpTool{
+	{
+		Name:        "list_agents",
+		Description: "List the AI coding agents running in tmux, with their runtime, active conversation, current model, and who (if anyone) holds input control.",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{}},
+	},
+	{
+		Name:        "read_conversation",
+		Description: "Read the most recent events of an agent's conversation: user prompts, assistant replies, tool calls and results.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"agent":          map[string]any{"type": "string", "description": "Agent name; reads its active conversation."},
+				"conversationId": map[string]any{"type": "string", "description": "Conversation to read instead of the agent's active one."},
+				"limit":          map[string]any{"type": "integer", "description": fmt.Sprintf("Number of most recent events to return (default %d, max %d).", mcpDefaultEventLimit, mcpMaxEventLimit)},
+				"types":          map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Only these event types, e.g. user, assistant, tool_use, tool_result."},
+			},
+		},
+	},
+	{
+		Name:        "send_prompt",
+		Description: "Type a prompt into an agent's terminal and press Enter. Returns once the prompt is submitted, not when the agent finishes; use read_conversation to follow the reply. The user event the prompt becomes carries metadata.promptId.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"agent":    map[string]any{"type": "string", "description": "Agent name."},
+				"prompt":   map[string]any{"type": "string", "description": "Prompt text."},
+				"promptId": map[string]any{"type": "string", "description": "ID to tag the prompt's user event with; one is generated if omitted."},
+			},
+			"required": []string{"agent", "prompt"},
+		},
+	},
+}
+
+func (h *MCPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	grant, status, err := h.server.screenAPIRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, mcpMaxRequestBytes))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var req mcpRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeMCP(w, mcpResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &mcpError{Code: jsonrpcParseError, Message: "parse error: " + err.Error()}})
+		return
+	}
+	if len(req.ID) == 0 {
+		// Notifications (notifications/initialized, cancellations) need no answer.
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	resp := mcpResponse{JSONRPC: "2.0", ID: req.ID}
+	resp.Result, resp.Error = h.handle(req, grant)
+	writeMCP(w, resp)
+}
+
+// screenAPIRequest applies the checks /mcp and /v1/ share, in order: the
+// server must have credentials configured, since these endpoints drive
+// agents; a browser page must come from an allowed API origin, which keeps
+// DNS-rebinding pages out as the MCP spec requires; the caller must
+// authenticate; and a POST body must be JSON, which a cross-site form can't
+// send. It returns the caller's grant, or the status and error to refuse the
+// request with.
+func (s *Server) screenAPIRequest(r *http.Request) (wsbase.Grant, int, error) {
+	if !s.auth.Enabled() {
+		return wsbase.Grant{}, http.StatusForbidden, errors.New("this endpoint requires server authentication to be configured")
+	}
+	if err := s.apiOrigins.Check(r); err != nil {
+		log.Printf("api: %s %s rejected: %v", r.Method, r.URL.Path, err)
+		return wsbase.Grant{}, http.StatusForbidden, errors.New("origin not allowed")
+	}
+	grant, err := s.auth.Authenticate(r)
+	if err != nil {
+		return wsbase.Grant{}, http.StatusUnauthorized, errors.New("unauthorized")
+	}
+	if r.Method == http.MethodPost {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			return wsbase.Grant{}, http.StatusUnsupportedMediaType, errors.New("content type must be application/json")
+		}
+	}
+	return grant, 0, nil
+}
+
+func (h *MCPHandler) handle(req mcpRequest, grant wsbase.Grant) (any, *mcpError) {
+	if req.JSONRPC != "2.0" {
+		return nil, &mcpError{Code: jsonrpcInvalidRequest, Message: `jsonrpc must be "2.0"`}
+	}
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		protocol := mcpLatestProtocol
+		if slices.Contains(mcpProtocols, params.ProtocolVersion) {
+			protocol = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": protocol,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "tmux-converter", "version": version.Version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &mcpError{Code: jsonrpcInvalidParams, Message: err.Error()}
+		}
+		if len(params.Arguments) == 0 {
+			params.Arguments = json.RawMessage("{}")
+		}
+		var text string
+		var err error
+		switch params.Name {
+		case "list_agents":
+			text, err = mcpJSON(h.server.agentList())
+		case "read_conversation":
+			text, err = h.readConversation(params.Arguments)
+		case "send_prompt":
+			text, err = h.sendPrompt(params.Arguments, grant)
+		default:
+			return nil, &mcpError{Code: jsonrpcInvalidParams, Message: "unknown tool: " + params.Name}
+		}
+		// Tool failures are results the calling model can read, not protocol errors.
+		if err != nil {
+			return mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+		}
+		return mcpToolResult{Content: []mcpContent{{Type: "text", Text: text}}}, nil
+	default:
+		return nil, &mcpError{Code: jsonrpcMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+func (h *MCPHandler) readConversation(raw json.RawMessage) (string, error) {
+	var args struct {
+		Agent          string   `json:"agent"`
+		ConversationID string   `json:"conversationId"`
+		Limit          int      `json:"limit"`
+		Types          []string `json:"types"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	convID := conv.CanonicalConversationID(args.ConversationID)
+	if convID == "" {
+		if args.Agent == "" {
+			return "", errors.New("agent or conversationId required")
+		}
+		if convID = h.server.watcher.GetActiveConversation(args.Agent); convID == "" {
+			return "", fmt.Errorf("no active conversation for agent %q", args.Agent)
+		}
+	}
+	buf := h.server.watcher.EnsureTailing(convID, conv.FullHistory)
+	if buf == nil {
+		return "", fmt.Errorf("conversation not found: %s", convID)
+	}
+
+	limit := args.Limit
+	if limit <= 0 {
+		limit = mcpDefaultEventLimit
+	}
+	limit = min(limit, mcpMaxEventLimit)
+	filter := conv.EventFilter{ExcludeProgress: true}
+	if len(args.Types) > 0 {
+		filter.Types = make(map[string]bool, len(args.Types))
+		for _, t := range args.Types {
+			filter.Types[t] = true
+		}
+	}
+	events := buf.Snapshot(filter)
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return mcpJSON(map[string]any{"conversationId": convID, "events": events})
+}
+
+func (h *MCPHandler) sendPrompt(raw json.RawMessage, grant wsbase.Grant) (string, error) {
+	var args struct {
+		Agent    string `json:"agent"`
+		Prompt   string `json:"prompt"`
+		PromptID string `json:"promptId"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.Agent == "" || args.Prompt == "" {
+		return "", errors.New("agent and prompt required")
+	}
+	if args.PromptID == "" {
+		args.PromptID = "mcp-" + randomHex(8)
+	}
+	if !grant.Prompt {
+		return "", wsbase.ErrPromptNotAllowed
+	}
+	if _, ok := h.server.registry.GetAgent(args.Agent); !ok {
+		return "", fmt.Errorf("agent not found: %s", args.Agent)
+	}
+	// An MCP caller never holds control, so any holder blocks it.
+	if err := h.server.control.CheckInput(args.Agent, h); err != nil {
+		return "", err
+	}
+
+	lock := h.server.prompter.GetLock(args.Agent)
+	lock.Lock()
+	defer lock.Unlock()
+	// Expected, like a WebSocket client's prompt, so its user event is
+	// tagged and linked to the send_prompt call of an agent that sent it.
+	var sent *conv.SentPrompt
+	_, err := h.server.prompter.DeliverPrompt(args.Agent, args.Prompt, false, func(text string) {
+		sent = h.server.watcher.ExpectPrompt(args.Agent, text, conv.PromptTag{PromptID: args.PromptID, Request: args.Prompt})
+	})
+	if err != nil {
+		if sent != nil {
+			sent.Withdraw()
+		}
+		return "", err
+	}
+	log.Printf("mcp: send_prompt %s (%d bytes, subject %q, promptId %s)", args.Agent, len(args.Prompt), grant.Subject, args.PromptID)
+	return mcpJSON(map[string]any{"agent": args.Agent, "promptId": args.PromptID, "sent": true})
+}
+
+func mcpJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func writeMCP(w http.ResponseWriter, resp mcpResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,296 @@
+package wsconv
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agentio"
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+// fakeTmux reports one live Claude agent, hq-mayor, to the registry.
+type fakeTmux struct {
+	gtDir   string
+	notifCh chan tmux.Notification
+}
+
+func (f *fakeTmux) ListSessions() ([]tmux.SessionInfo, error) {
+	return []tmux.SessionInfo{{Name: "hq-mayor"}}, nil
+}
+
+func (f *fakeTmux) GetPaneInfo(string) (tmux.PaneInfo, error) {
+	return tmux.PaneInfo{Command: "claude", PID: "100", WorkDir: filepath.Join(f.gtDir, "mayor")}, nil
+}
+
+func (f *fakeTmux) ShowEnvironment(string, string) (string, error) { return "", nil }
+
+func (f *fakeTmux) Notifications() <-chan tmux.Notification { return f.notifCh }
+
+// fileDiscoverer finds one transcript for every agent.
+type fileDiscoverer struct{ path string }
+
+func (d fileDiscoverer) FindConversations(string, string) (conv.DiscoveryResult, error) {
+	return conv.DiscoveryResult{Files: []conv.ConversationFile{{
+		Path:                 d.path,
+		NativeConversationID: "test",
+		ConversationID:       "claude:test",
+		Runtime:              "claude",
+	}}}, nil
+}
+
+const testTranscript = `{"type":"user","uuid":"u1","timestamp":"2026-02-14T01:44:54.253Z","message":{"role":"user","content":[{"type":"text","text":"hello"}]}}
+{"type":"assistant","uuid":"a1","timestamp":"2026-02-14T01:44:55.253Z","message":{"id":"msg_1","role":"assistant","model":"claude-test","content":[{"type":"text","text":"hi there"}]}}
+`
+
+// newTestServer serves hq-mayor, whose conversation claude:test is
+// testTranscript, to clients presenting the token "secret" (everything) or
+// a client certificate named "viewer" (read only). Prompts longer than 20
+// bytes are rejected before anything is typed, since there is no tmux.
+// setup configures the watcher before it starts.
+func newTestServer(t *testing.T, setup ...func(*conv.ConversationWatcher)) *Server {
+	t.Helper()
+	gtDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "test.jsonl")
+	if err := os.WriteFile(path, []byte(testTranscript), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	registry := agents.NewRegistry(&fakeTmux{gtDir: gtDir, notifCh: make(chan tmux.Notification)}, gtDir, nil)
+	if err := registry.Start(); err != nil {
+		t.Fatal(err)
+	}
+	watcher := conv.NewConversationWatcher(registry, 100)
+	watcher.RegisterRuntime("claude", fileDiscoverer{path: path}, func(agentName, convID string) conv.Parser {
+		return conv.NewClaudeParser(agentName, convID)
+	})
+	for _, fn := range setup {
+		fn(watcher)
+	}
+	watcher.Start()
+	t.Cleanup(watcher.Stop)
+	deadline := time.Now().Add(3 * time.Second)
+	for watcher.GetActiveConversation("hq-mayor") == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("hq-mayor's conversation was never discovered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	auth := wsbase.NewAuthenticator("secret", nil, wsbase.ExpireClose)
+	auth.SetCertScopes(map[string]wsbase.Grant{"viewer": {Read: true}})
+	origins, err := wsbase.ParseOriginPolicy([]string{"localhost:*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(watcher, nil, registry, Options{
+		Auth:         auth,
+		APIOrigins:   origins,
+		PromptPolicy: &agentio.PromptPolicy{MaxLength: 20},
+	})
+}
+
+// apiRequest builds a JSON POST authenticated as "secret", or as the
+// read-only "viewer" certificate when credential is "viewer".
+func apiRequest(method, path, credential, body string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if method == http.MethodPost {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	switch credential {
+	case "viewer":
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "viewer"}}}}}
+	case "":
+	default:
+		r.Header.Set("Authorization", "Bearer "+credential)
+	}
+	return r
+}
+
+// callMCP posts a JSON-RPC request and decodes the response.
+func callMCP(t *testing.T, h http.Handler, credential, method string, params any) mcpResponse {
+	t.Helper()
+	req := map[string]any{"jsonrpc": "2.0", "id": 1, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, apiRequest(http.MethodPost, "/mcp", credential, string(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d (%s)", method, w.Code, w.Body.String())
+	}
+	var resp struct {
+		mcpResponse
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: %v (%s)", method, err, w.Body.String())
+	}
+	resp.mcpResponse.Result = resp.Result
+	return resp.mcpResponse
+}
+
+// toolText calls a tool and returns its text and whether it failed.
+func toolText(t *testing.T, h http.Handler, credential, tool string, args any) (string, bool) {
+	t.Helper()
+	resp := callMCP(t, h, credential, "tools/call", map[string]any{"name": tool, "arguments": args})
+	if resp.Error != nil {
+		t.Fatalf("%s: protocol error %+v", tool, resp.Error)
+	}
+	var result mcpToolResult
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &result); err != nil || len(result.Content) != 1 {
+		t.Fatalf("%s: result %s", tool, resp.Result)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+func TestMCPRejectsBeforeHandling(t *testing.T) {
+	srv := newTestServer(t)
+	open := NewServer(srv.watcher, nil, srv.registry, Options{})
+	body := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	tests := []struct {
+		name    string
+		server  *Server
+		request func() *http.Request
+		want    int
+	}{
+		{name: "auth not configured", server: open, request: func() *http.Request {
+			return apiRequest(http.MethodPost, "/mcp", "", body)
+		}, want: http.StatusForbidden},
+		{name: "missing token", server: srv, request: func() *http.Request {
+			return apiRequest(http.MethodPost, "/mcp", "", body)
+		}, want: http.StatusUnauthorized},
+		{name: "wrong token", server: srv, request: func() *http.Request {
+			return apiRequest(http.MethodPost, "/mcp", "guess", body)
+		}, want: http.StatusUnauthorized},
+		{name: "foreign origin", server: srv, request: func() *http.Request {
+			r := apiRequest(http.MethodPost, "/mcp", "secret", body)
+			r.Header.Set("Origin", "http://attacker.example")
+			return r
+		}, want: http.StatusForbidden},
+		{name: "form body", server: srv, request: func() *http.Request {
+			r := apiRequest(http.MethodPost, "/mcp", "secret", body)
+			r.Header.Set("Content-Type", "text/plain")
+			return r
+		}, want: http.StatusUnsupportedMediaType},
+		{name: "GET", server: srv, request: func() *http.Request {
+			return apiRequest(http.MethodGet, "/mcp", "secret", "")
+		}, want: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		NewMCPHandler(tt.server).ServeHTTP(w, tt.request())
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+
+	// A local page and a JSON content type with parameters are fine.
+	r := apiRequest(http.MethodPost, "/mcp", "secret", body)
+	r.Header.Set("Origin", "http://localhost:3000")
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	w := httptest.NewRecorder()
+	NewMCPHandler(srv).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("local origin: status = %d (%s)", w.Code, w.Body.String())
+	}
+}
+
+func TestMCPInitializeAndToolsList(t *testing.T) {
+	h := NewMCPHandler(newTestServer(t))
+
+	resp := callMCP(t, h, "secret", "initialize", map[string]any{"protocolVersion": "2025-03-26"})
+	var init struct {
+		ProtocolVersion string         `json:"protocolVersion"`
+		Capabilities    map[string]any `json:"capabilities"`
+	}
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &init); err != nil {
+		t.Fatal(err)
+	}
+	if init.ProtocolVersion != "2025-03-26" || init.Capabilities["tools"] == nil {
+		t.Errorf("initialize = %+v, want the client's protocol and tools", init)
+	}
+	resp = callMCP(t, h, "secret", "initialize", map[string]any{"protocolVersion": "1999-01-01"})
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &init); err != nil || init.ProtocolVersion != mcpLatestProtocol {
+		t.Errorf("initialize with an unknown protocol = %+v, want %s", init, mcpLatestProtocol)
+	}
+
+	resp = callMCP(t, h, "secret", "tools/list", nil)
+	var list struct {
+		Tools []mcpTool `json:"tools"`
+	}
+	if err := json.Unmarshal(resp.Result.(json.RawMessage), &list); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "list_agents,read_conversation,send_prompt" {
+		t.Errorf("tools = %v", names)
+	}
+
+	if resp := callMCP(t, h, "secret", "resources/list", nil); resp.Error == nil || resp.Error.Code != jsonrpcMethodNotFound {
+		t.Errorf("unknown method error = %+v", resp.Error)
+	}
+}
+
+func TestMCPTools(t *testing.T) {
+	h := NewMCPHandler(newTestServer(t))
+
+	text, isErr := toolText(t, h, "viewer", "list_agents", nil)
+	if isErr || !strings.Contains(text, `"name":"hq-mayor"`) {
+		t.Errorf("list_agents = %s (error %v)", text, isErr)
+	}
+
+	text, isErr = toolText(t, h, "viewer", "read_conversation", map[string]any{"agent": "hq-mayor", "types": []string{"assistant"}})
+	var read struct {
+		ConversationID string                   `json:"conversationId"`
+		Events         []conv.ConversationEvent `json:"events"`
+	}
+	if isErr || json.Unmarshal([]byte(text), &read) != nil {
+		t.Fatalf("read_conversation = %s (error %v)", text, isErr)
+	}
+	if read.ConversationID != "claude:test" || len(read.Events) != 1 || read.Events[0].Content[0].Text != "hi there" {
+		t.Errorf("read_conversation = %+v, want the assistant reply", read)
+	}
+	if text, isErr := toolText(t, h, "viewer", "read_conversation", map[string]any{"limit": 1, "conversationId": "claude:test"}); isErr || strings.Count(text, `"type":`) == 0 {
+		t.Errorf("read_conversation by ID = %s (error %v)", text, isErr)
+	}
+	if text, isErr := toolText(t, h, "viewer", "read_conversation", map[string]any{"agent": "nobody"}); !isErr {
+		t.Errorf("read_conversation of an unknown agent = %s, want an error", text)
+	}
+
+	sendTests := []struct {
+		name       string
+		credential string
+		args       map[string]any
+		want       string
+	}{
+		{name: "read-only token", credential: "viewer", args: map[string]any{"agent": "hq-mayor", "prompt": "hi"}, want: wsbase.ErrPromptNotAllowed.Error()},
+		{name: "missing prompt", credential: "secret", args: map[string]any{"agent": "hq-mayor"}, want: "agent and prompt required"},
+		{name: "unknown agent", credential: "secret", args: map[string]any{"agent": "nobody", "prompt": "hi"}, want: "agent not found"},
+		{name: "prompt policy", credential: "secret", args: map[string]any{"agent": "hq-mayor", "prompt": strings.Repeat("x", 21)}, want: "rejected by max-length"},
+	}
+	for _, tt := range sendTests {
+		text, isErr := toolText(t, h, tt.credential, "send_prompt", tt.args)
+		if !isErr || !strings.Contains(text, tt.want) {
+			t.Errorf("send_prompt, %s = %q (error %v), want an error containing %q", tt.name, text, isErr, tt.want)
+		}
+	}
+
+	if resp := callMCP(t, h, "secret", "tools/call", map[string]any{"name": "rm_rf"}); resp.Error == nil || resp.Error.Code != jsonrpcInvalidParams {
+		t.Errorf("unknown tool error = %+v", resp.Error)
+	}
+}
@@ -0,0 +1,305 @@
+package wsconv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+const (
+	openAIMaxRequestBytes = 4 << 20
+	// openAITurnTimeout bounds how long a completion waits for the agent's turn to end.
+	openAITurnTimeout = 10 * time.Minute
+)
+
+// OpenAIHandler serves an experimental OpenAI-compatible API: /v1/models
+// lists agents, and /v1/chat/completions sends the last user message to the
+// agent named by "model", waits for its turn to end, and returns the
+// assistant's text, streamed as server-sent events when "stream" is set.
+// The agent keeps its own context, so earlier messages are not replayed.
+// Requests are screened like /mcp (see screenAPIRequest); completions need
+// the prompt scope.
+type OpenAIHandler struct {
+	server *Server
+}
+
+// NewOpenAIHandler creates the OpenAI-compatible endpoints for the server.
+func NewOpenAIHandler(server *Server) *OpenAIHandler {
+	return &OpenAIHandler{server: server}
+}
+
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+}
+
+// openAITurn collects the assistant's reply to one prompt.
+type openAITurn struct {
+	id       string
+	model    string
+	created  int64
+	promptID string // tags the user event the prompt becomes
+	text     strings.Builder
+	sent     map[int64]string // seq → text already taken from that event, which updates may extend
+}
+
+func (h *OpenAIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	grant, status, err := h.server.screenAPIRequest(r)
+	if err != nil {
+		errType := "invalid_request_error"
+		if status == http.StatusForbidden {
+			errType = "permission_error"
+		}
+		writeOpenAIError(w, status, errType, err.Error())
+		return
+	}
+	switch {
+	case r.URL.Path == "/v1/models" && r.Method == http.MethodGet:
+		h.listModels(w)
+	case r.URL.Path == "/v1/chat/completions" && r.Method == http.MethodPost:
+		h.chatCompletion(w, r, grant)
+	default:
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", r.Method+" "+r.URL.Path+" is not supported")
+	}
+}
+
+func (h *OpenAIHandler) listModels(w http.ResponseWriter) {
+	data := make([]map[string]any, 0)
+	for _, a := range h.server.agentList() {
+		data = append(data, map[string]any{"id": a.Name, "object": "model", "created": 0, "owned_by": a.Runtime})
+	}
+	writeOpenAI(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+func (h *OpenAIHandler) chatCompletion(w http.ResponseWriter, r *http.Request, grant wsbase.Grant) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, openAIMaxRequestBytes))
+	if err != nil {
+		writeOpenAIError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "request too large")
+		return
+	}
+	var req openAIChatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON: "+err.Error())
+		return
+	}
+	prompt := lastUserMessage(req.Messages)
+	if prompt == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "messages must end with a non-empty user message")
+		return
+	}
+	if !grant.Prompt {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", wsbase.ErrPromptNotAllowed.Error())
+		return
+	}
+	if _, ok := h.server.registry.GetAgent(req.Model); !ok {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("model %q is not a known agent", req.Model))
+		return
+	}
+	if err := h.server.control.CheckInput(req.Model, h); err != nil {
+		writeOpenAIError(w, http.StatusConflict, "invalid_request_error", err.Error())
+		return
+	}
+	convID := h.server.watcher.GetActiveConversation(req.Model)
+	buf := h.server.watcher.EnsureTailing(convID, conv.FullHistory)
+	if buf == nil {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "server_error", "agent has no active conversation to follow")
+		return
+	}
+
+	// Subscribe before sending so the reply can't slip past.
+	filter := conv.EventFilter{Types: map[string]bool{conv.EventUser: true, conv.EventAssistant: true, conv.EventTurnEnd: true}}
+	_, subID, live := buf.Subscribe(filter)
+	defer buf.Unsubscribe(subID)
+
+	turn := &openAITurn{id: "chatcmpl-" + randomHex(12), model: req.Model, created: time.Now().Unix(), promptID: "openai-" + randomHex(8)}
+	lock := h.server.prompter.GetLock(req.Model)
+	lock.Lock()
+	// Expected like any other client's prompt, so the reply is told apart
+	// from turns other clients started by the promptId on its user event.
+	var sent *conv.SentPrompt
+	_, err = h.server.prompter.DeliverPrompt(req.Model, prompt, false, func(text string) {
+		sent = h.server.watcher.ExpectPrompt(req.Model, text, conv.PromptTag{PromptID: turn.promptID, Request: prompt})
+	})
+	lock.Unlock()
+	if err != nil {
+		if sent != nil {
+			sent.Withdraw()
+		}
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	log.Printf("openai: chat completion for %s (%d bytes, stream=%v, promptId %s)", req.Model, len(prompt), req.Stream, turn.promptID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), openAITurnTimeout)
+	defer cancel()
+
+	if !req.Stream {
+		err := turn.collect(ctx, live, nil)
+		if err != nil && turn.text.Len() == 0 {
+			writeOpenAIError(w, http.StatusGatewayTimeout, "server_error", err.Error())
+			return
+		}
+		writeOpenAI(w, http.StatusOK, map[string]any{
+			"id":      turn.id,
+			"object":  "chat.completion",
+			"created": turn.created,
+			"model":   turn.model,
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": turn.text.String()},
+				"finish_reason": "stop",
+			}},
+		})
+		return
+	}
+	if err := turn.stream(ctx, w, live); err != nil {
+		log.Printf("openai: chat completion for %s ended early: %v", req.Model, err)
+	}
+}
+
+// stream writes the reply as server-sent chat.completion.chunk events: the
+// assistant role, each piece of text as collect finds it, a stop chunk, and
+// [DONE]. The stream is closed normally even if collect gave up early; its
+// error is returned for logging.
+func (t *openAITurn) stream(ctx context.Context, w http.ResponseWriter, live <-chan conv.ConversationEvent) error {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	send := func(delta map[string]any, finish any) {
+		chunk, _ := json.Marshal(map[string]any{
+			"id":      t.id,
+			"object":  "chat.completion.chunk",
+			"created": t.created,
+			"model":   t.model,
+			"choices": []map[string]any{{"index": 0, "delta": delta, "finish_reason": finish}},
+		})
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", chunk)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	send(map[string]any{"role": "assistant"}, nil)
+	err := t.collect(ctx, live, func(text string) { send(map[string]any{"content": text}, nil) })
+	send(map[string]any{}, "stop")
+	_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	return err
+}
+
+// collect gathers assistant text until the turn ends, passing each new piece
+// to onText if set. An event updated in place contributes only the text it
+// gained. The turn starts at the user event tagged with the turn's promptID;
+// events before it, including other clients' prompts and the replies to
+// them, are skipped.
+func (t *openAITurn) collect(ctx context.Context, live <-chan conv.ConversationEvent, onText func(string)) error {
+	promptSeen := false
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.New("timed out waiting for the agent's turn to end")
+		case e, ok := <-live:
+			if !ok {
+				return errors.New("conversation closed")
+			}
+			if e.SubagentID != "" {
+				continue
+			}
+			switch e.Type {
+			case conv.EventUser:
+				if id, _ := e.Metadata["promptId"].(string); id == t.promptID {
+					promptSeen = true
+				}
+			case conv.EventAssistant:
+				if !promptSeen {
+					continue
+				}
+				var parts []string
+				for _, b := range e.Content {
+					if b.Type == "text" && b.Text != "" {
+						parts = append(parts, b.Text)
+					}
+				}
+				full := strings.Join(parts, "\n\n")
+				prev := t.sent[e.Seq]
+				if !strings.HasPrefix(full, prev) || len(full) == len(prev) {
+					continue
+				}
+				piece := full[len(prev):]
+				if prev == "" && t.text.Len() > 0 {
+					piece = "\n\n" + piece
+				}
+				if t.sent == nil {
+					t.sent = make(map[int64]string)
+				}
+				t.sent[e.Seq] = full
+				t.text.WriteString(piece)
+				if onText != nil {
+					onText(piece)
+				}
+			case conv.EventTurnEnd:
+				if promptSeen {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// lastUserMessage returns the text of the final message if it is from the
+// user. Content may be a string or an array of parts; only text parts count.
+func lastUserMessage(messages []openAIMessage) string {
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return ""
+	}
+	content := messages[len(messages)-1].Content
+	var s string
+	if json.Unmarshal(content, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(content, &parts) != nil {
+		return ""
+	}
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p.Type == "text" && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeOpenAI(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, errType, message string) {
+	writeOpenAI(w, status, map[string]any{"error": map[string]any{"message": message, "type": errType}})
+}
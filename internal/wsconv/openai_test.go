@@ -0,0 +1,188 @@
+package wsconv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+func TestOpenAIRejectsBeforeHandling(t *testing.T) {
+	srv := newTestServer(t)
+	open := NewServer(srv.watcher, nil, srv.registry, Options{})
+	chat := `{"model":"hq-mayor","messages":[{"role":"user","content":"hi"}]}`
+	tests := []struct {
+		name    string
+		server  *Server
+		request func() *http.Request
+		want    int
+	}{
+		{name: "auth not configured", server: open, request: func() *http.Request {
+			return apiRequest(http.MethodGet, "/v1/models", "", "")
+		}, want: http.StatusForbidden},
+		{name: "missing token", server: srv, request: func() *http.Request {
+			return apiRequest(http.MethodGet, "/v1/models", "", "")
+		}, want: http.StatusUnauthorized},
+		{name: "foreign origin", server: srv, request: func() *http.Request {
+			r := apiRequest(http.MethodPost, "/v1/chat/completions", "secret", chat)
+			r.Header.Set("Origin", "http://attacker.example")
+			return r
+		}, want: http.StatusForbidden},
+		{name: "form body", server: srv, request: func() *http.Request {
+			r := apiRequest(http.MethodPost, "/v1/chat/completions", "secret", chat)
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return r
+		}, want: http.StatusUnsupportedMediaType},
+		{name: "read-only token", server: srv, request: func() *http.Request {
+			return apiRequest(http.MethodPost, "/v1/chat/completions", "viewer", chat)
+		}, want: http.StatusForbidden},
+		{name: "unknown model", server: srv, request: func() *http.Request {
+			return apiRequest(http.MethodPost, "/v1/chat/completions", "secret", `{"model":"nobody","messages":[{"role":"user","content":"hi"}]}`)
+		}, want: http.StatusNotFound},
+		{name: "no user message", server: srv, request: func() *http.Request {
+			return apiRequest(http.MethodPost, "/v1/chat/completions", "secret", `{"model":"hq-mayor","messages":[{"role":"system","content":"hi"}]}`)
+		}, want: http.StatusBadRequest},
+		{name: "prompt policy", server: srv, request: func() *http.Request {
+			return apiRequest(http.MethodPost, "/v1/chat/completions", "secret", `{"model":"hq-mayor","messages":[{"role":"user","content":"`+strings.Repeat("x", 21)+`"}]}`)
+		}, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		NewOpenAIHandler(tt.server).ServeHTTP(w, tt.request())
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	NewOpenAIHandler(srv).ServeHTTP(w, apiRequest(http.MethodGet, "/v1/models", "viewer", ""))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"id":"hq-mayor"`) {
+		t.Errorf("models = %d %s, want hq-mayor listed", w.Code, w.Body.String())
+	}
+}
+
+func userEvent(promptID, text string) conv.ConversationEvent {
+	e := conv.ConversationEvent{Type: conv.EventUser, Content: []conv.ContentBlock{{Type: "text", Text: text}}}
+	if promptID != "" {
+		e.Metadata = map[string]any{"promptId": promptID}
+	}
+	return e
+}
+
+func assistantEvent(seq int64, text string) conv.ConversationEvent {
+	return conv.ConversationEvent{Type: conv.EventAssistant, Seq: seq, Content: []conv.ContentBlock{{Type: "text", Text: text}}}
+}
+
+func turnEvents(events ...conv.ConversationEvent) <-chan conv.ConversationEvent {
+	live := make(chan conv.ConversationEvent, len(events))
+	for _, e := range events {
+		live <- e
+	}
+	return live
+}
+
+func TestOpenAITurnCollect(t *testing.T) {
+	subagent := assistantEvent(8, "subagent chatter")
+	subagent.SubagentID = "agent-1"
+	live := turnEvents(
+		assistantEvent(1, "the end of an earlier turn"),
+		// Another client's prompt and its whole turn come first.
+		userEvent("ws-other", "what time is it?"),
+		assistantEvent(2, "noon"),
+		conv.ConversationEvent{Type: conv.EventTurnEnd},
+		userEvent("", "typed by hand"),
+		userEvent("openai-1", "run the tests"),
+		assistantEvent(3, "Running"),
+		assistantEvent(3, "Running them now."), // updated in place
+		assistantEvent(3, "Running them now."), // an update that adds nothing
+		subagent,
+		assistantEvent(4, "All green."),
+		conv.ConversationEvent{Type: conv.EventTurnEnd},
+		assistantEvent(5, "after the turn"),
+	)
+	turn := &openAITurn{promptID: "openai-1"}
+	var pieces []string
+	if err := turn.collect(context.Background(), live, func(s string) { pieces = append(pieces, s) }); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Running", " them now.", "\n\nAll green."}; !slices.Equal(pieces, want) {
+		t.Errorf("pieces = %q, want %q", pieces, want)
+	}
+	if got := turn.text.String(); got != "Running them now.\n\nAll green." {
+		t.Errorf("text = %q", got)
+	}
+}
+
+func TestOpenAITurnCollectGivesUp(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	// The reply started, but the turn never ends.
+	turn := &openAITurn{promptID: "openai-1"}
+	err := turn.collect(ctx, turnEvents(userEvent("openai-1", "hi"), assistantEvent(1, "partial")), nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("collect error = %v, want a timeout", err)
+	}
+	if turn.text.String() != "partial" {
+		t.Errorf("text = %q, want what arrived before the timeout", turn.text.String())
+	}
+
+	closed := make(chan conv.ConversationEvent)
+	close(closed)
+	if err := (&openAITurn{promptID: "openai-1"}).collect(context.Background(), closed, nil); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("collect error = %v, want the conversation closed", err)
+	}
+}
+
+func TestOpenAITurnStream(t *testing.T) {
+	turn := &openAITurn{id: "chatcmpl-1", model: "hq-mayor", created: 1700000000, promptID: "openai-1"}
+	live := turnEvents(userEvent("openai-1", "hi"), assistantEvent(1, "Hello"), assistantEvent(1, "Hello there"), conv.ConversationEvent{Type: conv.EventTurnEnd})
+	w := httptest.NewRecorder()
+	if err := turn.stream(context.Background(), w, live); err != nil {
+		t.Fatal(err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !w.Flushed {
+		t.Error("chunks were not flushed")
+	}
+
+	frames := strings.Split(w.Body.String(), "\n\n")
+	if len(frames) != 6 || frames[5] != "" || frames[4] != "data: [DONE]" {
+		t.Fatalf("frames = %q, want 5 data frames each ending in a blank line", frames)
+	}
+	type chunk struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Created int64  `json:"created"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Delta        map[string]string `json:"delta"`
+			FinishReason *string           `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	var deltas []map[string]string
+	for i, frame := range frames[:4] {
+		data, ok := strings.CutPrefix(frame, "data: ")
+		var c chunk
+		if !ok || json.Unmarshal([]byte(data), &c) != nil || len(c.Choices) != 1 {
+			t.Fatalf("frame %d = %q", i, frame)
+		}
+		if c.ID != "chatcmpl-1" || c.Object != "chat.completion.chunk" || c.Created != 1700000000 || c.Model != "hq-mayor" {
+			t.Errorf("frame %d envelope = %+v", i, c)
+		}
+		if finish := c.Choices[0].FinishReason; (i == 3) != (finish != nil && *finish == "stop") {
+			t.Errorf("frame %d finish_reason = %v", i, finish)
+		}
+		deltas = append(deltas, c.Choices[0].Delta)
+	}
+	if deltas[0]["role"] != "assistant" || deltas[1]["content"] != "Hello" || deltas[2]["content"] != " there" || len(deltas[3]) != 0 {
+		t.Errorf("deltas = %v", deltas)
+	}
+}
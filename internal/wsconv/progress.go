@@ -0,0 +1,52 @@
+package wsconv
+
+import (
+	"context"
+	"time"
+)
+
+// loadProgressInterval is how often snapshot-progress heartbeats are sent
+// while a conversation's history is still being read.
+const loadProgressInterval = 500 * time.Millisecond
+
+// snapshotProgress estimates how much of a conversation's existing history
+// the converter has read. Events arrive on the subscription as they are parsed.
+type snapshotProgress struct {
+	BytesRead  int64 `json:"bytesRead"`
+	TotalBytes int64 `json:"totalBytes"`
+	Done       bool  `json:"done"`
+}
+
+// reportLoadProgress sends snapshot-progress heartbeats for sub until the
+// conversation's history has been read, finishing with a done message, or
+// until ctx, the subscription's context, ends.
+// Conversations that are already loaded, and subscriptions that asked for no
+// history, produce no messages.
+func (c *Client) reportLoadProgress(ctx context.Context, sub *subscription, conversationID string) {
+	if sub.history == 0 {
+		return
+	}
+	if _, _, loading := c.server.watcher.LoadProgress(conversationID); !loading {
+		return
+	}
+
+	ticker := time.NewTicker(loadProgressInterval)
+	defer ticker.Stop()
+	for {
+		read, total, loading := c.server.watcher.LoadProgress(conversationID)
+		c.sendToSub(sub, serverMessage{
+			Type:           "snapshot-progress",
+			SubscriptionID: sub.id,
+			ConversationID: conversationID,
+			Progress:       &snapshotProgress{BytesRead: read, TotalBytes: total, Done: !loading},
+		})
+		if !loading {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package wsconv
+
+import (
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/agentio"
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+)
+
+// promptMetricsWait is how long after a prompt is submitted its
+// prompt-metrics waits for the prompt's user event and the first reply.
+const promptMetricsWait = 2 * time.Minute
+
+// promptMetrics times one send-prompt, in milliseconds. The transcript
+// latencies are measured from Enter being accepted, or for paste-only prompts,
+// which a human submits, from typing starting. They are omitted if the event
+// was not read within promptMetricsWait.
+type promptMetrics struct {
+	SendMs        float64  `json:"sendMs"`                 // typing through the wake resize, settle delays included
+	TypeMs        float64  `json:"typeMs"`                 // the send-keys that typed the prompt
+	EnterAttempts int      `json:"enterAttempts"`          // submit key presses it took
+	EchoMs        *float64 `json:"echoMs,omitempty"`       // until the prompt's user event was read
+	FirstReplyMs  *float64 `json:"firstReplyMs,omitempty"` // until the first reply after it was read
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// reportPromptMetrics sends a prompt-metrics message for the send-prompt
+// request msg once the agent has replied to the prompt, or promptMetricsWait
+// has passed.
+func (c *Client) reportPromptMetrics(msg clientMessage, d agentio.PromptDelivery, sent *conv.SentPrompt) {
+	m := &promptMetrics{
+		SendMs:        millis(d.Total),
+		TypeMs:        millis(d.Typing),
+		EnterAttempts: d.EnterAttempts,
+	}
+	from := d.Submitted
+	if d.PasteOnly {
+		from = d.Started
+	}
+	if sent != nil {
+		timeout := time.NewTimer(promptMetricsWait)
+		defer timeout.Stop()
+	wait:
+		for _, ch := range []<-chan struct{}{sent.Echoed(), sent.Answered()} {
+			select {
+			case <-ch:
+			case <-timeout.C:
+				break wait
+			case <-c.ctx.Done():
+				return
+			}
+		}
+		if isClosed(sent.Echoed()) {
+			echo := millis(sent.EchoedAt().Sub(from))
+			m.EchoMs = &echo
+		}
+		if isClosed(sent.Answered()) {
+			reply := millis(sent.AnsweredAt().Sub(from))
+			m.FirstReplyMs = &reply
+		}
+	}
+	c.sendJSON(serverMessage{Type: "prompt-metrics", RequestID: msg.ID, Name: msg.Agent, PromptID: msg.PromptID, PromptMetrics: m})
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,129 +1,328 @@
 package wsconv
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"nhooyr.io/websocket"
 
 	"github.com/gastownhall/tmux-adapter/internal/agentio"
 	"github.com/gastownhall/tmux-adapter/internal/agents"
 	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
 	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/version"
 	"github.com/gastownhall/tmux-adapter/internal/wsbase"
 )
 
 // maxSnapshotEvents caps the number of events in a single snapshot message.
 const maxSnapshotEvents = 20000
 
+// ProtocolV1 names version 1 of the converter protocol, both in hello and as
+// the WebSocket subprotocol negotiated at /ws/v1 (and /ws, its alias).
+const ProtocolV1 = "tmux-converter.v1"
+
 // Server manages WebSocket connections for the converter service.
 type Server struct {
 	watcher        *conv.ConversationWatcher
 	ctrl           *tmux.ControlMode
 	registry       *agents.Registry
 	prompter       *agentio.Prompter
-	authToken      string
-	originPatterns []string
+	actions        *agentio.QuickActions
+	auth           *wsbase.Authenticator
+	allowedOrigins *wsbase.OriginPolicy
+	apiOrigins     *wsbase.OriginPolicy
+	debugProtocol  bool
+	presence       *wsbase.Presence
+	control        *agentio.ControlLocks
+	limits         wsbase.Limits
 	clients        map[*Client]struct{}
+	nextClientID   int
 	mu             sync.Mutex
 }
 
+// Options configures a Server. Nil policies allow everything.
+type Options struct {
+	Auth           *wsbase.Authenticator // checks every connection; nil leaves them open
+	AllowedOrigins *wsbase.OriginPolicy
+	// APIOrigins are the browser origins that may call /mcp and /v1/; nil
+	// admits only pages served by the converter itself.
+	APIOrigins   *wsbase.OriginPolicy
+	PromptPolicy *agentio.PromptPolicy // screens send-prompt requests
+	UploadPolicy *agentio.UploadPolicy // screens file uploads
+	// Submit says how typed prompts are submitted to each runtime, and Holds
+	// which agents' prompts wait for confirm-prompt.
+	Submit  agentio.SubmitStrategies
+	Holds   *agentio.PromptHolds
+	History *agentio.PromptHistory // records the prompts sent to each agent
+	Actions *agentio.QuickActions  // offered by list-actions
+	Limits  wsbase.Limits          // caps what each connection may hold
+	// ControlIdleTimeout releases input control held this long without
+	// input from the holder; zero keeps it until released.
+	ControlIdleTimeout time.Duration
+	// DebugProtocol logs every connection's traffic as if it had sent hello
+	// with debug: true.
+	DebugProtocol bool
+}
+
 // NewServer creates a new converter WebSocket server.
-func NewServer(watcher *conv.ConversationWatcher, authToken string, originPatterns []string, ctrl *tmux.ControlMode, registry *agents.Registry) *Server {
-	return &Server{
+func NewServer(watcher *conv.ConversationWatcher, ctrl *tmux.ControlMode, registry *agents.Registry, opts Options) *Server {
+	apiOrigins := opts.APIOrigins
+	if apiOrigins == nil {
+		apiOrigins = &wsbase.OriginPolicy{}
+	}
+	s := &Server{
 		watcher:        watcher,
 		ctrl:           ctrl,
 		registry:       registry,
-		prompter:       agentio.NewPrompter(ctrl, registry),
-		authToken:      authToken,
-		originPatterns: originPatterns,
+		prompter:       agentio.NewPrompter(ctrl, registry, opts.PromptPolicy, opts.UploadPolicy, opts.Submit, opts.Holds, opts.History),
+		actions:        opts.Actions,
+		auth:           opts.Auth,
+		allowedOrigins: opts.AllowedOrigins,
+		apiOrigins:     apiOrigins,
+		debugProtocol:  opts.DebugProtocol,
+		presence:       wsbase.NewPresence(),
+		control:        agentio.NewControlLocks(),
+		limits:         opts.Limits,
 		clients:        make(map[*Client]struct{}),
 	}
+	s.control.SetIdleTimeout(opts.ControlIdleTimeout, func(agent string) {
+		log.Printf("control of %s released after %s idle", agent, opts.ControlIdleTimeout)
+		s.broadcastControl(agent, "")
+	})
+	return s
 }
 
-// HandleWebSocket is the HTTP handler for /ws.
+// HandleWebSocket is the HTTP handler for /ws/v1 and /ws. Clients offering
+// the ProtocolV1 subprotocol get it back; the hello handshake is required
+// either way.
 func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	if !wsbase.IsAuthorizedRequest(s.authToken, r) {
+	grant, err := s.auth.Authenticate(r)
+	if err != nil {
+		log.Printf("websocket unauthorized from %s: %v", r.RemoteAddr, err)
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	conn, err := wsbase.AcceptWebSocket(w, r, s.originPatterns)
+	conn, err := wsbase.AcceptWebSocket(w, r, s.allowedOrigins, ProtocolV1)
 	if err != nil {
 		return
 	}
 	conn.SetReadLimit(int64(agentio.MaxFileUploadBytes + 64*1024))
 
-	client := newClient(conn, s)
+	client := newClient(conn, s, wsbase.NewConnInfo(r, conn, grant))
+	client.grant = wsbase.NewGrantHolder(grant)
 	s.addClient(client)
+	client.logf("connected %s", client.info)
 	defer s.removeClient(client)
 
 	client.run()
 }
 
-// Broadcast sends a watcher event to all connected clients.
+// Broadcast sends a watcher event to all connected clients. Messages that
+// are the same for every client are encoded once; delivery runs outside the
+// server lock, spread across a bounded set of workers, so one slow client
+// lock can't hold up the rest or block clients connecting.
 func (s *Server) Broadcast(event conv.WatcherEvent) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	clients := s.clientList()
 
 	switch event.Type {
-	case "agent-added":
-		msg := serverMessage{
-			Type:  "agent-added",
-			Agent: event.Agent,
+	case "agent-added", "agent-updated":
+		msg := serverMessage{Type: event.Type}
+		if event.Agent != nil {
+			msg.Agent = agentUpdate{Agent: event.Agent, Activity: s.watcher.Activity(event.Agent.Name)}
 		}
-		for c := range s.clients {
-			if c.subscribedAgents {
-				c.sendJSON(msg)
-			}
+		s.broadcastToAgentSubscribers(clients, eventAgentName(event), msg)
+		if event.Agent != nil && event.Agent.Project != "" {
+			fanout(clients, func(c *Client) { c.joinProject(event.Agent) })
 		}
 	case "agent-removed":
-		msg := serverMessage{
-			Type: "agent-removed",
-		}
+		msg := serverMessage{Type: "agent-removed", Name: eventAgentName(event), Reason: event.Reason}
+		s.broadcastToAgentSubscribers(clients, msg.Name, msg)
+	case "agent-renamed":
 		if event.Agent != nil {
-			msg.Name = event.Agent.Name
-		}
-		for c := range s.clients {
-			if c.subscribedAgents {
-				c.sendJSON(msg)
-			}
+			s.renameAgent(clients, event)
 		}
-	case "agent-updated":
+	case "agent-model-changed":
 		msg := serverMessage{
-			Type:  "agent-updated",
-			Agent: event.Agent,
-		}
-		for c := range s.clients {
-			if c.subscribedAgents {
-				c.sendJSON(msg)
-			}
+			Type: "agent-model-changed",
+			Name: eventAgentName(event),
+			From: event.OldModel,
+			To:   event.NewModel,
 		}
+		s.broadcastToAgentSubscribers(clients, msg.Name, msg)
 	case "conversation-started":
-		for c := range s.clients {
-			c.deliverConversationStarted(event)
-		}
-	case "conversation-event":
+		fanout(clients, func(c *Client) { c.deliverConversationStarted(event) })
+	case "conversation-event", "conversation-event-updated":
 		if event.Event == nil {
 			return
 		}
-		for c := range s.clients {
-			c.deliverConversationEvent(event.Event)
+		encoded, err := json.Marshal(event.Event)
+		if err != nil {
+			return
 		}
+		fanout(clients, func(c *Client) { c.deliverConversationEvent(event.Event, encoded) })
 	case "conversation-switched":
-		for c := range s.clients {
-			c.deliverConversationSwitch(event)
+		fanout(clients, func(c *Client) { c.deliverConversationSwitch(event) })
+	}
+}
+
+// renameAgent moves follows, viewers, and input control of an agent whose
+// session was renamed to its new name, then tells lifecycle subscribers of
+// either name with agent-renamed.
+func (s *Server) renameAgent(clients []*Client, event conv.WatcherEvent) {
+	oldName, newName := event.OldName, event.Agent.Name
+	s.presence.Rename(oldName, newName)
+	s.control.Rename(oldName, newName)
+	for _, c := range clients {
+		c.renameAgent(oldName, newName)
+	}
+
+	msg := serverMessage{Type: "agent-renamed", OldName: oldName, Agent: agentUpdate{Agent: event.Agent, Activity: s.watcher.Activity(newName)}}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fanout(clients, func(c *Client) {
+		filter := c.agentFilter.Load()
+		if c.subscribedAgents.Load() && (filter.Matches(oldName) || filter.Matches(newName)) {
+			c.sendEncoded(msg.Type, data)
+		}
+	})
+}
+
+// renameAgent re-keys the client's follow of oldName, and the agent its
+// subscriptions count as viewing, to newName.
+func (c *Client) renameAgent(oldName, newName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sub, ok := c.follows[oldName]; ok {
+		delete(c.follows, oldName)
+		sub.agentName = newName
+		c.follows[newName] = sub
+	}
+	for _, sub := range c.subs {
+		if sub.viewing == oldName {
+			sub.viewing = newName
 		}
 	}
 }
 
+// fanoutWorkers bounds the goroutines delivering one broadcast, and
+// fanoutBatch is the fewest clients worth handing to another one.
+const (
+	fanoutWorkers = 8
+	fanoutBatch   = 32
+)
+
+// fanout calls deliver for every client, splitting the clients across up
+// to fanoutWorkers goroutines. It returns once every client is served, so
+// successive broadcasts reach each client's queue in order.
+func fanout(clients []*Client, deliver func(*Client)) {
+	workers := min(fanoutWorkers, (len(clients)+fanoutBatch-1)/fanoutBatch)
+	if workers <= 1 {
+		for _, c := range clients {
+			deliver(c)
+		}
+		return
+	}
+	per := (len(clients) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(clients); start += per {
+		chunk := clients[start:min(start+per, len(clients))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer crash.Recover("broadcast fanout")
+			for _, c := range chunk {
+				deliver(c)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// eventAgentName returns the name of the agent a watcher event is about, or
+// "" if it carries none.
+func eventAgentName(event conv.WatcherEvent) string {
+	if event.Agent == nil {
+		return ""
+	}
+	return event.Agent.Name
+}
+
+// clientList returns the connected clients.
+func (s *Server) clientList() []*Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clients := make([]*Client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// broadcastToAgentSubscribers encodes msg once and queues it for every
+// client subscribed to lifecycle events of the named agent.
+func (s *Server) broadcastToAgentSubscribers(clients []*Client, agentName string, msg serverMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fanout(clients, func(c *Client) {
+		if c.subscribedAgents.Load() && c.agentFilter.Load().Matches(agentName) {
+			c.sendEncoded(msg.Type, data)
+		}
+	})
+}
+
+// broadcastViewers tells agent-lifecycle subscribers that an agent gained
+// ("viewer-joined") or lost ("viewer-left") a client following or subscribed to it.
+func (s *Server) broadcastViewers(eventType, agentName string, count int) {
+	s.broadcastToAgentSubscribers(s.clientList(), agentName, serverMessage{Type: eventType, Name: agentName, ViewerCount: &count})
+}
+
+// broadcastControl tells agent-lifecycle subscribers who now controls an
+// agent's input; an empty controlledBy means the agent is free.
+func (s *Server) broadcastControl(agentName, controlledBy string) {
+	s.broadcastToAgentSubscribers(s.clientList(), agentName, serverMessage{Type: "control-changed", Name: agentName, ControlledBy: controlledBy})
+}
+
+// PromptAPI returns the HTTP prompt endpoint, sharing this server's
+// prompter, control locks, and authentication.
+func (s *Server) PromptAPI() *agentio.PromptAPI {
+	return agentio.NewPromptAPI(s.prompter, s.control, s.auth)
+}
+
+// ScreenshotAPI returns the HTTP endpoint rendering an agent's pane to a
+// PNG, sharing this server's authentication.
+func (s *Server) ScreenshotAPI() *agentio.ScreenshotAPI {
+	return agentio.NewScreenshotAPI(s.ctrl, s.registry, s.auth)
+}
+
+// ClientCount returns the number of connected clients.
+func (s *Server) ClientCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
 func (s *Server) addClient(c *Client) {
 	s.mu.Lock()
+	s.nextClientID++
+	c.id = "client-" + itoa(s.nextClientID)
 	s.clients[c] = struct{}{}
 	s.mu.Unlock()
 }
@@ -133,6 +332,7 @@ func (s *Server) removeClient(c *Client) {
 	delete(s.clients, c)
 	s.mu.Unlock()
 	c.cleanup()
+	c.logf("disconnected after %s", time.Since(c.connectedAt).Round(time.Second))
 }
 
 // outMsg wraps a WebSocket message with its type (text or binary).
@@ -143,49 +343,119 @@ type outMsg struct {
 
 // Client represents a connected WebSocket client.
 type Client struct {
-	conn     *websocket.Conn
-	server   *Server
-	send     chan outMsg
-	ctx      context.Context
-	cancel   context.CancelFunc
-	mu       sync.Mutex
-	subs     map[string]*subscription // subscriptionId → subscription
-	follows  map[string]*subscription // agentName → subscription (follow-agent)
-	nextSub  int
-	subscribedAgents bool
+	id               string
+	connectedAt      time.Time
+	conn             *websocket.Conn
+	server           *Server
+	send             chan outMsg
+	ctx              context.Context
+	cancel           context.CancelFunc
+	mu               sync.Mutex
+	subs             map[string]*subscription // subscriptionId → subscription
+	follows          map[string]*subscription // agentName → subscription (follow-agent)
+	projects         map[string]clientMessage // project → follow-project request, for agents joining it
+	nextSub          int
+	subscribedAgents atomic.Bool
+	agentFilter      atomic.Pointer[wsbase.NameFilter] // agents subscribe-agents reports on
 	handshakeDone    bool
+	grant            *wsbase.GrantHolder // what the connection's credentials allow
+	goroutines       atomic.Int64        // started by go, still running
+
+	info     wsbase.ConnInfo
+	protocol string // from hello, once handshakeDone
+
+	// Protocol debugging (--debug-protocol or hello debug: true)
+	debug    atomic.Bool
+	debugMu  sync.Mutex
+	received map[string]time.Time // request ID → arrival time
 }
 
 type subscription struct {
 	id             string
 	conversationID string
 	agentName      string // non-empty for follow-agent
+	project        string // non-empty for a follow made by follow-project
+	viewing        string // agent this subscription counts as a viewer of
 	bufSubID       int    // buffer subscription ID for Unsubscribe
 	filter         conv.EventFilter
+	history        int // events of existing history to send: -1 for all, 0 for none
 	live           <-chan conv.ConversationEvent
 	cancel         context.CancelFunc
+
+	// msgSeq numbers every message sent for this subscription so clients
+	// can spot drops; msgMu keeps numbering and queueing in the same order.
+	msgMu  sync.Mutex
+	msgSeq int64
+
+	// lastSeq is the buffer seq the client is caught up to, so live delivery
+	// can backfill events a dropping channel lost. gapMu serializes delivery
+	// and snapshots against it.
+	gapMu   sync.Mutex
+	lastSeq int64
 }
 
-func newClient(conn *websocket.Conn, server *Server) *Client {
+func newClient(conn *websocket.Conn, server *Server, info wsbase.ConnInfo) *Client {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Client{
-		conn:    conn,
-		server:  server,
-		send:    make(chan outMsg, 256),
-		ctx:     ctx,
-		cancel:  cancel,
-		subs:    make(map[string]*subscription),
-		follows: make(map[string]*subscription),
+	c := &Client{
+		conn:        conn,
+		connectedAt: time.Now(),
+		server:      server,
+		send:        make(chan outMsg, 256),
+		ctx:         ctx,
+		cancel:      cancel,
+		subs:        make(map[string]*subscription),
+		follows:     make(map[string]*subscription),
+		projects:    make(map[string]clientMessage),
+		info:        info,
+		received:    make(map[string]time.Time),
 	}
+	c.debug.Store(server.debugProtocol)
+	return c
 }
 
 func (c *Client) run() {
-	go c.writePump()
+	c.goTracked(c.writePump)
+	c.goTracked(c.watchGrantExpiry)
 	c.readPump()
 }
 
+// logf logs a line about this connection, prefixed with its ID.
+func (c *Client) logf(format string, args ...any) {
+	log.Printf("%s: %s", c.id, fmt.Sprintf(format, args...))
+}
+
+// goTracked runs fn on a new goroutine counted in c.goroutines. Everything
+// a connection starts goes through it, so the admin API can show work that
+// outlives the client.
+func (c *Client) goTracked(fn func()) {
+	c.goroutines.Add(1)
+	go func() {
+		defer c.goroutines.Add(-1)
+		defer crash.Recover("%s: background work", c.id)
+		fn()
+	}()
+}
+
+// goAgentWork runs fn on a tracked goroutine holding agentName's prompter
+// lock. Work still queued for the lock when the client disconnects is
+// dropped; work already running finishes, so a pane is never left with a
+// half-typed prompt.
+func (c *Client) goAgentWork(agentName, what string, fn func()) {
+	lock := c.server.prompter.GetLock(agentName)
+	c.goTracked(func() {
+		lock.Lock()
+		defer lock.Unlock()
+		if c.ctx.Err() != nil {
+			c.logf("dropped %s for %s: client disconnected", what, agentName)
+			return
+		}
+		fn()
+	})
+}
+
 func (c *Client) readPump() {
 	defer c.cancel()
+	defer crash.Recover("%s: read pump", c.id)
 	for {
 		typ, data, err := c.conn.Read(c.ctx)
 		if err != nil {
@@ -201,6 +471,7 @@ func (c *Client) readPump() {
 
 func (c *Client) writePump() {
 	defer func() { _ = c.conn.Close(websocket.StatusNormalClosure, "") }()
+	defer crash.Recover("%s: write pump", c.id)
 	for {
 		select {
 		case <-c.ctx.Done():
@@ -220,17 +491,53 @@ func (c *Client) writePump() {
 }
 
 func (c *Client) sendJSON(v any) {
+	msgType := ""
+	if msg, ok := v.(serverMessage); ok {
+		c.debugStamp(&msg)
+		msgType = msg.Type
+		v = msg
+	}
 	data, err := json.Marshal(v)
 	if err != nil {
 		return
 	}
+	c.sendEncoded(msgType, data)
+}
+
+// sendEncoded queues an already-encoded message of type msgType.
+func (c *Client) sendEncoded(msgType string, data []byte) {
 	select {
 	case c.send <- outMsg{typ: websocket.MessageText, data: data}:
+		c.debugOutbound(msgType, len(data), false)
 	default:
 		// Slow consumer — drop
+		c.debugOutbound(msgType, len(data), true)
 	}
 }
 
+// sendBinary queues a binary frame, dropping it for a slow consumer like
+// sendEncoded does.
+func (c *Client) sendBinary(msgType string, frame []byte) {
+	select {
+	case c.send <- outMsg{typ: websocket.MessageBinary, data: frame}:
+		c.debugOutbound(msgType, len(frame), false)
+	default:
+		c.debugOutbound(msgType, len(frame), true)
+	}
+}
+
+// sendToSub stamps msg with the subscription's next msgSeq and queues it.
+// A message dropped for a slow consumer still consumes its number, so the
+// client sees a gap and can send resync.
+func (c *Client) sendToSub(sub *subscription, msg serverMessage) {
+	sub.msgMu.Lock()
+	defer sub.msgMu.Unlock()
+	sub.msgSeq++
+	msg.MsgSeq = sub.msgSeq
+	msg.Project = sub.project
+	c.sendJSON(msg)
+}
+
 func (c *Client) handleBinaryMessage(data []byte) {
 	msgType, agentName, payload, err := agentio.ParseBinaryEnvelope(data)
 	if err != nil {
@@ -239,28 +546,86 @@ func (c *Client) handleBinaryMessage(data []byte) {
 	}
 
 	switch msgType {
-	case agentio.BinaryFileUpload:
+	case agentio.BinaryFileUpload, agentio.BinaryFileAttach, agentio.BinaryArchiveUpload:
+		if err := c.grant.CheckInput(c.server.control, agentName, c); err != nil {
+			c.sendJSON(serverMessage{Type: "error", Error: err.Error()})
+			return
+		}
+		handle := c.server.prompter.HandleFileUpload
+		if msgType == agentio.BinaryArchiveUpload {
+			handle = c.server.prompter.HandleArchiveUpload
+		}
+		paste := msgType != agentio.BinaryFileAttach
 		payloadCopy := append([]byte(nil), payload...)
-		go func() {
-			lock := c.server.prompter.GetLock(agentName)
-			lock.Lock()
-			defer lock.Unlock()
-			if err := c.server.prompter.HandleFileUpload(agentName, payloadCopy); err != nil {
-				log.Printf("file upload %s error: %v", agentName, err)
-				c.sendJSON(serverMessage{Type: "error", Error: "file upload " + agentName + ": " + err.Error()})
+		c.goAgentWork(agentName, "file upload", func() {
+			fileID, err := handle(agentName, payloadCopy, paste)
+			if err != nil {
+				c.logf("file upload %s error: %v", agentName, err)
+				var rejection *agentio.Rejection
+				errors.As(err, &rejection)
+				c.sendJSON(serverMessage{Type: "error", Name: agentName, Error: "file upload " + agentName + ": " + err.Error(), Code: tmux.ErrorCode(err), Rejection: rejection})
+				return
 			}
-		}()
+			c.sendJSON(serverMessage{Type: "file-uploaded", Name: agentName, FileID: fileID})
+		})
+	case agentio.BinaryUploadBegin, agentio.BinaryUploadChunk, agentio.BinaryUploadCommit:
+		if err := c.grant.CheckInput(c.server.control, agentName, c); err != nil {
+			c.sendJSON(serverMessage{Type: "error", Error: err.Error()})
+			return
+		}
+		switch msgType {
+		case agentio.BinaryUploadBegin:
+			progress, err := c.server.prompter.BeginChunkedUpload(agentName, c, payload)
+			c.sendUploadResult("upload-progress", agentName, progress, err)
+		case agentio.BinaryUploadChunk:
+			progress, err := c.server.prompter.WriteUploadChunk(agentName, c, payload)
+			c.sendUploadResult("upload-progress", agentName, progress, err)
+		default:
+			payloadCopy := append([]byte(nil), payload...)
+			c.goAgentWork(agentName, "upload commit", func() {
+				progress, err := c.server.prompter.CommitChunkedUpload(agentName, c, payloadCopy)
+				c.sendUploadResult("upload-complete", agentName, progress, err)
+			})
+		}
 	default:
 		c.sendJSON(serverMessage{Type: "error", Error: fmt.Sprintf("unsupported binary message type: 0x%02x", msgType)})
 	}
 }
 
+// sendUploadResult acks a chunked upload step, or reports the error that
+// stopped it along with any policy rejection.
+func (c *Client) sendUploadResult(msgType, agentName string, progress agentio.UploadProgress, err error) {
+	var upload *agentio.UploadProgress
+	if progress.UploadID != "" {
+		upload = &progress
+	}
+	if err != nil {
+		c.logf("chunked upload %s error: %v", agentName, err)
+		var rejection *agentio.Rejection
+		errors.As(err, &rejection)
+		c.sendJSON(serverMessage{Type: "error", Name: agentName, Error: "file upload " + agentName + ": " + err.Error(), Code: tmux.ErrorCode(err), Upload: upload, Rejection: rejection})
+		return
+	}
+	c.sendJSON(serverMessage{Type: msgType, Name: agentName, Upload: upload})
+}
+
 func (c *Client) handleTextMessage(data []byte) {
+	if lerr := c.server.limits.CheckMessage(len(data)); lerr != nil {
+		c.sendLimit("", lerr)
+		return
+	}
 	var msg clientMessage
 	if err := json.Unmarshal(data, &msg); err != nil {
 		c.sendJSON(serverMessage{Type: "error", Error: "invalid JSON"})
 		return
 	}
+	// Older conversation IDs are answered under their current form, which
+	// is what events and cursors carry.
+	msg.ConversationID = conv.CanonicalConversationID(msg.ConversationID)
+	if msg.Type == "hello" && msg.Debug {
+		c.debug.Store(true)
+	}
+	c.debugInbound(msg, len(data))
 
 	if !c.handshakeDone {
 		if msg.Type != "hello" {
@@ -288,43 +653,150 @@ func (c *Client) handleTextMessage(data []byte) {
 		c.handleUnsubscribe(msg)
 	case "unsubscribe-agent":
 		c.handleUnsubscribeAgent(msg)
+	case "follow-project":
+		c.handleFollowProject(msg)
+	case "unsubscribe-project":
+		c.handleUnsubscribeProject(msg)
 	case "send-prompt":
 		c.handleSendPrompt(msg)
+	case "run-command":
+		c.handleRunCommand(msg)
+	case "set-prompt-hold":
+		c.handleSetPromptHold(msg)
+	case "confirm-prompt":
+		c.handleConfirmPrompt(msg)
+	case "get-parse-errors":
+		c.handleGetParseErrors(msg)
+	case "annotate-conversation":
+		c.handleAnnotateConversation(msg)
+	case "resume-hint":
+		c.handleResumeHint(msg)
+	case "get-blob":
+		c.handleGetBlob(msg)
+	case "get-full-content":
+		c.handleGetFullContent(msg)
+	case "get-buffer-stats":
+		c.handleGetBufferStats(msg)
+	case "get-run-graph":
+		c.handleGetRunGraph(msg)
+	case "get-pane-text":
+		c.handleGetPaneText(msg)
+	case "get-prompt-history":
+		c.handleGetPromptHistory(msg)
+	case "list-actions":
+		c.handleListActions(msg)
+	case "resync":
+		c.handleResync(msg)
+	case "acquire-control":
+		c.handleAcquireControl(msg)
+	case "release-control":
+		c.handleReleaseControl(msg)
 	default:
 		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "unknown message type", UnknownType: msg.Type})
 	}
 }
 
 func (c *Client) handleHello(msg clientMessage) {
-	if msg.Protocol != "tmux-converter.v1" {
+	if msg.Protocol != ProtocolV1 {
 		c.sendJSON(serverMessage{ID: msg.ID, Type: "hello", OK: boolPtr(false), Error: "unsupported protocol version"})
 		return
 	}
+	c.mu.Lock()
 	c.handshakeDone = true
-	c.sendJSON(serverMessage{ID: msg.ID, Type: "hello", OK: boolPtr(true), Protocol: "tmux-converter.v1", ServerVersion: "0.1.0"})
+	c.protocol = msg.Protocol
+	c.mu.Unlock()
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "hello", OK: boolPtr(true), Protocol: ProtocolV1, ServerVersion: version.Version, Debug: c.debug.Load()})
 }
 
 func (c *Client) handleListAgents(msg clientMessage) {
-	regAgents := c.buildAgentList()
-	c.sendJSON(serverMessage{ID: msg.ID, Type: "list-agents", Agents: regAgents})
+	if lerr := c.server.limits.CheckNameFilter(msg.AgentFilter); lerr != nil {
+		c.sendLimit(msg.ID, lerr)
+		return
+	}
+	filter, err := wsbase.ParseNameFilter(msg.AgentFilter, msg.FilterSyntax)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: err.Error()})
+		return
+	}
+	page, next, total, err := c.server.agentPage(msg, filter)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: err.Error()})
+		return
+	}
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "list-agents", Agents: page, NextCursor: next, Total: &total})
 }
 
 func (c *Client) handleSubscribeAgents(msg clientMessage) {
-	c.subscribedAgents = true
-	regAgents := c.buildAgentList()
-	c.sendJSON(serverMessage{ID: msg.ID, Type: "subscribe-agents", OK: boolPtr(true), Agents: regAgents})
+	if lerr := c.server.limits.CheckNameFilter(msg.AgentFilter); lerr != nil {
+		c.sendLimit(msg.ID, lerr)
+		return
+	}
+	filter, err := wsbase.ParseNameFilter(msg.AgentFilter, msg.FilterSyntax)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: err.Error()})
+		return
+	}
+	page, next, total, err := c.server.agentPage(msg, filter)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: err.Error()})
+		return
+	}
+	c.agentFilter.Store(filter)
+	c.subscribedAgents.Store(true)
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "subscribe-agents", OK: boolPtr(true), Agents: page, NextCursor: next, Total: &total})
+}
+
+// agentSorts orders agent lists by list-agents' sortBy values. Ties, and
+// agents with no activity under lastActivity, fall back to name order.
+var agentSorts = map[string]func(a, b agentInfo) int{
+	"name": func(a, b agentInfo) int { return strings.Compare(a.Name, b.Name) },
+	"runtime": func(a, b agentInfo) int {
+		return cmp.Or(strings.Compare(a.Runtime, b.Runtime), strings.Compare(a.Name, b.Name))
+	},
+	"lastActivity": func(a, b agentInfo) int {
+		var at, bt time.Time
+		if a.LastEventAt != nil {
+			at = *a.LastEventAt
+		}
+		if b.LastEventAt != nil {
+			bt = *b.LastEventAt
+		}
+		return cmp.Or(bt.Compare(at), strings.Compare(a.Name, b.Name)) // most recent first
+	},
+}
+
+// agentPage returns the agents a list-agents or subscribe-agents request
+// asks for, the cursor of the page after it, and how many agents pass filter.
+func (s *Server) agentPage(msg clientMessage, filter *wsbase.NameFilter) ([]agentInfo, string, int, error) {
+	sortBy := cmp.Or(msg.SortBy, "name")
+	compare, ok := agentSorts[sortBy]
+	if !ok {
+		return nil, "", 0, fmt.Errorf("unknown sortBy %q: want name, lastActivity, or runtime", msg.SortBy)
+	}
+	if msg.Limit < 0 || msg.Offset < 0 {
+		return nil, "", 0, errors.New("limit and offset must not be negative")
+	}
+	list := slices.DeleteFunc(s.agentList(), func(a agentInfo) bool { return !filter.Matches(a.Name) })
+	slices.SortFunc(list, compare)
+	page, next, err := wsbase.Paginate(list, func(a agentInfo) string { return a.Name }, wsbase.Page{Limit: msg.Limit, Offset: msg.Offset, Cursor: msg.Cursor})
+	return page, next, len(list), err
 }
 
-func (c *Client) buildAgentList() []agentInfo {
-	agents := c.server.watcher.ListAgents()
+func (s *Server) agentList() []agentInfo {
+	agents := s.watcher.ListAgents()
 	result := make([]agentInfo, 0, len(agents))
 	for _, a := range agents {
 		info := agentInfo{
-			Name:    a.Name,
-			Runtime: a.Runtime,
+			Name:         a.Name,
+			Runtime:      a.Runtime,
+			Project:      a.Project,
+			ViewerCount:  s.presence.Count(a.Name),
+			ControlledBy: s.control.Holder(a.Name),
+			CurrentModel: s.watcher.CurrentModel(a.Name),
+			Activity:     s.watcher.Activity(a.Name),
 		}
 		// Attach active conversation ID if one exists
-		if convID := c.server.watcher.GetActiveConversation(a.Name); convID != "" {
+		if convID := s.watcher.GetActiveConversation(a.Name); convID != "" {
 			info.ConversationID = convID
 		}
 		result = append(result, info)
@@ -343,32 +815,51 @@ func (c *Client) handleSubscribeConversation(msg clientMessage) {
 		return
 	}
 
-	buf := c.server.watcher.GetBuffer(msg.ConversationID)
+	history, err := parseHistory(msg.History)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: err.Error()})
+		return
+	}
+
+	// Checked before tailing, so a refused request starts no tailer.
+	if lerr := c.checkSubscribe(msg, false); lerr != nil {
+		c.sendLimit(msg.ID, lerr)
+		return
+	}
+
+	buf := c.server.watcher.EnsureTailing(msg.ConversationID, history)
 	if buf == nil {
 		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "conversation not found"})
 		return
 	}
 
 	filter := buildFilter(msg.Filter)
-	snapshot, bufSubID, live := buf.Subscribe(filter)
+	lastSeq, snapshot, bufSubID, live := subscribeBuffer(buf, filter)
 
+	subCtx, subCancel := context.WithCancel(c.ctx)
 	c.mu.Lock()
 	c.nextSub++
 	sID := subID(c.nextSub)
 	sub := &subscription{
 		id:             sID,
 		conversationID: msg.ConversationID,
+		viewing:        buf.AgentName(),
 		bufSubID:       bufSubID,
 		filter:         filter,
+		history:        history,
 		live:           live,
+		cancel:         subCancel,
+		lastSeq:        lastSeq,
 	}
 	c.subs[sID] = sub
 	c.mu.Unlock()
+	c.logf("subscribe-conversation %s as %s", msg.ConversationID, sID)
+	c.joinAgent(sub.viewing)
 
-	snapshot = capSnapshot(snapshot)
+	snapshot = sub.historySnapshot(snapshot)
 	cursor := makeCursor(msg.ConversationID, snapshot)
 
-	c.sendJSON(serverMessage{
+	c.sendToSub(sub, serverMessage{
 		ID:             msg.ID,
 		Type:           "conversation-snapshot",
 		SubscriptionID: sID,
@@ -377,18 +868,40 @@ func (c *Client) handleSubscribeConversation(msg clientMessage) {
 		Cursor:         cursor,
 	})
 
-	go c.streamLive(sub, buf)
+	c.goTracked(func() { c.streamLiveWithContext(sub, buf, subCtx) })
+	c.goTracked(func() { c.reportLoadProgress(subCtx, sub, msg.ConversationID) })
 }
 
 func (c *Client) handleFollowAgent(msg clientMessage) {
+	c.followAgent(msg, "")
+}
+
+// followAgent follows msg.Agent for a follow-agent request, or for the
+// follow-project of project when it is non-empty.
+func (c *Client) followAgent(msg clientMessage, project string) {
 	if msg.Agent == "" {
 		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "agent required"})
 		return
 	}
+	history, err := parseHistory(msg.History)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: err.Error()})
+		return
+	}
+	convID := c.server.watcher.GetActiveConversation(msg.Agent)
+	pending := convID == "" || !c.server.watcher.Tracks(convID)
+	if lerr := c.checkSubscribe(msg, pending); lerr != nil {
+		c.sendLimit(msg.ID, lerr)
+		return
+	}
+	if !pending {
+		c.server.watcher.EnsureTailing(convID, history)
+	}
 
-	// Remove existing follow for this agent
+	// Remove existing follow for this agent. The replacement keeps its viewer slot.
 	c.mu.Lock()
-	if existing, ok := c.follows[msg.Agent]; ok {
+	existing, replacing := c.follows[msg.Agent]
+	if replacing {
 		delete(c.subs, existing.id)
 		if existing.cancel != nil {
 			existing.cancel()
@@ -404,21 +917,27 @@ func (c *Client) handleFollowAgent(msg clientMessage) {
 	filter := buildFilter(msg.Filter)
 	c.nextSub++
 	sID := subID(c.nextSub)
-
-	convID := c.server.watcher.GetActiveConversation(msg.Agent)
+	c.logf("follow-agent %s as %s", msg.Agent, sID)
 
 	if convID == "" {
 		// No active conversation yet — register a pending follow
 		sub := &subscription{
 			id:        sID,
 			agentName: msg.Agent,
+			project:   project,
+			viewing:   msg.Agent,
 			filter:    filter,
+			history:   history,
+			lastSeq:   -1,
 		}
 		c.subs[sID] = sub
 		c.follows[msg.Agent] = sub
 		c.mu.Unlock()
+		if !replacing {
+			c.joinAgent(msg.Agent)
+		}
 
-		c.sendJSON(serverMessage{
+		c.sendToSub(sub, serverMessage{
 			ID:             msg.ID,
 			Type:           "follow-agent",
 			OK:             boolPtr(true),
@@ -433,13 +952,20 @@ func (c *Client) handleFollowAgent(msg clientMessage) {
 		sub := &subscription{
 			id:        sID,
 			agentName: msg.Agent,
+			project:   project,
+			viewing:   msg.Agent,
 			filter:    filter,
+			history:   history,
+			lastSeq:   -1,
 		}
 		c.subs[sID] = sub
 		c.follows[msg.Agent] = sub
 		c.mu.Unlock()
+		if !replacing {
+			c.joinAgent(msg.Agent)
+		}
 
-		c.sendJSON(serverMessage{
+		c.sendToSub(sub, serverMessage{
 			ID:             msg.ID,
 			Type:           "follow-agent",
 			OK:             boolPtr(true),
@@ -448,25 +974,32 @@ func (c *Client) handleFollowAgent(msg clientMessage) {
 		return
 	}
 
-	snapshot, bufSubID, live := buf.Subscribe(filter)
+	lastSeq, snapshot, bufSubID, live := subscribeBuffer(buf, filter)
 	subCtx, subCancel := context.WithCancel(c.ctx)
 	sub := &subscription{
 		id:             sID,
 		conversationID: convID,
 		agentName:      msg.Agent,
+		project:        project,
+		viewing:        msg.Agent,
 		bufSubID:       bufSubID,
 		filter:         filter,
+		history:        history,
 		live:           live,
 		cancel:         subCancel,
+		lastSeq:        lastSeq,
 	}
 	c.subs[sID] = sub
 	c.follows[msg.Agent] = sub
 	c.mu.Unlock()
+	if !replacing {
+		c.joinAgent(msg.Agent)
+	}
 
-	snapshot = capSnapshot(snapshot)
+	snapshot = sub.historySnapshot(snapshot)
 	cursor := makeCursor(convID, snapshot)
 
-	c.sendJSON(serverMessage{
+	c.sendToSub(sub, serverMessage{
 		ID:             msg.ID,
 		Type:           "follow-agent",
 		OK:             boolPtr(true),
@@ -476,7 +1009,48 @@ func (c *Client) handleFollowAgent(msg clientMessage) {
 		Cursor:         cursor,
 	})
 
-	go c.streamLiveWithContext(sub, buf, subCtx)
+	c.goTracked(func() { c.streamLiveWithContext(sub, buf, subCtx) })
+	c.goTracked(func() { c.reportLoadProgress(subCtx, sub, convID) })
+}
+
+// checkSubscribe applies the server's per-connection limits to a
+// subscribe-conversation or follow-agent request. pending reports whether a
+// follow would wait for the agent's first conversation. Replacing an
+// existing follow doesn't count as a new subscription.
+func (c *Client) checkSubscribe(msg clientMessage, pending bool) *wsbase.LimitError {
+	limits := c.server.limits
+	if msg.Filter != nil {
+		if lerr := limits.CheckFilterTypes(len(msg.Filter.Types)); lerr != nil {
+			return lerr
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	existing, replacing := c.follows[msg.Agent]
+	replacing = replacing && msg.Type == "follow-agent"
+	if !replacing {
+		if lerr := limits.CheckSubscriptions(len(c.subs)); lerr != nil {
+			return lerr
+		}
+	}
+	if pending && (!replacing || existing.conversationID != "") {
+		waiting := 0
+		for _, f := range c.follows {
+			if f.conversationID == "" {
+				waiting++
+			}
+		}
+		if lerr := limits.CheckPendingFollows(waiting); lerr != nil {
+			return lerr
+		}
+	}
+	return nil
+}
+
+// sendLimit refuses a request that would exceed a per-connection limit.
+func (c *Client) sendLimit(id string, lerr *wsbase.LimitError) {
+	c.sendJSON(serverMessage{ID: id, Type: "error", Error: lerr.Error(), Limit: lerr})
 }
 
 func (c *Client) handleUnsubscribe(msg clientMessage) {
@@ -499,15 +1073,25 @@ func (c *Client) handleUnsubscribe(msg clientMessage) {
 			buf.Unsubscribe(sub.bufSubID)
 		}
 	}
+	if ok {
+		c.logf("unsubscribe %s", sub.id)
+		c.leaveAgent(sub.viewing)
+	}
 
 	c.sendJSON(serverMessage{ID: msg.ID, Type: "unsubscribe", OK: boolPtr(true)})
 }
 
 func (c *Client) handleUnsubscribeAgent(msg clientMessage) {
+	c.unfollow(msg.Agent)
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "unsubscribe-agent", OK: boolPtr(true)})
+}
+
+// unfollow ends the client's follow of agentName, if it has one.
+func (c *Client) unfollow(agentName string) {
 	c.mu.Lock()
-	sub, ok := c.follows[msg.Agent]
+	sub, ok := c.follows[agentName]
 	if ok {
-		delete(c.follows, msg.Agent)
+		delete(c.follows, agentName)
 		delete(c.subs, sub.id)
 		if sub.cancel != nil {
 			sub.cancel()
@@ -521,8 +1105,79 @@ func (c *Client) handleUnsubscribeAgent(msg clientMessage) {
 			buf.Unsubscribe(sub.bufSubID)
 		}
 	}
+	if ok {
+		c.logf("unsubscribe-agent %s (%s)", agentName, sub.id)
+		c.leaveAgent(sub.viewing)
+	}
+}
 
-	c.sendJSON(serverMessage{ID: msg.ID, Type: "unsubscribe-agent", OK: boolPtr(true)})
+func (c *Client) handleFollowProject(msg clientMessage) {
+	if msg.Project == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "project required"})
+		return
+	}
+	if _, err := parseHistory(msg.History); err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: err.Error()})
+		return
+	}
+	if msg.Filter != nil {
+		if lerr := c.server.limits.CheckFilterTypes(len(msg.Filter.Types)); lerr != nil {
+			c.sendLimit(msg.ID, lerr)
+			return
+		}
+	}
+
+	c.mu.Lock()
+	c.projects[msg.Project] = msg
+	c.mu.Unlock()
+	members := slices.DeleteFunc(c.server.agentList(), func(a agentInfo) bool { return a.Project != msg.Project })
+	slices.SortFunc(members, agentSorts["name"])
+	c.logf("follow-project %s (%d agents)", msg.Project, len(members))
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "follow-project", OK: boolPtr(true), Project: msg.Project, Agents: members})
+
+	for _, a := range members {
+		c.followProjectAgent(msg, a.Name)
+	}
+}
+
+// followProjectAgent follows agentName, a member of the project req
+// follows, with req's filter and history. An agent the client already
+// follows keeps that follow.
+func (c *Client) followProjectAgent(req clientMessage, agentName string) {
+	c.mu.Lock()
+	_, following := c.follows[agentName]
+	c.mu.Unlock()
+	if following {
+		return
+	}
+	c.followAgent(clientMessage{Type: "follow-agent", Agent: agentName, Filter: req.Filter, History: req.History}, req.Project)
+}
+
+// joinProject follows an agent that was added, or moved into a project,
+// if the client follows its project.
+func (c *Client) joinProject(agent *agents.Agent) {
+	c.mu.Lock()
+	req, ok := c.projects[agent.Project]
+	c.mu.Unlock()
+	if ok {
+		c.followProjectAgent(req, agent.Name)
+	}
+}
+
+func (c *Client) handleUnsubscribeProject(msg clientMessage) {
+	c.mu.Lock()
+	delete(c.projects, msg.Project)
+	var names []string
+	for name, sub := range c.follows {
+		if sub.project != "" && sub.project == msg.Project {
+			names = append(names, name)
+		}
+	}
+	c.mu.Unlock()
+	for _, name := range names {
+		c.unfollow(name)
+	}
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "unsubscribe-project", OK: boolPtr(true)})
 }
 
 func (c *Client) handleSendPrompt(msg clientMessage) {
@@ -530,25 +1185,392 @@ func (c *Client) handleSendPrompt(msg clientMessage) {
 		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "agent field required"})
 		return
 	}
-	if msg.Prompt == "" {
+	if msg.Prompt == "" && len(msg.Attachments) == 0 {
 		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "prompt field required"})
 		return
 	}
+	// Only agents the registry admits (inside --gt-dir) can be prompted.
+	if _, ok := c.server.registry.GetAgent(msg.Agent); !ok {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "send-prompt", OK: boolPtr(false), Error: "agent not found"})
+		return
+	}
+	if err := c.grant.CheckInput(c.server.control, msg.Agent, c); err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "send-prompt", OK: boolPtr(false), Error: err.Error()})
+		return
+	}
+	if msg.SubscriptionID != "" {
+		c.mu.Lock()
+		_, ok := c.subs[msg.SubscriptionID]
+		c.mu.Unlock()
+		if !ok {
+			c.sendJSON(serverMessage{ID: msg.ID, Type: "send-prompt", OK: boolPtr(false), PromptID: msg.PromptID, Error: "subscription not found"})
+			return
+		}
+	}
 
-	lock := c.server.prompter.GetLock(msg.Agent)
-	go func() {
-		lock.Lock()
-		defer lock.Unlock()
+	c.goAgentWork(msg.Agent, "send-prompt", func() {
+		prompt, err := c.server.prompter.ExpandAttachments(msg.Agent, msg.Prompt, msg.Attachments)
+		var sent *conv.SentPrompt
+		var delivery agentio.PromptDelivery
+		if err == nil {
+			// The prompt is expected before it is typed: a fast runtime can
+			// record it before DeliverPrompt returns.
+			delivery, err = c.server.prompter.DeliverPrompt(msg.Agent, prompt, msg.PasteOnly, func(text string) {
+				sent = c.server.watcher.ExpectPrompt(msg.Agent, text, conv.PromptTag{PromptID: msg.PromptID, SubscriptionID: msg.SubscriptionID, Request: msg.Prompt})
+			})
+			if err != nil && sent != nil {
+				sent.Withdraw()
+			}
+		}
+		if err != nil {
+			var rejection *agentio.Rejection
+			errors.As(err, &rejection)
+			c.sendJSON(serverMessage{ID: msg.ID, Type: "send-prompt", OK: boolPtr(false), PromptID: msg.PromptID, Error: err.Error(), Code: tmux.ErrorCode(err), Rejection: rejection, Verification: delivery.Verification})
+			return
+		}
+		resp := serverMessage{ID: msg.ID, Type: "send-prompt", OK: boolPtr(true), PromptID: msg.PromptID, Verification: delivery.Verification, PasteOnly: delivery.PasteOnly}
+		if delivery.Held {
+			resp.Held = boolPtr(true)
+		}
+		c.sendJSON(resp)
+		c.goTracked(func() { c.reportPromptMetrics(msg, delivery, sent) })
+	})
+}
+
+func (c *Client) handleSetPromptHold(msg clientMessage) {
+	if msg.Agent == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "agent field required"})
+		return
+	}
+	if msg.Hold == nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "hold field required"})
+		return
+	}
+	if err := c.grant.CheckInput(c.server.control, msg.Agent, c); err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "set-prompt-hold", OK: boolPtr(false), Error: err.Error()})
+		return
+	}
+	c.server.prompter.Holds.SetHeld(msg.Agent, *msg.Hold)
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "set-prompt-hold", OK: boolPtr(true), Name: msg.Agent, Held: msg.Hold})
+}
+
+func (c *Client) handleConfirmPrompt(msg clientMessage) {
+	if msg.Agent == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "agent field required"})
+		return
+	}
+	if err := c.grant.CheckInput(c.server.control, msg.Agent, c); err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "confirm-prompt", OK: boolPtr(false), Error: err.Error()})
+		return
+	}
 
-		if err := c.server.prompter.SendPrompt(msg.Agent, msg.Prompt); err != nil {
-			c.sendJSON(serverMessage{ID: msg.ID, Type: "send-prompt", OK: boolPtr(false), Error: err.Error()})
+	c.goAgentWork(msg.Agent, "confirm-prompt", func() {
+		delivery, err := c.server.prompter.ConfirmPrompt(msg.Agent)
+		if err != nil {
+			c.sendJSON(serverMessage{ID: msg.ID, Type: "confirm-prompt", OK: boolPtr(false), Error: err.Error(), Code: tmux.ErrorCode(err), Verification: delivery.Verification})
 			return
 		}
-		c.sendJSON(serverMessage{ID: msg.ID, Type: "send-prompt", OK: boolPtr(true)})
-	}()
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "confirm-prompt", OK: boolPtr(true), Verification: delivery.Verification})
+	})
 }
 
-func (c *Client) deliverConversationEvent(event *conv.ConversationEvent) {
+func (c *Client) handleRunCommand(msg clientMessage) {
+	if msg.Agent == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "agent field required"})
+		return
+	}
+	if msg.Command == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "command field required"})
+		return
+	}
+	if _, ok := c.server.registry.GetAgent(msg.Agent); !ok {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "run-command", OK: boolPtr(false), Error: "agent not found"})
+		return
+	}
+	if err := c.grant.CheckInput(c.server.control, msg.Agent, c); err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "run-command", OK: boolPtr(false), Error: err.Error()})
+		return
+	}
+
+	c.goAgentWork(msg.Agent, "run-command", func() {
+		if err := c.server.prompter.RunCommand(msg.Agent, msg.Command); err != nil {
+			c.sendJSON(serverMessage{ID: msg.ID, Type: "run-command", OK: boolPtr(false), Error: err.Error(), Code: tmux.ErrorCode(err)})
+			return
+		}
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "run-command", OK: boolPtr(true)})
+	})
+}
+
+// handleResync re-sends a subscription's current snapshot, for clients that
+// detected a msgSeq gap. Live delivery continues; events may repeat, keyed by seq.
+func (c *Client) handleResync(msg clientMessage) {
+	c.mu.Lock()
+	sub, ok := c.subs[msg.SubscriptionID]
+	var convID string
+	if ok {
+		convID = sub.conversationID
+	}
+	c.mu.Unlock()
+	if !ok {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "subscription not found"})
+		return
+	}
+
+	var buf *conv.ConversationBuffer
+	if convID != "" {
+		buf = c.server.watcher.GetBuffer(convID)
+	}
+	sub.gapMu.Lock()
+	defer sub.gapMu.Unlock()
+	c.resyncLocked(sub, buf, convID, msg.ID)
+}
+
+// resyncLocked sends sub a fresh snapshot of buf and moves its lastSeq to
+// the end of it. The caller holds sub.gapMu.
+func (c *Client) resyncLocked(sub *subscription, buf *conv.ConversationBuffer, convID, id string) {
+	var snapshot []conv.ConversationEvent
+	if buf != nil {
+		before := buf.LastSeq()
+		full := buf.Snapshot(sub.filter)
+		sub.lastSeq = caughtUpSeq(before, full)
+		snapshot = sub.historySnapshot(full)
+	}
+	c.sendToSub(sub, serverMessage{
+		ID:             id,
+		Type:           "conversation-snapshot",
+		SubscriptionID: sub.id,
+		ConversationID: convID,
+		Events:         snapshot,
+		Cursor:         makeCursor(convID, snapshot),
+		Reason:         "resync",
+	})
+}
+
+func (c *Client) handleAcquireControl(msg clientMessage) {
+	if msg.Agent == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "agent field required"})
+		return
+	}
+	if _, ok := c.server.registry.GetAgent(msg.Agent); !ok {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "acquire-control", OK: boolPtr(false), Error: "agent not found"})
+		return
+	}
+	if !c.grant.Get().Control {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "acquire-control", OK: boolPtr(false), Error: wsbase.ErrControlNotAllowed.Error()})
+		return
+	}
+
+	holder, acquired := c.server.control.Acquire(msg.Agent, c, c.id)
+	if !acquired {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "acquire-control", OK: boolPtr(false), ControlledBy: holder, Error: msg.Agent + " is already controlled by " + holder})
+		return
+	}
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "acquire-control", OK: boolPtr(true), ControlledBy: holder})
+	c.server.broadcastControl(msg.Agent, holder)
+}
+
+func (c *Client) handleReleaseControl(msg clientMessage) {
+	if msg.Agent == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "agent field required"})
+		return
+	}
+	released := c.server.control.Release(msg.Agent, c)
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "release-control", OK: boolPtr(true)})
+	if released {
+		c.server.broadcastControl(msg.Agent, "")
+	}
+}
+
+func (c *Client) handleGetParseErrors(msg clientMessage) {
+	failures := c.server.watcher.GetParseErrors(msg.ConversationID)
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "get-parse-errors", ConversationID: msg.ConversationID, ParseErrors: failures})
+}
+
+// handleAnnotateConversation adds an annotation event to a conversation's
+// timeline. The author defaults to the connection's identity.
+func (c *Client) handleAnnotateConversation(msg clientMessage) {
+	if !c.grant.Get().Annotate {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "annotate-conversation", OK: boolPtr(false), Error: wsbase.ErrAnnotateNotAllowed.Error()})
+		return
+	}
+	if msg.ConversationID == "" || msg.Text == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "conversationId and text fields required"})
+		return
+	}
+	author := msg.Author
+	if author == "" {
+		author = c.grant.Get().Subject
+	}
+	if author == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "author field required"})
+		return
+	}
+
+	event, err := c.server.watcher.Annotate(msg.ConversationID, author, msg.Text)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "annotate-conversation", OK: boolPtr(false), Error: err.Error()})
+		return
+	}
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "annotate-conversation", OK: boolPtr(true), ConversationID: msg.ConversationID, Event: &event})
+}
+
+// handleResumeHint tells the client how to resume a conversation in a new
+// tmux session.
+func (c *Client) handleResumeHint(msg clientMessage) {
+	if msg.ConversationID == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "conversationId field required"})
+		return
+	}
+	hint, err := c.server.watcher.ResumeHint(msg.ConversationID)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "resume-hint", OK: boolPtr(false), ConversationID: msg.ConversationID, Error: err.Error()})
+		return
+	}
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "resume-hint", OK: boolPtr(true), ConversationID: msg.ConversationID, Resume: &hint})
+}
+
+// handleGetBlob sends the data behind an image block's blobId: a JSON
+// reply with its size, then a 0x0C binary frame carrying the bytes.
+func (c *Client) handleGetBlob(msg clientMessage) {
+	if msg.BlobID == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "blobId field required"})
+		return
+	}
+	data, err := c.server.watcher.Blob(msg.BlobID)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "get-blob", OK: boolPtr(false), BlobID: msg.BlobID, Error: err.Error()})
+		return
+	}
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "get-blob", OK: boolPtr(true), BlobID: msg.BlobID, Size: len(data)})
+	c.sendBinary("blob", agentio.MakeBinaryFrame(agentio.BinaryBlob, msg.BlobID, data))
+}
+
+// fullContentChunk is the most text one full-content-chunk message carries.
+const fullContentChunk = 256 << 10
+
+// handleGetFullContent sends a truncated content block's whole text or
+// output: a reply with its size, then full-content-chunk messages in order,
+// the last with done set.
+func (c *Client) handleGetFullContent(msg clientMessage) {
+	if msg.ConversationID == "" || msg.Seq == nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "conversationId and seq fields required"})
+		return
+	}
+	block, err := c.server.watcher.FullContent(msg.ConversationID, *msg.Seq, msg.Block)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "get-full-content", OK: boolPtr(false), ConversationID: msg.ConversationID, Error: err.Error()})
+		return
+	}
+	text := block.Text
+	if block.Type == "tool_result" {
+		text = block.Output
+	}
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "get-full-content", OK: boolPtr(true), ConversationID: msg.ConversationID, Size: len(text)})
+	for off := 0; ; {
+		end := min(off+fullContentChunk, len(text))
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end-- // don't split a character across chunks
+		}
+		done := end == len(text)
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "full-content-chunk", Offset: off, Text: text[off:end], Done: done})
+		if done {
+			return
+		}
+		off = end
+	}
+}
+
+// handleGetBufferStats reports each conversation's buffer occupancy, largest
+// estimated size first, or only the one conversationId names.
+func (c *Client) handleGetBufferStats(msg clientMessage) {
+	stats := c.server.watcher.Stats()
+	if msg.ConversationID != "" {
+		stats = slices.DeleteFunc(stats, func(s conv.ConversationStats) bool { return s.ConversationID != msg.ConversationID })
+		if len(stats) == 0 {
+			c.sendJSON(serverMessage{ID: msg.ID, Type: "get-buffer-stats", OK: boolPtr(false), ConversationID: msg.ConversationID, Error: conv.ErrConversationNotFound.Error()})
+			return
+		}
+	}
+	slices.SortFunc(stats, func(a, b conv.ConversationStats) int {
+		return cmp.Or(cmp.Compare(b.Buffer.Bytes, a.Buffer.Bytes), cmp.Compare(a.ConversationID, b.ConversationID))
+	})
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "get-buffer-stats", OK: boolPtr(true), ConversationID: msg.ConversationID, BufferStats: stats})
+}
+
+// defaultRunGraphWindow is how far back get-run-graph looks without since.
+const defaultRunGraphWindow = time.Hour
+
+func (c *Client) handleGetRunGraph(msg clientMessage) {
+	until := time.Now()
+	if msg.Until != nil {
+		until = *msg.Until
+	}
+	since := until.Add(-defaultRunGraphWindow)
+	if msg.Since != nil {
+		since = *msg.Since
+	}
+	if !since.Before(until) {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "since must be before until"})
+		return
+	}
+	graph := c.server.watcher.RunGraph(since, until)
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "get-run-graph", OK: boolPtr(true), Graph: &graph})
+}
+
+// handleGetPaneText sends the agent's visible pane as plain text, so a
+// client can glance at what the TUI shows (a permission dialog, say)
+// without an adapter connection. Blank rows below the text are dropped.
+func (c *Client) handleGetPaneText(msg clientMessage) {
+	if msg.Agent == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "agent field required"})
+		return
+	}
+	if _, ok := c.server.registry.GetAgent(msg.Agent); !ok {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "get-pane-text", OK: boolPtr(false), Name: msg.Agent, Error: "agent not found"})
+		return
+	}
+	c.goTracked(func() {
+		text, err := c.server.ctrl.CapturePaneVisibleText(msg.Agent)
+		if err != nil {
+			c.sendJSON(serverMessage{ID: msg.ID, Type: "get-pane-text", OK: boolPtr(false), Name: msg.Agent, Error: err.Error(), Code: tmux.ErrorCode(err)})
+			return
+		}
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "get-pane-text", OK: boolPtr(true), Name: msg.Agent, Text: strings.TrimRight(text, "\n")})
+	})
+}
+
+// handleGetPromptHistory sends the prompts typed into an agent, newest
+// first, for clients offering to resend one.
+func (c *Client) handleGetPromptHistory(msg clientMessage) {
+	if msg.Agent == "" {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "error", Error: "agent field required"})
+		return
+	}
+	prompts, next, err := c.server.prompter.History.Page(msg.Agent, msg.Limit, msg.Cursor)
+	if err != nil {
+		c.sendJSON(serverMessage{ID: msg.ID, Type: "get-prompt-history", OK: boolPtr(false), Name: msg.Agent, Error: err.Error()})
+		return
+	}
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "get-prompt-history", OK: boolPtr(true), Name: msg.Agent, Prompts: prompts, NextCursor: next})
+}
+
+// handleListActions sends the configured quick actions: all of them as
+// configured, or with an agent, those selecting it with prompts rendered
+// for it.
+func (c *Client) handleListActions(msg clientMessage) {
+	actions := c.server.actions.List()
+	if msg.Agent != "" {
+		agent, ok := c.server.registry.GetAgent(msg.Agent)
+		if !ok {
+			c.sendJSON(serverMessage{ID: msg.ID, Type: "list-actions", OK: boolPtr(false), Name: msg.Agent, Error: "agent not found"})
+			return
+		}
+		actions = c.server.actions.For(agent)
+	}
+	c.sendJSON(serverMessage{ID: msg.ID, Type: "list-actions", OK: boolPtr(true), Name: msg.Agent, Actions: actions})
+}
+
+func (c *Client) deliverConversationEvent(event *conv.ConversationEvent, encoded json.RawMessage) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -557,18 +1579,7 @@ func (c *Client) deliverConversationEvent(event *conv.ConversationEvent) {
 			continue // already delivered via streamLiveWithContext
 		}
 		if sub.conversationID == event.ConversationID && sub.filter.Matches(*event) {
-			cursor := conv.Cursor{
-				ConversationID: event.ConversationID,
-				Seq:            event.Seq,
-				EventID:        event.EventID,
-			}
-			c.sendJSON(serverMessage{
-				Type:           "conversation-event",
-				SubscriptionID: sub.id,
-				ConversationID: event.ConversationID,
-				Event:          event,
-				Cursor:         encodeCursor(cursor),
-			})
+			c.deliverLive(sub, c.server.watcher.GetBuffer(event.ConversationID), *event, encoded)
 		}
 	}
 }
@@ -592,18 +1603,19 @@ func (c *Client) deliverConversationStarted(we conv.WatcherEvent) {
 		return
 	}
 
-	snapshot, bufSubID, live := buf.Subscribe(sub.filter)
+	lastSeq, snapshot, bufSubID, live := subscribeBuffer(buf, sub.filter)
 	subCtx, subCancel := context.WithCancel(c.ctx)
 
 	sub.conversationID = we.NewConvID
 	sub.bufSubID = bufSubID
 	sub.live = live
 	sub.cancel = subCancel
+	sub.setLastSeq(lastSeq)
 
-	snapshot = capSnapshot(snapshot)
+	snapshot = sub.historySnapshot(snapshot)
 	cursor := makeCursor(we.NewConvID, snapshot)
 
-	c.sendJSON(serverMessage{
+	c.sendToSub(sub, serverMessage{
 		Type:           "conversation-snapshot",
 		SubscriptionID: sub.id,
 		ConversationID: we.NewConvID,
@@ -611,7 +1623,8 @@ func (c *Client) deliverConversationStarted(we conv.WatcherEvent) {
 		Cursor:         cursor,
 	})
 
-	go c.streamLiveWithContext(sub, buf, subCtx)
+	c.goTracked(func() { c.streamLiveWithContext(sub, buf, subCtx) })
+	c.goTracked(func() { c.reportLoadProgress(subCtx, sub, we.NewConvID) })
 }
 
 func (c *Client) deliverConversationSwitch(we conv.WatcherEvent) {
@@ -639,7 +1652,7 @@ func (c *Client) deliverConversationSwitch(we conv.WatcherEvent) {
 	}
 
 	// Send switch message
-	c.sendJSON(serverMessage{
+	c.sendToSub(sub, serverMessage{
 		Type:           "conversation-switched",
 		SubscriptionID: sub.id,
 		Agent:          we.Agent,
@@ -653,18 +1666,19 @@ func (c *Client) deliverConversationSwitch(we conv.WatcherEvent) {
 		return
 	}
 
-	snapshot, bufSubID, live := newBuf.Subscribe(sub.filter)
+	lastSeq, snapshot, bufSubID, live := subscribeBuffer(newBuf, sub.filter)
 	subCtx, subCancel := context.WithCancel(c.ctx)
 
 	sub.conversationID = we.NewConvID
 	sub.bufSubID = bufSubID
 	sub.live = live
 	sub.cancel = subCancel
+	sub.setLastSeq(lastSeq)
 
-	snapshot = capSnapshot(snapshot)
+	snapshot = sub.historySnapshot(snapshot)
 	cursor := makeCursor(we.NewConvID, snapshot)
 
-	c.sendJSON(serverMessage{
+	c.sendToSub(sub, serverMessage{
 		Type:           "conversation-snapshot",
 		SubscriptionID: sub.id,
 		ConversationID: we.NewConvID,
@@ -673,14 +1687,11 @@ func (c *Client) deliverConversationSwitch(we conv.WatcherEvent) {
 		Reason:         "switch",
 	})
 
-	go c.streamLiveWithContext(sub, newBuf, subCtx)
+	c.goTracked(func() { c.streamLiveWithContext(sub, newBuf, subCtx) })
+	c.goTracked(func() { c.reportLoadProgress(subCtx, sub, we.NewConvID) })
 }
 
-func (c *Client) streamLive(sub *subscription, buf *conv.ConversationBuffer) {
-	c.streamLiveWithContext(sub, buf, c.ctx)
-}
-
-func (c *Client) streamLiveWithContext(sub *subscription, _ *conv.ConversationBuffer, ctx context.Context) {
+func (c *Client) streamLiveWithContext(sub *subscription, buf *conv.ConversationBuffer, ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -689,23 +1700,127 @@ func (c *Client) streamLiveWithContext(sub *subscription, _ *conv.ConversationBu
 			if !ok {
 				return
 			}
-			cursor := conv.Cursor{
-				ConversationID: sub.conversationID,
-				Seq:            event.Seq,
-				EventID:        event.EventID,
+			c.deliverLive(sub, buf, event, nil)
+		}
+	}
+}
+
+// deliverLive sends a live event to sub. The channels feeding live delivery
+// drop events for slow readers, so a jump in seq first backfills the missed
+// events from buf; if those have already been evicted, the subscription gets
+// a fresh snapshot with reason "resync" instead. A new version of an event
+// already sent goes out as conversation-event-updated.
+// encoded, if non-nil, is event already encoded for sharing across clients.
+func (c *Client) deliverLive(sub *subscription, buf *conv.ConversationBuffer, event conv.ConversationEvent, encoded json.RawMessage) {
+	sub.gapMu.Lock()
+	defer sub.gapMu.Unlock()
+
+	if event.Seq <= sub.lastSeq {
+		if event.Updates > 0 {
+			c.sendEvent(sub, "conversation-event-updated", event, encoded)
+		}
+		return // already sent by a backfill or snapshot
+	}
+	if buf != nil && event.Seq > sub.lastSeq+1 {
+		missed, ok := buf.EventsSince(sub.lastSeq, sub.filter)
+		if !ok {
+			c.logf("subscription %s missed evicted events after seq %d, resyncing", sub.id, sub.lastSeq)
+			c.resyncLocked(sub, buf, event.ConversationID, "")
+			if event.Seq <= sub.lastSeq {
+				return
+			}
+		} else {
+			for _, e := range missed {
+				if e.Seq >= event.Seq {
+					break
+				}
+				c.sendEvent(sub, "conversation-event", e, nil)
 			}
-			c.sendJSON(serverMessage{
-				Type:           "conversation-event",
-				SubscriptionID: sub.id,
-				ConversationID: sub.conversationID,
-				Event:          &event,
-				Cursor:         encodeCursor(cursor),
-			})
 		}
 	}
+	c.sendEvent(sub, "conversation-event", event, encoded)
+	sub.lastSeq = event.Seq
+}
+
+// sendEvent sends event as a message of type typ: conversation-event, or
+// conversation-event-updated for a new version of one already sent.
+func (c *Client) sendEvent(sub *subscription, typ string, event conv.ConversationEvent, encoded json.RawMessage) {
+	var payload any = &event
+	if encoded != nil {
+		payload = encoded
+	}
+	cursor := conv.Cursor{
+		ConversationID: event.ConversationID,
+		Seq:            event.Seq,
+		EventID:        event.EventID,
+		StableID:       event.StableID,
+	}
+	c.sendToSub(sub, serverMessage{
+		Type:           typ,
+		SubscriptionID: sub.id,
+		ConversationID: event.ConversationID,
+		Event:          payload,
+		Cursor:         encodeCursor(cursor),
+	})
+}
+
+func (s *subscription) setLastSeq(seq int64) {
+	s.gapMu.Lock()
+	s.lastSeq = seq
+	s.gapMu.Unlock()
+}
+
+// subscribeBuffer subscribes to buf and also returns the seq the subscriber
+// is caught up to once it has the snapshot.
+func subscribeBuffer(buf *conv.ConversationBuffer, filter conv.EventFilter) (int64, []conv.ConversationEvent, int, <-chan conv.ConversationEvent) {
+	before := buf.LastSeq()
+	snapshot, bufSubID, live := buf.Subscribe(filter)
+	return caughtUpSeq(before, snapshot), snapshot, bufSubID, live
+}
+
+// caughtUpSeq is the seq a subscriber holding snapshot is caught up to: the
+// buffer's last seq read just before taking it, or the snapshot's last event
+// if more arrived in between. Events appended in between that the filter
+// excluded are never backfilled, so they need not be counted.
+func caughtUpSeq(before int64, snapshot []conv.ConversationEvent) int64 {
+	if n := len(snapshot); n > 0 && snapshot[n-1].Seq > before {
+		return snapshot[n-1].Seq
+	}
+	return before
+}
+
+// joinAgent counts this client as a viewer of agentName and announces it.
+func (c *Client) joinAgent(agentName string) {
+	if agentName == "" {
+		return
+	}
+	if count, joined := c.server.presence.Join(agentName, c); joined {
+		c.server.broadcastViewers("viewer-joined", agentName, count)
+	}
+}
+
+// leaveAgent releases one of this client's subscriptions to agentName.
+func (c *Client) leaveAgent(agentName string) {
+	if agentName == "" {
+		return
+	}
+	if count, left := c.server.presence.Leave(agentName, c); left {
+		c.server.broadcastViewers("viewer-left", agentName, count)
+	}
 }
 
 func (c *Client) cleanup() {
+	// Runs after removeClient releases the server lock, so broadcasting is safe.
+	defer func() {
+		for agentName, count := range c.server.presence.LeaveAll(c) {
+			c.server.broadcastViewers("viewer-left", agentName, count)
+		}
+		for _, agentName := range c.server.control.ReleaseAll(c) {
+			c.server.broadcastControl(agentName, "")
+		}
+		c.server.prompter.ReleaseChunkedUploads(c)
+	}()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -722,20 +1837,41 @@ func (c *Client) cleanup() {
 	}
 	c.subs = nil
 	c.follows = nil
+	c.projects = nil
 }
 
 // Helper types and functions
 
 type clientMessage struct {
-	ID             string           `json:"id"`
-	Type           string           `json:"type"`
-	Protocol       string           `json:"protocol,omitempty"`
-	ConversationID string           `json:"conversationId,omitempty"`
-	Agent          string           `json:"agent,omitempty"`
-	Prompt         string           `json:"prompt,omitempty"`
-	SubscriptionID string           `json:"subscriptionId,omitempty"`
-	Filter         *clientFilter    `json:"filter,omitempty"`
-	Cursor         string           `json:"cursor,omitempty"`
+	ID             string        `json:"id"`
+	Type           string        `json:"type"`
+	Protocol       string        `json:"protocol,omitempty"`
+	ConversationID string        `json:"conversationId,omitempty"`
+	Agent          string        `json:"agent,omitempty"`
+	Project        string        `json:"project,omitempty"`
+	Prompt         string        `json:"prompt,omitempty"`
+	PromptID       string        `json:"promptId,omitempty"`
+	PasteOnly      bool          `json:"pasteOnly,omitempty"`
+	Hold           *bool         `json:"hold,omitempty"`
+	Attachments    []string      `json:"attachments,omitempty"`
+	Command        string        `json:"command,omitempty"`
+	Author         string        `json:"author,omitempty"`
+	Text           string        `json:"text,omitempty"`
+	SubscriptionID string        `json:"subscriptionId,omitempty"`
+	Filter         *clientFilter `json:"filter,omitempty"`
+	Cursor         string        `json:"cursor,omitempty"`
+	History        string        `json:"history,omitempty"`
+	Debug          bool          `json:"debug,omitempty"`
+	SortBy         string        `json:"sortBy,omitempty"`
+	AgentFilter    []string      `json:"agentFilter,omitempty"`
+	FilterSyntax   string        `json:"filterSyntax,omitempty"`
+	Limit          int           `json:"limit,omitempty"`
+	Offset         int           `json:"offset,omitempty"`
+	BlobID         string        `json:"blobId,omitempty"`
+	Seq            *int64        `json:"seq,omitempty"`
+	Block          int           `json:"block,omitempty"`
+	Since          *time.Time    `json:"since,omitempty"`
+	Until          *time.Time    `json:"until,omitempty"`
 }
 
 type clientFilter struct {
@@ -745,31 +1881,75 @@ type clientFilter struct {
 }
 
 type serverMessage struct {
-	ID             string                    `json:"id,omitempty"`
-	Type           string                    `json:"type"`
-	OK             *bool                     `json:"ok,omitempty"`
-	Error          string                    `json:"error,omitempty"`
-	Protocol       string                    `json:"protocol,omitempty"`
-	ServerVersion  string                    `json:"serverVersion,omitempty"`
-	UnknownType    string                    `json:"unknownType,omitempty"`
-	Agents         []agentInfo               `json:"agents,omitempty"`
-	Conversations  []conv.ConversationInfo   `json:"conversations,omitempty"`
-	SubscriptionID string                    `json:"subscriptionId,omitempty"`
-	ConversationID string                    `json:"conversationId,omitempty"`
-	Events         []conv.ConversationEvent  `json:"events,omitempty"`
-	Event          *conv.ConversationEvent   `json:"event,omitempty"`
-	Cursor         string                    `json:"cursor,omitempty"`
-	Agent          any                       `json:"agent,omitempty"`
-	Name           string                    `json:"name,omitempty"`
-	From           string                    `json:"from,omitempty"`
-	To             string                    `json:"to,omitempty"`
-	Reason         string                    `json:"reason,omitempty"`
+	ID             string                   `json:"id,omitempty"`
+	Type           string                   `json:"type"`
+	OK             *bool                    `json:"ok,omitempty"`
+	Error          string                   `json:"error,omitempty"`
+	Code           string                   `json:"code,omitempty"` // tmux.ErrorCode of the failure, e.g. session-not-found
+	Protocol       string                   `json:"protocol,omitempty"`
+	ServerVersion  string                   `json:"serverVersion,omitempty"`
+	UnknownType    string                   `json:"unknownType,omitempty"`
+	Agents         []agentInfo              `json:"agents,omitempty"`
+	Total          *int                     `json:"total,omitempty"`
+	NextCursor     string                   `json:"nextCursor,omitempty"`
+	Conversations  []conv.ConversationInfo  `json:"conversations,omitempty"`
+	BufferStats    []conv.ConversationStats `json:"bufferStats,omitempty"`
+	SubscriptionID string                   `json:"subscriptionId,omitempty"`
+	ConversationID string                   `json:"conversationId,omitempty"`
+	Events         []conv.ConversationEvent `json:"events,omitempty"`
+	Event          any                      `json:"event,omitempty"` // *conv.ConversationEvent, or one encoded once for all clients
+	Cursor         string                   `json:"cursor,omitempty"`
+	Agent          any                      `json:"agent,omitempty"`
+	Name           string                   `json:"name,omitempty"`
+	From           string                   `json:"from,omitempty"`
+	To             string                   `json:"to,omitempty"`
+	Reason         string                   `json:"reason,omitempty"`
+	Graph          *conv.RunGraph           `json:"graph,omitempty"`
+	Project        string                   `json:"project,omitempty"`
+	OldName        string                   `json:"oldName,omitempty"`
+	PromptID       string                   `json:"promptId,omitempty"`
+	RequestID      string                   `json:"requestId,omitempty"`
+	Verification   string                   `json:"verification,omitempty"`
+	PasteOnly      bool                     `json:"pasteOnly,omitempty"`
+	Held           *bool                    `json:"held,omitempty"`
+	Prompts        []agentio.PromptRecord   `json:"prompts,omitempty"`
+	Actions        []agentio.QuickAction    `json:"actions,omitempty"`
+	PromptMetrics  *promptMetrics           `json:"metrics,omitempty"`
+	ParseErrors    []conv.ParseFailure      `json:"parseErrors,omitempty"`
+	Resume         *conv.ResumeHint         `json:"resume,omitempty"`
+	Debug          bool                     `json:"debug,omitempty"`
+	ViewerCount    *int                     `json:"viewerCount,omitempty"`
+	MsgSeq         int64                    `json:"msgSeq,omitempty"`
+	Progress       *snapshotProgress        `json:"progress,omitempty"`
+	ControlledBy   string                   `json:"controlledBy,omitempty"`
+	Upload         *agentio.UploadProgress  `json:"upload,omitempty"`
+	FileID         string                   `json:"fileId,omitempty"`
+	BlobID         string                   `json:"blobId,omitempty"`
+	Size           int                      `json:"size,omitempty"`
+	Offset         int                      `json:"offset,omitempty"`
+	Text           string                   `json:"text,omitempty"`
+	Done           bool                     `json:"done,omitempty"`
+	Rejection      *agentio.Rejection       `json:"rejection,omitempty"`
+	Limit          *wsbase.LimitError       `json:"limit,omitempty"`
+	ServerTiming   *serverTiming            `json:"serverTiming,omitempty"`
 }
 
 type agentInfo struct {
 	Name           string `json:"name"`
 	Runtime        string `json:"runtime"`
+	Project        string `json:"project,omitempty"`
 	ConversationID string `json:"conversationId,omitempty"`
+	ViewerCount    int    `json:"viewerCount"`
+	ControlledBy   string `json:"controlledBy,omitempty"`
+	CurrentModel   string `json:"currentModel,omitempty"`
+	conv.Activity
+}
+
+// agentUpdate is an agent-added or agent-updated payload: the agent and its
+// active conversation's activity.
+type agentUpdate struct {
+	*agents.Agent
+	conv.Activity
 }
 
 func buildFilter(cf *clientFilter) conv.EventFilter {
@@ -810,6 +1990,33 @@ func itoa(n int) string {
 	return string(buf[pos:])
 }
 
+// parseHistory reads a subscription's history option: "full" (the default,
+// conv.FullHistory), "none" for live events only, or "recent:N" for the last
+// N buffered events. It limits what snapshots send, and how much of a
+// conversation that isn't being tailed is read (see EnsureTailing).
+func parseHistory(s string) (int, error) {
+	switch s {
+	case "", "full":
+		return conv.FullHistory, nil
+	case "none":
+		return 0, nil
+	}
+	if n, ok := strings.CutPrefix(s, "recent:"); ok {
+		if v, err := strconv.Atoi(n); err == nil && v > 0 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid history %q: want none, recent:N, or full", s)
+}
+
+// historySnapshot trims a snapshot to the history the subscription asked for.
+func (s *subscription) historySnapshot(events []conv.ConversationEvent) []conv.ConversationEvent {
+	if s.history >= 0 && len(events) > s.history {
+		events = events[len(events)-s.history:]
+	}
+	return capSnapshot(events)
+}
+
 func capSnapshot(events []conv.ConversationEvent) []conv.ConversationEvent {
 	if len(events) > maxSnapshotEvents {
 		return events[len(events)-maxSnapshotEvents:]
@@ -826,6 +2033,7 @@ func makeCursor(convID string, events []conv.ConversationEvent) string {
 		ConversationID: convID,
 		Seq:            last.Seq,
 		EventID:        last.EventID,
+		StableID:       last.StableID,
 	}
 	return encodeCursor(c)
 }
@@ -0,0 +1,49 @@
+package wsconv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gastownhall/tmux-adapter/internal/conv"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
+)
+
+func TestRefusedSubscriptionsStartNoTailer(t *testing.T) {
+	srv := newTestServer(t, func(w *conv.ConversationWatcher) { w.SetIdleTTL(10 * time.Millisecond) })
+	deadline := time.Now().Add(3 * time.Second)
+	for srv.watcher.GetBuffer("claude:test") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("claude:test was never collected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	limited := NewServer(srv.watcher, nil, srv.registry, Options{Limits: wsbase.Limits{MaxSubscriptions: 1}})
+
+	tests := []struct {
+		name string
+		full bool // the client already holds its one subscription
+		msg  clientMessage
+	}{
+		{name: "subscribe over limit", full: true, msg: clientMessage{ID: "1", Type: "subscribe-conversation", ConversationID: "claude:test"}},
+		{name: "subscribe bad history", msg: clientMessage{ID: "2", Type: "subscribe-conversation", ConversationID: "claude:test", History: "some"}},
+		{name: "follow over limit", full: true, msg: clientMessage{ID: "3", Type: "follow-agent", Agent: "hq-mayor"}},
+		{name: "follow bad history", msg: clientMessage{ID: "4", Type: "follow-agent", Agent: "hq-mayor", History: "some"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClient(nil, limited, wsbase.ConnInfo{})
+			defer c.cancel()
+			if tt.full {
+				c.subs["sub-0"] = &subscription{id: "sub-0"}
+			}
+			if tt.msg.Type == "follow-agent" {
+				c.handleFollowAgent(tt.msg)
+			} else {
+				c.handleSubscribeConversation(tt.msg)
+			}
+			if srv.watcher.GetBuffer("claude:test") != nil {
+				t.Fatal("refused request started tailing the conversation")
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -9,13 +10,29 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gastownhall/tmux-adapter/internal/adapter"
+	"github.com/gastownhall/tmux-adapter/internal/agentio"
+	"github.com/gastownhall/tmux-adapter/internal/agents"
+	"github.com/gastownhall/tmux-adapter/internal/crash"
+	"github.com/gastownhall/tmux-adapter/internal/demo"
+	"github.com/gastownhall/tmux-adapter/internal/service"
+	"github.com/gastownhall/tmux-adapter/internal/tmux"
+	"github.com/gastownhall/tmux-adapter/internal/version"
+	"github.com/gastownhall/tmux-adapter/internal/wsadapter"
+	"github.com/gastownhall/tmux-adapter/internal/wsbase"
 )
 
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: tmux-adapter [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: tmux-adapter [install-service|uninstall-service|demo-agent|version|check-update|self-update] [flags]\n\n")
 		fmt.Fprintf(os.Stderr, "WebSocket service that exposes gastown agents as a programmatic API.\n\n")
 		fmt.Fprintf(os.Stderr, "Flags:\n")
 		flag.PrintDefaults()
@@ -23,31 +40,290 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  tmux-adapter --gt-dir ~/gt --port 8080\n")
 		fmt.Fprintf(os.Stderr, "  tmux-adapter --gt-dir ~/gt --auth-token SECRET\n")
 		fmt.Fprintf(os.Stderr, "  tmux-adapter --gt-dir ~/gt --debug-serve-dir ./samples\n")
+		fmt.Fprintf(os.Stderr, "  tmux-adapter --gt-dir ~/gt --reuse-port   # then: kill -USR1 <old pid>\n")
+		fmt.Fprintf(os.Stderr, "  tmux-adapter install-service --gt-dir ~/gt --auth-token SECRET\n")
+		fmt.Fprintf(os.Stderr, "  tmux-adapter uninstall-service\n")
+		fmt.Fprintf(os.Stderr, "  tmux new-session -d -s gt-demo -c ~/gt 'tmux-adapter demo-agent'\n")
+	}
+
+	// Subcommands take the same flags as the server; install-service bakes them into the unit.
+	if len(os.Args) > 1 {
+		if handled, err := version.RunCommand(os.Args[1], "tmux-adapter"); handled {
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "demo-agent" {
+		runDemoAgent(os.Args[2:])
+		return
+	}
+
+	var command string
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "install-service" || args[0] == "uninstall-service") {
+		command, args = args[0], args[1:]
 	}
 
 	gtDir := flag.String("gt-dir", filepath.Join(os.Getenv("HOME"), "gt"), "gastown town directory")
 	port := flag.Int("port", 8080, "WebSocket server port")
 	authToken := flag.String("auth-token", "", "optional WebSocket auth token (Bearer token or ?token=...)")
-	allowedOrigins := flag.String("allowed-origins", "localhost:*", "comma-separated origin patterns for WebSocket CORS")
+	jwtSecret := flag.String("jwt-secret", "", "comma-separated HS256 secrets; JWTs signed with any are accepted (list old and new to rotate)")
+	jwtPublicKey := flag.String("jwt-public-key", "", "PEM file of RS256 public keys for JWT verification")
+	jwksURL := flag.String("jwks-url", "", "JWKS endpoint for RS256 JWT keys, refetched every 10m and on unknown key IDs")
+	jwtAudience := flag.String("jwt-audience", "", "require this value in each JWT's aud claim")
+	jwtExpiry := flag.String("jwt-expiry", "close", "when a connection's JWT expires: close, or read-only")
+	tlsCert := flag.String("tls-cert", "", "serve HTTPS/WSS with this certificate (PEM)")
+	tlsKey := flag.String("tls-key", "", "private key for --tls-cert (PEM)")
+	tlsClientCA := flag.String("tls-client-ca", "", "verify client certificates against this CA bundle (mutual TLS)")
+	var certScopes stringList
+	flag.Var(&certScopes, "client-cert-scope", "map a client certificate common name to scopes, e.g. ops=read,prompt,control; * matches any (repeatable)")
+	allowedOrigins := flag.String("allowed-origins", "localhost:*", "comma-separated origin patterns for WebSocket CORS (host, scheme://host, file://*, null, or *)")
+	allowRemoteCIDR := flag.String("allow-remote-cidr", "", "comma-separated CIDRs whose clients may connect from any origin (e.g. 192.168.0.0/16)")
+	allowIPs := flag.String("allow-ips", "", "comma-separated IPs or CIDRs allowed to connect; others get 403 (default: all)")
+	maxConnsPerIP := flag.Int("max-conns-per-ip", 0, "maximum concurrent WebSocket connections from one IP; 0 for no limit")
+	maxMessageBytes := flag.Int("max-message-bytes", wsbase.DefaultLimits.MaxMessageBytes, "largest inbound JSON message accepted per WebSocket frame; 0 for no limit")
+	maxSubscriptions := flag.Int("max-subscriptions", wsbase.DefaultLimits.MaxSubscriptions, "maximum open subscriptions per connection; 0 for no limit")
+	maxFilterPatterns := flag.Int("max-filter-patterns", wsbase.DefaultLimits.MaxFilterPatterns, "maximum agentFilter patterns in one request; 0 for no limit")
+	controlIdleTimeout := flag.Duration("control-idle-timeout", 15*time.Minute, "release input control after this long without input from its holder; 0 keeps it until released")
+	promptMaxLength := flag.Int("prompt-max-length", 0, "reject prompts longer than this many bytes; 0 for no limit")
+	promptBlockSecrets := flag.Bool("prompt-block-secrets", false, "reject prompts containing API keys, tokens, or private keys")
+	var promptDeny stringList
+	flag.Var(&promptDeny, "prompt-deny-pattern", "reject prompts matching this regex (repeatable)")
+	promptPrefix := flag.String("prompt-prefix", "", "prepend this text to prompts that don't already start with it")
+	promptHook := flag.String("prompt-hook", "", "shell command that screens each prompt on stdin ($TMUX_ADAPTER_AGENT set); non-zero exit rejects, stdout rewrites")
+	uploadExtensions := flag.String("upload-extensions", "", "comma-separated file extensions allowed for uploads, e.g. .png,.txt (default: any)")
+	uploadMIMETypes := flag.String("upload-mime-types", "", "comma-separated MIME types allowed for uploads; image/* matches a family (default: any)")
+	uploadMaxBytes := flag.Int("upload-max-bytes", 0, "maximum size of one uploaded file; 0 for the protocol limit (8MiB per frame, 1GiB chunked)")
+	uploadQuota := flag.Int64("upload-agent-quota", 0, "maximum total bytes of uploads kept per agent; 0 for no limit")
+	uploadScanner := flag.String("upload-scanner", "", "shell command run on each upload with the staged file as $1; non-zero exit rejects it")
+	var holdPrompts stringList
+	flag.Var(&holdPrompts, "hold-prompts", "type prompts to agents matching this glob or regex but wait for confirm-prompt before submitting them; !pattern excludes (repeatable)")
+	holdTimeout := flag.Duration("hold-timeout", 0, "with --hold-prompts or set-prompt-hold: submit a held prompt nobody confirmed after this long, e.g. 5m; 0 waits for confirm-prompt")
+	promptHistory := flag.Int("prompt-history", agentio.DefaultPromptHistory, "how many prompts get-prompt-history keeps per agent; 0 records none")
+	promptHistoryFile := flag.String("prompt-history-file", "", "also append every prompt to this JSONL file and reload it on start, so history survives restarts")
+	actionsConfig := flag.String("actions-config", "", "JSON file of quick actions (label, prompt template, agent selectors) that list-actions offers every client")
+	submitConfig := flag.String("submit-config", "", "JSON file of per-runtime submit strategies: the keys that submit a typed prompt, the settle delay before them, whether Escape is sent first, and paste-only mode")
+	resizePolicy := flag.String("resize-policy", string(agentio.ResizeLastWriter), "whose resize frames set an agent's size when several clients view it: last-writer, largest, first-writer, or controller")
+	rescanInterval := flag.Duration("rescan-interval", agents.DefaultRescanInterval, "how often sessions without an agent are checked for one started in them; 0 relies on tmux notifications alone")
+	scanServers := flag.String("scan-tmux-servers", "", "also watch other users' tmux servers whose sockets match this glob, e.g. "+tmux.DefaultServerPattern+"; agents are named user:session (needs root)")
+	recordTmux := flag.String("record-tmux", "", "write every tmux control mode command and reply, with timestamps, to this JSONL file for --replay-tmux")
+	replayTmux := flag.String("replay-tmux", "", "play back a --record-tmux file instead of connecting to tmux, to reproduce registry and detection bugs offline")
+	replaySpeed := flag.Float64("replay-speed", 1, "with --replay-tmux: play notifications this many times faster than recorded; 0 as fast as possible")
 	debugServeDir := flag.String("debug-serve-dir", "", "serve static files from this directory at / (development only)")
-	flag.Parse()
+	reusePort := flag.Bool("reuse-port", false, "bind with SO_REUSEPORT so a new adapter can take over the port while this one drains")
+	pprof := flag.Bool("pprof", false, "serve net/http/pprof at /debug/pprof/, authorized by --auth-token")
+	drainTimeout := flag.Duration("drain-timeout", 10*time.Minute, "on SIGUSR1, how long to keep serving connected clients before exiting")
+	stateDir := flag.String("state-dir", service.DefaultStateDir("tmux-adapter"), "directory for service state and logs")
+	crashDumps := flag.Bool("crash-dumps", false, "write a dump with every goroutine's stack to crashes/ under --state-dir when a panic is recovered")
+	_ = flag.CommandLine.Parse(args)
 
-	var origins []string
-	for _, o := range strings.Split(*allowedOrigins, ",") {
-		if s := strings.TrimSpace(o); s != "" {
-			origins = append(origins, s)
+	switch command {
+	case "install-service":
+		installService(*stateDir, args)
+		return
+	case "uninstall-service":
+		path, err := service.Uninstall("tmux-adapter")
+		if err != nil {
+			log.Fatal(err)
 		}
+		fmt.Printf("removed %s\n", path)
+		return
 	}
 
-	a := adapter.New(*gtDir, *port, *authToken, origins, *debugServeDir)
+	if *crashDumps {
+		crash.SetDumpDir(filepath.Join(*stateDir, "crashes"))
+	}
+
+	origins, err := wsbase.ParseOriginPolicy(strings.Split(*allowedOrigins, ","), strings.Split(*allowRemoteCIDR, ","))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jwt, err := wsbase.LoadJWTVerifier(*jwtSecret, *jwtPublicKey, *jwksURL, *jwtAudience)
+	if err != nil {
+		log.Fatal(err)
+	}
+	onExpiry, err := wsbase.ParseExpiryAction(*jwtExpiry)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tlsConfig, err := wsbase.TLSConfig(*tlsCert, *tlsKey, *tlsClientCA)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth := wsbase.NewAuthenticator(*authToken, jwt, onExpiry)
+	if len(certScopes) > 0 {
+		if *tlsClientCA == "" {
+			log.Fatal("--client-cert-scope requires --tls-client-ca")
+		}
+		certs, err := wsbase.ParseCertScopes(certScopes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		auth.SetCertScopes(certs)
+	}
+	if *pprof && !auth.Enabled() {
+		log.Fatal("--pprof requires credentials: --auth-token, a JWT option, or --client-cert-scope")
+	}
+
+	ipGuard, err := wsbase.ParseIPGuard(strings.Split(*allowIPs, ","), *maxConnsPerIP)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	promptPolicy, err := agentio.LoadPromptPolicy(*promptMaxLength, promptDeny, *promptBlockSecrets, *promptPrefix, *promptHook)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	uploadPolicy, err := agentio.LoadUploadPolicy(*uploadExtensions, *uploadMIMETypes, *uploadMaxBytes, *uploadQuota, *uploadScanner)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	submit, err := agentio.LoadSubmitStrategies(*submitConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	holdFilter, err := wsbase.ParseNameFilter(holdPrompts, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	holds := agentio.NewPromptHolds(holdFilter, *holdTimeout)
+	history, err := agentio.LoadPromptHistory(*promptHistory, *promptHistoryFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	actions, err := agentio.LoadQuickActions(*actionsConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resize, err := agentio.ParseResizePolicy(*resizePolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *recordTmux != "" && *replayTmux != "" {
+		log.Fatal("--record-tmux and --replay-tmux are mutually exclusive")
+	}
+
+	limits := wsbase.Limits{
+		MaxMessageBytes:   *maxMessageBytes,
+		MaxSubscriptions:  *maxSubscriptions,
+		MaxFilterPatterns: *maxFilterPatterns,
+		MaxPatternBytes:   wsbase.DefaultLimits.MaxPatternBytes,
+	}
+	a := adapter.New(adapter.Config{
+		GTDir:     *gtDir,
+		Port:      *port,
+		TLSConfig: tlsConfig,
+		IPGuard:   ipGuard,
+		Server: wsadapter.Options{
+			Auth:               auth,
+			AllowedOrigins:     origins,
+			PromptPolicy:       promptPolicy,
+			UploadPolicy:       uploadPolicy,
+			Submit:             submit,
+			Holds:              holds,
+			History:            history,
+			Actions:            actions,
+			ResizePolicy:       resize,
+			Limits:             limits,
+			ControlIdleTimeout: *controlIdleTimeout,
+		},
+		ScanServers:    *scanServers,
+		RescanInterval: *rescanInterval,
+		RecordTmux:     *recordTmux,
+		ReplayTmux:     *replayTmux,
+		ReplaySpeed:    *replaySpeed,
+		DebugServeDir:  *debugServeDir,
+		ReusePort:      *reusePort,
+		Pprof:          *pprof,
+	})
 	if err := a.Start(); err != nil {
 		log.Fatal(err)
 	}
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal; SIGUSR1 drains instead of dropping clients
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
+	if sig := <-sigCh; sig != syscall.SIGUSR1 {
+		a.Stop()
+		return
+	}
+
+	// A second signal during the drain cuts it short.
+	ctx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+	defer cancel()
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+	a.Drain(ctx)
+}
+
+func installService(stateDir string, args []string) {
+	binary, err := os.Executable()
+	if err != nil {
+		log.Fatalf("locate executable: %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+
+	// Pin --state-dir so the unit doesn't depend on the service manager's environment.
+	hasStateDir := false
+	flag.Visit(func(f *flag.Flag) { hasStateDir = hasStateDir || f.Name == "state-dir" })
+	if !hasStateDir {
+		args = append(args, "--state-dir", stateDir)
+	}
+
+	path, err := service.Install(service.Config{
+		Name:     "tmux-adapter",
+		Binary:   binary,
+		Args:     args,
+		StateDir: stateDir,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("installed %s\n", path)
+}
+
+// runDemoAgent runs the simulated agent in the current terminal; see
+// package demo.
+func runDemoAgent(args []string) {
+	fs := flag.NewFlagSet("demo-agent", flag.ExitOnError)
+	root := fs.String("root", demo.DefaultRoot(), "directory to write transcripts under (projects/<workdir>/<session>.jsonl)")
+	interval := fs.Duration("interval", 30*time.Second, "start a scripted turn this often (0 only answers typed prompts)")
+	pace := fs.Duration("pace", 1500*time.Millisecond, "delay between the events of a turn")
+	toolRate := fs.Float64("tool-rate", 0.6, "chance (0-1) that a turn calls a tool")
+	seed := fs.Uint64("seed", 0, "seed for the scripted turns (0 = random)")
+	turns := fs.Int("turns", 0, "exit after this many turns (0 = run until interrupted)")
+	model := fs.String("model", "claude-demo", "model name written on replies")
+	_ = fs.Parse(args)
 
-	a.Stop()
+	if err := demo.MarkSession(); err != nil {
+		log.Printf("demo-agent: %v (the registry may not detect this session)", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := demo.Run(ctx, demo.Options{
+		Root:     *root,
+		Interval: *interval,
+		Pace:     *pace,
+		ToolRate: *toolRate,
+		Seed:     *seed,
+		Turns:    *turns,
+		Model:    *model,
+		In:       os.Stdin,
+		Out:      os.Stdout,
+	}); err != nil {
+		log.Fatalf("demo-agent: %v", err)
+	}
 }
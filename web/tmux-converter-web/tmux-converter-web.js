@@ -133,6 +133,9 @@ tmux-converter-web .event-block.turn_end .event-type-badge { background: #484f58
 tmux-converter-web .event-block.queue_op { background: #161b22; border-left-color: #484f58; }
 tmux-converter-web .event-block.queue_op .event-type-badge { background: #484f5833; color: #484f58; }
 
+tmux-converter-web .event-block.compaction { background: #161b22; border-left-color: #d29922; }
+tmux-converter-web .event-block.compaction .event-type-badge { background: #d2992233; color: #d29922; }
+
 tmux-converter-web .tool-name {
   font-weight: 600;
   color: #d29922;